@@ -34,6 +34,7 @@ const EnvVaultWrapTTL = "VAULT_WRAP_TTL"
 const EnvVaultMaxRetries = "VAULT_MAX_RETRIES"
 const EnvVaultToken = "VAULT_TOKEN"
 const EnvVaultMFA = "VAULT_MFA"
+const EnvVaultNamespace = "VAULT_NAMESPACE"
 
 // WrappingLookupFunc is a function that, given an HTTP verb and a path,
 // returns an optional string duration to be used for response wrapping (e.g.
@@ -285,6 +286,7 @@ type Client struct {
 	addr               *url.URL
 	config             *Config
 	token              string
+	namespace          string
 	headers            http.Header
 	wrappingLookupFunc WrappingLookupFunc
 	mfaCreds           []string
@@ -298,7 +300,7 @@ type Client struct {
 //
 // If the environment variable `VAULT_TOKEN` is present, the token will be
 // automatically added to the client. Otherwise, you must manually call
-// `SetToken()`.
+// `SetToken()`. Likewise, the namespace is set from `VAULT_NAMESPACE`.
 func NewClient(c *Config) (*Client, error) {
 	def := DefaultConfig()
 	if def == nil {
@@ -336,6 +338,10 @@ func NewClient(c *Config) (*Client, error) {
 		client.token = token
 	}
 
+	if namespace := os.Getenv(EnvVaultNamespace); namespace != "" {
+		client.namespace = namespace
+	}
+
 	return client, nil
 }
 
@@ -426,6 +432,32 @@ func (c *Client) ClearToken() {
 	c.token = ""
 }
 
+// Namespace returns the namespace requests are scoped to. It will return the
+// empty string for the root namespace.
+func (c *Client) Namespace() string {
+	c.modifyLock.RLock()
+	defer c.modifyLock.RUnlock()
+
+	return c.namespace
+}
+
+// SetNamespace sets the namespace future requests are scoped to. Request
+// paths are relative to the namespace.
+func (c *Client) SetNamespace(namespace string) {
+	c.modifyLock.Lock()
+	defer c.modifyLock.Unlock()
+
+	c.namespace = namespace
+}
+
+// ClearNamespace scopes future requests to the root namespace.
+func (c *Client) ClearNamespace() {
+	c.modifyLock.Lock()
+	defer c.modifyLock.Unlock()
+
+	c.namespace = ""
+}
+
 // SetHeaders sets the headers to be used for future requests.
 func (c *Client) SetHeaders(headers http.Header) {
 	c.modifyLock.Lock()
@@ -492,6 +524,7 @@ func (c *Client) NewRequest(method, requestPath string) *Request {
 			Path:   path.Join(c.addr.Path, requestPath),
 		},
 		ClientToken: c.token,
+		Namespace:   c.namespace,
 		Params:      make(map[string][]string),
 	}
 
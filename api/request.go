@@ -18,6 +18,7 @@ type Request struct {
 	ClientToken   string
 	MFAHeaderVals []string
 	WrapTTL       string
+	Namespace     string
 	Obj           interface{}
 	Body          io.Reader
 	BodySize      int64
@@ -83,6 +84,10 @@ func (r *Request) ToHTTP() (*http.Request, error) {
 		req.Header.Set("X-Vault-Wrap-TTL", r.WrapTTL)
 	}
 
+	if len(r.Namespace) != 0 {
+		req.Header.Set("X-Vault-Namespace", r.Namespace)
+	}
+
 	if len(r.MFAHeaderVals) != 0 {
 		for _, mfaHeaderVal := range r.MFAHeaderVals {
 			req.Header.Add("X-Vault-MFA", mfaHeaderVal)
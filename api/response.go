@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/hashicorp/vault/helper/jsonutil"
@@ -22,7 +23,7 @@ func (r *Response) DecodeJSON(out interface{}) error {
 }
 
 // Error returns an error response if there is one. If there is an error,
-// this will fully consume the response body, but will not close it. The
+// the response body is buffered and replaced so it can still be read. The
 // body must still be closed manually.
 func (r *Response) Error() error {
 	// 200 to 399 are okay status codes. 429 is the code for health status of
@@ -38,6 +39,10 @@ func (r *Response) Error() error {
 		return err
 	}
 
+	// Put the body back so callers proxying the response can still read it
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(bodyBuf.Bytes()))
+
 	// Decode the error response if we can. Note that we wrap the bodyBuf
 	// in a bytes.Reader here so that the JSON decoder doesn't move the
 	// read pointer for the original buffer.
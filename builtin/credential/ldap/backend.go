@@ -6,13 +6,16 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/go-ldap/ldap"
+	"github.com/hashicorp/vault/helper/ldaputil"
 	"github.com/hashicorp/vault/helper/mfa"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	cache "github.com/patrickmn/go-cache"
 )
 
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
@@ -51,14 +54,63 @@ func Backend() *backend {
 		),
 
 		AuthRenew:   b.pathLoginRenew,
+		Invalidate:  b.invalidate,
+		Clean:       b.clean,
 		BackendType: logical.TypeCredential,
 	}
 
+	b.groupCache = cache.New(0, cache.DefaultExpiration)
+
 	return &b
 }
 
 type backend struct {
 	*framework.Backend
+
+	poolLock sync.Mutex
+	pool     *ldaputil.Pool
+
+	// groupCache holds the LDAP groups of users, by user DN
+	groupCache *cache.Cache
+}
+
+// nestedGroupFilter searches for the groups of a user, including nested
+// groups, with the LDAP_MATCHING_RULE_IN_CHAIN matching rule of Active
+// Directory
+const nestedGroupFilter = "(&(objectClass=group)(member:1.2.840.113556.1.4.1941:={{.UserDN}}))"
+
+func (b *backend) invalidate(_ context.Context, key string) {
+	switch key {
+	case "config":
+		b.reset()
+	}
+}
+
+func (b *backend) clean(_ context.Context) {
+	b.reset()
+}
+
+// reset closes the connections to the LDAP servers and flushes the cached
+// groups, which depend on the configuration
+func (b *backend) reset() {
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	if b.pool != nil {
+		b.pool.Close()
+		b.pool = nil
+	}
+	b.groupCache.Flush()
+}
+
+func (b *backend) getPool(cfg *ConfigEntry) *ldaputil.Pool {
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	if b.pool == nil {
+		b.pool = ldaputil.NewPool(cfg.connectionConfig(), cfg.PoolSize, b.Logger())
+	}
+	return b.pool
 }
 
 func EscapeLDAPValue(input string) string {
@@ -105,62 +157,32 @@ func (b *backend) Login(ctx context.Context, req *logical.Request, username stri
 		return nil, logical.ErrorResponse("ldap backend not configured"), nil, nil
 	}
 
-	c, err := cfg.DialLDAP()
-	if err != nil {
-		return nil, logical.ErrorResponse(err.Error()), nil, nil
-	}
-	if c == nil {
-		return nil, logical.ErrorResponse("invalid connection returned from LDAP dial"), nil, nil
-	}
-
-	// Clean connection
-	defer c.Close()
-
-	userBindDN, err := b.getUserBindDN(cfg, c, username)
-	if err != nil {
-		return nil, logical.ErrorResponse(err.Error()), nil, nil
-	}
-
-	if b.Logger().IsDebug() {
-		b.Logger().Debug("user binddn fetched", "username", username, "binddn", userBindDN)
-	}
-
 	if cfg.DenyNullBind && len(password) == 0 {
 		return nil, logical.ErrorResponse("password cannot be of zero length when passwordless binds are being denied"), nil, nil
 	}
 
-	// Try to bind as the login user. This is where the actual authentication takes place.
-	if len(password) > 0 {
-		err = c.Bind(userBindDN, password)
-	} else {
-		err = c.UnauthenticatedBind(userBindDN)
-	}
-	if err != nil {
-		return nil, logical.ErrorResponse(fmt.Sprintf("LDAP bind failed: %v", err)), nil, nil
-	}
-
-	// We re-bind to the BindDN if it's defined because we assume
-	// the BindDN should be the one to search, not the user logging in.
-	if cfg.BindDN != "" && cfg.BindPassword != "" {
-		if err := c.Bind(cfg.BindDN, cfg.BindPassword); err != nil {
-			return nil, logical.ErrorResponse(fmt.Sprintf("Encountered an error while attempting to re-bind with the BindDN User: %s", err.Error())), nil, nil
-		}
-		if b.Logger().IsDebug() {
-			b.Logger().Debug("re-bound to original binddn")
+	pool := b.getPool(cfg)
+	var ldapGroups []string
+	for attempt := 0; ; attempt++ {
+		c, err := pool.Get(ctx)
+		if err != nil {
+			return nil, logical.ErrorResponse(err.Error()), nil, nil
 		}
-	}
 
-	userDN, err := b.getUserDN(cfg, c, userBindDN)
-	if err != nil {
-		return nil, logical.ErrorResponse(err.Error()), nil, nil
-	}
+		ldapGroups, err = b.authenticate(cfg, c.Conn, username, password)
+		if err == nil {
+			pool.Put(c, false)
+			break
+		}
 
-	ldapGroups, err := b.getLdapGroups(cfg, c, userDN, username)
-	if err != nil {
-		return nil, logical.ErrorResponse(err.Error()), nil, nil
-	}
-	if b.Logger().IsDebug() {
-		b.Logger().Debug("groups fetched from server", "num_server_groups", len(ldapGroups), "server_groups", ldapGroups)
+		// Connections closed by the server are only noticed when used, so
+		// retry once on a new connection if this one is broken
+		broken := !ldaputil.Healthy(c.Conn)
+		pool.Put(c, broken)
+		if !broken || attempt > 0 {
+			return nil, logical.ErrorResponse(err.Error()), nil, nil
+		}
+		b.Logger().Debug("retrying login on a new connection", "error", err)
 	}
 
 	ldapResponse := &logical.Response{
@@ -226,6 +248,69 @@ func (b *backend) Login(ctx context.Context, req *logical.Request, username stri
 	return policies, ldapResponse, allGroups, nil
 }
 
+/*
+ * Authenticates the user by binding as the user, and returns the LDAP groups
+ * of the user.
+ */
+func (b *backend) authenticate(cfg *ConfigEntry, c *ldap.Conn, username string, password string) ([]string, error) {
+	userBindDN, err := b.getUserBindDN(cfg, c, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Logger().IsDebug() {
+		b.Logger().Debug("user binddn fetched", "username", username, "binddn", userBindDN)
+	}
+
+	// Try to bind as the login user. This is where the actual authentication takes place.
+	if len(password) > 0 {
+		err = c.Bind(userBindDN, password)
+	} else {
+		err = c.UnauthenticatedBind(userBindDN)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LDAP bind failed: %v", err)
+	}
+
+	// We re-bind to the BindDN if it's defined because we assume
+	// the BindDN should be the one to search, not the user logging in.
+	if cfg.BindDN != "" && cfg.BindPassword != "" {
+		if err := c.Bind(cfg.BindDN, cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("Encountered an error while attempting to re-bind with the BindDN User: %s", err.Error())
+		}
+		if b.Logger().IsDebug() {
+			b.Logger().Debug("re-bound to original binddn")
+		}
+	}
+
+	userDN, err := b.getUserDN(cfg, c, userBindDN)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached, ok := b.groupCache.Get(userDN); ok {
+		ldapGroups := cached.([]string)
+		if b.Logger().IsDebug() {
+			b.Logger().Debug("groups fetched from cache", "num_server_groups", len(ldapGroups), "server_groups", ldapGroups)
+		}
+		return ldapGroups, nil
+	}
+
+	ldapGroups, err := b.getLdapGroups(cfg, c, userDN, username)
+	if err != nil {
+		return nil, err
+	}
+	if b.Logger().IsDebug() {
+		b.Logger().Debug("groups fetched from server", "num_server_groups", len(ldapGroups), "server_groups", ldapGroups)
+	}
+
+	if cfg.GroupCacheTTL > 0 {
+		b.groupCache.Set(userDN, ldapGroups, cfg.GroupCacheTTL)
+	}
+
+	return ldapGroups, nil
+}
+
 /*
  * Parses a distinguished name and returns the CN portion.
  * Given a non-conforming string (such as an already-extracted CN),
@@ -277,7 +362,7 @@ func (b *backend) getUserBindDN(cfg *ConfigEntry, c *ldap.Conn, username string)
 		if b.Logger().IsDebug() {
 			b.Logger().Debug("discovering user", "userdn", cfg.UserDN, "filter", filter)
 		}
-		result, err := c.Search(&ldap.SearchRequest{
+		result, err := cfg.search(c, &ldap.SearchRequest{
 			BaseDN:    cfg.UserDN,
 			Scope:     2, // subtree
 			Filter:    filter,
@@ -312,7 +397,7 @@ func (b *backend) getUserDN(cfg *ConfigEntry, c *ldap.Conn, bindDN string) (stri
 		if b.Logger().IsDebug() {
 			b.Logger().Debug("searching upn", "userdn", cfg.UserDN, "filter", filter)
 		}
-		result, err := c.Search(&ldap.SearchRequest{
+		result, err := cfg.search(c, &ldap.SearchRequest{
 			BaseDN:    cfg.UserDN,
 			Scope:     2, // subtree
 			Filter:    filter,
@@ -348,12 +433,19 @@ func (b *backend) getUserDN(cfg *ConfigEntry, c *ldap.Conn, bindDN string) (stri
  *
  * NOTE - If cfg.GroupFilter is empty, no query is performed and an empty result slice is returned.
  *
+ * If cfg.NestedGroups is set, nestedGroupFilter is used instead of cfg.GroupFilter.
+ *
  */
 func (b *backend) getLdapGroups(cfg *ConfigEntry, c *ldap.Conn, userDN string, username string) ([]string, error) {
 	// retrieve the groups in a string/bool map as a structure to avoid duplicates inside
 	ldapMap := make(map[string]bool)
 
-	if cfg.GroupFilter == "" {
+	groupFilter := cfg.GroupFilter
+	if cfg.NestedGroups {
+		groupFilter = nestedGroupFilter
+	}
+
+	if groupFilter == "" {
 		b.Logger().Warn("groupfilter is empty, will not query server")
 		return make([]string, 0), nil
 	}
@@ -366,12 +458,12 @@ func (b *backend) getLdapGroups(cfg *ConfigEntry, c *ldap.Conn, userDN string, u
 	// If groupfilter was defined, resolve it as a Go template and use the query for
 	// returning the user's groups
 	if b.Logger().IsDebug() {
-		b.Logger().Debug("compiling group filter", "group_filter", cfg.GroupFilter)
+		b.Logger().Debug("compiling group filter", "group_filter", groupFilter)
 	}
 
 	// Parse the configuration as a template.
 	// Example template "(&(objectClass=group)(member:1.2.840.113556.1.4.1941:={{.UserDN}}))"
-	t, err := template.New("queryTemplate").Parse(cfg.GroupFilter)
+	t, err := template.New("queryTemplate").Parse(groupFilter)
 	if err != nil {
 		return nil, fmt.Errorf("LDAP search failed due to template compilation error: %v", err)
 	}
@@ -392,7 +484,7 @@ func (b *backend) getLdapGroups(cfg *ConfigEntry, c *ldap.Conn, userDN string, u
 		b.Logger().Debug("searching", "groupdn", cfg.GroupDN, "rendered_query", renderedQuery.String())
 	}
 
-	result, err := c.Search(&ldap.SearchRequest{
+	result, err := cfg.search(c, &ldap.SearchRequest{
 		BaseDN: cfg.GroupDN,
 		Scope:  2, // subtree
 		Filter: renderedQuery.String(),
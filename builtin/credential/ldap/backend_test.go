@@ -3,11 +3,16 @@ package ldap
 import (
 	"context"
 	"fmt"
+	"net"
 	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/vault/helper/ldaputil"
+	"github.com/hashicorp/vault/helper/ldaputil/ldaptest"
 	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/logical"
 	logicaltest "github.com/hashicorp/vault/logical/testing"
@@ -720,3 +725,213 @@ func testAccStepUserList(t *testing.T, users []string) logicaltest.TestStep {
 		},
 	}
 }
+
+func testLDAPConfigure(t *testing.T, b *backend, storage logical.Storage, url string, data map[string]interface{}) {
+	config := map[string]interface{}{
+		"url":      url,
+		"binddn":   ldaptest.BindDN,
+		"bindpass": ldaptest.BindPassword,
+		"userdn":   ldaptest.UserDN,
+		"userattr": "uid",
+		"groupdn":  ldaptest.GroupDN,
+	}
+	for k, v := range data {
+		config[k] = v
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   storage,
+		Data:      config,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	for group, policy := range map[string]string{"devs": "dev", "engineering": "eng", "ops": "ops"} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "groups/" + group,
+			Storage:   storage,
+			Data:      map[string]interface{}{"policies": policy},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+	}
+}
+
+func testLDAPLogin(t *testing.T, b *backend, storage logical.Storage, username, password string) *logical.Response {
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "login/" + username,
+		Storage:   storage,
+		Data:      map[string]interface{}{"password": password},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func testLDAPLoginPolicies(t *testing.T, b *backend, storage logical.Storage, username, password string, expected []string) {
+	resp := testLDAPLogin(t, b, storage, username, password)
+	if resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login to succeed, got %#v", resp)
+	}
+	if !reflect.DeepEqual(resp.Auth.Policies, expected) {
+		t.Fatalf("expected policies %v, got %v", expected, resp.Auth.Policies)
+	}
+}
+
+func TestLdapAuthBackend_ConnectionPool(t *testing.T) {
+	server := ldaptest.NewServer(t)
+	defer server.Close()
+
+	b, storage := createBackendWithStorage(t)
+	testLDAPConfigure(t, b, storage, server.URL, map[string]interface{}{
+		"pool_size": 2,
+	})
+
+	for i := 0; i < 3; i++ {
+		testLDAPLoginPolicies(t, b, storage, "alice", "alicepw", []string{"dev"})
+	}
+	if resp := testLDAPLogin(t, b, storage, "alice", "wrong"); !resp.IsError() {
+		t.Fatalf("expected login with a wrong password to fail")
+	}
+	testLDAPLoginPolicies(t, b, storage, "bob", "bobpw", []string{"ops"})
+	if connections := server.Connections(); connections != 1 {
+		t.Fatalf("expected sequential logins to share a connection, got %d connections", connections)
+	}
+
+	// Concurrent logins use at most pool_size connections
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := testLDAPLogin(t, b, storage, "alice", "alicepw")
+			if resp == nil || resp.Auth == nil {
+				t.Errorf("expected login to succeed, got %#v", resp)
+			}
+		}()
+	}
+	wg.Wait()
+	if connections := server.Connections(); connections > 2 {
+		t.Fatalf("expected at most 2 connections, got %d", connections)
+	}
+
+	// Connections closed by the server are replaced
+	server.CloseConns()
+	testLDAPLoginPolicies(t, b, storage, "alice", "alicepw", []string{"dev"})
+}
+
+func TestLdapAuthBackend_Failover(t *testing.T) {
+	primary := ldaptest.NewServer(t)
+	defer primary.Close()
+	secondary := ldaptest.NewServer(t)
+	defer secondary.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := "ldap://" + listener.Addr().String()
+	listener.Close()
+
+	b, storage := createBackendWithStorage(t)
+	testLDAPConfigure(t, b, storage, strings.Join([]string{unreachable, primary.URL, secondary.URL}, ","), nil)
+
+	testLDAPLoginPolicies(t, b, storage, "alice", "alicepw", []string{"dev"})
+	if connections := primary.Connections(); connections != 1 {
+		t.Fatalf("expected a connection to the primary server, got %d", connections)
+	}
+
+	// The unreachable URL is tried last
+	order := b.pool.DialOrder(time.Now())
+	if !reflect.DeepEqual(order, []string{primary.URL, secondary.URL, unreachable}) {
+		t.Fatalf("unexpected dial order %v", order)
+	}
+	order = b.pool.DialOrder(time.Now().Add(ldaputil.FailedURLRetryInterval))
+	if !reflect.DeepEqual(order, []string{unreachable, primary.URL, secondary.URL}) {
+		t.Fatalf("unexpected dial order after the retry interval %v", order)
+	}
+
+	primary.Close()
+	testLDAPLoginPolicies(t, b, storage, "alice", "alicepw", []string{"dev"})
+	if connections := secondary.Connections(); connections != 1 {
+		t.Fatalf("expected a connection to the secondary server, got %d", connections)
+	}
+}
+
+func TestLdapAuthBackend_GroupCache(t *testing.T) {
+	server := ldaptest.NewServer(t)
+	defer server.Close()
+
+	b, storage := createBackendWithStorage(t)
+	testLDAPConfigure(t, b, storage, server.URL, nil)
+
+	testLDAPLoginPolicies(t, b, storage, "alice", "alicepw", []string{"dev"})
+	testLDAPLoginPolicies(t, b, storage, "alice", "alicepw", []string{"dev"})
+	if groupSearches := server.Searches(ldaptest.GroupDN); groupSearches != 2 {
+		t.Fatalf("expected 2 group searches, got %d", groupSearches)
+	}
+
+	testLDAPConfigure(t, b, storage, server.URL, map[string]interface{}{
+		"group_cache_ttl": "1h",
+	})
+	testLDAPLoginPolicies(t, b, storage, "alice", "alicepw", []string{"dev"})
+	testLDAPLoginPolicies(t, b, storage, "alice", "alicepw", []string{"dev"})
+	if groupSearches := server.Searches(ldaptest.GroupDN); groupSearches != 3 {
+		t.Fatalf("expected cached groups to be used, got %d group searches", groupSearches)
+	}
+
+	// The password is still checked
+	if resp := testLDAPLogin(t, b, storage, "alice", "wrong"); !resp.IsError() {
+		t.Fatalf("expected login with a wrong password to fail")
+	}
+
+	// Updating the configuration flushes the cache
+	testLDAPConfigure(t, b, storage, server.URL, map[string]interface{}{
+		"group_cache_ttl": "1h",
+		"nested_groups":   true,
+	})
+	testLDAPLoginPolicies(t, b, storage, "alice", "alicepw", []string{"dev", "eng"})
+	if groupSearches := server.Searches(ldaptest.GroupDN); groupSearches != 4 {
+		t.Fatalf("expected 4 group searches, got %d", groupSearches)
+	}
+}
+
+func TestLdapAuthBackend_NestedGroups(t *testing.T) {
+	server := ldaptest.NewServer(t)
+	defer server.Close()
+
+	b, storage := createBackendWithStorage(t)
+	testLDAPConfigure(t, b, storage, server.URL, map[string]interface{}{
+		"nested_groups": true,
+	})
+
+	testLDAPLoginPolicies(t, b, storage, "alice", "alicepw", []string{"dev", "eng"})
+	testLDAPLoginPolicies(t, b, storage, "bob", "bobpw", []string{"ops"})
+}
+
+func TestLdapAuthBackend_PagedSearch(t *testing.T) {
+	server := ldaptest.NewServer(t)
+	server.MaxResults = 1
+	defer server.Close()
+
+	b, storage := createBackendWithStorage(t)
+	testLDAPConfigure(t, b, storage, server.URL, map[string]interface{}{
+		"nested_groups": true,
+	})
+	if resp := testLDAPLogin(t, b, storage, "alice", "alicepw"); !resp.IsError() {
+		t.Fatalf("expected unpaged search to exceed the size limit")
+	}
+
+	testLDAPConfigure(t, b, storage, server.URL, map[string]interface{}{
+		"nested_groups": true,
+		"page_size":     1,
+	})
+	testLDAPLoginPolicies(t, b, storage, "alice", "alicepw", []string{"dev", "eng"})
+}
@@ -6,15 +6,15 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
-	"net"
-	"net/url"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/go-ldap/ldap"
 	log "github.com/hashicorp/go-hclog"
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/ldaputil"
 	"github.com/hashicorp/vault/helper/tlsutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -121,6 +121,29 @@ Default: cn`,
 				Type:        framework.TypeBool,
 				Description: "If true, case sensitivity will be used when comparing usernames and groups for matching policies.",
 			},
+
+			"nested_groups": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If true, the groups of the user are searched for with the
+LDAP_MATCHING_RULE_IN_CHAIN matching rule of Active Directory, which includes
+nested groups, instead of <groupfilter>.`,
+			},
+
+			"page_size": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Description: "If greater than 0, searches are paged with this page size, for directories limiting the size of search results (optional)",
+			},
+
+			"pool_size": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Default:     5,
+				Description: "Maximum number of connections to the LDAP servers in use at once. Logins wait for a connection when all are in use. Defaults to 5",
+			},
+
+			"group_cache_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Duration for which the LDAP groups of a user are cached. If 0, groups are searched for on every login (optional)",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -218,6 +241,10 @@ func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, d *f
 			"tls_min_version":      cfg.TLSMinVersion,
 			"tls_max_version":      cfg.TLSMaxVersion,
 			"case_sensitive_names": *cfg.CaseSensitiveNames,
+			"nested_groups":        cfg.NestedGroups,
+			"page_size":            cfg.PageSize,
+			"pool_size":            cfg.PoolSize,
+			"group_cache_ttl":      cfg.GroupCacheTTL.Seconds(),
 		},
 	}
 	return resp, nil
@@ -338,6 +365,23 @@ func (b *backend) newConfigEntry(d *framework.FieldData) (*ConfigEntry, error) {
 		*cfg.CaseSensitiveNames = caseSensitiveNames.(bool)
 	}
 
+	cfg.NestedGroups = d.Get("nested_groups").(bool)
+
+	cfg.PageSize = d.Get("page_size").(int)
+	if cfg.PageSize < 0 {
+		return nil, fmt.Errorf("'page_size' cannot be negative")
+	}
+
+	cfg.PoolSize = d.Get("pool_size").(int)
+	if cfg.PoolSize < 1 {
+		return nil, fmt.Errorf("'pool_size' must be at least 1")
+	}
+
+	cfg.GroupCacheTTL = time.Duration(d.Get("group_cache_ttl").(int)) * time.Second
+	if cfg.GroupCacheTTL < 0 {
+		return nil, fmt.Errorf("'group_cache_ttl' cannot be negative")
+	}
+
 	return cfg, nil
 }
 
@@ -363,6 +407,9 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 		return nil, err
 	}
 
+	// Connections and cached groups depend on the configuration
+	b.reset()
+
 	return nil, nil
 }
 
@@ -385,92 +432,36 @@ type ConfigEntry struct {
 	TLSMinVersion      string `json:"tls_min_version"`
 	TLSMaxVersion      string `json:"tls_max_version"`
 	CaseSensitiveNames *bool  `json:"case_sensitive_names,omitempty`
-}
 
-func (c *ConfigEntry) GetTLSConfig(host string) (*tls.Config, error) {
-	tlsConfig := &tls.Config{
-		ServerName: host,
-	}
+	NestedGroups  bool          `json:"nested_groups"`
+	PageSize      int           `json:"page_size"`
+	PoolSize      int           `json:"pool_size"`
+	GroupCacheTTL time.Duration `json:"group_cache_ttl"`
+}
 
-	if c.TLSMinVersion != "" {
-		tlsMinVersion, ok := tlsutil.TLSLookup[c.TLSMinVersion]
-		if !ok {
-			return nil, fmt.Errorf("invalid 'tls_min_version' in config")
-		}
-		tlsConfig.MinVersion = tlsMinVersion
-	}
-
-	if c.TLSMaxVersion != "" {
-		tlsMaxVersion, ok := tlsutil.TLSLookup[c.TLSMaxVersion]
-		if !ok {
-			return nil, fmt.Errorf("invalid 'tls_max_version' in config")
-		}
-		tlsConfig.MaxVersion = tlsMaxVersion
+// connectionConfig returns the settings used to connect to the LDAP servers
+func (c *ConfigEntry) connectionConfig() *ldaputil.ConnectionConfig {
+	return &ldaputil.ConnectionConfig{
+		URL:           c.Url,
+		Certificate:   c.Certificate,
+		InsecureTLS:   c.InsecureTLS,
+		StartTLS:      c.StartTLS,
+		TLSMinVersion: c.TLSMinVersion,
+		TLSMaxVersion: c.TLSMaxVersion,
 	}
+}
 
-	if c.InsecureTLS {
-		tlsConfig.InsecureSkipVerify = true
-	}
-	if c.Certificate != "" {
-		caPool := x509.NewCertPool()
-		ok := caPool.AppendCertsFromPEM([]byte(c.Certificate))
-		if !ok {
-			return nil, fmt.Errorf("could not append CA certificate")
-		}
-		tlsConfig.RootCAs = caPool
-	}
-	return tlsConfig, nil
+func (c *ConfigEntry) GetTLSConfig(host string) (*tls.Config, error) {
+	return c.connectionConfig().TLSConfig(host)
 }
 
 func (c *ConfigEntry) DialLDAP() (*ldap.Conn, error) {
 	var retErr *multierror.Error
 	var conn *ldap.Conn
-	urls := strings.Split(c.Url, ",")
-	for _, uut := range urls {
-		u, err := url.Parse(uut)
-		if err != nil {
-			retErr = multierror.Append(retErr, fmt.Errorf("error parsing url %q: %s", uut, err.Error()))
-			continue
-		}
-		host, port, err := net.SplitHostPort(u.Host)
-		if err != nil {
-			host = u.Host
-		}
-
-		var tlsConfig *tls.Config
-		switch u.Scheme {
-		case "ldap":
-			if port == "" {
-				port = "389"
-			}
-			conn, err = ldap.Dial("tcp", net.JoinHostPort(host, port))
-			if err != nil {
-				break
-			}
-			if conn == nil {
-				err = fmt.Errorf("empty connection after dialing")
-				break
-			}
-			if c.StartTLS {
-				tlsConfig, err = c.GetTLSConfig(host)
-				if err != nil {
-					break
-				}
-				err = conn.StartTLS(tlsConfig)
-			}
-		case "ldaps":
-			if port == "" {
-				port = "636"
-			}
-			tlsConfig, err = c.GetTLSConfig(host)
-			if err != nil {
-				break
-			}
-			conn, err = ldap.DialTLS("tcp", net.JoinHostPort(host, port), tlsConfig)
-		default:
-			retErr = multierror.Append(retErr, fmt.Errorf("invalid LDAP scheme in url %q", net.JoinHostPort(host, port)))
-			continue
-		}
+	connConfig := c.connectionConfig()
+	for _, uut := range connConfig.URLs() {
+		var err error
+		conn, err = connConfig.DialURL(uut)
 		if err == nil {
 			if retErr != nil {
 				if c.logger.IsDebug() {
@@ -486,6 +477,11 @@ func (c *ConfigEntry) DialLDAP() (*ldap.Conn, error) {
 	return conn, retErr.ErrorOrNil()
 }
 
+// search runs a search request, paging the results if a page size is set
+func (c *ConfigEntry) search(conn *ldap.Conn, searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	return ldaputil.Search(conn, searchRequest, c.PageSize)
+}
+
 /*
  * Returns FieldData describing our ConfigEntry struct schema
  */
@@ -519,6 +515,11 @@ case, an unencrypted connection will be made with a default port of 389, unless
 the "starttls" parameter is set to true, in which case TLS will be used. In the
 latter case, a SSL connection will be established with a default port of 636.
 
+Multiple URLs are tried in order. A URL which fails to connect is tried last
+for a minute, after which the configured order applies again. Connections are
+kept open in a pool of at most "pool_size" connections, and the LDAP groups of
+users can be cached for "group_cache_ttl".
+
 ## A NOTE ON ESCAPING
 
 It is up to the administrator to provide properly escaped DNs. This includes
@@ -3,6 +3,7 @@ package userpass
 import (
 	"context"
 
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/helper/mfa"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -30,10 +31,13 @@ func Backend() *backend {
 		},
 
 		Paths: append([]*framework.Path{
+			pathConfig(&b),
 			pathUsers(&b),
 			pathUsersList(&b),
 			pathUserPolicies(&b),
 			pathUserPassword(&b),
+			pathUserChangePassword(&b),
+			pathUserUnlock(&b),
 		},
 			mfa.MFAPaths(b.Backend, pathLogin(&b))...,
 		),
@@ -42,11 +46,17 @@ func Backend() *backend {
 		BackendType: logical.TypeCredential,
 	}
 
+	b.userLocks = locksutil.CreateLocks()
+
 	return &b
 }
 
 type backend struct {
 	*framework.Backend
+
+	// userLocks serialize the updates of a user, such as the failed login
+	// count and the password history
+	userLocks []*locksutil.LockEntry
 }
 
 const backendHelp = `
@@ -57,4 +67,8 @@ are supported.
 The username/password combination is configured using the "users/"
 endpoints by a user with root access. Authentication is then done
 by supplying the two fields for "login".
+
+The "config" endpoint sets the policy passwords must comply with and
+locks users out after too many failed logins. Users can change their
+own password with the "users/<username>/change-password" endpoint.
 `
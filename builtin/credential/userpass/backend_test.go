@@ -276,3 +276,222 @@ func testAccStepReadUser(t *testing.T, name string, policies string) logicaltest
 		},
 	}
 }
+
+func TestBackend_passwordPolicy(t *testing.T) {
+	b, err := Factory(context.Background(), &logical.BackendConfig{
+		Logger: nil,
+		System: &logical.StaticSystemView{
+			DefaultLeaseTTLVal: testSysTTL,
+			MaxLeaseTTLVal:     testSysMaxTTL,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create backend: %s", err)
+	}
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testConfigWrite(t, map[string]interface{}{
+				"password_min_length":        8,
+				"password_require_uppercase": true,
+				"password_require_digit":     true,
+				"password_history":           2,
+			}),
+			testUsersWrite(t, "web", map[string]interface{}{"password": "Short1"}, true),
+			testUsersWrite(t, "web", map[string]interface{}{"password": "nouppercase1"}, true),
+			testUsersWrite(t, "web", map[string]interface{}{"password": "NoDigitsHere"}, true),
+			testAccStepUser(t, "web", "Password1", "foo"),
+			testUsersWrite(t, "web", map[string]interface{}{"password": "Password1"}, true),
+			testUpdatePassword(t, "web", "Password2"),
+			testUsersWrite(t, "web", map[string]interface{}{"password": "Password1"}, true),
+			testUsersWrite(t, "web", map[string]interface{}{"password": "Password2"}, true),
+			testUpdatePassword(t, "web", "Password3"),
+			testUpdatePassword(t, "web", "Password1"),
+			testAccStepLogin(t, "web", "Password1", []string{"default", "foo"}),
+		},
+	})
+}
+
+func TestBackend_lockout(t *testing.T) {
+	storage := &logical.InmemStorage{}
+	b, err := Factory(context.Background(), &logical.BackendConfig{
+		Logger: nil,
+		System: &logical.StaticSystemView{
+			DefaultLeaseTTLVal: testSysTTL,
+			MaxLeaseTTLVal:     testSysMaxTTL,
+		},
+		StorageView: storage,
+	})
+	if err != nil {
+		t.Fatalf("Unable to create backend: %s", err)
+	}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil && err != logical.ErrInvalidRequest {
+			t.Fatalf("err: %v", err)
+		}
+		return resp
+	}
+	login := func(password string) *logical.Response {
+		return request(logical.UpdateOperation, "login/web", map[string]interface{}{"password": password})
+	}
+
+	request(logical.UpdateOperation, "config", map[string]interface{}{
+		"lockout_threshold": 2,
+		"lockout_duration":  "1h",
+	})
+	request(logical.UpdateOperation, "users/web", map[string]interface{}{"password": "password"})
+
+	if resp := login("wrong"); !resp.IsError() {
+		t.Fatalf("expected login with a wrong password to fail")
+	}
+	if resp := login("password"); resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login to succeed, got %#v", resp)
+	}
+
+	// A successful login resets the count of failed logins
+	login("wrong")
+	if resp := login("password"); resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login to succeed, got %#v", resp)
+	}
+
+	login("wrong")
+	login("wrong")
+	// The lockout is not revealed, so that it can't be used to tell which
+	// users exist
+	locked := login("password")
+	unknown := request(logical.UpdateOperation, "login/unknown", map[string]interface{}{"password": "password"})
+	if !locked.IsError() || !unknown.IsError() {
+		t.Fatalf("expected locked out user to fail to log in")
+	}
+	if locked.Error().Error() != unknown.Error().Error() {
+		t.Fatalf("expected the same error as an unknown user, got %q and %q", locked.Error(), unknown.Error())
+	}
+	resp := request(logical.ReadOperation, "users/web", nil)
+	if resp.Data["locked"] != true || resp.Data["failed_logins"] != 2 {
+		t.Fatalf("expected user to be locked out, got %#v", resp.Data)
+	}
+
+	request(logical.UpdateOperation, "users/web/unlock", nil)
+	resp = request(logical.ReadOperation, "users/web", nil)
+	if resp.Data["locked"] != false || resp.Data["failed_logins"] != 0 {
+		t.Fatalf("expected user to be unlocked, got %#v", resp.Data)
+	}
+	if resp := login("password"); resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login to succeed, got %#v", resp)
+	}
+
+	// Expired lockouts no longer apply
+	user, err := b.(*backend).user(context.Background(), storage, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.FailedLogins = 2
+	user.LockedAt = time.Now().Add(-2 * time.Hour)
+	if err := b.(*backend).setUser(context.Background(), storage, "web", user); err != nil {
+		t.Fatal(err)
+	}
+	if resp := login("password"); resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login to succeed, got %#v", resp)
+	}
+}
+
+func TestBackend_changePassword(t *testing.T) {
+	storage := &logical.InmemStorage{}
+	b, err := Factory(context.Background(), &logical.BackendConfig{
+		Logger: nil,
+		System: &logical.StaticSystemView{
+			DefaultLeaseTTLVal: testSysTTL,
+			MaxLeaseTTLVal:     testSysMaxTTL,
+			EntityVal: &logical.Entity{
+				ID: "entity",
+				Aliases: []*logical.Alias{
+					&logical.Alias{
+						MountAccessor: "userpass_accessor",
+						Name:          "web",
+					},
+				},
+			},
+		},
+		StorageView: storage,
+	})
+	if err != nil {
+		t.Fatalf("Unable to create backend: %s", err)
+	}
+
+	changePassword := func(username, entityID, mountAccessor string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation:     logical.UpdateOperation,
+			Path:          "users/" + username + "/change-password",
+			Storage:       storage,
+			Data:          data,
+			EntityID:      entityID,
+			MountAccessor: mountAccessor,
+		})
+	}
+
+	for _, username := range []string{"web", "web2"} {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "users/" + username,
+			Storage:   storage,
+			Data:      map[string]interface{}{"password": "password"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := map[string]interface{}{
+		"old_password": "password",
+		"new_password": "newpassword",
+	}
+
+	// Only the entity of the user on this mount can change its password
+	if _, err := changePassword("web2", "entity", "userpass_accessor", data); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied for another user, got %v", err)
+	}
+	if _, err := changePassword("web", "other", "userpass_accessor", data); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied for another entity, got %v", err)
+	}
+	if _, err := changePassword("web", "entity", "other_accessor", data); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied for another mount, got %v", err)
+	}
+
+	resp, err := changePassword("web", "entity", "userpass_accessor", map[string]interface{}{
+		"old_password": "wrong",
+		"new_password": "newpassword",
+	})
+	if err != logical.ErrInvalidRequest || !resp.IsError() {
+		t.Fatalf("expected a wrong old password to be rejected, got %#v, %v", resp, err)
+	}
+
+	if _, err := changePassword("web", "entity", "userpass_accessor", data); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "login/web",
+		Storage:   storage,
+		Data:      map[string]interface{}{"password": "newpassword"},
+	})
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login with the new password to succeed, got %#v, %v", resp, err)
+	}
+}
+
+func testConfigWrite(t *testing.T, data map[string]interface{}) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Data:      data,
+	}
+}
@@ -0,0 +1,214 @@
+package userpass
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"unicode"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config$",
+		Fields: map[string]*framework.FieldSchema{
+			"password_min_length": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Description: "Minimum length of passwords.",
+			},
+			"password_require_uppercase": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Require passwords to contain an uppercase letter.",
+			},
+			"password_require_lowercase": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Require passwords to contain a lowercase letter.",
+			},
+			"password_require_digit": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Require passwords to contain a digit.",
+			},
+			"password_require_symbol": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Require passwords to contain a character which is neither a letter nor a digit.",
+			},
+			"password_history": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Description: "Number of the last passwords of a user, including the current one, which cannot be reused.",
+			},
+			"lockout_threshold": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Description: "Number of consecutive failed logins after which the user is locked out. If 0, users are never locked out.",
+			},
+			"lockout_duration": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Duration of the lockout. If 0, users stay locked out until unlocked.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigRead,
+			logical.UpdateOperation: b.pathConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigHelpSyn,
+		HelpDescription: pathConfigHelpDesc,
+	}
+}
+
+func (b *backend) config(ctx context.Context, s logical.Storage) (*configEntry, error) {
+	entry, err := s.Get(ctx, "config")
+	if err != nil {
+		return nil, err
+	}
+
+	var result configEntry
+	if entry != nil {
+		if err := entry.DecodeJSON(&result); err != nil {
+			return nil, err
+		}
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"password_min_length":        config.PasswordMinLength,
+			"password_require_uppercase": config.PasswordRequireUppercase,
+			"password_require_lowercase": config.PasswordRequireLowercase,
+			"password_require_digit":     config.PasswordRequireDigit,
+			"password_require_symbol":    config.PasswordRequireSymbol,
+			"password_history":           config.PasswordHistory,
+			"lockout_threshold":          config.LockoutThreshold,
+			"lockout_duration":           config.LockoutDuration.Seconds(),
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if minLengthRaw, ok := d.GetOk("password_min_length"); ok {
+		config.PasswordMinLength = minLengthRaw.(int)
+	}
+	if requireRaw, ok := d.GetOk("password_require_uppercase"); ok {
+		config.PasswordRequireUppercase = requireRaw.(bool)
+	}
+	if requireRaw, ok := d.GetOk("password_require_lowercase"); ok {
+		config.PasswordRequireLowercase = requireRaw.(bool)
+	}
+	if requireRaw, ok := d.GetOk("password_require_digit"); ok {
+		config.PasswordRequireDigit = requireRaw.(bool)
+	}
+	if requireRaw, ok := d.GetOk("password_require_symbol"); ok {
+		config.PasswordRequireSymbol = requireRaw.(bool)
+	}
+	if historyRaw, ok := d.GetOk("password_history"); ok {
+		config.PasswordHistory = historyRaw.(int)
+	}
+	if thresholdRaw, ok := d.GetOk("lockout_threshold"); ok {
+		config.LockoutThreshold = thresholdRaw.(int)
+	}
+	if durationRaw, ok := d.GetOk("lockout_duration"); ok {
+		config.LockoutDuration = time.Duration(durationRaw.(int)) * time.Second
+	}
+
+	switch {
+	case config.PasswordMinLength < 0:
+		return logical.ErrorResponse("password_min_length cannot be negative"), nil
+	case config.PasswordHistory < 0:
+		return logical.ErrorResponse("password_history cannot be negative"), nil
+	case config.LockoutThreshold < 0:
+		return logical.ErrorResponse("lockout_threshold cannot be negative"), nil
+	case config.LockoutDuration < 0:
+		return logical.ErrorResponse("lockout_duration cannot be negative"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("config", config)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, req.Storage.Put(ctx, entry)
+}
+
+// configEntry holds the password policy and the lockout settings of the
+// mount
+type configEntry struct {
+	PasswordMinLength        int  `json:"password_min_length"`
+	PasswordRequireUppercase bool `json:"password_require_uppercase"`
+	PasswordRequireLowercase bool `json:"password_require_lowercase"`
+	PasswordRequireDigit     bool `json:"password_require_digit"`
+	PasswordRequireSymbol    bool `json:"password_require_symbol"`
+
+	// PasswordHistory is the number of the last passwords which cannot be
+	// reused, including the current one
+	PasswordHistory int `json:"password_history"`
+
+	LockoutThreshold int           `json:"lockout_threshold"`
+	LockoutDuration  time.Duration `json:"lockout_duration"`
+}
+
+// validatePassword checks the password against the password policy
+func (c *configEntry) validatePassword(password string) error {
+	if len([]rune(password)) < c.PasswordMinLength {
+		return fmt.Errorf("password must be at least %d characters long", c.PasswordMinLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r):
+			hasSymbol = true
+		}
+	}
+
+	switch {
+	case c.PasswordRequireUppercase && !hasUpper:
+		return fmt.Errorf("password must contain an uppercase letter")
+	case c.PasswordRequireLowercase && !hasLower:
+		return fmt.Errorf("password must contain a lowercase letter")
+	case c.PasswordRequireDigit && !hasDigit:
+		return fmt.Errorf("password must contain a digit")
+	case c.PasswordRequireSymbol && !hasSymbol:
+		return fmt.Errorf("password must contain a symbol")
+	}
+
+	return nil
+}
+
+// lockedOut returns whether the user is locked out at the given time
+func (c *configEntry) lockedOut(user *UserEntry, now time.Time) bool {
+	if user.LockedAt.IsZero() {
+		return false
+	}
+	return c.LockoutDuration == 0 || now.Before(user.LockedAt.Add(c.LockoutDuration))
+}
+
+const pathConfigHelpSyn = `
+Configure the password policy and the lockout of users.
+`
+
+const pathConfigHelpDesc = `
+This endpoint configures the policy passwords must comply with when they are
+set, and how many consecutive failed logins lock a user out, and for how long.
+Locked out users can be unlocked with the "users/<username>/unlock" endpoint.
+`
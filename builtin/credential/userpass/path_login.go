@@ -2,14 +2,14 @@ package userpass
 
 import (
 	"context"
-	"crypto/subtle"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func pathLogin(b *backend) *framework.Path {
@@ -60,6 +60,10 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 		return nil, fmt.Errorf("missing password")
 	}
 
+	lock := locksutil.LockForKey(b.userLocks, username)
+	lock.Lock()
+	defer lock.Unlock()
+
 	// Get the user and validate auth
 	user, err := b.user(ctx, req.Storage, username)
 	if err != nil {
@@ -69,17 +73,12 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 		return logical.ErrorResponse("invalid username or password"), nil
 	}
 
-	// Check for a password match. Check for a hash collision for Vault 0.2+,
-	// but handle the older legacy passwords with a constant time comparison.
-	passwordBytes := []byte(password)
-	if user.PasswordHash != nil {
-		if err := bcrypt.CompareHashAndPassword(user.PasswordHash, passwordBytes); err != nil {
-			return logical.ErrorResponse("invalid username or password"), nil
-		}
-	} else {
-		if subtle.ConstantTimeCompare([]byte(user.Password), passwordBytes) != 1 {
-			return logical.ErrorResponse("invalid username or password"), nil
-		}
+	userErr, intErr := b.checkUserPassword(ctx, req.Storage, username, user, password)
+	if intErr != nil {
+		return nil, intErr
+	}
+	if userErr != nil {
+		return logical.ErrorResponse(userErr.Error()), nil
 	}
 
 	return &logical.Response{
@@ -101,6 +100,55 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 	}, nil
 }
 
+// checkUserPassword checks the password of a user which is not locked out.
+// Failed checks count toward the lockout of the user, and a successful one
+// resets the count. The caller must hold the lock of the user. The first
+// returned error is a user error, the second an internal one.
+func (b *backend) checkUserPassword(ctx context.Context, s logical.Storage, username string, user *UserEntry, password string) (error, error) {
+	config, err := b.config(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// A locked out user gets the same error as a wrong password so that the
+	// lockout doesn't tell which usernames exist
+	now := time.Now()
+	if config.lockedOut(user, now) {
+		b.Logger().Warn("login attempt for locked out user", "username", username)
+		return fmt.Errorf("invalid username or password"), nil
+	}
+
+	if !passwordMatches(user, password) {
+		if config.LockoutThreshold == 0 {
+			return fmt.Errorf("invalid username or password"), nil
+		}
+
+		// Failed logins before an expired lockout no longer count
+		if !user.LockedAt.IsZero() {
+			user.FailedLogins = 0
+			user.LockedAt = time.Time{}
+		}
+		user.FailedLogins++
+		if user.FailedLogins >= config.LockoutThreshold {
+			user.LockedAt = now
+		}
+		if err := b.setUser(ctx, s, username, user); err != nil {
+			return nil, err
+		}
+		return fmt.Errorf("invalid username or password"), nil
+	}
+
+	if user.FailedLogins != 0 || !user.LockedAt.IsZero() {
+		user.FailedLogins = 0
+		user.LockedAt = time.Time{}
+		if err := b.setUser(ctx, s, username, user); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
 func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	// Get the user
 	user, err := b.user(ctx, req.Storage, req.Auth.Metadata["username"])
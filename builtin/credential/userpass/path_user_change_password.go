@@ -0,0 +1,123 @@
+package userpass
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathUserChangePassword(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "users/" + framework.GenericNameRegex("username") + "/change-password$",
+		Fields: map[string]*framework.FieldSchema{
+			"username": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Username for this user.",
+			},
+
+			"old_password": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Current password of the user.",
+			},
+
+			"new_password": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "New password of the user.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathUserChangePasswordUpdate,
+		},
+
+		HelpSynopsis:    pathUserChangePasswordHelpSyn,
+		HelpDescription: pathUserChangePasswordHelpDesc,
+	}
+}
+
+func (b *backend) pathUserChangePasswordUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	username := strings.ToLower(d.Get("username").(string))
+
+	// Only the user itself can change its password, so the entity of the
+	// token must have been created by a login as this user on this mount
+	allowed, err := b.entityIsUser(req, username)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, logical.ErrPermissionDenied
+	}
+
+	oldPassword := d.Get("old_password").(string)
+	if oldPassword == "" {
+		return logical.ErrorResponse("missing old_password"), logical.ErrInvalidRequest
+	}
+
+	lock := locksutil.LockForKey(b.userLocks, username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	userEntry, err := b.user(ctx, req.Storage, username)
+	if err != nil {
+		return nil, err
+	}
+	if userEntry == nil {
+		return nil, logical.ErrPermissionDenied
+	}
+
+	userErr, intErr := b.checkUserPassword(ctx, req.Storage, username, userEntry, oldPassword)
+	if intErr != nil {
+		return nil, intErr
+	}
+	if userErr != nil {
+		return logical.ErrorResponse(userErr.Error()), logical.ErrInvalidRequest
+	}
+
+	userErr, intErr = b.setUserPassword(ctx, req.Storage, userEntry, d.Get("new_password").(string))
+	if intErr != nil {
+		return nil, intErr
+	}
+	if userErr != nil {
+		return logical.ErrorResponse(userErr.Error()), logical.ErrInvalidRequest
+	}
+
+	return nil, b.setUser(ctx, req.Storage, username, userEntry)
+}
+
+// entityIsUser returns whether the entity of the request has an alias for
+// the user on this mount
+func (b *backend) entityIsUser(req *logical.Request, username string) (bool, error) {
+	if req.EntityID == "" {
+		return false, nil
+	}
+
+	entity, err := b.System().EntityInfo(req.EntityID)
+	if err != nil {
+		return false, err
+	}
+	if entity == nil {
+		return false, nil
+	}
+
+	for _, alias := range entity.Aliases {
+		if alias.MountAccessor == req.MountAccessor && alias.Name == username {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+const pathUserChangePasswordHelpSyn = `
+Change the password of the calling user.
+`
+
+const pathUserChangePasswordHelpDesc = `
+This endpoint allows users to change their own password, by providing their
+current password. It can only be called with a token whose entity has logged
+in as the user on this mount. The new password must comply with the password
+policy set on the "config" endpoint.
+`
@@ -2,10 +2,13 @@ package userpass
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -35,7 +38,11 @@ func pathUserPassword(b *backend) *framework.Path {
 }
 
 func (b *backend) pathUserPasswordUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	username := d.Get("username").(string)
+	username := strings.ToLower(d.Get("username").(string))
+
+	lock := locksutil.LockForKey(b.userLocks, username)
+	lock.Lock()
+	defer lock.Unlock()
 
 	userEntry, err := b.user(ctx, req.Storage, username)
 	if err != nil {
@@ -45,9 +52,9 @@ func (b *backend) pathUserPasswordUpdate(ctx context.Context, req *logical.Reque
 		return nil, fmt.Errorf("username does not exist")
 	}
 
-	userErr, intErr := b.updateUserPassword(req, d, userEntry)
+	userErr, intErr := b.updateUserPassword(ctx, req, d, userEntry)
 	if intErr != nil {
-		return nil, err
+		return nil, intErr
 	}
 	if userErr != nil {
 		return logical.ErrorResponse(userErr.Error()), logical.ErrInvalidRequest
@@ -56,24 +63,79 @@ func (b *backend) pathUserPasswordUpdate(ctx context.Context, req *logical.Reque
 	return nil, b.setUser(ctx, req.Storage, username, userEntry)
 }
 
-func (b *backend) updateUserPassword(req *logical.Request, d *framework.FieldData, userEntry *UserEntry) (error, error) {
-	password := d.Get("password").(string)
+func (b *backend) updateUserPassword(ctx context.Context, req *logical.Request, d *framework.FieldData, userEntry *UserEntry) (error, error) {
+	return b.setUserPassword(ctx, req.Storage, userEntry, d.Get("password").(string))
+}
+
+// setUserPassword checks the password against the password policy and the
+// password history of the user, and sets it. The first returned error is a
+// user error, the second an internal one.
+func (b *backend) setUserPassword(ctx context.Context, s logical.Storage, userEntry *UserEntry, password string) (error, error) {
 	if password == "" {
 		return fmt.Errorf("missing password"), nil
 	}
+
+	config, err := b.config(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.validatePassword(password); err != nil {
+		return err, nil
+	}
+
+	// The current password counts toward the history
+	if config.PasswordHistory > 0 {
+		if passwordMatches(userEntry, password) {
+			return fmt.Errorf("password was used recently"), nil
+		}
+		for i, previous := range userEntry.PasswordHistory {
+			if i >= config.PasswordHistory-1 {
+				break
+			}
+			if bcrypt.CompareHashAndPassword(previous, []byte(password)) == nil {
+				return fmt.Errorf("password was used recently"), nil
+			}
+		}
+	}
+
 	// Generate a hash of the password
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
+
+	var history [][]byte
+	if config.PasswordHistory > 1 {
+		if userEntry.PasswordHash != nil {
+			history = append(history, userEntry.PasswordHash)
+		}
+		history = append(history, userEntry.PasswordHistory...)
+		if len(history) > config.PasswordHistory-1 {
+			history = history[:config.PasswordHistory-1]
+		}
+	}
+
 	userEntry.PasswordHash = hash
+	userEntry.PasswordHistory = history
 	return nil, nil
 }
 
+// passwordMatches checks the password of the user. Check for a hash collision
+// for Vault 0.2+, but handle the older legacy passwords with a constant time
+// comparison.
+func passwordMatches(userEntry *UserEntry, password string) bool {
+	passwordBytes := []byte(password)
+	if userEntry.PasswordHash != nil {
+		return bcrypt.CompareHashAndPassword(userEntry.PasswordHash, passwordBytes) == nil
+	}
+	return userEntry.Password != "" && subtle.ConstantTimeCompare([]byte(userEntry.Password), passwordBytes) == 1
+}
+
 const pathUserPasswordHelpSyn = `
 Reset user's password.
 `
 
 const pathUserPasswordHelpDesc = `
-This endpoint allows resetting the user's password.
+This endpoint allows resetting the user's password. The password must comply
+with the password policy set on the "config" endpoint.
 `
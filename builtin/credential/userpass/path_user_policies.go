@@ -3,7 +3,9 @@ package userpass
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -35,6 +37,10 @@ func pathUserPolicies(b *backend) *framework.Path {
 func (b *backend) pathUserPoliciesUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	username := d.Get("username").(string)
 
+	lock := locksutil.LockForKey(b.userLocks, strings.ToLower(username))
+	lock.Lock()
+	defer lock.Unlock()
+
 	userEntry, err := b.user(ctx, req.Storage, username)
 	if err != nil {
 		return nil, err
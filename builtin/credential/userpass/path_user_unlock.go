@@ -0,0 +1,61 @@
+package userpass
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathUserUnlock(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "users/" + framework.GenericNameRegex("username") + "/unlock$",
+		Fields: map[string]*framework.FieldSchema{
+			"username": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Username for this user.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathUserUnlockUpdate,
+		},
+
+		HelpSynopsis:    pathUserUnlockHelpSyn,
+		HelpDescription: pathUserUnlockHelpDesc,
+	}
+}
+
+func (b *backend) pathUserUnlockUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	username := strings.ToLower(d.Get("username").(string))
+
+	lock := locksutil.LockForKey(b.userLocks, username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	userEntry, err := b.user(ctx, req.Storage, username)
+	if err != nil {
+		return nil, err
+	}
+	if userEntry == nil {
+		return nil, fmt.Errorf("username does not exist")
+	}
+
+	userEntry.FailedLogins = 0
+	userEntry.LockedAt = time.Time{}
+
+	return nil, b.setUser(ctx, req.Storage, username, userEntry)
+}
+
+const pathUserUnlockHelpSyn = `
+Unlock a user locked out after failed logins.
+`
+
+const pathUserUnlockHelpDesc = `
+This endpoint unlocks a user locked out after too many failed logins, and
+resets the count of failed logins of the user.
+`
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -116,7 +117,13 @@ func (b *backend) pathUserList(ctx context.Context, req *logical.Request, d *fra
 }
 
 func (b *backend) pathUserDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete(ctx, "user/"+strings.ToLower(d.Get("username").(string)))
+	username := strings.ToLower(d.Get("username").(string))
+
+	lock := locksutil.LockForKey(b.userLocks, username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	err := req.Storage.Delete(ctx, "user/"+username)
 	if err != nil {
 		return nil, err
 	}
@@ -133,17 +140,29 @@ func (b *backend) pathUserRead(ctx context.Context, req *logical.Request, d *fra
 		return nil, nil
 	}
 
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"policies": user.Policies,
-			"ttl":      user.TTL.Seconds(),
-			"max_ttl":  user.MaxTTL.Seconds(),
+			"policies":      user.Policies,
+			"ttl":           user.TTL.Seconds(),
+			"max_ttl":       user.MaxTTL.Seconds(),
+			"failed_logins": user.FailedLogins,
+			"locked":        config.lockedOut(user, time.Now()),
 		},
 	}, nil
 }
 
 func (b *backend) userCreateUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	username := strings.ToLower(d.Get("username").(string))
+
+	lock := locksutil.LockForKey(b.userLocks, username)
+	lock.Lock()
+	defer lock.Unlock()
+
 	userEntry, err := b.user(ctx, req.Storage, username)
 	if err != nil {
 		return nil, err
@@ -154,9 +173,9 @@ func (b *backend) userCreateUpdate(ctx context.Context, req *logical.Request, d
 	}
 
 	if _, ok := d.GetOk("password"); ok {
-		userErr, intErr := b.updateUserPassword(req, d, userEntry)
+		userErr, intErr := b.updateUserPassword(ctx, req, d, userEntry)
 		if intErr != nil {
-			return nil, err
+			return nil, intErr
 		}
 		if userErr != nil {
 			return logical.ErrorResponse(userErr.Error()), logical.ErrInvalidRequest
@@ -209,6 +228,16 @@ type UserEntry struct {
 
 	// Maximum duration for which user can be valid
 	MaxTTL time.Duration
+
+	// PasswordHistory holds the bcrypt hashes of the previous passwords,
+	// most recent first, which cannot be reused
+	PasswordHistory [][]byte
+
+	// FailedLogins is the number of consecutive failed logins
+	FailedLogins int
+
+	// LockedAt is the time the user was locked out, if it is
+	LockedAt time.Time
 }
 
 const pathUserHelpSyn = `
@@ -30,6 +30,8 @@ the session for AWS account owners defaults to one hour.`,
 			},
 		},
 
+		ForwardPerformanceStandby: true,
+
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation:   b.pathSTSRead,
 			logical.UpdateOperation: b.pathSTSRead,
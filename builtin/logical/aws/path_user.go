@@ -21,6 +21,8 @@ func pathUser(b *backend) *framework.Path {
 			},
 		},
 
+		ForwardPerformanceStandby: true,
+
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation: b.pathUserRead,
 		},
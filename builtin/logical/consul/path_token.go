@@ -20,6 +20,8 @@ func pathToken(b *backend) *framework.Path {
 			},
 		},
 
+		ForwardPerformanceStandby: true,
+
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation: b.pathTokenRead,
 		},
@@ -6,12 +6,15 @@ import (
 	"net/rpc"
 	"strings"
 	"sync"
+	"time"
 
 	log "github.com/hashicorp/go-hclog"
 
 	"github.com/hashicorp/errwrap"
 	uuid "github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/queue"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
@@ -45,6 +48,8 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 	if err := b.Setup(ctx, conf); err != nil {
 		return nil, err
 	}
+
+	b.initQueue(conf)
 	return b, nil
 }
 
@@ -56,6 +61,7 @@ func Backend(conf *logical.BackendConfig) *databaseBackend {
 		PathsSpecial: &logical.Paths{
 			SealWrapStorage: []string{
 				"config/*",
+				"static-role/*",
 			},
 		},
 
@@ -67,18 +73,28 @@ func Backend(conf *logical.BackendConfig) *databaseBackend {
 			pathCredsCreate(&b),
 			pathResetConnection(&b),
 			pathRotateCredentials(&b),
+			pathListStaticRoles(&b),
+			pathStaticRoles(&b),
+			pathStaticCredsRead(&b),
+			pathRotateRoleCredentials(&b),
 		},
 
 		Secrets: []*framework.Secret{
 			secretCreds(&b),
 		},
-		Clean:       b.closeAllDBs,
-		Invalidate:  b.invalidate,
-		BackendType: logical.TypeLogical,
+		Clean:             b.clean,
+		Invalidate:        b.invalidate,
+		WALRollback:       b.walRollback,
+		WALRollbackMinAge: staticRotationWALMinAge,
+		BackendType:       logical.TypeLogical,
 	}
 
 	b.logger = conf.Logger
 	b.connections = make(map[string]*dbPluginInstance)
+	b.credRotationQueue = queue.NewRotationQueue(conf.Logger, func(ctx context.Context, name string) (time.Time, error) {
+		return b.rotateStaticRole(ctx, conf.StorageView, name)
+	})
+	b.roleLocks = locksutil.CreateLocks()
 	return &b
 }
 
@@ -86,6 +102,11 @@ type databaseBackend struct {
 	connections map[string]*dbPluginInstance
 	logger      log.Logger
 
+	// credRotationQueue rotates the passwords of the static roles as they
+	// are due
+	credRotationQueue *queue.RotationQueue
+	roleLocks         []*locksutil.LockEntry
+
 	*framework.Backend
 	sync.RWMutex
 }
@@ -264,6 +285,12 @@ func (b *databaseBackend) CloseIfShutdown(db *dbPluginInstance, err error) {
 	}
 }
 
+// clean stops the rotation queue and closes all connections
+func (b *databaseBackend) clean(ctx context.Context) {
+	b.credRotationQueue.Stop()
+	b.closeAllDBs(ctx)
+}
+
 // closeAllDBs closes all connections from all database types
 func (b *databaseBackend) closeAllDBs(ctx context.Context) {
 	b.Lock()
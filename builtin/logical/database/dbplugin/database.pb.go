@@ -21,6 +21,9 @@ It has these top-level messages:
 	TypeResponse
 	RotateRootCredentialsResponse
 	Empty
+	StaticUserConfig
+	SetCredentialsRequest
+	SetCredentialsResponse
 */
 package dbplugin
 
@@ -210,6 +213,7 @@ type Statements struct {
 	Revocation      []string `protobuf:"bytes,6,rep,name=revocation" json:"revocation,omitempty"`
 	Rollback        []string `protobuf:"bytes,7,rep,name=rollback" json:"rollback,omitempty"`
 	Renewal         []string `protobuf:"bytes,8,rep,name=renewal" json:"renewal,omitempty"`
+	Rotation        []string `protobuf:"bytes,9,rep,name=rotation" json:"rotation,omitempty"`
 }
 
 func (m *Statements) Reset()                    { *m = Statements{} }
@@ -273,6 +277,13 @@ func (m *Statements) GetRenewal() []string {
 	return nil
 }
 
+func (m *Statements) GetRotation() []string {
+	if m != nil {
+		return m.Rotation
+	}
+	return nil
+}
+
 type UsernameConfig struct {
 	DisplayName string `protobuf:"bytes,1,opt,name=DisplayName" json:"DisplayName,omitempty"`
 	RoleName    string `protobuf:"bytes,2,opt,name=RoleName" json:"RoleName,omitempty"`
@@ -377,6 +388,78 @@ func (m *Empty) String() string            { return proto.CompactTextString(m) }
 func (*Empty) ProtoMessage()               {}
 func (*Empty) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
 
+type StaticUserConfig struct {
+	Username string `protobuf:"bytes,1,opt,name=username" json:"username,omitempty"`
+	Password string `protobuf:"bytes,2,opt,name=password" json:"password,omitempty"`
+}
+
+func (m *StaticUserConfig) Reset()                    { *m = StaticUserConfig{} }
+func (m *StaticUserConfig) String() string            { return proto.CompactTextString(m) }
+func (*StaticUserConfig) ProtoMessage()               {}
+func (*StaticUserConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *StaticUserConfig) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *StaticUserConfig) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+type SetCredentialsRequest struct {
+	Statements       *Statements       `protobuf:"bytes,1,opt,name=statements" json:"statements,omitempty"`
+	StaticUserConfig *StaticUserConfig `protobuf:"bytes,2,opt,name=static_user_config,json=staticUserConfig" json:"static_user_config,omitempty"`
+}
+
+func (m *SetCredentialsRequest) Reset()                    { *m = SetCredentialsRequest{} }
+func (m *SetCredentialsRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetCredentialsRequest) ProtoMessage()               {}
+func (*SetCredentialsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *SetCredentialsRequest) GetStatements() *Statements {
+	if m != nil {
+		return m.Statements
+	}
+	return nil
+}
+
+func (m *SetCredentialsRequest) GetStaticUserConfig() *StaticUserConfig {
+	if m != nil {
+		return m.StaticUserConfig
+	}
+	return nil
+}
+
+type SetCredentialsResponse struct {
+	Username string `protobuf:"bytes,1,opt,name=username" json:"username,omitempty"`
+	Password string `protobuf:"bytes,2,opt,name=password" json:"password,omitempty"`
+}
+
+func (m *SetCredentialsResponse) Reset()                    { *m = SetCredentialsResponse{} }
+func (m *SetCredentialsResponse) String() string            { return proto.CompactTextString(m) }
+func (*SetCredentialsResponse) ProtoMessage()               {}
+func (*SetCredentialsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *SetCredentialsResponse) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *SetCredentialsResponse) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*InitializeRequest)(nil), "dbplugin.InitializeRequest")
 	proto.RegisterType((*InitRequest)(nil), "dbplugin.InitRequest")
@@ -391,6 +474,9 @@ func init() {
 	proto.RegisterType((*TypeResponse)(nil), "dbplugin.TypeResponse")
 	proto.RegisterType((*RotateRootCredentialsResponse)(nil), "dbplugin.RotateRootCredentialsResponse")
 	proto.RegisterType((*Empty)(nil), "dbplugin.Empty")
+	proto.RegisterType((*StaticUserConfig)(nil), "dbplugin.StaticUserConfig")
+	proto.RegisterType((*SetCredentialsRequest)(nil), "dbplugin.SetCredentialsRequest")
+	proto.RegisterType((*SetCredentialsResponse)(nil), "dbplugin.SetCredentialsResponse")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -409,6 +495,7 @@ type DatabaseClient interface {
 	RenewUser(ctx context.Context, in *RenewUserRequest, opts ...grpc.CallOption) (*Empty, error)
 	RevokeUser(ctx context.Context, in *RevokeUserRequest, opts ...grpc.CallOption) (*Empty, error)
 	RotateRootCredentials(ctx context.Context, in *RotateRootCredentialsRequest, opts ...grpc.CallOption) (*RotateRootCredentialsResponse, error)
+	SetCredentials(ctx context.Context, in *SetCredentialsRequest, opts ...grpc.CallOption) (*SetCredentialsResponse, error)
 	Init(ctx context.Context, in *InitRequest, opts ...grpc.CallOption) (*InitResponse, error)
 	Close(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
 	Initialize(ctx context.Context, in *InitializeRequest, opts ...grpc.CallOption) (*Empty, error)
@@ -467,6 +554,15 @@ func (c *databaseClient) RotateRootCredentials(ctx context.Context, in *RotateRo
 	return out, nil
 }
 
+func (c *databaseClient) SetCredentials(ctx context.Context, in *SetCredentialsRequest, opts ...grpc.CallOption) (*SetCredentialsResponse, error) {
+	out := new(SetCredentialsResponse)
+	err := grpc.Invoke(ctx, "/dbplugin.Database/SetCredentials", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *databaseClient) Init(ctx context.Context, in *InitRequest, opts ...grpc.CallOption) (*InitResponse, error) {
 	out := new(InitResponse)
 	err := grpc.Invoke(ctx, "/dbplugin.Database/Init", in, out, c.cc, opts...)
@@ -502,6 +598,7 @@ type DatabaseServer interface {
 	RenewUser(context.Context, *RenewUserRequest) (*Empty, error)
 	RevokeUser(context.Context, *RevokeUserRequest) (*Empty, error)
 	RotateRootCredentials(context.Context, *RotateRootCredentialsRequest) (*RotateRootCredentialsResponse, error)
+	SetCredentials(context.Context, *SetCredentialsRequest) (*SetCredentialsResponse, error)
 	Init(context.Context, *InitRequest) (*InitResponse, error)
 	Close(context.Context, *Empty) (*Empty, error)
 	Initialize(context.Context, *InitializeRequest) (*Empty, error)
@@ -601,6 +698,24 @@ func _Database_RotateRootCredentials_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Database_SetCredentials_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetCredentialsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).SetCredentials(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dbplugin.Database/SetCredentials",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).SetCredentials(ctx, req.(*SetCredentialsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Database_Init_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(InitRequest)
 	if err := dec(in); err != nil {
@@ -679,6 +794,10 @@ var _Database_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RotateRootCredentials",
 			Handler:    _Database_RotateRootCredentials_Handler,
 		},
+		{
+			MethodName: "SetCredentials",
+			Handler:    _Database_SetCredentials_Handler,
+		},
 		{
 			MethodName: "Init",
 			Handler:    _Database_Init_Handler,
@@ -699,49 +818,53 @@ var _Database_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("builtin/logical/database/dbplugin/database.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 690 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x55, 0x41, 0x4f, 0xdb, 0x4a,
-	0x10, 0x96, 0x93, 0x00, 0xc9, 0x80, 0x80, 0xec, 0x03, 0x64, 0xf9, 0xf1, 0xde, 0x43, 0x3e, 0xf0,
-	0x40, 0x95, 0xe2, 0x0a, 0x5a, 0xb5, 0xe2, 0xd0, 0xaa, 0x0a, 0x55, 0x55, 0xa9, 0xe2, 0xb0, 0xc0,
-	0xad, 0x12, 0xda, 0x38, 0x43, 0xba, 0xc2, 0xf1, 0xba, 0xde, 0x0d, 0x34, 0xfd, 0x03, 0xed, 0xcf,
-	0xe8, 0x4f, 0xe9, 0xb1, 0x3f, 0xab, 0xf2, 0xda, 0x6b, 0x6f, 0x62, 0x28, 0x07, 0xda, 0x9b, 0x67,
-	0xe6, 0xfb, 0x66, 0xbe, 0x9d, 0x9d, 0x59, 0xc3, 0xe3, 0xc1, 0x84, 0x47, 0x8a, 0xc7, 0x41, 0x24,
-	0x46, 0x3c, 0x64, 0x51, 0x30, 0x64, 0x8a, 0x0d, 0x98, 0xc4, 0x60, 0x38, 0x48, 0xa2, 0xc9, 0x88,
-	0xc7, 0xa5, 0xa7, 0x97, 0xa4, 0x42, 0x09, 0xd2, 0x36, 0x01, 0xef, 0xbf, 0x91, 0x10, 0xa3, 0x08,
-	0x03, 0xed, 0x1f, 0x4c, 0x2e, 0x03, 0xc5, 0xc7, 0x28, 0x15, 0x1b, 0x27, 0x39, 0xd4, 0x7f, 0x0f,
-	0xdd, 0xb7, 0x31, 0x57, 0x9c, 0x45, 0xfc, 0x33, 0x52, 0xfc, 0x38, 0x41, 0xa9, 0xc8, 0x16, 0x2c,
-	0x86, 0x22, 0xbe, 0xe4, 0x23, 0xd7, 0xd9, 0x71, 0xf6, 0x56, 0x68, 0x61, 0x91, 0x47, 0xd0, 0xbd,
-	0xc6, 0x94, 0x5f, 0x4e, 0x2f, 0x42, 0x11, 0xc7, 0x18, 0x2a, 0x2e, 0x62, 0xb7, 0xb1, 0xe3, 0xec,
-	0xb5, 0xe9, 0x7a, 0x1e, 0xe8, 0x97, 0xfe, 0xa3, 0x86, 0xeb, 0xf8, 0x14, 0x96, 0xb3, 0xec, 0xbf,
-	0x33, 0xaf, 0xff, 0xc3, 0x81, 0x6e, 0x3f, 0x45, 0xa6, 0xf0, 0x5c, 0x62, 0x6a, 0x52, 0x3f, 0x01,
-	0x90, 0x8a, 0x29, 0x1c, 0x63, 0xac, 0xa4, 0x4e, 0xbf, 0x7c, 0xb0, 0xd1, 0x33, 0x7d, 0xe8, 0x9d,
-	0x96, 0x31, 0x6a, 0xe1, 0xc8, 0x2b, 0x58, 0x9b, 0x48, 0x4c, 0x63, 0x36, 0xc6, 0x8b, 0x42, 0x59,
-	0x43, 0x53, 0xdd, 0x8a, 0x7a, 0x5e, 0x00, 0xfa, 0x3a, 0x4e, 0x57, 0x27, 0x33, 0x36, 0x39, 0x02,
-	0xc0, 0x4f, 0x09, 0x4f, 0x99, 0x16, 0xdd, 0xd4, 0x6c, 0xaf, 0x97, 0xb7, 0xbd, 0x67, 0xda, 0xde,
-	0x3b, 0x33, 0x6d, 0xa7, 0x16, 0xda, 0xff, 0xe6, 0xc0, 0x3a, 0xc5, 0x18, 0x6f, 0x1e, 0x7e, 0x12,
-	0x0f, 0xda, 0x46, 0x98, 0x3e, 0x42, 0x87, 0x96, 0xf6, 0x83, 0x24, 0x22, 0x74, 0x29, 0x5e, 0x8b,
-	0x2b, 0xfc, 0xa3, 0x12, 0xfd, 0x17, 0xb0, 0x4d, 0x45, 0x06, 0xa5, 0x42, 0xa8, 0x7e, 0x8a, 0x43,
-	0x8c, 0xb3, 0x99, 0x94, 0xa6, 0xe2, 0xbf, 0x73, 0x15, 0x9b, 0x7b, 0x1d, 0x3b, 0xb7, 0xff, 0xbd,
-	0x01, 0x50, 0x95, 0x25, 0x01, 0xfc, 0x15, 0x66, 0x23, 0xc2, 0x45, 0x7c, 0x31, 0xa7, 0xb4, 0x43,
-	0x89, 0x09, 0x59, 0x84, 0x43, 0xd8, 0x4c, 0xf1, 0x5a, 0x84, 0x35, 0x4a, 0x2e, 0x74, 0xa3, 0x0a,
-	0xce, 0x56, 0x49, 0x45, 0x14, 0x0d, 0x58, 0x78, 0x65, 0x53, 0x9a, 0x79, 0x15, 0x13, 0xb2, 0x08,
-	0xfb, 0xb0, 0x9e, 0x66, 0xd7, 0x6d, 0xa3, 0x5b, 0x1a, 0xbd, 0xa6, 0xfd, 0xa7, 0x33, 0xcd, 0x32,
-	0x32, 0xdd, 0x05, 0x7d, 0xdc, 0xd2, 0xce, 0x9a, 0x51, 0xe9, 0x71, 0x17, 0xf3, 0x66, 0x54, 0x9e,
-	0x8c, 0x6b, 0x8a, 0xbb, 0x4b, 0x39, 0xd7, 0xd8, 0xc4, 0x85, 0x25, 0x5d, 0x8a, 0x45, 0x6e, 0x5b,
-	0x87, 0x8c, 0xe9, 0x9f, 0xc0, 0xea, 0xec, 0xa8, 0x93, 0x1d, 0x58, 0x3e, 0xe6, 0x32, 0x89, 0xd8,
-	0xf4, 0x24, 0xbb, 0xb3, 0xbc, 0x7b, 0xb6, 0x2b, 0xab, 0x44, 0x45, 0x84, 0x27, 0xd6, 0x95, 0x1a,
-	0xdb, 0xdf, 0x85, 0x95, 0x7c, 0xf7, 0x65, 0x22, 0x62, 0x89, 0x77, 0x2d, 0xbf, 0xff, 0x0e, 0x88,
-	0xbd, 0xce, 0x05, 0xda, 0x1e, 0x16, 0x67, 0x6e, 0x9e, 0x3d, 0x68, 0x27, 0x4c, 0xca, 0x1b, 0x91,
-	0x0e, 0x4d, 0x55, 0x63, 0xfb, 0x3e, 0xac, 0x9c, 0x4d, 0x13, 0x2c, 0xf3, 0x10, 0x68, 0xa9, 0x69,
-	0x62, 0x72, 0xe8, 0x6f, 0xff, 0x19, 0xfc, 0x73, 0xc7, 0xb0, 0xdd, 0x23, 0x75, 0x09, 0x16, 0x5e,
-	0x8f, 0x13, 0x35, 0x3d, 0xf8, 0xd2, 0x82, 0xf6, 0x71, 0xf1, 0xe6, 0x92, 0x00, 0x5a, 0x59, 0x49,
-	0xb2, 0x56, 0x6d, 0x80, 0x46, 0x79, 0x5b, 0x95, 0x63, 0x46, 0xd3, 0x1b, 0x80, 0xea, 0xc4, 0xe4,
-	0xef, 0x0a, 0x55, 0x7b, 0xd6, 0xbc, 0xed, 0xdb, 0x83, 0x45, 0xa2, 0xe7, 0xd0, 0x29, 0x9f, 0x0f,
-	0xe2, 0x55, 0xd0, 0xf9, 0x37, 0xc5, 0x9b, 0x97, 0x96, 0x3d, 0x09, 0xd5, 0x5a, 0xdb, 0x12, 0x6a,
-	0xcb, 0x5e, 0xe7, 0x7e, 0x80, 0xcd, 0x5b, 0xdb, 0x47, 0x76, 0xad, 0x34, 0xbf, 0x58, 0x66, 0xef,
-	0xff, 0x7b, 0x71, 0xc5, 0xf9, 0x9e, 0x42, 0x2b, 0x1b, 0x21, 0xb2, 0x59, 0x11, 0xac, 0xdf, 0x89,
-	0xdd, 0xdf, 0x99, 0x49, 0xdb, 0x87, 0x85, 0x7e, 0x24, 0xe4, 0x2d, 0x37, 0x52, 0x3b, 0xcb, 0x4b,
-	0x80, 0xea, 0xf7, 0x67, 0xf7, 0xa1, 0xf6, 0x53, 0xac, 0x71, 0xfd, 0xe6, 0xd7, 0x86, 0x33, 0x58,
-	0xd4, 0xef, 0xe7, 0xe1, 0xcf, 0x00, 0x00, 0x00, 0xff, 0xff, 0xa7, 0x13, 0xfe, 0x55, 0xa5, 0x07,
-	0x00, 0x00,
+	// 768 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x56, 0xdd, 0x6e, 0xd3, 0x30,
+	0x14, 0x56, 0xda, 0x6e, 0x6b, 0xcf, 0xa6, 0xad, 0x35, 0x6b, 0x15, 0x85, 0xc1, 0xaa, 0x5c, 0x8c,
+	0x4d, 0x48, 0x2d, 0xda, 0x40, 0xa0, 0x5d, 0x80, 0x50, 0x87, 0xf8, 0x11, 0x9a, 0x90, 0xbb, 0xdd,
+	0x21, 0x55, 0x69, 0xea, 0x15, 0x6b, 0x69, 0x1c, 0x62, 0x77, 0xa3, 0x3c, 0x01, 0x6f, 0xc0, 0x2d,
+	0x8f, 0xc3, 0x43, 0xec, 0x61, 0x90, 0x9d, 0xb8, 0x71, 0xda, 0x8e, 0x49, 0x1b, 0xdc, 0xe5, 0xfc,
+	0x7c, 0xe7, 0x7c, 0x3e, 0xe7, 0xf8, 0x38, 0xf0, 0xa4, 0x3f, 0xa6, 0x81, 0xa0, 0x61, 0x3b, 0x60,
+	0x43, 0xea, 0x7b, 0x41, 0x7b, 0xe0, 0x09, 0xaf, 0xef, 0x71, 0xd2, 0x1e, 0xf4, 0xa3, 0x60, 0x3c,
+	0xa4, 0xe1, 0x54, 0xd3, 0x8a, 0x62, 0x26, 0x18, 0x2a, 0x6b, 0x83, 0xb3, 0x3d, 0x64, 0x6c, 0x18,
+	0x90, 0xb6, 0xd2, 0xf7, 0xc7, 0x67, 0x6d, 0x41, 0x47, 0x84, 0x0b, 0x6f, 0x14, 0x25, 0xae, 0xee,
+	0x67, 0xa8, 0xbd, 0x0f, 0xa9, 0xa0, 0x5e, 0x40, 0xbf, 0x13, 0x4c, 0xbe, 0x8e, 0x09, 0x17, 0xa8,
+	0x01, 0xcb, 0x3e, 0x0b, 0xcf, 0xe8, 0xd0, 0xb6, 0x9a, 0xd6, 0xee, 0x1a, 0x4e, 0x25, 0xf4, 0x18,
+	0x6a, 0x17, 0x24, 0xa6, 0x67, 0x93, 0x9e, 0xcf, 0xc2, 0x90, 0xf8, 0x82, 0xb2, 0xd0, 0x2e, 0x34,
+	0xad, 0xdd, 0x32, 0xae, 0x26, 0x86, 0xce, 0x54, 0x7f, 0x58, 0xb0, 0x2d, 0x17, 0xc3, 0xaa, 0x8c,
+	0xfe, 0x2f, 0xe3, 0xba, 0xbf, 0x2d, 0xa8, 0x75, 0x62, 0xe2, 0x09, 0x72, 0xca, 0x49, 0xac, 0x43,
+	0x3f, 0x05, 0xe0, 0xc2, 0x13, 0x64, 0x44, 0x42, 0xc1, 0x55, 0xf8, 0xd5, 0xfd, 0xcd, 0x96, 0xae,
+	0x43, 0xab, 0x3b, 0xb5, 0x61, 0xc3, 0x0f, 0xbd, 0x86, 0x8d, 0x31, 0x27, 0x71, 0xe8, 0x8d, 0x48,
+	0x2f, 0x65, 0x56, 0x50, 0x50, 0x3b, 0x83, 0x9e, 0xa6, 0x0e, 0x1d, 0x65, 0xc7, 0xeb, 0xe3, 0x9c,
+	0x8c, 0x0e, 0x01, 0xc8, 0xb7, 0x88, 0xc6, 0x9e, 0x22, 0x5d, 0x54, 0x68, 0xa7, 0x95, 0x94, 0xbd,
+	0xa5, 0xcb, 0xde, 0x3a, 0xd1, 0x65, 0xc7, 0x86, 0xb7, 0xfb, 0xcb, 0x82, 0x2a, 0x26, 0x21, 0xb9,
+	0xbc, 0xfb, 0x49, 0x1c, 0x28, 0x6b, 0x62, 0xea, 0x08, 0x15, 0x3c, 0x95, 0xef, 0x44, 0x91, 0x40,
+	0x0d, 0x93, 0x0b, 0x76, 0x4e, 0xfe, 0x2b, 0x45, 0xf7, 0x25, 0x6c, 0x61, 0x26, 0x5d, 0x31, 0x63,
+	0xa2, 0x13, 0x93, 0x01, 0x09, 0xe5, 0x4c, 0x72, 0x9d, 0xf1, 0xe1, 0x4c, 0xc6, 0xe2, 0x6e, 0xc5,
+	0x8c, 0xed, 0x5e, 0x15, 0x00, 0xb2, 0xb4, 0xa8, 0x0d, 0xf7, 0x7c, 0x39, 0x22, 0x94, 0x85, 0xbd,
+	0x19, 0xa6, 0x15, 0x8c, 0xb4, 0xc9, 0x00, 0x1c, 0x40, 0x3d, 0x26, 0x17, 0xcc, 0x9f, 0x83, 0x24,
+	0x44, 0x37, 0x33, 0x63, 0x3e, 0x4b, 0xcc, 0x82, 0xa0, 0xef, 0xf9, 0xe7, 0x26, 0xa4, 0x98, 0x64,
+	0xd1, 0x26, 0x03, 0xb0, 0x07, 0xd5, 0x58, 0xb6, 0xdb, 0xf4, 0x2e, 0x29, 0xef, 0x0d, 0xa5, 0xef,
+	0xe6, 0x8a, 0xa5, 0x69, 0xda, 0x4b, 0xea, 0xb8, 0x53, 0x59, 0x16, 0x23, 0xe3, 0x63, 0x2f, 0x27,
+	0xc5, 0xc8, 0x34, 0x12, 0xab, 0x93, 0xdb, 0x2b, 0x09, 0x56, 0xcb, 0xc8, 0x86, 0x15, 0x95, 0xca,
+	0x0b, 0xec, 0xb2, 0x32, 0x69, 0x31, 0x41, 0x89, 0x24, 0x66, 0x45, 0xa3, 0x12, 0xd9, 0x3d, 0x86,
+	0xf5, 0xfc, 0x35, 0x40, 0x4d, 0x58, 0x3d, 0xa2, 0x3c, 0x0a, 0xbc, 0xc9, 0xb1, 0xec, 0x67, 0x52,
+	0x59, 0x53, 0x25, 0xe3, 0x61, 0x16, 0x90, 0x63, 0xa3, 0xdd, 0x5a, 0x76, 0x77, 0x60, 0x2d, 0xd9,
+	0x0b, 0x3c, 0x62, 0x21, 0x27, 0xd7, 0x2d, 0x06, 0xf7, 0x23, 0x20, 0xf3, 0xaa, 0xa7, 0xde, 0xe6,
+	0x20, 0x59, 0x33, 0xb3, 0xee, 0x40, 0x39, 0xf2, 0x38, 0xbf, 0x64, 0xf1, 0x40, 0x67, 0xd5, 0xb2,
+	0xeb, 0xc2, 0xda, 0xc9, 0x24, 0x22, 0xd3, 0x38, 0x08, 0x4a, 0x62, 0x12, 0xe9, 0x18, 0xea, 0xdb,
+	0x7d, 0x0e, 0x0f, 0xae, 0x19, 0xc4, 0x1b, 0xa8, 0xae, 0xc0, 0xd2, 0x9b, 0x51, 0x24, 0x26, 0xee,
+	0x07, 0xa8, 0xca, 0x3e, 0x52, 0x5f, 0x72, 0x4e, 0xab, 0x75, 0x5b, 0xc6, 0x3f, 0x2d, 0xa8, 0x77,
+	0xc9, 0xa2, 0x0b, 0x71, 0xbb, 0x2b, 0xf8, 0x0e, 0x10, 0x57, 0xdc, 0x7a, 0x32, 0x7d, 0x7e, 0xe5,
+	0x39, 0x79, 0xb4, 0xc9, 0x1f, 0x57, 0xf9, 0x8c, 0xc6, 0xfd, 0x04, 0x8d, 0x2e, 0x59, 0x58, 0xa0,
+	0x5b, 0x9e, 0x75, 0xff, 0xaa, 0x04, 0xe5, 0xa3, 0xf4, 0x1d, 0x43, 0x6d, 0x28, 0xc9, 0x56, 0xa1,
+	0x8d, 0x8c, 0x94, 0xaa, 0xae, 0xd3, 0xc8, 0x14, 0xb9, 0x5e, 0xbe, 0x05, 0xc8, 0x26, 0x05, 0xdd,
+	0xcf, 0xbc, 0xe6, 0x9e, 0x0a, 0x67, 0x6b, 0xb1, 0x31, 0x0d, 0xf4, 0x02, 0x2a, 0xd3, 0x95, 0x8c,
+	0x8c, 0x9a, 0xcc, 0xee, 0x69, 0x67, 0x96, 0x9a, 0x5c, 0xb3, 0xd9, 0xaa, 0x34, 0x29, 0xcc, 0x2d,
+	0xd0, 0x79, 0xec, 0x17, 0xa8, 0x2f, 0x1c, 0x3b, 0xb4, 0x63, 0x84, 0xf9, 0xcb, 0x82, 0x74, 0x1e,
+	0xdd, 0xe8, 0x97, 0x9e, 0xaf, 0x0b, 0xeb, 0xf9, 0xc6, 0xa1, 0x6d, 0xa3, 0xf1, 0x8b, 0x66, 0xcd,
+	0x69, 0x5e, 0xef, 0x90, 0x06, 0x7d, 0x06, 0x25, 0x79, 0x9f, 0x51, 0x3d, 0xf3, 0x34, 0xde, 0x7d,
+	0xa7, 0x31, 0xab, 0x4e, 0x61, 0x7b, 0xb0, 0xd4, 0x09, 0x18, 0x5f, 0xd0, 0xe6, 0xb9, 0x02, 0xbd,
+	0x02, 0xc8, 0xfe, 0x53, 0xcc, 0xe2, 0xce, 0xfd, 0xbd, 0xcc, 0x61, 0xdd, 0xe2, 0x8f, 0x82, 0xd5,
+	0x5f, 0x56, 0x0f, 0xdd, 0xc1, 0x9f, 0x01, 0x00, 0xd2, 0xb2, 0x1a, 0x9e, 0x4e, 0x09, 0x00, 0x00,
 }
@@ -80,6 +80,18 @@ func (s *gRPCServer) RotateRootCredentials(ctx context.Context, req *RotateRootC
 	}, err
 }
 
+func (s *gRPCServer) SetCredentials(ctx context.Context, req *SetCredentialsRequest) (*SetCredentialsResponse, error) {
+	u, p, err := s.impl.SetCredentials(ctx, *req.Statements, *req.StaticUserConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SetCredentialsResponse{
+		Username: u,
+		Password: p,
+	}, nil
+}
+
 func (s *gRPCServer) Initialize(ctx context.Context, req *InitializeRequest) (*Empty, error) {
 	_, err := s.Init(ctx, &InitRequest{
 		Config:           req.Config,
@@ -234,6 +246,27 @@ func (c *gRPCClient) RotateRootCredentials(ctx context.Context, statements []str
 	return conf, nil
 }
 
+func (c *gRPCClient) SetCredentials(ctx context.Context, statements Statements, staticConfig StaticUserConfig) (username, password string, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	quitCh := pluginutil.CtxCancelIfCanceled(cancel, c.doneCtx)
+	defer close(quitCh)
+	defer cancel()
+
+	resp, err := c.client.SetCredentials(ctx, &SetCredentialsRequest{
+		Statements:       &statements,
+		StaticUserConfig: &staticConfig,
+	})
+	if err != nil {
+		if c.doneCtx.Err() != nil {
+			return "", "", ErrPluginShutdown
+		}
+
+		return "", "", err
+	}
+
+	return resp.Username, resp.Password, nil
+}
+
 func (c *gRPCClient) Initialize(ctx context.Context, conf map[string]interface{}, verifyConnection bool) error {
 	_, err := c.Init(ctx, conf, verifyConnection)
 	return err
@@ -23,6 +23,12 @@ type Database interface {
 
 	RotateRootCredentials(ctx context.Context, statements []string) (config map[string]interface{}, err error)
 
+	// SetCredentials sets the password of an existing user that is managed
+	// by Vault. If staticConfig.Password is empty a new password is
+	// generated by the plugin. The username and password that were set are
+	// returned.
+	SetCredentials(ctx context.Context, statements Statements, staticConfig StaticUserConfig) (username string, password string, err error)
+
 	Init(ctx context.Context, config map[string]interface{}, verifyConnection bool) (saveConfig map[string]interface{}, err error)
 	Close() error
 
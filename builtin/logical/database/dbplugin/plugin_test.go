@@ -64,6 +64,19 @@ func (m *mockPlugin) RevokeUser(_ context.Context, statements dbplugin.Statement
 func (m *mockPlugin) RotateRootCredentials(_ context.Context, statements []string) (map[string]interface{}, error) {
 	return nil, nil
 }
+func (m *mockPlugin) SetCredentials(_ context.Context, statements dbplugin.Statements, staticConfig dbplugin.StaticUserConfig) (string, string, error) {
+	if staticConfig.Username == "" {
+		return "", "", errors.New("err")
+	}
+
+	password := staticConfig.Password
+	if password == "" {
+		password = "generated"
+	}
+	m.users[staticConfig.Username] = []string{password}
+
+	return staticConfig.Username, password, nil
+}
 func (m *mockPlugin) Init(_ context.Context, conf map[string]interface{}, _ bool) (map[string]interface{}, error) {
 	err := errors.New("err")
 	if len(conf) != 1 {
@@ -283,6 +296,48 @@ func TestPlugin_RevokeUser(t *testing.T) {
 }
 
 // Test the code is still compatible with an old netRPC plugin
+func TestPlugin_SetCredentials(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	db, err := dbplugin.PluginFactory(context.Background(), "test-plugin", sys, log.NewNullLogger())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	connectionDetails := map[string]interface{}{
+		"test": 1,
+	}
+	_, err = db.Init(context.Background(), connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Test a generated password
+	us, pw, err := db.SetCredentials(context.Background(), dbplugin.Statements{}, dbplugin.StaticUserConfig{Username: "static"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if us != "static" || pw != "generated" {
+		t.Fatalf("bad: %s %s", us, pw)
+	}
+
+	// Test a provided password
+	_, pw, err = db.SetCredentials(context.Background(), dbplugin.Statements{}, dbplugin.StaticUserConfig{Username: "static", Password: "secret"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if pw != "secret" {
+		t.Fatalf("bad: %s", pw)
+	}
+
+	_, _, err = db.SetCredentials(context.Background(), dbplugin.Statements{}, dbplugin.StaticUserConfig{})
+	if err == nil {
+		t.Fatal("expected error setting credentials without a username")
+	}
+}
+
 func TestPlugin_NetRPC_Init(t *testing.T) {
 	cluster, sys := getCluster(t)
 	defer cluster.Cleanup()
@@ -421,3 +476,45 @@ func TestPlugin_NetRPC_RevokeUser(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 }
+
+func TestPlugin_NetRPC_SetCredentials(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	db, err := dbplugin.PluginFactory(context.Background(), "test-plugin-netRPC", sys, log.NewNullLogger())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	connectionDetails := map[string]interface{}{
+		"test": 1,
+	}
+	_, err = db.Init(context.Background(), connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Test a generated password
+	us, pw, err := db.SetCredentials(context.Background(), dbplugin.Statements{}, dbplugin.StaticUserConfig{Username: "static"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if us != "static" || pw != "generated" {
+		t.Fatalf("bad: %s %s", us, pw)
+	}
+
+	// Test a provided password
+	_, pw, err = db.SetCredentials(context.Background(), dbplugin.Statements{}, dbplugin.StaticUserConfig{Username: "static", Password: "secret"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if pw != "secret" {
+		t.Fatalf("bad: %s", pw)
+	}
+
+	_, _, err = db.SetCredentials(context.Background(), dbplugin.Statements{}, dbplugin.StaticUserConfig{})
+	if err == nil {
+		t.Fatal("expected error setting credentials without a username")
+	}
+}
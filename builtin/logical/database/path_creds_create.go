@@ -21,6 +21,8 @@ func pathCredsCreate(b *databaseBackend) *framework.Path {
 			},
 		},
 
+		ForwardPerformanceStandby: true,
+
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation: b.pathCredsCreateRead(),
 		},
@@ -71,6 +71,49 @@ func (b *databaseBackend) pathRotateCredentialsUpdate() framework.OperationFunc
 	}
 }
 
+func pathRotateRoleCredentials(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "rotate-role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the static role",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRotateRoleCredentialsUpdate(),
+		},
+
+		HelpSynopsis:    pathRotateRoleCredentialsUpdateHelpSyn,
+		HelpDescription: pathRotateRoleCredentialsUpdateHelpDesc,
+	}
+}
+
+func (b *databaseBackend) pathRotateRoleCredentialsUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+		if name == "" {
+			return logical.ErrorResponse("empty role name attribute given"), nil
+		}
+
+		next, err := b.rotateStaticRole(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if next.IsZero() {
+			return logical.ErrorResponse(fmt.Sprintf("unknown static role: %s", name)), nil
+		}
+
+		// Restart the rotation period from now
+		if err := b.credRotationQueue.Push(name, next); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
 const pathRotateCredentialsUpdateHelpSyn = `
 Request to rotate the root credentials for a certain database connection.
 `
@@ -78,3 +121,12 @@ Request to rotate the root credentials for a certain database connection.
 const pathRotateCredentialsUpdateHelpDesc = `
 This path attempts to rotate the root credentials for the given database. 
 `
+
+const pathRotateRoleCredentialsUpdateHelpSyn = `
+Request to rotate the credentials for a static role.
+`
+
+const pathRotateRoleCredentialsUpdateHelpDesc = `
+This path attempts to rotate the password of the user bound to the given
+static role. The next scheduled rotation is one rotation period from now.
+`
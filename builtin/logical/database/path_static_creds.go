@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathStaticCredsRead(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the static role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathStaticCredsRead(),
+		},
+
+		HelpSynopsis:    pathStaticCredsReadHelpSyn,
+		HelpDescription: pathStaticCredsReadHelpDesc,
+	}
+}
+
+func (b *databaseBackend) pathStaticCredsRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+
+		role, err := b.StaticRole(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown static role: %s", name)), nil
+		}
+
+		dbConfig, err := b.DatabaseConfig(ctx, req.Storage, role.DBName)
+		if err != nil {
+			return nil, err
+		}
+
+		// If role name isn't in the database's allowed roles, send back a
+		// permission denied.
+		if !strutil.StrListContains(dbConfig.AllowedRoles, "*") && !strutil.StrListContainsGlob(dbConfig.AllowedRoles, name) {
+			return nil, logical.ErrPermissionDenied
+		}
+
+		ttl := time.Until(role.NextRotation())
+		if ttl < 0 {
+			ttl = 0
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"username":            role.Username,
+				"password":            role.Password,
+				"ttl":                 int64(ttl.Seconds()),
+				"rotation_period":     role.RotationPeriod.Seconds(),
+				"last_vault_rotation": role.LastVaultRotation,
+			},
+		}, nil
+	}
+}
+
+const pathStaticCredsReadHelpSyn = `
+Request the current credentials of a static role.
+`
+
+const pathStaticCredsReadHelpDesc = `
+This path reads the username and current password of a static role. The
+password is owned by Vault and rotated every rotation period; "ttl" is the
+number of seconds until the next rotation.
+`
@@ -0,0 +1,269 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	staticRolePath = "static-role/"
+
+	// minRotationPeriod is the shortest rotation period a static role can
+	// have, since the rotation queue is only checked every few seconds
+	minRotationPeriod = 5 * time.Second
+
+	defaultRotationPeriod = 24 * time.Hour
+)
+
+func pathListStaticRoles(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathStaticRoleList(),
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func pathStaticRoles(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+
+			"db_name": {
+				Type:        framework.TypeString,
+				Description: "Name of the database this role acts on.",
+			},
+			"username": {
+				Type: framework.TypeString,
+				Description: `Name of the existing database user whose password
+				is managed by this role.`,
+			},
+			"rotation_period": {
+				Type:        framework.TypeDurationSecond,
+				Default:     int(defaultRotationPeriod.Seconds()),
+				Description: "Period for automatic password rotation.",
+			},
+			"rotation_statements": {
+				Type: framework.TypeStringSlice,
+				Description: `Specifies the database statements to be executed
+				to rotate the user's password. Defaults to the plugin's root
+				credential rotation statements. See the plugin's API page for
+				more information on support and formatting for this
+				parameter.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathStaticRoleRead(),
+			logical.UpdateOperation: b.pathStaticRoleCreateUpdate(),
+			logical.DeleteOperation: b.pathStaticRoleDelete(),
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func (b *databaseBackend) StaticRole(ctx context.Context, s logical.Storage, roleName string) (*staticRoleEntry, error) {
+	entry, err := s.Get(ctx, staticRolePath+roleName)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result staticRoleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *databaseBackend) pathStaticRoleDelete() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+
+		lock := locksutil.LockForKey(b.roleLocks, name)
+		lock.Lock()
+		defer lock.Unlock()
+
+		if err := req.Storage.Delete(ctx, staticRolePath+name); err != nil {
+			return nil, err
+		}
+
+		if err := b.credRotationQueue.Remove(name); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		role, err := b.StaticRole(ctx, req.Storage, data.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"db_name":             role.DBName,
+				"username":            role.Username,
+				"rotation_period":     role.RotationPeriod.Seconds(),
+				"rotation_statements": role.RotationStatements,
+				"last_vault_rotation": role.LastVaultRotation,
+			},
+		}, nil
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleList() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		entries, err := req.Storage.List(ctx, staticRolePath)
+		if err != nil {
+			return nil, err
+		}
+
+		return logical.ListResponse(entries), nil
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleCreateUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+		if name == "" {
+			return logical.ErrorResponse("empty role name attribute given"), nil
+		}
+
+		lock := locksutil.LockForKey(b.roleLocks, name)
+		lock.Lock()
+		defer lock.Unlock()
+
+		role, err := b.StaticRole(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		rotate := false
+		if role == nil {
+			role = &staticRoleEntry{}
+			rotate = true
+		}
+
+		if dbNameRaw, ok := data.GetOk("db_name"); ok {
+			role.DBName = dbNameRaw.(string)
+		}
+		if role.DBName == "" {
+			return logical.ErrorResponse("empty database name attribute given"), nil
+		}
+
+		if usernameRaw, ok := data.GetOk("username"); ok && usernameRaw.(string) != role.Username {
+			role.Username = usernameRaw.(string)
+			rotate = true
+		}
+		if role.Username == "" {
+			return logical.ErrorResponse("empty username attribute given"), nil
+		}
+
+		if rotationPeriodRaw, ok := data.GetOk("rotation_period"); ok {
+			role.RotationPeriod = time.Duration(rotationPeriodRaw.(int)) * time.Second
+		} else if role.RotationPeriod == 0 {
+			role.RotationPeriod = defaultRotationPeriod
+		}
+		if role.RotationPeriod < minRotationPeriod {
+			return logical.ErrorResponse(fmt.Sprintf("rotation_period must be at least %s", minRotationPeriod)), nil
+		}
+
+		if rotationStmtsRaw, ok := data.GetOk("rotation_statements"); ok {
+			role.RotationStatements = rotationStmtsRaw.([]string)
+		}
+
+		dbConfig, err := b.DatabaseConfig(ctx, req.Storage, role.DBName)
+		if err != nil {
+			return nil, err
+		}
+		if !strutil.StrListContains(dbConfig.AllowedRoles, "*") && !strutil.StrListContainsGlob(dbConfig.AllowedRoles, name) {
+			return logical.ErrorResponse(fmt.Sprintf("%q is not an allowed role of database %q", name, role.DBName)), nil
+		}
+
+		// A new role or user has its password set right away so it never
+		// serves a password Vault doesn't know
+		if rotate {
+			if err := b.setStaticRolePassword(ctx, req.Storage, name, role); err != nil {
+				return nil, err
+			}
+		} else {
+			entry, err := logical.StorageEntryJSON(staticRolePath+name, role)
+			if err != nil {
+				return nil, err
+			}
+			if err := req.Storage.Put(ctx, entry); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := b.credRotationQueue.Push(name, role.NextRotation()); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+type staticRoleEntry struct {
+	DBName             string        `json:"db_name"`
+	Username           string        `json:"username"`
+	Password           string        `json:"password"`
+	RotationPeriod     time.Duration `json:"rotation_period"`
+	RotationStatements []string      `json:"rotation_statements"`
+	LastVaultRotation  time.Time     `json:"last_vault_rotation"`
+}
+
+// NextRotation returns when the role's password is due to be rotated
+func (r *staticRoleEntry) NextRotation() time.Time {
+	return r.LastVaultRotation.Add(r.RotationPeriod)
+}
+
+const pathStaticRoleHelpSyn = `
+Manage the static roles that can be created with this backend.
+`
+
+const pathStaticRoleHelpDesc = `
+This path lets you manage the static roles of this backend. A static role is
+bound to an existing database user whose password is owned by Vault and
+rotated on a schedule.
+
+The "db_name" parameter is required and configures the name of the database
+connection to use.
+
+The "username" parameter is required and is the name of the existing database
+user. The password of the user is rotated as soon as the role is created.
+
+The "rotation_period" parameter is how often the password is rotated. It
+defaults to 24 hours.
+
+The "rotation_statements" parameter customizes the statements used to set the
+password. The "username" and "password" variables are substituted. Example of
+rotation_statements for a postgresql database plugin:
+
+	ALTER ROLE "{{username}}" WITH PASSWORD '{{password}}';
+`
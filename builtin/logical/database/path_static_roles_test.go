@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/lib/pq"
+)
+
+func TestBackend_StaticRole_Rotation(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = sys
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Cleanup(context.Background())
+
+	cleanup, connURL := preparePostgresTestContainer(t, config.StorageView, b)
+	defer cleanup()
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+		return resp
+	}
+
+	// Create the user that the static role manages
+	db, err := sql.Open("postgres", connURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE ROLE "static-user" WITH LOGIN PASSWORD 'initial';`); err != nil {
+		t.Fatal(err)
+	}
+
+	testLogin := func(password string) error {
+		u, err := url.Parse(connURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.User = url.UserPassword("static-user", password)
+		conn, err := pq.ParseURL(u.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		userDB, err := sql.Open("postgres", conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer userDB.Close()
+		return userDB.Ping()
+	}
+
+	request(logical.UpdateOperation, "config/plugin-test", map[string]interface{}{
+		"connection_url": connURL,
+		"plugin_name":    "postgresql-database-plugin",
+		"allowed_roles":  []string{"static-*"},
+	})
+
+	// Roles outside of allowed_roles are rejected
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "static-roles/other",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"db_name":  "plugin-test",
+			"username": "static-user",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response, got resp:%#v err:%v", resp, err)
+	}
+
+	// The password is rotated as soon as the role is created
+	request(logical.UpdateOperation, "static-roles/static-test", map[string]interface{}{
+		"db_name":         "plugin-test",
+		"username":        "static-user",
+		"rotation_period": "1h",
+	})
+	if err := testLogin("initial"); err == nil {
+		t.Fatal("expected the initial password to be rotated")
+	}
+
+	resp = request(logical.ReadOperation, "static-creds/static-test", nil)
+	password := resp.Data["password"].(string)
+	if resp.Data["username"] != "static-user" || password == "" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if ttl := resp.Data["ttl"].(int64); ttl <= 0 || ttl > 3600 {
+		t.Fatalf("bad ttl: %d", ttl)
+	}
+	if err := testLogin(password); err != nil {
+		t.Fatal(err)
+	}
+
+	// Manual rotation
+	request(logical.UpdateOperation, "rotate-role/static-test", nil)
+	resp = request(logical.ReadOperation, "static-creds/static-test", nil)
+	rotated := resp.Data["password"].(string)
+	if rotated == password {
+		t.Fatal("expected the password to change")
+	}
+	if err := testLogin(rotated); err != nil {
+		t.Fatal(err)
+	}
+
+	// Scheduled rotation by the queue
+	request(logical.UpdateOperation, "static-roles/static-test", map[string]interface{}{
+		"rotation_period": "5s",
+	})
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		resp = request(logical.ReadOperation, "static-creds/static-test", nil)
+		if resp.Data["password"].(string) != rotated {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("password was not rotated by the queue")
+		}
+		time.Sleep(time.Second)
+	}
+	if err := testLogin(resp.Data["password"].(string)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A password set on the database whose role could not be stored is
+	// stored from its WAL entry, while older entries are dropped
+	request(logical.UpdateOperation, "static-roles/static-test", map[string]interface{}{
+		"rotation_period": "1h",
+	})
+	current := request(logical.ReadOperation, "static-creds/static-test", nil).Data["password"].(string)
+	if _, err := framework.PutWAL(context.Background(), config.StorageView, staticRotationWALKind, &staticRotationWAL{
+		Name:              "static-test",
+		Password:          "A1a-stale",
+		LastVaultRotation: time.Now().Add(-time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rollback := func() {
+		if _, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RollbackOperation,
+			Storage:   config.StorageView,
+			Data: map[string]interface{}{
+				"immediate": true,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rollback()
+	if password := request(logical.ReadOperation, "static-creds/static-test", nil).Data["password"].(string); password != current {
+		t.Fatal("expected the stale WAL entry to be dropped")
+	}
+
+	if _, err := framework.PutWAL(context.Background(), config.StorageView, staticRotationWALKind, &staticRotationWAL{
+		Name:              "static-test",
+		Password:          "A1a-pending",
+		LastVaultRotation: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rollback()
+	if password := request(logical.ReadOperation, "static-creds/static-test", nil).Data["password"].(string); password != "A1a-pending" {
+		t.Fatalf("expected the pending password to be stored, got %q", password)
+	}
+	if err := testLogin("A1a-pending"); err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := framework.ListWAL(context.Background(), config.StorageView); err != nil || len(keys) != 0 {
+		t.Fatalf("expected the WAL entries to be deleted, got %v %v", keys, err)
+	}
+
+	resp = request(logical.ListOperation, "static-roles/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "static-test" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	request(logical.DeleteOperation, "static-roles/static-test", nil)
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-creds/static-test",
+		Storage:   config.StorageView,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response, got resp:%#v err:%v", resp, err)
+	}
+}
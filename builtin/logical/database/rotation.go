@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+)
+
+const (
+	// staticRotationWALKind is the kind of the WAL entries holding a new
+	// password of a static role while it is being set
+	staticRotationWALKind = "staticRotation"
+
+	// staticRotationWALMinAge is how old such an entry must be before it is
+	// used to store the password, which is longer than setting it takes
+	staticRotationWALMinAge = time.Minute
+)
+
+// staticRotationWAL is the new password of a static role, written before it
+// is set on the database so that it is never lost if storing the role fails
+type staticRotationWAL struct {
+	Name              string    `json:"name"`
+	Password          string    `json:"password"`
+	LastVaultRotation time.Time `json:"last_vault_rotation"`
+}
+
+// initQueue loads the static roles into the rotation queue and starts
+// rotating their passwords. Only the active node of the primary cluster
+// rotates, since rotating writes to storage; after a leader failover the new
+// active node picks up the rotation schedule from storage.
+func (b *databaseBackend) initQueue(conf *logical.BackendConfig) {
+	// Performance secondaries and standbys mount the backend too but can't
+	// write to storage, so a password they set would be lost
+	if conf.System.ReplicationState().HasState(consts.ReplicationPerformanceSecondary | consts.ReplicationPerformanceStandby) {
+		return
+	}
+
+	b.credRotationQueue.Start(func(ctx context.Context) error {
+		return b.loadStaticRoles(ctx, conf.StorageView)
+	})
+}
+
+// loadStaticRoles pushes every stored static role onto the rotation queue
+func (b *databaseBackend) loadStaticRoles(ctx context.Context, s logical.Storage) error {
+	names, err := s.List(ctx, staticRolePath)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		role, err := b.StaticRole(ctx, s, name)
+		if err != nil {
+			return err
+		}
+		if role == nil {
+			continue
+		}
+
+		if err := b.credRotationQueue.Push(name, role.NextRotation()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rotateStaticRole sets a new password for the static role and returns the
+// time of its next rotation. A zero time is returned if the role does not
+// exist.
+func (b *databaseBackend) rotateStaticRole(ctx context.Context, s logical.Storage, name string) (time.Time, error) {
+	lock := locksutil.LockForKey(b.roleLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.StaticRole(ctx, s, name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if role == nil {
+		return time.Time{}, nil
+	}
+
+	if err := b.setStaticRolePassword(ctx, s, name, role); err != nil {
+		return time.Time{}, err
+	}
+
+	return role.NextRotation(), nil
+}
+
+// setStaticRolePassword generates a new password for the role's user,
+// sets it on the database, and stores it. The password is written to the
+// WAL first; if storing the role fails the WAL rollback stores it later. The
+// role lock must be held.
+func (b *databaseBackend) setStaticRolePassword(ctx context.Context, s logical.Storage, name string, role *staticRoleEntry) error {
+	db, err := b.GetConnection(ctx, s, role.DBName)
+	if err != nil {
+		return err
+	}
+
+	password, err := credsutil.RandomAlphaNumeric(20, true)
+	if err != nil {
+		return err
+	}
+	rotationTime := time.Now()
+
+	walID, err := framework.PutWAL(ctx, s, staticRotationWALKind, &staticRotationWAL{
+		Name:              name,
+		Password:          password,
+		LastVaultRotation: rotationTime,
+	})
+	if err != nil {
+		return errwrap.Wrapf("error writing WAL entry: {{err}}", err)
+	}
+
+	if err := b.setCredentials(ctx, db, role, password); err != nil {
+		return err
+	}
+
+	role.Password = password
+	role.LastVaultRotation = rotationTime
+
+	entry, err := logical.StorageEntryJSON(staticRolePath+name, role)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return errwrap.Wrapf("password was set on the database but could not be stored, it will be stored from the WAL: {{err}}", err)
+	}
+
+	if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+		b.logger.Warn("error deleting WAL entry of stored password", "name", name, "error", err)
+	}
+
+	return nil
+}
+
+// setCredentials sets the given password for the role's user on the database
+func (b *databaseBackend) setCredentials(ctx context.Context, db *dbPluginInstance, role *staticRoleEntry, password string) error {
+	db.RLock()
+	defer db.RUnlock()
+
+	statements := dbplugin.Statements{
+		Rotation: role.RotationStatements,
+	}
+	_, _, err := db.SetCredentials(ctx, statements, dbplugin.StaticUserConfig{
+		Username: role.Username,
+		Password: password,
+	})
+	if err != nil {
+		b.CloseIfShutdown(db, err)
+		return errwrap.Wrapf("error setting credentials: {{err}}", err)
+	}
+
+	return nil
+}
+
+// walRollback stores the password of a static role whose rotation wrote a
+// WAL entry but did not store the role, setting it on the database again in
+// case it was not set. Entries older than the stored password are dropped.
+func (b *databaseBackend) walRollback(ctx context.Context, req *logical.Request, kind string, data interface{}) error {
+	if kind != staticRotationWALKind {
+		return fmt.Errorf("unknown type to rollback")
+	}
+
+	// The entry was decoded generically; go through JSON again to get the
+	// rotation time back
+	raw, err := jsonutil.EncodeJSON(data)
+	if err != nil {
+		return err
+	}
+	var wal staticRotationWAL
+	if err := jsonutil.DecodeJSON(raw, &wal); err != nil {
+		return err
+	}
+
+	lock := locksutil.LockForKey(b.roleLocks, wal.Name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.StaticRole(ctx, req.Storage, wal.Name)
+	if err != nil {
+		return err
+	}
+	if role == nil || !role.LastVaultRotation.Before(wal.LastVaultRotation) {
+		// The role was deleted or has stored a newer password since
+		return nil
+	}
+
+	db, err := b.GetConnection(ctx, req.Storage, role.DBName)
+	if err != nil {
+		return err
+	}
+	if err := b.setCredentials(ctx, db, role, wal.Password); err != nil {
+		return err
+	}
+
+	role.Password = wal.Password
+	role.LastVaultRotation = wal.LastVaultRotation
+
+	entry, err := logical.StorageEntryJSON(staticRolePath+wal.Name, role)
+	if err != nil {
+		return err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return err
+	}
+
+	return b.credRotationQueue.Push(wal.Name, role.NextRotation())
+}
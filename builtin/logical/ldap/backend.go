@@ -0,0 +1,171 @@
+package ldap
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/ldaputil"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/queue"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// poolSize is the maximum number of connections to the LDAP servers in use
+// at once
+const poolSize = 5
+
+func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
+	b := Backend(conf)
+	if err := b.Setup(ctx, conf); err != nil {
+		return nil, err
+	}
+
+	b.initQueue(conf)
+	return b, nil
+}
+
+func Backend(conf *logical.BackendConfig) *backend {
+	var b backend
+	b.Backend = &framework.Backend{
+		Help: strings.TrimSpace(backendHelp),
+
+		PathsSpecial: &logical.Paths{
+			SealWrapStorage: []string{
+				"config",
+				staticRolePath + "*",
+			},
+		},
+
+		Paths: []*framework.Path{
+			pathConfig(&b),
+			pathListStaticRoles(&b),
+			pathStaticRoles(&b),
+			pathStaticCredsRead(&b),
+			pathRotateRole(&b),
+			pathListRoles(&b),
+			pathRoles(&b),
+			pathCredsCreate(&b),
+			pathLibraryManageCheckIn(&b),
+			pathListLibraries(&b),
+			pathLibrary(&b),
+			pathLibraryCheckOut(&b),
+			pathLibraryCheckIn(&b),
+			pathLibraryStatus(&b),
+		},
+
+		Secrets: []*framework.Secret{
+			secretCreds(&b),
+			secretCheckOut(&b),
+		},
+
+		Clean:             b.clean,
+		Invalidate:        b.invalidate,
+		WALRollback:       b.walRollback,
+		WALRollbackMinAge: staticRotationWALMinAge,
+		BackendType:       logical.TypeLogical,
+	}
+
+	b.logger = conf.Logger
+	b.credRotationQueue = queue.NewRotationQueue(conf.Logger, func(ctx context.Context, name string) (time.Time, error) {
+		return b.rotateStaticRole(ctx, conf.StorageView, name)
+	})
+	b.roleLocks = locksutil.CreateLocks()
+	return &b
+}
+
+type backend struct {
+	*framework.Backend
+
+	logger log.Logger
+
+	poolLock sync.Mutex
+	pool     *ldaputil.Pool
+
+	// credRotationQueue rotates the passwords of the static roles as they
+	// are due
+	credRotationQueue *queue.RotationQueue
+	roleLocks         []*locksutil.LockEntry
+
+	// checkOutLock serializes the changes to libraries and check-outs, as a
+	// service account can only be in one library
+	checkOutLock sync.Mutex
+}
+
+func (b *backend) invalidate(_ context.Context, key string) {
+	switch key {
+	case "config":
+		b.resetPool()
+	}
+}
+
+// clean stops the rotation queue and closes the connections
+func (b *backend) clean(_ context.Context) {
+	b.credRotationQueue.Stop()
+	b.resetPool()
+}
+
+// resetPool closes the connections to the LDAP servers, which depend on the
+// configuration
+func (b *backend) resetPool() {
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	if b.pool != nil {
+		b.pool.Close()
+		b.pool = nil
+	}
+}
+
+func (b *backend) getPool(cfg *configEntry) *ldaputil.Pool {
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	if b.pool == nil {
+		b.pool = ldaputil.NewPool(cfg.connectionConfig(), poolSize, b.logger)
+	}
+	return b.pool
+}
+
+// withConn calls f with a connection bound as the configured bind DN
+func (b *backend) withConn(ctx context.Context, cfg *configEntry, f func(conn *ldap.Conn) error) error {
+	pool := b.getPool(cfg)
+	for attempt := 0; ; attempt++ {
+		c, err := pool.Get(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = c.Bind(cfg.BindDN, cfg.BindPassword)
+		if err == nil {
+			err = f(c.Conn)
+		}
+		if err == nil {
+			pool.Put(c, false)
+			return nil
+		}
+
+		// Connections closed by the server are only noticed when used, so
+		// retry once on a new connection if this one is broken
+		broken := !ldaputil.Healthy(c.Conn)
+		pool.Put(c, broken)
+		if !broken || attempt > 0 {
+			return err
+		}
+		b.logger.Debug("retrying on a new connection", "error", err)
+	}
+}
+
+const backendHelp = `
+The LDAP backend manages the passwords of LDAP accounts.
+
+Static roles rotate the password of existing accounts on a schedule, dynamic
+roles create accounts from LDIF templates for the duration of a lease, and
+libraries of service accounts are checked out for exclusive use.
+
+After mounting this backend, configure it using the "config" endpoint.
+`
@@ -0,0 +1,465 @@
+package ldap
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/ldaputil/ldaptest"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func testBackend(t *testing.T) (*backend, logical.Storage) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.(*backend), config.StorageView
+}
+
+func testRequest(t *testing.T, b *backend, req *logical.Request) *logical.Response {
+	resp, err := b.HandleRequest(context.Background(), req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	return resp
+}
+
+func testConfigure(t *testing.T, b *backend, s logical.Storage, url string, data map[string]interface{}) {
+	configData := map[string]interface{}{
+		"url":      url,
+		"binddn":   ldaptest.BindDN,
+		"bindpass": ldaptest.BindPassword,
+		"userdn":   ldaptest.UserDN,
+	}
+	for k, v := range data {
+		configData[k] = v
+	}
+
+	testRequest(t, b, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   s,
+		Data:      configData,
+	})
+}
+
+func TestBackend_StaticRole(t *testing.T) {
+	server := ldaptest.NewServer(t)
+	defer server.Close()
+
+	b, s := testBackend(t)
+	defer b.Cleanup(context.Background())
+	testConfigure(t, b, s, server.URL, nil)
+
+	aliceDN := "uid=alice," + ldaptest.UserDN
+	bobDN := "uid=bob," + ldaptest.UserDN
+
+	// The account is searched for and its password rotated on creation
+	testRequest(t, b, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "static-role/alice",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"username":        "alice",
+			"rotation_period": "1h",
+		},
+	})
+	resp := testRequest(t, b, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-cred/alice",
+		Storage:   s,
+	})
+	password := resp.Data["password"].(string)
+	if len(password) != defaultPasswordLength || password == "alicepw" {
+		t.Fatalf("bad: password %q", password)
+	}
+	if server.Password(aliceDN) != password {
+		t.Fatalf("password was not set on the server")
+	}
+	if ttl := resp.Data["ttl"].(int64); ttl <= 3500 || ttl > 3600 {
+		t.Fatalf("bad: ttl %d", ttl)
+	}
+
+	resp = testRequest(t, b, &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "static-role/",
+		Storage:   s,
+	})
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"alice"}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	testRequest(t, b, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "rotate-role/alice",
+		Storage:   s,
+	})
+	resp = testRequest(t, b, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-cred/alice",
+		Storage:   s,
+	})
+	if resp.Data["password"] == password || server.Password(aliceDN) != resp.Data["password"] {
+		t.Fatalf("password was not rotated")
+	}
+	current := resp.Data["password"].(string)
+
+	// A password set on the server whose role could not be stored is stored
+	// from its WAL entry, while older entries are dropped
+	if _, err := framework.PutWAL(context.Background(), s, staticRotationWALKind, &staticRotationWAL{
+		Name:              "alice",
+		Password:          "stale",
+		LastVaultRotation: time.Now().Add(-time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rollback := func() {
+		testRequest(t, b, &logical.Request{
+			Operation: logical.RollbackOperation,
+			Storage:   s,
+			Data: map[string]interface{}{
+				"immediate": true,
+			},
+		})
+	}
+	rollback()
+	resp = testRequest(t, b, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-cred/alice",
+		Storage:   s,
+	})
+	if resp.Data["password"] != current || server.Password(aliceDN) != current {
+		t.Fatal("expected the stale WAL entry to be dropped")
+	}
+
+	if _, err := framework.PutWAL(context.Background(), s, staticRotationWALKind, &staticRotationWAL{
+		Name:              "alice",
+		Password:          "pending",
+		LastVaultRotation: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rollback()
+	resp = testRequest(t, b, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-cred/alice",
+		Storage:   s,
+	})
+	if resp.Data["password"] != "pending" || server.Password(aliceDN) != "pending" {
+		t.Fatalf("expected the pending password to be stored, got %q", resp.Data["password"])
+	}
+	if keys, err := framework.ListWAL(context.Background(), s); err != nil || len(keys) != 0 {
+		t.Fatalf("expected the WAL entries to be deleted, got %v %v", keys, err)
+	}
+
+	// Unknown accounts are rejected
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "static-role/carol",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"username": "carol",
+		},
+	})
+	if err != nil || !resp.IsError() {
+		t.Fatalf("expected error, got resp:%#v err:%s", resp, err)
+	}
+
+	// Passwords of Active Directory are set through unicodePwd
+	testConfigure(t, b, s, server.URL, map[string]interface{}{
+		"schema":          "ad",
+		"userattr":        "uid",
+		"password_length": 16,
+	})
+	testRequest(t, b, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "static-role/bob",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"username": "bob",
+			"dn":       bobDN,
+		},
+	})
+	resp = testRequest(t, b, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-cred/bob",
+		Storage:   s,
+	})
+	password = resp.Data["password"].(string)
+	if len(password) != 16 || server.Password(bobDN) != password {
+		t.Fatalf("bad: password %q, server has %q", password, server.Password(bobDN))
+	}
+
+	testRequest(t, b, &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "static-role/bob",
+		Storage:   s,
+	})
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-cred/bob",
+		Storage:   s,
+	})
+	if err != nil || !resp.IsError() {
+		t.Fatalf("expected error, got resp:%#v err:%s", resp, err)
+	}
+}
+
+func TestBackend_DynamicRole(t *testing.T) {
+	server := ldaptest.NewServer(t)
+	defer server.Close()
+
+	b, s := testBackend(t)
+	defer b.Cleanup(context.Background())
+	testConfigure(t, b, s, server.URL, nil)
+
+	groupDN := "cn=devs," + ldaptest.GroupDN
+
+	// Invalid LDIF is rejected
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/dev",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"creation_ldif": "uid: {{.Username}}",
+			"deletion_ldif": "dn: uid={{.Username}},ou=users,dc=example,dc=org\nchangetype: delete",
+		},
+	})
+	if err != nil || !resp.IsError() {
+		t.Fatalf("expected error, got resp:%#v err:%s", resp, err)
+	}
+
+	testRequest(t, b, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/dev",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"creation_ldif": `
+dn: uid={{.Username}},ou=users,dc=example,dc=org
+objectClass: person
+uid: {{.Username}}
+userPassword: {{.Password}}
+
+# Add the account to the devs group
+dn: cn=devs,ou=groups,dc=example,dc=org
+changetype: modify
+add: member
+member: uid={{.Username}},ou=users,dc=example,dc=org
+-
+`,
+			"deletion_ldif": `
+dn: cn=devs,ou=groups,dc=example,dc=org
+changetype: modify
+delete: member
+member: uid={{.Username}},ou=users,dc=example,dc=org
+-
+
+dn: uid={{.Username}},ou=users,dc=example,dc=org
+changetype: delete
+`,
+			"default_ttl": "1h",
+			"max_ttl":     "2h",
+		},
+	})
+
+	resp = testRequest(t, b, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/dev",
+		Storage:   s,
+	})
+	username := resp.Data["username"].(string)
+	userDN := "uid=" + username + "," + ldaptest.UserDN
+	if server.Entry(userDN) == nil {
+		t.Fatalf("account %q was not created", username)
+	}
+	if server.Password(userDN) != resp.Data["password"] {
+		t.Fatalf("password was not set on the server")
+	}
+	if members := server.Entry(groupDN)["member"]; len(members) != 2 || members[1] != userDN {
+		t.Fatalf("bad: members %v", members)
+	}
+	if resp.Secret.TTL.Hours() != 1 {
+		t.Fatalf("bad: ttl %s", resp.Secret.TTL)
+	}
+
+	testRequest(t, b, &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    resp.Secret,
+	})
+	if server.Entry(userDN) != nil {
+		t.Fatalf("account %q was not deleted", username)
+	}
+	if members := server.Entry(groupDN)["member"]; len(members) != 1 {
+		t.Fatalf("bad: members %v", members)
+	}
+
+	// Revoking again is a no-op
+	testRequest(t, b, &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    resp.Secret,
+	})
+}
+
+func TestBackend_Library(t *testing.T) {
+	server := ldaptest.NewServer(t)
+	defer server.Close()
+	for _, name := range []string{"svc1", "svc2"} {
+		server.AddEntry("uid="+name+","+ldaptest.UserDN, map[string][]string{
+			"objectClass": {"person"},
+			"uid":         {name},
+		})
+	}
+
+	b, s := testBackend(t)
+	defer b.Cleanup(context.Background())
+	testConfigure(t, b, s, server.URL, nil)
+
+	testRequest(t, b, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/shared",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"service_account_names": "svc1,svc2",
+			"ttl":                   "1h",
+			"max_ttl":               "4h",
+		},
+	})
+
+	// Accounts can only be in one library
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/other",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"service_account_names": "svc2",
+		},
+	})
+	if err != nil || !resp.IsError() {
+		t.Fatalf("expected error, got resp:%#v err:%s", resp, err)
+	}
+
+	checkOut := func(entityID string) *logical.Response {
+		return testRequest(t, b, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "library/shared/check-out",
+			Storage:   s,
+			EntityID:  entityID,
+		})
+	}
+	checkIn := func(path, entityID string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   s,
+			EntityID:  entityID,
+			Data:      data,
+		})
+	}
+
+	first := checkOut("entity1")
+	second := checkOut("entity2")
+	accounts := []string{first.Data["service_account_name"].(string), second.Data["service_account_name"].(string)}
+	sort.Strings(accounts)
+	if !reflect.DeepEqual(accounts, []string{"svc1", "svc2"}) {
+		t.Fatalf("bad: checked out %v", accounts)
+	}
+	svc1DN := "uid=" + first.Data["service_account_name"].(string) + "," + ldaptest.UserDN
+	if server.Password(svc1DN) != first.Data["password"] {
+		t.Fatalf("password was not set on the server")
+	}
+	if first.Secret.TTL.Hours() != 1 || first.Secret.MaxTTL.Hours() != 4 {
+		t.Fatalf("bad: ttl %s max_ttl %s", first.Secret.TTL, first.Secret.MaxTTL)
+	}
+
+	// All accounts are checked out
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/shared/check-out",
+		Storage:   s,
+	})
+	if err != nil || !resp.IsError() {
+		t.Fatalf("expected error, got resp:%#v err:%s", resp, err)
+	}
+
+	resp = testRequest(t, b, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "library/shared/status",
+		Storage:   s,
+	})
+	status := resp.Data[first.Data["service_account_name"].(string)].(map[string]interface{})
+	if status["available"] != false || status["borrower_entity_id"] != "entity1" {
+		t.Fatalf("bad: status %#v", resp.Data)
+	}
+
+	// Only the borrower can check an account in
+	resp, err = checkIn("library/shared/check-in", "entity2", map[string]interface{}{
+		"service_account_names": first.Data["service_account_name"],
+	})
+	if err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, got resp:%#v err:%s", resp, err)
+	}
+
+	resp, err = checkIn("library/shared/check-in", "entity1", nil)
+	if err != nil || resp.IsError() {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Data["check_ins"], []string{first.Data["service_account_name"].(string)}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if server.Password(svc1DN) == first.Data["password"] {
+		t.Fatalf("password was not rotated on check-in")
+	}
+
+	// Revoking the lease of an account checked in since does nothing
+	rotated := server.Password(svc1DN)
+	testRequest(t, b, &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    first.Secret,
+	})
+	if server.Password(svc1DN) != rotated {
+		t.Fatalf("password was rotated by the revocation of a stale lease")
+	}
+
+	// Operators can check in any account
+	resp, err = checkIn("library/manage/shared/check-in", "", nil)
+	if err != nil || resp.IsError() {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Data["check_ins"], []string{second.Data["service_account_name"].(string)}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Revoking the lease checks the account in
+	third := checkOut("entity3")
+	testRequest(t, b, &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    third.Secret,
+	})
+	resp = testRequest(t, b, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "library/shared/status",
+		Storage:   s,
+	})
+	for account, status := range resp.Data {
+		if status.(map[string]interface{})["available"] != true {
+			t.Fatalf("service account %q is not available", account)
+		}
+	}
+
+	testRequest(t, b, &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "library/shared",
+		Storage:   s,
+	})
+}
@@ -0,0 +1,244 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/go-ldap/ldap"
+)
+
+// ldifRecord is a change record of an LDIF document. Exactly one of the
+// requests is set.
+type ldifRecord struct {
+	add    *ldap.AddRequest
+	modify *ldap.ModifyRequest
+	del    *ldap.DelRequest
+}
+
+// ldifTemplateData is the data the LDIF templates of dynamic roles are
+// rendered with
+type ldifTemplateData struct {
+	Username string
+	Password string
+
+	// EncodedPassword is the base64 encoding of the password for the
+	// unicodePwd attribute of Active Directory, to use as "unicodePwd:: {{.EncodedPassword}}"
+	EncodedPassword string
+}
+
+func newLDIFTemplateData(username, password string) *ldifTemplateData {
+	return &ldifTemplateData{
+		Username:        username,
+		Password:        password,
+		EncodedPassword: base64.StdEncoding.EncodeToString([]byte(encodeUnicodePwd(password))),
+	}
+}
+
+// renderLDIF renders an LDIF template and parses the result
+func renderLDIF(tmpl string, data *ldifTemplateData) (string, []*ldifRecord, error) {
+	t, err := template.New("ldif").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid LDIF template: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", nil, fmt.Errorf("invalid LDIF template: %s", err)
+	}
+
+	records, err := parseLDIF(buf.String())
+	if err != nil {
+		return "", nil, err
+	}
+	return buf.String(), records, nil
+}
+
+// applyLDIF applies the records in order
+func applyLDIF(conn *ldap.Conn, records []*ldifRecord) error {
+	for _, r := range records {
+		var err error
+		switch {
+		case r.add != nil:
+			err = conn.Add(r.add)
+		case r.modify != nil:
+			err = conn.Modify(r.modify)
+		case r.del != nil:
+			err = conn.Del(r.del)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseLDIF parses the change records of an LDIF document (RFC 2849). Records
+// without a changetype are additions. Modifications of a record are sent in a
+// single request, which applies additions, then deletions, then replacements.
+func parseLDIF(text string) ([]*ldifRecord, error) {
+	var records []*ldifRecord
+	for _, lines := range ldifRecordLines(text) {
+		r, err := parseLDIFRecord(lines)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			records = append(records, r)
+		}
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("LDIF holds no records")
+	}
+	return records, nil
+}
+
+// ldifRecordLines splits an LDIF document in the lines of its records,
+// unfolding continuation lines and dropping comments and trailing spaces
+func ldifRecordLines(text string) [][]string {
+	var records [][]string
+	var current []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		switch {
+		case strings.TrimSpace(line) == "":
+			if len(current) > 0 {
+				records = append(records, current)
+				current = nil
+			}
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, " ") && len(current) > 0:
+			current[len(current)-1] += line[1:]
+		default:
+			current = append(current, line)
+		}
+	}
+	if len(current) > 0 {
+		records = append(records, current)
+	}
+	return records
+}
+
+func parseLDIFRecord(lines []string) (*ldifRecord, error) {
+	name, dn, err := parseLDIFLine(lines[0])
+	if err != nil {
+		return nil, err
+	}
+	if name == "version" {
+		if len(lines) == 1 {
+			return nil, nil
+		}
+		lines = lines[1:]
+		if name, dn, err = parseLDIFLine(lines[0]); err != nil {
+			return nil, err
+		}
+	}
+	if !strings.EqualFold(name, "dn") {
+		return nil, fmt.Errorf("LDIF record must start with a dn, got %q", lines[0])
+	}
+	lines = lines[1:]
+
+	changeType := "add"
+	if len(lines) > 0 {
+		name, value, err := parseLDIFLine(lines[0])
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(name, "changetype") {
+			changeType = strings.ToLower(value)
+			lines = lines[1:]
+		}
+	}
+
+	switch changeType {
+	case "add":
+		req := ldap.NewAddRequest(dn)
+		var order []string
+		attrs := make(map[string][]string)
+		for _, line := range lines {
+			name, value, err := parseLDIFLine(line)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := attrs[name]; !ok {
+				order = append(order, name)
+			}
+			attrs[name] = append(attrs[name], value)
+		}
+		if len(order) == 0 {
+			return nil, fmt.Errorf("LDIF add record for %q has no attributes", dn)
+		}
+		for _, name := range order {
+			req.Attribute(name, attrs[name])
+		}
+		return &ldifRecord{add: req}, nil
+
+	case "delete":
+		if len(lines) > 0 {
+			return nil, fmt.Errorf("LDIF delete record for %q cannot have attributes", dn)
+		}
+		return &ldifRecord{del: ldap.NewDelRequest(dn, nil)}, nil
+
+	case "modify":
+		req := ldap.NewModifyRequest(dn)
+		for len(lines) > 0 {
+			op, attr, err := parseLDIFLine(lines[0])
+			if err != nil {
+				return nil, err
+			}
+			lines = lines[1:]
+
+			var values []string
+			for len(lines) > 0 && lines[0] != "-" {
+				name, value, err := parseLDIFLine(lines[0])
+				if err != nil {
+					return nil, err
+				}
+				if !strings.EqualFold(name, attr) {
+					return nil, fmt.Errorf("LDIF modification of %q in %q holds attribute %q", attr, dn, name)
+				}
+				values = append(values, value)
+				lines = lines[1:]
+			}
+			if len(lines) > 0 {
+				lines = lines[1:]
+			}
+
+			switch strings.ToLower(op) {
+			case "add":
+				req.Add(attr, values)
+			case "delete":
+				req.Delete(attr, values)
+			case "replace":
+				req.Replace(attr, values)
+			default:
+				return nil, fmt.Errorf("invalid LDIF modification %q in %q", op, dn)
+			}
+		}
+		return &ldifRecord{modify: req}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported LDIF changetype %q", changeType)
+	}
+}
+
+// parseLDIFLine parses an "attr: value" line, where "attr:: value" holds a
+// base64 encoded value
+func parseLDIFLine(line string) (string, string, error) {
+	i := strings.Index(line, ":")
+	if i < 1 {
+		return "", "", fmt.Errorf("invalid LDIF line %q", line)
+	}
+
+	name, value := line[:i], line[i+1:]
+	if strings.HasPrefix(value, ":") {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+		if err != nil {
+			return "", "", fmt.Errorf("invalid base64 value of %q in LDIF: %s", name, err)
+		}
+		return name, string(decoded), nil
+	}
+	return name, strings.TrimLeft(value, " "), nil
+}
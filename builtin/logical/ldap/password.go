@@ -0,0 +1,67 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+
+	"github.com/go-ldap/ldap"
+	"github.com/hashicorp/vault/helper/ldaputil"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+)
+
+// generatePassword returns a random password holding uppercase and lowercase
+// letters, digits and a symbol, to satisfy the password complexity rules of
+// directories
+func generatePassword(cfg *configEntry) (string, error) {
+	return credsutil.RandomAlphaNumeric(cfg.PasswordLength, true)
+}
+
+// encodeUnicodePwd encodes a password for the unicodePwd attribute of Active
+// Directory, which holds the quoted password in UTF-16LE
+func encodeUnicodePwd(password string) string {
+	encoded := utf16.Encode([]rune(`"` + password + `"`))
+	b := make([]byte, 2*len(encoded))
+	for i, c := range encoded {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return string(b)
+}
+
+// setPassword resets the password of the entry with the given DN
+func setPassword(conn *ldap.Conn, cfg *configEntry, dn, password string) error {
+	req := ldap.NewModifyRequest(dn)
+	switch cfg.Schema {
+	case schemaAD:
+		req.Replace("unicodePwd", []string{encodeUnicodePwd(password)})
+	default:
+		req.Replace("userPassword", []string{password})
+	}
+
+	if err := conn.Modify(req); err != nil {
+		return fmt.Errorf("error setting password of %q: %s", dn, err)
+	}
+	return nil
+}
+
+// findUserDN searches for the DN of the account with the given name
+func findUserDN(conn *ldap.Conn, cfg *configEntry, username string) (string, error) {
+	if cfg.UserDN == "" {
+		return "", fmt.Errorf("userdn must be configured to search for account %q", username)
+	}
+
+	result, err := ldaputil.Search(conn, &ldap.SearchRequest{
+		BaseDN:     cfg.UserDN,
+		Scope:      ldap.ScopeWholeSubtree,
+		Filter:     fmt.Sprintf("(%s=%s)", cfg.UserAttr, ldap.EscapeFilter(username)),
+		Attributes: []string{"dn"},
+		SizeLimit:  2,
+	}, 0)
+	if err != nil {
+		return "", fmt.Errorf("error searching for account %q: %s", username, err)
+	}
+	if len(result.Entries) != 1 {
+		return "", fmt.Errorf("account %q does not exist or is not unique", username)
+	}
+	return result.Entries[0].DN, nil
+}
@@ -0,0 +1,358 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-ldap/ldap"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathLibraryCheckOut(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/" + framework.GenericNameRegex("name") + "/check-out$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the library.",
+			},
+
+			"ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Duration of the check-out, at most the ttl of the library.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathLibraryCheckOutUpdate,
+		},
+
+		HelpSynopsis:    pathLibraryCheckOutHelpSyn,
+		HelpDescription: pathLibraryCheckOutHelpDesc,
+	}
+}
+
+func pathLibraryCheckIn(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/" + framework.GenericNameRegex("name") + "/check-in$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the library.",
+			},
+
+			"service_account_names": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Names of the service accounts to check in. Can be omitted if only one account is checked out by the caller.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathLibraryCheckInUpdate(true),
+		},
+
+		HelpSynopsis:    pathLibraryCheckInHelpSyn,
+		HelpDescription: pathLibraryCheckInHelpDesc,
+	}
+}
+
+func pathLibraryManageCheckIn(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/manage/" + framework.GenericNameRegex("name") + "/check-in$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the library.",
+			},
+
+			"service_account_names": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Names of the service accounts to check in. Can be omitted if only one account is checked out.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathLibraryCheckInUpdate(false),
+		},
+
+		HelpSynopsis:    pathLibraryManageCheckInHelpSyn,
+		HelpDescription: pathLibraryManageCheckInHelpDesc,
+	}
+}
+
+func pathLibraryStatus(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/" + framework.GenericNameRegex("name") + "/status$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the library.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathLibraryStatusRead,
+		},
+
+		HelpSynopsis:    pathLibraryStatusHelpSyn,
+		HelpDescription: pathLibraryStatusHelpDesc,
+	}
+}
+
+func (b *backend) pathLibraryCheckOutUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	b.checkOutLock.Lock()
+	defer b.checkOutLock.Unlock()
+
+	lib, err := b.Library(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if lib == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown library: %s", name)), nil
+	}
+
+	cfg, err := b.requireConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	account := ""
+	for _, candidate := range lib.ServiceAccountNames {
+		checkOut, err := b.CheckOut(ctx, req.Storage, candidate)
+		if err != nil {
+			return nil, err
+		}
+		if checkOut == nil {
+			account = candidate
+			break
+		}
+	}
+	if account == "" {
+		return logical.ErrorResponse(fmt.Sprintf("no service accounts available for check-out in library %q", name)), nil
+	}
+
+	// A new password keeps the previous borrowers out
+	password, err := b.rotateServiceAccount(ctx, cfg, account)
+	if err != nil {
+		return nil, err
+	}
+
+	checkOutID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := logical.StorageEntryJSON(checkOutPath+account, &checkOutEntry{
+		LibraryName:      name,
+		CheckOutID:       checkOutID,
+		BorrowerEntityID: req.EntityID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	ttl := b.System().DefaultLeaseTTL()
+	if lib.TTL != 0 {
+		ttl = lib.TTL
+	}
+	if ttlRaw, ok := data.GetOk("ttl"); ok {
+		requested := time.Duration(ttlRaw.(int)) * time.Second
+		if requested > 0 && requested < ttl {
+			ttl = requested
+		}
+	}
+	if lib.MaxTTL != 0 && ttl > lib.MaxTTL {
+		ttl = lib.MaxTTL
+	}
+
+	resp := b.Secret(SecretCheckOutType).Response(map[string]interface{}{
+		"service_account_name": account,
+		"password":             password,
+	}, map[string]interface{}{
+		"service_account_name": account,
+		"library":              name,
+		"check_out_id":         checkOutID,
+	})
+	resp.Secret.TTL = ttl
+	resp.Secret.MaxTTL = lib.MaxTTL
+	return resp, nil
+}
+
+func (b *backend) pathLibraryCheckInUpdate(enforce bool) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+
+		b.checkOutLock.Lock()
+		defer b.checkOutLock.Unlock()
+
+		lib, err := b.Library(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if lib == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown library: %s", name)), nil
+		}
+		enforce = enforce && !lib.DisableCheckInEnforcement
+
+		accounts := data.Get("service_account_names").([]string)
+		for _, account := range accounts {
+			if !strutil.StrListContains(lib.ServiceAccountNames, account) {
+				return logical.ErrorResponse(fmt.Sprintf("service account %q is not in library %q", account, name)), nil
+			}
+		}
+
+		// Find the accounts the caller can check in
+		checkOuts := make(map[string]*checkOutEntry)
+		for _, account := range lib.ServiceAccountNames {
+			if len(accounts) > 0 && !strutil.StrListContains(accounts, account) {
+				continue
+			}
+			checkOut, err := b.CheckOut(ctx, req.Storage, account)
+			if err != nil {
+				return nil, err
+			}
+			if checkOut == nil {
+				continue
+			}
+			if enforce && checkOut.BorrowerEntityID != req.EntityID {
+				if len(accounts) > 0 {
+					return nil, logical.ErrPermissionDenied
+				}
+				continue
+			}
+			checkOuts[account] = checkOut
+		}
+		if len(accounts) == 0 && len(checkOuts) > 1 {
+			return logical.ErrorResponse("more than one service account is checked out, service_account_names must be provided"), nil
+		}
+
+		cfg, err := b.requireConfig(ctx, req.Storage)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		checkIns := []string{}
+		for account := range checkOuts {
+			if err := b.checkIn(ctx, req.Storage, cfg, account); err != nil {
+				return nil, err
+			}
+			checkIns = append(checkIns, account)
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"check_ins": checkIns,
+			},
+		}, nil
+	}
+}
+
+func (b *backend) pathLibraryStatusRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	lib, err := b.Library(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if lib == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown library: %s", name)), nil
+	}
+
+	status := make(map[string]interface{}, len(lib.ServiceAccountNames))
+	for _, account := range lib.ServiceAccountNames {
+		checkOut, err := b.CheckOut(ctx, req.Storage, account)
+		if err != nil {
+			return nil, err
+		}
+
+		accountStatus := map[string]interface{}{
+			"available": checkOut == nil,
+		}
+		if checkOut != nil {
+			accountStatus["borrower_entity_id"] = checkOut.BorrowerEntityID
+		}
+		status[account] = accountStatus
+	}
+
+	return &logical.Response{
+		Data: status,
+	}, nil
+}
+
+// checkIn rotates the password of a checked out service account and makes it
+// available. The check-out lock must be held.
+func (b *backend) checkIn(ctx context.Context, s logical.Storage, cfg *configEntry, account string) error {
+	if _, err := b.rotateServiceAccount(ctx, cfg, account); err != nil {
+		return err
+	}
+	return s.Delete(ctx, checkOutPath+account)
+}
+
+// rotateServiceAccount sets a new password for a service account and returns
+// it
+func (b *backend) rotateServiceAccount(ctx context.Context, cfg *configEntry, account string) (string, error) {
+	password, err := generatePassword(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	err = b.withConn(ctx, cfg, func(conn *ldap.Conn) error {
+		dn, err := findUserDN(conn, cfg, account)
+		if err != nil {
+			return err
+		}
+		return setPassword(conn, cfg, dn, password)
+	})
+	if err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+const pathLibraryCheckOutHelpSyn = `
+Check a service account out of a library.
+`
+
+const pathLibraryCheckOutHelpDesc = `
+This path checks out an available service account of the library for
+exclusive use, and returns its name and a new password. The check-out is a
+lease: the account is checked in when the lease is revoked or expires.
+`
+
+const pathLibraryCheckInHelpSyn = `
+Check service accounts back into a library.
+`
+
+const pathLibraryCheckInHelpDesc = `
+This path checks service accounts back in, making them available to others.
+Their password is rotated so the previous borrower can no longer use them.
+Unless check-in enforcement is disabled on the library, only the entity that
+checked an account out can check it in.
+`
+
+const pathLibraryManageCheckInHelpSyn = `
+Check service accounts back into a library, regardless of their borrower.
+`
+
+const pathLibraryManageCheckInHelpDesc = `
+This path lets operators check service accounts back in, regardless of the
+entity that checked them out. Their password is rotated so the previous
+borrower can no longer use them.
+`
+
+const pathLibraryStatusHelpSyn = `
+Read the check-out status of the service accounts of a library.
+`
+
+const pathLibraryStatusHelpDesc = `
+This path returns, for each service account of the library, whether it is
+available and, if it is checked out, the entity that checked it out.
+`
@@ -0,0 +1,278 @@
+package ldap
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/ldaputil"
+	"github.com/hashicorp/vault/helper/tlsutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	schemaOpenLDAP = "openldap"
+	schemaAD       = "ad"
+
+	defaultPasswordLength = 24
+
+	// minPasswordLength is the shortest password that can be generated with
+	// all character classes
+	minPasswordLength = 10
+)
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config",
+		Fields: map[string]*framework.FieldSchema{
+			"url": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "ldap://127.0.0.1",
+				Description: "LDAP URL to connect to (default: ldap://127.0.0.1). Multiple URLs can be specified by concatenating them with commas; they will be tried in-order.",
+			},
+
+			"binddn": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "LDAP DN of the account used to manage passwords and entries",
+			},
+
+			"bindpass": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "LDAP password of the bind DN",
+			},
+
+			"schema": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     schemaOpenLDAP,
+				Description: "Schema of the directory, 'openldap' or 'ad' for Active Directory. Defaults to 'openldap'",
+			},
+
+			"userdn": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "LDAP domain to search for the accounts of static roles and libraries (eg: ou=People,dc=example,dc=org)",
+			},
+
+			"userattr": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Attribute matched against the account names of static roles and libraries (default: uid for openldap, sAMAccountName for ad)",
+			},
+
+			"password_length": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Default:     defaultPasswordLength,
+				Description: fmt.Sprintf("Length of the generated passwords. Defaults to %d", defaultPasswordLength),
+			},
+
+			"certificate": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "CA certificate to use when verifying LDAP server certificate, must be x509 PEM encoded (optional)",
+			},
+
+			"insecure_tls": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Skip LDAP server SSL Certificate verification - VERY insecure (optional)",
+			},
+
+			"starttls": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Issue a StartTLS command after establishing unencrypted connection (optional)",
+			},
+
+			"tls_min_version": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "tls12",
+				Description: "Minimum TLS version to use. Accepted values are 'tls10', 'tls11' or 'tls12'. Defaults to 'tls12'",
+			},
+
+			"tls_max_version": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "tls12",
+				Description: "Maximum TLS version to use. Accepted values are 'tls10', 'tls11' or 'tls12'. Defaults to 'tls12'",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigRead,
+			logical.UpdateOperation: b.pathConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigHelpSyn,
+		HelpDescription: pathConfigHelpDesc,
+	}
+}
+
+// Config returns the configuration of the backend, or nil if it is not
+// configured
+func (b *backend) Config(ctx context.Context, s logical.Storage) (*configEntry, error) {
+	entry, err := s.Get(ctx, "config")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var cfg configEntry
+	if err := entry.DecodeJSON(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// requireConfig returns the configuration of the backend, or an error if it
+// is not configured
+func (b *backend) requireConfig(ctx context.Context, s logical.Storage) (*configEntry, error) {
+	cfg, err := b.Config(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return nil, fmt.Errorf("ldap backend not configured")
+	}
+	return cfg, nil
+}
+
+func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	cfg, err := b.Config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"url":             cfg.URL,
+			"binddn":          cfg.BindDN,
+			"schema":          cfg.Schema,
+			"userdn":          cfg.UserDN,
+			"userattr":        cfg.UserAttr,
+			"password_length": cfg.PasswordLength,
+			"certificate":     cfg.Certificate,
+			"insecure_tls":    cfg.InsecureTLS,
+			"starttls":        cfg.StartTLS,
+			"tls_min_version": cfg.TLSMinVersion,
+			"tls_max_version": cfg.TLSMaxVersion,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	cfg := &configEntry{
+		URL:            strings.ToLower(d.Get("url").(string)),
+		BindDN:         d.Get("binddn").(string),
+		BindPassword:   d.Get("bindpass").(string),
+		Schema:         strings.ToLower(d.Get("schema").(string)),
+		UserDN:         d.Get("userdn").(string),
+		UserAttr:       d.Get("userattr").(string),
+		PasswordLength: d.Get("password_length").(int),
+		InsecureTLS:    d.Get("insecure_tls").(bool),
+		StartTLS:       d.Get("starttls").(bool),
+		TLSMinVersion:  d.Get("tls_min_version").(string),
+		TLSMaxVersion:  d.Get("tls_max_version").(string),
+	}
+
+	if cfg.BindDN == "" || cfg.BindPassword == "" {
+		return logical.ErrorResponse("binddn and bindpass are required"), nil
+	}
+
+	switch cfg.Schema {
+	case schemaOpenLDAP:
+		if cfg.UserAttr == "" {
+			cfg.UserAttr = "uid"
+		}
+	case schemaAD:
+		if cfg.UserAttr == "" {
+			cfg.UserAttr = "sAMAccountName"
+		}
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("invalid schema %q, must be %q or %q", cfg.Schema, schemaOpenLDAP, schemaAD)), nil
+	}
+
+	if cfg.PasswordLength < minPasswordLength {
+		return logical.ErrorResponse(fmt.Sprintf("password_length must be at least %d", minPasswordLength)), nil
+	}
+
+	if certificate := d.Get("certificate").(string); certificate != "" {
+		block, _ := pem.Decode([]byte(certificate))
+		if block == nil || block.Type != "CERTIFICATE" {
+			return logical.ErrorResponse("failed to decode PEM block in the certificate"), nil
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to parse certificate %s", err.Error())), nil
+		}
+		cfg.Certificate = certificate
+	}
+
+	if _, ok := tlsutil.TLSLookup[cfg.TLSMinVersion]; !ok {
+		return logical.ErrorResponse("invalid 'tls_min_version'"), nil
+	}
+	if _, ok := tlsutil.TLSLookup[cfg.TLSMaxVersion]; !ok {
+		return logical.ErrorResponse("invalid 'tls_max_version'"), nil
+	}
+	if cfg.TLSMaxVersion < cfg.TLSMinVersion {
+		return logical.ErrorResponse("'tls_max_version' must be greater than or equal to 'tls_min_version'"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("config", cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	// Connections depend on the configuration
+	b.resetPool()
+
+	return nil, nil
+}
+
+type configEntry struct {
+	URL            string `json:"url"`
+	BindDN         string `json:"binddn"`
+	BindPassword   string `json:"bindpass"`
+	Schema         string `json:"schema"`
+	UserDN         string `json:"userdn"`
+	UserAttr       string `json:"userattr"`
+	PasswordLength int    `json:"password_length"`
+	Certificate    string `json:"certificate"`
+	InsecureTLS    bool   `json:"insecure_tls"`
+	StartTLS       bool   `json:"starttls"`
+	TLSMinVersion  string `json:"tls_min_version"`
+	TLSMaxVersion  string `json:"tls_max_version"`
+}
+
+// connectionConfig returns the settings used to connect to the LDAP servers
+func (c *configEntry) connectionConfig() *ldaputil.ConnectionConfig {
+	return &ldaputil.ConnectionConfig{
+		URL:           c.URL,
+		Certificate:   c.Certificate,
+		InsecureTLS:   c.InsecureTLS,
+		StartTLS:      c.StartTLS,
+		TLSMinVersion: c.TLSMinVersion,
+		TLSMaxVersion: c.TLSMaxVersion,
+	}
+}
+
+const pathConfigHelpSyn = `
+Configure the LDAP server to connect to, along with its options.
+`
+
+const pathConfigHelpDesc = `
+This endpoint allows you to configure the LDAP server to connect to and its
+configuration options.
+
+The "binddn" account must be allowed to reset the passwords of the accounts
+managed by static roles and libraries, and to apply the LDIF of dynamic
+roles. The "schema" decides how passwords are set: the userPassword attribute
+for "openldap", or the unicodePwd attribute for "ad", which Active Directory
+only allows to be changed over an encrypted connection.
+
+Accounts of static roles and libraries without a DN are searched for under
+"userdn" with the "userattr" attribute.
+`
@@ -0,0 +1,118 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-ldap/ldap"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+)
+
+func pathCredsCreate(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+		},
+
+		ForwardPerformanceStandby: true,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathCredsCreateRead,
+		},
+
+		HelpSynopsis:    pathCredsCreateReadHelpSyn,
+		HelpDescription: pathCredsCreateReadHelpDesc,
+	}
+}
+
+func (b *backend) pathCredsCreateRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	role, err := b.Role(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", name)), nil
+	}
+
+	cfg, err := b.requireConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	suffix, err := credsutil.RandomAlphaNumeric(10, false)
+	if err != nil {
+		return nil, err
+	}
+	username := fmt.Sprintf("v_%s_%s", name, suffix)
+	password, err := generatePassword(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	templateData := newLDIFTemplateData(username, password)
+	_, creation, err := renderLDIF(role.CreationLDIF, templateData)
+	if err != nil {
+		return nil, err
+	}
+	deletionLDIF, deletion, err := renderLDIF(role.DeletionLDIF, templateData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.withConn(ctx, cfg, func(conn *ldap.Conn) error {
+		if err := applyLDIF(conn, creation); err != nil {
+			// Remove what was created before the failure
+			if rollbackErr := applyLDIF(conn, deletion); rollbackErr != nil {
+				b.logger.Warn("error rolling back account creation", "username", username, "error", rollbackErr)
+			}
+			return fmt.Errorf("error applying creation LDIF: %s", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := b.System().DefaultLeaseTTL()
+	if role.DefaultTTL != 0 {
+		ttl = role.DefaultTTL
+	}
+	maxTTL := b.System().MaxLeaseTTL()
+	if role.MaxTTL != 0 && role.MaxTTL < maxTTL {
+		maxTTL = role.MaxTTL
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	// The rendered deletion LDIF is kept with the lease so the account can be
+	// deleted even if the role was changed or deleted in the meantime
+	resp := b.Secret(SecretCredsType).Response(map[string]interface{}{
+		"username": username,
+		"password": password,
+	}, map[string]interface{}{
+		"username":      username,
+		"role":          name,
+		"deletion_ldif": deletionLDIF,
+	})
+	resp.Secret.TTL = ttl
+	return resp, nil
+}
+
+const pathCredsCreateReadHelpSyn = `
+Request LDAP credentials for a certain role.
+`
+
+const pathCredsCreateReadHelpDesc = `
+This path creates an account from the creation LDIF of the role, with a
+random username and password. The account is deleted with the deletion LDIF
+of the role when the lease is revoked.
+`
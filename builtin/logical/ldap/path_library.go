@@ -0,0 +1,289 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	libraryPath  = "library/"
+	checkOutPath = "checkout/"
+)
+
+func pathListLibraries(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathLibraryList,
+		},
+
+		HelpSynopsis:    pathLibraryHelpSyn,
+		HelpDescription: pathLibraryHelpDesc,
+	}
+}
+
+func pathLibrary(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/" + framework.GenericNameRegex("name") + "$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the library.",
+			},
+
+			"service_account_names": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Names of the existing accounts that can be checked out. An account can only be in one library.",
+			},
+
+			"ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Default duration of a check-out.",
+			},
+
+			"max_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Maximum duration of a check-out, including renewals.",
+			},
+
+			"disable_check_in_enforcement": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "If true, accounts can be checked in by anyone allowed to call the check-in endpoint, not only by the entity that checked them out.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathLibraryRead,
+			logical.UpdateOperation: b.pathLibraryCreateUpdate,
+			logical.DeleteOperation: b.pathLibraryDelete,
+		},
+
+		HelpSynopsis:    pathLibraryHelpSyn,
+		HelpDescription: pathLibraryHelpDesc,
+	}
+}
+
+func (b *backend) Library(ctx context.Context, s logical.Storage, name string) (*libraryEntry, error) {
+	entry, err := s.Get(ctx, libraryPath+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result libraryEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// CheckOut returns the check-out of a service account, or nil if it is
+// available
+func (b *backend) CheckOut(ctx context.Context, s logical.Storage, account string) (*checkOutEntry, error) {
+	entry, err := s.Get(ctx, checkOutPath+account)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result checkOutEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathLibraryList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, libraryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathLibraryRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	lib, err := b.Library(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if lib == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"service_account_names":        lib.ServiceAccountNames,
+			"ttl":                          lib.TTL.Seconds(),
+			"max_ttl":                      lib.MaxTTL.Seconds(),
+			"disable_check_in_enforcement": lib.DisableCheckInEnforcement,
+		},
+	}, nil
+}
+
+func (b *backend) pathLibraryCreateUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("empty library name attribute given"), nil
+	}
+
+	b.checkOutLock.Lock()
+	defer b.checkOutLock.Unlock()
+
+	lib, err := b.Library(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if lib == nil {
+		lib = &libraryEntry{}
+	}
+
+	if accountsRaw, ok := data.GetOk("service_account_names"); ok {
+		accounts := strutil.RemoveDuplicates(accountsRaw.([]string), false)
+
+		// Accounts in use can't be removed
+		for _, account := range lib.ServiceAccountNames {
+			if strutil.StrListContains(accounts, account) {
+				continue
+			}
+			checkOut, err := b.CheckOut(ctx, req.Storage, account)
+			if err != nil {
+				return nil, err
+			}
+			if checkOut != nil {
+				return logical.ErrorResponse(fmt.Sprintf("service account %q is checked out and cannot be removed", account)), nil
+			}
+		}
+
+		// An account can only be in one library
+		names, err := req.Storage.List(ctx, libraryPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range names {
+			if other == name {
+				continue
+			}
+			otherLib, err := b.Library(ctx, req.Storage, other)
+			if err != nil {
+				return nil, err
+			}
+			if otherLib == nil {
+				continue
+			}
+			for _, account := range accounts {
+				if strutil.StrListContains(otherLib.ServiceAccountNames, account) {
+					return logical.ErrorResponse(fmt.Sprintf("service account %q is already in library %q", account, other)), nil
+				}
+			}
+		}
+
+		lib.ServiceAccountNames = accounts
+	}
+	if len(lib.ServiceAccountNames) == 0 {
+		return logical.ErrorResponse("service_account_names is required"), nil
+	}
+
+	if ttlRaw, ok := data.GetOk("ttl"); ok {
+		lib.TTL = time.Duration(ttlRaw.(int)) * time.Second
+	}
+	if maxTTLRaw, ok := data.GetOk("max_ttl"); ok {
+		lib.MaxTTL = time.Duration(maxTTLRaw.(int)) * time.Second
+	}
+	if lib.MaxTTL > 0 && lib.TTL > lib.MaxTTL {
+		return logical.ErrorResponse("ttl cannot be greater than max_ttl"), nil
+	}
+
+	if disableRaw, ok := data.GetOk("disable_check_in_enforcement"); ok {
+		lib.DisableCheckInEnforcement = disableRaw.(bool)
+	}
+
+	entry, err := logical.StorageEntryJSON(libraryPath+name, lib)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathLibraryDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	b.checkOutLock.Lock()
+	defer b.checkOutLock.Unlock()
+
+	lib, err := b.Library(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if lib == nil {
+		return nil, nil
+	}
+
+	for _, account := range lib.ServiceAccountNames {
+		checkOut, err := b.CheckOut(ctx, req.Storage, account)
+		if err != nil {
+			return nil, err
+		}
+		if checkOut != nil {
+			return logical.ErrorResponse(fmt.Sprintf("service account %q is checked out, check it in before deleting the library", account)), nil
+		}
+	}
+
+	if err := req.Storage.Delete(ctx, libraryPath+name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+type libraryEntry struct {
+	ServiceAccountNames       []string      `json:"service_account_names"`
+	TTL                       time.Duration `json:"ttl"`
+	MaxTTL                    time.Duration `json:"max_ttl"`
+	DisableCheckInEnforcement bool          `json:"disable_check_in_enforcement"`
+}
+
+type checkOutEntry struct {
+	LibraryName      string `json:"library_name"`
+	CheckOutID       string `json:"check_out_id"`
+	BorrowerEntityID string `json:"borrower_entity_id"`
+}
+
+const pathLibraryHelpSyn = `
+Manage the libraries of service accounts that can be checked out.
+`
+
+const pathLibraryHelpDesc = `
+This path lets you manage libraries of existing service accounts, which are
+shared by checking them out for exclusive use. The password of an account is
+rotated when it is checked out and when it is checked in, so only the current
+borrower knows it.
+
+The "service_account_names" parameter is required and is the list of the
+accounts of the library. Accounts are searched for under the configured
+"userdn", by its "userattr" attribute.
+
+The "ttl" and "max_ttl" parameters are the default and maximum durations of
+a check-out. A check-out is a lease; revoking it checks the account in.
+
+By default, only the entity that checked an account out can check it in.
+Setting "disable_check_in_enforcement" lets anyone allowed to call the
+check-in endpoint check accounts in. Operators can always check accounts in
+with the "library/manage/<name>/check-in" endpoint.
+`
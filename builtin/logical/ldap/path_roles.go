@@ -0,0 +1,197 @@
+package ldap
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRoleList,
+		},
+
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+func pathRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+
+			"creation_ldif": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "LDIF template applied to create the account.",
+			},
+
+			"deletion_ldif": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "LDIF template applied to delete the account when the lease is revoked.",
+			},
+
+			"default_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Default ttl for role.",
+			},
+
+			"max_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Maximum time a credential is valid for",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRoleRead,
+			logical.UpdateOperation: b.pathRoleCreate,
+			logical.DeleteOperation: b.pathRoleDelete,
+		},
+
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+func (b *backend) Role(ctx context.Context, s logical.Storage, name string) (*roleEntry, error) {
+	entry, err := s.Get(ctx, "role/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathRoleDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(ctx, "role/"+data.Get("name").(string)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathRoleRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	role, err := b.Role(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"creation_ldif": role.CreationLDIF,
+			"deletion_ldif": role.DeletionLDIF,
+			"default_ttl":   role.DefaultTTL.Seconds(),
+			"max_ttl":       role.MaxTTL.Seconds(),
+		},
+	}, nil
+}
+
+func (b *backend) pathRoleList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, "role/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathRoleCreate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("empty role name attribute given"), nil
+	}
+
+	role := &roleEntry{
+		CreationLDIF: data.Get("creation_ldif").(string),
+		DeletionLDIF: data.Get("deletion_ldif").(string),
+		DefaultTTL:   time.Duration(data.Get("default_ttl").(int)) * time.Second,
+		MaxTTL:       time.Duration(data.Get("max_ttl").(int)) * time.Second,
+	}
+	if role.CreationLDIF == "" || role.DeletionLDIF == "" {
+		return logical.ErrorResponse("creation_ldif and deletion_ldif are required"), nil
+	}
+
+	// Check that the templates render to valid LDIF
+	testData := newLDIFTemplateData("v_"+name+"_test", "password")
+	if _, _, err := renderLDIF(role.CreationLDIF, testData); err != nil {
+		return logical.ErrorResponse("creation_ldif: " + err.Error()), nil
+	}
+	if _, _, err := renderLDIF(role.DeletionLDIF, testData); err != nil {
+		return logical.ErrorResponse("deletion_ldif: " + err.Error()), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("role/"+name, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+type roleEntry struct {
+	CreationLDIF string        `json:"creation_ldif"`
+	DeletionLDIF string        `json:"deletion_ldif"`
+	DefaultTTL   time.Duration `json:"default_ttl"`
+	MaxTTL       time.Duration `json:"max_ttl"`
+}
+
+const pathRoleHelpSyn = `
+Manage the dynamic roles that can be created with this backend.
+`
+
+const pathRoleHelpDesc = `
+This path lets you manage the dynamic roles of this backend. Credentials of a
+dynamic role are accounts created for the duration of a lease.
+
+The "creation_ldif" parameter is required and is the LDIF applied to create
+the account. The "deletion_ldif" parameter is required and is the LDIF
+applied to delete it when the lease is revoked. Both are Go templates, which
+can access the following variables:
+
+  * "Username" - The random username generated for the account.
+
+  * "Password" - The random password generated for the account.
+
+  * "EncodedPassword" - The base64 encoded password for the unicodePwd
+    attribute of Active Directory.
+
+Example of a creation_ldif for OpenLDAP:
+
+	dn: uid={{.Username}},ou=users,dc=example,dc=org
+	objectClass: inetOrgPerson
+	uid: {{.Username}}
+	cn: {{.Username}}
+	sn: {{.Username}}
+	userPassword: {{.Password}}
+
+and the matching deletion_ldif:
+
+	dn: uid={{.Username}},ou=users,dc=example,dc=org
+	changetype: delete
+
+Records without a changetype are additions. The "modify" changetype is
+supported with "add", "delete" and "replace" modifications.
+`
@@ -0,0 +1,117 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathStaticCredsRead(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-cred/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the static role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathStaticCredsRead,
+		},
+
+		HelpSynopsis:    pathStaticCredsReadHelpSyn,
+		HelpDescription: pathStaticCredsReadHelpDesc,
+	}
+}
+
+func (b *backend) pathStaticCredsRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	role, err := b.StaticRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown static role: %s", name)), nil
+	}
+
+	ttl := time.Until(role.NextRotation())
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"username":            role.Username,
+			"dn":                  role.DN,
+			"password":            role.Password,
+			"ttl":                 int64(ttl.Seconds()),
+			"rotation_period":     role.RotationPeriod.Seconds(),
+			"last_vault_rotation": role.LastVaultRotation,
+		},
+	}, nil
+}
+
+func pathRotateRole(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "rotate-role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the static role",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRotateRoleUpdate,
+		},
+
+		HelpSynopsis:    pathRotateRoleHelpSyn,
+		HelpDescription: pathRotateRoleHelpDesc,
+	}
+}
+
+func (b *backend) pathRotateRoleUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("empty role name attribute given"), nil
+	}
+
+	next, err := b.rotateStaticRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if next.IsZero() {
+		return logical.ErrorResponse(fmt.Sprintf("unknown static role: %s", name)), nil
+	}
+
+	// Restart the rotation period from now
+	if err := b.credRotationQueue.Push(name, next); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathStaticCredsReadHelpSyn = `
+Request the current credentials of a static role.
+`
+
+const pathStaticCredsReadHelpDesc = `
+This path reads the username and current password of a static role. The
+password is owned by Vault and rotated every rotation period; "ttl" is the
+number of seconds until the next rotation.
+`
+
+const pathRotateRoleHelpSyn = `
+Request to rotate the password of a static role.
+`
+
+const pathRotateRoleHelpDesc = `
+This path rotates the password of the account bound to the given static role.
+The next scheduled rotation is one rotation period from now.
+`
@@ -0,0 +1,231 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	staticRolePath = "static-role/"
+
+	// minRotationPeriod is the shortest rotation period a static role can
+	// have, since the rotation queue is only checked every few seconds
+	minRotationPeriod = 5 * time.Second
+
+	defaultRotationPeriod = 24 * time.Hour
+)
+
+func pathListStaticRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-role/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathStaticRoleList,
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func pathStaticRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+
+			"username": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the existing account whose password is managed by this role.",
+			},
+
+			"dn": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "DN of the account. If not set, the account is searched for under the configured userdn.",
+			},
+
+			"rotation_period": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Default:     int(defaultRotationPeriod.Seconds()),
+				Description: "Period for automatic password rotation.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathStaticRoleRead,
+			logical.UpdateOperation: b.pathStaticRoleCreateUpdate,
+			logical.DeleteOperation: b.pathStaticRoleDelete,
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func (b *backend) StaticRole(ctx context.Context, s logical.Storage, name string) (*staticRoleEntry, error) {
+	entry, err := s.Get(ctx, staticRolePath+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result staticRoleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathStaticRoleDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	lock := locksutil.LockForKey(b.roleLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := req.Storage.Delete(ctx, staticRolePath+name); err != nil {
+		return nil, err
+	}
+
+	if err := b.credRotationQueue.Remove(name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathStaticRoleRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	role, err := b.StaticRole(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"username":            role.Username,
+			"dn":                  role.DN,
+			"rotation_period":     role.RotationPeriod.Seconds(),
+			"last_vault_rotation": role.LastVaultRotation,
+		},
+	}, nil
+}
+
+func (b *backend) pathStaticRoleList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, staticRolePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathStaticRoleCreateUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("empty role name attribute given"), nil
+	}
+
+	lock := locksutil.LockForKey(b.roleLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.StaticRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	rotate := false
+	if role == nil {
+		role = &staticRoleEntry{}
+		rotate = true
+	}
+
+	if usernameRaw, ok := data.GetOk("username"); ok && usernameRaw.(string) != role.Username {
+		role.Username = usernameRaw.(string)
+		rotate = true
+	}
+	if role.Username == "" {
+		return logical.ErrorResponse("empty username attribute given"), nil
+	}
+
+	if dnRaw, ok := data.GetOk("dn"); ok && dnRaw.(string) != role.DN {
+		role.DN = dnRaw.(string)
+		rotate = true
+	}
+
+	if rotationPeriodRaw, ok := data.GetOk("rotation_period"); ok {
+		role.RotationPeriod = time.Duration(rotationPeriodRaw.(int)) * time.Second
+	} else if role.RotationPeriod == 0 {
+		role.RotationPeriod = defaultRotationPeriod
+	}
+	if role.RotationPeriod < minRotationPeriod {
+		return logical.ErrorResponse(fmt.Sprintf("rotation_period must be at least %s", minRotationPeriod)), nil
+	}
+
+	// A new role or account has its password set right away so it never
+	// serves a password Vault doesn't know
+	if rotate {
+		if err := b.setStaticRolePassword(ctx, req.Storage, name, role); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	} else {
+		entry, err := logical.StorageEntryJSON(staticRolePath+name, role)
+		if err != nil {
+			return nil, err
+		}
+		if err := req.Storage.Put(ctx, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := b.credRotationQueue.Push(name, role.NextRotation()); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+type staticRoleEntry struct {
+	Username          string        `json:"username"`
+	DN                string        `json:"dn"`
+	Password          string        `json:"password"`
+	RotationPeriod    time.Duration `json:"rotation_period"`
+	LastVaultRotation time.Time     `json:"last_vault_rotation"`
+}
+
+// NextRotation returns when the role's password is due to be rotated
+func (r *staticRoleEntry) NextRotation() time.Time {
+	return r.LastVaultRotation.Add(r.RotationPeriod)
+}
+
+const pathStaticRoleHelpSyn = `
+Manage the static roles that can be created with this backend.
+`
+
+const pathStaticRoleHelpDesc = `
+This path lets you manage the static roles of this backend. A static role is
+bound to an existing LDAP account whose password is owned by Vault and
+rotated on a schedule.
+
+The "username" parameter is required and is the name of the existing account.
+The password of the account is rotated as soon as the role is created.
+
+The "dn" parameter is the DN of the account. If it is not set, the account is
+searched for under the configured "userdn", by its "userattr" attribute.
+
+The "rotation_period" parameter is how often the password is rotated. It
+defaults to 24 hours.
+`
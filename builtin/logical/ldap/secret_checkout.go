@@ -0,0 +1,105 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const SecretCheckOutType = "checkout"
+
+func secretCheckOut(b *backend) *framework.Secret {
+	return &framework.Secret{
+		Type: SecretCheckOutType,
+		Fields: map[string]*framework.FieldSchema{
+			"service_account_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the checked out service account",
+			},
+
+			"password": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Password of the service account",
+			},
+		},
+
+		Renew:  b.secretCheckOutRenew,
+		Revoke: b.secretCheckOutRevoke,
+	}
+}
+
+// currentCheckOut returns the check-out of the lease, or nil if the account
+// was checked in since. The check-out lock must be held.
+func (b *backend) currentCheckOut(ctx context.Context, req *logical.Request) (string, *checkOutEntry, error) {
+	accountRaw, ok := req.Secret.InternalData["service_account_name"]
+	if !ok {
+		return "", nil, fmt.Errorf("secret is missing service_account_name internal data")
+	}
+	checkOutIDRaw, ok := req.Secret.InternalData["check_out_id"]
+	if !ok {
+		return "", nil, fmt.Errorf("secret is missing check_out_id internal data")
+	}
+
+	account := accountRaw.(string)
+	checkOut, err := b.CheckOut(ctx, req.Storage, account)
+	if err != nil {
+		return "", nil, err
+	}
+	if checkOut == nil || checkOut.CheckOutID != checkOutIDRaw.(string) {
+		return account, nil, nil
+	}
+	return account, checkOut, nil
+}
+
+func (b *backend) secretCheckOutRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	b.checkOutLock.Lock()
+	defer b.checkOutLock.Unlock()
+
+	account, checkOut, err := b.currentCheckOut(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if checkOut == nil {
+		return nil, fmt.Errorf("service account %q was checked in", account)
+	}
+
+	lib, err := b.Library(ctx, req.Storage, checkOut.LibraryName)
+	if err != nil {
+		return nil, err
+	}
+	if lib == nil {
+		return nil, fmt.Errorf("error during renew: could not find library with name %s", checkOut.LibraryName)
+	}
+
+	resp := &logical.Response{Secret: req.Secret}
+	resp.Secret.TTL = lib.TTL
+	resp.Secret.MaxTTL = lib.MaxTTL
+	return resp, nil
+}
+
+func (b *backend) secretCheckOutRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	b.checkOutLock.Lock()
+	defer b.checkOutLock.Unlock()
+
+	account, checkOut, err := b.currentCheckOut(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if checkOut == nil {
+		// Already checked in
+		return nil, nil
+	}
+
+	cfg, err := b.requireConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.checkIn(ctx, req.Storage, cfg, account); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
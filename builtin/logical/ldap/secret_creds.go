@@ -0,0 +1,83 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-ldap/ldap"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const SecretCredsType = "creds"
+
+func secretCreds(b *backend) *framework.Secret {
+	return &framework.Secret{
+		Type: SecretCredsType,
+		Fields: map[string]*framework.FieldSchema{
+			"username": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Username of the account",
+			},
+
+			"password": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Password of the account",
+			},
+		},
+
+		Renew:  b.secretCredsRenew,
+		Revoke: b.secretCredsRevoke,
+	}
+}
+
+func (b *backend) secretCredsRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleNameRaw, ok := req.Secret.InternalData["role"]
+	if !ok {
+		return nil, fmt.Errorf("secret is missing role internal data")
+	}
+
+	role, err := b.Role(ctx, req.Storage, roleNameRaw.(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, fmt.Errorf("error during renew: could not find role with name %s", roleNameRaw)
+	}
+
+	resp := &logical.Response{Secret: req.Secret}
+	resp.Secret.TTL = role.DefaultTTL
+	resp.Secret.MaxTTL = role.MaxTTL
+	return resp, nil
+}
+
+func (b *backend) secretCredsRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	deletionRaw, ok := req.Secret.InternalData["deletion_ldif"]
+	if !ok {
+		return nil, fmt.Errorf("secret is missing deletion_ldif internal data")
+	}
+
+	deletion, err := parseLDIF(deletionRaw.(string))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := b.requireConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.withConn(ctx, cfg, func(conn *ldap.Conn) error {
+		err := applyLDIF(conn, deletion)
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			// Already deleted
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error applying deletion LDIF: %s", err)
+	}
+
+	return nil, nil
+}
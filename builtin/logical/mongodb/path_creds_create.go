@@ -19,6 +19,8 @@ func pathCredsCreate(b *backend) *framework.Path {
 			},
 		},
 
+		ForwardPerformanceStandby: true,
+
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation: b.pathCredsCreateRead,
 		},
@@ -0,0 +1,86 @@
+package pki
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+const (
+	acmeChallengeHTTP01 = "http-01"
+	acmeChallengeDNS01  = "dns-01"
+)
+
+// ACMEChallengeValidator checks the responses to ACME challenges. The
+// default implementation performs real HTTP requests and DNS lookups; it can
+// be replaced, for example to validate against a local stub in tests.
+type ACMEChallengeValidator interface {
+	// ValidateHTTP01 checks that the key authorization is served at
+	// http://<domain>/.well-known/acme-challenge/<token>
+	ValidateHTTP01(domain, token, keyAuthorization string) error
+
+	// ValidateDNS01 checks that the digest of the key authorization is
+	// published in a TXT record at _acme-challenge.<domain>
+	ValidateDNS01(domain, keyAuthorization string) error
+}
+
+// acmeDNS01Digest returns the TXT record value expected for a dns-01
+// challenge
+func acmeDNS01Digest(keyAuthorization string) string {
+	digest := sha256.Sum256([]byte(keyAuthorization))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+type acmeDefaultValidator struct{}
+
+var _ ACMEChallengeValidator = acmeDefaultValidator{}
+
+func (acmeDefaultValidator) ValidateHTTP01(domain, token, keyAuthorization string) error {
+	client := cleanhttp.DefaultClient()
+	client.Timeout = 10 * time.Second
+
+	resp, err := client.Get(fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", domain, token))
+	if err != nil {
+		return fmt.Errorf("error fetching challenge response: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d fetching challenge response", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return fmt.Errorf("error reading challenge response: %v", err)
+	}
+
+	if strings.TrimSpace(string(body)) != keyAuthorization {
+		return fmt.Errorf("challenge response does not match the key authorization")
+	}
+
+	return nil
+}
+
+func (acmeDefaultValidator) ValidateDNS01(domain, keyAuthorization string) error {
+	records, err := net.LookupTXT("_acme-challenge." + domain)
+	if err != nil {
+		return fmt.Errorf("error looking up TXT records: %v", err)
+	}
+
+	expected := acmeDNS01Digest(keyAuthorization)
+	for _, record := range records {
+		if record == expected {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no TXT record matches the key authorization")
+}
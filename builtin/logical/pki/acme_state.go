@@ -0,0 +1,182 @@
+package pki
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// acmeAccount is an ACME account registered against a role
+type acmeAccount struct {
+	ID                   string    `json:"id"`
+	Role                 string    `json:"role"`
+	Status               string    `json:"status"`
+	Contact              []string  `json:"contact"`
+	TermsOfServiceAgreed bool      `json:"terms_of_service_agreed"`
+	JWK                  []byte    `json:"jwk"`
+	KeyThumbprint        string    `json:"key_thumbprint"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+func (a *acmeAccount) publicKey() (*jose.JSONWebKey, error) {
+	var key jose.JSONWebKey
+	if err := key.UnmarshalJSON(a.JWK); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// acmeIdentifier is an identifier an order requests a certificate for
+type acmeIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// acmeOrder is a request for a certificate covering a set of identifiers
+type acmeOrder struct {
+	ID                string           `json:"id"`
+	AccountID         string           `json:"account_id"`
+	Status            string           `json:"status"`
+	Expires           time.Time        `json:"expires"`
+	Identifiers       []acmeIdentifier `json:"identifiers"`
+	AuthorizationIDs  []string         `json:"authorization_ids"`
+	CertificateSerial string           `json:"certificate_serial"`
+}
+
+// acmeAuthorization is the proof of control of a single identifier
+type acmeAuthorization struct {
+	ID         string           `json:"id"`
+	AccountID  string           `json:"account_id"`
+	Status     string           `json:"status"`
+	Expires    time.Time        `json:"expires"`
+	Identifier acmeIdentifier   `json:"identifier"`
+	Wildcard   bool             `json:"wildcard"`
+	Challenges []*acmeChallenge `json:"challenges"`
+}
+
+// acmeChallenge is a way of proving control of an identifier
+type acmeChallenge struct {
+	Type      string     `json:"type"`
+	Token     string     `json:"token"`
+	Status    string     `json:"status"`
+	Validated time.Time  `json:"validated"`
+	Error     *acmeError `json:"error"`
+}
+
+// acmeCertificate is a certificate issued through ACME
+type acmeCertificate struct {
+	AccountID string `json:"account_id"`
+	PEMChain  string `json:"pem_chain"`
+}
+
+const (
+	acmeAccountPrefix       = "acme/accounts/"
+	acmeAccountKeyPrefix    = "acme/account-keys/"
+	acmeAccountOrderPrefix  = "acme/account-orders/"
+	acmeOrderPrefix         = "acme/orders/"
+	acmeAuthorizationPrefix = "acme/authorizations/"
+	acmeCertificatePrefix   = "acme/certs/"
+)
+
+func acmeGet(ctx context.Context, s logical.Storage, key string, out interface{}) (bool, error) {
+	entry, err := s.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if entry == nil {
+		return false, nil
+	}
+	if err := entry.DecodeJSON(out); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func acmePut(ctx context.Context, s logical.Storage, key string, value interface{}) error {
+	entry, err := logical.StorageEntryJSON(key, value)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+func (b *backend) acmeGetAccount(ctx context.Context, s logical.Storage, id string) (*acmeAccount, error) {
+	var account acmeAccount
+	ok, err := acmeGet(ctx, s, acmeAccountPrefix+id, &account)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// acmeGetAccountByKey returns the account registered with the key having
+// the given thumbprint
+func (b *backend) acmeGetAccountByKey(ctx context.Context, s logical.Storage, thumbprint string) (*acmeAccount, error) {
+	var id string
+	ok, err := acmeGet(ctx, s, acmeAccountKeyPrefix+thumbprint, &id)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return b.acmeGetAccount(ctx, s, id)
+}
+
+func (b *backend) acmeGetOrder(ctx context.Context, s logical.Storage, id string) (*acmeOrder, error) {
+	var order acmeOrder
+	ok, err := acmeGet(ctx, s, acmeOrderPrefix+id, &order)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (b *backend) acmeGetAuthorization(ctx context.Context, s logical.Storage, id string) (*acmeAuthorization, error) {
+	var authz acmeAuthorization
+	ok, err := acmeGet(ctx, s, acmeAuthorizationPrefix+id, &authz)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	if authz.Status == acmeStatusPending && time.Now().After(authz.Expires) {
+		authz.Status = acmeStatusExpired
+	}
+	return &authz, nil
+}
+
+// acmeUpdateOrderStatus recomputes the status of an order from its
+// authorizations
+func (b *backend) acmeUpdateOrderStatus(ctx context.Context, s logical.Storage, order *acmeOrder) error {
+	switch order.Status {
+	case acmeStatusValid, acmeStatusInvalid, acmeStatusProcessing:
+		return nil
+	}
+
+	if time.Now().After(order.Expires) {
+		order.Status = acmeStatusInvalid
+		return nil
+	}
+
+	status := acmeStatusReady
+	for _, id := range order.AuthorizationIDs {
+		authz, err := b.acmeGetAuthorization(ctx, s, id)
+		if err != nil {
+			return err
+		}
+		if authz == nil {
+			order.Status = acmeStatusInvalid
+			return nil
+		}
+		switch authz.Status {
+		case acmeStatusValid:
+		case acmeStatusPending:
+			status = acmeStatusPending
+		default:
+			order.Status = acmeStatusInvalid
+			return nil
+		}
+	}
+	order.Status = status
+
+	return nil
+}
@@ -0,0 +1,366 @@
+package pki
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const testACMEBaseURL = "https://vault.example.com/v1/pki"
+
+// testACMEValidator accepts challenges whose key authorization matches the
+// expected account thumbprint
+type testACMEValidator struct {
+	thumbprint string
+	validated  []string
+}
+
+func (v *testACMEValidator) ValidateHTTP01(domain, token, keyAuthorization string) error {
+	if keyAuthorization != token+"."+v.thumbprint {
+		return fmt.Errorf("bad key authorization %q", keyAuthorization)
+	}
+	v.validated = append(v.validated, "http-01:"+domain)
+	return nil
+}
+
+func (v *testACMEValidator) ValidateDNS01(domain, keyAuthorization string) error {
+	if !strings.HasSuffix(keyAuthorization, "."+v.thumbprint) {
+		return fmt.Errorf("bad key authorization %q", keyAuthorization)
+	}
+	v.validated = append(v.validated, "dns-01:"+domain)
+	return nil
+}
+
+type testACMEClient struct {
+	t       *testing.T
+	b       *backend
+	storage logical.Storage
+	key     *ecdsa.PrivateKey
+	kid     string
+	nonce   string
+}
+
+func (c *testACMEClient) request(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+	c.t.Helper()
+
+	resp, err := c.b.HandleRequest(context.Background(), &logical.Request{
+		Operation: op,
+		Path:      path,
+		Storage:   c.storage,
+		Data:      data,
+	})
+	if err != nil {
+		c.t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Data[logical.HTTPStatusCode] == nil {
+		c.t.Fatalf("expected a raw response, got %#v", resp)
+	}
+	if nonce := resp.Headers["Replay-Nonce"]; len(nonce) == 1 {
+		c.nonce = nonce[0]
+	}
+	return resp
+}
+
+// post signs payload with the account key and sends it to the role's ACME
+// path, using the last nonce returned by the server
+func (c *testACMEClient) post(path string, payload interface{}) *logical.Response {
+	c.t.Helper()
+
+	var payloadBytes []byte
+	if payload != nil {
+		var err error
+		payloadBytes, err = json.Marshal(payload)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	return c.postRaw(path, payloadBytes, c.nonce, testACMEBaseURL+"/"+path)
+}
+
+func (c *testACMEClient) postRaw(path string, payload []byte, nonce, url string) *logical.Response {
+	c.t.Helper()
+
+	opts := &jose.SignerOptions{}
+	opts.WithHeader("url", url)
+	opts.NonceSource = staticNonce(nonce)
+
+	signingKey := jose.SigningKey{Algorithm: jose.ES256}
+	if c.kid == "" {
+		opts.EmbedJWK = true
+		signingKey.Key = c.key
+	} else {
+		signingKey.Key = jose.JSONWebKey{Key: c.key, KeyID: c.kid}
+	}
+
+	signer, err := jose.NewSigner(signingKey, opts)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(jws.FullSerialize()), &data); err != nil {
+		c.t.Fatal(err)
+	}
+
+	return c.request(logical.UpdateOperation, path, data)
+}
+
+type staticNonce string
+
+func (n staticNonce) Nonce() (string, error) {
+	return string(n), nil
+}
+
+func testACMEStatus(t *testing.T, resp *logical.Response, expected int) map[string]interface{} {
+	t.Helper()
+
+	var body map[string]interface{}
+	if raw, ok := resp.Data[logical.HTTPRawBody].([]byte); ok && resp.Data[logical.HTTPContentType] != "application/pem-certificate-chain" {
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if resp.Data[logical.HTTPStatusCode] != expected {
+		t.Fatalf("expected status %d, got %v: %#v", expected, resp.Data[logical.HTTPStatusCode], body)
+	}
+	return body
+}
+
+func testACMEProblem(t *testing.T, resp *logical.Response, errType string) {
+	t.Helper()
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["type"] != acmeErrorPrefix+errType {
+		t.Fatalf("expected %q problem, got %#v", errType, body)
+	}
+}
+
+func TestPki_ACME(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	for path, data := range map[string]map[string]interface{}{
+		"root/generate/internal": {
+			"common_name": "example.com",
+			"ttl":         "48h",
+		},
+		"roles/test": {
+			"allowed_domains":  "example.com",
+			"allow_subdomains": true,
+			"key_type":         "ec",
+			"key_bits":         256,
+			"ttl":              "1h",
+		},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: err: %v resp: %#v", err, resp)
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &testACMEClient{t: t, b: b, storage: storage, key: key}
+
+	// ACME is disabled until configured
+	resp := client.request(logical.ReadOperation, "acme/test/directory", nil)
+	testACMEStatus(t, resp, http.StatusNotFound)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/acme",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"enabled":  true,
+			"base_url": testACMEBaseURL,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	resp = client.request(logical.ReadOperation, "acme/test/directory", nil)
+	directory := testACMEStatus(t, resp, http.StatusOK)
+	if directory["newAccount"] != testACMEBaseURL+"/acme/test/new-account" {
+		t.Fatalf("bad directory: %#v", directory)
+	}
+
+	resp = client.request(logical.HeaderOperation, "acme/test/new-nonce", nil)
+	testACMEStatus(t, resp, http.StatusOK)
+	firstNonce := client.nonce
+
+	// Register the account
+	resp = client.post("acme/test/new-account", map[string]interface{}{
+		"contact":              []string{"mailto:admin@example.com"},
+		"termsOfServiceAgreed": true,
+	})
+	account := testACMEStatus(t, resp, http.StatusCreated)
+	if account["status"] != acmeStatusValid {
+		t.Fatalf("bad account: %#v", account)
+	}
+	client.kid = resp.Headers["Location"][0]
+
+	// Nonces can only be used once
+	resp = client.postRaw("acme/test/new-order", []byte("{}"), firstNonce, testACMEBaseURL+"/acme/test/new-order")
+	testACMEStatus(t, resp, http.StatusBadRequest)
+	testACMEProblem(t, resp, "badNonce")
+
+	// The signed URL must match the request
+	resp = client.postRaw("acme/test/new-order", []byte("{}"), client.nonce, testACMEBaseURL+"/acme/other/new-order")
+	testACMEStatus(t, resp, http.StatusForbidden)
+	testACMEProblem(t, resp, "unauthorized")
+
+	// Names outside of the role are rejected up front
+	resp = client.post("acme/test/new-order", map[string]interface{}{
+		"identifiers": []map[string]string{{"type": "dns", "value": "www.example.net"}},
+	})
+	testACMEStatus(t, resp, http.StatusBadRequest)
+	testACMEProblem(t, resp, "rejectedIdentifier")
+
+	resp = client.post("acme/test/new-order", map[string]interface{}{
+		"identifiers": []map[string]string{{"type": "dns", "value": "www.example.com"}},
+	})
+	order := testACMEStatus(t, resp, http.StatusCreated)
+	orderURL := resp.Headers["Location"][0]
+	if order["status"] != acmeStatusPending {
+		t.Fatalf("bad order: %#v", order)
+	}
+
+	authzURL := order["authorizations"].([]interface{})[0].(string)
+	resp = client.post(strings.TrimPrefix(authzURL, testACMEBaseURL+"/"), nil)
+	authz := testACMEStatus(t, resp, http.StatusOK)
+
+	var challengeURL string
+	for _, raw := range authz["challenges"].([]interface{}) {
+		challenge := raw.(map[string]interface{})
+		if challenge["type"] == acmeChallengeHTTP01 {
+			challengeURL = challenge["url"].(string)
+		}
+	}
+	if challengeURL == "" {
+		t.Fatalf("no http-01 challenge: %#v", authz)
+	}
+
+	// Finalizing before validation fails
+	resp = client.post(strings.TrimPrefix(order["finalize"].(string), testACMEBaseURL+"/"), map[string]interface{}{
+		"csr": "",
+	})
+	testACMEStatus(t, resp, http.StatusForbidden)
+	testACMEProblem(t, resp, "orderNotReady")
+
+	jwk := jose.JSONWebKey{Key: key.Public()}
+	thumbprint, err := acmeKeyThumbprint(&jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator := &testACMEValidator{thumbprint: thumbprint}
+	b.acmeValidator = validator
+
+	resp = client.post(strings.TrimPrefix(challengeURL, testACMEBaseURL+"/"), map[string]interface{}{})
+	challenge := testACMEStatus(t, resp, http.StatusOK)
+	if challenge["status"] != acmeStatusValid {
+		t.Fatalf("bad challenge: %#v", challenge)
+	}
+	if len(validator.validated) != 1 || validator.validated[0] != "http-01:www.example.com" {
+		t.Fatalf("bad validations: %#v", validator.validated)
+	}
+
+	resp = client.post(strings.TrimPrefix(orderURL, testACMEBaseURL+"/"), nil)
+	order = testACMEStatus(t, resp, http.StatusOK)
+	if order["status"] != acmeStatusReady {
+		t.Fatalf("bad order: %#v", order)
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The CSR must match the order
+	badCSR, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "www.example.com"},
+		DNSNames: []string{"mail.example.com"},
+	}, certKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = client.post(strings.TrimPrefix(order["finalize"].(string), testACMEBaseURL+"/"), map[string]interface{}{
+		"csr": base64.RawURLEncoding.EncodeToString(badCSR),
+	})
+	testACMEStatus(t, resp, http.StatusBadRequest)
+	testACMEProblem(t, resp, "badCSR")
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		DNSNames: []string{"www.example.com"},
+	}, certKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = client.post(strings.TrimPrefix(order["finalize"].(string), testACMEBaseURL+"/"), map[string]interface{}{
+		"csr": base64.RawURLEncoding.EncodeToString(csr),
+	})
+	order = testACMEStatus(t, resp, http.StatusOK)
+	if order["status"] != acmeStatusValid || order["certificate"] == nil {
+		t.Fatalf("bad order: %#v", order)
+	}
+
+	resp = client.post(strings.TrimPrefix(order["certificate"].(string), testACMEBaseURL+"/"), nil)
+	testACMEStatus(t, resp, http.StatusOK)
+	if resp.Data[logical.HTTPContentType] != "application/pem-certificate-chain" {
+		t.Fatalf("bad content type: %#v", resp.Data)
+	}
+	block, rest := pem.Decode(resp.Data[logical.HTTPRawBody].([]byte))
+	if block == nil || len(rest) == 0 {
+		t.Fatalf("expected a certificate chain")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "www.example.com" {
+		t.Fatalf("bad certificate names: %#v", cert.DNSNames)
+	}
+
+	// The issued certificate is stored like any other
+	entry, err := storage.Get(context.Background(), "certs/"+order["certificate"].(string)[strings.LastIndex(order["certificate"].(string), "/")+1:])
+	if err != nil || entry == nil {
+		t.Fatalf("certificate not stored: %v", err)
+	}
+
+	resp = client.post("acme/test/revoke-cert", map[string]interface{}{
+		"certificate": base64.RawURLEncoding.EncodeToString(cert.Raw),
+	})
+	testACMEStatus(t, resp, http.StatusOK)
+
+	resp = client.post("acme/test/revoke-cert", map[string]interface{}{
+		"certificate": base64.RawURLEncoding.EncodeToString(cert.Raw),
+	})
+	testACMEStatus(t, resp, http.StatusBadRequest)
+	testACMEProblem(t, resp, "alreadyRevoked")
+}
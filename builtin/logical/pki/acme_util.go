@@ -0,0 +1,420 @@
+package pki
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	acmeErrorPrefix = "urn:ietf:params:acme:error:"
+
+	// acmeNonceLifetime is how long an issued nonce remains usable
+	acmeNonceLifetime = 15 * time.Minute
+
+	// acmeOrderLifetime is how long orders and authorizations remain
+	// pending before expiring
+	acmeOrderLifetime = 24 * time.Hour
+
+	// ACME object statuses
+	acmeStatusPending     = "pending"
+	acmeStatusReady       = "ready"
+	acmeStatusProcessing  = "processing"
+	acmeStatusValid       = "valid"
+	acmeStatusInvalid     = "invalid"
+	acmeStatusDeactivated = "deactivated"
+	acmeStatusExpired     = "expired"
+)
+
+// acmeAllowedAlgorithms are the JWS signature algorithms accepted from
+// clients; RFC 8555 forbids MAC-based algorithms and "none"
+var acmeAllowedAlgorithms = map[string]bool{
+	string(jose.RS256): true,
+	string(jose.RS384): true,
+	string(jose.RS512): true,
+	string(jose.PS256): true,
+	string(jose.PS384): true,
+	string(jose.PS512): true,
+	string(jose.ES256): true,
+	string(jose.ES384): true,
+	string(jose.ES512): true,
+	string(jose.EdDSA): true,
+}
+
+// acmeError is an ACME problem document (RFC 7807) returned to clients
+type acmeError struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+func (e *acmeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Detail)
+}
+
+func newACMEError(status int, errType, format string, args ...interface{}) *acmeError {
+	return &acmeError{
+		Type:   acmeErrorPrefix + errType,
+		Detail: fmt.Sprintf(format, args...),
+		Status: status,
+	}
+}
+
+func acmeMalformed(format string, args ...interface{}) *acmeError {
+	return newACMEError(http.StatusBadRequest, "malformed", format, args...)
+}
+
+func acmeUnauthorized(format string, args ...interface{}) *acmeError {
+	return newACMEError(http.StatusForbidden, "unauthorized", format, args...)
+}
+
+func acmeNotFound(format string, args ...interface{}) *acmeError {
+	return newACMEError(http.StatusNotFound, "malformed", format, args...)
+}
+
+// acmeNonces tracks the anti-replay nonces handed out to clients. Nonces are
+// kept in memory only, so clients retry with a fresh nonce after a restart or
+// a leadership change.
+type acmeNonces struct {
+	l      sync.Mutex
+	nonces map[string]time.Time
+}
+
+func newACMENonces() *acmeNonces {
+	return &acmeNonces{
+		nonces: make(map[string]time.Time),
+	}
+}
+
+// get returns a new nonce
+func (n *acmeNonces) get() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(buf)
+
+	n.l.Lock()
+	defer n.l.Unlock()
+
+	now := time.Now()
+	for k, expires := range n.nonces {
+		if now.After(expires) {
+			delete(n.nonces, k)
+		}
+	}
+	n.nonces[nonce] = now.Add(acmeNonceLifetime)
+
+	return nonce, nil
+}
+
+// redeem consumes a nonce, returning false if it was unknown or expired
+func (n *acmeNonces) redeem(nonce string) bool {
+	n.l.Lock()
+	defer n.l.Unlock()
+
+	expires, ok := n.nonces[nonce]
+	if !ok {
+		return false
+	}
+	delete(n.nonces, nonce)
+
+	return time.Now().Before(expires)
+}
+
+// acmeContext holds the state shared by every request to a role's ACME
+// endpoints
+type acmeContext struct {
+	roleName string
+	role     *roleEntry
+
+	// baseURL is the URL of the role's ACME directory tree, without a
+	// trailing slash
+	baseURL string
+}
+
+func (c *acmeContext) url(parts ...string) string {
+	return c.baseURL + "/" + strings.Join(parts, "/")
+}
+
+// acmeJWS is a verified request from an ACME client
+type acmeJWS struct {
+	// account is set when the request was signed by an existing account
+	account *acmeAccount
+
+	// jwk is set when the request carried the public key itself
+	jwk *jose.JSONWebKey
+
+	payload []byte
+}
+
+// isPostAsGet reports whether the request was a POST-as-GET, i.e. signed
+// with an empty payload
+func (j *acmeJWS) isPostAsGet() bool {
+	return len(j.payload) == 0
+}
+
+func (j *acmeJWS) decodePayload(out interface{}) error {
+	if j.isPostAsGet() {
+		return acmeMalformed("request payload is empty")
+	}
+	if err := jsonutil.DecodeJSON(j.payload, out); err != nil {
+		return acmeMalformed("failed to decode payload: %v", err)
+	}
+	return nil
+}
+
+// acmeJWSFields are the fields of a flattened JWS request body
+func acmeJWSFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["protected"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "The base64url-encoded JWS protected header.",
+	}
+	fields["payload"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "The base64url-encoded JWS payload.",
+	}
+	fields["signature"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "The base64url-encoded JWS signature.",
+	}
+	return fields
+}
+
+// acmeVerifyJWS parses and verifies the JWS carried by the request. The
+// request must be signed either by the key of an existing account, or, when
+// allowJWK is set, by a key included in the request.
+func (b *backend) acmeVerifyJWS(ctx context.Context, req *logical.Request, ac *acmeContext, data *framework.FieldData, allowJWK bool) (*acmeJWS, error) {
+	raw, err := json.Marshal(map[string]string{
+		"protected": data.Get("protected").(string),
+		"payload":   data.Get("payload").(string),
+		"signature": data.Get("signature").(string),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := jose.ParseSigned(string(raw))
+	if err != nil {
+		return nil, acmeMalformed("failed to parse JWS: %v", err)
+	}
+	if len(sig.Signatures) != 1 {
+		return nil, acmeMalformed("JWS must carry exactly one signature")
+	}
+
+	// Only the protected header is accepted in the request, so the merged
+	// header is the protected one
+	header := sig.Signatures[0].Header
+	if !acmeAllowedAlgorithms[header.Algorithm] {
+		return nil, newACMEError(http.StatusBadRequest, "badSignatureAlgorithm", "unsupported signature algorithm %q", header.Algorithm)
+	}
+
+	// The URL the client signed must be the one it sent the request to
+	reqURL, _ := header.ExtraHeaders[jose.HeaderKey("url")].(string)
+	if reqURL != ac.url(acmePathSuffix(req.Path)) {
+		return nil, acmeUnauthorized("JWS url header %q does not match the request URL", reqURL)
+	}
+
+	if header.Nonce == "" || !b.acmeNonces.redeem(header.Nonce) {
+		return nil, newACMEError(http.StatusBadRequest, "badNonce", "invalid or expired nonce")
+	}
+
+	ret := &acmeJWS{}
+	var verificationKey *jose.JSONWebKey
+	switch {
+	case header.JSONWebKey != nil && header.KeyID != "":
+		return nil, acmeMalformed("JWS must not carry both jwk and kid")
+
+	case header.JSONWebKey != nil:
+		if !allowJWK {
+			return nil, acmeMalformed("this endpoint requires a kid")
+		}
+		if !header.JSONWebKey.Valid() || !header.JSONWebKey.IsPublic() {
+			return nil, acmeMalformed("invalid jwk")
+		}
+		ret.jwk = header.JSONWebKey
+		verificationKey = header.JSONWebKey
+
+	case header.KeyID != "":
+		accountPrefix := ac.url("account") + "/"
+		if !strings.HasPrefix(header.KeyID, accountPrefix) {
+			return nil, newACMEError(http.StatusBadRequest, "accountDoesNotExist", "unknown account %q", header.KeyID)
+		}
+		account, err := b.acmeGetAccount(ctx, req.Storage, strings.TrimPrefix(header.KeyID, accountPrefix))
+		if err != nil {
+			return nil, err
+		}
+		if account == nil || account.Role != ac.roleName {
+			return nil, newACMEError(http.StatusBadRequest, "accountDoesNotExist", "unknown account %q", header.KeyID)
+		}
+		if account.Status != acmeStatusValid {
+			return nil, acmeUnauthorized("account is %s", account.Status)
+		}
+		key, err := account.publicKey()
+		if err != nil {
+			return nil, err
+		}
+		ret.account = account
+		verificationKey = key
+
+	default:
+		return nil, acmeMalformed("JWS must carry either jwk or kid")
+	}
+
+	ret.payload, err = sig.Verify(verificationKey)
+	if err != nil {
+		return nil, acmeMalformed("JWS signature verification failed")
+	}
+
+	return ret, nil
+}
+
+// acmePathSuffix returns the part of a request path below acme/<role>/
+func acmePathSuffix(path string) string {
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+// acmeKeyThumbprint returns the base64url-encoded RFC 7638 thumbprint of a
+// key
+func acmeKeyThumbprint(key *jose.JSONWebKey) (string, error) {
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+// acmeResponse builds a raw HTTP response carrying a fresh nonce and a link
+// to the directory
+func (b *backend) acmeResponse(ac *acmeContext, status int, body interface{}, headers map[string][]string) (*logical.Response, error) {
+	nonce, err := b.acmeNonces.get()
+	if err != nil {
+		return nil, err
+	}
+
+	if headers == nil {
+		headers = make(map[string][]string)
+	}
+	headers["Replay-Nonce"] = []string{nonce}
+	headers["Cache-Control"] = []string{"no-store"}
+	if ac != nil {
+		headers["Link"] = append(headers["Link"], fmt.Sprintf(`<%s>;rel="index"`, ac.url("directory")))
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode: status,
+		},
+		Headers: headers,
+	}
+
+	switch body := body.(type) {
+	case nil:
+	case []byte:
+		resp.Data[logical.HTTPRawBody] = body
+	default:
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		resp.Data[logical.HTTPContentType] = "application/json"
+		resp.Data[logical.HTTPRawBody] = encoded
+	}
+
+	return resp, nil
+}
+
+// acmeErrorResponse converts an error into an ACME problem document response
+func (b *backend) acmeErrorResponse(ac *acmeContext, err error) (*logical.Response, error) {
+	problem, ok := err.(*acmeError)
+	if !ok {
+		b.Logger().Error("error processing ACME request", "error", err)
+		problem = newACMEError(http.StatusInternalServerError, "serverInternal", "internal error processing the request")
+	}
+
+	body, jsonErr := json.Marshal(problem)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	resp, respErr := b.acmeResponse(ac, problem.Status, body, nil)
+	if respErr != nil {
+		return nil, respErr
+	}
+	resp.Data[logical.HTTPContentType] = "application/problem+json"
+	return resp, nil
+}
+
+// acmeOperation is a handler for an ACME endpoint that has been checked to
+// be enabled for a role
+type acmeOperation func(context.Context, *logical.Request, *acmeContext, *framework.FieldData) (*logical.Response, error)
+
+// acmeWrapper loads the ACME configuration and role for the request and
+// converts errors returned by op into ACME problem documents
+func (b *backend) acmeWrapper(op acmeOperation) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		config, err := b.acmeConfig(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+		if !config.Enabled {
+			return b.acmeErrorResponse(nil, acmeNotFound("ACME is not enabled on this mount"))
+		}
+
+		roleName := data.Get("role").(string)
+		role, err := b.getRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return b.acmeErrorResponse(nil, acmeNotFound("unknown role %q", roleName))
+		}
+
+		ac := &acmeContext{
+			roleName: roleName,
+			role:     role,
+			baseURL:  config.BaseURL + "/acme/" + roleName,
+		}
+
+		resp, err := op(ctx, req, ac, data)
+		if err != nil {
+			return b.acmeErrorResponse(ac, err)
+		}
+		return resp, nil
+	}
+}
+
+// acmeJWSOperation is a handler for an ACME endpoint taking a verified JWS
+type acmeJWSOperation func(context.Context, *logical.Request, *acmeContext, *acmeJWS, *framework.FieldData) (*logical.Response, error)
+
+// acmeJWSWrapper verifies the JWS carried by the request before calling op.
+// When allowJWK is false the request must be signed by an existing account.
+func (b *backend) acmeJWSWrapper(allowJWK bool, op acmeJWSOperation) framework.OperationFunc {
+	return b.acmeWrapper(func(ctx context.Context, req *logical.Request, ac *acmeContext, data *framework.FieldData) (*logical.Response, error) {
+		jws, err := b.acmeVerifyJWS(ctx, req, ac, data, allowJWK)
+		if err != nil {
+			return nil, err
+		}
+
+		b.acmeLock.Lock()
+		defer b.acmeLock.Unlock()
+
+		return op(ctx, req, ac, jws, data)
+	})
+}
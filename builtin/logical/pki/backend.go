@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -33,12 +34,23 @@ func Backend() *backend {
 				"ca",
 				"crl/pem",
 				"crl",
+				"crl/delta",
+				"crl/delta/pem",
+				"acme/*",
+				"est/*",
+				"ocsp",
+				"ocsp/*",
 			},
 
 			LocalStorage: []string{
 				"revoked/",
 				"crl",
+				"crls/",
+				"crl-state",
+				"crl-deltas/",
+				"crl-delta-wal/",
 				"certs/",
+				"acme/",
 			},
 
 			Root: []string{
@@ -48,6 +60,7 @@ func Backend() *backend {
 
 			SealWrapStorage: []string{
 				"config/ca_bundle",
+				"config/key/",
 			},
 		},
 
@@ -61,30 +74,63 @@ func Backend() *backend {
 			pathGenerateIntermediate(&b),
 			pathSetSignedIntermediate(&b),
 			pathConfigCA(&b),
+			pathConfigIssuers(&b),
+			pathListIssuers(&b),
+			pathIssuer(&b),
+			pathListKeys(&b),
+			pathKey(&b),
 			pathConfigCRL(&b),
 			pathConfigURLs(&b),
 			pathSignVerbatim(&b),
 			pathSign(&b),
 			pathIssue(&b),
 			pathRotateCRL(&b),
+			pathRotateDeltaCRL(&b),
 			pathFetchCA(&b),
 			pathFetchCAChain(&b),
 			pathFetchCRL(&b),
 			pathFetchCRLViaCertPath(&b),
 			pathFetchValid(&b),
 			pathFetchListCerts(&b),
+			pathFetchIssuer(&b),
 			pathRevoke(&b),
+			pathOCSP(&b),
+			pathOCSPGet(&b),
 			pathTidy(&b),
+			pathConfigAutoTidy(&b),
+			pathTidyStatus(&b),
+			pathConfigACME(&b),
+			pathConfigEST(&b),
+			pathESTCACerts(&b),
+			pathESTSimpleEnroll(&b),
+			pathESTSimpleReenroll(&b),
+			pathACMEDirectory(&b),
+			pathACMENewNonce(&b),
+			pathACMENewAccount(&b),
+			pathACMEAccount(&b),
+			pathACMEAccountOrders(&b),
+			pathACMENewOrder(&b),
+			pathACMEOrder(&b),
+			pathACMEOrderFinalize(&b),
+			pathACMEAuthorization(&b),
+			pathACMEChallenge(&b),
+			pathACMECertificate(&b),
+			pathACMERevokeCert(&b),
 		},
 
 		Secrets: []*framework.Secret{
 			secretCerts(&b),
 		},
 
+		PeriodicFunc: b.periodicFunc,
+
 		BackendType: logical.TypeLogical,
 	}
 
 	b.crlLifetime = time.Hour * 72
+	b.lastAutoTidy = time.Now()
+	b.acmeNonces = newACMENonces()
+	b.acmeValidator = acmeDefaultValidator{}
 
 	return &b
 }
@@ -94,11 +140,96 @@ type backend struct {
 
 	crlLifetime       time.Duration
 	revokeStorageLock sync.RWMutex
+
+	// crlBuildLock serializes CRL builds, which share the CRL numbering
+	crlBuildLock sync.Mutex
+
+	// issuersLock serializes changes to the issuers and keys
+	issuersLock sync.Mutex
+
+	// tidyRunning is set while a tidy operation is in progress; the status
+	// of the last one and the time the last automatic one started are
+	// guarded by tidyStatusLock
+	tidyRunning    uint32
+	tidyStatusLock sync.RWMutex
+	tidyStatus     *tidyStatus
+	lastAutoTidy   time.Time
+
+	acmeNonces    *acmeNonces
+	acmeValidator ACMEChallengeValidator
+
+	// acmeLock serializes ACME state changes
+	acmeLock sync.Mutex
+}
+
+// periodicFunc of the backend will be invoked once a minute by the
+// RollbackManager. It rebuilds the CRLs and starts automatic tidying when
+// these are enabled.
+func (b *backend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	var result error
+	if err := b.periodicRebuildCRL(ctx, req); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if err := b.autoTidy(ctx, req.Storage); err != nil {
+		result = multierror.Append(result, err)
+	}
+	return result
+}
+
+// periodicRebuildCRL rebuilds the complete CRLs before they expire and the
+// delta CRLs at their interval when automatic CRL rebuilding is enabled.
+func (b *backend) periodicRebuildCRL(ctx context.Context, req *logical.Request) error {
+	crlInfo, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	if crlInfo == nil || !crlInfo.AutoRebuild {
+		return nil
+	}
+
+	// Nothing to build until a CA is configured
+	issuersConfig, err := fetchIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	if issuersConfig.DefaultIssuerID == "" {
+		return nil
+	}
+
+	gracePeriod, err := time.ParseDuration(crlInfo.AutoRebuildGracePeriod)
+	if err != nil {
+		return err
+	}
+	state, err := fetchCRLState(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+
+	now := time.Now()
+	if now.After(state.NextUpdate.Add(-gracePeriod)) {
+		return buildCRL(ctx, b, req)
+	}
+
+	if crlInfo.EnableDelta {
+		interval, err := time.ParseDuration(crlInfo.DeltaRebuildInterval)
+		if err != nil {
+			return err
+		}
+		if now.Sub(state.LastDeltaBuild) >= interval {
+			return buildDeltaCRL(ctx, b, req)
+		}
+	}
+
+	return nil
 }
 
 const backendHelp = `
 The PKI backend dynamically generates X509 server and client certificates.
 
 After mounting this backend, configure the CA using the "pem_bundle" endpoint within
-the "config/" path.
+the "config/" path. Several issuers can be configured; the default one is set
+with the "config/issuers" endpoint.
 `
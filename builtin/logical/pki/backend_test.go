@@ -2486,15 +2486,36 @@ func TestBackend_Root_Idempotency(t *testing.T) {
 
 	r1Data := resp.Data
 
-	// Try again, make sure it's a 204 and same CA
+	resp, err = client.Logical().Read("pki/cert/ca")
+	if err != nil {
+		t.Fatalf("error reading ca: %v", err)
+	}
+	ca1 := resp.Data["certificate"]
+
+	// Try again, make sure a new issuer is created without changing the
+	// default CA
 	resp, err = client.Logical().Write("pki/root/generate/internal", map[string]interface{}{
 		"common_name": "myvault.com",
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if resp != nil {
-		t.Fatal("expected no ca info")
+	if resp == nil || resp.Data["issuer_id"] == nil {
+		t.Fatal("expected ca info")
+	}
+	resp, err = client.Logical().Read("pki/cert/ca")
+	if err != nil {
+		t.Fatalf("error reading ca: %v", err)
+	}
+	if resp.Data["certificate"] != ca1 {
+		t.Fatal("got different default ca certs")
+	}
+	resp, err = client.Logical().List("pki/issuers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data["keys"].([]interface{})) != 2 {
+		t.Fatalf("expected two issuers, got %#v", resp.Data)
 	}
 	resp, err = client.Logical().Read("pki/cert/ca_chain")
 	if err != nil {
@@ -2940,7 +2961,7 @@ func TestBackend_SignSelfIssued(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	signingBundle, err := fetchCAInfo(context.Background(), &logical.Request{Storage: storage})
+	signingBundle, err := fetchCAInfo(context.Background(), b, &logical.Request{Storage: storage}, defaultRef)
 	if err != nil {
 		t.Fatal(err)
 	}
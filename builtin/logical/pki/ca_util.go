@@ -1,8 +1,14 @@
 package pki
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
 	"time"
 
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -14,10 +20,10 @@ func (b *backend) getGenerationParams(
 	switch exportedStr {
 	case "exported":
 		exported = true
-	case "internal":
+	case "internal", "existing":
 	default:
 		errorResp = logical.ErrorResponse(
-			`the "exported" path parameter must be "internal" or "exported"`)
+			`the "exported" path parameter must be "internal", "exported" or "existing"`)
 		return
 	}
 
@@ -45,6 +51,11 @@ func (b *backend) getGenerationParams(
 		PostalCode:       data.Get("postal_code").([]string),
 	}
 
+	// The key type and size of an existing key are those of the key
+	if exportedStr == "existing" {
+		return
+	}
+
 	if role.KeyType == "rsa" && role.KeyBits < 2048 {
 		errorResp = logical.ErrorResponse("RSA keys < 2048 bits are unsafe and not supported")
 		return
@@ -54,3 +65,45 @@ func (b *backend) getGenerationParams(
 
 	return
 }
+
+// getExistingKey loads the key given by "key_ref" when generating with an
+// existing key, setting the role's key type and size to match it. Nothing is
+// returned when a new key is to be generated.
+func (b *backend) getExistingKey(ctx context.Context, req *logical.Request, data *framework.FieldData, role *roleEntry) (*keyEntry, *certutil.ParsedCertBundle, error) {
+	if data.Get("exported").(string) != "existing" {
+		return nil, nil, nil
+	}
+
+	keyRef := data.Get("key_ref").(string)
+	if keyRef == "" {
+		return nil, nil, errutil.UserError{Err: `"key_ref" is required when using an existing key`}
+	}
+	id, err := b.resolveKeyRef(ctx, req.Storage, keyRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := fetchKey(ctx, req.Storage, id)
+	if err != nil {
+		return nil, nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch key %q: %v", keyRef, err)}
+	}
+	if key == nil {
+		return nil, nil, errutil.UserError{Err: fmt.Sprintf("key %q not found", keyRef)}
+	}
+	parsed, err := key.parse()
+	if err != nil {
+		return nil, nil, errutil.InternalError{Err: fmt.Sprintf("unable to parse key %q: %v", keyRef, err)}
+	}
+
+	switch privateKey := parsed.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		role.KeyType = "rsa"
+		role.KeyBits = privateKey.N.BitLen()
+	case *ecdsa.PrivateKey:
+		role.KeyType = "ec"
+		role.KeyBits = privateKey.Curve.Params().BitSize
+	default:
+		return nil, nil, errutil.InternalError{Err: fmt.Sprintf("unsupported type of key %q", keyRef)}
+	}
+
+	return key, parsed, nil
+}
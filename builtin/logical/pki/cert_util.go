@@ -45,6 +45,7 @@ const (
 type dataBundle struct {
 	params        *creationParameters
 	signingBundle *caInfoBundle
+	existingKey   *certutil.ParsedCertBundle
 	csr           *x509.CertificateRequest
 	role          *roleEntry
 	req           *logical.Request
@@ -79,7 +80,8 @@ type creationParameters struct {
 
 type caInfoBundle struct {
 	certutil.ParsedCertBundle
-	URLs *urlEntries
+	IssuerID string
+	URLs     *urlEntries
 }
 
 func (b *caInfoBundle) GetCAChain() []*certutil.CertBlock {
@@ -164,30 +166,32 @@ func validateKeyTypeLength(keyType string, keyBits int) *logical.Response {
 
 // Fetches the CA info. Unlike other certificates, the CA info is stored
 // in the backend as a CertBundle, because we are storing its private key
-func fetchCAInfo(ctx context.Context, req *logical.Request) (*caInfoBundle, error) {
-	bundleEntry, err := req.Storage.Get(ctx, "config/ca_bundle")
+// fetchCAInfo returns the signing bundle of the referenced issuer, or of the
+// default issuer if the reference is empty
+func fetchCAInfo(ctx context.Context, b *backend, req *logical.Request, issuerRef string) (*caInfoBundle, error) {
+	id, err := b.resolveIssuerRef(ctx, req.Storage, issuerRef)
 	if err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch local CA certificate/key: %v", err)}
-	}
-	if bundleEntry == nil {
-		return nil, errutil.UserError{Err: "backend must be configured with a CA certificate/key"}
+		return nil, err
 	}
 
-	var bundle certutil.CertBundle
-	if err := bundleEntry.DecodeJSON(&bundle); err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode local CA certificate/key: %v", err)}
+	issuer, err := fetchIssuer(ctx, req.Storage, id)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch local CA certificate/key: %v", err)}
+	}
+	if issuer == nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("issuer %q not found", issuerRef)}
 	}
 
-	parsedBundle, err := bundle.ToParsedCertBundle()
+	parsedBundle, err := fetchIssuerSigner(ctx, req.Storage, issuer)
 	if err != nil {
-		return nil, errutil.InternalError{Err: err.Error()}
+		return nil, err
 	}
-
-	if parsedBundle.Certificate == nil {
-		return nil, errutil.InternalError{Err: "stored CA information not able to be parsed"}
+	parsedBundle.CAChain, err = fetchIssuerChain(ctx, req.Storage, parsedBundle.Certificate)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to build CA chain: %v", err)}
 	}
 
-	caInfo := &caInfoBundle{*parsedBundle, nil}
+	caInfo := &caInfoBundle{*parsedBundle, id, nil}
 
 	entries, err := getURLs(ctx, req)
 	if err != nil {
@@ -225,6 +229,8 @@ func fetchCertBySerial(ctx context.Context, req *logical.Request, prefix, serial
 		path = "ca"
 	case serial == "crl":
 		path = "crl"
+	case serial == "delta-crl":
+		path = deltaCRLPath
 	default:
 		legacyPath = "certs/" + colonSerial
 		path = "certs/" + hyphenSerial
@@ -1012,7 +1018,10 @@ func createCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 		return nil, err
 	}
 
-	if err := certutil.GeneratePrivateKey(data.params.KeyType,
+	if data.existingKey != nil {
+		result.SetParsedPrivateKey(data.existingKey.PrivateKey, data.existingKey.PrivateKeyType, data.existingKey.PrivateKeyBytes)
+		result.PrivateKeyFormat = data.existingKey.PrivateKeyFormat
+	} else if err := certutil.GeneratePrivateKey(data.params.KeyType,
 		data.params.KeyBits,
 		result); err != nil {
 		return nil, err
@@ -1133,7 +1142,9 @@ func createCSR(data *dataBundle) (*certutil.ParsedCSRBundle, error) {
 	var err error
 	result := &certutil.ParsedCSRBundle{}
 
-	if err := certutil.GeneratePrivateKey(data.params.KeyType,
+	if data.existingKey != nil {
+		result.SetParsedPrivateKey(data.existingKey.PrivateKey, data.existingKey.PrivateKeyType, data.existingKey.PrivateKeyBytes)
+	} else if err := certutil.GeneratePrivateKey(data.params.KeyType,
 		data.params.KeyBits,
 		result); err != nil {
 		return nil, err
@@ -2,16 +2,53 @@ package pki
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
 	"fmt"
 	"time"
 
+	"github.com/hashicorp/vault/helper/certutil"
 	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
 )
 
+const (
+	crlStatePath   = "crl-state"
+	deltaCRLPath   = "crl/delta"
+	deltaCRLPrefix = "crl-deltas/"
+	deltaWALPrefix = "crl-delta-wal/"
+)
+
+var (
+	crlNumberOID         = asn1.ObjectIdentifier{2, 5, 29, 20}
+	deltaCRLIndicatorOID = asn1.ObjectIdentifier{2, 5, 29, 27}
+	authorityKeyIDOID    = asn1.ObjectIdentifier{2, 5, 29, 35}
+
+	sha256WithRSAOID   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	ecdsaWithSHA256OID = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// crlState tracks the numbering of the CRLs, shared by all the issuers so
+// that the numbers of each issuer's CRLs are increasing
+type crlState struct {
+	// NextNumber is the number of the next CRL built, complete or delta
+	NextNumber int64 `json:"next_number"`
+
+	// BaseNumbers holds the number of the last complete CRL of each issuer
+	BaseNumbers map[string]int64 `json:"base_numbers"`
+
+	// NextUpdate is the expiration of the last complete CRLs
+	NextUpdate time.Time `json:"next_update"`
+
+	// LastDeltaBuild is when the delta CRLs were last built
+	LastDeltaBuild time.Time `json:"last_delta_build"`
+}
+
 type revocationInfo struct {
 	CertificateBytes  []byte    `json:"certificate_bytes"`
 	RevocationTime    int64     `json:"revocation_time"`
@@ -99,12 +136,30 @@ func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial st
 
 	}
 
-	crlErr := buildCRL(ctx, b, req)
-	switch crlErr.(type) {
-	case errutil.UserError:
-		return logical.ErrorResponse(fmt.Sprintf("Error during CRL building: %s", crlErr)), nil
-	case errutil.InternalError:
-		return nil, fmt.Errorf("error encountered during CRL building: %s", crlErr)
+	crlInfo, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching CRL config information: %s", err)
+	}
+	if crlInfo != nil && crlInfo.AutoRebuild {
+		// The CRLs are rebuilt by the periodic function; new revocations are
+		// recorded for the next delta CRLs
+		if crlInfo.EnableDelta && !alreadyRevoked {
+			err = req.Storage.Put(ctx, &logical.StorageEntry{
+				Key:   deltaWALPrefix + normalizeSerial(serial),
+				Value: revEntry.Value,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error saving revoked certificate for the delta CRL")
+			}
+		}
+	} else {
+		crlErr := buildCRL(ctx, b, req)
+		switch crlErr.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(fmt.Sprintf("Error during CRL building: %s", crlErr)), nil
+		case errutil.InternalError:
+			return nil, fmt.Errorf("error encountered during CRL building: %s", crlErr)
+		}
 	}
 
 	resp := &logical.Response{
@@ -118,39 +173,198 @@ func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial st
 	return resp, nil
 }
 
-// Builds a CRL by going through the list of revoked certificates and building
-// a new CRL with the stored revocation times and serial numbers.
+// Builds a complete CRL for each issuer with a key by going through the list
+// of revoked certificates and building new CRLs with the stored revocation
+// times and serial numbers. Certificates whose issuer is unknown, for instance
+// because it was deleted, are placed on the default issuer's CRL. The delta
+// CRLs are rebuilt on top of the new CRLs.
 func buildCRL(ctx context.Context, b *backend, req *logical.Request) error {
-	revokedSerials, err := req.Storage.List(ctx, "revoked/")
+	if err := b.migrateLegacyCABundle(ctx, req.Storage); err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error migrating CA bundle: %s", err)}
+	}
+
+	b.crlBuildLock.Lock()
+	defer b.crlBuildLock.Unlock()
+
+	revokedCerts, revokedEntries, err := fetchRevocations(ctx, req.Storage, "revoked/")
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("error fetching list of revoked certs: %s", err)}
+		return err
 	}
+	issuers, err := fetchCRLIssuers(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	issuerRevoked := issuers.assign(revokedCerts, revokedEntries)
 
-	revokedCerts := []pkix.RevokedCertificate{}
-	var revInfo revocationInfo
+	crlInfo, crlLifetime, err := b.fetchCRLLifetime(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	state, err := fetchCRLState(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	state.BaseNumbers = make(map[string]int64, len(issuers.ids))
+	for _, id := range issuers.ids {
+		issuer, ok := issuers.entries[id]
+		if !ok || issuer.KeyID == "" {
+			continue
+		}
+
+		number := state.NextNumber
+		state.NextNumber++
+		crlBytes, err := buildIssuerCRL(ctx, req.Storage, issuer, issuerRevoked[id], now, now.Add(crlLifetime), number, -1)
+		if err != nil {
+			return err
+		}
+		state.BaseNumbers[id] = number
+
+		err = req.Storage.Put(ctx, &logical.StorageEntry{
+			Key:   issuerCRLPrefix + id,
+			Value: crlBytes,
+		})
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error storing CRL: %s", err)}
+		}
+
+		// The default issuer's CRL is also served by the "crl" endpoints
+		if id == issuers.defaultID {
+			err = req.Storage.Put(ctx, &logical.StorageEntry{
+				Key:   "crl",
+				Value: crlBytes,
+			})
+			if err != nil {
+				return errutil.InternalError{Err: fmt.Sprintf("error storing CRL: %s", err)}
+			}
+		}
+	}
+	state.NextUpdate = now.Add(crlLifetime)
+
+	if err := writeCRLState(ctx, req.Storage, state); err != nil {
+		return err
+	}
+
+	// Revocations cannot happen while the CRLs are built, so all the recorded
+	// ones are on the new CRLs
+	walSerials, err := req.Storage.List(ctx, deltaWALPrefix)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching list of delta CRL entries: %s", err)}
+	}
+	for _, serial := range walSerials {
+		if err := req.Storage.Delete(ctx, deltaWALPrefix+serial); err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error removing delta CRL entry for serial %s: %s", serial, err)}
+		}
+	}
+
+	if crlInfo != nil && crlInfo.EnableDelta {
+		return b.buildDeltaCRLLocked(ctx, req)
+	}
+	return nil
+}
+
+// Builds a delta CRL for each issuer with a complete CRL, listing the
+// certificates revoked since the complete CRL was built
+func buildDeltaCRL(ctx context.Context, b *backend, req *logical.Request) error {
+	b.crlBuildLock.Lock()
+	defer b.crlBuildLock.Unlock()
+
+	return b.buildDeltaCRLLocked(ctx, req)
+}
+
+func (b *backend) buildDeltaCRLLocked(ctx context.Context, req *logical.Request) error {
+	revokedCerts, revokedEntries, err := fetchRevocations(ctx, req.Storage, deltaWALPrefix)
+	if err != nil {
+		return err
+	}
+	issuers, err := fetchCRLIssuers(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	issuerRevoked := issuers.assign(revokedCerts, revokedEntries)
+
+	_, crlLifetime, err := b.fetchCRLLifetime(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	state, err := fetchCRLState(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, id := range issuers.ids {
+		issuer, ok := issuers.entries[id]
+		baseNumber, hasBase := state.BaseNumbers[id]
+		if !ok || issuer.KeyID == "" || !hasBase {
+			continue
+		}
+
+		number := state.NextNumber
+		state.NextNumber++
+		crlBytes, err := buildIssuerCRL(ctx, req.Storage, issuer, issuerRevoked[id], now, now.Add(crlLifetime), number, baseNumber)
+		if err != nil {
+			return err
+		}
+
+		err = req.Storage.Put(ctx, &logical.StorageEntry{
+			Key:   deltaCRLPrefix + id,
+			Value: crlBytes,
+		})
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error storing delta CRL: %s", err)}
+		}
+
+		if id == issuers.defaultID {
+			err = req.Storage.Put(ctx, &logical.StorageEntry{
+				Key:   deltaCRLPath,
+				Value: crlBytes,
+			})
+			if err != nil {
+				return errutil.InternalError{Err: fmt.Sprintf("error storing delta CRL: %s", err)}
+			}
+		}
+	}
+	state.LastDeltaBuild = now
+
+	return writeCRLState(ctx, req.Storage, state)
+}
+
+// fetchRevocations returns the revoked certificates stored under the prefix
+// along with their CRL entries
+func fetchRevocations(ctx context.Context, s logical.Storage, prefix string) ([]*x509.Certificate, []pkix.RevokedCertificate, error) {
+	revokedSerials, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, nil, errutil.InternalError{Err: fmt.Sprintf("error fetching list of revoked certs: %s", err)}
+	}
+
+	var revokedCerts []*x509.Certificate
+	var revokedEntries []pkix.RevokedCertificate
 	for _, serial := range revokedSerials {
-		revokedEntry, err := req.Storage.Get(ctx, "revoked/"+serial)
+		var revInfo revocationInfo
+		revokedEntry, err := s.Get(ctx, prefix+serial)
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("unable to fetch revoked cert with serial %s: %s", serial, err)}
+			return nil, nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch revoked cert with serial %s: %s", serial, err)}
 		}
 		if revokedEntry == nil {
-			return errutil.InternalError{Err: fmt.Sprintf("revoked certificate entry for serial %s is nil", serial)}
+			return nil, nil, errutil.InternalError{Err: fmt.Sprintf("revoked certificate entry for serial %s is nil", serial)}
 		}
 		if revokedEntry.Value == nil || len(revokedEntry.Value) == 0 {
 			// TODO: In this case, remove it and continue? How likely is this to
 			// happen? Alternately, could skip it entirely, or could implement a
 			// delete function so that there is a way to remove these
-			return errutil.InternalError{Err: fmt.Sprintf("found revoked serial but actual certificate is empty")}
+			return nil, nil, errutil.InternalError{Err: fmt.Sprintf("found revoked serial but actual certificate is empty")}
 		}
 
 		err = revokedEntry.DecodeJSON(&revInfo)
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("error decoding revocation entry for serial %s: %s", serial, err)}
+			return nil, nil, errutil.InternalError{Err: fmt.Sprintf("error decoding revocation entry for serial %s: %s", serial, err)}
 		}
 
 		revokedCert, err := x509.ParseCertificate(revInfo.CertificateBytes)
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("unable to parse stored revoked certificate with serial %s: %s", serial, err)}
+			return nil, nil, errutil.InternalError{Err: fmt.Sprintf("unable to parse stored revoked certificate with serial %s: %s", serial, err)}
 		}
 
 		// NOTE: We have to change this to UTC time because the CRL standard
@@ -163,42 +377,233 @@ func buildCRL(ctx context.Context, b *backend, req *logical.Request) error {
 		} else {
 			newRevCert.RevocationTime = time.Unix(revInfo.RevocationTime, 0).UTC()
 		}
-		revokedCerts = append(revokedCerts, newRevCert)
+		revokedCerts = append(revokedCerts, revokedCert)
+		revokedEntries = append(revokedEntries, newRevCert)
 	}
 
-	signingBundle, caErr := fetchCAInfo(ctx, req)
-	switch caErr.(type) {
-	case errutil.UserError:
-		return errutil.UserError{Err: fmt.Sprintf("could not fetch the CA certificate: %s", caErr)}
-	case errutil.InternalError:
-		return errutil.InternalError{Err: fmt.Sprintf("error fetching CA certificate: %s", caErr)}
+	return revokedCerts, revokedEntries, nil
+}
+
+// crlIssuers holds the issuers for which CRLs are built
+type crlIssuers struct {
+	defaultID string
+	ids       []string
+	entries   map[string]*issuerEntry
+	certs     map[string]*x509.Certificate
+}
+
+func fetchCRLIssuers(ctx context.Context, s logical.Storage) (*crlIssuers, error) {
+	config, err := fetchIssuersConfig(ctx, s)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching issuers configuration: %s", err)}
+	}
+	if config.DefaultIssuerID == "" {
+		return nil, errutil.UserError{Err: "could not fetch the CA certificate: backend must be configured with a CA certificate/key"}
 	}
 
-	crlLifetime := b.crlLifetime
-	crlInfo, err := b.CRL(ctx, req.Storage)
+	issuerIDs, err := listSorted(ctx, s, issuerPrefix)
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("error fetching CRL config information: %s", err)}
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching list of issuers: %s", err)}
 	}
-	if crlInfo != nil {
-		crlDur, err := time.ParseDuration(crlInfo.Expiry)
+	issuers := &crlIssuers{
+		defaultID: config.DefaultIssuerID,
+		ids:       issuerIDs,
+		entries:   make(map[string]*issuerEntry, len(issuerIDs)),
+		certs:     make(map[string]*x509.Certificate, len(issuerIDs)),
+	}
+	for _, id := range issuerIDs {
+		issuer, err := fetchIssuer(ctx, s, id)
+		if err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching issuer %s: %s", id, err)}
+		}
+		if issuer == nil {
+			continue
+		}
+		cert, _, err := issuer.parseCertificate()
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("error parsing CRL duration of %s", crlInfo.Expiry)}
+			return nil, errutil.InternalError{Err: err.Error()}
 		}
-		crlLifetime = crlDur
+		issuers.entries[id] = issuer
+		issuers.certs[id] = cert
 	}
 
-	crlBytes, err := signingBundle.Certificate.CreateCRL(rand.Reader, signingBundle.PrivateKey, revokedCerts, time.Now(), time.Now().Add(crlLifetime))
+	return issuers, nil
+}
+
+// assign places each revoked certificate on the CRLs of its issuers
+func (c *crlIssuers) assign(revokedCerts []*x509.Certificate, revokedEntries []pkix.RevokedCertificate) map[string][]pkix.RevokedCertificate {
+	issuerRevoked := make(map[string][]pkix.RevokedCertificate, len(c.entries))
+	for i, revokedCert := range revokedCerts {
+		found := false
+		for _, id := range c.ids {
+			if cert, ok := c.certs[id]; ok && issuedBy(revokedCert, cert) {
+				issuerRevoked[id] = append(issuerRevoked[id], revokedEntries[i])
+				found = true
+			}
+		}
+		if !found {
+			issuerRevoked[c.defaultID] = append(issuerRevoked[c.defaultID], revokedEntries[i])
+		}
+	}
+	return issuerRevoked
+}
+
+// fetchCRLLifetime returns the CRL configuration, if any, and the lifetime of the
+// CRLs
+func (b *backend) fetchCRLLifetime(ctx context.Context, s logical.Storage) (*crlConfig, time.Duration, error) {
+	crlInfo, err := b.CRL(ctx, s)
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("error creating new CRL: %s", err)}
+		return nil, 0, errutil.InternalError{Err: fmt.Sprintf("error fetching CRL config information: %s", err)}
 	}
+	if crlInfo == nil {
+		return nil, b.crlLifetime, nil
+	}
+	crlDur, err := time.ParseDuration(crlInfo.Expiry)
+	if err != nil {
+		return nil, 0, errutil.InternalError{Err: fmt.Sprintf("error parsing CRL duration of %s", crlInfo.Expiry)}
+	}
+	return crlInfo, crlDur, nil
+}
 
-	err = req.Storage.Put(ctx, &logical.StorageEntry{
-		Key:   "crl",
-		Value: crlBytes,
-	})
+func fetchCRLState(ctx context.Context, s logical.Storage) (*crlState, error) {
+	entry, err := s.Get(ctx, crlStatePath)
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("error storing CRL: %s", err)}
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching CRL state: %s", err)}
 	}
 
+	state := &crlState{
+		NextNumber: 1,
+	}
+	if entry != nil {
+		if err := entry.DecodeJSON(state); err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("error decoding CRL state: %s", err)}
+		}
+	}
+	return state, nil
+}
+
+func writeCRLState(ctx context.Context, s logical.Storage, state *crlState) error {
+	entry, err := logical.StorageEntryJSON(crlStatePath, state)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error encoding CRL state: %s", err)}
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error storing CRL state: %s", err)}
+	}
 	return nil
 }
+
+// buildIssuerCRL signs a CRL with the issuer. A non-negative base number
+// makes it a delta CRL on top of the complete CRL with that number.
+func buildIssuerCRL(ctx context.Context, s logical.Storage, issuer *issuerEntry, revoked []pkix.RevokedCertificate, thisUpdate, nextUpdate time.Time, number, baseNumber int64) ([]byte, error) {
+	signingBundle, err := fetchIssuerSigner(ctx, s, issuer)
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		return nil, errutil.UserError{Err: fmt.Sprintf("could not fetch the CA certificate: %s", err)}
+	default:
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching CA certificate: %s", err)}
+	}
+
+	numberBytes, err := asn1.Marshal(number)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error encoding CRL number: %s", err)}
+	}
+	extensions := []pkix.Extension{
+		{Id: crlNumberOID, Value: numberBytes},
+	}
+	if baseNumber >= 0 {
+		baseBytes, err := asn1.Marshal(baseNumber)
+		if err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("error encoding base CRL number: %s", err)}
+		}
+		extensions = append(extensions, pkix.Extension{
+			Id:       deltaCRLIndicatorOID,
+			Critical: true,
+			Value:    baseBytes,
+		})
+	}
+
+	crlBytes, err := createCRL(signingBundle, revoked, thisUpdate, nextUpdate, extensions)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error creating new CRL: %s", err)}
+	}
+	return crlBytes, nil
+}
+
+// createCRL works like x509.Certificate.CreateCRL, which cannot add the
+// extensions needed for CRL numbering
+func createCRL(signingBundle *certutil.ParsedCertBundle, revoked []pkix.RevokedCertificate, thisUpdate, nextUpdate time.Time, extensions []pkix.Extension) ([]byte, error) {
+	signer, ok := signingBundle.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("CA key does not support signing")
+	}
+	sigAlg, err := sha256SignatureAlgorithm(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	var issuer pkix.RDNSequence
+	if _, err := asn1.Unmarshal(signingBundle.Certificate.RawSubject, &issuer); err != nil {
+		return nil, err
+	}
+
+	if len(signingBundle.Certificate.SubjectKeyId) > 0 {
+		aki, err := asn1.Marshal(struct {
+			ID []byte `asn1:"optional,tag:0"`
+		}{ID: signingBundle.Certificate.SubjectKeyId})
+		if err != nil {
+			return nil, err
+		}
+		extensions = append([]pkix.Extension{{Id: authorityKeyIDOID, Value: aki}}, extensions...)
+	}
+
+	tbs := pkix.TBSCertificateList{
+		Version:             1,
+		Signature:           sigAlg,
+		Issuer:              issuer,
+		ThisUpdate:          thisUpdate.UTC(),
+		NextUpdate:          nextUpdate.UTC(),
+		RevokedCertificates: revoked,
+		Extensions:          extensions,
+	}
+	tbsBytes, err := asn1.Marshal(tbs)
+	if err != nil {
+		return nil, err
+	}
+	tbs.Raw = tbsBytes
+
+	digest := crypto.SHA256.New()
+	digest.Write(tbsBytes)
+	signature, err := signer.Sign(rand.Reader, digest.Sum(nil), crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pkix.CertificateList{
+		TBSCertList:        tbs,
+		SignatureAlgorithm: sigAlg,
+		SignatureValue: asn1.BitString{
+			Bytes:     signature,
+			BitLength: 8 * len(signature),
+		},
+	})
+}
+
+// sha256SignatureAlgorithm returns the algorithm of SHA-256 signatures made
+// with the signer
+func sha256SignatureAlgorithm(signer crypto.Signer) (pkix.AlgorithmIdentifier, error) {
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		return pkix.AlgorithmIdentifier{
+			Algorithm:  sha256WithRSAOID,
+			Parameters: asn1.RawValue{Tag: asn1.TagNull},
+		}, nil
+	case *ecdsa.PublicKey:
+		return pkix.AlgorithmIdentifier{
+			Algorithm: ecdsaWithSHA256OID,
+		}, nil
+	default:
+		return pkix.AlgorithmIdentifier{}, fmt.Errorf("unsupported CA key type %T", signer.Public())
+	}
+}
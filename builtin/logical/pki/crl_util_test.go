@@ -0,0 +1,164 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_DeltaCRL(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	var caCert *x509.Certificate
+	fetchCRL := func(path string) *pkix.CertificateList {
+		resp := mustRequest(logical.ReadOperation, path, nil)
+		crl, err := x509.ParseCRL(resp.Data[logical.HTTPRawBody].([]byte))
+		if err != nil {
+			t.Fatalf("error parsing %s: %v", path, err)
+		}
+		if err := caCert.CheckCRLSignature(crl); err != nil {
+			t.Fatalf("bad signature of %s: %v", path, err)
+		}
+		return crl
+	}
+	crlExtension := func(crl *pkix.CertificateList, oid asn1.ObjectIdentifier) (int64, bool) {
+		for _, ext := range crl.TBSCertList.Extensions {
+			if ext.Id.Equal(oid) {
+				var number int64
+				if _, err := asn1.Unmarshal(ext.Value, &number); err != nil {
+					t.Fatal(err)
+				}
+				return number, ext.Critical
+			}
+		}
+		t.Fatalf("extension %v not found", oid)
+		return 0, false
+	}
+
+	// Delta CRLs require automatic rebuilding
+	resp, err := request(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"enable_delta": true,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got: err: %v resp: %#v", err, resp)
+	}
+	mustRequest(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"auto_rebuild": true,
+		"enable_delta": true,
+	})
+	resp = mustRequest(logical.ReadOperation, "config/crl", nil)
+	if resp.Data["expiry"] != "72h" || resp.Data["auto_rebuild_grace_period"] != "12h" || resp.Data["delta_rebuild_interval"] != "15m" {
+		t.Fatalf("bad config: %#v", resp.Data)
+	}
+
+	resp = mustRequest(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "example.com",
+		"ttl":         "48h",
+	})
+	block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	caCert, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustRequest(logical.UpdateOperation, "roles/test", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+	})
+	resp = mustRequest(logical.UpdateOperation, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	serial := resp.Data["serial_number"].(string)
+
+	base := fetchCRL("crl")
+	baseNumber, _ := crlExtension(base, crlNumberOID)
+
+	// Revoking does not rebuild the complete CRL
+	mustRequest(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serial,
+	})
+	if crl := fetchCRL("crl"); len(crl.TBSCertList.RevokedCertificates) != 0 {
+		t.Fatal("expected the complete CRL not to be rebuilt")
+	}
+	if crl := fetchCRL("crl/delta"); len(crl.TBSCertList.RevokedCertificates) != 0 {
+		t.Fatal("expected the delta CRL not to be rebuilt")
+	}
+
+	mustRequest(logical.ReadOperation, "crl/rotate-delta", nil)
+	delta := fetchCRL("crl/delta")
+	if len(delta.TBSCertList.RevokedCertificates) != 1 {
+		t.Fatalf("expected one revoked certificate on the delta CRL, got %d", len(delta.TBSCertList.RevokedCertificates))
+	}
+	deltaBase, critical := crlExtension(delta, deltaCRLIndicatorOID)
+	if deltaBase != baseNumber || !critical {
+		t.Fatalf("expected a critical delta CRL indicator for CRL %d, got %d", baseNumber, deltaBase)
+	}
+	if deltaNumber, _ := crlExtension(delta, crlNumberOID); deltaNumber <= baseNumber {
+		t.Fatalf("expected the delta CRL number %d to follow %d", deltaNumber, baseNumber)
+	}
+
+	// The periodic function does nothing until the CRLs near expiry
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	if crl := fetchCRL("crl"); len(crl.TBSCertList.RevokedCertificates) != 0 {
+		t.Fatal("expected the complete CRL not to be rebuilt")
+	}
+
+	state, err := fetchCRLState(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.NextUpdate = time.Now().Add(time.Hour)
+	if err := writeCRLState(context.Background(), storage, state); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+
+	full := fetchCRL("crl")
+	if len(full.TBSCertList.RevokedCertificates) != 1 {
+		t.Fatalf("expected one revoked certificate on the complete CRL, got %d", len(full.TBSCertList.RevokedCertificates))
+	}
+	fullNumber, _ := crlExtension(full, crlNumberOID)
+	delta = fetchCRL("crl/delta")
+	if len(delta.TBSCertList.RevokedCertificates) != 0 {
+		t.Fatal("expected an empty delta CRL after a complete rebuild")
+	}
+	if deltaBase, _ := crlExtension(delta, deltaCRLIndicatorOID); deltaBase != fullNumber {
+		t.Fatalf("expected the delta CRL to be based on CRL %d, got %d", fullNumber, deltaBase)
+	}
+	resp = mustRequest(logical.ReadOperation, "cert/delta-crl", nil)
+	if resp.Data["certificate"] == "" {
+		t.Fatal("expected the PEM-encoded delta CRL")
+	}
+	walSerials, err := storage.List(context.Background(), deltaWALPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(walSerials) != 0 {
+		t.Fatalf("expected the delta CRL entries to be cleared, got %v", walSerials)
+	}
+}
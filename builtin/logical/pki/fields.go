@@ -170,10 +170,23 @@ this value.`,
 func addCAKeyGenerationFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
 	fields["exported"] = &framework.FieldSchema{
 		Type: framework.TypeString,
-		Description: `Must be "internal" or "exported". If set to
-"exported", the generated private key will be
-returned. This is your *only* chance to retrieve
-the private key!`,
+		Description: `Must be "internal", "exported" or "existing".
+If set to "exported", the generated private key
+will be returned. This is your *only* chance to
+retrieve the private key! If set to "existing",
+the key given by "key_ref" is used instead of
+generating a new one.`,
+	}
+
+	fields["key_name"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Optional name for the generated key.`,
+	}
+
+	fields["key_ref"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Reference, by name or ID, to the existing key
+to use when "exported" is set to "existing".`,
 	}
 
 	fields["key_bits"] = &framework.FieldSchema{
@@ -210,3 +223,15 @@ func addCAIssueFields(fields map[string]*framework.FieldSchema) map[string]*fram
 
 	return fields
 }
+
+// addIssuerRefField adds the field selecting the issuer to sign with
+func addIssuerRefField(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["issuer_ref"] = &framework.FieldSchema{
+		Type:    framework.TypeString,
+		Default: defaultRef,
+		Description: `Reference, by name or ID, to the issuer to
+sign with. Defaults to the default issuer.`,
+	}
+
+	return fields
+}
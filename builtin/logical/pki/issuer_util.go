@@ -0,0 +1,683 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	issuerPrefix       = "config/issuer/"
+	keyPrefix          = "config/key/"
+	issuersConfigPath  = "config/issuers"
+	issuerCRLPrefix    = "crls/"
+	legacyCABundlePath = "config/ca_bundle"
+
+	// defaultRef refers to the default issuer wherever an issuer reference
+	// is accepted
+	defaultRef = "default"
+)
+
+var refNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// keyEntry is a private key that can be used by one or more issuers
+type keyEntry struct {
+	ID             string                  `json:"id"`
+	Name           string                  `json:"name"`
+	PrivateKeyType certutil.PrivateKeyType `json:"private_key_type"`
+	PrivateKey     string                  `json:"private_key"`
+}
+
+// issuerEntry is a CA certificate. Issuers with a KeyID can sign
+// certificates and CRLs; the others only serve to build CA chains.
+type issuerEntry struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	KeyID        string `json:"key_id"`
+	Certificate  string `json:"certificate"`
+	SerialNumber string `json:"serial_number"`
+}
+
+type issuersConfigEntry struct {
+	DefaultIssuerID string `json:"default"`
+}
+
+func (k *keyEntry) parse() (*certutil.ParsedCertBundle, error) {
+	cb := &certutil.CertBundle{
+		PrivateKey:     k.PrivateKey,
+		PrivateKeyType: k.PrivateKeyType,
+	}
+	return cb.ToParsedCertBundle()
+}
+
+func (i *issuerEntry) parseCertificate() (*x509.Certificate, []byte, error) {
+	block, _ := pem.Decode([]byte(i.Certificate))
+	if block == nil {
+		return nil, nil, fmt.Errorf("unable to decode certificate of issuer %s", i.ID)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse certificate of issuer %s: %v", i.ID, err)
+	}
+	return cert, block.Bytes, nil
+}
+
+func fetchKey(ctx context.Context, s logical.Storage, id string) (*keyEntry, error) {
+	entry, err := s.Get(ctx, keyPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var key keyEntry
+	if err := entry.DecodeJSON(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func writeKey(ctx context.Context, s logical.Storage, key *keyEntry) error {
+	entry, err := logical.StorageEntryJSON(keyPrefix+key.ID, key)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+func fetchIssuer(ctx context.Context, s logical.Storage, id string) (*issuerEntry, error) {
+	entry, err := s.Get(ctx, issuerPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var issuer issuerEntry
+	if err := entry.DecodeJSON(&issuer); err != nil {
+		return nil, err
+	}
+	return &issuer, nil
+}
+
+func writeIssuer(ctx context.Context, s logical.Storage, issuer *issuerEntry) error {
+	entry, err := logical.StorageEntryJSON(issuerPrefix+issuer.ID, issuer)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// listSorted lists the IDs under the prefix in a stable order, so that
+// chain building is deterministic
+func listSorted(ctx context.Context, s logical.Storage, prefix string) ([]string, error) {
+	ids, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func fetchIssuersConfig(ctx context.Context, s logical.Storage) (*issuersConfigEntry, error) {
+	entry, err := s.Get(ctx, issuersConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var config issuersConfigEntry
+	if entry != nil {
+		if err := entry.DecodeJSON(&config); err != nil {
+			return nil, err
+		}
+	}
+	return &config, nil
+}
+
+// setDefaultIssuer makes the issuer the default one, also storing its
+// certificate at the location served by the "ca" endpoints. An empty id
+// clears the default along with the certificate and CRL served.
+func setDefaultIssuer(ctx context.Context, s logical.Storage, id string) error {
+	entry, err := logical.StorageEntryJSON(issuersConfigPath, &issuersConfigEntry{
+		DefaultIssuerID: id,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return err
+	}
+
+	if id == "" {
+		for _, path := range []string{"ca", "crl", deltaCRLPath} {
+			if err := s.Delete(ctx, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	issuer, err := fetchIssuer(ctx, s, id)
+	if err != nil {
+		return err
+	}
+	if issuer == nil {
+		return fmt.Errorf("issuer %s not found", id)
+	}
+	_, certBytes, err := issuer.parseCertificate()
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, &logical.StorageEntry{
+		Key:   "ca",
+		Value: certBytes,
+	})
+}
+
+// resolveRef resolves a reference by ID or name to the ID of an entry under
+// the prefix, returning an empty string if none matches
+func resolveRef(ctx context.Context, s logical.Storage, prefix, ref string) (string, error) {
+	entry, err := s.Get(ctx, prefix+ref)
+	if err != nil {
+		return "", err
+	}
+	if entry != nil {
+		return ref, nil
+	}
+
+	ids, err := s.List(ctx, prefix)
+	if err != nil {
+		return "", err
+	}
+	for _, id := range ids {
+		entry, err := s.Get(ctx, prefix+id)
+		if err != nil {
+			return "", err
+		}
+		if entry == nil {
+			continue
+		}
+		var named struct {
+			Name string `json:"name"`
+		}
+		if err := entry.DecodeJSON(&named); err != nil {
+			return "", err
+		}
+		if named.Name == ref {
+			return id, nil
+		}
+	}
+	return "", nil
+}
+
+// resolveIssuerRef returns the ID of the issuer with the given ID or name,
+// or of the default issuer if the reference is empty or "default"
+func (b *backend) resolveIssuerRef(ctx context.Context, s logical.Storage, ref string) (string, error) {
+	if err := b.migrateLegacyCABundle(ctx, s); err != nil {
+		return "", errutil.InternalError{Err: fmt.Sprintf("error migrating CA bundle: %v", err)}
+	}
+
+	if ref == "" || ref == defaultRef {
+		config, err := fetchIssuersConfig(ctx, s)
+		if err != nil {
+			return "", errutil.InternalError{Err: fmt.Sprintf("unable to fetch issuers configuration: %v", err)}
+		}
+		if config.DefaultIssuerID == "" {
+			return "", errutil.UserError{Err: "backend must be configured with a CA certificate/key"}
+		}
+		return config.DefaultIssuerID, nil
+	}
+
+	id, err := resolveRef(ctx, s, issuerPrefix, ref)
+	if err != nil {
+		return "", errutil.InternalError{Err: fmt.Sprintf("unable to resolve issuer %q: %v", ref, err)}
+	}
+	if id == "" {
+		return "", errutil.UserError{Err: fmt.Sprintf("issuer %q not found", ref)}
+	}
+	return id, nil
+}
+
+// resolveKeyRef returns the ID of the key with the given ID or name
+func (b *backend) resolveKeyRef(ctx context.Context, s logical.Storage, ref string) (string, error) {
+	if err := b.migrateLegacyCABundle(ctx, s); err != nil {
+		return "", errutil.InternalError{Err: fmt.Sprintf("error migrating CA bundle: %v", err)}
+	}
+
+	id, err := resolveRef(ctx, s, keyPrefix, ref)
+	if err != nil {
+		return "", errutil.InternalError{Err: fmt.Sprintf("unable to resolve key %q: %v", ref, err)}
+	}
+	if id == "" {
+		return "", errutil.UserError{Err: fmt.Sprintf("key %q not found", ref)}
+	}
+	return id, nil
+}
+
+// checkRefName validates a new name for the entry with the given ID under
+// the prefix, which must be unique
+func checkRefName(ctx context.Context, s logical.Storage, prefix, id, name string) error {
+	if name == "" {
+		return nil
+	}
+	if name == defaultRef || !refNameRegex.MatchString(name) {
+		return errutil.UserError{Err: fmt.Sprintf("invalid name %q", name)}
+	}
+
+	existing, err := resolveRef(ctx, s, prefix, name)
+	if err != nil {
+		return err
+	}
+	if existing != "" && existing != id {
+		return errutil.UserError{Err: fmt.Sprintf("name %q is already in use", name)}
+	}
+	return nil
+}
+
+// importKey stores the PEM-encoded private key, unless a key with the same
+// public key already exists, in which case that one is returned. Issuers
+// without a key that match a new key are updated to use it.
+func importKey(ctx context.Context, s logical.Storage, privateKey, name string) (*keyEntry, bool, error) {
+	parsed, err := (&certutil.CertBundle{PrivateKey: privateKey}).ToParsedCertBundle()
+	if err != nil {
+		return nil, false, err
+	}
+	if parsed.PrivateKey == nil {
+		return nil, false, errutil.UserError{Err: "private key could not be parsed"}
+	}
+	public := parsed.PrivateKey.Public()
+
+	ids, err := listSorted(ctx, s, keyPrefix)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, id := range ids {
+		key, err := fetchKey(ctx, s, id)
+		if err != nil {
+			return nil, false, err
+		}
+		if key == nil {
+			continue
+		}
+		existing, err := key.parse()
+		if err != nil {
+			return nil, false, err
+		}
+		if equal, _ := certutil.ComparePublicKeys(existing.PrivateKey.Public(), public); equal {
+			return key, true, nil
+		}
+	}
+
+	if err := checkRefName(ctx, s, keyPrefix, "", name); err != nil {
+		return nil, false, err
+	}
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, false, err
+	}
+	cb, err := parsed.ToCertBundle()
+	if err != nil {
+		return nil, false, err
+	}
+	key := &keyEntry{
+		ID:             id,
+		Name:           name,
+		PrivateKeyType: parsed.PrivateKeyType,
+		PrivateKey:     cb.PrivateKey,
+	}
+	if err := writeKey(ctx, s, key); err != nil {
+		return nil, false, err
+	}
+
+	ids, err = listSorted(ctx, s, issuerPrefix)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, issuerID := range ids {
+		issuer, err := fetchIssuer(ctx, s, issuerID)
+		if err != nil {
+			return nil, false, err
+		}
+		if issuer == nil || issuer.KeyID != "" {
+			continue
+		}
+		cert, _, err := issuer.parseCertificate()
+		if err != nil {
+			return nil, false, err
+		}
+		if equal, _ := certutil.ComparePublicKeys(cert.PublicKey, public); equal {
+			issuer.KeyID = key.ID
+			if err := writeIssuer(ctx, s, issuer); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	return key, false, nil
+}
+
+// importIssuer stores the PEM-encoded CA certificate, unless the same
+// certificate is already stored, in which case that issuer is returned. The
+// issuer is linked to the stored key matching its public key, if any, and
+// becomes the default issuer if there is none.
+func importIssuer(ctx context.Context, s logical.Storage, certificate, name string) (*issuerEntry, bool, error) {
+	block, _ := pem.Decode([]byte(certificate))
+	if block == nil {
+		return nil, false, errutil.UserError{Err: "certificate could not be PEM-decoded"}
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, false, errutil.UserError{Err: fmt.Sprintf("error parsing certificate: %v", err)}
+	}
+	if !cert.IsCA {
+		return nil, false, errutil.UserError{Err: "the given certificate is not marked for CA use and cannot be used with this backend"}
+	}
+
+	ids, err := listSorted(ctx, s, issuerPrefix)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, id := range ids {
+		issuer, err := fetchIssuer(ctx, s, id)
+		if err != nil {
+			return nil, false, err
+		}
+		if issuer == nil {
+			continue
+		}
+		_, certBytes, err := issuer.parseCertificate()
+		if err != nil {
+			return nil, false, err
+		}
+		if bytes.Equal(certBytes, block.Bytes) {
+			return issuer, true, nil
+		}
+	}
+
+	if err := checkRefName(ctx, s, issuerPrefix, "", name); err != nil {
+		return nil, false, err
+	}
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, false, err
+	}
+	issuer := &issuerEntry{
+		ID:   id,
+		Name: name,
+		Certificate: strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: block.Bytes,
+		}))),
+		SerialNumber: certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":"),
+	}
+
+	keyIDs, err := listSorted(ctx, s, keyPrefix)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, keyID := range keyIDs {
+		key, err := fetchKey(ctx, s, keyID)
+		if err != nil {
+			return nil, false, err
+		}
+		if key == nil {
+			continue
+		}
+		parsed, err := key.parse()
+		if err != nil {
+			return nil, false, err
+		}
+		if equal, _ := certutil.ComparePublicKeys(cert.PublicKey, parsed.PrivateKey.Public()); equal {
+			issuer.KeyID = key.ID
+			break
+		}
+	}
+
+	if err := writeIssuer(ctx, s, issuer); err != nil {
+		return nil, false, err
+	}
+
+	config, err := fetchIssuersConfig(ctx, s)
+	if err != nil {
+		return nil, false, err
+	}
+	if config.DefaultIssuerID == "" {
+		if err := setDefaultIssuer(ctx, s, issuer.ID); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return issuer, false, nil
+}
+
+// importPEMBundle imports every private key and CA certificate of the
+// concatenated PEM blocks, returning the IDs of the newly created issuers
+// and keys
+func importPEMBundle(ctx context.Context, s logical.Storage, pemBundle string) ([]string, []string, error) {
+	var keys, certs []string
+	rest := []byte(pemBundle)
+	for len(bytes.TrimSpace(rest)) > 0 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, nil, errutil.UserError{Err: "no data found in PEM block"}
+		}
+
+		parsed, err := certutil.ParsePEMBundle(string(pem.EncodeToMemory(block)))
+		if err != nil {
+			return nil, nil, err
+		}
+		cb, err := parsed.ToCertBundle()
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case cb.PrivateKey != "":
+			keys = append(keys, cb.PrivateKey)
+		case cb.Certificate != "":
+			certs = append(certs, cb.Certificate)
+		}
+	}
+	if len(keys) == 0 && len(certs) == 0 {
+		return nil, nil, errutil.UserError{Err: "no private key or certificate found in the PEM bundle"}
+	}
+
+	// Import the keys first so the issuers get linked to them
+	var importedKeys, importedIssuers []string
+	for _, privateKey := range keys {
+		key, existing, err := importKey(ctx, s, privateKey, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		if !existing {
+			importedKeys = append(importedKeys, key.ID)
+		}
+	}
+	for _, certificate := range certs {
+		issuer, existing, err := importIssuer(ctx, s, certificate, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		if !existing {
+			importedIssuers = append(importedIssuers, issuer.ID)
+		}
+	}
+
+	return importedIssuers, importedKeys, nil
+}
+
+// migrateLegacyCABundle moves the single CA bundle stored by older versions
+// into the issuer and key storage
+func (b *backend) migrateLegacyCABundle(ctx context.Context, s logical.Storage) error {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	entry, err := s.Get(ctx, legacyCABundlePath)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return nil
+	}
+
+	var cb certutil.CertBundle
+	if err := entry.DecodeJSON(&cb); err != nil {
+		return err
+	}
+	if cb.PrivateKey != "" {
+		if _, _, err := importKey(ctx, s, cb.PrivateKey, ""); err != nil {
+			return err
+		}
+	}
+	if cb.Certificate != "" {
+		if _, _, err := importIssuer(ctx, s, cb.Certificate, ""); err != nil {
+			return err
+		}
+	}
+	for _, chainCert := range cb.CAChain {
+		if _, _, err := importIssuer(ctx, s, chainCert, ""); err != nil {
+			return err
+		}
+	}
+
+	return s.Delete(ctx, legacyCABundlePath)
+}
+
+// fetchIssuerChain returns the issuers of the certificate found among the
+// stored issuers, from its direct issuer up to the root
+func fetchIssuerChain(ctx context.Context, s logical.Storage, cert *x509.Certificate) ([]*certutil.CertBlock, error) {
+	ids, err := listSorted(ctx, s, issuerPrefix)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []*certutil.CertBlock
+	for _, id := range ids {
+		issuer, err := fetchIssuer(ctx, s, id)
+		if err != nil {
+			return nil, err
+		}
+		if issuer == nil {
+			continue
+		}
+		issuerCert, certBytes, err := issuer.parseCertificate()
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, &certutil.CertBlock{
+			Certificate: issuerCert,
+			Bytes:       certBytes,
+		})
+	}
+
+	var chain []*certutil.CertBlock
+	seen := map[string]bool{string(cert.Raw): true}
+	for !isSelfIssued(cert) {
+		var parent *certutil.CertBlock
+		for _, candidate := range candidates {
+			if seen[string(candidate.Bytes)] || !bytes.Equal(cert.RawIssuer, candidate.Certificate.RawSubject) {
+				continue
+			}
+			if cert.CheckSignatureFrom(candidate.Certificate) == nil {
+				parent = candidate
+				break
+			}
+		}
+		if parent == nil {
+			break
+		}
+		chain = append(chain, parent)
+		seen[string(parent.Bytes)] = true
+		cert = parent.Certificate
+	}
+
+	return chain, nil
+}
+
+func isSelfIssued(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject)
+}
+
+// issuedBy returns whether the certificate claims to be issued by the CA
+// certificate
+func issuedBy(cert, ca *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, ca.RawSubject) {
+		return false
+	}
+	if len(cert.AuthorityKeyId) > 0 && len(ca.SubjectKeyId) > 0 {
+		return bytes.Equal(cert.AuthorityKeyId, ca.SubjectKeyId)
+	}
+	return true
+}
+
+// fetchIssuerSigner returns the issuer's certificate and private key
+func fetchIssuerSigner(ctx context.Context, s logical.Storage, issuer *issuerEntry) (*certutil.ParsedCertBundle, error) {
+	if issuer.KeyID == "" {
+		return nil, errutil.UserError{Err: fmt.Sprintf("issuer %s has no private key and cannot be used for signing", issuer.ID)}
+	}
+	key, err := fetchKey(ctx, s, issuer.KeyID)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch key of issuer %s: %v", issuer.ID, err)}
+	}
+	if key == nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("key %s of issuer %s not found", issuer.KeyID, issuer.ID)}
+	}
+
+	parsedBundle, err := (&certutil.CertBundle{
+		PrivateKey:     key.PrivateKey,
+		PrivateKeyType: key.PrivateKeyType,
+		Certificate:    issuer.Certificate,
+	}).ToParsedCertBundle()
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+	if parsedBundle.Certificate == nil || parsedBundle.PrivateKey == nil {
+		return nil, errutil.InternalError{Err: "stored CA information not able to be parsed"}
+	}
+	return parsedBundle, nil
+}
+
+// fetchIssuerCAInfo returns the certificate and chain of the referenced
+// issuer, without its private key, so that issuers without a key can be used
+func (b *backend) fetchIssuerCAInfo(ctx context.Context, s logical.Storage, issuerRef string) (*issuerEntry, *caInfoBundle, error) {
+	id, err := b.resolveIssuerRef(ctx, s, issuerRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	issuer, err := fetchIssuer(ctx, s, id)
+	if err != nil {
+		return nil, nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch issuer %q: %v", issuerRef, err)}
+	}
+	if issuer == nil {
+		return nil, nil, errutil.UserError{Err: fmt.Sprintf("issuer %q not found", issuerRef)}
+	}
+
+	cert, certBytes, err := issuer.parseCertificate()
+	if err != nil {
+		return nil, nil, errutil.InternalError{Err: err.Error()}
+	}
+	chain, err := fetchIssuerChain(ctx, s, cert)
+	if err != nil {
+		return nil, nil, errutil.InternalError{Err: fmt.Sprintf("unable to build CA chain: %v", err)}
+	}
+
+	return issuer, &caInfoBundle{
+		ParsedCertBundle: certutil.ParsedCertBundle{
+			Certificate:      cert,
+			CertificateBytes: certBytes,
+			CAChain:          chain,
+		},
+		IssuerID: id,
+	}, nil
+}
@@ -0,0 +1,328 @@
+package pki
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// acmePattern returns the pattern for an ACME endpoint of a role
+func acmePattern(suffix string) string {
+	return "acme/" + framework.GenericNameRegex("role") + "/" + suffix
+}
+
+// acmeFields returns the fields shared by every ACME endpoint
+func acmeFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"role": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: `The desired role to issue certificates against.`,
+		},
+	}
+}
+
+func pathACMEDirectory(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("directory"),
+		Fields:  acmeFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.acmeWrapper(b.pathACMEDirectoryRead),
+		},
+
+		HelpSynopsis:    pathACMEDirectoryHelpSyn,
+		HelpDescription: pathACMEDirectoryHelpDesc,
+	}
+}
+
+func pathACMENewNonce(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("new-nonce"),
+		Fields:  acmeFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.acmeWrapper(b.pathACMENewNonce),
+			logical.HeaderOperation: b.acmeWrapper(b.pathACMENewNonce),
+		},
+
+		HelpSynopsis:    pathACMENewNonceHelpSyn,
+		HelpDescription: pathACMENewNonceHelpDesc,
+	}
+}
+
+func pathACMENewAccount(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("new-account"),
+		Fields:  acmeJWSFields(acmeFields()),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeJWSWrapper(true, b.pathACMENewAccount),
+		},
+
+		HelpSynopsis:    pathACMENewAccountHelpSyn,
+		HelpDescription: pathACMENewAccountHelpDesc,
+	}
+}
+
+func pathACMEAccount(b *backend) *framework.Path {
+	fields := acmeJWSFields(acmeFields())
+	fields["account_id"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The ID of the account.`,
+	}
+
+	return &framework.Path{
+		Pattern: acmePattern("account/" + framework.GenericNameRegex("account_id")),
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeJWSWrapper(false, b.pathACMEAccountUpdate),
+		},
+
+		HelpSynopsis:    pathACMEAccountHelpSyn,
+		HelpDescription: pathACMEAccountHelpDesc,
+	}
+}
+
+func pathACMEAccountOrders(b *backend) *framework.Path {
+	fields := acmeJWSFields(acmeFields())
+	fields["account_id"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The ID of the account.`,
+	}
+
+	return &framework.Path{
+		Pattern: acmePattern("account/" + framework.GenericNameRegex("account_id") + "/orders"),
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeJWSWrapper(false, b.pathACMEAccountOrders),
+		},
+
+		HelpSynopsis:    pathACMEAccountOrdersHelpSyn,
+		HelpDescription: pathACMEAccountOrdersHelpDesc,
+	}
+}
+
+func (b *backend) pathACMEDirectoryRead(ctx context.Context, req *logical.Request, ac *acmeContext, data *framework.FieldData) (*logical.Response, error) {
+	return b.acmeResponse(ac, http.StatusOK, map[string]interface{}{
+		"newNonce":   ac.url("new-nonce"),
+		"newAccount": ac.url("new-account"),
+		"newOrder":   ac.url("new-order"),
+		"revokeCert": ac.url("revoke-cert"),
+		"meta": map[string]interface{}{
+			"externalAccountRequired": false,
+		},
+	}, nil)
+}
+
+func (b *backend) pathACMENewNonce(ctx context.Context, req *logical.Request, ac *acmeContext, data *framework.FieldData) (*logical.Response, error) {
+	status := http.StatusNoContent
+	if req.Operation == logical.HeaderOperation {
+		status = http.StatusOK
+	}
+	return b.acmeResponse(ac, status, nil, nil)
+}
+
+// acmeAccountRequest is the payload of new-account and account update
+// requests
+type acmeAccountRequest struct {
+	Contact              []string `json:"contact"`
+	TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+	OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+	Status               string   `json:"status"`
+}
+
+func (b *backend) pathACMENewAccount(ctx context.Context, req *logical.Request, ac *acmeContext, jws *acmeJWS, data *framework.FieldData) (*logical.Response, error) {
+	var payload acmeAccountRequest
+	if err := jws.decodePayload(&payload); err != nil {
+		return nil, err
+	}
+
+	thumbprint, err := acmeKeyThumbprint(jws.jwk)
+	if err != nil {
+		return nil, acmeMalformed("failed to compute key thumbprint: %v", err)
+	}
+
+	// Registering an already-known key returns the existing account
+	account, err := b.acmeGetAccountByKey(ctx, req.Storage, thumbprint)
+	if err != nil {
+		return nil, err
+	}
+	if account != nil && account.Role == ac.roleName {
+		return b.acmeAccountResponse(ac, http.StatusOK, account)
+	}
+	if account != nil {
+		return nil, acmeUnauthorized("key is already registered to another role")
+	}
+
+	if payload.OnlyReturnExisting {
+		return nil, newACMEError(http.StatusBadRequest, "accountDoesNotExist", "no account exists for the given key")
+	}
+
+	if err := acmeValidateContacts(payload.Contact); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	jwk, err := jws.jwk.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	account = &acmeAccount{
+		ID:                   id,
+		Role:                 ac.roleName,
+		Status:               acmeStatusValid,
+		Contact:              payload.Contact,
+		TermsOfServiceAgreed: payload.TermsOfServiceAgreed,
+		JWK:                  jwk,
+		KeyThumbprint:        thumbprint,
+		CreatedAt:            time.Now(),
+	}
+	if err := acmePut(ctx, req.Storage, acmeAccountPrefix+id, account); err != nil {
+		return nil, err
+	}
+	if err := acmePut(ctx, req.Storage, acmeAccountKeyPrefix+thumbprint, id); err != nil {
+		return nil, err
+	}
+
+	return b.acmeAccountResponse(ac, http.StatusCreated, account)
+}
+
+func (b *backend) pathACMEAccountUpdate(ctx context.Context, req *logical.Request, ac *acmeContext, jws *acmeJWS, data *framework.FieldData) (*logical.Response, error) {
+	if jws.account.ID != data.Get("account_id").(string) {
+		return nil, acmeUnauthorized("request was not signed by the account's key")
+	}
+	account := jws.account
+
+	if !jws.isPostAsGet() {
+		var payload acmeAccountRequest
+		if err := jws.decodePayload(&payload); err != nil {
+			return nil, err
+		}
+
+		switch payload.Status {
+		case "":
+		case acmeStatusDeactivated:
+			account.Status = acmeStatusDeactivated
+		default:
+			return nil, acmeMalformed("account status can only be updated to %q", acmeStatusDeactivated)
+		}
+
+		if payload.Contact != nil {
+			if err := acmeValidateContacts(payload.Contact); err != nil {
+				return nil, err
+			}
+			account.Contact = payload.Contact
+		}
+
+		if err := acmePut(ctx, req.Storage, acmeAccountPrefix+account.ID, account); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.acmeAccountResponse(ac, http.StatusOK, account)
+}
+
+func (b *backend) pathACMEAccountOrders(ctx context.Context, req *logical.Request, ac *acmeContext, jws *acmeJWS, data *framework.FieldData) (*logical.Response, error) {
+	if jws.account.ID != data.Get("account_id").(string) {
+		return nil, acmeUnauthorized("request was not signed by the account's key")
+	}
+
+	ids, err := req.Storage.List(ctx, acmeAccountOrderPrefix+jws.account.ID+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []string{}
+	for _, id := range ids {
+		orders = append(orders, ac.url("order", id))
+	}
+
+	return b.acmeResponse(ac, http.StatusOK, map[string]interface{}{
+		"orders": orders,
+	}, nil)
+}
+
+func (b *backend) acmeAccountResponse(ac *acmeContext, status int, account *acmeAccount) (*logical.Response, error) {
+	contact := account.Contact
+	if contact == nil {
+		contact = []string{}
+	}
+
+	return b.acmeResponse(ac, status, map[string]interface{}{
+		"status":               account.Status,
+		"contact":              contact,
+		"termsOfServiceAgreed": account.TermsOfServiceAgreed,
+		"orders":               ac.url("account", account.ID, "orders"),
+	}, map[string][]string{
+		"Location": []string{ac.url("account", account.ID)},
+	})
+}
+
+// acmeValidateContacts checks that all contacts are mailto: URLs, the only
+// scheme RFC 8555 requires servers to support
+func acmeValidateContacts(contacts []string) error {
+	for _, contact := range contacts {
+		if !strings.HasPrefix(contact, "mailto:") || len(contact) == len("mailto:") {
+			return newACMEError(http.StatusBadRequest, "unsupportedContact", "unsupported contact %q", contact)
+		}
+	}
+	return nil
+}
+
+const pathACMEDirectoryHelpSyn = `
+Fetch the ACME directory of a role.
+`
+
+const pathACMEDirectoryHelpDesc = `
+This path returns the ACME (RFC 8555) directory object for the given role,
+listing the URLs of the other ACME endpoints. ACME clients should be pointed
+at this URL. ACME must be enabled and have a base URL set through the
+"config/acme" endpoint.
+`
+
+const pathACMENewNonceHelpSyn = `
+Fetch a new ACME anti-replay nonce.
+`
+
+const pathACMENewNonceHelpDesc = `
+This path returns a fresh nonce in the Replay-Nonce header. Every signed
+ACME request must carry an unused nonce.
+`
+
+const pathACMENewAccountHelpSyn = `
+Register a new ACME account.
+`
+
+const pathACMENewAccountHelpDesc = `
+This path creates an ACME account bound to the role, identified by the key
+used to sign the request. If an account already exists for the key it is
+returned instead.
+`
+
+const pathACMEAccountHelpSyn = `
+Update or deactivate an ACME account.
+`
+
+const pathACMEAccountHelpDesc = `
+This path returns the ACME account, optionally updating its contacts or
+deactivating it. Deactivated accounts can no longer be used.
+`
+
+const pathACMEAccountOrdersHelpSyn = `
+List the orders of an ACME account.
+`
+
+const pathACMEAccountOrdersHelpDesc = `
+This path returns the URLs of the orders created by the ACME account.
+`
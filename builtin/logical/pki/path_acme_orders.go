@@ -0,0 +1,709 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathACMENewOrder(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("new-order"),
+		Fields:  acmeJWSFields(acmeFields()),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeJWSWrapper(false, b.pathACMENewOrder),
+		},
+
+		HelpSynopsis:    pathACMENewOrderHelpSyn,
+		HelpDescription: pathACMENewOrderHelpDesc,
+	}
+}
+
+func pathACMEOrder(b *backend) *framework.Path {
+	fields := acmeJWSFields(acmeFields())
+	fields["order_id"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The ID of the order.`,
+	}
+
+	return &framework.Path{
+		Pattern: acmePattern("order/" + framework.GenericNameRegex("order_id")),
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeJWSWrapper(false, b.pathACMEOrderRead),
+		},
+
+		HelpSynopsis:    pathACMEOrderHelpSyn,
+		HelpDescription: pathACMEOrderHelpDesc,
+	}
+}
+
+func pathACMEOrderFinalize(b *backend) *framework.Path {
+	fields := acmeJWSFields(acmeFields())
+	fields["order_id"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The ID of the order.`,
+	}
+
+	return &framework.Path{
+		Pattern: acmePattern("order/" + framework.GenericNameRegex("order_id") + "/finalize"),
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeJWSWrapper(false, b.pathACMEOrderFinalize),
+		},
+
+		HelpSynopsis:    pathACMEOrderFinalizeHelpSyn,
+		HelpDescription: pathACMEOrderFinalizeHelpDesc,
+	}
+}
+
+func pathACMEAuthorization(b *backend) *framework.Path {
+	fields := acmeJWSFields(acmeFields())
+	fields["authorization_id"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The ID of the authorization.`,
+	}
+
+	return &framework.Path{
+		Pattern: acmePattern("authorization/" + framework.GenericNameRegex("authorization_id")),
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeJWSWrapper(false, b.pathACMEAuthorization),
+		},
+
+		HelpSynopsis:    pathACMEAuthorizationHelpSyn,
+		HelpDescription: pathACMEAuthorizationHelpDesc,
+	}
+}
+
+func pathACMEChallenge(b *backend) *framework.Path {
+	fields := acmeJWSFields(acmeFields())
+	fields["authorization_id"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The ID of the authorization.`,
+	}
+	fields["challenge_type"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The type of the challenge, "http-01" or "dns-01".`,
+	}
+
+	return &framework.Path{
+		Pattern: acmePattern("challenge/" + framework.GenericNameRegex("authorization_id") + "/" + framework.GenericNameRegex("challenge_type")),
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeJWSWrapper(false, b.pathACMEChallenge),
+		},
+
+		HelpSynopsis:    pathACMEChallengeHelpSyn,
+		HelpDescription: pathACMEChallengeHelpDesc,
+	}
+}
+
+func pathACMECertificate(b *backend) *framework.Path {
+	fields := acmeJWSFields(acmeFields())
+	fields["serial"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The serial number of the certificate, hyphen-separated.`,
+	}
+
+	return &framework.Path{
+		Pattern: acmePattern("certificate/" + framework.GenericNameRegex("serial")),
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeJWSWrapper(false, b.pathACMECertificate),
+		},
+
+		HelpSynopsis:    pathACMECertificateHelpSyn,
+		HelpDescription: pathACMECertificateHelpDesc,
+	}
+}
+
+func pathACMERevokeCert(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("revoke-cert"),
+		Fields:  acmeJWSFields(acmeFields()),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeJWSWrapper(true, b.pathACMERevokeCert),
+		},
+
+		HelpSynopsis:    pathACMERevokeCertHelpSyn,
+		HelpDescription: pathACMERevokeCertHelpDesc,
+	}
+}
+
+// acmeNewOrderRequest is the payload of a new-order request
+type acmeNewOrderRequest struct {
+	Identifiers []acmeIdentifier `json:"identifiers"`
+	NotBefore   string           `json:"notBefore"`
+	NotAfter    string           `json:"notAfter"`
+}
+
+func (b *backend) pathACMENewOrder(ctx context.Context, req *logical.Request, ac *acmeContext, jws *acmeJWS, data *framework.FieldData) (*logical.Response, error) {
+	var payload acmeNewOrderRequest
+	if err := jws.decodePayload(&payload); err != nil {
+		return nil, err
+	}
+
+	if payload.NotBefore != "" || payload.NotAfter != "" {
+		return nil, acmeMalformed("notBefore and notAfter are not supported; validity is controlled by the role")
+	}
+	if len(payload.Identifiers) == 0 {
+		return nil, acmeMalformed("at least one identifier is required")
+	}
+
+	// Reject names the role would refuse to issue for now, rather than
+	// after the client has gone through validation
+	names := make([]string, 0, len(payload.Identifiers))
+	seen := make(map[string]bool, len(payload.Identifiers))
+	identifiers := make([]acmeIdentifier, 0, len(payload.Identifiers))
+	for _, identifier := range payload.Identifiers {
+		if identifier.Type != "dns" {
+			return nil, newACMEError(http.StatusBadRequest, "unsupportedIdentifier", "unsupported identifier type %q", identifier.Type)
+		}
+		value := strings.ToLower(strings.TrimSpace(identifier.Value))
+		if value == "" || strings.Contains(value, "@") {
+			return nil, newACMEError(http.StatusBadRequest, "rejectedIdentifier", "invalid identifier %q", identifier.Value)
+		}
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		names = append(names, value)
+		identifiers = append(identifiers, acmeIdentifier{Type: "dns", Value: value})
+	}
+	if badName := validateNames(&dataBundle{role: ac.role}, names); badName != "" {
+		return nil, newACMEError(http.StatusBadRequest, "rejectedIdentifier", "name %q is not allowed by the role", badName)
+	}
+
+	orderID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	expires := time.Now().Add(acmeOrderLifetime)
+	order := &acmeOrder{
+		ID:          orderID,
+		AccountID:   jws.account.ID,
+		Status:      acmeStatusPending,
+		Expires:     expires,
+		Identifiers: identifiers,
+	}
+
+	for _, identifier := range identifiers {
+		authz, err := acmeNewAuthorization(jws.account.ID, identifier, expires)
+		if err != nil {
+			return nil, err
+		}
+		if err := acmePut(ctx, req.Storage, acmeAuthorizationPrefix+authz.ID, authz); err != nil {
+			return nil, err
+		}
+		order.AuthorizationIDs = append(order.AuthorizationIDs, authz.ID)
+	}
+
+	if err := acmePut(ctx, req.Storage, acmeOrderPrefix+orderID, order); err != nil {
+		return nil, err
+	}
+	if err := acmePut(ctx, req.Storage, acmeAccountOrderPrefix+jws.account.ID+"/"+orderID, orderID); err != nil {
+		return nil, err
+	}
+
+	return b.acmeOrderResponse(ac, http.StatusCreated, order)
+}
+
+// acmeNewAuthorization creates a pending authorization for an identifier.
+// Wildcard names can only be validated through DNS.
+func acmeNewAuthorization(accountID string, identifier acmeIdentifier, expires time.Time) (*acmeAuthorization, error) {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	authz := &acmeAuthorization{
+		ID:         id,
+		AccountID:  accountID,
+		Status:     acmeStatusPending,
+		Expires:    expires,
+		Identifier: identifier,
+	}
+
+	challengeTypes := []string{acmeChallengeHTTP01, acmeChallengeDNS01}
+	if strings.HasPrefix(identifier.Value, "*.") {
+		authz.Wildcard = true
+		authz.Identifier.Value = identifier.Value[2:]
+		challengeTypes = []string{acmeChallengeDNS01}
+	}
+
+	for _, challengeType := range challengeTypes {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		authz.Challenges = append(authz.Challenges, &acmeChallenge{
+			Type:   challengeType,
+			Token:  base64.RawURLEncoding.EncodeToString(buf),
+			Status: acmeStatusPending,
+		})
+	}
+
+	return authz, nil
+}
+
+// acmeGetAccountOrder loads an order, checking that it belongs to the
+// account that signed the request
+func (b *backend) acmeGetAccountOrder(ctx context.Context, req *logical.Request, jws *acmeJWS, id string) (*acmeOrder, error) {
+	order, err := b.acmeGetOrder(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil || order.AccountID != jws.account.ID {
+		return nil, acmeNotFound("order not found")
+	}
+
+	if err := b.acmeUpdateOrderStatus(ctx, req.Storage, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (b *backend) pathACMEOrderRead(ctx context.Context, req *logical.Request, ac *acmeContext, jws *acmeJWS, data *framework.FieldData) (*logical.Response, error) {
+	order, err := b.acmeGetAccountOrder(ctx, req, jws, data.Get("order_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	return b.acmeOrderResponse(ac, http.StatusOK, order)
+}
+
+// acmeFinalizeRequest is the payload of a finalize request
+type acmeFinalizeRequest struct {
+	CSR string `json:"csr"`
+}
+
+func (b *backend) pathACMEOrderFinalize(ctx context.Context, req *logical.Request, ac *acmeContext, jws *acmeJWS, data *framework.FieldData) (*logical.Response, error) {
+	order, err := b.acmeGetAccountOrder(ctx, req, jws, data.Get("order_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != acmeStatusReady {
+		return nil, newACMEError(http.StatusForbidden, "orderNotReady", "order is %s", order.Status)
+	}
+
+	var payload acmeFinalizeRequest
+	if err := jws.decodePayload(&payload); err != nil {
+		return nil, err
+	}
+	csrBytes, err := base64.RawURLEncoding.DecodeString(payload.CSR)
+	if err != nil {
+		return nil, newACMEError(http.StatusBadRequest, "badCSR", "failed to decode CSR: %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(csrBytes)
+	if err != nil {
+		return nil, newACMEError(http.StatusBadRequest, "badCSR", "failed to parse CSR: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, newACMEError(http.StatusBadRequest, "badCSR", "invalid CSR signature: %v", err)
+	}
+
+	// The CSR must request exactly the names that were authorized
+	if len(csr.EmailAddresses) != 0 || len(csr.IPAddresses) != 0 {
+		return nil, newACMEError(http.StatusBadRequest, "badCSR", "CSR may only contain DNS names")
+	}
+	csrNames := acmeNormalizeNames(append([]string{csr.Subject.CommonName}, csr.DNSNames...))
+	var orderNames []string
+	for _, identifier := range order.Identifiers {
+		orderNames = append(orderNames, identifier.Value)
+	}
+	orderNames = acmeNormalizeNames(orderNames)
+	if strings.Join(csrNames, ",") != strings.Join(orderNames, ",") {
+		return nil, newACMEError(http.StatusBadRequest, "badCSR", "CSR names %v do not match the order identifiers %v", csrNames, orderNames)
+	}
+
+	cb, chain, err := b.acmeIssue(ctx, req, ac, csrBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	serial := normalizeSerial(cb.SerialNumber)
+	if err := acmePut(ctx, req.Storage, acmeCertificatePrefix+serial, &acmeCertificate{
+		AccountID: jws.account.ID,
+		PEMChain:  chain,
+	}); err != nil {
+		return nil, err
+	}
+
+	order.Status = acmeStatusValid
+	order.CertificateSerial = serial
+	if err := acmePut(ctx, req.Storage, acmeOrderPrefix+order.ID, order); err != nil {
+		return nil, err
+	}
+
+	return b.acmeOrderResponse(ac, http.StatusOK, order)
+}
+
+// acmeIssue signs a CSR using the role, returning the issued certificate
+// and its PEM chain
+func (b *backend) acmeIssue(ctx context.Context, req *logical.Request, ac *acmeContext, csrBytes []byte) (*certutil.CertBundle, string, error) {
+	// Names come from the CSR, which has already been checked against the
+	// authorized identifiers
+	role := *ac.role
+	role.RequireCN = false
+
+	parsedBundle, signingBundle, err := b.signCSR(ctx, req, &role, csrBytes)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return nil, "", newACMEError(http.StatusBadRequest, "badCSR", "%v", err)
+		default:
+			return nil, "", err
+		}
+	}
+
+	cb, err := parsedBundle.ToCertBundle()
+	if err != nil {
+		return nil, "", errwrap.Wrapf("error converting raw cert bundle to cert bundle: {{err}}", err)
+	}
+	signingCB, err := signingBundle.ToCertBundle()
+	if err != nil {
+		return nil, "", errwrap.Wrapf("error converting raw signing bundle to cert bundle: {{err}}", err)
+	}
+
+	chain := []string{cb.Certificate}
+	if len(cb.CAChain) > 0 {
+		chain = append(chain, cb.CAChain...)
+	} else {
+		chain = append(chain, signingCB.Certificate)
+	}
+
+	return cb, strings.Join(chain, "\n") + "\n", nil
+}
+
+// acmeAuthorizationRequest is the payload of an authorization update
+type acmeAuthorizationRequest struct {
+	Status string `json:"status"`
+}
+
+func (b *backend) pathACMEAuthorization(ctx context.Context, req *logical.Request, ac *acmeContext, jws *acmeJWS, data *framework.FieldData) (*logical.Response, error) {
+	authz, err := b.acmeGetAuthorization(ctx, req.Storage, data.Get("authorization_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if authz == nil || authz.AccountID != jws.account.ID {
+		return nil, acmeNotFound("authorization not found")
+	}
+
+	if !jws.isPostAsGet() {
+		var payload acmeAuthorizationRequest
+		if err := jws.decodePayload(&payload); err != nil {
+			return nil, err
+		}
+		if payload.Status != acmeStatusDeactivated {
+			return nil, acmeMalformed("authorization status can only be updated to %q", acmeStatusDeactivated)
+		}
+		if authz.Status != acmeStatusPending && authz.Status != acmeStatusValid {
+			return nil, acmeMalformed("authorization is %s", authz.Status)
+		}
+		authz.Status = acmeStatusDeactivated
+		if err := acmePut(ctx, req.Storage, acmeAuthorizationPrefix+authz.ID, authz); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.acmeResponse(ac, http.StatusOK, acmeAuthorizationBody(ac, authz), nil)
+}
+
+func (b *backend) pathACMEChallenge(ctx context.Context, req *logical.Request, ac *acmeContext, jws *acmeJWS, data *framework.FieldData) (*logical.Response, error) {
+	authz, err := b.acmeGetAuthorization(ctx, req.Storage, data.Get("authorization_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if authz == nil || authz.AccountID != jws.account.ID {
+		return nil, acmeNotFound("authorization not found")
+	}
+
+	challengeType := data.Get("challenge_type").(string)
+	var challenge *acmeChallenge
+	for _, c := range authz.Challenges {
+		if c.Type == challengeType {
+			challenge = c
+		}
+	}
+	if challenge == nil {
+		return nil, acmeNotFound("challenge not found")
+	}
+
+	// Validation is only attempted once; afterwards the current state of
+	// the challenge is returned
+	if authz.Status == acmeStatusPending && challenge.Status == acmeStatusPending {
+		keyAuthorization := challenge.Token + "." + jws.account.KeyThumbprint
+
+		switch challenge.Type {
+		case acmeChallengeHTTP01:
+			err = b.acmeValidator.ValidateHTTP01(authz.Identifier.Value, challenge.Token, keyAuthorization)
+		case acmeChallengeDNS01:
+			err = b.acmeValidator.ValidateDNS01(authz.Identifier.Value, keyAuthorization)
+		}
+
+		if err != nil {
+			challenge.Status = acmeStatusInvalid
+			challenge.Error = newACMEError(http.StatusForbidden, "incorrectResponse", "%v", err)
+			authz.Status = acmeStatusInvalid
+		} else {
+			challenge.Status = acmeStatusValid
+			challenge.Validated = time.Now()
+			authz.Status = acmeStatusValid
+		}
+
+		if err := acmePut(ctx, req.Storage, acmeAuthorizationPrefix+authz.ID, authz); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.acmeResponse(ac, http.StatusOK, acmeChallengeBody(ac, authz, challenge), map[string][]string{
+		"Link": []string{fmt.Sprintf(`<%s>;rel="up"`, ac.url("authorization", authz.ID))},
+	})
+}
+
+func (b *backend) pathACMECertificate(ctx context.Context, req *logical.Request, ac *acmeContext, jws *acmeJWS, data *framework.FieldData) (*logical.Response, error) {
+	var cert acmeCertificate
+	ok, err := acmeGet(ctx, req.Storage, acmeCertificatePrefix+data.Get("serial").(string), &cert)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || cert.AccountID != jws.account.ID {
+		return nil, acmeNotFound("certificate not found")
+	}
+
+	resp, err := b.acmeResponse(ac, http.StatusOK, []byte(cert.PEMChain), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Data[logical.HTTPContentType] = "application/pem-certificate-chain"
+	return resp, nil
+}
+
+// acmeRevokeRequest is the payload of a revoke-cert request
+type acmeRevokeRequest struct {
+	Certificate string `json:"certificate"`
+	Reason      int    `json:"reason"`
+}
+
+func (b *backend) pathACMERevokeCert(ctx context.Context, req *logical.Request, ac *acmeContext, jws *acmeJWS, data *framework.FieldData) (*logical.Response, error) {
+	var payload acmeRevokeRequest
+	if err := jws.decodePayload(&payload); err != nil {
+		return nil, err
+	}
+
+	certBytes, err := base64.RawURLEncoding.DecodeString(payload.Certificate)
+	if err != nil {
+		return nil, acmeMalformed("failed to decode certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, acmeMalformed("failed to parse certificate: %v", err)
+	}
+	serial := certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":")
+
+	// Revocation is allowed to the account that ordered the certificate, or
+	// to anyone holding the certificate's private key
+	var owner acmeCertificate
+	ok, err := acmeGet(ctx, req.Storage, acmeCertificatePrefix+normalizeSerial(serial), &owner)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, acmeNotFound("certificate was not issued through ACME")
+	}
+	switch {
+	case jws.account != nil:
+		if owner.AccountID != jws.account.ID {
+			return nil, acmeUnauthorized("account did not order this certificate")
+		}
+	default:
+		certKey, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+		if err != nil {
+			return nil, acmeMalformed("unsupported certificate key: %v", err)
+		}
+		jwsKey, err := x509.MarshalPKIXPublicKey(jws.jwk.Key)
+		if err != nil || !bytes.Equal(certKey, jwsKey) {
+			return nil, acmeUnauthorized("request was not signed by the certificate's key")
+		}
+	}
+
+	b.revokeStorageLock.Lock()
+	defer b.revokeStorageLock.Unlock()
+
+	revoked, err := fetchCertBySerial(ctx, req, "revoked/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if revoked != nil {
+		return nil, newACMEError(http.StatusBadRequest, "alreadyRevoked", "certificate is already revoked")
+	}
+
+	resp, err := revokeCert(ctx, b, req, serial, false)
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil && resp.IsError() {
+		return nil, acmeMalformed("%v", resp.Error())
+	}
+
+	return b.acmeResponse(ac, http.StatusOK, nil, nil)
+}
+
+func (b *backend) acmeOrderResponse(ac *acmeContext, status int, order *acmeOrder) (*logical.Response, error) {
+	authorizations := make([]string, 0, len(order.AuthorizationIDs))
+	for _, id := range order.AuthorizationIDs {
+		authorizations = append(authorizations, ac.url("authorization", id))
+	}
+
+	body := map[string]interface{}{
+		"status":         order.Status,
+		"expires":        order.Expires.Format(time.RFC3339),
+		"identifiers":    order.Identifiers,
+		"authorizations": authorizations,
+		"finalize":       ac.url("order", order.ID, "finalize"),
+	}
+	if order.CertificateSerial != "" {
+		body["certificate"] = ac.url("certificate", order.CertificateSerial)
+	}
+
+	return b.acmeResponse(ac, status, body, map[string][]string{
+		"Location": []string{ac.url("order", order.ID)},
+	})
+}
+
+func acmeAuthorizationBody(ac *acmeContext, authz *acmeAuthorization) map[string]interface{} {
+	challenges := make([]map[string]interface{}, 0, len(authz.Challenges))
+	for _, challenge := range authz.Challenges {
+		challenges = append(challenges, acmeChallengeBody(ac, authz, challenge))
+	}
+
+	body := map[string]interface{}{
+		"status":     authz.Status,
+		"expires":    authz.Expires.Format(time.RFC3339),
+		"identifier": authz.Identifier,
+		"challenges": challenges,
+	}
+	if authz.Wildcard {
+		body["wildcard"] = true
+	}
+	return body
+}
+
+func acmeChallengeBody(ac *acmeContext, authz *acmeAuthorization, challenge *acmeChallenge) map[string]interface{} {
+	body := map[string]interface{}{
+		"type":   challenge.Type,
+		"url":    ac.url("challenge", authz.ID, challenge.Type),
+		"token":  challenge.Token,
+		"status": challenge.Status,
+	}
+	if !challenge.Validated.IsZero() {
+		body["validated"] = challenge.Validated.Format(time.RFC3339)
+	}
+	if challenge.Error != nil {
+		body["error"] = challenge.Error
+	}
+	return body
+}
+
+// acmeNormalizeNames lowercases, deduplicates and sorts a list of names,
+// dropping empty entries
+func acmeNormalizeNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	var ret []string
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+const pathACMENewOrderHelpSyn = `
+Create a new ACME order.
+`
+
+const pathACMENewOrderHelpDesc = `
+This path creates an order for a certificate covering the requested DNS
+identifiers, along with an authorization for each identifier. The names must
+be allowed by the role.
+`
+
+const pathACMEOrderHelpSyn = `
+Fetch an ACME order.
+`
+
+const pathACMEOrderHelpDesc = `
+This path returns the current state of an order created by the account.
+`
+
+const pathACMEOrderFinalizeHelpSyn = `
+Finalize an ACME order.
+`
+
+const pathACMEOrderFinalizeHelpDesc = `
+Once all of its authorizations are valid, this path signs a CSR for the
+order's identifiers using the role. The CSR must contain exactly the names of
+the order.
+`
+
+const pathACMEAuthorizationHelpSyn = `
+Fetch or deactivate an ACME authorization.
+`
+
+const pathACMEAuthorizationHelpDesc = `
+This path returns an authorization and its challenges, or deactivates it.
+`
+
+const pathACMEChallengeHelpSyn = `
+Respond to an ACME challenge.
+`
+
+const pathACMEChallengeHelpDesc = `
+This path validates an "http-01" or "dns-01" challenge. Validation is
+performed once, synchronously; if it fails the authorization becomes invalid
+and a new order must be created.
+`
+
+const pathACMECertificateHelpSyn = `
+Fetch a certificate issued through ACME.
+`
+
+const pathACMECertificateHelpDesc = `
+This path returns the PEM certificate chain issued for an order.
+`
+
+const pathACMERevokeCertHelpSyn = `
+Revoke a certificate issued through ACME.
+`
+
+const pathACMERevokeCertHelpDesc = `
+This path revokes a certificate issued through ACME. The request must be
+signed either by the account that ordered the certificate or by the
+certificate's own key.
+`
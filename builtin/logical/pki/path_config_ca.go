@@ -2,9 +2,7 @@ package pki
 
 import (
 	"context"
-	"fmt"
 
-	"github.com/hashicorp/vault/helper/certutil"
 	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -17,7 +15,7 @@ func pathConfigCA(b *backend) *framework.Path {
 			"pem_bundle": &framework.FieldSchema{
 				Type: framework.TypeString,
 				Description: `PEM-format, concatenated unencrypted
-secret key and certificate.`,
+secret keys and CA certificates.`,
 			},
 		},
 
@@ -37,67 +35,50 @@ func (b *backend) pathCAWrite(ctx context.Context, req *logical.Request, data *f
 		return logical.ErrorResponse("'pem_bundle' was empty"), nil
 	}
 
-	parsedBundle, err := certutil.ParsePEMBundle(pemBundle)
+	if err := b.migrateLegacyCABundle(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	b.issuersLock.Lock()
+	importedIssuers, importedKeys, err := importPEMBundle(ctx, req.Storage, pemBundle)
+	b.issuersLock.Unlock()
 	if err != nil {
 		switch err.(type) {
-		case errutil.InternalError:
-			return nil, err
-		default:
+		case errutil.UserError:
 			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
 		}
 	}
 
-	if parsedBundle.PrivateKey == nil ||
-		parsedBundle.PrivateKeyType == certutil.UnknownPrivateKey {
-		return logical.ErrorResponse("private key not found in the PEM bundle"), nil
-	}
-
-	if parsedBundle.Certificate == nil {
-		return logical.ErrorResponse("no certificate found in the PEM bundle"), nil
-	}
-
-	if !parsedBundle.Certificate.IsCA {
-		return logical.ErrorResponse("the given certificate is not marked for CA use and cannot be used with this backend"), nil
-	}
-
-	cb, err := parsedBundle.ToCertBundle()
-	if err != nil {
-		return nil, fmt.Errorf("error converting raw values into cert bundle: %s", err)
-	}
-
-	entry, err := logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
+	if err := buildCRL(ctx, b, req); err != nil {
 		return nil, err
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location, plus a fresh CRL
-	entry.Key = "ca"
-	entry.Value = parsedBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		return nil, err
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"imported_issuers": importedIssuers,
+			"imported_keys":    importedKeys,
+		},
 	}
-
-	err = buildCRL(ctx, b, req)
-
-	return nil, err
+	if len(importedIssuers) == 0 && len(importedKeys) == 0 {
+		resp.AddWarning("all the certificates and keys of the PEM bundle were already present")
+	}
+	return resp, nil
 }
 
 const pathConfigCAHelpSyn = `
-Set the CA certificate and private key used for generated credentials.
+Import CA certificates and private keys used for generated credentials.
 `
 
 const pathConfigCAHelpDesc = `
-This sets the CA information used for credentials generated by this
-by this mount. This must be a PEM-format, concatenated unencrypted
-secret key and certificate.
+This imports CA certificates and private keys used for credentials
+generated by this mount. This must be a PEM-format, concatenated list of
+unencrypted secret keys and CA certificates. Each certificate becomes an
+issuer, which can sign if its key is part of the bundle or was imported
+before. The first imported issuer becomes the default issuer.
 
-For security reasons, the secret key cannot be retrieved later.
+For security reasons, the secret keys cannot be retrieved later.
 `
 
 const pathConfigCAGenerateHelpSyn = `
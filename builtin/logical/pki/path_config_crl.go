@@ -11,7 +11,17 @@ import (
 
 // CRLConfig holds basic CRL configuration information
 type crlConfig struct {
-	Expiry string `json:"expiry" mapstructure:"expiry" structs:"expiry"`
+	Expiry                 string `json:"expiry" mapstructure:"expiry" structs:"expiry"`
+	AutoRebuild            bool   `json:"auto_rebuild" mapstructure:"auto_rebuild" structs:"auto_rebuild"`
+	AutoRebuildGracePeriod string `json:"auto_rebuild_grace_period" mapstructure:"auto_rebuild_grace_period" structs:"auto_rebuild_grace_period"`
+	EnableDelta            bool   `json:"enable_delta" mapstructure:"enable_delta" structs:"enable_delta"`
+	DeltaRebuildInterval   string `json:"delta_rebuild_interval" mapstructure:"delta_rebuild_interval" structs:"delta_rebuild_interval"`
+}
+
+var defaultCRLConfig = crlConfig{
+	Expiry:                 "72h",
+	AutoRebuildGracePeriod: "12h",
+	DeltaRebuildInterval:   "15m",
 }
 
 func pathConfigCRL(b *backend) *framework.Path {
@@ -22,7 +32,36 @@ func pathConfigCRL(b *backend) *framework.Path {
 				Type: framework.TypeString,
 				Description: `The amount of time the generated CRL should be
 valid; defaults to 72 hours`,
-				Default: "72h",
+				Default: defaultCRLConfig.Expiry,
+			},
+
+			"auto_rebuild": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the CRLs are rebuilt in the
+background before they expire instead of on
+every revocation; defaults to false`,
+			},
+
+			"auto_rebuild_grace_period": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `How long before the expiration of the CRLs
+they are rebuilt when auto_rebuild is set;
+defaults to 12 hours`,
+				Default: defaultCRLConfig.AutoRebuildGracePeriod,
+			},
+
+			"enable_delta": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, delta CRLs listing the certificates
+revoked since the last complete CRL are built;
+requires auto_rebuild. Defaults to false`,
+			},
+
+			"delta_rebuild_interval": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The interval at which the delta CRLs are
+rebuilt; defaults to 15 minutes`,
+				Default: defaultCRLConfig.DeltaRebuildInterval,
 			},
 		},
 
@@ -45,7 +84,8 @@ func (b *backend) CRL(ctx context.Context, s logical.Storage) (*crlConfig, error
 		return nil, nil
 	}
 
-	var result crlConfig
+	// Configurations stored before automatic rebuilding get its defaults
+	result := defaultCRLConfig
 	if err := entry.DecodeJSON(&result); err != nil {
 		return nil, err
 	}
@@ -64,21 +104,63 @@ func (b *backend) pathCRLRead(ctx context.Context, req *logical.Request, data *f
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"expiry": config.Expiry,
+			"expiry":                    config.Expiry,
+			"auto_rebuild":              config.AutoRebuild,
+			"auto_rebuild_grace_period": config.AutoRebuildGracePeriod,
+			"enable_delta":              config.EnableDelta,
+			"delta_rebuild_interval":    config.DeltaRebuildInterval,
 		},
 	}, nil
 }
 
 func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	expiry := d.Get("expiry").(string)
+	config, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &crlConfig{}
+		*config = defaultCRLConfig
+	}
+
+	if expiryRaw, ok := d.GetOk("expiry"); ok {
+		config.Expiry = expiryRaw.(string)
+	}
+	if autoRebuildRaw, ok := d.GetOk("auto_rebuild"); ok {
+		config.AutoRebuild = autoRebuildRaw.(bool)
+	}
+	if gracePeriodRaw, ok := d.GetOk("auto_rebuild_grace_period"); ok {
+		config.AutoRebuildGracePeriod = gracePeriodRaw.(string)
+	}
+	if enableDeltaRaw, ok := d.GetOk("enable_delta"); ok {
+		config.EnableDelta = enableDeltaRaw.(bool)
+	}
+	if intervalRaw, ok := d.GetOk("delta_rebuild_interval"); ok {
+		config.DeltaRebuildInterval = intervalRaw.(string)
+	}
 
-	_, err := time.ParseDuration(expiry)
+	expiry, err := time.ParseDuration(config.Expiry)
 	if err != nil {
 		return logical.ErrorResponse(fmt.Sprintf("Given expiry could not be decoded: %s", err)), nil
 	}
-
-	config := &crlConfig{
-		Expiry: expiry,
+	gracePeriod, err := time.ParseDuration(config.AutoRebuildGracePeriod)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Given auto_rebuild_grace_period could not be decoded: %s", err)), nil
+	}
+	interval, err := time.ParseDuration(config.DeltaRebuildInterval)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Given delta_rebuild_interval could not be decoded: %s", err)), nil
+	}
+	if config.AutoRebuild && (gracePeriod <= 0 || gracePeriod >= expiry) {
+		return logical.ErrorResponse("auto_rebuild_grace_period must be positive and shorter than expiry"), nil
+	}
+	if config.EnableDelta {
+		if !config.AutoRebuild {
+			return logical.ErrorResponse("enable_delta requires auto_rebuild"), nil
+		}
+		if interval <= 0 || interval >= expiry {
+			return logical.ErrorResponse("delta_rebuild_interval must be positive and shorter than expiry"), nil
+		}
 	}
 
 	entry, err := logical.StorageEntryJSON("config/crl", config)
@@ -94,9 +176,16 @@ func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *fra
 }
 
 const pathConfigCRLHelpSyn = `
-Configure the CRL expiration.
+Configure the CRL expiration and rebuilding.
 `
 
 const pathConfigCRLHelpDesc = `
 This endpoint allows configuration of the CRL lifetime.
+
+By default the CRLs are rebuilt on every revocation. With "auto_rebuild" set,
+revocations no longer rebuild the CRLs; they are instead rebuilt in the
+background when they are within "auto_rebuild_grace_period" of their
+expiration. Revocations then only appear on the CRLs at the next rebuild,
+unless "enable_delta" is set: delta CRLs listing the certificates revoked since
+the last complete CRLs are then rebuilt every "delta_rebuild_interval".
 `
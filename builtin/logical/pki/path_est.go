@@ -0,0 +1,561 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fullsailor/pkcs7"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// estLabelRegex matches the labels of the EST endpoints, which select a role
+var estLabelRegex = regexp.MustCompile(`^\w(([\w-.]+)?\w)?$`)
+
+// estConfig holds the configuration of the EST (RFC 7030) endpoints
+type estConfig struct {
+	Enabled     bool              `json:"enabled" mapstructure:"enabled" structs:"enabled"`
+	DefaultRole string            `json:"default_role" mapstructure:"default_role" structs:"default_role"`
+	LabelToRole map[string]string `json:"label_to_role" mapstructure:"label_to_role" structs:"label_to_role"`
+
+	// BasicAuthUsers maps the usernames of EST clients authenticating with
+	// HTTP basic auth to bcrypt hashes of their passwords
+	BasicAuthUsers map[string][]byte `json:"basic_auth_users" mapstructure:"basic_auth_users" structs:"basic_auth_users"`
+}
+
+func pathConfigEST(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/est",
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `Whether the EST endpoints are enabled; defaults to false`,
+			},
+
+			"default_role": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The role used by the EST endpoints without
+a label.`,
+			},
+
+			"label_to_role": &framework.FieldSchema{
+				Type: framework.TypeKVPairs,
+				Description: `A map of EST labels to the roles used by the
+EST endpoints with that label.`,
+			},
+
+			"basic_auth_users": &framework.FieldSchema{
+				Type: framework.TypeKVPairs,
+				Description: `A map of usernames to the passwords of the
+EST clients authenticating with HTTP basic auth.
+Replaces the current users.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathESTConfigRead,
+			logical.UpdateOperation: b.pathESTConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigESTHelpSyn,
+		HelpDescription: pathConfigESTHelpDesc,
+	}
+}
+
+func estPattern(operation string) string {
+	return "est/(" + framework.GenericNameRegex("label") + "/)?" + operation
+}
+
+func pathESTCACerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: estPattern("cacerts"),
+		Fields: map[string]*framework.FieldSchema{
+			"label": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `The label selecting the role`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathESTCACerts,
+		},
+
+		HelpSynopsis:    pathESTCACertsHelpSyn,
+		HelpDescription: pathESTCACertsHelpDesc,
+	}
+}
+
+func pathESTSimpleEnroll(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: estPattern("simpleenroll"),
+		Fields: map[string]*framework.FieldSchema{
+			"label": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `The label selecting the role`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathESTSimpleEnroll,
+		},
+
+		HelpSynopsis:    pathESTSimpleEnrollHelpSyn,
+		HelpDescription: pathESTSimpleEnrollHelpDesc,
+	}
+}
+
+func pathESTSimpleReenroll(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: estPattern("simplereenroll"),
+		Fields: map[string]*framework.FieldSchema{
+			"label": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `The label selecting the role`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathESTSimpleReenroll,
+		},
+
+		HelpSynopsis:    pathESTSimpleReenrollHelpSyn,
+		HelpDescription: pathESTSimpleReenrollHelpDesc,
+	}
+}
+
+func (b *backend) estConfig(ctx context.Context, s logical.Storage) (*estConfig, error) {
+	entry, err := s.Get(ctx, "config/est")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return &estConfig{}, nil
+	}
+
+	var result estConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathESTConfigRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.estConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	labelToRole := config.LabelToRole
+	if labelToRole == nil {
+		labelToRole = map[string]string{}
+	}
+
+	// The password hashes are not returned
+	users := make([]string, 0, len(config.BasicAuthUsers))
+	for username := range config.BasicAuthUsers {
+		users = append(users, username)
+	}
+	sort.Strings(users)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":          config.Enabled,
+			"default_role":     config.DefaultRole,
+			"label_to_role":    labelToRole,
+			"basic_auth_users": users,
+		},
+	}, nil
+}
+
+func (b *backend) pathESTConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.estConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabledRaw, ok := d.GetOk("enabled"); ok {
+		config.Enabled = enabledRaw.(bool)
+	}
+	if defaultRoleRaw, ok := d.GetOk("default_role"); ok {
+		config.DefaultRole = defaultRoleRaw.(string)
+	}
+	if labelToRoleRaw, ok := d.GetOk("label_to_role"); ok {
+		config.LabelToRole = labelToRoleRaw.(map[string]string)
+	}
+	if usersRaw, ok := d.GetOk("basic_auth_users"); ok {
+		users := usersRaw.(map[string]string)
+		config.BasicAuthUsers = make(map[string][]byte, len(users))
+		for username, password := range users {
+			if username == "" || strings.Contains(username, ":") || password == "" {
+				return logical.ErrorResponse(fmt.Sprintf("invalid basic auth user %q", username)), nil
+			}
+			hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+			if err != nil {
+				return nil, err
+			}
+			config.BasicAuthUsers[username] = hash
+		}
+	}
+
+	roles := make([]string, 0, len(config.LabelToRole)+1)
+	if config.DefaultRole != "" {
+		roles = append(roles, config.DefaultRole)
+	}
+	for label, role := range config.LabelToRole {
+		if !estLabelRegex.MatchString(label) {
+			return logical.ErrorResponse(fmt.Sprintf("invalid label %q", label)), nil
+		}
+		roles = append(roles, role)
+	}
+	for _, roleName := range roles {
+		role, err := b.getRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", roleName)), nil
+		}
+	}
+	if config.Enabled && len(roles) == 0 {
+		return logical.ErrorResponse("default_role or label_to_role must be set to enable EST"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("config/est", config)
+	if err != nil {
+		return nil, err
+	}
+	err = req.Storage.Put(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// estRole returns the role selected by the label of the request
+func (b *backend) estRole(ctx context.Context, req *logical.Request, data *framework.FieldData) (*roleEntry, error) {
+	config, err := b.estConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if !config.Enabled {
+		return nil, errutil.UserError{Err: "EST is not enabled"}
+	}
+
+	roleName := config.DefaultRole
+	if label := data.Get("label").(string); label != "" {
+		roleName = config.LabelToRole[label]
+	}
+	if roleName == "" {
+		return nil, errutil.UserError{Err: "no role is configured for this EST label"}
+	}
+
+	role, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("unknown role: %s", roleName)}
+	}
+	return role, nil
+}
+
+func (b *backend) pathESTCACerts(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	role, err := b.estRole(ctx, req, data)
+	if err != nil {
+		return estErrorResponse(err)
+	}
+
+	_, caInfo, err := b.fetchIssuerCAInfo(ctx, req.Storage, role.IssuerRef)
+	if err != nil {
+		return estErrorResponse(err)
+	}
+
+	certs := append([]byte{}, caInfo.CertificateBytes...)
+	for _, chainCert := range caInfo.CAChain {
+		if bytes.Equal(chainCert.Bytes, caInfo.CertificateBytes) {
+			continue
+		}
+		certs = append(certs, chainCert.Bytes...)
+	}
+
+	return estCertsOnlyResponse(certs, "application/pkcs7-mime")
+}
+
+// pathESTSimpleEnroll signs the CSR of an EST client authenticated with the
+// HTTP basic auth credentials of one of the configured users, or with a TLS
+// client certificate issued by this mount. The EST endpoints are
+// unauthenticated, since EST clients don't send Vault tokens, so the
+// credentials are checked here.
+func (b *backend) pathESTSimpleEnroll(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	role, err := b.estRole(ctx, req, data)
+	if err != nil {
+		return estErrorResponse(err)
+	}
+
+	config, err := b.estConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if username, password, ok := estBasicAuth(req); ok {
+		hash, ok := config.BasicAuthUsers[username]
+		if !ok || bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+			return estUnauthorizedResponse()
+		}
+	} else if _, err := b.estClientCertificate(ctx, req); err != nil {
+		return estUnauthorizedResponse()
+	}
+
+	csrBytes, _, err := estParseCSR(req)
+	if err != nil {
+		return estErrorResponse(err)
+	}
+
+	return b.estEnroll(ctx, req, role, csrBytes)
+}
+
+// pathESTSimpleReenroll renews the TLS client certificate of the request. Per
+// RFC 7030, the CSR must request the same subject and subject alternative
+// names as the certificate being renewed.
+func (b *backend) pathESTSimpleReenroll(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	role, err := b.estRole(ctx, req, data)
+	if err != nil {
+		return estErrorResponse(err)
+	}
+
+	csrBytes, csr, err := estParseCSR(req)
+	if err != nil {
+		return estErrorResponse(err)
+	}
+
+	// The certificate being renewed authenticates the client
+	current, err := b.estClientCertificate(ctx, req)
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			b.Logger().Debug("EST re-enrollment denied", "error", err)
+			return estUnauthorizedResponse()
+		}
+		return nil, err
+	}
+
+	if csr.Subject.String() != current.Subject.String() ||
+		!estSameNames(csr.DNSNames, current.DNSNames) ||
+		!estSameNames(csr.EmailAddresses, current.EmailAddresses) ||
+		!estSameIPs(csr, current) {
+		return logical.ErrorResponse("the CSR must request the subject and subject alternative names of the certificate being renewed"), nil
+	}
+
+	return b.estEnroll(ctx, req, role, csrBytes)
+}
+
+// estClientCertificate returns the TLS client certificate of the request if
+// it was issued by this mount and is neither expired nor revoked
+func (b *backend) estClientCertificate(ctx context.Context, req *logical.Request) (*x509.Certificate, error) {
+	if req.Connection == nil || req.Connection.ConnState == nil || len(req.Connection.ConnState.PeerCertificates) == 0 {
+		return nil, errutil.UserError{Err: "no TLS client certificate"}
+	}
+	cert := req.Connection.ConnState.PeerCertificates[0]
+
+	serial := normalizeSerial(certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":"))
+	certEntry, err := req.Storage.Get(ctx, "certs/"+serial)
+	if err != nil {
+		return nil, err
+	}
+	if certEntry == nil || !bytes.Equal(certEntry.Value, cert.Raw) {
+		return nil, errutil.UserError{Err: "the TLS client certificate was not issued by this mount"}
+	}
+	if time.Now().After(cert.NotAfter) {
+		return nil, errutil.UserError{Err: "the TLS client certificate has expired"}
+	}
+	revokedEntry, err := req.Storage.Get(ctx, "revoked/"+serial)
+	if err != nil {
+		return nil, err
+	}
+	if revokedEntry != nil {
+		return nil, errutil.UserError{Err: "the TLS client certificate has been revoked"}
+	}
+
+	return cert, nil
+}
+
+// estBasicAuth returns the HTTP basic auth credentials of the request. The
+// Authorization header only reaches the backend if the mount is tuned to
+// pass it through.
+func estBasicAuth(req *logical.Request) (string, string, bool) {
+	if req.Headers == nil {
+		return "", "", false
+	}
+	return (&http.Request{Header: http.Header(req.Headers)}).BasicAuth()
+}
+
+func (b *backend) estEnroll(ctx context.Context, req *logical.Request, role *roleEntry, csrBytes []byte) (*logical.Response, error) {
+	parsedBundle, _, err := b.signCSR(ctx, req, role, csrBytes)
+	if err != nil {
+		return estErrorResponse(err)
+	}
+
+	return estCertsOnlyResponse(parsedBundle.CertificateBytes, "application/pkcs7-mime; smime-type=certs-only")
+}
+
+// estParseCSR returns the PKCS#10 request of the body of an enrollment
+// request, which is base64-encoded DER
+func estParseCSR(req *logical.Request) ([]byte, *x509.CertificateRequest, error) {
+	body, ok := req.Data[logical.HTTPRawBody].([]byte)
+	if !ok {
+		return nil, nil, errutil.UserError{Err: "the request must have a content type of application/pkcs10"}
+	}
+
+	encoded := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		}
+		return r
+	}, string(body))
+	csrBytes, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, errutil.UserError{Err: fmt.Sprintf("failed to decode CSR: %v", err)}
+	}
+
+	csr, err := x509.ParseCertificateRequest(csrBytes)
+	if err != nil {
+		return nil, nil, errutil.UserError{Err: fmt.Sprintf("failed to parse CSR: %v", err)}
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, nil, errutil.UserError{Err: fmt.Sprintf("invalid CSR signature: %v", err)}
+	}
+
+	return csrBytes, csr, nil
+}
+
+// estSameNames returns whether both lists hold the same names, ignoring
+// case, order and duplicates
+func estSameNames(a, b []string) bool {
+	set := func(names []string) map[string]bool {
+		result := make(map[string]bool, len(names))
+		for _, name := range names {
+			result[strings.ToLower(name)] = true
+		}
+		return result
+	}
+	setA, setB := set(a), set(b)
+	if len(setA) != len(setB) {
+		return false
+	}
+	for name := range setA {
+		if !setB[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func estSameIPs(csr *x509.CertificateRequest, cert *x509.Certificate) bool {
+	var csrIPs, certIPs []string
+	for _, ip := range csr.IPAddresses {
+		csrIPs = append(csrIPs, ip.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		certIPs = append(certIPs, ip.String())
+	}
+	return estSameNames(csrIPs, certIPs)
+}
+
+// estCertsOnlyResponse returns the given DER-encoded certificates as a
+// base64-encoded PKCS#7 certs-only message
+func estCertsOnlyResponse(certs []byte, contentType string) (*logical.Response, error) {
+	p7, err := pkcs7.DegenerateCertificate(certs)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding PKCS#7 response: %v", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: contentType,
+			logical.HTTPRawBody:     []byte(base64.StdEncoding.EncodeToString(p7)),
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+		Headers: map[string][]string{
+			"Content-Transfer-Encoding": []string{"base64"},
+		},
+	}, nil
+}
+
+// estUnauthorizedResponse asks the EST client for credentials
+func estUnauthorizedResponse() (*logical.Response, error) {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "text/plain",
+			logical.HTTPRawBody:     []byte("permission denied"),
+			logical.HTTPStatusCode:  http.StatusUnauthorized,
+		},
+		Headers: map[string][]string{
+			"WWW-Authenticate": []string{`Basic realm="vault"`},
+		},
+	}, nil
+}
+
+func estErrorResponse(err error) (*logical.Response, error) {
+	switch err.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), nil
+	default:
+		return nil, err
+	}
+}
+
+const pathConfigESTHelpSyn = `
+Configure the EST server.
+`
+
+const pathConfigESTHelpDesc = `
+This endpoint enables or disables the EST (RFC 7030) endpoints of this mount,
+maps EST labels to the roles used to issue certificates, and sets the users
+of EST clients authenticating with HTTP basic auth. EST clients may also
+authenticate with a TLS client certificate issued by this mount.
+`
+
+const pathESTCACertsHelpSyn = `
+Fetch the CA certificates for EST clients.
+`
+
+const pathESTCACertsHelpDesc = `
+This endpoint returns the certificate of the issuer of the role selected by
+the label, along with its chain, as a base64-encoded PKCS#7 certs-only
+message.
+`
+
+const pathESTSimpleEnrollHelpSyn = `
+Enroll an EST client.
+`
+
+const pathESTSimpleEnrollHelpDesc = `
+This endpoint signs a base64-encoded PKCS#10 request, sent with a content type
+of application/pkcs10, using the role selected by the label. Clients
+authenticate with the HTTP basic auth credentials of a configured user or with
+a TLS client certificate issued by this mount. The certificate is returned as
+a base64-encoded PKCS#7 certs-only message.
+`
+
+const pathESTSimpleReenrollHelpSyn = `
+Re-enroll an EST client.
+`
+
+const pathESTSimpleReenrollHelpDesc = `
+This endpoint renews the certificate presented as TLS client certificate,
+which must have been issued by this mount and must not be expired or
+revoked. The PKCS#10 request must ask for the same subject and subject
+alternative names as that certificate.
+`
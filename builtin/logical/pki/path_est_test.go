@@ -0,0 +1,200 @@
+package pki
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/fullsailor/pkcs7"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_EST(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	// headers are sent with every request, as if the mount passed the
+	// Authorization header through
+	var headers map[string][]string
+	request := func(op logical.Operation, path string, data map[string]interface{}, conn *logical.Connection) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation:  op,
+			Path:       path,
+			Storage:    storage,
+			Data:       data,
+			Connection: conn,
+			Headers:    headers,
+		})
+	}
+	basicAuth := func(username, password string) map[string][]string {
+		return map[string][]string{
+			"Authorization": []string{"Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))},
+		}
+	}
+	mustBeUnauthorized := func(resp *logical.Response, err error) {
+		t.Helper()
+		if err != nil || resp == nil || resp.Data[logical.HTTPStatusCode] != http.StatusUnauthorized {
+			t.Fatalf("expected an unauthorized response, got: err: %v resp: %#v", err, resp)
+		}
+		if resp.Headers["WWW-Authenticate"] == nil {
+			t.Fatalf("expected a challenge, got: %#v", resp.Headers)
+		}
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}, conn *logical.Connection) *logical.Response {
+		resp, err := request(op, path, data, conn)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	parseCerts := func(resp *logical.Response) []*x509.Certificate {
+		p7Bytes, err := base64.StdEncoding.DecodeString(string(resp.Data[logical.HTTPRawBody].([]byte)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p7, err := pkcs7.Parse(p7Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p7.Certificates
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr := func(commonName string, dnsNames ...string) []byte {
+		der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: commonName},
+			DNSNames: dnsNames,
+		}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []byte(base64.StdEncoding.EncodeToString(der))
+	}
+
+	mustRequest(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "example.com",
+		"ttl":         "48h",
+	}, nil)
+	mustRequest(logical.UpdateOperation, "roles/devices", map[string]interface{}{
+		"allowed_domains":  "devices.example.com",
+		"allow_subdomains": true,
+		"key_type":         "ec",
+		"key_bits":         256,
+		"ttl":              "1h",
+	}, nil)
+
+	// Roles must exist, and EST is disabled until configured
+	resp, err := request(logical.UpdateOperation, "config/est", map[string]interface{}{
+		"enabled":      true,
+		"default_role": "missing",
+	}, nil)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got: err: %v resp: %#v", err, resp)
+	}
+	resp, err = request(logical.ReadOperation, "est/cacerts", nil, nil)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got: err: %v resp: %#v", err, resp)
+	}
+	mustRequest(logical.UpdateOperation, "config/est", map[string]interface{}{
+		"enabled":          true,
+		"default_role":     "devices",
+		"label_to_role":    map[string]interface{}{"routers": "devices"},
+		"basic_auth_users": map[string]interface{}{"device": "secret"},
+	}, nil)
+	resp = mustRequest(logical.ReadOperation, "config/est", nil, nil)
+	if users := resp.Data["basic_auth_users"].([]string); len(users) != 1 || users[0] != "device" || resp.Data["label_to_role"].(map[string]string)["routers"] != "devices" {
+		t.Fatalf("bad config: %#v", resp.Data)
+	}
+
+	for _, path := range []string{"est/cacerts", "est/routers/cacerts"} {
+		certs := parseCerts(mustRequest(logical.ReadOperation, path, nil, nil))
+		if len(certs) != 1 || certs[0].Subject.CommonName != "example.com" {
+			t.Fatalf("bad CA certificates from %s: %#v", path, certs)
+		}
+	}
+	resp, err = request(logical.ReadOperation, "est/unknown/cacerts", nil, nil)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for an unknown label, got: err: %v resp: %#v", err, resp)
+	}
+
+	// Enrollment requires the credentials of a configured user
+	mustBeUnauthorized(request(logical.UpdateOperation, "est/simpleenroll", map[string]interface{}{
+		logical.HTTPRawBody: csr("r1.devices.example.com", "r1.devices.example.com"),
+	}, nil))
+	headers = basicAuth("device", "wrong")
+	mustBeUnauthorized(request(logical.UpdateOperation, "est/simpleenroll", map[string]interface{}{
+		logical.HTTPRawBody: csr("r1.devices.example.com", "r1.devices.example.com"),
+	}, nil))
+	headers = basicAuth("unknown", "secret")
+	mustBeUnauthorized(request(logical.UpdateOperation, "est/simpleenroll", map[string]interface{}{
+		logical.HTTPRawBody: csr("r1.devices.example.com", "r1.devices.example.com"),
+	}, nil))
+	headers = basicAuth("device", "secret")
+
+	// Enrollment takes the names from the CSR, subject to the role
+	resp, err = request(logical.UpdateOperation, "est/simpleenroll", map[string]interface{}{
+		logical.HTTPRawBody: csr("www.example.com"),
+	}, nil)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a name not allowed, got: err: %v resp: %#v", err, resp)
+	}
+	resp = mustRequest(logical.UpdateOperation, "est/routers/simpleenroll", map[string]interface{}{
+		logical.HTTPRawBody: csr("r1.devices.example.com", "r1.devices.example.com"),
+	}, nil)
+	if resp.Headers["Content-Transfer-Encoding"][0] != "base64" {
+		t.Fatalf("bad headers: %#v", resp.Headers)
+	}
+	certs := parseCerts(resp)
+	if len(certs) != 1 || certs[0].Subject.CommonName != "r1.devices.example.com" {
+		t.Fatalf("bad certificates: %#v", certs)
+	}
+	issued := certs[0]
+
+	// A certificate issued by the mount authenticates enrollment too
+	headers = nil
+	conn := &logical.Connection{
+		ConnState: &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{issued},
+		},
+	}
+	mustRequest(logical.UpdateOperation, "est/simpleenroll", map[string]interface{}{
+		logical.HTTPRawBody: csr("r3.devices.example.com", "r3.devices.example.com"),
+	}, conn)
+
+	// Re-enrollment renews the TLS client certificate
+	mustBeUnauthorized(request(logical.UpdateOperation, "est/simplereenroll", map[string]interface{}{
+		logical.HTTPRawBody: csr("r1.devices.example.com", "r1.devices.example.com"),
+	}, nil))
+	resp, err = request(logical.UpdateOperation, "est/simplereenroll", map[string]interface{}{
+		logical.HTTPRawBody: csr("r2.devices.example.com", "r2.devices.example.com"),
+	}, conn)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for different names, got: err: %v resp: %#v", err, resp)
+	}
+	certs = parseCerts(mustRequest(logical.UpdateOperation, "est/simplereenroll", map[string]interface{}{
+		logical.HTTPRawBody: csr("r1.devices.example.com", "r1.devices.example.com"),
+	}, conn))
+	if len(certs) != 1 || certs[0].SerialNumber.Cmp(issued.SerialNumber) == 0 {
+		t.Fatalf("expected a new certificate, got: %#v", certs)
+	}
+
+	// Revoked certificates cannot be renewed
+	mustRequest(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": certutil.GetHexFormatted(issued.SerialNumber.Bytes(), ":"),
+	}, nil)
+	mustBeUnauthorized(request(logical.UpdateOperation, "est/simplereenroll", map[string]interface{}{
+		logical.HTTPRawBody: csr("r1.devices.example.com", "r1.devices.example.com"),
+	}, conn))
+	mustBeUnauthorized(request(logical.UpdateOperation, "est/simpleenroll", map[string]interface{}{
+		logical.HTTPRawBody: csr("r1.devices.example.com", "r1.devices.example.com"),
+	}, conn))
+}
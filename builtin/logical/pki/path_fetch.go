@@ -42,7 +42,7 @@ func pathFetchCAChain(b *backend) *framework.Path {
 // Returns the CRL in raw format
 func pathFetchCRL(b *backend) *framework.Path {
 	return &framework.Path{
-		Pattern: `crl(/pem)?`,
+		Pattern: `crl(/pem|/delta(/pem)?)?`,
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation: b.pathFetchRead,
@@ -78,7 +78,7 @@ hyphen-separated octal`,
 // This returns the CRL in a non-raw format
 func pathFetchCRLViaCertPath(b *backend) *framework.Path {
 	return &framework.Path{
-		Pattern: `cert/crl`,
+		Pattern: `cert/(crl|delta-crl)`,
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation: b.pathFetchRead,
@@ -145,9 +145,18 @@ func (b *backend) pathFetchRead(ctx context.Context, req *logical.Request, data
 		if req.Path == "crl/pem" {
 			pemType = "X509 CRL"
 		}
+	case req.Path == "crl/delta" || req.Path == "crl/delta/pem":
+		serial = "delta-crl"
+		contentType = "application/pkix-crl"
+		if req.Path == "crl/delta/pem" {
+			pemType = "X509 CRL"
+		}
 	case req.Path == "cert/crl":
 		serial = "crl"
 		pemType = "X509 CRL"
+	case req.Path == "cert/delta-crl":
+		serial = "delta-crl"
+		pemType = "X509 CRL"
 	default:
 		serial = data.Get("serial").(string)
 		pemType = "CERTIFICATE"
@@ -158,7 +167,7 @@ func (b *backend) pathFetchRead(ctx context.Context, req *logical.Request, data
 	}
 
 	if serial == "ca_chain" {
-		caInfo, err := fetchCAInfo(ctx, req)
+		_, caInfo, err := b.fetchIssuerCAInfo(ctx, req.Storage, defaultRef)
 		switch err.(type) {
 		case errutil.UserError:
 			response = logical.ErrorResponse(err.Error())
@@ -270,7 +279,7 @@ Fetch a CA, CRL, CA Chain, or non-revoked certificate.
 const pathFetchHelpDesc = `
 This allows certificates to be fetched. If using the fetch/ prefix any non-revoked certificate can be fetched.
 
-Using "ca" or "crl" as the value fetches the appropriate information in DER encoding. Add "/pem" to either to get PEM encoding.
+Using "ca" or "crl" as the value fetches the appropriate information in DER encoding. Add "/pem" to either to get PEM encoding. Using "crl/delta" fetches the delta CRL, if enabled.
 
 Using "ca_chain" as the value fetches the certificate authority trust chain in PEM encoding.
 `
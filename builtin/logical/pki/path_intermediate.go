@@ -44,9 +44,10 @@ func pathSetSignedIntermediate(b *backend) *framework.Path {
 			"certificate": &framework.FieldSchema{
 				Type: framework.TypeString,
 				Description: `PEM-format certificate. This must be a CA
-certificate with a public key matching the
+certificate with a public key matching a
 previously-generated key from the generation
-endpoint.`,
+endpoint. Issuing certificates may follow it
+to be imported as well.`,
 			},
 		},
 
@@ -69,11 +70,33 @@ func (b *backend) pathGenerateIntermediate(ctx context.Context, req *logical.Req
 		return errorResp, nil
 	}
 
+	if err := b.migrateLegacyCABundle(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	existingKey, parsedKey, err := b.getExistingKey(ctx, req, data, role)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	keyName := data.Get("key_name").(string)
+	if existingKey == nil {
+		if err := checkRefName(ctx, req.Storage, keyPrefix, "", keyName); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
 	var resp *logical.Response
 	input := &dataBundle{
-		role:    role,
-		req:     req,
-		apiData: data,
+		role:        role,
+		req:         req,
+		apiData:     data,
+		existingKey: parsedKey,
 	}
 	parsedBundle, err := generateIntermediateCSR(b, input)
 	if err != nil {
@@ -125,18 +148,17 @@ func (b *backend) pathGenerateIntermediate(ctx context.Context, req *logical.Req
 		}
 	}
 
-	cb := &certutil.CertBundle{}
-	cb.PrivateKey = csrb.PrivateKey
-	cb.PrivateKeyType = csrb.PrivateKeyType
-
-	entry, err := logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		return nil, err
+	// Store the key until the signed certificate is set
+	key := existingKey
+	if key == nil {
+		b.issuersLock.Lock()
+		key, _, err = importKey(ctx, req.Storage, csrb.PrivateKey, keyName)
+		b.issuersLock.Unlock()
+		if err != nil {
+			return nil, err
+		}
 	}
+	resp.Data["key_id"] = key.ID
 
 	return resp, nil
 }
@@ -162,78 +184,72 @@ func (b *backend) pathSetSignedIntermediate(ctx context.Context, req *logical.Re
 		return logical.ErrorResponse("supplied certificate could not be successfully parsed"), nil
 	}
 
-	cb := &certutil.CertBundle{}
-	entry, err := req.Storage.Get(ctx, "config/ca_bundle")
-	if err != nil {
-		return nil, err
-	}
-	if entry == nil {
-		return logical.ErrorResponse("could not find any existing entry with a private key"), nil
+	if !inputBundle.Certificate.IsCA {
+		return logical.ErrorResponse("the given certificate is not marked for CA use and cannot be used with this backend"), nil
 	}
 
-	err = entry.DecodeJSON(cb)
-	if err != nil {
+	if err := b.migrateLegacyCABundle(ctx, req.Storage); err != nil {
 		return nil, err
 	}
 
-	if len(cb.PrivateKey) == 0 || cb.PrivateKeyType == "" {
-		return logical.ErrorResponse("could not find an existing private key"), nil
-	}
-
-	parsedCB, err := cb.ToParsedCertBundle()
+	// The certificate must match one of the stored keys
+	keyIDs, err := req.Storage.List(ctx, keyPrefix)
 	if err != nil {
 		return nil, err
 	}
-	if parsedCB.PrivateKey == nil {
-		return nil, fmt.Errorf("saved key could not be parsed successfully")
-	}
-
-	inputBundle.PrivateKey = parsedCB.PrivateKey
-	inputBundle.PrivateKeyType = parsedCB.PrivateKeyType
-	inputBundle.PrivateKeyBytes = parsedCB.PrivateKeyBytes
-
-	if !inputBundle.Certificate.IsCA {
-		return logical.ErrorResponse("the given certificate is not marked for CA use and cannot be used with this backend"), nil
-	}
-
-	if err := inputBundle.Verify(); err != nil {
-		return nil, fmt.Errorf("verification of parsed bundle failed: %s", err)
+	var keyFound bool
+	for _, id := range keyIDs {
+		key, err := fetchKey(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			continue
+		}
+		parsedKey, err := key.parse()
+		if err != nil {
+			return nil, err
+		}
+		if equal, _ := certutil.ComparePublicKeys(inputBundle.Certificate.PublicKey, parsedKey.PrivateKey.Public()); equal {
+			keyFound = true
+			break
+		}
 	}
-
-	cb, err = inputBundle.ToCertBundle()
-	if err != nil {
-		return nil, fmt.Errorf("error converting raw values into cert bundle: %s", err)
+	if !keyFound {
+		return logical.ErrorResponse("could not find an existing private key matching the certificate"), nil
 	}
 
-	entry, err = logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(ctx, entry)
+	// Import the certificate along with the rest of its chain
+	b.issuersLock.Lock()
+	importedIssuers, _, err := importPEMBundle(ctx, req.Storage, cert)
+	b.issuersLock.Unlock()
 	if err != nil {
-		return nil, err
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
 	}
 
-	entry.Key = "certs/" + normalizeSerial(cb.SerialNumber)
-	entry.Value = inputBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
+	err = req.Storage.Put(ctx, &logical.StorageEntry{
+		Key:   "certs/" + normalizeSerial(certutil.GetHexFormatted(inputBundle.Certificate.SerialNumber.Bytes(), ":")),
+		Value: inputBundle.CertificateBytes,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location
-	entry.Key = "ca"
-	entry.Value = inputBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
+	// Build a fresh CRL
+	if err := buildCRL(ctx, b, req); err != nil {
 		return nil, err
 	}
 
-	// Build a fresh CRL
-	err = buildCRL(ctx, b, req)
-
-	return nil, err
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"imported_issuers": importedIssuers,
+		},
+	}, nil
 }
 
 const pathGenerateIntermediateHelpSyn = `
@@ -3,6 +3,7 @@ package pki
 import (
 	"context"
 	"encoding/base64"
+	"encoding/pem"
 	"fmt"
 	"time"
 
@@ -75,6 +76,13 @@ taken verbatim from the CSR, except for
 basic constraints.`,
 	}
 
+	ret.Fields["issuer_ref"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Reference, by name or ID, to the issuer to
+sign with. Defaults to the issuer of the role if
+one is given, otherwise to the default issuer.`,
+	}
+
 	return ret
 }
 
@@ -135,6 +143,7 @@ func (b *backend) pathSignVerbatim(ctx context.Context, req *logical.Request, da
 		UseCSRCommonName: true,
 		UseCSRSANs:       true,
 		GenerateLease:    new(bool),
+		IssuerRef:        defaultRef,
 	}
 
 	if role != nil {
@@ -160,6 +169,11 @@ func (b *backend) pathSignVerbatim(ctx context.Context, req *logical.Request, da
 			return logical.ErrorResponse(fmt.Sprintf("requested ttl of %s is greater than max ttl of %s", entry.TTL, entry.MaxTTL)), nil
 		}
 		entry.NoStore = role.NoStore
+		entry.IssuerRef = role.IssuerRef
+	}
+
+	if issuerRef := data.Get("issuer_ref").(string); issuerRef != "" {
+		entry.IssuerRef = issuerRef
 	}
 
 	*entry.GenerateLease = false
@@ -178,7 +192,7 @@ func (b *backend) pathIssueSignCert(ctx context.Context, req *logical.Request, d
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	signingBundle, caErr := fetchCAInfo(ctx, b, req, role.IssuerRef)
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -313,6 +327,63 @@ func (b *backend) pathIssueSignCert(ctx context.Context, req *logical.Request, d
 	return resp, nil
 }
 
+// signCSR signs a DER-encoded CSR using the role, for protocols such as ACME
+// and EST which carry no API parameters. Names and SANs are taken from the
+// CSR, subject to the role restrictions, and the certificate is stored
+// unless the role disables it. Problems with the CSR are returned as
+// errutil.UserError.
+func (b *backend) signCSR(ctx context.Context, req *logical.Request, role *roleEntry, csrBytes []byte) (*certutil.ParsedCertBundle, *caInfoBundle, error) {
+	signingBundle, err := fetchCAInfo(ctx, b, req, role.IssuerRef)
+	if err != nil {
+		return nil, nil, errwrap.Wrapf("error fetching CA certificate: {{err}}", err)
+	}
+
+	csrRole := *role
+	csrRole.UseCSRCommonName = true
+	csrRole.UseCSRSANs = true
+
+	fields := addNonCACommonFields(map[string]*framework.FieldSchema{})
+	fields["csr"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+	}
+	apiData := &framework.FieldData{
+		Raw: map[string]interface{}{
+			"csr": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE REQUEST",
+				Bytes: csrBytes,
+			})),
+			"format": "pem",
+		},
+		Schema: fields,
+	}
+
+	parsedBundle, err := signCert(b, &dataBundle{
+		req:           req,
+		apiData:       apiData,
+		role:          &csrRole,
+		signingBundle: signingBundle,
+	}, false, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !csrRole.NoStore {
+		cb, err := parsedBundle.ToCertBundle()
+		if err != nil {
+			return nil, nil, errwrap.Wrapf("error converting raw cert bundle to cert bundle: {{err}}", err)
+		}
+		err = req.Storage.Put(ctx, &logical.StorageEntry{
+			Key:   "certs/" + normalizeSerial(cb.SerialNumber),
+			Value: parsedBundle.CertificateBytes,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to store certificate locally: %v", err)
+		}
+	}
+
+	return parsedBundle, signingBundle, nil
+}
+
 const pathIssueHelpSyn = `
 Request a certificate using a certain role with the provided details.
 `
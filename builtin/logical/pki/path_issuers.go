@@ -0,0 +1,398 @@
+package pki
+
+import (
+	"context"
+	"encoding/pem"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathIssuerList,
+		},
+
+		HelpSynopsis:    pathListIssuersHelpSyn,
+		HelpDescription: pathListIssuersHelpDesc,
+	}
+}
+
+func pathIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex("issuer_ref"),
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to the issuer, by name or ID, or "default".`,
+			},
+
+			"issuer_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Name of the issuer.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathIssuerRead,
+			logical.UpdateOperation: b.pathIssuerWrite,
+			logical.DeleteOperation: b.pathIssuerDelete,
+		},
+
+		HelpSynopsis:    pathIssuerHelpSyn,
+		HelpDescription: pathIssuerHelpDesc,
+	}
+}
+
+func pathConfigIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/issuers",
+		Fields: map[string]*framework.FieldSchema{
+			"default": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference, by name or ID, to the default issuer.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigIssuersRead,
+			logical.UpdateOperation: b.pathConfigIssuersWrite,
+		},
+
+		HelpSynopsis:    pathConfigIssuersHelpSyn,
+		HelpDescription: pathConfigIssuersHelpDesc,
+	}
+}
+
+// Returns an issuer's certificate, chain or CRL without authentication
+func pathFetchIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "cert/issuer/" + framework.GenericNameRegex("issuer_ref") + `(/der|/pem|/crl|/crl/pem|/crl/delta|/crl/delta/pem)?`,
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to the issuer, by name or ID, or "default".`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchIssuerRead,
+		},
+
+		HelpSynopsis:    pathFetchIssuerHelpSyn,
+		HelpDescription: pathFetchIssuerHelpDesc,
+	}
+}
+
+func issuerErrorResponse(err error) (*logical.Response, error) {
+	switch err.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), nil
+	default:
+		return nil, err
+	}
+}
+
+func (b *backend) pathIssuerList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := b.migrateLegacyCABundle(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	config, err := fetchIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := listSorted(ctx, req.Storage, issuerPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := make(map[string]interface{}, len(ids))
+	for _, id := range ids {
+		issuer, err := fetchIssuer(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+		if issuer == nil {
+			continue
+		}
+		keyInfo[id] = map[string]interface{}{
+			"issuer_name": issuer.Name,
+			"key_id":      issuer.KeyID,
+			"is_default":  id == config.DefaultIssuerID,
+		}
+	}
+
+	return logical.ListResponseWithInfo(ids, keyInfo), nil
+}
+
+func (b *backend) pathIssuerRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuer, caInfo, err := b.fetchIssuerCAInfo(ctx, req.Storage, data.Get("issuer_ref").(string))
+	if err != nil {
+		return issuerErrorResponse(err)
+	}
+
+	caChain := []string{issuer.Certificate}
+	for _, ca := range caInfo.CAChain {
+		caChain = append(caChain, strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: ca.Bytes,
+		}))))
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id":     issuer.ID,
+			"issuer_name":   issuer.Name,
+			"key_id":        issuer.KeyID,
+			"certificate":   issuer.Certificate,
+			"ca_chain":      caChain,
+			"serial_number": issuer.SerialNumber,
+		},
+	}, nil
+}
+
+func (b *backend) pathIssuerWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := b.resolveIssuerRef(ctx, req.Storage, data.Get("issuer_ref").(string))
+	if err != nil {
+		return issuerErrorResponse(err)
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	issuer, err := fetchIssuer(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return logical.ErrorResponse("issuer not found"), nil
+	}
+
+	if nameRaw, ok := data.GetOk("issuer_name"); ok {
+		name := nameRaw.(string)
+		if err := checkRefName(ctx, req.Storage, issuerPrefix, id, name); err != nil {
+			return issuerErrorResponse(err)
+		}
+		issuer.Name = name
+	}
+
+	if err := writeIssuer(ctx, req.Storage, issuer); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathIssuerDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := b.resolveIssuerRef(ctx, req.Storage, data.Get("issuer_ref").(string))
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			// Deleting a missing issuer is not an error
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	b.issuersLock.Lock()
+	config, err := fetchIssuersConfig(ctx, req.Storage)
+	if err == nil {
+		err = req.Storage.Delete(ctx, issuerPrefix+id)
+	}
+	if err == nil {
+		err = req.Storage.Delete(ctx, issuerCRLPrefix+id)
+	}
+	if err == nil {
+		err = req.Storage.Delete(ctx, deltaCRLPrefix+id)
+	}
+	if err == nil && config.DefaultIssuerID == id {
+		err = setDefaultIssuer(ctx, req.Storage, "")
+	}
+	b.issuersLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *logical.Response
+	if config.DefaultIssuerID == id {
+		resp = &logical.Response{}
+		resp.AddWarning("the default issuer was deleted; set a new default issuer with the config/issuers endpoint")
+	} else {
+		b.revokeStorageLock.RLock()
+		defer b.revokeStorageLock.RUnlock()
+		if err := buildCRL(ctx, b, req); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+func (b *backend) pathConfigIssuersRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := b.migrateLegacyCABundle(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	config, err := fetchIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"default": config.DefaultIssuerID,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigIssuersWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ref := data.Get("default").(string)
+	if ref == "" || ref == defaultRef {
+		return logical.ErrorResponse(`"default" must reference an issuer by name or ID`), nil
+	}
+	id, err := b.resolveIssuerRef(ctx, req.Storage, ref)
+	if err != nil {
+		return issuerErrorResponse(err)
+	}
+
+	b.issuersLock.Lock()
+	err = setDefaultIssuer(ctx, req.Storage, id)
+	b.issuersLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// The CRL served by the "crl" endpoints changes along with the default
+	// issuer
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+	if err := buildCRL(ctx, b, req); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathFetchIssuerRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuer, caInfo, err := b.fetchIssuerCAInfo(ctx, req.Storage, data.Get("issuer_ref").(string))
+	if err != nil {
+		return issuerErrorResponse(err)
+	}
+
+	var contentType, pemType string
+	var body []byte
+	switch {
+	case strings.HasSuffix(req.Path, "/crl/delta/pem"), strings.HasSuffix(req.Path, "/crl/delta"):
+		entry, err := req.Storage.Get(ctx, deltaCRLPrefix+issuer.ID)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			body = entry.Value
+		}
+		contentType = "application/pkix-crl"
+		if strings.HasSuffix(req.Path, "/pem") {
+			pemType = "X509 CRL"
+		}
+	case strings.HasSuffix(req.Path, "/crl/pem"), strings.HasSuffix(req.Path, "/crl"):
+		entry, err := req.Storage.Get(ctx, issuerCRLPrefix+issuer.ID)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			body = entry.Value
+		}
+		contentType = "application/pkix-crl"
+		if strings.HasSuffix(req.Path, "/pem") {
+			pemType = "X509 CRL"
+		}
+	case strings.HasSuffix(req.Path, "/der"), strings.HasSuffix(req.Path, "/pem"):
+		body = caInfo.CertificateBytes
+		contentType = "application/pkix-cert"
+		if strings.HasSuffix(req.Path, "/pem") {
+			pemType = "CERTIFICATE"
+		}
+	default:
+		var caChain []string
+		for _, ca := range caInfo.GetCAChain() {
+			caChain = append(caChain, strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE",
+				Bytes: ca.Bytes,
+			}))))
+		}
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"issuer_id":   issuer.ID,
+				"issuer_name": issuer.Name,
+				"certificate": issuer.Certificate,
+				"ca_chain":    caChain,
+			},
+		}, nil
+	}
+
+	if pemType != "" && len(body) > 0 {
+		body = []byte(strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+			Type:  pemType,
+			Bytes: body,
+		}))))
+	}
+
+	statusCode := 200
+	if len(body) == 0 {
+		statusCode = 204
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: contentType,
+			logical.HTTPRawBody:     body,
+			logical.HTTPStatusCode:  statusCode,
+		},
+	}, nil
+}
+
+const pathListIssuersHelpSyn = `
+List the issuers of this mount.
+`
+
+const pathListIssuersHelpDesc = `
+This lists the IDs of the issuers of this mount, along with their names,
+the IDs of their keys and whether they are the default issuer.
+`
+
+const pathIssuerHelpSyn = `
+Read, rename or delete an issuer.
+`
+
+const pathIssuerHelpDesc = `
+An issuer is a CA certificate imported or generated on this mount, which
+can be referenced by its ID or name. Issuers whose private key is present
+can sign certificates and CRLs; the other ones are only used to build CA
+chains. Deleting an issuer does not delete its key.
+`
+
+const pathConfigIssuersHelpSyn = `
+Read or set the default issuer.
+`
+
+const pathConfigIssuersHelpDesc = `
+The default issuer signs certificates for roles and requests that do not
+reference another issuer, and is the one served by the "ca", "ca_chain" and
+"crl" endpoints.
+`
+
+const pathFetchIssuerHelpSyn = `
+Fetch an issuer's certificate, CA chain or CRL.
+`
+
+const pathFetchIssuerHelpDesc = `
+This returns the certificate and CA chain of the issuer. Add "/der" or
+"/pem" to get the raw certificate, "/crl" or "/crl/pem" to get the issuer's
+CRL, or "/crl/delta" or "/crl/delta/pem" to get its delta CRL.
+`
@@ -0,0 +1,212 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_MultipleIssuers(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	parseCert := func(data interface{}) *x509.Certificate {
+		block, _ := pem.Decode([]byte(data.(string)))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	resp := mustRequest(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "old.example.com",
+		"issuer_name": "old",
+		"key_name":    "old-key",
+		"ttl":         "48h",
+	})
+	oldID := resp.Data["issuer_id"].(string)
+	oldCert := parseCert(resp.Data["certificate"])
+
+	resp = mustRequest(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "new.example.com",
+		"issuer_name": "new",
+		"ttl":         "48h",
+	})
+	newID := resp.Data["issuer_id"].(string)
+	newCert := parseCert(resp.Data["certificate"])
+
+	// Names must be unique
+	resp, err := request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "other.example.com",
+		"issuer_name": "new",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a duplicate name, got: err: %v resp: %#v", err, resp)
+	}
+
+	resp = mustRequest(logical.ListOperation, "issuers", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 2 {
+		t.Fatalf("expected two issuers, got %#v", keys)
+	}
+
+	// The first issuer stays the default one until changed
+	resp = mustRequest(logical.ReadOperation, "config/issuers", nil)
+	if resp.Data["default"] != oldID {
+		t.Fatalf("expected default issuer %s, got %#v", oldID, resp.Data)
+	}
+
+	// Roles sign with their issuer
+	mustRequest(logical.UpdateOperation, "roles/test", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"issuer_ref":       "new",
+		"ttl":              "1h",
+	})
+	resp = mustRequest(logical.UpdateOperation, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	cert := parseCert(resp.Data["certificate"])
+	if err := cert.CheckSignatureFrom(newCert); err != nil {
+		t.Fatalf("expected certificate signed by the new issuer: %v", err)
+	}
+	serial := resp.Data["serial_number"].(string)
+
+	resp, err = request(logical.UpdateOperation, "roles/bad", map[string]interface{}{
+		"issuer_ref": "missing",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a missing issuer, got: err: %v resp: %#v", err, resp)
+	}
+
+	// Revoked certificates only go on the CRL of their issuer
+	mustRequest(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serial,
+	})
+	for ref, expected := range map[string]int{"new": 1, "old": 0} {
+		resp = mustRequest(logical.ReadOperation, "cert/issuer/"+ref+"/crl", nil)
+		crl, err := x509.ParseCRL(resp.Data[logical.HTTPRawBody].([]byte))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(crl.TBSCertList.RevokedCertificates) != expected {
+			t.Fatalf("expected %d revoked certificates on the CRL of %s, got %d", expected, ref, len(crl.TBSCertList.RevokedCertificates))
+		}
+	}
+
+	// Changing the default issuer changes the CA served
+	mustRequest(logical.UpdateOperation, "config/issuers", map[string]interface{}{
+		"default": "new",
+	})
+	resp = mustRequest(logical.ReadOperation, "cert/ca", nil)
+	if !parseCert(resp.Data["certificate"]).Equal(newCert) {
+		t.Fatal("expected the new issuer to be served as the CA")
+	}
+
+	// The old issuer can still be read and renamed
+	mustRequest(logical.UpdateOperation, "issuer/old", map[string]interface{}{
+		"issuer_name": "retired",
+	})
+	resp = mustRequest(logical.ReadOperation, "issuer/retired", nil)
+	if resp.Data["issuer_id"] != oldID || !parseCert(resp.Data["certificate"]).Equal(oldCert) {
+		t.Fatalf("bad issuer: %#v", resp.Data)
+	}
+
+	// Keys in use cannot be deleted, but are once their issuer is gone
+	resp, err = request(logical.DeleteOperation, "key/old-key", nil)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error deleting a key in use, got: err: %v resp: %#v", err, resp)
+	}
+	mustRequest(logical.DeleteOperation, "issuer/retired", nil)
+	mustRequest(logical.DeleteOperation, "key/old-key", nil)
+
+	resp = mustRequest(logical.ListOperation, "issuers", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != newID {
+		t.Fatalf("expected only the new issuer, got %#v", keys)
+	}
+}
+
+func TestPki_LegacyCABundleMigration(t *testing.T) {
+	initTest.Do(setCerts)
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	// Store the CA bundle the way older versions did
+	entry, err := logical.StorageEntryJSON(legacyCABundlePath, &certutil.CertBundle{
+		PrivateKeyType: certutil.RSAPrivateKey,
+		PrivateKey:     rsaCAKey,
+		Certificate:    rsaCACert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := request(logical.ReadOperation, "issuer/default", nil)
+	if resp.Data["certificate"] != rsaCACert || resp.Data["key_id"] == "" {
+		t.Fatalf("bad migrated issuer: %#v", resp.Data)
+	}
+	entry, err = storage.Get(context.Background(), legacyCABundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry != nil {
+		t.Fatal("expected the legacy CA bundle to be removed")
+	}
+
+	// Importing another bundle adds an issuer without changing the default
+	resp = request(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"pem_bundle": ecCAKey + "\n" + ecCACert,
+	})
+	if len(resp.Data["imported_issuers"].([]string)) != 1 || len(resp.Data["imported_keys"].([]string)) != 1 {
+		t.Fatalf("bad import: %#v", resp.Data)
+	}
+	resp = request(logical.ReadOperation, "cert/ca", nil)
+	if resp.Data["certificate"] != rsaCACert {
+		t.Fatal("expected the default issuer to be unchanged")
+	}
+
+	// Importing it again is a no-op
+	resp = request(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"pem_bundle": ecCAKey + "\n" + ecCACert,
+	})
+	if len(resp.Data["imported_issuers"].([]string)) != 0 || len(resp.Warnings) == 0 {
+		t.Fatalf("expected nothing to be imported, got %#v", resp)
+	}
+	resp = request(logical.ListOperation, "keys", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 2 {
+		t.Fatalf("expected two keys, got %#v", keys)
+	}
+}
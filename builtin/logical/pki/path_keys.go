@@ -0,0 +1,175 @@
+package pki
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListKeys(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathKeyList,
+		},
+
+		HelpSynopsis:    pathListKeysHelpSyn,
+		HelpDescription: pathListKeysHelpDesc,
+	}
+}
+
+func pathKey(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "key/" + framework.GenericNameRegex("key_ref"),
+		Fields: map[string]*framework.FieldSchema{
+			"key_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to the key, by name or ID.`,
+			},
+
+			"key_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Name of the key.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathKeyRead,
+			logical.UpdateOperation: b.pathKeyWrite,
+			logical.DeleteOperation: b.pathKeyDelete,
+		},
+
+		HelpSynopsis:    pathKeyHelpSyn,
+		HelpDescription: pathKeyHelpDesc,
+	}
+}
+
+func (b *backend) pathKeyList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := b.migrateLegacyCABundle(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	ids, err := listSorted(ctx, req.Storage, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := make(map[string]interface{}, len(ids))
+	for _, id := range ids {
+		key, err := fetchKey(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			continue
+		}
+		keyInfo[id] = map[string]interface{}{
+			"key_name": key.Name,
+		}
+	}
+
+	return logical.ListResponseWithInfo(ids, keyInfo), nil
+}
+
+func (b *backend) pathKeyRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := b.resolveKeyRef(ctx, req.Storage, data.Get("key_ref").(string))
+	if err != nil {
+		return issuerErrorResponse(err)
+	}
+	key, err := fetchKey(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key_id":   key.ID,
+			"key_name": key.Name,
+			"key_type": string(key.PrivateKeyType),
+		},
+	}, nil
+}
+
+func (b *backend) pathKeyWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := b.resolveKeyRef(ctx, req.Storage, data.Get("key_ref").(string))
+	if err != nil {
+		return issuerErrorResponse(err)
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	key, err := fetchKey(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse("key not found"), nil
+	}
+
+	if nameRaw, ok := data.GetOk("key_name"); ok {
+		name := nameRaw.(string)
+		if err := checkRefName(ctx, req.Storage, keyPrefix, id, name); err != nil {
+			return issuerErrorResponse(err)
+		}
+		key.Name = name
+	}
+
+	if err := writeKey(ctx, req.Storage, key); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// pathKeyDelete deletes a key, which must not be used by any issuer
+func (b *backend) pathKeyDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := b.resolveKeyRef(ctx, req.Storage, data.Get("key_ref").(string))
+	if err != nil {
+		return issuerErrorResponse(err)
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	issuerIDs, err := req.Storage.List(ctx, issuerPrefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, issuerID := range issuerIDs {
+		issuer, err := fetchIssuer(ctx, req.Storage, issuerID)
+		if err != nil {
+			return nil, err
+		}
+		if issuer != nil && issuer.KeyID == id {
+			return logical.ErrorResponse(fmt.Sprintf("key is in use by issuer %s and cannot be deleted", issuerID)), nil
+		}
+	}
+
+	return nil, req.Storage.Delete(ctx, keyPrefix+id)
+}
+
+const pathListKeysHelpSyn = `
+List the keys of this mount.
+`
+
+const pathListKeysHelpDesc = `
+This lists the IDs of the private keys of this mount, along with their
+names.
+`
+
+const pathKeyHelpSyn = `
+Read, rename or delete a key.
+`
+
+const pathKeyHelpDesc = `
+Keys are the private keys of the mount's issuers, which can be referenced
+by their ID or name. The private key itself cannot be read. Keys still in
+use by an issuer cannot be deleted.
+`
@@ -0,0 +1,409 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ocsp"
+)
+
+var (
+	// id-pkix-ocsp-nonce, RFC 6960 section 4.4.1
+	ocspNonceOID = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+
+	// id-pkix-ocsp-basic, RFC 6960 section 4.2.1
+	ocspBasicResponseOID = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+	ocspHashOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+		crypto.SHA1:   asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26},
+		crypto.SHA256: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1},
+		crypto.SHA384: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2},
+		crypto.SHA512: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3},
+	}
+)
+
+// The x/crypto/ocsp package cannot add extensions to the response data, which
+// is where nonces belong, so responses are marshaled with the structures
+// below. Requests are parsed by the library.
+
+type ocspRequestExtensions struct {
+	TBSRequest struct {
+		Version       int           `asn1:"explicit,tag:0,default:0,optional"`
+		RequestorName asn1.RawValue `asn1:"explicit,tag:1,optional"`
+		RequestList   []asn1.RawValue
+		Extensions    []pkix.Extension `asn1:"explicit,tag:2,optional"`
+	}
+}
+
+type ocspResponseASN1 struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    ocspResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+}
+
+type ocspResponseData struct {
+	Version            int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID     asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []ocspSingleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	Good       asn1.Flag       `asn1:"tag:0,optional"`
+	Revoked    ocspRevokedInfo `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag       `asn1:"tag:2,optional"`
+	ThisUpdate time.Time       `asn1:"generalized"`
+	NextUpdate time.Time       `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time `asn1:"generalized"`
+}
+
+func pathOCSP(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "ocsp",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathOCSPPost,
+		},
+
+		HelpSynopsis:    pathOCSPHelpSyn,
+		HelpDescription: pathOCSPHelpDesc,
+	}
+}
+
+func pathOCSPGet(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `ocsp/(?P<req>.+)`,
+
+		Fields: map[string]*framework.FieldSchema{
+			"req": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `The base64-encoded DER OCSP request.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathOCSPGetRead,
+		},
+
+		HelpSynopsis:    pathOCSPHelpSyn,
+		HelpDescription: pathOCSPHelpDesc,
+	}
+}
+
+func (b *backend) pathOCSPGetRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	encoded := data.Get("req").(string)
+
+	// Clients are supposed to URL-encode the request, but some only
+	// base64-encode it, and some use the URL-safe alphabet
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		der, err = base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			return ocspRawResponse(ocsp.MalformedRequestErrorResponse), nil
+		}
+	}
+
+	return b.ocspRespond(ctx, req, der)
+}
+
+func (b *backend) pathOCSPPost(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	der, ok := req.Data[logical.HTTPRawBody].([]byte)
+	if !ok {
+		return ocspRawResponse(ocsp.MalformedRequestErrorResponse), nil
+	}
+
+	return b.ocspRespond(ctx, req, der)
+}
+
+// ocspRespond answers a DER-encoded OCSP request. Per RFC 6960, errors are
+// reported through the OCSP response status rather than the HTTP status.
+func (b *backend) ocspRespond(ctx context.Context, req *logical.Request, der []byte) (*logical.Response, error) {
+	ocspReq, err := ocsp.ParseRequest(der)
+	if err != nil {
+		return ocspRawResponse(ocsp.MalformedRequestErrorResponse), nil
+	}
+	var exts ocspRequestExtensions
+	if _, err := asn1.Unmarshal(der, &exts); err != nil {
+		return ocspRawResponse(ocsp.MalformedRequestErrorResponse), nil
+	}
+
+	hashOID, ok := ocspHashOIDs[ocspReq.HashAlgorithm]
+	if !ok {
+		return ocspRawResponse(ocsp.MalformedRequestErrorResponse), nil
+	}
+
+	// Only certificates issued by one of this mount's issuers can be
+	// answered for
+	signingBundle, nameHash, keyHash, err := b.ocspFindIssuer(ctx, req, ocspReq)
+	if err != nil {
+		b.Logger().Error("error fetching CA certificate for OCSP", "error", err)
+		return ocspRawResponse(ocsp.InternalErrorErrorResponse), nil
+	}
+	if signingBundle == nil {
+		return ocspRawResponse(ocsp.UnauthorizedErrorResponse), nil
+	}
+
+	single, err := b.ocspCertStatus(ctx, req, ocspReq.SerialNumber)
+	if err != nil {
+		b.Logger().Error("error fetching certificate status for OCSP", "error", err)
+		return ocspRawResponse(ocsp.InternalErrorErrorResponse), nil
+	}
+	single.CertID = ocspCertID{
+		HashAlgorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  hashOID,
+			Parameters: asn1.RawValue{Tag: asn1.TagNull},
+		},
+		NameHash:      nameHash,
+		IssuerKeyHash: keyHash,
+		SerialNumber:  ocspReq.SerialNumber,
+	}
+
+	responseData := ocspResponseData{
+		RawResponderID: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        1,
+			IsCompound: true,
+			Bytes:      signingBundle.Certificate.RawSubject,
+		},
+		ProducedAt: time.Now().UTC().Truncate(time.Second),
+		Responses:  []ocspSingleResponse{*single},
+	}
+	for _, ext := range exts.TBSRequest.Extensions {
+		if ext.Id.Equal(ocspNonceOID) {
+			responseData.ResponseExtensions = append(responseData.ResponseExtensions, pkix.Extension{
+				Id:    ocspNonceOID,
+				Value: ext.Value,
+			})
+		}
+	}
+
+	resp, err := ocspSign(responseData, signingBundle.PrivateKey)
+	if err != nil {
+		b.Logger().Error("error signing OCSP response", "error", err)
+		return ocspRawResponse(ocsp.InternalErrorErrorResponse), nil
+	}
+
+	return ocspRawResponse(resp), nil
+}
+
+// ocspFindIssuer returns the signing bundle of the issuer the request is
+// for, along with its hashes, or nil if the request is for another CA
+func (b *backend) ocspFindIssuer(ctx context.Context, req *logical.Request, ocspReq *ocsp.Request) (*caInfoBundle, []byte, []byte, error) {
+	if err := b.migrateLegacyCABundle(ctx, req.Storage); err != nil {
+		return nil, nil, nil, err
+	}
+
+	ids, err := listSorted(ctx, req.Storage, issuerPrefix)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, id := range ids {
+		issuer, err := fetchIssuer(ctx, req.Storage, id)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if issuer == nil || issuer.KeyID == "" {
+			continue
+		}
+		cert, _, err := issuer.parseCertificate()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		nameHash, keyHash, err := ocspIssuerHashes(cert, ocspReq.HashAlgorithm)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !bytes.Equal(nameHash, ocspReq.IssuerNameHash) || !bytes.Equal(keyHash, ocspReq.IssuerKeyHash) {
+			continue
+		}
+
+		signingBundle, err := fetchCAInfo(ctx, b, req, id)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return signingBundle, nameHash, keyHash, nil
+	}
+
+	return nil, nil, nil, nil
+}
+
+// ocspCertStatus looks up the status of a serial number in the certificate
+// and revocation stores
+func (b *backend) ocspCertStatus(ctx context.Context, req *logical.Request, serialNumber *big.Int) (*ocspSingleResponse, error) {
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+
+	serial := certutil.GetHexFormatted(serialNumber.Bytes(), ":")
+
+	crlLifetime := b.crlLifetime
+	crlInfo, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if crlInfo != nil && crlInfo.Expiry != "" {
+		crlLifetime, err = time.ParseDuration(crlInfo.Expiry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	single := &ocspSingleResponse{
+		ThisUpdate: now,
+		NextUpdate: now.Add(crlLifetime),
+	}
+
+	revEntry, err := fetchCertBySerial(ctx, req, "revoked/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if revEntry != nil {
+		var revInfo revocationInfo
+		if err := revEntry.DecodeJSON(&revInfo); err != nil {
+			return nil, fmt.Errorf("error decoding revocation entry for serial %s: %s", serial, err)
+		}
+		revokedAt := revInfo.RevocationTimeUTC
+		if revokedAt.IsZero() {
+			revokedAt = time.Unix(revInfo.RevocationTime, 0).UTC()
+		}
+		single.Revoked = ocspRevokedInfo{
+			RevocationTime: revokedAt,
+		}
+		return single, nil
+	}
+
+	certEntry, err := fetchCertBySerial(ctx, req, "certs/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if certEntry != nil {
+		single.Good = true
+	} else {
+		single.Unknown = true
+	}
+
+	return single, nil
+}
+
+// ocspIssuerHashes returns the hashes of the issuer name and public key used
+// to identify the issuer in OCSP requests
+func ocspIssuerHashes(issuer *x509.Certificate, hash crypto.Hash) ([]byte, []byte, error) {
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return nil, nil, err
+	}
+
+	h := hash.New()
+	h.Write(issuer.RawSubject)
+	nameHash := h.Sum(nil)
+
+	h.Reset()
+	h.Write(publicKeyInfo.PublicKey.RightAlign())
+	keyHash := h.Sum(nil)
+
+	return nameHash, keyHash, nil
+}
+
+// ocspSign signs the response data and wraps it into a successful OCSP
+// response
+func ocspSign(responseData ocspResponseData, signer crypto.Signer) ([]byte, error) {
+	sigAlg, err := sha256SignatureAlgorithm(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	tbs, err := asn1.Marshal(responseData)
+	if err != nil {
+		return nil, err
+	}
+	digest := crypto.SHA256.New()
+	digest.Write(tbs)
+	signature, err := signer.Sign(rand.Reader, digest.Sum(nil), crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	basic, err := asn1.Marshal(ocspBasicResponse{
+		TBSResponseData:    responseData,
+		SignatureAlgorithm: sigAlg,
+		Signature: asn1.BitString{
+			Bytes:     signature,
+			BitLength: 8 * len(signature),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(ocspResponseASN1{
+		Status: asn1.Enumerated(ocsp.Success),
+		Response: ocspResponseBytes{
+			ResponseType: ocspBasicResponseOID,
+			Response:     basic,
+		},
+	})
+}
+
+func ocspRawResponse(body []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/ocsp-response",
+			logical.HTTPRawBody:     body,
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+	}
+}
+
+const pathOCSPHelpSyn = `
+Query the revocation status of a certificate using OCSP.
+`
+
+const pathOCSPHelpDesc = `
+This endpoint answers RFC 6960 OCSP requests for certificates issued by this
+backend's CA, using the stored revocation entries. Requests can be sent as a
+DER-encoded POST body with a content type of "application/ocsp-request", or
+as a base64-encoded GET path suffix. Request nonces are echoed back in the
+signed response.
+
+To advertise this endpoint in issued certificates, add its URL to the
+"ocsp_servers" parameter of the "config/urls" endpoint.
+`
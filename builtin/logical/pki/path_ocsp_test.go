@@ -0,0 +1,171 @@
+package pki
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/crypto/ocsp"
+)
+
+type testOCSPRequest struct {
+	TBSRequest struct {
+		RequestList []struct {
+			Cert ocspCertID
+		}
+		Extensions []pkix.Extension `asn1:"explicit,tag:2,optional"`
+	}
+}
+
+func testOCSPRequestDER(t *testing.T, cert, issuer *x509.Certificate, nonce []byte) []byte {
+	nameHash, keyHash, err := ocspIssuerHashes(issuer, crypto.SHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req testOCSPRequest
+	req.TBSRequest.RequestList = []struct{ Cert ocspCertID }{
+		{
+			Cert: ocspCertID{
+				HashAlgorithm: pkix.AlgorithmIdentifier{
+					Algorithm:  ocspHashOIDs[crypto.SHA1],
+					Parameters: asn1.RawValue{Tag: asn1.TagNull},
+				},
+				NameHash:      nameHash,
+				IssuerKeyHash: keyHash,
+				SerialNumber:  cert.SerialNumber,
+			},
+		},
+	}
+	if nonce != nil {
+		value, err := asn1.Marshal(nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.TBSRequest.Extensions = []pkix.Extension{
+			{Id: ocspNonceOID, Value: value},
+		}
+	}
+
+	der, err := asn1.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestPki_OCSP(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: err: %v resp: %#v", err, resp)
+		}
+		return resp
+	}
+	parseCert := func(data interface{}) *x509.Certificate {
+		block, _ := pem.Decode([]byte(data.(string)))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	resp := request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "example.com",
+		"ttl":         "48h",
+	})
+	issuer := parseCert(resp.Data["certificate"])
+
+	request(logical.UpdateOperation, "roles/test", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+	})
+	resp = request(logical.UpdateOperation, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	cert := parseCert(resp.Data["certificate"])
+	serial := resp.Data["serial_number"].(string)
+
+	checkStatus := func(resp *logical.Response, expected int) *ocsp.Response {
+		if resp.Data[logical.HTTPStatusCode] != http.StatusOK || resp.Data[logical.HTTPContentType] != "application/ocsp-response" {
+			t.Fatalf("bad response: %#v", resp.Data)
+		}
+		parsed, err := ocsp.ParseResponseForCert(resp.Data[logical.HTTPRawBody].([]byte), cert, issuer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Status != expected {
+			t.Fatalf("expected status %d, got %d", expected, parsed.Status)
+		}
+		return parsed
+	}
+
+	// POST with a nonce, which must be echoed in the response
+	nonce := []byte("0123456789abcdef")
+	resp = request(logical.UpdateOperation, "ocsp", map[string]interface{}{
+		logical.HTTPRawBody: testOCSPRequestDER(t, cert, issuer, nonce),
+	})
+	checkStatus(resp, ocsp.Good)
+
+	var outer ocspResponseASN1
+	if _, err := asn1.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &outer); err != nil {
+		t.Fatal(err)
+	}
+	var basic ocspBasicResponse
+	if _, err := asn1.Unmarshal(outer.Response.Response, &basic); err != nil {
+		t.Fatal(err)
+	}
+	exts := basic.TBSResponseData.ResponseExtensions
+	if len(exts) != 1 || !exts[0].Id.Equal(ocspNonceOID) {
+		t.Fatalf("expected a nonce extension, got %#v", exts)
+	}
+	var echoed []byte
+	if _, err := asn1.Unmarshal(exts[0].Value, &echoed); err != nil || string(echoed) != string(nonce) {
+		t.Fatalf("bad nonce: %q, err: %v", echoed, err)
+	}
+
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serial,
+	})
+
+	// GET without a nonce
+	resp = request(logical.ReadOperation, "ocsp/"+base64.StdEncoding.EncodeToString(testOCSPRequestDER(t, cert, issuer, nil)), nil)
+	parsed := checkStatus(resp, ocsp.Revoked)
+	if parsed.RevokedAt.IsZero() {
+		t.Fatal("expected a revocation time")
+	}
+
+	// Garbage is reported through the OCSP status
+	resp = request(logical.UpdateOperation, "ocsp", map[string]interface{}{
+		logical.HTTPRawBody: []byte("not an ocsp request"),
+	})
+	if string(resp.Data[logical.HTTPRawBody].([]byte)) != string(ocsp.MalformedRequestErrorResponse) {
+		t.Fatalf("expected a malformed request response, got %#v", resp.Data)
+	}
+
+	// Certificates from another issuer are not answered for
+	other := *cert
+	other.RawSubject = []byte("other")
+	resp = request(logical.UpdateOperation, "ocsp", map[string]interface{}{
+		logical.HTTPRawBody: testOCSPRequestDER(t, cert, &other, nil),
+	})
+	if string(resp.Data[logical.HTTPRawBody].([]byte)) != string(ocsp.UnauthorizedErrorResponse) {
+		t.Fatalf("expected an unauthorized response, got %#v", resp.Data)
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -260,6 +261,14 @@ for "generate_lease".`,
 				Type:        framework.TypeBool,
 				Description: `Mark Basic Constraints valid when issuing non-CA certificates.`,
 			},
+
+			"issuer_ref": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: defaultRef,
+				Description: `Reference, by name or ID, to the issuer that
+signs the certificates of this role. Defaults to the
+default issuer.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -334,6 +343,12 @@ func (b *backend) getRole(ctx context.Context, s logical.Storage, n string) (*ro
 		modified = true
 	}
 
+	// Roles created before issuers could be selected use the default one
+	if result.IssuerRef == "" {
+		result.IssuerRef = defaultRef
+		modified = true
+	}
+
 	// Upgrade key usages
 	if result.KeyUsageOld != "" {
 		result.KeyUsage = strings.Split(result.KeyUsageOld, ",")
@@ -458,6 +473,7 @@ func (b *backend) pathRoleCreate(ctx context.Context, req *logical.Request, data
 		RequireCN:                     data.Get("require_cn").(bool),
 		PolicyIdentifiers:             data.Get("policy_identifiers").([]string),
 		BasicConstraintsValidForNonCA: data.Get("basic_constraints_valid_for_non_ca").(bool),
+		IssuerRef:                     data.Get("issuer_ref").(string),
 	}
 
 	otherSANs := data.Get("allowed_other_sans").([]string)
@@ -509,6 +525,17 @@ func (b *backend) pathRoleCreate(ctx context.Context, req *logical.Request, data
 		}
 	}
 
+	if entry.IssuerRef != defaultRef {
+		if _, err := b.resolveIssuerRef(ctx, req.Storage, entry.IssuerRef); err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				return logical.ErrorResponse(err.Error()), nil
+			default:
+				return nil, err
+			}
+		}
+	}
+
 	// Store it
 	jsonEntry, err := logical.StorageEntryJSON("role/"+name, entry)
 	if err != nil {
@@ -592,6 +619,7 @@ type roleEntry struct {
 	AllowedOtherSANs              []string `json:"allowed_other_sans" mapstructure:"allowed_other_sans"`
 	PolicyIdentifiers             []string `json:"policy_identifiers" mapstructure:"policy_identifiers"`
 	BasicConstraintsValidForNonCA bool     `json:"basic_constraints_valid_for_non_ca" mapstructure:"basic_constraints_valid_for_non_ca"`
+	IssuerRef                     string   `json:"issuer_ref" mapstructure:"issuer_ref"`
 
 	// Used internally for signing intermediates
 	AllowExpirationPastCA bool
@@ -630,6 +658,7 @@ func (r *roleEntry) ToResponseData() map[string]interface{} {
 		"allowed_other_sans":                 r.AllowedOtherSANs,
 		"policy_identifiers":                 r.PolicyIdentifiers,
 		"basic_constraints_valid_for_non_ca": r.BasicConstraintsValidForNonCA,
+		"issuer_ref":                         r.IssuerRef,
 	}
 	if r.MaxPathLength != nil {
 		responseData["max_path_length"] = r.MaxPathLength
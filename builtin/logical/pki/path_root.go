@@ -32,6 +32,10 @@ func pathGenerateRoot(b *backend) *framework.Path {
 	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
 	ret.Fields = addCAKeyGenerationFields(ret.Fields)
 	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields["issuer_name"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Optional name for the generated issuer.`,
+	}
 
 	return ret
 }
@@ -65,6 +69,7 @@ func pathSignIntermediate(b *backend) *framework.Path {
 
 	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
 	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields = addIssuerRefField(ret.Fields)
 
 	ret.Fields["csr"] = &framework.FieldSchema{
 		Type:        framework.TypeString,
@@ -108,23 +113,43 @@ func pathSignSelfIssued(b *backend) *framework.Path {
 		HelpDescription: pathSignSelfIssuedHelpDesc,
 	}
 
+	ret.Fields = addIssuerRefField(ret.Fields)
+
 	return ret
 }
 
+// pathCADeleteRoot deletes all the issuers and keys of the mount
 func (b *backend) pathCADeleteRoot(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return nil, req.Storage.Delete(ctx, "config/ca_bundle")
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	for _, prefix := range []string{issuerPrefix, keyPrefix, issuerCRLPrefix, deltaCRLPrefix} {
+		ids, err := req.Storage.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if err := req.Storage.Delete(ctx, prefix+id); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, path := range []string{issuersConfigPath, legacyCABundlePath, "ca", "crl", deltaCRLPath, crlStatePath} {
+		if err := req.Storage.Delete(ctx, path); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
 }
 
 func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	var err error
 
-	entry, err := req.Storage.Get(ctx, "config/ca_bundle")
-	if err != nil {
+	if err := b.migrateLegacyCABundle(ctx, req.Storage); err != nil {
 		return nil, err
 	}
-	if entry != nil {
-		return nil, nil
-	}
 
 	exported, format, role, errorResp := b.getGenerationParams(data)
 	if errorResp != nil {
@@ -137,10 +162,32 @@ func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request,
 		role.MaxPathLength = &maxPathLength
 	}
 
+	existingKey, parsedKey, err := b.getExistingKey(ctx, req, data, role)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	issuerName := data.Get("issuer_name").(string)
+	keyName := data.Get("key_name").(string)
+	if err := checkRefName(ctx, req.Storage, issuerPrefix, "", issuerName); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if existingKey == nil {
+		if err := checkRefName(ctx, req.Storage, keyPrefix, "", keyName); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
 	input := &dataBundle{
-		req:     req,
-		apiData: data,
-		role:    role,
+		req:         req,
+		apiData:     data,
+		role:        role,
+		existingKey: parsedKey,
 	}
 	parsedBundle, err := generateCert(ctx, b, input, true)
 	if err != nil {
@@ -200,15 +247,22 @@ func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request,
 		}
 	}
 
-	// Store it as the CA bundle
-	entry, err = logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
+	// Store the key and the certificate as a new issuer
+	b.issuersLock.Lock()
+	key := existingKey
+	if key == nil {
+		key, _, err = importKey(ctx, req.Storage, cb.PrivateKey, keyName)
+	}
+	var issuer *issuerEntry
+	if err == nil {
+		issuer, _, err = importIssuer(ctx, req.Storage, cb.Certificate, issuerName)
 	}
-	err = req.Storage.Put(ctx, entry)
+	b.issuersLock.Unlock()
 	if err != nil {
 		return nil, err
 	}
+	resp.Data["issuer_id"] = issuer.ID
+	resp.Data["key_id"] = key.ID
 
 	// Also store it as just the certificate identified by serial number, so it
 	// can be revoked
@@ -220,15 +274,6 @@ func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request,
 		return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location
-	entry.Key = "ca"
-	entry.Value = parsedBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		return nil, err
-	}
-
 	// Build a fresh CRL
 	err = buildCRL(ctx, b, req)
 	if err != nil {
@@ -274,7 +319,7 @@ func (b *backend) pathCASignIntermediate(ctx context.Context, req *logical.Reque
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	signingBundle, caErr := fetchCAInfo(ctx, b, req, data.Get("issuer_ref").(string))
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -401,7 +446,7 @@ func (b *backend) pathCASignSelfIssued(ctx context.Context, req *logical.Request
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	signingBundle, caErr := fetchCAInfo(ctx, b, req, data.Get("issuer_ref").(string))
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -453,7 +498,7 @@ See the API documentation for more information.
 `
 
 const pathDeleteRootHelpSyn = `
-Deletes the root CA key to allow a new one to be generated.
+Deletes all the issuers and keys of the mount.
 `
 
 const pathDeleteRootHelpDesc = `
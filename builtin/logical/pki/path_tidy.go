@@ -4,65 +4,198 @@ import (
 	"context"
 	"crypto/x509"
 	"fmt"
+	"sync/atomic"
 	"time"
 
+	metrics "github.com/armon/go-metrics"
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
 
+const autoTidyConfigPath = "config/auto-tidy"
+
+// tidyConfig describes what to tidy; when stored, it configures the
+// automatic tidying
+type tidyConfig struct {
+	Enabled        bool          `json:"enabled"`
+	Interval       time.Duration `json:"interval_duration"`
+	CertStore      bool          `json:"tidy_cert_store"`
+	RevocationList bool          `json:"tidy_revocation_list"`
+	SafetyBuffer   time.Duration `json:"safety_buffer"`
+}
+
+var defaultTidyConfig = tidyConfig{
+	Interval:     12 * time.Hour,
+	SafetyBuffer: 72 * time.Hour,
+}
+
+const (
+	tidyStatusInactive = "Inactive"
+	tidyStatusRunning  = "Running"
+	tidyStatusFinished = "Finished"
+	tidyStatusError    = "Error"
+)
+
+// tidyStatus is the outcome of the last tidy operation, manual or automatic
+type tidyStatus struct {
+	State                   string
+	Err                     error
+	Automatic               bool
+	TimeStarted             time.Time
+	TimeFinished            time.Time
+	Config                  tidyConfig
+	CertStoreDeletedCount   int
+	RevokedCertDeletedCount int
+	CertStoreCount          int
+	RevokedCertCount        int
+}
+
 func pathTidy(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "tidy",
-		Fields: map[string]*framework.FieldSchema{
-			"tidy_cert_store": &framework.FieldSchema{
-				Type: framework.TypeBool,
-				Description: `Set to true to enable tidying up
+		Fields:  addTidyFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathTidyWrite,
+		},
+
+		HelpSynopsis:    pathTidyHelpSyn,
+		HelpDescription: pathTidyHelpDesc,
+	}
+}
+
+func pathConfigAutoTidy(b *backend) *framework.Path {
+	fields := addTidyFields(map[string]*framework.FieldSchema{})
+	fields["enabled"] = &framework.FieldSchema{
+		Type:        framework.TypeBool,
+		Description: `Set to true to enable automatic tidying.`,
+	}
+	fields["interval_duration"] = &framework.FieldSchema{
+		Type: framework.TypeDurationSecond,
+		Description: `The interval between automatic tidy operations.
+Defaults to 12 hours.`,
+		Default: int(defaultTidyConfig.Interval / time.Second),
+	}
+
+	return &framework.Path{
+		Pattern: "config/auto-tidy",
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigAutoTidyRead,
+			logical.UpdateOperation: b.pathConfigAutoTidyWrite,
+		},
+
+		HelpSynopsis:    pathConfigAutoTidyHelpSyn,
+		HelpDescription: pathConfigAutoTidyHelpDesc,
+	}
+}
+
+func pathTidyStatus(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "tidy-status",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathTidyStatusRead,
+		},
+
+		HelpSynopsis:    pathTidyStatusHelpSyn,
+		HelpDescription: pathTidyStatusHelpDesc,
+	}
+}
+
+func addTidyFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["tidy_cert_store"] = &framework.FieldSchema{
+		Type: framework.TypeBool,
+		Description: `Set to true to enable tidying up
 the certificate store`,
-				Default: false,
-			},
+		Default: false,
+	}
 
-			"tidy_revocation_list": &framework.FieldSchema{
-				Type: framework.TypeBool,
-				Description: `Set to true to enable tidying up
+	fields["tidy_revocation_list"] = &framework.FieldSchema{
+		Type: framework.TypeBool,
+		Description: `Set to true to enable tidying up
 the revocation list`,
-				Default: false,
-			},
+		Default: false,
+	}
 
-			"safety_buffer": &framework.FieldSchema{
-				Type: framework.TypeDurationSecond,
-				Description: `The amount of extra time that must have passed
+	fields["safety_buffer"] = &framework.FieldSchema{
+		Type: framework.TypeDurationSecond,
+		Description: `The amount of extra time that must have passed
 beyond certificate expiration before it is removed
 from the backend storage and/or revocation list.
 Defaults to 72 hours.`,
-				Default: 259200, //72h, but TypeDurationSecond currently requires defaults to be int
-			},
-		},
+		Default: 259200, //72h, but TypeDurationSecond currently requires defaults to be int
+	}
 
-		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.UpdateOperation: b.pathTidyWrite,
-		},
+	return fields
+}
 
-		HelpSynopsis:    pathTidyHelpSyn,
-		HelpDescription: pathTidyHelpDesc,
+func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := &tidyConfig{
+		CertStore:      d.Get("tidy_cert_store").(bool),
+		RevocationList: d.Get("tidy_revocation_list").(bool),
+		SafetyBuffer:   time.Duration(d.Get("safety_buffer").(int)) * time.Second,
 	}
+
+	if !atomic.CompareAndSwapUint32(&b.tidyRunning, 0, 1) {
+		return logical.ErrorResponse("a tidy operation is already in progress"), nil
+	}
+	defer atomic.StoreUint32(&b.tidyRunning, 0)
+
+	return b.tidy(ctx, req, config, false)
 }
 
-func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	safetyBuffer := d.Get("safety_buffer").(int)
-	tidyCertStore := d.Get("tidy_cert_store").(bool)
-	tidyRevocationList := d.Get("tidy_revocation_list").(bool)
+// tidy removes the expired certificates and revocation entries and records
+// the outcome in the tidy status. The caller must have set tidyRunning.
+func (b *backend) tidy(ctx context.Context, req *logical.Request, config *tidyConfig, automatic bool) (*logical.Response, error) {
+	status := &tidyStatus{
+		State:       tidyStatusRunning,
+		Automatic:   automatic,
+		TimeStarted: time.Now(),
+		Config:      *config,
+	}
+	b.tidyStatusLock.Lock()
+	b.tidyStatus = status
+	b.tidyStatusLock.Unlock()
+
+	resp, err := b.doTidy(ctx, req, config, status)
+
+	b.tidyStatusLock.Lock()
+	status.TimeFinished = time.Now()
+	if err != nil {
+		status.State = tidyStatusError
+		status.Err = err
+	} else {
+		status.State = tidyStatusFinished
+	}
+	b.tidyStatusLock.Unlock()
+
+	metrics.IncrCounter([]string{"secrets", "pki", "tidy", "cert_store_deleted_count"}, float32(status.CertStoreDeletedCount))
+	metrics.IncrCounter([]string{"secrets", "pki", "tidy", "revoked_cert_deleted_count"}, float32(status.RevokedCertDeletedCount))
+	if err == nil {
+		metrics.SetGauge([]string{"secrets", "pki", "total_certificates_stored"}, float32(status.CertStoreCount))
+		metrics.SetGauge([]string{"secrets", "pki", "total_revoked_certificates_stored"}, float32(status.RevokedCertCount))
+	}
+
+	return resp, err
+}
 
-	bufferDuration := time.Duration(safetyBuffer) * time.Second
+func (b *backend) doTidy(ctx context.Context, req *logical.Request, config *tidyConfig, status *tidyStatus) (*logical.Response, error) {
+	bufferDuration := config.SafetyBuffer
 
 	var resp *logical.Response
 
-	if tidyCertStore {
-		serials, err := req.Storage.List(ctx, "certs/")
-		if err != nil {
-			return nil, fmt.Errorf("error fetching list of certs: %s", err)
-		}
+	serials, err := req.Storage.List(ctx, "certs/")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching list of certs: %s", err)
+	}
+	b.tidyStatusLock.Lock()
+	status.CertStoreCount = len(serials)
+	b.tidyStatusLock.Unlock()
 
+	if config.CertStore {
 		for _, serial := range serials {
 			certEntry, err := req.Storage.Get(ctx, "certs/"+serial)
 			if err != nil {
@@ -77,6 +210,8 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 				if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
 					return nil, errwrap.Wrapf(fmt.Sprintf("error deleting nil entry with serial %s: {{err}}", serial), err)
 				}
+				b.tidyDeletedCert(status)
+				continue
 			}
 
 			if certEntry.Value == nil || len(certEntry.Value) == 0 {
@@ -87,6 +222,8 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 				if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
 					return nil, errwrap.Wrapf(fmt.Sprintf("error deleting entry with nil value with serial %s: {{err}}", serial), err)
 				}
+				b.tidyDeletedCert(status)
+				continue
 			}
 
 			cert, err := x509.ParseCertificate(certEntry.Value)
@@ -98,23 +235,27 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 				if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
 					return nil, fmt.Errorf("error deleting serial %s from storage: %s", serial, err)
 				}
+				b.tidyDeletedCert(status)
 			}
 		}
 	}
 
-	if tidyRevocationList {
-		b.revokeStorageLock.Lock()
-		defer b.revokeStorageLock.Unlock()
+	b.revokeStorageLock.Lock()
+	defer b.revokeStorageLock.Unlock()
 
-		tidiedRevoked := false
+	revokedSerials, err := req.Storage.List(ctx, "revoked/")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching list of revoked certs: %s", err)
+	}
+	b.tidyStatusLock.Lock()
+	status.RevokedCertCount = len(revokedSerials)
+	b.tidyStatusLock.Unlock()
 
-		revokedSerials, err := req.Storage.List(ctx, "revoked/")
-		if err != nil {
-			return nil, fmt.Errorf("error fetching list of revoked certs: %s", err)
-		}
+	if config.RevocationList {
+		tidiedRevoked := false
 
-		var revInfo revocationInfo
 		for _, serial := range revokedSerials {
+			var revInfo revocationInfo
 			revokedEntry, err := req.Storage.Get(ctx, "revoked/"+serial)
 			if err != nil {
 				return nil, fmt.Errorf("unable to fetch revoked cert with serial %s: %s", serial, err)
@@ -128,6 +269,8 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 				if err := req.Storage.Delete(ctx, "revoked/"+serial); err != nil {
 					return nil, errwrap.Wrapf(fmt.Sprintf("error deleting nil revoked entry with serial %s: {{err}}", serial), err)
 				}
+				b.tidyDeletedRevokedCert(status)
+				continue
 			}
 
 			if revokedEntry.Value == nil || len(revokedEntry.Value) == 0 {
@@ -138,6 +281,8 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 				if err := req.Storage.Delete(ctx, "revoked/"+serial); err != nil {
 					return nil, errwrap.Wrapf(fmt.Sprintf("error deleting revoked entry with nil value with serial %s: {{err}}", serial), err)
 				}
+				b.tidyDeletedRevokedCert(status)
+				continue
 			}
 
 			err = revokedEntry.DecodeJSON(&revInfo)
@@ -154,6 +299,7 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 				if err := req.Storage.Delete(ctx, "revoked/"+serial); err != nil {
 					return nil, fmt.Errorf("error deleting serial %s from revoked list: %s", serial, err)
 				}
+				b.tidyDeletedRevokedCert(status)
 				tidiedRevoked = true
 			}
 		}
@@ -168,6 +314,180 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 	return resp, nil
 }
 
+func (b *backend) tidyDeletedCert(status *tidyStatus) {
+	b.tidyStatusLock.Lock()
+	defer b.tidyStatusLock.Unlock()
+
+	status.CertStoreDeletedCount++
+	status.CertStoreCount--
+}
+
+func (b *backend) tidyDeletedRevokedCert(status *tidyStatus) {
+	b.tidyStatusLock.Lock()
+	defer b.tidyStatusLock.Unlock()
+
+	status.RevokedCertDeletedCount++
+	status.RevokedCertCount--
+}
+
+// autoTidy starts a tidy operation in the background when automatic tidying
+// is enabled and its interval has passed since the last one
+func (b *backend) autoTidy(ctx context.Context, s logical.Storage) error {
+	config, err := b.autoTidyConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	if !config.Enabled {
+		return nil
+	}
+
+	b.tidyStatusLock.RLock()
+	lastTidy := b.lastAutoTidy
+	b.tidyStatusLock.RUnlock()
+	if time.Since(lastTidy) < config.Interval {
+		return nil
+	}
+
+	if !atomic.CompareAndSwapUint32(&b.tidyRunning, 0, 1) {
+		return nil
+	}
+
+	b.tidyStatusLock.Lock()
+	b.lastAutoTidy = time.Now()
+	b.tidyStatusLock.Unlock()
+
+	go func() {
+		defer atomic.StoreUint32(&b.tidyRunning, 0)
+
+		if _, err := b.tidy(ctx, &logical.Request{Storage: s}, config, true); err != nil {
+			b.Logger().Error("automatic tidy failed", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+func (b *backend) autoTidyConfig(ctx context.Context, s logical.Storage) (*tidyConfig, error) {
+	entry, err := s.Get(ctx, autoTidyConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := defaultTidyConfig
+	if entry != nil {
+		if err := entry.DecodeJSON(&config); err != nil {
+			return nil, err
+		}
+	}
+	return &config, nil
+}
+
+func (b *backend) pathConfigAutoTidyRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.autoTidyConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":              config.Enabled,
+			"interval_duration":    int64(config.Interval / time.Second),
+			"tidy_cert_store":      config.CertStore,
+			"tidy_revocation_list": config.RevocationList,
+			"safety_buffer":        int64(config.SafetyBuffer / time.Second),
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigAutoTidyWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.autoTidyConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabledRaw, ok := data.GetOk("enabled"); ok {
+		config.Enabled = enabledRaw.(bool)
+	}
+	if intervalRaw, ok := data.GetOk("interval_duration"); ok {
+		config.Interval = time.Duration(intervalRaw.(int)) * time.Second
+	}
+	if certStoreRaw, ok := data.GetOk("tidy_cert_store"); ok {
+		config.CertStore = certStoreRaw.(bool)
+	}
+	if revocationListRaw, ok := data.GetOk("tidy_revocation_list"); ok {
+		config.RevocationList = revocationListRaw.(bool)
+	}
+	if safetyBufferRaw, ok := data.GetOk("safety_buffer"); ok {
+		config.SafetyBuffer = time.Duration(safetyBufferRaw.(int)) * time.Second
+	}
+
+	if config.Interval <= 0 {
+		return logical.ErrorResponse("interval_duration must be positive"), nil
+	}
+	if config.SafetyBuffer < 0 {
+		return logical.ErrorResponse("safety_buffer must not be negative"), nil
+	}
+	if config.Enabled && !config.CertStore && !config.RevocationList {
+		return logical.ErrorResponse("automatic tidying requires tidy_cert_store or tidy_revocation_list"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(autoTidyConfigPath, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathTidyStatusRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.tidyStatusLock.RLock()
+	defer b.tidyStatusLock.RUnlock()
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"state":                      tidyStatusInactive,
+			"automatic":                  nil,
+			"time_started":               nil,
+			"time_finished":              nil,
+			"error":                      nil,
+			"safety_buffer":              nil,
+			"tidy_cert_store":            nil,
+			"tidy_revocation_list":       nil,
+			"cert_store_deleted_count":   nil,
+			"revoked_cert_deleted_count": nil,
+			"current_cert_store_count":   nil,
+			"current_revoked_cert_count": nil,
+		},
+	}
+
+	status := b.tidyStatus
+	if status == nil {
+		return resp, nil
+	}
+
+	resp.Data["state"] = status.State
+	resp.Data["automatic"] = status.Automatic
+	resp.Data["time_started"] = status.TimeStarted.Format(time.RFC3339Nano)
+	resp.Data["safety_buffer"] = int64(status.Config.SafetyBuffer / time.Second)
+	resp.Data["tidy_cert_store"] = status.Config.CertStore
+	resp.Data["tidy_revocation_list"] = status.Config.RevocationList
+	resp.Data["cert_store_deleted_count"] = status.CertStoreDeletedCount
+	resp.Data["revoked_cert_deleted_count"] = status.RevokedCertDeletedCount
+	resp.Data["current_cert_store_count"] = status.CertStoreCount
+	resp.Data["current_revoked_cert_count"] = status.RevokedCertCount
+	if !status.TimeFinished.IsZero() {
+		resp.Data["time_finished"] = status.TimeFinished.Format(time.RFC3339Nano)
+	}
+	if status.Err != nil {
+		resp.Data["error"] = status.Err.Error()
+	}
+
+	return resp, nil
+}
+
 const pathTidyHelpSyn = `
 Tidy up the backend by removing expired certificates, revocation information,
 or both.
@@ -194,3 +514,23 @@ certificate storage or in revocation information will then be checked. If the
 current time, minus the value of 'safety_buffer', is greater than the
 expiration, it will be removed.
 `
+
+const pathConfigAutoTidyHelpSyn = `
+Configure automatic tidying of the backend.
+`
+
+const pathConfigAutoTidyHelpDesc = `
+When 'enabled' is set, the backend is tidied in the background every
+'interval_duration', with the same parameters as the tidy endpoint. The
+outcome of the last tidy operation can be read from the tidy-status endpoint.
+`
+
+const pathTidyStatusHelpSyn = `
+Return the status of the last tidy operation.
+`
+
+const pathTidyStatusHelpDesc = `
+This returns the state of the last tidy operation, manual or automatic, along
+with its parameters, the number of certificates and revocation entries it
+removed, and the number of certificates and revoked certificates left.
+`
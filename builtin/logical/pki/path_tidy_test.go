@@ -0,0 +1,115 @@
+package pki
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_AutoTidy(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	resp := mustRequest(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["state"] != tidyStatusInactive {
+		t.Fatalf("bad status: %#v", resp.Data)
+	}
+
+	// Enabling automatic tidying requires something to tidy
+	resp, err := request(logical.UpdateOperation, "config/auto-tidy", map[string]interface{}{
+		"enabled": true,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got: err: %v resp: %#v", err, resp)
+	}
+	mustRequest(logical.UpdateOperation, "config/auto-tidy", map[string]interface{}{
+		"enabled":              true,
+		"tidy_cert_store":      true,
+		"tidy_revocation_list": true,
+		"safety_buffer":        "1s",
+	})
+	resp = mustRequest(logical.ReadOperation, "config/auto-tidy", nil)
+	if resp.Data["interval_duration"] != int64(43200) || resp.Data["safety_buffer"] != int64(1) {
+		t.Fatalf("bad config: %#v", resp.Data)
+	}
+
+	mustRequest(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "example.com",
+		"ttl":         "48h",
+	})
+	mustRequest(logical.UpdateOperation, "roles/test", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1s",
+	})
+	resp = mustRequest(logical.UpdateOperation, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	mustRequest(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": resp.Data["serial_number"],
+	})
+	mustRequest(logical.UpdateOperation, "issue/test", map[string]interface{}{
+		"common_name": "mail.example.com",
+	})
+
+	// Nothing happens until the interval has passed
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	resp = mustRequest(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["state"] != tidyStatusInactive {
+		t.Fatalf("bad status: %#v", resp.Data)
+	}
+
+	time.Sleep(3 * time.Second)
+	b.tidyStatusLock.Lock()
+	b.lastAutoTidy = time.Now().Add(-13 * time.Hour)
+	b.tidyStatusLock.Unlock()
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; ; i++ {
+		resp = mustRequest(logical.ReadOperation, "tidy-status", nil)
+		if resp.Data["state"] == tidyStatusFinished {
+			break
+		}
+		if resp.Data["state"] == tidyStatusError || i > 50 {
+			t.Fatalf("bad status: %#v", resp.Data)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if resp.Data["automatic"] != true ||
+		resp.Data["cert_store_deleted_count"] != 2 ||
+		resp.Data["revoked_cert_deleted_count"] != 1 ||
+		resp.Data["current_revoked_cert_count"] != 0 ||
+		resp.Data["time_finished"] == nil {
+		t.Fatalf("bad status: %#v", resp.Data)
+	}
+
+	// A manual tidy updates the status as well
+	mustRequest(logical.UpdateOperation, "tidy", map[string]interface{}{
+		"tidy_cert_store": true,
+	})
+	resp = mustRequest(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["automatic"] != false || resp.Data["cert_store_deleted_count"] != 0 {
+		t.Fatalf("bad status: %#v", resp.Data)
+	}
+}
@@ -15,6 +15,9 @@ type backend struct {
 	view      logical.Storage
 	salt      *salt.Salt
 	saltMutex sync.RWMutex
+
+	// revocationLock serializes updates of the revoked certificates
+	revocationLock sync.Mutex
 }
 
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
@@ -38,6 +41,8 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			Unauthenticated: []string{
 				"verify",
 				"public_key",
+				"krl",
+				"known_hosts",
 			},
 
 			LocalStorage: []string{
@@ -47,6 +52,7 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			SealWrapStorage: []string{
 				caPrivateKey,
 				caPrivateKeyStoragePath,
+				caHostPrivateKeyStoragePath,
 				"keys/",
 			},
 		},
@@ -60,8 +66,13 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			pathLookup(&b),
 			pathVerify(&b),
 			pathConfigCA(&b),
+			pathConfigCARotate(&b),
 			pathSign(&b),
+			pathSignHost(&b),
+			pathRevoke(&b),
 			pathFetchPublicKey(&b),
+			pathFetchKRL(&b),
+			pathFetchKnownHosts(&b),
 		},
 
 		Secrets: []*framework.Secret{
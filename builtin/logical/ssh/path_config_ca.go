@@ -2,15 +2,21 @@ package ssh
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/binary"
 	"encoding/pem"
 	"fmt"
+	"strings"
+	"time"
 
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -21,12 +27,70 @@ const (
 	caPublicKeyStoragePathDeprecated  = "public_key"
 	caPrivateKeyStoragePath           = "config/ca_private_key"
 	caPrivateKeyStoragePathDeprecated = "config/ca_bundle"
+
+	// caPreviousKeysStoragePath holds the public keys replaced by a rotation,
+	// which remain trusted until their overlap window ends
+	caPreviousKeysStoragePath = "config/ca_previous_keys"
+
+	// The optional host CA signs host certificates in place of the user CA
+	caHostPublicKey               = "ca_host_public_key"
+	caHostPrivateKey              = "ca_host_private_key"
+	caHostPublicKeyStoragePath    = "config/ca_host_public_key"
+	caHostPrivateKeyStoragePath   = "config/ca_host_private_key"
+	caHostPreviousKeysStoragePath = "config/ca_host_previous_keys"
+
+	caTypeUser = "user"
+	caTypeHost = "host"
+
+	defaultCAKeyType = "rsa"
 )
 
+// caStorage names the storage entries of one of the CAs of the mount
+type caStorage struct {
+	publicKey        string
+	privateKey       string
+	publicKeyPath    string
+	privateKeyPath   string
+	previousKeysPath string
+}
+
+func caStorageFor(caType string) (*caStorage, error) {
+	switch caType {
+	case caTypeUser, "":
+		return &caStorage{
+			publicKey:        caPublicKey,
+			privateKey:       caPrivateKey,
+			publicKeyPath:    caPublicKeyStoragePath,
+			privateKeyPath:   caPrivateKeyStoragePath,
+			previousKeysPath: caPreviousKeysStoragePath,
+		}, nil
+	case caTypeHost:
+		return &caStorage{
+			publicKey:        caHostPublicKey,
+			privateKey:       caHostPrivateKey,
+			publicKeyPath:    caHostPublicKeyStoragePath,
+			privateKeyPath:   caHostPrivateKeyStoragePath,
+			previousKeysPath: caHostPreviousKeysStoragePath,
+		}, nil
+	default:
+		return nil, fmt.Errorf("ca_type must be either %q or %q", caTypeUser, caTypeHost)
+	}
+}
+
 type keyStorageEntry struct {
 	Key string `json:"key" structs:"key" mapstructure:"key"`
 }
 
+// previousKeyEntry is a CA public key which was rotated out
+type previousKeyEntry struct {
+	PublicKey string    `json:"public_key"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type previousKeysStorageEntry struct {
+	Keys []previousKeyEntry `json:"keys"`
+}
+
 func pathConfigCA(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "config/ca",
@@ -44,6 +108,16 @@ func pathConfigCA(b *backend) *framework.Path {
 				Description: `Generate SSH key pair internally rather than use the private_key and public_key fields.`,
 				Default:     true,
 			},
+			"key_type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Type of the generated signing key; one of "rsa", "ecdsa-p256", "ecdsa-p384", "ecdsa-p521" or "ed25519".`,
+				Default:     defaultCAKeyType,
+			},
+			"ca_type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `CA to manage; either "user" or "host". The host CA is optional: until it is configured, host certificates are signed by the user CA.`,
+				Default:     caTypeUser,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -58,12 +132,52 @@ by this mount. The fields must be in the standard private and public SSH format.
 
 For security reasons, the private key cannot be retrieved later.
 
-Read operations will return the public key, if already stored/generated.`,
+Read operations will return the public key, if already stored/generated.
+
+A separate CA can be configured for host certificates by setting ca_type to
+"host"; until then, host certificates are signed by the user CA.`,
+	}
+}
+
+func pathConfigCARotate(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/ca/rotate",
+		Fields: map[string]*framework.FieldSchema{
+			"key_type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Type of the new signing key; one of "rsa", "ecdsa-p256", "ecdsa-p384", "ecdsa-p521" or "ed25519". Defaults to the type of the current key.`,
+			},
+			"overlap": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: `How long the current key remains trusted after the rotation. Defaults to the max TTL of the mount.`,
+			},
+			"ca_type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `CA to rotate; either "user" or "host".`,
+				Default:     caTypeUser,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathConfigCARotate,
+		},
+
+		HelpSynopsis: `Rotate the SSH key used for signing certificates.`,
+		HelpDescription: `This generates a new signing key, which is used for all certificates signed
+afterwards. The previous key is still returned by the public_key endpoint and
+covered by the KRL until the overlap window ends, so that hosts trusting it
+can be updated while the certificates it signed expire.`,
 	}
 }
 
 func (b *backend) pathConfigCARead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
+	caType := data.Get("ca_type").(string)
+	storage, err := caStorageFor(caType)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	publicKeyEntry, err := caKey(ctx, req.Storage, storage.publicKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CA public key: %v", err)
 	}
@@ -72,9 +186,15 @@ func (b *backend) pathConfigCARead(ctx context.Context, req *logical.Request, da
 		return logical.ErrorResponse("keys haven't been configured yet"), nil
 	}
 
+	publicKeys, err := trustedCAPublicKeys(ctx, req.Storage, caType)
+	if err != nil {
+		return nil, err
+	}
+
 	response := &logical.Response{
 		Data: map[string]interface{}{
-			"public_key": publicKeyEntry.Key,
+			"public_key":  publicKeyEntry.Key,
+			"public_keys": publicKeys,
 		},
 	}
 
@@ -82,10 +202,18 @@ func (b *backend) pathConfigCARead(ctx context.Context, req *logical.Request, da
 }
 
 func (b *backend) pathConfigCADelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	if err := req.Storage.Delete(ctx, caPrivateKeyStoragePath); err != nil {
+	storage, err := caStorageFor(data.Get("ca_type").(string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if err := req.Storage.Delete(ctx, storage.privateKeyPath); err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Delete(ctx, storage.publicKeyPath); err != nil {
 		return nil, err
 	}
-	if err := req.Storage.Delete(ctx, caPublicKeyStoragePath); err != nil {
+	if err := req.Storage.Delete(ctx, storage.previousKeysPath); err != nil {
 		return nil, err
 	}
 	return nil, nil
@@ -100,6 +228,10 @@ func caKey(ctx context.Context, storage logical.Storage, keyType string) (*keySt
 	case caPublicKey:
 		path = caPublicKeyStoragePath
 		deprecatedPath = caPublicKeyStoragePathDeprecated
+	case caHostPrivateKey:
+		path = caHostPrivateKeyStoragePath
+	case caHostPublicKey:
+		path = caHostPublicKeyStoragePath
 	default:
 		return nil, fmt.Errorf("unrecognized key type %q", keyType)
 	}
@@ -109,7 +241,7 @@ func caKey(ctx context.Context, storage logical.Storage, keyType string) (*keySt
 		return nil, fmt.Errorf("failed to read CA key of type %q: %v", keyType, err)
 	}
 
-	if entry == nil {
+	if entry == nil && deprecatedPath != "" {
 		// If the entry is not found, look at an older path. If found, upgrade
 		// it.
 		entry, err = storage.Get(ctx, deprecatedPath)
@@ -144,7 +276,11 @@ func caKey(ctx context.Context, storage logical.Storage, keyType string) (*keySt
 }
 
 func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	var err error
+	storage, err := caStorageFor(data.Get("ca_type").(string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
 	publicKey := data.Get("public_key").(string)
 	privateKey := data.Get("private_key").(string)
 
@@ -190,9 +326,9 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 	}
 
 	if generateSigningKey {
-		publicKey, privateKey, err = generateSSHKeyPair()
+		publicKey, privateKey, err = generateSSHKeyPair(data.Get("key_type").(string))
 		if err != nil {
-			return nil, err
+			return logical.ErrorResponse(err.Error()), nil
 		}
 	}
 
@@ -200,12 +336,12 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 		return nil, fmt.Errorf("failed to generate or parse the keys")
 	}
 
-	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
+	publicKeyEntry, err := caKey(ctx, req.Storage, storage.publicKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CA public key: %v", err)
 	}
 
-	privateKeyEntry, err := caKey(ctx, req.Storage, caPrivateKey)
+	privateKeyEntry, err := caKey(ctx, req.Storage, storage.privateKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CA private key: %v", err)
 	}
@@ -214,7 +350,7 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 		return nil, fmt.Errorf("keys are already configured; delete them before reconfiguring")
 	}
 
-	entry, err := logical.StorageEntryJSON(caPublicKeyStoragePath, &keyStorageEntry{
+	entry, err := logical.StorageEntryJSON(storage.publicKeyPath, &keyStorageEntry{
 		Key: publicKey,
 	})
 	if err != nil {
@@ -227,7 +363,7 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 		return nil, err
 	}
 
-	entry, err = logical.StorageEntryJSON(caPrivateKeyStoragePath, &keyStorageEntry{
+	entry, err = logical.StorageEntryJSON(storage.privateKeyPath, &keyStorageEntry{
 		Key: privateKey,
 	})
 	if err != nil {
@@ -243,7 +379,7 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 
 		// If storing private key fails, the corresponding public key should be
 		// removed
-		if delErr := req.Storage.Delete(ctx, caPublicKeyStoragePath); delErr != nil {
+		if delErr := req.Storage.Delete(ctx, storage.publicKeyPath); delErr != nil {
 			mErr = multierror.Append(mErr, fmt.Errorf("failed to cleanup CA public key: %v", delErr))
 			return nil, mErr
 		}
@@ -264,22 +400,284 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 	return nil, nil
 }
 
-func generateSSHKeyPair() (string, string, error) {
-	privateSeed, err := rsa.GenerateKey(rand.Reader, 4096)
+func (b *backend) pathConfigCARotate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	storage, err := caStorageFor(data.Get("ca_type").(string))
 	if err != nil {
-		return "", "", err
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	publicKeyEntry, err := caKey(ctx, req.Storage, storage.publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA public key: %v", err)
+	}
+	if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+		return logical.ErrorResponse("keys haven't been configured yet"), nil
+	}
+
+	keyType := data.Get("key_type").(string)
+	if keyType == "" {
+		currentKey, err := parsePublicSSHKey(publicKeyEntry.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CA public key: %v", err)
+		}
+		keyType = caKeyType(currentKey)
+	}
+
+	overlap := b.System().MaxLeaseTTL()
+	if overlapRaw, ok := data.GetOk("overlap"); ok {
+		overlap = time.Duration(overlapRaw.(int)) * time.Second
+	}
+
+	publicKey, privateKey, err := generateSSHKeyPair(keyType)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// Keep trusting the current key during the overlap window, dropping the
+	// keys whose window has ended
+	previousKeys, err := fetchPreviousCAKeys(ctx, req.Storage, storage.previousKeysPath)
+	if err != nil {
+		return nil, err
+	}
+	keys := []previousKeyEntry{{
+		PublicKey: publicKeyEntry.Key,
+		ExpiresAt: time.Now().Add(overlap),
+	}}
+	for _, previousKey := range previousKeys.Keys {
+		if time.Now().Before(previousKey.ExpiresAt) {
+			keys = append(keys, previousKey)
+		}
+	}
+	entry, err := logical.StorageEntryJSON(storage.previousKeysPath, &previousKeysStorageEntry{
+		Keys: keys,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	entry, err = logical.StorageEntryJSON(storage.privateKeyPath, &keyStorageEntry{
+		Key: privateKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, fmt.Errorf("failed to store CA private key: %v", err)
+	}
+	entry, err = logical.StorageEntryJSON(storage.publicKeyPath, &keyStorageEntry{
+		Key: publicKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, fmt.Errorf("failed to store CA public key: %v", err)
 	}
 
-	privateBlock := &pem.Block{
-		Type:    "RSA PRIVATE KEY",
-		Headers: nil,
-		Bytes:   x509.MarshalPKCS1PrivateKey(privateSeed),
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": publicKey,
+		},
+	}, nil
+}
+
+// caKeyType returns the key_type value matching the given public key
+func caKeyType(key ssh.PublicKey) string {
+	switch key.Type() {
+	case ssh.KeyAlgoECDSA256:
+		return "ecdsa-p256"
+	case ssh.KeyAlgoECDSA384:
+		return "ecdsa-p384"
+	case ssh.KeyAlgoECDSA521:
+		return "ecdsa-p521"
+	case ssh.KeyAlgoED25519:
+		return "ed25519"
+	default:
+		return "rsa"
 	}
+}
+
+// generateSSHKeyPair generates a CA key pair of the given type, returning
+// the public key in the authorized_keys format and the PEM-encoded private key
+func generateSSHKeyPair(keyType string) (string, string, error) {
+	var privateBlock *pem.Block
+	var publicKey interface{}
 
-	public, err := ssh.NewPublicKey(&privateSeed.PublicKey)
+	switch keyType {
+	case "rsa", "":
+		privateSeed, err := rsa.GenerateKey(rand.Reader, 4096)
+		if err != nil {
+			return "", "", err
+		}
+		privateBlock = &pem.Block{
+			Type:    "RSA PRIVATE KEY",
+			Headers: nil,
+			Bytes:   x509.MarshalPKCS1PrivateKey(privateSeed),
+		}
+		publicKey = &privateSeed.PublicKey
+
+	case "ecdsa-p256", "ecdsa-p384", "ecdsa-p521":
+		var curve elliptic.Curve
+		switch keyType {
+		case "ecdsa-p256":
+			curve = elliptic.P256()
+		case "ecdsa-p384":
+			curve = elliptic.P384()
+		default:
+			curve = elliptic.P521()
+		}
+		privateSeed, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		marshaled, err := x509.MarshalECPrivateKey(privateSeed)
+		if err != nil {
+			return "", "", err
+		}
+		privateBlock = &pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: marshaled,
+		}
+		publicKey = &privateSeed.PublicKey
+
+	case "ed25519":
+		public, privateSeed, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		privateBlock, err = marshalEd25519PrivateKey(privateSeed)
+		if err != nil {
+			return "", "", err
+		}
+		publicKey = public
+
+	default:
+		return "", "", fmt.Errorf("unsupported key_type %q", keyType)
+	}
+
+	public, err := ssh.NewPublicKey(publicKey)
 	if err != nil {
 		return "", "", err
 	}
 
 	return string(ssh.MarshalAuthorizedKey(public)), string(pem.EncodeToMemory(privateBlock)), nil
 }
+
+// marshalEd25519PrivateKey encodes an Ed25519 private key in the OpenSSH
+// private key format, described in PROTOCOL.key of OpenSSH, which is the only
+// format Ed25519 keys can be parsed from
+func marshalEd25519PrivateKey(key ed25519.PrivateKey) (*pem.Block, error) {
+	publicKey, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	var checkBytes [4]byte
+	if _, err := rand.Read(checkBytes[:]); err != nil {
+		return nil, err
+	}
+	check := binary.BigEndian.Uint32(checkBytes[:])
+
+	privateKey := struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+		Pub     []byte
+		Priv    []byte
+		Comment string
+		Pad     []byte `ssh:"rest"`
+	}{
+		Check1:  check,
+		Check2:  check,
+		Keytype: ssh.KeyAlgoED25519,
+		Pub:     []byte(key.Public().(ed25519.PublicKey)),
+		Priv:    []byte(key),
+	}
+	// The private section is padded to the cipher block size, which is 8
+	// for unencrypted keys
+	for i := 1; len(ssh.Marshal(privateKey))%8 != 0; i++ {
+		privateKey.Pad = append(privateKey.Pad, byte(i))
+	}
+
+	container := struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{
+		CipherName:   "none",
+		KdfName:      "none",
+		NumKeys:      1,
+		PubKey:       publicKey.Marshal(),
+		PrivKeyBlock: ssh.Marshal(privateKey),
+	}
+
+	return &pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: append([]byte("openssh-key-v1\x00"), ssh.Marshal(container)...),
+	}, nil
+}
+
+// trustedCAPublicKeys returns the public keys certificates of the given CA
+// may be signed with: the current CA key followed by the rotated keys still in
+// their overlap window
+func trustedCAPublicKeys(ctx context.Context, s logical.Storage, caType string) ([]string, error) {
+	storage, err := caStorageFor(caType)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeyEntry, err := caKey(ctx, s, storage.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+		return nil, nil
+	}
+
+	previousKeys, err := fetchPreviousCAKeys(ctx, s, storage.previousKeysPath)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeys := []string{strings.TrimSpace(publicKeyEntry.Key)}
+	for _, previousKey := range previousKeys.Keys {
+		if time.Now().Before(previousKey.ExpiresAt) {
+			publicKeys = append(publicKeys, strings.TrimSpace(previousKey.PublicKey))
+		}
+	}
+	return publicKeys, nil
+}
+
+// hostCAType returns the CA signing host certificates: the host CA if it is
+// configured, the user CA otherwise
+func hostCAType(ctx context.Context, s logical.Storage) (string, error) {
+	publicKeyEntry, err := caKey(ctx, s, caHostPublicKey)
+	if err != nil {
+		return "", err
+	}
+	if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+		return caTypeUser, nil
+	}
+	return caTypeHost, nil
+}
+
+func fetchPreviousCAKeys(ctx context.Context, s logical.Storage, path string) (*previousKeysStorageEntry, error) {
+	entry, err := s.Get(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read previous CA keys: %v", err)
+	}
+
+	var previousKeys previousKeysStorageEntry
+	if entry != nil {
+		if err := entry.DecodeJSON(&previousKeys); err != nil {
+			return nil, err
+		}
+	}
+	return &previousKeys, nil
+}
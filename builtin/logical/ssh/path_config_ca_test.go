@@ -2,9 +2,11 @@ package ssh
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/vault/logical"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestSSH_ConfigCAStorageUpgrade(t *testing.T) {
@@ -162,3 +164,152 @@ func TestSSH_ConfigCAUpdateDelete(t *testing.T) {
 		t.Fatalf("bad: err: %v, resp:%v", err, resp)
 	}
 }
+
+func TestSSH_ConfigCAKeyTypes(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Backend(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+	}
+
+	_, userKey, err := generateSSHKeyPair("ed25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userSigner, err := ssh.ParsePrivateKey([]byte(userKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	userPublicKey := string(ssh.MarshalAuthorizedKey(userSigner.PublicKey()))
+
+	resp, err := request(logical.UpdateOperation, "roles/users", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+		"default_user":            "ubuntu",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	resp, err = request(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"key_type": "dsa",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for an unsupported key type, got: err: %v resp: %#v", err, resp)
+	}
+
+	for keyType, algorithm := range map[string]string{
+		"rsa":        ssh.KeyAlgoRSA,
+		"ecdsa-p256": ssh.KeyAlgoECDSA256,
+		"ecdsa-p384": ssh.KeyAlgoECDSA384,
+		"ecdsa-p521": ssh.KeyAlgoECDSA521,
+		"ed25519":    ssh.KeyAlgoED25519,
+	} {
+		if _, err := request(logical.DeleteOperation, "config/ca", nil); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := request(logical.UpdateOperation, "config/ca", map[string]interface{}{
+			"key_type": keyType,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: key type: %s err: %v resp: %#v", keyType, err, resp)
+		}
+
+		resp, err = request(logical.UpdateOperation, "sign/users", map[string]interface{}{
+			"public_key": userPublicKey,
+		})
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("bad: key type: %s err: %v resp: %#v", keyType, err, resp)
+		}
+		parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(resp.Data["signed_key"].(string)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert := parsed.(*ssh.Certificate)
+		if cert.SignatureKey.Type() != algorithm {
+			t.Fatalf("bad signature key type for %s: %s", keyType, cert.SignatureKey.Type())
+		}
+		checker := ssh.CertChecker{}
+		if err := checker.CheckCert("ubuntu", cert); err != nil {
+			t.Fatalf("bad certificate for %s: %v", keyType, err)
+		}
+	}
+}
+
+func TestSSH_ConfigCARotate(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Backend(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	oldKey := request(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"key_type": "ecdsa-p256",
+	}).Data["public_key"].(string)
+
+	// The new key keeps the type of the current one unless told otherwise
+	newKey := request(logical.UpdateOperation, "config/ca/rotate", map[string]interface{}{
+		"overlap": "1h",
+	}).Data["public_key"].(string)
+	if newKey == oldKey || !strings.HasPrefix(newKey, ssh.KeyAlgoECDSA256) {
+		t.Fatalf("bad rotated key: %q", newKey)
+	}
+
+	resp := request(logical.ReadOperation, "config/ca", nil)
+	if resp.Data["public_key"] != newKey {
+		t.Fatalf("bad public key: %#v", resp.Data)
+	}
+	publicKeys := resp.Data["public_keys"].([]string)
+	if len(publicKeys) != 2 || publicKeys[0] != strings.TrimSpace(newKey) || publicKeys[1] != strings.TrimSpace(oldKey) {
+		t.Fatalf("bad public keys: %#v", publicKeys)
+	}
+
+	body := string(request(logical.ReadOperation, "public_key", nil).Data[logical.HTTPRawBody].([]byte))
+	if body != strings.Join(publicKeys, "\n")+"\n" {
+		t.Fatalf("bad public_key body: %q", body)
+	}
+
+	// Without overlap, the previous key is no longer trusted
+	request(logical.UpdateOperation, "config/ca/rotate", map[string]interface{}{
+		"key_type": "ed25519",
+		"overlap":  0,
+	})
+	resp = request(logical.ReadOperation, "config/ca", nil)
+	publicKeys = resp.Data["public_keys"].([]string)
+	if len(publicKeys) != 2 || !strings.HasPrefix(publicKeys[0], ssh.KeyAlgoED25519) || publicKeys[1] != strings.TrimSpace(oldKey) {
+		t.Fatalf("bad public keys: %#v", publicKeys)
+	}
+}
@@ -2,6 +2,8 @@ package ssh
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -16,7 +18,7 @@ func pathFetchPublicKey(b *backend) *framework.Path {
 		},
 
 		HelpSynopsis:    `Retrieve the public key.`,
-		HelpDescription: `This allows the public key, that this backend has been configured with, to be fetched.`,
+		HelpDescription: `This allows the public key, that this backend has been configured with, to be fetched. During a key rotation, the previous keys are returned as well, one per line.`,
 	}
 }
 
@@ -29,13 +31,77 @@ func (b *backend) pathFetchPublicKey(ctx context.Context, req *logical.Request,
 		return nil, nil
 	}
 
+	// During a rotation, the previous keys are still trusted
+	publicKeys, err := trustedCAPublicKeys(ctx, req.Storage, caTypeUser)
+	if err != nil {
+		return nil, err
+	}
+	body := publicKeyEntry.Key
+	if len(publicKeys) > 1 {
+		body = strings.Join(publicKeys, "\n") + "\n"
+	}
+
 	response := &logical.Response{
 		Data: map[string]interface{}{
 			logical.HTTPContentType: "text/plain",
-			logical.HTTPRawBody:     []byte(publicKeyEntry.Key),
+			logical.HTTPRawBody:     []byte(body),
 			logical.HTTPStatusCode:  200,
 		},
 	}
 
 	return response, nil
 }
+
+func pathFetchKnownHosts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `known_hosts`,
+		Fields: map[string]*framework.FieldSchema{
+			"host_patterns": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Host patterns the host CA is trusted for, as used in known_hosts files.`,
+				Default:     "*",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchKnownHosts,
+		},
+
+		HelpSynopsis: `Retrieve the known_hosts lines trusting the host CA.`,
+		HelpDescription: `This returns an @cert-authority line for each key of the CA signing host
+certificates, which is the host CA if configured and the user CA otherwise.
+The output can be used as a known_hosts or ssh_known_hosts file.`,
+	}
+}
+
+func (b *backend) pathFetchKnownHosts(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	patterns := data.Get("host_patterns").([]string)
+	if len(patterns) == 0 {
+		return logical.ErrorResponse("missing host_patterns"), nil
+	}
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, " \t") {
+			return logical.ErrorResponse(fmt.Sprintf("invalid host pattern %q", pattern)), nil
+		}
+	}
+
+	caType, err := hostCAType(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	publicKeys, err := trustedCAPublicKeys(ctx, req.Storage, caType)
+	if err != nil {
+		return nil, err
+	}
+	if len(publicKeys) == 0 {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "text/plain",
+			logical.HTTPRawBody:     []byte(knownHostsLines(publicKeys, patterns)),
+			logical.HTTPStatusCode:  200,
+		},
+	}, nil
+}
@@ -0,0 +1,236 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ssh"
+)
+
+const revocationsStoragePath = "revocations"
+
+// Constants of the OpenSSH Key Revocation List format, described in
+// PROTOCOL.krl of OpenSSH
+const (
+	krlMagic                 = 0x5353484b524c0a00
+	krlFormatVersion         = 1
+	krlSectionCertificates   = 1
+	krlSectionCertSerialList = 0x20
+	krlSectionCertKeyID      = 0x23
+)
+
+// revocationsEntry holds the revoked certificates. The version is bumped on
+// each revocation and becomes the version of the KRL.
+type revocationsEntry struct {
+	Version uint64   `json:"version"`
+	Serials []uint64 `json:"serials"`
+	KeyIDs  []string `json:"key_ids"`
+}
+
+func pathRevoke(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "revoke",
+		Fields: map[string]*framework.FieldSchema{
+			"serial_number": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Serial number of the certificate to revoke, in hexadecimal as returned when signing.`,
+			},
+			"key_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Key ID of the certificates to revoke. All certificates with this key ID are revoked.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRevokeWrite,
+		},
+
+		HelpSynopsis: `Revoke SSH certificates signed by this mount.`,
+		HelpDescription: `This revokes a certificate by its serial number, or all certificates with a
+key ID. Revoked certificates are listed in the Key Revocation List served by
+the krl endpoint, and certificates can no longer be signed with a revoked
+key ID.`,
+	}
+}
+
+func pathFetchKRL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "krl",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchKRL,
+		},
+
+		HelpSynopsis: `Retrieve the Key Revocation List.`,
+		HelpDescription: `This returns the revoked certificates as an OpenSSH Key Revocation List,
+which can be used with the RevokedKeys option of sshd.`,
+	}
+}
+
+func fetchRevocations(ctx context.Context, s logical.Storage) (*revocationsEntry, error) {
+	entry, err := s.Get(ctx, revocationsStoragePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revocations: %v", err)
+	}
+
+	var revocations revocationsEntry
+	if entry != nil {
+		if err := entry.DecodeJSON(&revocations); err != nil {
+			return nil, err
+		}
+	}
+	return &revocations, nil
+}
+
+func (b *backend) pathRevokeWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	serialNumber := data.Get("serial_number").(string)
+	keyID := data.Get("key_id").(string)
+	if serialNumber == "" && keyID == "" {
+		return logical.ErrorResponse("serial_number or key_id must be set"), nil
+	}
+
+	var serial uint64
+	if serialNumber != "" {
+		var err error
+		serial, err = strconv.ParseUint(strings.Replace(serialNumber, ":", "", -1), 16, 64)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid serial_number %q", serialNumber)), nil
+		}
+	}
+
+	b.revocationLock.Lock()
+	defer b.revocationLock.Unlock()
+
+	revocations, err := fetchRevocations(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if serialNumber != "" {
+		found := false
+		for _, revoked := range revocations.Serials {
+			if revoked == serial {
+				found = true
+				break
+			}
+		}
+		if !found {
+			revocations.Serials = append(revocations.Serials, serial)
+		}
+	}
+	if keyID != "" && !strutil.StrListContains(revocations.KeyIDs, keyID) {
+		revocations.KeyIDs = append(revocations.KeyIDs, keyID)
+	}
+	revocations.Version++
+
+	entry, err := logical.StorageEntryJSON(revocationsStoragePath, revocations)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathFetchKRL(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	revocations, err := fetchRevocations(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Revocations apply to the certificates of every key still trusted, by
+	// both the user and the host CA
+	publicKeys, err := trustedCAPublicKeys(ctx, req.Storage, caTypeUser)
+	if err != nil {
+		return nil, err
+	}
+	hostPublicKeys, err := trustedCAPublicKeys(ctx, req.Storage, caTypeHost)
+	if err != nil {
+		return nil, err
+	}
+	publicKeys = strutil.RemoveDuplicates(append(publicKeys, hostPublicKeys...), false)
+
+	var caKeys []ssh.PublicKey
+	for _, publicKey := range publicKeys {
+		caKey, err := parsePublicSSHKey(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CA public key: %v", err)
+		}
+		caKeys = append(caKeys, caKey)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/octet-stream",
+			logical.HTTPRawBody:     marshalKRL(revocations, caKeys, time.Now()),
+			logical.HTTPStatusCode:  200,
+		},
+	}, nil
+}
+
+// marshalKRL encodes the revocations of the certificates signed by the given
+// CA keys as an OpenSSH Key Revocation List
+func marshalKRL(revocations *revocationsEntry, caKeys []ssh.PublicKey, generated time.Time) []byte {
+	var krl bytes.Buffer
+	binary.Write(&krl, binary.BigEndian, uint64(krlMagic))
+	binary.Write(&krl, binary.BigEndian, uint32(krlFormatVersion))
+	binary.Write(&krl, binary.BigEndian, revocations.Version)
+	binary.Write(&krl, binary.BigEndian, uint64(generated.Unix()))
+	binary.Write(&krl, binary.BigEndian, uint64(0)) // flags
+	writeKRLString(&krl, nil)                       // reserved
+	writeKRLString(&krl, nil)                       // comment
+
+	if len(revocations.Serials) == 0 && len(revocations.KeyIDs) == 0 {
+		return krl.Bytes()
+	}
+
+	serials := append([]uint64{}, revocations.Serials...)
+	sort.Slice(serials, func(i, j int) bool { return serials[i] < serials[j] })
+	keyIDs := append([]string{}, revocations.KeyIDs...)
+	sort.Strings(keyIDs)
+
+	var serialList, keyIDList bytes.Buffer
+	for _, serial := range serials {
+		binary.Write(&serialList, binary.BigEndian, serial)
+	}
+	for _, keyID := range keyIDs {
+		writeKRLString(&keyIDList, []byte(keyID))
+	}
+
+	for _, caKey := range caKeys {
+		var section bytes.Buffer
+		writeKRLString(&section, caKey.Marshal())
+		writeKRLString(&section, nil) // reserved
+		if serialList.Len() > 0 {
+			section.WriteByte(krlSectionCertSerialList)
+			writeKRLString(&section, serialList.Bytes())
+		}
+		if keyIDList.Len() > 0 {
+			section.WriteByte(krlSectionCertKeyID)
+			writeKRLString(&section, keyIDList.Bytes())
+		}
+
+		krl.WriteByte(krlSectionCertificates)
+		writeKRLString(&krl, section.Bytes())
+	}
+
+	return krl.Bytes()
+}
+
+// writeKRLString writes an SSH string: its length as uint32 followed by its
+// bytes
+func writeKRLString(buf *bytes.Buffer, s []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.Write(s)
+}
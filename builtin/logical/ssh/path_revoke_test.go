@@ -0,0 +1,158 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSH_RevokeKRL(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Backend(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	caPublicKey := mustRequest(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"key_type": "ed25519",
+	}).Data["public_key"].(string)
+	mustRequest(logical.UpdateOperation, "roles/users", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+		"allow_user_key_ids":      true,
+	})
+
+	serialNumber := mustRequest(logical.UpdateOperation, "sign/users", map[string]interface{}{
+		"public_key": publicKey,
+		"key_id":     "alice",
+	}).Data["serial_number"].(string)
+	serial, err := strconv.ParseUint(serialNumber, 16, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := request(logical.UpdateOperation, "revoke", nil)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error without serial_number or key_id, got: err: %v resp: %#v", err, resp)
+	}
+	mustRequest(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serialNumber,
+	})
+	mustRequest(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"key_id": "mallory",
+	})
+
+	// Revoked key IDs can no longer be signed
+	resp, err = request(logical.UpdateOperation, "sign/users", map[string]interface{}{
+		"public_key": publicKey,
+		"key_id":     "mallory",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a revoked key id, got: err: %v resp: %#v", err, resp)
+	}
+
+	resp = mustRequest(logical.ReadOperation, "krl", nil)
+	if resp.Data[logical.HTTPContentType] != "application/octet-stream" {
+		t.Fatalf("bad content type: %#v", resp.Data)
+	}
+	krl := bytes.NewReader(resp.Data[logical.HTTPRawBody].([]byte))
+
+	var header struct {
+		Magic         uint64
+		FormatVersion uint32
+		KRLVersion    uint64
+		GeneratedDate uint64
+		Flags         uint64
+	}
+	if err := binary.Read(krl, binary.BigEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Magic != krlMagic || header.FormatVersion != krlFormatVersion || header.KRLVersion != 2 {
+		t.Fatalf("bad header: %#v", header)
+	}
+	readString := func(r *bytes.Reader) []byte {
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			t.Fatal(err)
+		}
+		s := make([]byte, length)
+		if _, err := r.Read(s); err != nil && length > 0 {
+			t.Fatal(err)
+		}
+		return s
+	}
+	readString(krl) // reserved
+	readString(krl) // comment
+
+	if sectionType, _ := krl.ReadByte(); sectionType != krlSectionCertificates {
+		t.Fatalf("bad section type: %d", sectionType)
+	}
+	section := bytes.NewReader(readString(krl))
+	if krl.Len() != 0 {
+		t.Fatalf("expected a single section, %d bytes left", krl.Len())
+	}
+
+	caKey, err := parsePublicSSHKey(caPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readString(section), caKey.Marshal()) {
+		t.Fatal("bad CA key")
+	}
+	readString(section) // reserved
+
+	if subsectionType, _ := section.ReadByte(); subsectionType != krlSectionCertSerialList {
+		t.Fatalf("bad subsection type: %d", subsectionType)
+	}
+	var revokedSerial uint64
+	if err := binary.Read(bytes.NewReader(readString(section)), binary.BigEndian, &revokedSerial); err != nil {
+		t.Fatal(err)
+	}
+	if revokedSerial != serial {
+		t.Fatalf("bad serial: expected %d, got %d", serial, revokedSerial)
+	}
+
+	if subsectionType, _ := section.ReadByte(); subsectionType != krlSectionCertKeyID {
+		t.Fatalf("bad subsection type: %d", subsectionType)
+	}
+	keyIDs := bytes.NewReader(readString(section))
+	if keyID := string(readString(keyIDs)); keyID != "mallory" {
+		t.Fatalf("bad key id: %q", keyID)
+	}
+}
+
+func TestSSH_MarshalKRLEmpty(t *testing.T) {
+	krl := marshalKRL(&revocationsEntry{}, []ssh.PublicKey{}, time.Unix(0, 0))
+	// magic, format version, krl version, date, flags, reserved and comment
+	if len(krl) != 8+4+8+8+8+4+4 {
+		t.Fatalf("bad empty KRL: %x", krl)
+	}
+}
@@ -95,10 +95,15 @@ func (b *backend) pathSign(ctx context.Context, req *logical.Request, data *fram
 		return logical.ErrorResponse(fmt.Sprintf("Unknown role: %s", roleName)), nil
 	}
 
-	return b.pathSignCertificate(ctx, req, data, role)
+	certificateType, err := b.calculateCertificateType(data, role)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	return b.pathSignCertificate(ctx, req, data, role, certificateType)
 }
 
-func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request, data *framework.FieldData, role *sshRole) (*logical.Response, error) {
+func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request, data *framework.FieldData, role *sshRole, certificateType uint32) (*logical.Response, error) {
 	publicKey := data.Get("public_key").(string)
 	if publicKey == "" {
 		return logical.ErrorResponse("missing public_key"), nil
@@ -116,9 +121,12 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	certificateType, err := b.calculateCertificateType(data, role)
+	revocations, err := fetchRevocations(ctx, req.Storage)
 	if err != nil {
-		return logical.ErrorResponse(err.Error()), nil
+		return nil, err
+	}
+	if strutil.StrListContains(revocations.KeyIDs, keyId) {
+		return logical.ErrorResponse(fmt.Sprintf("key id %q has been revoked", keyId)), nil
 	}
 
 	var parsedPrincipals []string
@@ -149,7 +157,20 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	privateKeyEntry, err := caKey(ctx, req.Storage, caPrivateKey)
+	// Host certificates are signed by the host CA if there is one
+	caType := caTypeUser
+	if certificateType == ssh.HostCert {
+		caType, err = hostCAType(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+	}
+	storage, err := caStorageFor(caType)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKeyEntry, err := caKey(ctx, req.Storage, storage.privateKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CA private key: %v", err)
 	}
@@ -0,0 +1,116 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ssh"
+)
+
+func pathSignHost(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "sign-host/" + framework.GenericNameRegex("role"),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathSignHost,
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"role": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `The desired role with configuration for this request.`,
+			},
+			"ttl": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `The requested Time To Live for the SSH certificate;
+sets the expiration date. If not specified
+the role default, backend default, or system
+default TTL is used, in that order. Cannot
+be later than the role max TTL.`,
+			},
+			"public_key": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `SSH host public key that should be signed.`,
+			},
+			"valid_principals": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Hostnames that the certificate should be signed for. They must be allowed by the allowed_domains of the role.`,
+			},
+			"key_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Key id that the created certificate should have. If not specified, the display name of the token will be used.`,
+			},
+			"critical_options": &framework.FieldSchema{
+				Type:        framework.TypeMap,
+				Description: `Critical options that the certificate should be signed for.`,
+			},
+			"extensions": &framework.FieldSchema{
+				Type:        framework.TypeMap,
+				Description: `Extensions that the certificate should be signed for.`,
+			},
+		},
+
+		HelpSynopsis: `Request signing an SSH host key using a certain role.`,
+		HelpDescription: `This path signs host keys according to the policy of the given role, which
+must allow host certificates. The hostnames are validated against the
+allowed_domains of the role. Along with the certificate, the known_hosts lines
+trusting the host CA for these hostnames are returned.`,
+	}
+}
+
+func (b *backend) pathSignHost(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("role").(string)
+
+	role, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("Unknown role: %s", roleName)), nil
+	}
+	if !role.AllowHostCertificates {
+		return logical.ErrorResponse("host certificates are not allowed by role"), nil
+	}
+
+	// A host certificate without principals would be valid for any host
+	principals := strutil.RemoveDuplicates(strutil.ParseStringSlice(data.Get("valid_principals").(string), ","), false)
+	if len(principals) == 0 {
+		return logical.ErrorResponse("missing valid_principals"), nil
+	}
+	for _, principal := range principals {
+		if strings.ContainsAny(principal, "*?") {
+			return logical.ErrorResponse(fmt.Sprintf("wildcard hostname %q is not allowed in valid_principals", principal)), nil
+		}
+	}
+
+	resp, err := b.pathSignCertificate(ctx, req, data, role, ssh.HostCert)
+	if err != nil || resp == nil || resp.IsError() {
+		return resp, err
+	}
+
+	caType, err := hostCAType(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	publicKeys, err := trustedCAPublicKeys(ctx, req.Storage, caType)
+	if err != nil {
+		return nil, err
+	}
+	resp.Data["known_hosts"] = knownHostsLines(publicKeys, principals)
+
+	return resp, nil
+}
+
+// knownHostsLines returns the known_hosts lines trusting the given CA keys
+// for the host certificates matching the patterns
+func knownHostsLines(publicKeys []string, patterns []string) string {
+	var lines []string
+	for _, publicKey := range publicKeys {
+		lines = append(lines, fmt.Sprintf("@cert-authority %s %s", strings.Join(patterns, ","), strings.TrimSpace(publicKey)))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,155 @@
+package ssh
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSH_SignHost(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Backend(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	userCAKey := mustRequest(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"key_type": "ecdsa-p256",
+	}).Data["public_key"].(string)
+	mustRequest(logical.UpdateOperation, "roles/hosts", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_host_certificates": true,
+		"allowed_domains":         "example.com",
+		"allow_subdomains":        true,
+	})
+	mustRequest(logical.UpdateOperation, "roles/users", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+	})
+
+	hostPublicKey, _, err := generateSSHKeyPair("ed25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signHost := func(role, principals string) (*logical.Response, error) {
+		return request(logical.UpdateOperation, "sign-host/"+role, map[string]interface{}{
+			"public_key":       hostPublicKey,
+			"valid_principals": principals,
+		})
+	}
+
+	// Without a host CA, host certificates are signed by the user CA
+	resp := mustRequest(logical.UpdateOperation, "sign-host/hosts", map[string]interface{}{
+		"public_key":       hostPublicKey,
+		"valid_principals": "web.example.com",
+	})
+	if resp.Data["known_hosts"] != "@cert-authority web.example.com "+strings.TrimSpace(userCAKey)+"\n" {
+		t.Fatalf("bad known_hosts: %q", resp.Data["known_hosts"])
+	}
+
+	hostCAKey := mustRequest(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"ca_type":  "host",
+		"key_type": "ed25519",
+	}).Data["public_key"].(string)
+	resp = mustRequest(logical.ReadOperation, "config/ca", map[string]interface{}{
+		"ca_type": "host",
+	})
+	if resp.Data["public_key"] != hostCAKey || hostCAKey == userCAKey {
+		t.Fatalf("bad host CA: %#v", resp.Data)
+	}
+
+	for _, principals := range []string{"", "example.com", "web.example.org", "*.example.com", "web.example.com,evil.com"} {
+		resp, err := signHost("hosts", principals)
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected an error for principals %q, got: err: %v resp: %#v", principals, err, resp)
+		}
+	}
+	resp, err = signHost("users", "web.example.com")
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a role without host certificates, got: err: %v resp: %#v", err, resp)
+	}
+
+	resp, err = signHost("hosts", "web.example.com,db.eu.example.com")
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	knownHosts := resp.Data["known_hosts"].(string)
+	if knownHosts != "@cert-authority db.eu.example.com,web.example.com "+strings.TrimSpace(hostCAKey)+"\n" {
+		t.Fatalf("bad known_hosts: %q", knownHosts)
+	}
+
+	// The certificate is accepted by a client trusting the known_hosts line
+	_, hosts, caKey, _, _, err := ssh.ParseKnownHosts([]byte(knownHosts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 {
+		t.Fatalf("bad hosts: %#v", hosts)
+	}
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(resp.Data["signed_key"].(string)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := parsed.(*ssh.Certificate)
+	checker := ssh.CertChecker{
+		IsHostAuthority: func(auth ssh.PublicKey, address string) bool {
+			return string(auth.Marshal()) == string(caKey.Marshal())
+		},
+	}
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22}
+	if err := checker.CheckHostKey("web.example.com:22", addr, cert); err != nil {
+		t.Fatalf("bad host certificate: %v", err)
+	}
+	if err := checker.CheckHostKey("mail.example.com:22", addr, cert); err == nil {
+		t.Fatal("expected an error for a host not in the principals")
+	}
+
+	// User certificates are still signed by the user CA
+	userPublicKey, _, err := generateSSHKeyPair("ed25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = mustRequest(logical.UpdateOperation, "sign/users", map[string]interface{}{
+		"public_key": userPublicKey,
+	})
+	parsed, _, _, _, err = ssh.ParseAuthorizedKey([]byte(resp.Data["signed_key"].(string)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.(*ssh.Certificate).SignatureKey.Type() != ssh.KeyAlgoECDSA256 {
+		t.Fatalf("bad signature key: %s", parsed.(*ssh.Certificate).SignatureKey.Type())
+	}
+
+	body := string(mustRequest(logical.ReadOperation, "known_hosts", map[string]interface{}{
+		"host_patterns": "*.example.com,example.com",
+	}).Data[logical.HTTPRawBody].([]byte))
+	if body != "@cert-authority *.example.com,example.com "+strings.TrimSpace(hostCAKey)+"\n" {
+		t.Fatalf("bad known_hosts body: %q", body)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	cache "github.com/patrickmn/go-cache"
@@ -40,6 +41,8 @@ func Backend() *backend {
 	}
 
 	b.usedCodes = cache.New(0, 30*time.Second)
+	b.failedValidations = cache.New(0, 30*time.Second)
+	b.keyLocks = locksutil.CreateLocks()
 
 	return &b
 }
@@ -47,9 +50,17 @@ func Backend() *backend {
 type backend struct {
 	*framework.Backend
 
+	// usedCodes holds the last time step validated for each TOTP key, to
+	// reject replays
 	usedCodes *cache.Cache
+
+	// failedValidations counts the failed validations of each key
+	failedValidations *cache.Cache
+
+	keyLocks []*locksutil.LockEntry
 }
 
 const backendHelp = `
-The TOTP backend dynamically generates time-based one-time use passwords.
+The TOTP backend dynamically generates time-based and counter-based one-time
+use passwords, and validates them.
 `
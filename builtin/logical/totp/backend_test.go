@@ -13,6 +13,7 @@ import (
 	logicaltest "github.com/hashicorp/vault/logical/testing"
 	"github.com/mitchellh/mapstructure"
 	otplib "github.com/pquerna/otp"
+	hotplib "github.com/pquerna/otp/hotp"
 	totplib "github.com/pquerna/otp/totp"
 )
 
@@ -1130,3 +1131,193 @@ func testAccStepValidateCode(t *testing.T, name string, code string, valid, expe
 		},
 	}
 }
+
+func testTOTPRequest(t *testing.T, b logical.Backend, s logical.Storage) func(logical.Operation, string, map[string]interface{}) *logical.Response {
+	return func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   s,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("bad: path: %s err: %v", path, err)
+		}
+		return resp
+	}
+}
+
+func TestBackend_hotpKey(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := testTOTPRequest(t, b, config.StorageView)
+
+	key, err := createKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hotpCode := func(counter uint64) string {
+		code, err := hotplib.GenerateCode(key, counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+	validate := func(code string) *logical.Response {
+		return request(logical.UpdateOperation, "code/test", map[string]interface{}{
+			"code": code,
+		})
+	}
+
+	resp := request(logical.UpdateOperation, "keys/test", map[string]interface{}{
+		"url":        "otpauth://hotp/Vault:test@email.com?secret=" + key + "&counter=3",
+		"look_ahead": 2,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp = request(logical.ReadOperation, "keys/test", nil)
+	if resp.Data["type"] != "hotp" || resp.Data["counter"] != uint64(3) || resp.Data["look_ahead"] != uint(2) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Codes past the look-ahead window or before the counter are rejected
+	for _, counter := range []uint64{2, 6} {
+		if resp := validate(hotpCode(counter)); resp.IsError() || resp.Data["valid"] != false {
+			t.Fatalf("expected counter %d to be rejected, got: %#v", counter, resp)
+		}
+	}
+
+	// A code within the window resynchronizes the counter
+	if resp := validate(hotpCode(5)); resp.IsError() || resp.Data["valid"] != true {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if resp := validate(hotpCode(5)); resp.IsError() || resp.Data["valid"] != false {
+		t.Fatalf("expected a replayed code to be rejected, got: %#v", resp)
+	}
+	if resp := validate(hotpCode(4)); resp.IsError() || resp.Data["valid"] != false {
+		t.Fatalf("expected a past code to be rejected, got: %#v", resp)
+	}
+
+	// Generating codes advances the counter
+	resp = request(logical.ReadOperation, "code/test", nil)
+	if resp.Data["code"] != hotpCode(6) {
+		t.Fatalf("bad code: %#v", resp.Data)
+	}
+	resp = request(logical.ReadOperation, "code/test", nil)
+	if resp.Data["code"] != hotpCode(7) {
+		t.Fatalf("bad code: %#v", resp.Data)
+	}
+
+	// Generated HOTP keys carry their counter in the url
+	resp = request(logical.UpdateOperation, "keys/generated", map[string]interface{}{
+		"generate":     true,
+		"type":         "hotp",
+		"issuer":       "Vault",
+		"account_name": "test@email.com",
+		"counter":      10,
+		"qr_size":      0,
+	})
+	keyURL, err := url.Parse(resp.Data["url"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyURL.Host != "hotp" || keyURL.Query().Get("counter") != "10" {
+		t.Fatalf("bad url: %s", keyURL)
+	}
+}
+
+func TestBackend_totpReplay(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := testTOTPRequest(t, b, config.StorageView)
+
+	key, err := createKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	request(logical.UpdateOperation, "keys/test", map[string]interface{}{
+		"key": key,
+	})
+
+	now := time.Now()
+	code, err := totplib.GenerateCode(key, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := totplib.GenerateCode(key, now.Add(-30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := request(logical.UpdateOperation, "code/test", map[string]interface{}{"code": code})
+	if resp.IsError() || resp.Data["valid"] != true {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Neither the same code nor the code of an earlier time step within the
+	// skew can be used again
+	for _, replayed := range []string{code, previous} {
+		resp := request(logical.UpdateOperation, "code/test", map[string]interface{}{"code": replayed})
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected a replayed code to be rejected, got: %#v", resp)
+		}
+	}
+}
+
+func TestBackend_failedValidationsLimited(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := testTOTPRequest(t, b, config.StorageView)
+
+	key, err := createKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	request(logical.UpdateOperation, "keys/test", map[string]interface{}{
+		"key":                    key,
+		"max_failed_validations": 2,
+	})
+	code, err := totplib.GenerateCode(key, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := "000000"
+	if wrong == code {
+		wrong = "111111"
+	}
+
+	for i := 0; i < 2; i++ {
+		resp := request(logical.UpdateOperation, "code/test", map[string]interface{}{"code": wrong})
+		if resp.IsError() || resp.Data["valid"] != false {
+			t.Fatalf("bad: %#v", resp)
+		}
+	}
+
+	// Even the right code is refused once the limit is reached
+	resp := request(logical.UpdateOperation, "code/test", map[string]interface{}{"code": code})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected validation to be refused, got: %#v", resp)
+	}
+
+	// Failures are counted per key
+	request(logical.UpdateOperation, "keys/other", map[string]interface{}{
+		"key": key,
+	})
+	resp = request(logical.UpdateOperation, "code/other", map[string]interface{}{"code": code})
+	if resp.IsError() || resp.Data["valid"] != true {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
@@ -5,10 +5,11 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	otplib "github.com/pquerna/otp"
+	hotplib "github.com/pquerna/otp/hotp"
 	totplib "github.com/pquerna/otp/totp"
 )
 
@@ -22,7 +23,7 @@ func pathCode(b *backend) *framework.Path {
 			},
 			"code": &framework.FieldSchema{
 				Type:        framework.TypeString,
-				Description: "TOTP code to be validated.",
+				Description: "TOTP or HOTP code to be validated.",
 			},
 		},
 
@@ -39,6 +40,11 @@ func pathCode(b *backend) *framework.Path {
 func (b *backend) pathReadCode(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
 
+	// The counter of HOTP keys is advanced for each code
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
 	// Get the key
 	key, err := b.Key(ctx, req.Storage, name)
 	if err != nil {
@@ -48,20 +54,38 @@ func (b *backend) pathReadCode(ctx context.Context, req *logical.Request, data *
 		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
 	}
 
-	// Generate password using totp library
-	totpToken, err := totplib.GenerateCodeCustom(key.Key, time.Now(), totplib.ValidateOpts{
-		Period:    key.Period,
-		Digits:    key.Digits,
-		Algorithm: key.Algorithm,
-	})
-	if err != nil {
-		return nil, err
+	var code string
+	switch key.keyType() {
+	case keyTypeHOTP:
+		code, err = hotplib.GenerateCodeCustom(key.Key, key.Counter, hotplib.ValidateOpts{
+			Digits:    key.Digits,
+			Algorithm: key.Algorithm,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		key.Counter++
+		if err := b.storeKey(ctx, req.Storage, name, key); err != nil {
+			return nil, err
+		}
+
+	default:
+		// Generate password using totp library
+		code, err = totplib.GenerateCodeCustom(key.Key, time.Now(), totplib.ValidateOpts{
+			Period:    key.Period,
+			Digits:    key.Digits,
+			Algorithm: key.Algorithm,
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Return the secret
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"code": totpToken,
+			"code": code,
 		},
 	}, nil
 }
@@ -75,6 +99,10 @@ func (b *backend) pathValidateCode(ctx context.Context, req *logical.Request, da
 		return logical.ErrorResponse("the code value is required"), nil
 	}
 
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
 	// Get the key's stored values
 	key, err := b.Key(ctx, req.Storage, name)
 	if err != nil {
@@ -84,31 +112,66 @@ func (b *backend) pathValidateCode(ctx context.Context, req *logical.Request, da
 		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
 	}
 
-	usedName := fmt.Sprintf("%s_%s", name, code)
-
-	_, ok := b.usedCodes.Get(usedName)
-	if ok {
-		return logical.ErrorResponse("code already used; wait until the next time period"), nil
+	if key.MaxFailedValidations > 0 {
+		if failed, ok := b.failedValidations.Get(name); ok && failed.(int) >= key.MaxFailedValidations {
+			return logical.ErrorResponse("too many failed validations; wait before trying again"), nil
+		}
 	}
 
-	valid, err := totplib.ValidateCustom(code, key.Key, time.Now(), totplib.ValidateOpts{
-		Period:    key.Period,
-		Skew:      key.Skew,
-		Digits:    key.Digits,
-		Algorithm: key.Algorithm,
-	})
-	if err != nil && err != otplib.ErrValidateInputInvalidLength {
-		return logical.ErrorResponse("an error occured while validating the code"), err
+	var valid bool
+	switch key.keyType() {
+	case keyTypeHOTP:
+		// Accept the codes up to look_ahead counter values ahead, which
+		// resynchronizes the counter with the generator. Past codes are
+		// rejected, so each code can only be used once.
+		for counter := key.Counter; counter <= key.Counter+uint64(key.LookAhead); counter++ {
+			valid, err = hotplib.ValidateCustom(code, counter, key.Key, hotplib.ValidateOpts{
+				Digits:    key.Digits,
+				Algorithm: key.Algorithm,
+			})
+			if err != nil && err != otplib.ErrValidateInputInvalidLength {
+				return logical.ErrorResponse("an error occured while validating the code"), err
+			}
+			if valid {
+				key.Counter = counter + 1
+				if err := b.storeKey(ctx, req.Storage, name, key); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+
+	default:
+		var step uint64
+		step, valid, err = validateTOTP(code, key, time.Now())
+		if err != nil && err != otplib.ErrValidateInputInvalidLength {
+			return logical.ErrorResponse("an error occured while validating the code"), err
+		}
+
+		// Reject the codes of the time steps up to the last one validated,
+		// which covers the codes still valid within the skew
+		if valid {
+			if lastStep, ok := b.usedCodes.Get(name); ok && step <= lastStep.(uint64) {
+				return logical.ErrorResponse("code already used; wait until the next time period"), nil
+			}
+
+			// Take the key skew, add two for behind and in front, and
+			// multiple that by the period to cover the full possibility of
+			// the validity of the key
+			b.usedCodes.Set(name, step, time.Duration(
+				int64(time.Second)*
+					int64(key.Period)*
+					int64((2+key.Skew))))
+		}
 	}
 
-	// Take the key skew, add two for behind and in front, and multiple that by
-	// the period to cover the full possibility of the validity of the key
-	err = b.usedCodes.Add(usedName, nil, time.Duration(
-		int64(time.Second)*
-			int64(key.Period)*
-			int64((2+key.Skew))))
-	if err != nil {
-		return nil, errwrap.Wrapf("error adding code to used cache: {{err}}", err)
+	switch {
+	case valid:
+		b.failedValidations.Delete(name)
+	case key.MaxFailedValidations > 0:
+		if _, err := b.failedValidations.IncrementInt(name, 1); err != nil {
+			b.failedValidations.Set(name, 1, time.Duration(key.FailedValidationPeriod)*time.Second)
+		}
 	}
 
 	return &logical.Response{
@@ -118,10 +181,30 @@ func (b *backend) pathValidateCode(ctx context.Context, req *logical.Request, da
 	}, nil
 }
 
+// validateTOTP validates a TOTP code, returning the time step it belongs to
+func validateTOTP(code string, key *keyEntry, now time.Time) (uint64, bool, error) {
+	current := uint64(now.Unix()) / uint64(key.Period)
+	for step := current - uint64(key.Skew); step <= current+uint64(key.Skew); step++ {
+		valid, err := hotplib.ValidateCustom(code, step, key.Key, hotplib.ValidateOpts{
+			Digits:    key.Digits,
+			Algorithm: key.Algorithm,
+		})
+		if err != nil {
+			return 0, false, err
+		}
+		if valid {
+			return step, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
 const pathCodeHelpSyn = `
-Request time-based one-time use password or validate a password for a certain key .
+Request a one-time use password or validate a password for a certain key.
 `
 const pathCodeHelpDesc = `
-This path generates and validates time-based one-time use passwords for a certain key. 
-
+This path generates and validates one-time use passwords for a certain key.
+Time-based codes are valid within the skew of the key, and counter-based codes
+within its look-ahead window; each code can only be validated once. Failed
+validations are limited per key.
 `
@@ -11,12 +11,19 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	otplib "github.com/pquerna/otp"
+	hotplib "github.com/pquerna/otp/hotp"
 	totplib "github.com/pquerna/otp/totp"
 )
 
+const (
+	keyTypeTOTP = "totp"
+	keyTypeHOTP = "hotp"
+)
+
 func pathListKeys(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "keys/?$",
@@ -106,6 +113,36 @@ func pathKeys(b *backend) *framework.Path {
 				Type:        framework.TypeString,
 				Description: `A TOTP url string containing all of the parameters for key setup. Only used if generate is false.`,
 			},
+
+			"type": {
+				Type:        framework.TypeString,
+				Default:     keyTypeTOTP,
+				Description: `The type of the key: "totp" for time-based or "hotp" for counter-based tokens. Read from the url if given.`,
+			},
+
+			"counter": {
+				Type:        framework.TypeInt,
+				Default:     0,
+				Description: `The initial counter of an HOTP key. Read from the url if given.`,
+			},
+
+			"look_ahead": {
+				Type:        framework.TypeInt,
+				Default:     10,
+				Description: `The number of counter values past the current one that are accepted when validating an HOTP token, resynchronizing the counter. Only used if type is "hotp".`,
+			},
+
+			"max_failed_validations": {
+				Type:        framework.TypeInt,
+				Default:     5,
+				Description: `The number of failed validations allowed within failed_validation_period, after which validation of the key is refused until the period ends. If 0, failed validations are not limited.`,
+			},
+
+			"failed_validation_period": {
+				Type:        framework.TypeDurationSecond,
+				Default:     300,
+				Description: `The period over which failed validations are counted.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -136,12 +173,30 @@ func (b *backend) Key(ctx context.Context, s logical.Storage, n string) (*keyEnt
 	return &result, nil
 }
 
+func (b *backend) storeKey(ctx context.Context, s logical.Storage, n string, key *keyEntry) error {
+	entry, err := logical.StorageEntryJSON("key/"+n, key)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
 func (b *backend) pathKeyDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete(ctx, "key/"+data.Get("name").(string))
+	name := data.Get("name").(string)
+
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	err := req.Storage.Delete(ctx, "key/"+name)
 	if err != nil {
 		return nil, err
 	}
 
+	// Forget the validations of the deleted key
+	b.usedCodes.Delete(name)
+	b.failedValidations.Delete(name)
+
 	return nil, nil
 }
 
@@ -158,15 +213,24 @@ func (b *backend) pathKeyRead(ctx context.Context, req *logical.Request, data *f
 	algorithm := key.Algorithm.String()
 
 	// Return values of key
-	return &logical.Response{
+	resp := &logical.Response{
 		Data: map[string]interface{}{
-			"issuer":       key.Issuer,
-			"account_name": key.AccountName,
-			"period":       key.Period,
-			"algorithm":    algorithm,
-			"digits":       key.Digits,
+			"type":                     key.keyType(),
+			"issuer":                   key.Issuer,
+			"account_name":             key.AccountName,
+			"period":                   key.Period,
+			"algorithm":                algorithm,
+			"digits":                   key.Digits,
+			"max_failed_validations":   key.MaxFailedValidations,
+			"failed_validation_period": key.FailedValidationPeriod,
 		},
-	}, nil
+	}
+	if key.keyType() == keyTypeHOTP {
+		delete(resp.Data, "period")
+		resp.Data["counter"] = key.Counter
+		resp.Data["look_ahead"] = key.LookAhead
+	}
+	return resp, nil
 }
 
 func (b *backend) pathKeyList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
@@ -192,6 +256,11 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 	qrSize := data.Get("qr_size").(int)
 	keySize := data.Get("key_size").(int)
 	inputURL := data.Get("url").(string)
+	keyType := data.Get("type").(string)
+	counter := data.Get("counter").(int)
+	lookAhead := data.Get("look_ahead").(int)
+	maxFailedValidations := data.Get("max_failed_validations").(int)
+	failedValidationPeriod := data.Get("failed_validation_period").(int)
 
 	if generate {
 		if keyString != "" {
@@ -210,6 +279,14 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 			return logical.ErrorResponse("an error occured while parsing url string"), err
 		}
 
+		//Read type
+		switch urlObject.Host {
+		case keyTypeTOTP, keyTypeHOTP:
+			keyType = urlObject.Host
+		default:
+			return logical.ErrorResponse("the url type must be totp or hotp"), nil
+		}
+
 		//Set up query object
 		urlQuery := urlObject.Query()
 		path := strings.TrimPrefix(urlObject.Path, "/")
@@ -260,6 +337,22 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		if algorithmQuery != "" {
 			algorithm = algorithmQuery
 		}
+
+		//Read counter
+		counterQuery := urlQuery.Get("counter")
+		if counterQuery != "" {
+			counterInt, err := strconv.Atoi(counterQuery)
+			if err != nil {
+				return logical.ErrorResponse("an error occured while parsing counter value in url"), err
+			}
+			counter = counterInt
+		}
+	}
+
+	switch keyType {
+	case keyTypeTOTP, keyTypeHOTP:
+	default:
+		return logical.ErrorResponse("the type value must be totp or hotp"), nil
 	}
 
 	// Translate digits and algorithm to a format the totp library understands
@@ -306,6 +399,22 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		return logical.ErrorResponse("the key_size value must be greater than zero"), nil
 	}
 
+	if counter < 0 {
+		return logical.ErrorResponse("the counter value must be greater than or equal to zero"), nil
+	}
+
+	if lookAhead < 0 {
+		return logical.ErrorResponse("the look_ahead value must be greater than or equal to zero"), nil
+	}
+
+	if maxFailedValidations < 0 {
+		return logical.ErrorResponse("the max_failed_validations value must be greater than or equal to zero"), nil
+	}
+
+	if maxFailedValidations > 0 && failedValidationPeriod <= 0 {
+		return logical.ErrorResponse("the failed_validation_period value must be greater than zero"), nil
+	}
+
 	// Period, Skew and Key Size need to be unsigned ints
 	uintPeriod := uint(period)
 	uintSkew := uint(skew)
@@ -325,14 +434,30 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		}
 
 		// Generate a new key
-		keyObject, err := totplib.Generate(totplib.GenerateOpts{
-			Issuer:      issuer,
-			AccountName: accountName,
-			Period:      uintPeriod,
-			Digits:      keyDigits,
-			Algorithm:   keyAlgorithm,
-			SecretSize:  uintKeySize,
-		})
+		var keyObject *otplib.Key
+		var err error
+		switch keyType {
+		case keyTypeHOTP:
+			keyObject, err = hotplib.Generate(hotplib.GenerateOpts{
+				Issuer:      issuer,
+				AccountName: accountName,
+				Digits:      keyDigits,
+				Algorithm:   keyAlgorithm,
+				SecretSize:  uintKeySize,
+			})
+			if err == nil {
+				keyObject, err = withCounter(keyObject, counter)
+			}
+		default:
+			keyObject, err = totplib.Generate(totplib.GenerateOpts{
+				Issuer:      issuer,
+				AccountName: accountName,
+				Period:      uintPeriod,
+				Digits:      keyDigits,
+				Algorithm:   keyAlgorithm,
+				SecretSize:  uintKeySize,
+			})
+		}
 		if err != nil {
 			return logical.ErrorResponse("an error occured while generating a key"), err
 		}
@@ -381,26 +506,49 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		}
 	}
 
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
 	// Store it
-	entry, err := logical.StorageEntryJSON("key/"+name, &keyEntry{
-		Key:         keyString,
-		Issuer:      issuer,
-		AccountName: accountName,
-		Period:      uintPeriod,
-		Algorithm:   keyAlgorithm,
-		Digits:      keyDigits,
-		Skew:        uintSkew,
+	err := b.storeKey(ctx, req.Storage, name, &keyEntry{
+		Key:                    keyString,
+		Issuer:                 issuer,
+		AccountName:            accountName,
+		Period:                 uintPeriod,
+		Algorithm:              keyAlgorithm,
+		Digits:                 keyDigits,
+		Skew:                   uintSkew,
+		Type:                   keyType,
+		Counter:                uint64(counter),
+		LookAhead:              uint(lookAhead),
+		MaxFailedValidations:   maxFailedValidations,
+		FailedValidationPeriod: uint(failedValidationPeriod),
 	})
 	if err != nil {
 		return nil, err
 	}
-	if err := req.Storage.Put(ctx, entry); err != nil {
-		return nil, err
-	}
+
+	// A new key starts without validations
+	b.usedCodes.Delete(name)
+	b.failedValidations.Delete(name)
 
 	return response, nil
 }
 
+// withCounter adds the counter to the url of an HOTP key, which authenticator
+// apps need to start in sync
+func withCounter(key *otplib.Key, counter int) (*otplib.Key, error) {
+	keyURL, err := url.Parse(key.String())
+	if err != nil {
+		return nil, err
+	}
+	query := keyURL.Query()
+	query.Set("counter", strconv.Itoa(counter))
+	keyURL.RawQuery = query.Encode()
+	return otplib.NewKeyFromURL(keyURL.String())
+}
+
 type keyEntry struct {
 	Key         string           `json:"key" mapstructure:"key" structs:"key"`
 	Issuer      string           `json:"issuer" mapstructure:"issuer" structs:"issuer"`
@@ -409,6 +557,21 @@ type keyEntry struct {
 	Algorithm   otplib.Algorithm `json:"algorithm" mapstructure:"algorithm" structs:"algorithm"`
 	Digits      otplib.Digits    `json:"digits" mapstructure:"digits" structs:"digits"`
 	Skew        uint             `json:"skew" mapstructure:"skew" structs:"skew"`
+
+	// Type is empty for keys created before HOTP support, which are TOTP keys
+	Type      string `json:"type" mapstructure:"type" structs:"type"`
+	Counter   uint64 `json:"counter" mapstructure:"counter" structs:"counter"`
+	LookAhead uint   `json:"look_ahead" mapstructure:"look_ahead" structs:"look_ahead"`
+
+	MaxFailedValidations   int  `json:"max_failed_validations" mapstructure:"max_failed_validations" structs:"max_failed_validations"`
+	FailedValidationPeriod uint `json:"failed_validation_period" mapstructure:"failed_validation_period" structs:"failed_validation_period"`
+}
+
+func (k *keyEntry) keyType() string {
+	if k.Type == "" {
+		return keyTypeTOTP
+	}
+	return k.Type
 }
 
 const pathKeyHelpSyn = `
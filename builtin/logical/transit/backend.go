@@ -3,6 +3,7 @@ package transit
 import (
 	"context"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
@@ -24,6 +25,7 @@ func Backend(conf *logical.BackendConfig) *backend {
 			SealWrapStorage: []string{
 				"archive/",
 				"policy/",
+				"import/",
 			},
 		},
 
@@ -32,6 +34,8 @@ func Backend(conf *logical.BackendConfig) *backend {
 			// as the handler is greedy
 			b.pathConfig(),
 			b.pathRotate(),
+			b.pathImport(),
+			b.pathImportVersion(),
 			b.pathRewrap(),
 			b.pathKeys(),
 			b.pathListKeys(),
@@ -46,6 +50,15 @@ func Backend(conf *logical.BackendConfig) *backend {
 			b.pathVerify(),
 			b.pathBackup(),
 			b.pathRestore(),
+			b.pathWrappingKey(),
+			b.pathListFPEAlphabets(),
+			b.pathFPEAlphabets(),
+			b.pathListFPETemplates(),
+			b.pathFPETemplates(),
+			b.pathListFPERoles(),
+			b.pathFPERoles(),
+			b.pathFPEEncode(),
+			b.pathFPEDecode(),
 		},
 
 		Secrets:     []*framework.Secret{},
@@ -61,6 +74,10 @@ func Backend(conf *logical.BackendConfig) *backend {
 type backend struct {
 	*framework.Backend
 	lm *keysutil.LockManager
+
+	// wrappingKeyLock serializes the creation of the key used to wrap
+	// imported keys
+	wrappingKeyLock sync.Mutex
 }
 
 func (b *backend) invalidate(_ context.Context, key string) {
@@ -0,0 +1,180 @@
+package transit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const fpeBuiltinPrefix = "builtin/"
+
+// builtinAlphabets are the alphabets that are always available to templates
+var builtinAlphabets = map[string]string{
+	"builtin/numeric":           "0123456789",
+	"builtin/alphalower":        "abcdefghijklmnopqrstuvwxyz",
+	"builtin/alphaupper":        "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"builtin/alphanumericlower": "0123456789abcdefghijklmnopqrstuvwxyz",
+	"builtin/alphanumericupper": "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"builtin/alphanumeric":      "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+}
+
+// fpeNameRegex is like framework.GenericNameRegex but also matches the names
+// of the builtin alphabets and templates
+func fpeNameRegex(name string) string {
+	return fmt.Sprintf("(?P<%s>(builtin/)?\\w(([\\w-.]+)?\\w)?)", name)
+}
+
+// fpeAlphabet is the set of characters a value is made of. The position of
+// each character in the alphabet is its numeral value during encryption.
+type fpeAlphabet struct {
+	Alphabet string `json:"alphabet"`
+}
+
+func (a *fpeAlphabet) validate() error {
+	runes := []rune(a.Alphabet)
+	if len(runes) < 2 || len(runes) > 1<<16 {
+		return fmt.Errorf("alphabet must contain between 2 and %d characters", 1<<16)
+	}
+	seen := make(map[rune]bool, len(runes))
+	for _, r := range runes {
+		if seen[r] {
+			return fmt.Errorf("alphabet contains %q more than once", r)
+		}
+		seen[r] = true
+	}
+	return nil
+}
+
+func (b *backend) pathListFPEAlphabets() *framework.Path {
+	return &framework.Path{
+		Pattern: "alphabet/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathFPEAlphabetList,
+		},
+
+		HelpSynopsis:    pathFPEAlphabetHelpSyn,
+		HelpDescription: pathFPEAlphabetHelpDesc,
+	}
+}
+
+func (b *backend) pathFPEAlphabets() *framework.Path {
+	return &framework.Path{
+		Pattern: "alphabet/" + fpeNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the alphabet",
+			},
+
+			"alphabet": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The set of characters the values are made of.
+Each character may only appear once.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathFPEAlphabetWrite,
+			logical.ReadOperation:   b.pathFPEAlphabetRead,
+			logical.DeleteOperation: b.pathFPEAlphabetDelete,
+		},
+
+		HelpSynopsis:    pathFPEAlphabetHelpSyn,
+		HelpDescription: pathFPEAlphabetHelpDesc,
+	}
+}
+
+func (b *backend) getFPEAlphabet(ctx context.Context, s logical.Storage, name string) (*fpeAlphabet, error) {
+	if alphabet, ok := builtinAlphabets[name]; ok {
+		return &fpeAlphabet{Alphabet: alphabet}, nil
+	}
+
+	entry, err := s.Get(ctx, "alphabet/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result fpeAlphabet
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathFPEAlphabetList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, "alphabet/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathFPEAlphabetWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if strings.HasPrefix(name, fpeBuiltinPrefix) {
+		return logical.ErrorResponse("builtin alphabets cannot be modified"), logical.ErrInvalidRequest
+	}
+
+	alphabet := &fpeAlphabet{
+		Alphabet: d.Get("alphabet").(string),
+	}
+	if err := alphabet.validate(); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	entry, err := logical.StorageEntryJSON("alphabet/"+name, alphabet)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathFPEAlphabetRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	alphabet, err := b.getFPEAlphabet(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if alphabet == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"alphabet": alphabet.Alphabet,
+		},
+	}, nil
+}
+
+func (b *backend) pathFPEAlphabetDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if strings.HasPrefix(name, fpeBuiltinPrefix) {
+		return logical.ErrorResponse("builtin alphabets cannot be deleted"), logical.ErrInvalidRequest
+	}
+
+	if err := req.Storage.Delete(ctx, "alphabet/"+name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathFPEAlphabetHelpSyn = `Manage the alphabets used for format-preserving encoding`
+
+const pathFPEAlphabetHelpDesc = `
+This path is used to manage the named alphabets that templates refer to. An
+alphabet is the set of characters the encoded parts of a value are made of;
+encoded values only contain characters of the same alphabet. Builtin
+alphabets are available under the "builtin/" prefix and cannot be changed.
+`
@@ -0,0 +1,291 @@
+package transit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/mapstructure"
+)
+
+// FPEBatchRequestItem represents a request item for batch encoding and
+// decoding
+type FPEBatchRequestItem struct {
+	// Value to be encoded or decoded
+	Value string `json:"value" structs:"value" mapstructure:"value"`
+
+	// Base64 encoded tweak, for roles with a supplied tweak
+	Tweak string `json:"tweak" structs:"tweak" mapstructure:"tweak"`
+
+	// The key version to be used
+	KeyVersion int `json:"key_version" structs:"key_version" mapstructure:"key_version"`
+}
+
+// FPEBatchResponseItem represents a response item for batch encoding and
+// decoding
+type FPEBatchResponseItem struct {
+	// EncodedValue for the value present in the corresponding batch request
+	// item
+	EncodedValue string `json:"encoded_value,omitempty" structs:"encoded_value" mapstructure:"encoded_value"`
+
+	// DecodedValue for the value present in the corresponding batch request
+	// item
+	DecodedValue string `json:"decoded_value,omitempty" structs:"decoded_value" mapstructure:"decoded_value"`
+
+	// Error, if set represents a failure encountered while encoding or
+	// decoding a corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
+func fpeEncodeFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"role": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Name of the role",
+		},
+
+		"value": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "The value, in the format described by the role's template",
+		},
+
+		"tweak": &framework.FieldSchema{
+			Type: framework.TypeString,
+			Description: `Base64 encoded 7 byte tweak. Required for roles with a
+supplied tweak; the same tweak must be used to decode a value.`,
+		},
+
+		"key_version": &framework.FieldSchema{
+			Type: framework.TypeInt,
+			Description: `The version of the key to use. Must be 0 (for latest)
+or a value greater than or equal to the min_encryption_version (for encoding)
+or min_decryption_version (for decoding) configured on the key.`,
+		},
+	}
+}
+
+func (b *backend) pathFPEEncode() *framework.Path {
+	return &framework.Path{
+		Pattern: "encode/" + framework.GenericNameRegex("role"),
+		Fields:  fpeEncodeFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathFPEEncodeWrite,
+		},
+
+		HelpSynopsis:    pathFPEEncodeHelpSyn,
+		HelpDescription: pathFPEEncodeHelpDesc,
+	}
+}
+
+func (b *backend) pathFPEDecode() *framework.Path {
+	return &framework.Path{
+		Pattern: "decode/" + framework.GenericNameRegex("role"),
+		Fields:  fpeEncodeFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathFPEDecodeWrite,
+		},
+
+		HelpSynopsis:    pathFPEDecodeHelpSyn,
+		HelpDescription: pathFPEDecodeHelpDesc,
+	}
+}
+
+func (b *backend) pathFPEEncodeWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.fpeTransform(ctx, req, d, true)
+}
+
+func (b *backend) pathFPEDecodeWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.fpeTransform(ctx, req, d, false)
+}
+
+func (b *backend) fpeTransform(ctx context.Context, req *logical.Request, d *framework.FieldData, encode bool) (*logical.Response, error) {
+	batchInputRaw := d.Raw["batch_input"]
+	var batchInputItems []FPEBatchRequestItem
+	if batchInputRaw != nil {
+		if err := mapstructure.Decode(batchInputRaw, &batchInputItems); err != nil {
+			return nil, fmt.Errorf("failed to parse batch input: %v", err)
+		}
+
+		if len(batchInputItems) == 0 {
+			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+		}
+	} else {
+		valueRaw, ok := d.GetOk("value")
+		if !ok {
+			return logical.ErrorResponse("missing value"), logical.ErrInvalidRequest
+		}
+
+		batchInputItems = []FPEBatchRequestItem{
+			{
+				Value:      valueRaw.(string),
+				Tweak:      d.Get("tweak").(string),
+				KeyVersion: d.Get("key_version").(int),
+			},
+		}
+	}
+
+	roleName := d.Get("role").(string)
+	role, err := b.getFPERole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("role %q not found", roleName)), logical.ErrInvalidRequest
+	}
+	if !encode && role.Type == fpeRoleTypeMasking {
+		return logical.ErrorResponse("values encoded with a masking role cannot be decoded"), logical.ErrInvalidRequest
+	}
+
+	template, err := b.getFPETemplate(ctx, req.Storage, role.Template)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return logical.ErrorResponse(fmt.Sprintf("template %q not found", role.Template)), logical.ErrInvalidRequest
+	}
+
+	var p *keysutil.Policy
+	var alphabet []rune
+	numerals := map[rune]uint16{}
+	if role.Type == fpeRoleTypeFPE {
+		a, err := b.getFPEAlphabet(ctx, req.Storage, template.Alphabet)
+		if err != nil {
+			return nil, err
+		}
+		if a == nil {
+			return logical.ErrorResponse(fmt.Sprintf("alphabet %q not found", template.Alphabet)), logical.ErrInvalidRequest
+		}
+		alphabet = []rune(a.Alphabet)
+		for i, r := range alphabet {
+			numerals[r] = uint16(i)
+		}
+
+		var lock *sync.RWMutex
+		p, lock, err = b.lm.GetPolicyShared(ctx, req.Storage, role.KeyName)
+		if lock != nil {
+			defer lock.RUnlock()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return logical.ErrorResponse(fmt.Sprintf("key %q not found", role.KeyName)), logical.ErrInvalidRequest
+		}
+	}
+
+	// Process batch request items. If encoding of any request item fails,
+	// respectively mark the error in the response collection and continue
+	// to process other items.
+	batchResponseItems := make([]FPEBatchResponseItem, len(batchInputItems))
+	for i, item := range batchInputItems {
+		var f func([]rune) ([]rune, error)
+		switch role.Type {
+		case fpeRoleTypeMasking:
+			mask := []rune(role.MaskingCharacter)[0]
+			f = func(in []rune) ([]rune, error) {
+				out := make([]rune, len(in))
+				for i := range out {
+					out[i] = mask
+				}
+				return out, nil
+			}
+
+		case fpeRoleTypeFPE:
+			tweak, err := role.fpeTweak(item.Tweak)
+			if err != nil {
+				batchResponseItems[i].Error = err.Error()
+				continue
+			}
+			f = func(in []rune) ([]rune, error) {
+				x := make([]uint16, len(in))
+				for i, r := range in {
+					n, ok := numerals[r]
+					if !ok {
+						return nil, errutil.UserError{Err: fmt.Sprintf("value contains %q which is not in the alphabet", r)}
+					}
+					x[i] = n
+				}
+
+				var y []uint16
+				var err error
+				if encode {
+					y, err = p.EncryptFPE(item.KeyVersion, tweak, len(alphabet), x)
+				} else {
+					y, err = p.DecryptFPE(item.KeyVersion, tweak, len(alphabet), x)
+				}
+				if err != nil {
+					return nil, err
+				}
+
+				out := make([]rune, len(y))
+				for i, n := range y {
+					out[i] = alphabet[n]
+				}
+				return out, nil
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown role type %q", role.Type)
+		}
+
+		out, err := template.transform(item.Value, f)
+		if err != nil {
+			if _, ok := err.(errutil.InternalError); ok {
+				return nil, err
+			}
+			batchResponseItems[i].Error = err.Error()
+			continue
+		}
+
+		if encode {
+			batchResponseItems[i].EncodedValue = out
+		} else {
+			batchResponseItems[i].DecodedValue = out
+		}
+	}
+
+	resp := &logical.Response{}
+	if batchInputRaw != nil {
+		resp.Data = map[string]interface{}{
+			"batch_results": batchResponseItems,
+		}
+		return resp, nil
+	}
+
+	if batchResponseItems[0].Error != "" {
+		return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
+	}
+	if encode {
+		resp.Data = map[string]interface{}{
+			"encoded_value": batchResponseItems[0].EncodedValue,
+		}
+	} else {
+		resp.Data = map[string]interface{}{
+			"decoded_value": batchResponseItems[0].DecodedValue,
+		}
+	}
+	return resp, nil
+}
+
+const pathFPEEncodeHelpSyn = `Encode a value or a batch of values using a named role`
+
+const pathFPEEncodeHelpDesc = `
+This path uses the named role from the request path to encode a value or a
+batch of values. The encoded value has the same format as the input: the
+parts matched by the role's template are encrypted with format-preserving
+encryption, or masked for masking roles, and the rest is kept as it is.
+`
+
+const pathFPEDecodeHelpSyn = `Decode a value or a batch of values using a named role`
+
+const pathFPEDecodeHelpDesc = `
+This path uses the named role from the request path to decode a value or a
+batch of values previously encoded with the same role. Values encoded with a
+masking role cannot be decoded.
+`
@@ -0,0 +1,171 @@
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTransit_FPE(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	doRequest := func(op logical.Operation, path string, data map[string]interface{}, errExpected bool) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   s,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if errExpected {
+			if err == nil && (resp == nil || !resp.IsError()) {
+				t.Fatalf("expected error for %s, got %#v", path, resp)
+			}
+			return resp
+		}
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+		return resp
+	}
+
+	doRequest(logical.UpdateOperation, "keys/fpe", map[string]interface{}{
+		"type": "aes256-ff3-1",
+	}, false)
+	doRequest(logical.UpdateOperation, "keys/aes", nil, false)
+
+	// FPE keys can't be used for regular encryption
+	doRequest(logical.UpdateOperation, "encrypt/fpe", map[string]interface{}{
+		"plaintext": "Zm9v",
+	}, true)
+
+	// Roles require an FPE key and an existing template
+	doRequest(logical.UpdateOperation, "role/bad", map[string]interface{}{
+		"template": "builtin/creditcardnumber",
+		"key":      "aes",
+	}, true)
+	doRequest(logical.UpdateOperation, "role/bad", map[string]interface{}{
+		"template": "nonexistent",
+		"key":      "fpe",
+	}, true)
+
+	doRequest(logical.UpdateOperation, "role/cc", map[string]interface{}{
+		"template": "builtin/creditcardnumber",
+		"key":      "fpe",
+	}, false)
+
+	tweak := base64.StdEncoding.EncodeToString([]byte("abcdefg"))
+	resp := doRequest(logical.UpdateOperation, "encode/cc", map[string]interface{}{
+		"value": "4111-1111-1111-1111",
+		"tweak": tweak,
+	}, false)
+	encoded := resp.Data["encoded_value"].(string)
+	if !regexp.MustCompile(`^\d{4}-\d{4}-\d{4}-\d{4}$`).MatchString(encoded) || encoded == "4111-1111-1111-1111" {
+		t.Fatalf("bad encoded value: %q", encoded)
+	}
+
+	// Encoding is deterministic for the same tweak, so the encoded values
+	// can be searched for
+	resp = doRequest(logical.UpdateOperation, "encode/cc", map[string]interface{}{
+		"value": "4111-1111-1111-1111",
+		"tweak": tweak,
+	}, false)
+	if resp.Data["encoded_value"].(string) != encoded {
+		t.Fatalf("expected %q, got %q", encoded, resp.Data["encoded_value"])
+	}
+
+	resp = doRequest(logical.UpdateOperation, "decode/cc", map[string]interface{}{
+		"value": encoded,
+		"tweak": tweak,
+	}, false)
+	if resp.Data["decoded_value"].(string) != "4111-1111-1111-1111" {
+		t.Fatalf("bad decoded value: %#v", resp.Data)
+	}
+
+	// A supplied tweak is required
+	doRequest(logical.UpdateOperation, "encode/cc", map[string]interface{}{
+		"value": "4111-1111-1111-1111",
+	}, true)
+
+	// Batch input reports errors per item
+	resp = doRequest(logical.UpdateOperation, "encode/cc", map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"value": "4111111111111111", "tweak": tweak},
+			map[string]interface{}{"value": "not a card number", "tweak": tweak},
+		},
+	}, false)
+	results := resp.Data["batch_results"].([]FPEBatchResponseItem)
+	if len(results) != 2 || results[0].Error != "" || results[1].Error == "" {
+		t.Fatalf("bad: %#v", results)
+	}
+	if results[0].EncodedValue != encoded[0:4]+encoded[5:9]+encoded[10:14]+encoded[15:19] {
+		t.Fatalf("expected the same digits without separators, got %q", results[0].EncodedValue)
+	}
+
+	// Custom alphabets and templates, with an internal tweak
+	doRequest(logical.UpdateOperation, "alphabet/hex", map[string]interface{}{
+		"alphabet": "0123456789abcdef",
+	}, false)
+	doRequest(logical.UpdateOperation, "alphabet/dup", map[string]interface{}{
+		"alphabet": "00",
+	}, true)
+	doRequest(logical.UpdateOperation, "alphabet/builtin/numeric", map[string]interface{}{
+		"alphabet": "01",
+	}, true)
+	doRequest(logical.UpdateOperation, "template/id", map[string]interface{}{
+		"pattern":  `id-([0-9a-f]+)`,
+		"alphabet": "hex",
+	}, false)
+	doRequest(logical.UpdateOperation, "template/nogroups", map[string]interface{}{
+		"pattern":  `[0-9a-f]+`,
+		"alphabet": "hex",
+	}, true)
+	doRequest(logical.UpdateOperation, "role/id", map[string]interface{}{
+		"template":     "id",
+		"key":          "fpe",
+		"tweak_source": "internal",
+	}, false)
+
+	resp = doRequest(logical.UpdateOperation, "encode/id", map[string]interface{}{
+		"value": "id-deadbeef",
+	}, false)
+	encoded = resp.Data["encoded_value"].(string)
+	if !regexp.MustCompile(`^id-[0-9a-f]{8}$`).MatchString(encoded) {
+		t.Fatalf("bad encoded value: %q", encoded)
+	}
+	resp = doRequest(logical.UpdateOperation, "decode/id", map[string]interface{}{
+		"value": encoded,
+	}, false)
+	if resp.Data["decoded_value"].(string) != "id-deadbeef" {
+		t.Fatalf("bad decoded value: %#v", resp.Data)
+	}
+
+	// Masking
+	doRequest(logical.UpdateOperation, "role/ssn", map[string]interface{}{
+		"type":              "masking",
+		"template":          "builtin/socialsecuritynumber",
+		"masking_character": "#",
+	}, false)
+	resp = doRequest(logical.UpdateOperation, "encode/ssn", map[string]interface{}{
+		"value": "123-45-6789",
+	}, false)
+	if resp.Data["encoded_value"].(string) != "###-##-####" {
+		t.Fatalf("bad masked value: %#v", resp.Data)
+	}
+	doRequest(logical.UpdateOperation, "decode/ssn", map[string]interface{}{
+		"value": "###-##-####",
+	}, true)
+
+	resp = doRequest(logical.ListOperation, "role/", nil, false)
+	if keys := resp.Data["keys"].([]string); !reflect.DeepEqual(keys, []string{"cc", "id", "ssn"}) {
+		t.Fatalf("bad: %#v", keys)
+	}
+	resp = doRequest(logical.ReadOperation, "alphabet/builtin/numeric", nil, false)
+	if resp.Data["alphabet"].(string) != "0123456789" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
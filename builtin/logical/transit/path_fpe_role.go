@@ -0,0 +1,270 @@
+package transit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	fpeRoleTypeFPE     = "fpe"
+	fpeRoleTypeMasking = "masking"
+
+	fpeTweakSourceSupplied = "supplied"
+	fpeTweakSourceInternal = "internal"
+)
+
+// fpeRole ties a template to either a format-preserving encryption key or to
+// a masking character
+type fpeRole struct {
+	Type             string `json:"type"`
+	Template         string `json:"template"`
+	KeyName          string `json:"key"`
+	TweakSource      string `json:"tweak_source"`
+	Tweak            []byte `json:"tweak"`
+	MaskingCharacter string `json:"masking_character"`
+}
+
+func (b *backend) pathListFPERoles() *framework.Path {
+	return &framework.Path{
+		Pattern: "role/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathFPERoleList,
+		},
+
+		HelpSynopsis:    pathFPERoleHelpSyn,
+		HelpDescription: pathFPERoleHelpDesc,
+	}
+}
+
+func (b *backend) pathFPERoles() *framework.Path {
+	return &framework.Path{
+		Pattern: "role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role",
+			},
+
+			"type": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: fpeRoleTypeFPE,
+				Description: `The type of the role. "fpe" encrypts values with a
+format-preserving encryption key, "masking" replaces the encoded characters
+with the masking character; masked values cannot be decoded. Defaults to "fpe".`,
+			},
+
+			"template": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the template describing the values encoded with the role",
+			},
+
+			"key": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Name of the "aes256-ff3-1" key used for "fpe" roles`,
+			},
+
+			"tweak_source": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: fpeTweakSourceSupplied,
+				Description: `Where the tweak of "fpe" roles comes from. With
+"supplied", each request must provide a 7 byte tweak; with "internal", a tweak
+is generated when the role is created and used for all values. Defaults to
+"supplied".`,
+			},
+
+			"masking_character": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "*",
+				Description: `The character used by "masking" roles. Defaults to "*".`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathFPERoleWrite,
+			logical.ReadOperation:   b.pathFPERoleRead,
+			logical.DeleteOperation: b.pathFPERoleDelete,
+		},
+
+		HelpSynopsis:    pathFPERoleHelpSyn,
+		HelpDescription: pathFPERoleHelpDesc,
+	}
+}
+
+func (b *backend) getFPERole(ctx context.Context, s logical.Storage, name string) (*fpeRole, error) {
+	entry, err := s.Get(ctx, "role/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result fpeRole
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathFPERoleList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, "role/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathFPERoleWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	role := &fpeRole{
+		Type:     d.Get("type").(string),
+		Template: d.Get("template").(string),
+	}
+
+	if role.Template == "" {
+		return logical.ErrorResponse("missing template"), logical.ErrInvalidRequest
+	}
+	template, err := b.getFPETemplate(ctx, req.Storage, role.Template)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return logical.ErrorResponse(fmt.Sprintf("template %q not found", role.Template)), logical.ErrInvalidRequest
+	}
+
+	switch role.Type {
+	case fpeRoleTypeFPE:
+		role.KeyName = d.Get("key").(string)
+		if role.KeyName == "" {
+			return logical.ErrorResponse("missing key"), logical.ErrInvalidRequest
+		}
+		p, lock, err := b.lm.GetPolicyShared(ctx, req.Storage, role.KeyName)
+		if lock != nil {
+			defer lock.RUnlock()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return logical.ErrorResponse(fmt.Sprintf("key %q not found", role.KeyName)), logical.ErrInvalidRequest
+		}
+		if !p.Type.FPESupported() {
+			return logical.ErrorResponse(fmt.Sprintf("key type %v does not support format-preserving encryption", p.Type)), logical.ErrInvalidRequest
+		}
+
+		role.TweakSource = d.Get("tweak_source").(string)
+		switch role.TweakSource {
+		case fpeTweakSourceSupplied:
+		case fpeTweakSourceInternal:
+			// Keep the tweak of an existing role, as changing it would make
+			// the values encoded before undecodable
+			existing, err := b.getFPERole(ctx, req.Storage, name)
+			if err != nil {
+				return nil, err
+			}
+			if existing != nil && len(existing.Tweak) == keysutil.FF3TweakSize {
+				role.Tweak = existing.Tweak
+			} else {
+				role.Tweak = make([]byte, keysutil.FF3TweakSize)
+				if _, err := rand.Read(role.Tweak); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return logical.ErrorResponse(fmt.Sprintf("unknown tweak source %q", role.TweakSource)), logical.ErrInvalidRequest
+		}
+
+	case fpeRoleTypeMasking:
+		role.MaskingCharacter = d.Get("masking_character").(string)
+		if len([]rune(role.MaskingCharacter)) != 1 {
+			return logical.ErrorResponse("masking character must be a single character"), logical.ErrInvalidRequest
+		}
+
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("unknown role type %q", role.Type)), logical.ErrInvalidRequest
+	}
+
+	entry, err := logical.StorageEntryJSON("role/"+name, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathFPERoleRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role, err := b.getFPERole(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"type":     role.Type,
+			"template": role.Template,
+		},
+	}
+	switch role.Type {
+	case fpeRoleTypeFPE:
+		resp.Data["key"] = role.KeyName
+		resp.Data["tweak_source"] = role.TweakSource
+	case fpeRoleTypeMasking:
+		resp.Data["masking_character"] = role.MaskingCharacter
+	}
+
+	return resp, nil
+}
+
+func (b *backend) pathFPERoleDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(ctx, "role/"+d.Get("name").(string)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// fpeTweak returns the tweak to use for a value encoded with the role
+func (r *fpeRole) fpeTweak(supplied string) ([]byte, error) {
+	if r.TweakSource == fpeTweakSourceInternal {
+		if supplied != "" {
+			return nil, fmt.Errorf("tweak cannot be supplied for roles with an internal tweak")
+		}
+		return r.Tweak, nil
+	}
+
+	if supplied == "" {
+		return nil, fmt.Errorf("missing tweak")
+	}
+	tweak, err := base64.StdEncoding.DecodeString(supplied)
+	if err != nil {
+		return nil, fmt.Errorf("failed to base64-decode tweak")
+	}
+	if len(tweak) != keysutil.FF3TweakSize {
+		return nil, fmt.Errorf("tweak must be %d bytes long", keysutil.FF3TweakSize)
+	}
+	return tweak, nil
+}
+
+const pathFPERoleHelpSyn = `Manage the roles used for format-preserving encoding`
+
+const pathFPERoleHelpDesc = `
+This path is used to manage the named roles that values are encoded and
+decoded with. A role ties a template to either an "aes256-ff3-1" key, in which
+case the values are encrypted with format-preserving encryption, or to a
+masking character, in which case the values are irreversibly masked.
+`
@@ -0,0 +1,256 @@
+package transit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const fpeTemplateTypeRegex = "regex"
+
+// builtinTemplates are the templates that are always available to roles
+var builtinTemplates = map[string]*fpeTemplate{
+	"builtin/creditcardnumber": &fpeTemplate{
+		Type:     fpeTemplateTypeRegex,
+		Pattern:  `(\d{4})-?(\d{4})-?(\d{4})-?(\d{4})`,
+		Alphabet: "builtin/numeric",
+	},
+	"builtin/socialsecuritynumber": &fpeTemplate{
+		Type:     fpeTemplateTypeRegex,
+		Pattern:  `(\d{3})-?(\d{2})-?(\d{4})`,
+		Alphabet: "builtin/numeric",
+	},
+}
+
+// fpeTemplate describes the format of the values a role encodes. The parts
+// of a value matched by the capture groups of the pattern are encoded, the
+// rest of the value is kept as it is.
+type fpeTemplate struct {
+	Type     string `json:"type"`
+	Pattern  string `json:"pattern"`
+	Alphabet string `json:"alphabet"`
+}
+
+func (t *fpeTemplate) regexp() (*regexp.Regexp, error) {
+	re, err := regexp.Compile("^(?:" + t.Pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+	if re.NumSubexp() == 0 {
+		return nil, fmt.Errorf("pattern must contain at least one capture group")
+	}
+	return re, nil
+}
+
+// transform applies f to the concatenation of the parts of value matched by
+// the capture groups of the template, and returns value with those parts
+// replaced by the output of f. f must not change the length of its input.
+func (t *fpeTemplate) transform(value string, f func([]rune) ([]rune, error)) (string, error) {
+	re, err := t.regexp()
+	if err != nil {
+		return "", err
+	}
+
+	match := re.FindStringSubmatchIndex(value)
+	if match == nil {
+		return "", fmt.Errorf("value does not match the template")
+	}
+
+	var input []rune
+	for i := 2; i < len(match); i += 2 {
+		if match[i] < 0 {
+			continue
+		}
+		input = append(input, []rune(value[match[i]:match[i+1]])...)
+	}
+
+	output, err := f(input)
+	if err != nil {
+		return "", err
+	}
+	if len(output) != len(input) {
+		return "", fmt.Errorf("encoded value has the wrong length")
+	}
+
+	var ret bytes.Buffer
+	last := 0
+	for i := 2; i < len(match); i += 2 {
+		if match[i] < 0 {
+			continue
+		}
+		n := len([]rune(value[match[i]:match[i+1]]))
+		ret.WriteString(value[last:match[i]])
+		ret.WriteString(string(output[:n]))
+		output = output[n:]
+		last = match[i+1]
+	}
+	ret.WriteString(value[last:])
+
+	return ret.String(), nil
+}
+
+func (b *backend) pathListFPETemplates() *framework.Path {
+	return &framework.Path{
+		Pattern: "template/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathFPETemplateList,
+		},
+
+		HelpSynopsis:    pathFPETemplateHelpSyn,
+		HelpDescription: pathFPETemplateHelpDesc,
+	}
+}
+
+func (b *backend) pathFPETemplates() *framework.Path {
+	return &framework.Path{
+		Pattern: "template/" + fpeNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the template",
+			},
+
+			"type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     fpeTemplateTypeRegex,
+				Description: `The type of the template. Currently, only "regex" is supported.`,
+			},
+
+			"pattern": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Regular expression the whole value must match. The
+parts of the value matched by capture groups are encoded.`,
+			},
+
+			"alphabet": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the alphabet the captured parts of the value are made of",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathFPETemplateWrite,
+			logical.ReadOperation:   b.pathFPETemplateRead,
+			logical.DeleteOperation: b.pathFPETemplateDelete,
+		},
+
+		HelpSynopsis:    pathFPETemplateHelpSyn,
+		HelpDescription: pathFPETemplateHelpDesc,
+	}
+}
+
+func (b *backend) getFPETemplate(ctx context.Context, s logical.Storage, name string) (*fpeTemplate, error) {
+	if template, ok := builtinTemplates[name]; ok {
+		return template, nil
+	}
+
+	entry, err := s.Get(ctx, "template/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result fpeTemplate
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathFPETemplateList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, "template/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathFPETemplateWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if strings.HasPrefix(name, fpeBuiltinPrefix) {
+		return logical.ErrorResponse("builtin templates cannot be modified"), logical.ErrInvalidRequest
+	}
+
+	template := &fpeTemplate{
+		Type:     d.Get("type").(string),
+		Pattern:  d.Get("pattern").(string),
+		Alphabet: d.Get("alphabet").(string),
+	}
+	if template.Type != fpeTemplateTypeRegex {
+		return logical.ErrorResponse(fmt.Sprintf("unknown template type %q", template.Type)), logical.ErrInvalidRequest
+	}
+	if _, err := template.regexp(); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	if template.Alphabet == "" {
+		return logical.ErrorResponse("missing alphabet"), logical.ErrInvalidRequest
+	}
+	alphabet, err := b.getFPEAlphabet(ctx, req.Storage, template.Alphabet)
+	if err != nil {
+		return nil, err
+	}
+	if alphabet == nil {
+		return logical.ErrorResponse(fmt.Sprintf("alphabet %q not found", template.Alphabet)), logical.ErrInvalidRequest
+	}
+
+	entry, err := logical.StorageEntryJSON("template/"+name, template)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathFPETemplateRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	template, err := b.getFPETemplate(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"type":     template.Type,
+			"pattern":  template.Pattern,
+			"alphabet": template.Alphabet,
+		},
+	}, nil
+}
+
+func (b *backend) pathFPETemplateDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if strings.HasPrefix(name, fpeBuiltinPrefix) {
+		return logical.ErrorResponse("builtin templates cannot be deleted"), logical.ErrInvalidRequest
+	}
+
+	if err := req.Storage.Delete(ctx, "template/"+name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathFPETemplateHelpSyn = `Manage the templates used for format-preserving encoding`
+
+const pathFPETemplateHelpDesc = `
+This path is used to manage the named templates that roles refer to. A
+template is a regular expression that values must match; the parts of a value
+matched by the capture groups of the expression are encoded using the
+template's alphabet, while the rest of the value, such as separators, is kept
+as it is. Builtin templates for credit card numbers and US social security
+numbers are available under the "builtin/" prefix.
+`
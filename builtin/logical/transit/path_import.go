@@ -0,0 +1,259 @@
+package transit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// The wrapped ephemeral AES key takes the size of the RSA-4096 wrapping key
+const wrappedEphemeralKeySize = 512
+
+func importFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"name": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Name of the key",
+		},
+
+		"ciphertext": &framework.FieldSchema{
+			Type: framework.TypeString,
+			Description: `The base64 encoded key to import, wrapped as follows: an
+ephemeral 256-bit AES key encrypted with RSA-OAEP using the wrapping key,
+followed by the key to import wrapped with the ephemeral key using AES Key
+Wrap with Padding (RFC 5649). Symmetric keys are given as raw bytes,
+asymmetric keys as PKCS #8 DER encoded private keys.`,
+		},
+
+		"hash_function": &framework.FieldSchema{
+			Type:    framework.TypeString,
+			Default: "SHA256",
+			Description: `The hash function used for RSA-OAEP. Valid values are
+"SHA1", "SHA224", "SHA256", "SHA384" and "SHA512". Defaults to "SHA256".`,
+		},
+	}
+}
+
+func (b *backend) pathImport() *framework.Path {
+	fields := importFields()
+	fields["type"] = &framework.FieldSchema{
+		Type:    framework.TypeString,
+		Default: "aes256-gcm96",
+		Description: `The type of the imported key. All the types supported
+by the "keys/:name" endpoint can be imported. Defaults to "aes256-gcm96".`,
+	}
+	fields["derived"] = &framework.FieldSchema{
+		Type:        framework.TypeBool,
+		Description: "Enables key derivation mode.",
+	}
+	fields["exportable"] = &framework.FieldSchema{
+		Type: framework.TypeBool,
+		Description: `Enables export of the key. Imported keys are not
+exportable by default. Once set, this cannot be disabled.`,
+	}
+	fields["allow_plaintext_backup"] = &framework.FieldSchema{
+		Type:        framework.TypeBool,
+		Description: `Enables taking a backup of the key in plaintext format. Once set, this cannot be disabled.`,
+	}
+	fields["allow_rotation"] = &framework.FieldSchema{
+		Type: framework.TypeBool,
+		Description: `Allows rotating the key, in which case Vault generates
+the new versions. New versions can always be imported with the
+"import_version" endpoint.`,
+	}
+
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import",
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportWrite,
+		},
+
+		HelpSynopsis:    pathImportHelpSyn,
+		HelpDescription: pathImportHelpDesc,
+	}
+}
+
+func (b *backend) pathImportVersion() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import_version",
+		Fields:  importFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportVersionWrite,
+		},
+
+		HelpSynopsis:    pathImportVersionHelpSyn,
+		HelpDescription: pathImportVersionHelpDesc,
+	}
+}
+
+func (b *backend) pathImportWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	polReq := keysutil.PolicyRequest{
+		Storage:                  req.Storage,
+		Name:                     name,
+		Derived:                  d.Get("derived").(bool),
+		Exportable:               d.Get("exportable").(bool),
+		AllowPlaintextBackup:     d.Get("allow_plaintext_backup").(bool),
+		AllowImportedKeyRotation: d.Get("allow_rotation").(bool),
+	}
+
+	keyType := d.Get("type").(string)
+	switch keyType {
+	case "aes256-gcm96":
+		polReq.KeyType = keysutil.KeyType_AES256_GCM96
+	case "chacha20-poly1305":
+		polReq.KeyType = keysutil.KeyType_ChaCha20_Poly1305
+	case "ecdsa-p256":
+		polReq.KeyType = keysutil.KeyType_ECDSA_P256
+	case "ed25519":
+		polReq.KeyType = keysutil.KeyType_ED25519
+	case "rsa-2048":
+		polReq.KeyType = keysutil.KeyType_RSA2048
+	case "rsa-4096":
+		polReq.KeyType = keysutil.KeyType_RSA4096
+	case "aes256-ff3-1":
+		polReq.KeyType = keysutil.KeyType_AES256_FF3_1
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
+	}
+
+	p, lock, err := b.lm.GetPolicyShared(ctx, req.Storage, name)
+	if lock != nil {
+		lock.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p != nil {
+		return logical.ErrorResponse(fmt.Sprintf("key %q already exists; use the import_version endpoint to import a new version", name)), logical.ErrInvalidRequest
+	}
+
+	key, err := b.unwrapImportedKey(ctx, req.Storage, d)
+	if err != nil {
+		return importErrorResponse(err)
+	}
+
+	if err := b.lm.ImportPolicy(ctx, polReq, key); err != nil {
+		return importErrorResponse(err)
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathImportVersionWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, lock, err := b.lm.GetPolicyExclusive(ctx, req.Storage, name)
+	if lock != nil {
+		defer lock.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+	if !p.Imported {
+		return logical.ErrorResponse("new versions can only be imported into imported keys"), logical.ErrInvalidRequest
+	}
+
+	key, err := b.unwrapImportedKey(ctx, req.Storage, d)
+	if err != nil {
+		return importErrorResponse(err)
+	}
+
+	if err := p.Import(ctx, req.Storage, key); err != nil {
+		return importErrorResponse(err)
+	}
+
+	return nil, nil
+}
+
+// unwrapImportedKey decrypts the ephemeral AES key with the wrapping key and
+// uses it to unwrap the imported key
+func (b *backend) unwrapImportedKey(ctx context.Context, s logical.Storage, d *framework.FieldData) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(d.Get("ciphertext").(string))
+	if err != nil {
+		return nil, errutil.UserError{Err: "failed to base64-decode ciphertext"}
+	}
+	if len(ciphertext) <= wrappedEphemeralKeySize {
+		return nil, errutil.UserError{Err: "ciphertext is too short"}
+	}
+
+	var hashFn hash.Hash
+	switch strings.ToUpper(d.Get("hash_function").(string)) {
+	case "SHA1":
+		hashFn = sha1.New()
+	case "SHA224":
+		hashFn = sha256.New224()
+	case "SHA256":
+		hashFn = sha256.New()
+	case "SHA384":
+		hashFn = sha512.New384()
+	case "SHA512":
+		hashFn = sha512.New()
+	default:
+		return nil, errutil.UserError{Err: fmt.Sprintf("unsupported hash function %q", d.Get("hash_function").(string))}
+	}
+
+	wrappingKey, err := b.getWrappingKey(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralKey, err := rsa.DecryptOAEP(hashFn, rand.Reader, wrappingKey.RSAKey, ciphertext[:wrappedEphemeralKeySize], nil)
+	if err != nil {
+		return nil, errutil.UserError{Err: "failed to decrypt the ephemeral key"}
+	}
+	if len(ephemeralKey) != 32 {
+		return nil, errutil.UserError{Err: "ephemeral key must be a 256-bit AES key"}
+	}
+
+	key, err := keysutil.UnwrapKeyKWP(ephemeralKey, ciphertext[wrappedEphemeralKeySize:])
+	if err != nil {
+		return nil, errutil.UserError{Err: err.Error()}
+	}
+	return key, nil
+}
+
+func importErrorResponse(err error) (*logical.Response, error) {
+	switch err.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	default:
+		return nil, err
+	}
+}
+
+const pathImportHelpSyn = `Import a key into a new named key`
+
+const pathImportHelpDesc = `
+This path is used to create a named key from existing key material, for
+instance to migrate keys from other systems. The key must be wrapped for
+transport using the public key returned by the "wrapping_key" endpoint.
+Imported keys are not exportable and cannot be rotated unless allowed.
+`
+
+const pathImportVersionHelpSyn = `Import a new version of an imported key`
+
+const pathImportVersionHelpDesc = `
+This path is used to import new key material as the latest version of a key
+that was created by import. The key must be wrapped the same way as for the
+"import" endpoint.
+`
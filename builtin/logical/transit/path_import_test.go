@@ -0,0 +1,192 @@
+package transit
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+// testWrapKey wraps the key for import with the backend's wrapping key
+func testWrapKey(t *testing.T, b *backend, s logical.Storage, key []byte) string {
+	t.Helper()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   s,
+		Operation: logical.ReadOperation,
+		Path:      "wrapping_key",
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	block, _ := pem.Decode([]byte(resp.Data["public_key"].(string)))
+	if block == nil {
+		t.Fatal("failed to decode wrapping key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ephemeralKey := make([]byte, 32)
+	if _, err := rand.Read(ephemeralKey); err != nil {
+		t.Fatal(err)
+	}
+	wrappedEphemeralKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub.(*rsa.PublicKey), ephemeralKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrappedKey, err := keysutil.WrapKeyKWP(ephemeralKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(append(wrappedEphemeralKey, wrappedKey...))
+}
+
+func TestTransit_Import(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	doRequest := func(op logical.Operation, path string, data map[string]interface{}, errExpected bool) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   s,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if errExpected {
+			if err == nil && (resp == nil || !resp.IsError()) {
+				t.Fatalf("expected error for %s, got %#v", path, resp)
+			}
+			return resp
+		}
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+		return resp
+	}
+
+	// Import an AES key and check that it is used for encryption
+	aesKey := make([]byte, 32)
+	if _, err := rand.Read(aesKey); err != nil {
+		t.Fatal(err)
+	}
+	doRequest(logical.UpdateOperation, "keys/aes/import", map[string]interface{}{
+		"ciphertext": testWrapKey(t, b, s, aesKey),
+	}, false)
+
+	resp := doRequest(logical.UpdateOperation, "encrypt/aes", map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString([]byte("foo")),
+	}, false)
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(resp.Data["ciphertext"].(string), "vault:v1:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], nil)
+	if err != nil || string(plaintext) != "foo" {
+		t.Fatalf("failed to decrypt with the imported key: %v", err)
+	}
+
+	resp = doRequest(logical.ReadOperation, "keys/aes", nil, false)
+	if !resp.Data["imported_key"].(bool) || resp.Data["exportable"].(bool) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Imported keys can't be exported, rotated or imported again by default
+	doRequest(logical.ReadOperation, "export/encryption-key/aes", nil, true)
+	doRequest(logical.UpdateOperation, "keys/aes/rotate", nil, true)
+	doRequest(logical.UpdateOperation, "keys/aes/import", map[string]interface{}{
+		"ciphertext": testWrapKey(t, b, s, aesKey),
+	}, true)
+
+	// New versions can be imported
+	doRequest(logical.UpdateOperation, "keys/aes/import_version", map[string]interface{}{
+		"ciphertext": testWrapKey(t, b, s, aesKey),
+	}, false)
+	resp = doRequest(logical.ReadOperation, "keys/aes", nil, false)
+	if resp.Data["latest_version"].(int) != 2 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Rotation can be allowed
+	doRequest(logical.UpdateOperation, "keys/rotatable/import", map[string]interface{}{
+		"ciphertext":     testWrapKey(t, b, s, aesKey),
+		"type":           "chacha20-poly1305",
+		"allow_rotation": true,
+	}, false)
+	doRequest(logical.UpdateOperation, "keys/rotatable/rotate", nil, false)
+
+	// Keys generated by Vault can't have versions imported
+	doRequest(logical.UpdateOperation, "keys/generated", nil, false)
+	doRequest(logical.UpdateOperation, "keys/generated/import_version", map[string]interface{}{
+		"ciphertext": testWrapKey(t, b, s, aesKey),
+	}, true)
+
+	// Asymmetric keys are PKCS #8 encoded
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doRequest(logical.UpdateOperation, "keys/rsa/import", map[string]interface{}{
+		"ciphertext": testWrapKey(t, b, s, der),
+		"type":       "rsa-4096",
+	}, true)
+	doRequest(logical.UpdateOperation, "keys/rsa/import", map[string]interface{}{
+		"ciphertext": testWrapKey(t, b, s, der),
+		"type":       "rsa-2048",
+	}, false)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doRequest(logical.UpdateOperation, "keys/ec/import", map[string]interface{}{
+		"ciphertext": testWrapKey(t, b, s, der),
+		"type":       "ecdsa-p256",
+	}, false)
+	resp = doRequest(logical.ReadOperation, "keys/ec", nil, false)
+	pubDer, err := x509.MarshalPKIXPublicKey(ecKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDer}))
+	if resp.Data["keys"].(map[string]map[string]interface{})["1"]["public_key"].(string) != expected {
+		t.Fatalf("bad: %#v", resp.Data["keys"])
+	}
+
+	// A tampered wrapped key is rejected
+	ciphertext, _ = base64.StdEncoding.DecodeString(testWrapKey(t, b, s, aesKey))
+	ciphertext[len(ciphertext)-1] ^= 1
+	doRequest(logical.UpdateOperation, "keys/bad/import", map[string]interface{}{
+		"ciphertext": base64.StdEncoding.EncodeToString(ciphertext),
+	}, true)
+}
@@ -0,0 +1,92 @@
+package transit
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	wrappingKeyName          = "wrapping-key"
+	wrappingKeyStoragePrefix = "import/"
+)
+
+func (b *backend) pathWrappingKey() *framework.Path {
+	return &framework.Path{
+		Pattern: "wrapping_key",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathWrappingKeyRead,
+		},
+
+		HelpSynopsis:    pathWrappingKeyHelpSyn,
+		HelpDescription: pathWrappingKeyHelpDesc,
+	}
+}
+
+// getWrappingKey returns the RSA key used to wrap keys to import, generating
+// it on first use
+func (b *backend) getWrappingKey(ctx context.Context, s logical.Storage) (*keysutil.KeyEntry, error) {
+	b.wrappingKeyLock.Lock()
+	defer b.wrappingKeyLock.Unlock()
+
+	p, err := keysutil.LoadPolicy(ctx, s, wrappingKeyStoragePrefix+"policy/"+wrappingKeyName)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		p = keysutil.NewPolicy(keysutil.PolicyConfig{
+			Name:          wrappingKeyName,
+			Type:          keysutil.KeyType_RSA4096,
+			StoragePrefix: wrappingKeyStoragePrefix,
+		})
+		if err := p.Rotate(ctx, s); err != nil {
+			return nil, fmt.Errorf("failed to generate wrapping key: %v", err)
+		}
+	}
+
+	entry, ok := p.Keys[strconv.Itoa(p.LatestVersion)]
+	if !ok || entry.RSAKey == nil {
+		return nil, fmt.Errorf("wrapping key not found")
+	}
+	return &entry, nil
+}
+
+func (b *backend) pathWrappingKeyRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entry, err := b.getWrappingKey(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	derBytes, err := x509.MarshalPKIXPublicKey(entry.RSAKey.Public())
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling RSA public key: %v", err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	})
+	if len(pemBytes) == 0 {
+		return nil, fmt.Errorf("failed to PEM-encode RSA public key")
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": string(pemBytes),
+		},
+	}, nil
+}
+
+const pathWrappingKeyHelpSyn = `Returns the public key to use for wrapping imported keys`
+
+const pathWrappingKeyHelpDesc = `
+This path is used to retrieve the RSA-4096 public key that keys imported with
+the "keys/:name/import" and "keys/:name/import_version" endpoints must be
+wrapped with. The key is generated on first use.
+`
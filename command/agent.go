@@ -0,0 +1,473 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/auth"
+	"github.com/hashicorp/vault/command/agent/auth/approle"
+	"github.com/hashicorp/vault/command/agent/auth/cert"
+	"github.com/hashicorp/vault/command/agent/auth/tokenfile"
+	"github.com/hashicorp/vault/command/agent/cache"
+	"github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/command/agent/sink/file"
+	"github.com/hashicorp/vault/command/agent/sink/inmem"
+	"github.com/hashicorp/vault/command/server"
+	gatedwriter "github.com/hashicorp/vault/helper/gated-writer"
+	"github.com/hashicorp/vault/helper/logging"
+	"github.com/hashicorp/vault/version"
+	colorable "github.com/mattn/go-colorable"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*AgentCommand)(nil)
+var _ cli.CommandAutocomplete = (*AgentCommand)(nil)
+
+type AgentCommand struct {
+	*BaseCommand
+
+	ShutdownCh chan struct{}
+
+	logWriter *gatedwriter.Writer
+	logger    log.Logger
+
+	startedCh chan (struct{}) // for tests
+
+	flagConfigs  []string
+	flagLogLevel string
+
+	flagTestVerifyOnly bool
+}
+
+func (c *AgentCommand) Synopsis() string {
+	return "Start a Vault agent"
+}
+
+func (c *AgentCommand) Help() string {
+	helpText := `
+Usage: vault agent [options]
+
+  This command starts a Vault agent that can perform automatic authentication
+  in certain environments, write the resulting token to one or more sinks, and
+  optionally proxy and cache requests to Vault.
+
+  Start an agent with a configuration file:
+
+      $ vault agent -config=/etc/vault/config.hcl
+
+  For a full list of examples, please see the documentation.
+
+` + c.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (c *AgentCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP)
+
+	f := set.NewFlagSet("Command Options")
+
+	f.StringSliceVar(&StringSliceVar{
+		Name:   "config",
+		Target: &c.flagConfigs,
+		Completion: complete.PredictOr(
+			complete.PredictFiles("*.hcl"),
+			complete.PredictFiles("*.json"),
+		),
+		Usage: "Path to a configuration file. This configuration file should " +
+			"contain only agent directives.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "log-level",
+		Target:     &c.flagLogLevel,
+		Default:    "info",
+		EnvVar:     "VAULT_LOG_LEVEL",
+		Completion: complete.PredictSet("trace", "debug", "info", "warn", "err"),
+		Usage: "Log verbosity level. Supported values (in order of detail) are " +
+			"\"trace\", \"debug\", \"info\", \"warn\", and \"err\".",
+	})
+
+	// Internal-only flags to follow.
+	//
+	// Why hello there little source code reader! Welcome to the Vault source
+	// code. The remaining options are intentionally undocumented and come with
+	// no warranty or backwards-compatability promise. Do not use these flags
+	// in production. Do not build automation using these flags. Unless you are
+	// developing against Vault, you should not need any of these flags.
+
+	// TODO: should the below flags be public?
+	f.BoolVar(&BoolVar{
+		Name:    "test-verify-only",
+		Target:  &c.flagTestVerifyOnly,
+		Default: false,
+		Hidden:  true,
+	})
+
+	// End internal-only flags.
+
+	return set
+}
+
+func (c *AgentCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *AgentCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *AgentCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	// Create a logger. We wrap it in a gated writer so that it doesn't
+	// start logging too early.
+	c.logWriter = &gatedwriter.Writer{Writer: colorable.NewColorable(os.Stderr)}
+	var level log.Level
+	c.flagLogLevel = strings.ToLower(strings.TrimSpace(c.flagLogLevel))
+	switch c.flagLogLevel {
+	case "trace":
+		level = log.Trace
+	case "debug":
+		level = log.Debug
+	case "notice", "info", "":
+		level = log.Info
+	case "warn", "warning":
+		level = log.Warn
+	case "err", "error":
+		level = log.Error
+	default:
+		c.UI.Error(fmt.Sprintf("Unknown log level: %s", c.flagLogLevel))
+		return 1
+	}
+
+	c.logger = logging.NewVaultLoggerWithWriter(c.logWriter, level)
+
+	// Validation
+	if len(c.flagConfigs) != 1 {
+		c.UI.Error("Must specify exactly one config path using -config")
+		return 1
+	}
+
+	// Load the configuration
+	cfg, err := config.LoadConfig(c.flagConfigs[0])
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error loading configuration from %s: %s", c.flagConfigs[0], err))
+		return 1
+	}
+
+	// Ensure at least one config was found.
+	if cfg == nil {
+		c.UI.Output(wrapAtLength(
+			"No configuration read. Please provide the configuration with the " +
+				"-config flag."))
+		return 1
+	}
+	if cfg.AutoAuth == nil && cfg.Cache == nil {
+		c.UI.Error("No auto_auth or cache block found in config file")
+		return 1
+	}
+
+	if cfg.Vault != nil {
+		// Settings from the command line or the environment take precedence
+		// over the config file
+		setFlags := make(map[string]bool)
+		f.Visit(func(fl *flag.Flag) {
+			setFlags[fl.Name] = true
+		})
+		setStringFlag := func(configVal, name, envVar string, target *string) {
+			if configVal == "" || setFlags[name] || os.Getenv(envVar) != "" {
+				return
+			}
+			*target = configVal
+		}
+		setStringFlag(cfg.Vault.Address, "address", "VAULT_ADDR", &c.flagAddress)
+		setStringFlag(cfg.Vault.CACert, "ca-cert", "VAULT_CACERT", &c.flagCACert)
+		setStringFlag(cfg.Vault.CAPath, "ca-path", "VAULT_CAPATH", &c.flagCAPath)
+		setStringFlag(cfg.Vault.ClientCert, "client-cert", "VAULT_CLIENT_CERT", &c.flagClientCert)
+		setStringFlag(cfg.Vault.ClientKey, "client-key", "VAULT_CLIENT_KEY", &c.flagClientKey)
+		if cfg.Vault.TLSSkipVerify && !setFlags["tls-skip-verify"] && os.Getenv("VAULT_SKIP_VERIFY") == "" {
+			c.flagTLSSkipVerify = true
+		}
+	}
+
+	infoKeys := make([]string, 0, 10)
+	info := make(map[string]string)
+	info["log level"] = c.flagLogLevel
+	infoKeys = append(infoKeys, "log level")
+
+	infoKeys = append(infoKeys, "version")
+	verInfo := version.GetVersion()
+	info["version"] = verInfo.FullVersionNumber(false)
+	if verInfo.Revision != "" {
+		info["version sha"] = strings.Trim(verInfo.Revision, "'")
+		infoKeys = append(infoKeys, "version sha")
+	}
+	infoKeys = append(infoKeys, "cgo")
+	info["cgo"] = "disabled"
+	if version.CgoEnabled {
+		info["cgo"] = "enabled"
+	}
+
+	// Agent configuration output
+	padding := 24
+	sort.Strings(infoKeys)
+	c.UI.Output("==> Vault agent configuration:\n")
+	for _, k := range infoKeys {
+		c.UI.Output(fmt.Sprintf(
+			"%s%s: %s",
+			strings.Repeat(" ", padding-len(k)),
+			strings.Title(k),
+			info[k]))
+	}
+	c.UI.Output("")
+
+	// Tests might not want to start a vault server and just want to verify
+	// the configuration.
+	if c.flagTestVerifyOnly {
+		return 0
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf(
+			"Error fetching client: %v",
+			err))
+		return 1
+	}
+	// The agent supplies the token for each request itself
+	client.ClearToken()
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	var sinks []*sink.SinkConfig
+	var method auth.AuthMethod
+	var inmemSink sink.Sink
+	if cfg.AutoAuth != nil {
+		for _, sc := range cfg.AutoAuth.Sinks {
+			switch sc.Type {
+			case "file":
+				config := &sink.SinkConfig{
+					Logger:  c.logger.Named("sink.file"),
+					Config:  sc.Config,
+					Client:  client,
+					WrapTTL: sc.WrapTTL,
+					DHType:  sc.DHType,
+					DHPath:  sc.DHPath,
+					AAD:     sc.AAD,
+				}
+				s, err := file.NewFileSink(config)
+				if err != nil {
+					c.UI.Error(fmt.Errorf("Error creating file sink: %v", err).Error())
+					return 1
+				}
+				config.Sink = s
+				sinks = append(sinks, config)
+			default:
+				c.UI.Error(fmt.Sprintf("Unknown sink type %q", sc.Type))
+				return 1
+			}
+		}
+
+		authConfig := &auth.AuthConfig{
+			Logger:    c.logger.Named(fmt.Sprintf("auth.%s", cfg.AutoAuth.Method.Type)),
+			MountPath: cfg.AutoAuth.Method.MountPath,
+			WrapTTL:   cfg.AutoAuth.Method.WrapTTL,
+			Config:    cfg.AutoAuth.Method.Config,
+		}
+		switch cfg.AutoAuth.Method.Type {
+		case "approle":
+			method, err = approle.NewApproleAuthMethod(authConfig)
+		case "cert":
+			method, err = cert.NewCertAuthMethod(authConfig)
+		case "token_file":
+			if authConfig.WrapTTL > 0 {
+				c.UI.Error("The token_file auth method does not support wrap_ttl")
+				return 1
+			}
+			method, err = tokenfile.NewTokenFileAuthMethod(authConfig)
+		default:
+			c.UI.Error(fmt.Sprintf("Unknown auth method %q", cfg.AutoAuth.Method.Type))
+			return 1
+		}
+		if err != nil {
+			c.UI.Error(fmt.Errorf("Error creating %s auth method: %v", cfg.AutoAuth.Method.Type, err).Error())
+			return 1
+		}
+
+		// The cache proxy reads the auto-auth token from an in-memory sink
+		if cfg.Cache != nil && cfg.Cache.UseAutoAuthToken {
+			if cfg.AutoAuth.Method.WrapTTL > 0 {
+				c.UI.Error("use_auto_auth_token cannot be used with a wrapped auto-auth token")
+				return 1
+			}
+			config := &sink.SinkConfig{
+				Logger: c.logger.Named("sink.inmem"),
+				Client: client,
+			}
+			inmemSink, err = inmem.New(config)
+			if err != nil {
+				c.UI.Error(fmt.Errorf("Error creating inmem sink: %v", err).Error())
+				return 1
+			}
+			config.Sink = inmemSink
+			sinks = append(sinks, config)
+		}
+	}
+
+	// Start the cache listeners before auth so the proxy is available as
+	// soon as possible
+	var listeners []net.Listener
+	if cfg.Cache != nil {
+		cacheLogger := c.logger.Named("cache")
+
+		// Create the API proxier
+		apiProxy := cache.NewAPIProxy(&cache.APIProxyConfig{
+			Client: client,
+			Logger: cacheLogger.Named("apiproxy"),
+		})
+
+		// Create the lease cache proxier and set its underlying proxier to
+		// the API proxier.
+		leaseCache := cache.NewLeaseCache(&cache.LeaseCacheConfig{
+			Proxier: apiProxy,
+			Logger:  cacheLogger.Named("leasecache"),
+		})
+
+		var tokenReader inmem.TokenReader
+		if inmemSink != nil {
+			tokenReader = inmemSink.(inmem.TokenReader)
+		}
+
+		handler := cache.ProxyHandler(ctx, cacheLogger, leaseCache, tokenReader)
+		for i, lnConfig := range cfg.Listeners {
+			ln, props, _, err := server.NewListener(lnConfig.Type, lnConfig.Config, c.logWriter, c.UI)
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error parsing listener configuration: %v", err))
+				return 1
+			}
+			listeners = append(listeners, ln)
+
+			server := &http.Server{
+				Handler:           handler,
+				ReadHeaderTimeout: 10 * time.Second,
+				ReadTimeout:       30 * time.Second,
+				IdleTimeout:       5 * time.Minute,
+				ErrorLog:          cacheLogger.StandardLogger(nil),
+			}
+			go server.Serve(ln)
+
+			cacheLogger.Info("listener started", "index", i, "type", lnConfig.Type, "address", props["address"])
+		}
+	}
+
+	// Ensure that listeners are closed at all the exits
+	defer func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}()
+
+	if err := c.storePidFile(cfg.PidFile); err != nil {
+		c.UI.Error(fmt.Sprintf("Error storing PID: %s", err))
+		return 1
+	}
+	defer func() {
+		if err := c.removePidFile(cfg.PidFile); err != nil {
+			c.UI.Error(fmt.Sprintf("Error deleting the PID file: %s", err))
+		}
+	}()
+
+	var ssDoneCh, ahDoneCh chan struct{}
+	if method != nil {
+		ah := auth.NewAuthHandler(&auth.AuthHandlerConfig{
+			Logger:  c.logger.Named("auth.handler"),
+			Client:  client,
+			WrapTTL: cfg.AutoAuth.Method.WrapTTL,
+		})
+		ahDoneCh = ah.DoneCh
+
+		ss := sink.NewSinkServer(&sink.SinkServerConfig{
+			Logger: c.logger.Named("sink.server"),
+			Client: client,
+		})
+		ssDoneCh = ss.DoneCh
+
+		go ah.Run(ctx, method)
+		go ss.Run(ctx, ah.OutputCh, sinks)
+	}
+
+	// Output the header that the agent has started
+	c.UI.Output("==> Vault agent started! Log data will stream in below:\n")
+
+	// Inform any tests that the agent is ready
+	if c.startedCh != nil {
+		close(c.startedCh)
+	}
+
+	// Release the log gate.
+	c.logWriter.Flush()
+
+	// Wait for shutdown
+	select {
+	case <-ssDoneCh:
+		c.UI.Output("==> Vault agent shut down due to sink server stopping")
+	case <-c.ShutdownCh:
+		c.UI.Output("==> Vault agent shutdown triggered")
+		cancelFunc()
+		if ahDoneCh != nil {
+			<-ahDoneCh
+		}
+		if ssDoneCh != nil {
+			<-ssDoneCh
+		}
+	}
+
+	return 0
+}
+
+// storePidFile is used to write out our PID to a file if necessary
+func (c *AgentCommand) storePidFile(pidPath string) error {
+	// Quit fast if no pidfile
+	if pidPath == "" {
+		return nil
+	}
+
+	// Open the PID file
+	pidFile, err := os.OpenFile(pidPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open pid file: %v", err)
+	}
+	defer pidFile.Close()
+
+	// Write out the PID
+	pid := os.Getpid()
+	_, err = pidFile.WriteString(fmt.Sprintf("%d", pid))
+	if err != nil {
+		return fmt.Errorf("could not write to pid file: %v", err)
+	}
+	return nil
+}
+
+// removePidFile is used to cleanup the PID file if necessary
+func (c *AgentCommand) removePidFile(pidPath string) error {
+	if pidPath == "" {
+		return nil
+	}
+	return os.Remove(pidPath)
+}
@@ -0,0 +1,360 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	credAppRole "github.com/hashicorp/vault/builtin/credential/approle"
+	"github.com/hashicorp/vault/command/agent/auth"
+	agentapprole "github.com/hashicorp/vault/command/agent/auth/approle"
+	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/command/agent/sink/file"
+	"github.com/hashicorp/vault/helper/dhutil"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/logging"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/vault"
+)
+
+func testApproleCluster(t *testing.T) (*vault.TestCluster, *api.Client) {
+	coreConfig := &vault.CoreConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace),
+		CredentialBackends: map[string]logical.Factory{
+			"approle": credAppRole.Factory,
+		},
+	}
+
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	client := cluster.Cores[0].Client
+
+	if err := client.Sys().EnableAuthWithOptions("approle", &api.EnableAuthOptions{
+		Type: "approle",
+	}); err != nil {
+		cluster.Cleanup()
+		t.Fatal(err)
+	}
+
+	if _, err := client.Logical().Write("auth/approle/role/test1", map[string]interface{}{
+		"bind_secret_id": "true",
+		"token_ttl":      "3s",
+		"token_max_ttl":  "10s",
+	}); err != nil {
+		cluster.Cleanup()
+		t.Fatal(err)
+	}
+
+	return cluster, client
+}
+
+func testTempFile(t *testing.T, prefix string) string {
+	f, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := f.Name()
+	f.Close()
+	os.Remove(path)
+	return path
+}
+
+func TestAppRoleEndToEnd(t *testing.T) {
+	cluster, client := testApproleCluster(t)
+	defer cluster.Cleanup()
+
+	logger := logging.NewVaultLogger(hclog.Trace)
+
+	resp, err := client.Logical().Write("auth/approle/role/test1/secret-id", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretID := resp.Data["secret_id"].(string)
+
+	resp, err = client.Logical().Read("auth/approle/role/test1/role-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roleID := resp.Data["role_id"].(string)
+
+	roleIDFile := testTempFile(t, "auth.roleid.test.")
+	secretIDFile := testTempFile(t, "auth.secretid.test.")
+	defer os.Remove(roleIDFile)
+	defer os.Remove(secretIDFile)
+	if err := ioutil.WriteFile(roleIDFile, []byte(roleID), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(secretIDFile, []byte(secretID), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	timer := time.AfterFunc(30*time.Second, func() {
+		cancelFunc()
+	})
+	defer timer.Stop()
+
+	am, err := agentapprole.NewApproleAuthMethod(&auth.AuthConfig{
+		Logger:    logger.Named("auth.approle"),
+		MountPath: "auth/approle",
+		Config: map[string]interface{}{
+			"role_id_file_path":   roleIDFile,
+			"secret_id_file_path": secretIDFile,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	agentClient, err := client.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentClient.ClearToken()
+
+	ah := auth.NewAuthHandler(&auth.AuthHandlerConfig{
+		Logger: logger.Named("auth.handler"),
+		Client: agentClient,
+	})
+	go ah.Run(ctx, am)
+	defer func() {
+		<-ah.DoneCh
+	}()
+
+	tokenPath := testTempFile(t, "auth.tokensink.test.")
+	defer os.Remove(tokenPath)
+	config := &sink.SinkConfig{
+		Logger: logger.Named("sink.file"),
+		Config: map[string]interface{}{
+			"path": tokenPath,
+		},
+	}
+	fs, err := file.NewFileSink(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Sink = fs
+
+	ss := sink.NewSinkServer(&sink.SinkServerConfig{
+		Logger: logger.Named("sink.server"),
+		Client: agentClient,
+	})
+	go ss.Run(ctx, ah.OutputCh, []*sink.SinkConfig{config})
+	defer func() {
+		<-ss.DoneCh
+	}()
+	defer cancelFunc()
+
+	// The secret ID file is removed once it has been read
+	token := waitForToken(t, tokenPath, "")
+	if _, err := os.Stat(secretIDFile); !os.IsNotExist(err) {
+		t.Fatalf("expected secret ID file to be removed, got: %v", err)
+	}
+
+	checkClient, err := client.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkClient.SetToken(token)
+	secret, err := checkClient.Auth().Token().LookupSelf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["display_name"] != "approle" {
+		t.Fatalf("bad lookup: %#v", secret.Data)
+	}
+
+	// The token is renewed until it reaches its max TTL, after which the
+	// agent logs in again with the cached credentials
+	newToken := waitForToken(t, tokenPath, token)
+	if newToken == token {
+		t.Fatal("expected a new token")
+	}
+}
+
+func TestAppRoleEndToEnd_WrappedSinkWithDH(t *testing.T) {
+	cluster, client := testApproleCluster(t)
+	defer cluster.Cleanup()
+
+	logger := logging.NewVaultLogger(hclog.Trace)
+
+	resp, err := client.Logical().Write("auth/approle/role/test1/secret-id", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretID := resp.Data["secret_id"].(string)
+
+	resp, err = client.Logical().Read("auth/approle/role/test1/role-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roleID := resp.Data["role_id"].(string)
+
+	roleIDFile := testTempFile(t, "auth.roleid.test.")
+	secretIDFile := testTempFile(t, "auth.secretid.test.")
+	defer os.Remove(roleIDFile)
+	defer os.Remove(secretIDFile)
+	if err := ioutil.WriteFile(roleIDFile, []byte(roleID), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(secretIDFile, []byte(secretID), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The consumer publishes its public key for the agent to use
+	pub, pri, err := dhutil.GeneratePublicPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dhPath := testTempFile(t, "auth.dhpath.test.")
+	defer os.Remove(dhPath)
+	mPubKey, err := jsonutil.EncodeJSON(&dhutil.PublicKeyInfo{
+		Curve25519PublicKey: pub,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dhPath, mPubKey, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	timer := time.AfterFunc(30*time.Second, func() {
+		cancelFunc()
+	})
+	defer timer.Stop()
+
+	am, err := agentapprole.NewApproleAuthMethod(&auth.AuthConfig{
+		Logger:    logger.Named("auth.approle"),
+		MountPath: "auth/approle",
+		Config: map[string]interface{}{
+			"role_id_file_path":                   roleIDFile,
+			"secret_id_file_path":                 secretIDFile,
+			"remove_secret_id_file_after_reading": "false",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	agentClient, err := client.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentClient.ClearToken()
+
+	ah := auth.NewAuthHandler(&auth.AuthHandlerConfig{
+		Logger: logger.Named("auth.handler"),
+		Client: agentClient,
+	})
+	go ah.Run(ctx, am)
+	defer func() {
+		<-ah.DoneCh
+	}()
+
+	tokenPath := testTempFile(t, "auth.tokensink.test.")
+	defer os.Remove(tokenPath)
+	config := &sink.SinkConfig{
+		Logger:  logger.Named("sink.file"),
+		WrapTTL: 5 * time.Minute,
+		DHType:  "curve25519",
+		DHPath:  dhPath,
+		AAD:     "foobar",
+		Config: map[string]interface{}{
+			"path": tokenPath,
+		},
+	}
+	fs, err := file.NewFileSink(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Sink = fs
+
+	ss := sink.NewSinkServer(&sink.SinkServerConfig{
+		Logger: logger.Named("sink.server"),
+		Client: agentClient,
+	})
+	go ss.Run(ctx, ah.OutputCh, []*sink.SinkConfig{config})
+	defer func() {
+		<-ss.DoneCh
+	}()
+	defer cancelFunc()
+
+	if _, err := os.Stat(secretIDFile); err != nil {
+		t.Fatalf("expected secret ID file to be kept: %v", err)
+	}
+
+	contents := waitForToken(t, tokenPath, "")
+
+	// Decrypt the envelope with our private key
+	resp2 := new(dhutil.Envelope)
+	if err := jsonutil.DecodeJSON([]byte(contents), resp2); err != nil {
+		t.Fatal(err)
+	}
+	aesKey, err := dhutil.GenerateSharedKey(pri, resp2.Curve25519PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dhutil.DecryptAES(aesKey, resp2.EncryptedPayload, resp2.Nonce, []byte("bad")); err == nil {
+		t.Fatal("expected decryption with the wrong AAD to fail")
+	}
+	plaintext, err := dhutil.DecryptAES(aesKey, resp2.EncryptedPayload, resp2.Nonce, []byte("foobar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The plaintext is the response-wrapping info for the sys/wrapping/wrap
+	// call that wrapped the token
+	var wrapInfo api.SecretWrapInfo
+	if err := json.Unmarshal(plaintext, &wrapInfo); err != nil {
+		t.Fatal(err)
+	}
+	unwrapClient, err := client.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapClient.SetToken(wrapInfo.Token)
+	secret, err := unwrapClient.Logical().Unwrap("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, ok := secret.Data["token"].(string)
+	if !ok || token == "" {
+		t.Fatalf("bad unwrapped secret: %#v", secret)
+	}
+	checkClient, err := client.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkClient.SetToken(token)
+	if _, err := checkClient.Auth().Token().LookupSelf(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitForToken waits for the sink file to hold a value other than prev
+func waitForToken(t *testing.T, path, prev string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(20 * time.Second)
+	for time.Now().Before(deadline) {
+		val, err := ioutil.ReadFile(path)
+		if err == nil && len(val) > 0 && string(val) != prev {
+			return string(val)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	t.Fatal("timed out waiting for token to be written")
+	return ""
+}
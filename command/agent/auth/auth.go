@@ -0,0 +1,303 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/jsonutil"
+)
+
+// TokenLookupSelfPath is returned by auth methods that already hold a token.
+// Rather than logging in, the handler looks the token up to learn its TTL and
+// then manages it like any other token.
+const TokenLookupSelfPath = "auth/token/lookup-self"
+
+// AuthMethod is implemented by each auto-auth method. Authenticate returns
+// the login path and data to write to it; NewCreds signals when the method
+// has picked up new credentials and a fresh login should be performed.
+type AuthMethod interface {
+	Authenticate(context.Context, *api.Client) (string, map[string]interface{}, error)
+	NewCreds() chan struct{}
+	CredSuccess()
+	Shutdown()
+}
+
+// AuthConfig is the common configuration passed to each auth method.
+type AuthConfig struct {
+	Logger    hclog.Logger
+	MountPath string
+	WrapTTL   time.Duration
+	Config    map[string]interface{}
+}
+
+// AuthHandler is responsible for keeping a token alive and renewed and
+// passing new tokens to the sink server
+type AuthHandler struct {
+	DoneCh   chan struct{}
+	OutputCh chan string
+	logger   hclog.Logger
+	client   *api.Client
+	random   *rand.Rand
+	wrapTTL  time.Duration
+}
+
+// AuthHandlerConfig is used to construct an AuthHandler.
+type AuthHandlerConfig struct {
+	Logger  hclog.Logger
+	Client  *api.Client
+	WrapTTL time.Duration
+}
+
+// NewAuthHandler returns a new AuthHandler.
+func NewAuthHandler(conf *AuthHandlerConfig) *AuthHandler {
+	ah := &AuthHandler{
+		DoneCh: make(chan struct{}),
+		// This is buffered so that if we try to output after the sink server
+		// has been shut down, during agent shutdown, we won't block
+		OutputCh: make(chan string, 1),
+		logger:   conf.Logger,
+		client:   conf.Client,
+		random:   rand.New(rand.NewSource(int64(time.Now().Nanosecond()))),
+		wrapTTL:  conf.WrapTTL,
+	}
+
+	return ah
+}
+
+func backoffOrQuit(ctx context.Context, backoff time.Duration) {
+	select {
+	case <-time.After(backoff):
+	case <-ctx.Done():
+	}
+}
+
+// Run authenticates with the given method until the context is cancelled,
+// renewing the resulting token and re-authenticating whenever renewal stops
+// or the method reports new credentials.
+func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) {
+	if am == nil {
+		panic("nil auth method")
+	}
+
+	ah.logger.Info("starting auth handler")
+	defer func() {
+		am.Shutdown()
+		close(ah.OutputCh)
+		close(ah.DoneCh)
+		ah.logger.Info("auth handler stopped")
+	}()
+
+	credCh := am.NewCreds()
+	if credCh == nil {
+		credCh = make(chan struct{})
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		default:
+		}
+
+		// Create a fresh backoff value
+		backoff := 2*time.Second + time.Duration(ah.random.Int63()%int64(time.Second*2)-int64(time.Second))
+
+		ah.logger.Info("authenticating")
+		path, data, err := am.Authenticate(ctx, ah.client)
+		if err != nil {
+			ah.logger.Error("error getting path or data from method", "error", err, "backoff", backoff.Seconds())
+			backoffOrQuit(ctx, backoff)
+			continue
+		}
+
+		clientToUse := ah.client
+		if ah.wrapTTL > 0 {
+			wrapClient, err := ah.client.Clone()
+			if err != nil {
+				ah.logger.Error("error creating client for wrapped call", "error", err, "backoff", backoff.Seconds())
+				backoffOrQuit(ctx, backoff)
+				continue
+			}
+			wrapClient.SetWrappingLookupFunc(func(string, string) string {
+				return ah.wrapTTL.String()
+			})
+			clientToUse = wrapClient
+		}
+
+		var secret *api.Secret
+		if path == TokenLookupSelfPath {
+			secret, err = ah.lookupToken(data)
+		} else {
+			secret, err = clientToUse.Logical().Write(path, data)
+		}
+		// Check errors/sanity
+		if err != nil {
+			ah.logger.Error("error authenticating", "error", err, "backoff", backoff.Seconds())
+			backoffOrQuit(ctx, backoff)
+			continue
+		}
+
+		switch {
+		case ah.wrapTTL > 0:
+			if secret.WrapInfo == nil {
+				ah.logger.Error("authentication returned nil wrap info", "backoff", backoff.Seconds())
+				backoffOrQuit(ctx, backoff)
+				continue
+			}
+			if secret.WrapInfo.Token == "" {
+				ah.logger.Error("authentication returned empty wrapped client token", "backoff", backoff.Seconds())
+				backoffOrQuit(ctx, backoff)
+				continue
+			}
+			wrappedResp, err := jsonutil.EncodeJSON(secret.WrapInfo)
+			if err != nil {
+				ah.logger.Error("failed to encode wrapinfo", "error", err, "backoff", backoff.Seconds())
+				backoffOrQuit(ctx, backoff)
+				continue
+			}
+			ah.logger.Info("authentication successful, sending wrapped token to sinks and pausing")
+			ah.OutputCh <- string(wrappedResp)
+
+			am.CredSuccess()
+
+			select {
+			case <-ctx.Done():
+				ah.logger.Info("shutdown triggered")
+				return
+
+			case <-credCh:
+				ah.logger.Info("auth method found new credentials, re-authenticating")
+				continue
+			}
+
+		default:
+			if secret == nil || secret.Auth == nil {
+				ah.logger.Error("authentication returned nil auth info", "backoff", backoff.Seconds())
+				backoffOrQuit(ctx, backoff)
+				continue
+			}
+			if secret.Auth.ClientToken == "" {
+				ah.logger.Error("authentication returned empty client token", "backoff", backoff.Seconds())
+				backoffOrQuit(ctx, backoff)
+				continue
+			}
+			ah.logger.Info("authentication successful, sending token to sinks")
+			ah.OutputCh <- secret.Auth.ClientToken
+
+			am.CredSuccess()
+		}
+
+		if !secret.Auth.Renewable {
+			// There is nothing to renew; hold on to the token until it is
+			// close to expiring, or indefinitely if it never expires
+			var expiryCh <-chan time.Time
+			if secret.Auth.LeaseDuration > 0 {
+				expiryCh = time.After(time.Duration(secret.Auth.LeaseDuration) * time.Second * 2 / 3)
+			}
+			ah.logger.Info("token is not renewable, waiting until it needs to be replaced")
+			select {
+			case <-ctx.Done():
+				ah.logger.Info("shutdown triggered")
+				return
+			case <-expiryCh:
+				ah.logger.Info("token nearing expiry, re-authenticating")
+			case <-credCh:
+				ah.logger.Info("auth method found new credentials, re-authenticating")
+			}
+			continue
+		}
+
+		// Renewal uses the newly acquired token rather than whatever the
+		// client was configured with
+		renewClient, err := ah.client.Clone()
+		if err != nil {
+			ah.logger.Error("error creating client for renewal", "error", err, "backoff", backoff.Seconds())
+			backoffOrQuit(ctx, backoff)
+			continue
+		}
+		renewClient.SetToken(secret.Auth.ClientToken)
+		renewer, err := renewClient.NewRenewer(&api.RenewerInput{
+			Secret: secret,
+		})
+		if err != nil {
+			ah.logger.Error("error creating renewer, backing off and retrying", "error", err, "backoff", backoff.Seconds())
+			backoffOrQuit(ctx, backoff)
+			continue
+		}
+
+		ah.logger.Info("starting renewal process")
+		go renewer.Renew()
+
+	RenewerLoop:
+		for {
+			select {
+			case <-ctx.Done():
+				ah.logger.Info("shutdown triggered, stopping renewer")
+				renewer.Stop()
+				break RenewerLoop
+
+			case err := <-renewer.DoneCh():
+				ah.logger.Info("renewer done channel triggered")
+				if err != nil {
+					ah.logger.Error("error renewing token", "error", err)
+				}
+				break RenewerLoop
+
+			case <-renewer.RenewCh():
+				ah.logger.Info("renewed auth token")
+
+			case <-credCh:
+				ah.logger.Info("auth method found new credentials, re-authenticating")
+				renewer.Stop()
+				break RenewerLoop
+			}
+		}
+	}
+}
+
+// lookupToken builds an auth secret for an existing token by looking it up,
+// so that it can be sent to the sinks and renewed
+func (ah *AuthHandler) lookupToken(data map[string]interface{}) (*api.Secret, error) {
+	tok, _ := data["token"].(string)
+	if tok == "" {
+		return nil, errors.New("no token provided")
+	}
+
+	lookupClient, err := ah.client.Clone()
+	if err != nil {
+		return nil, err
+	}
+	lookupClient.SetToken(tok)
+
+	secret, err := lookupClient.Auth().Token().LookupSelf()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := secret.TokenTTL()
+	if err != nil {
+		return nil, err
+	}
+	renewable, err := secret.TokenIsRenewable()
+	if err != nil {
+		return nil, err
+	}
+	policies, err := secret.TokenPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.Secret{
+		Auth: &api.SecretAuth{
+			ClientToken:   tok,
+			Policies:      policies,
+			LeaseDuration: int(ttl.Seconds()),
+			Renewable:     renewable,
+		},
+	}, nil
+}
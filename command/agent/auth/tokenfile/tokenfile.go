@@ -0,0 +1,84 @@
+package tokenfile
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/auth"
+	"github.com/hashicorp/vault/command/token"
+)
+
+type tokenFileMethod struct {
+	logger        hclog.Logger
+	tokenFilePath string
+	cachedToken   string
+}
+
+// NewTokenFileAuthMethod returns an auto-auth method that uses an existing
+// token read from a file. If no file is configured, the token stored by the
+// Vault CLI's internal token helper is used.
+func NewTokenFileAuthMethod(conf *auth.AuthConfig) (auth.AuthMethod, error) {
+	if conf == nil {
+		return nil, errors.New("empty config")
+	}
+
+	t := &tokenFileMethod{
+		logger: conf.Logger,
+	}
+
+	if conf.Config != nil {
+		if tokenFilePathRaw, ok := conf.Config["token_file_path"]; ok {
+			t.tokenFilePath, ok = tokenFilePathRaw.(string)
+			if !ok {
+				return nil, errors.New("could not convert 'token_file_path' config value to string")
+			}
+		}
+	}
+
+	return t, nil
+}
+
+func (t *tokenFileMethod) Authenticate(_ context.Context, client *api.Client) (string, map[string]interface{}, error) {
+	var tok string
+	var err error
+	if t.tokenFilePath != "" {
+		var raw []byte
+		raw, err = ioutil.ReadFile(t.tokenFilePath)
+		tok = strings.TrimSpace(string(raw))
+	} else {
+		helper := &token.InternalTokenHelper{}
+		tok, err = helper.Get()
+	}
+
+	switch {
+	case err != nil && t.cachedToken == "":
+		return "", nil, errwrap.Wrapf("error reading token and no cached token known: {{err}}", err)
+	case err != nil:
+		t.logger.Warn("error reading token, re-using cached value", "error", err)
+	case tok == "" && t.cachedToken == "":
+		return "", nil, errors.New("token empty and no cached token known")
+	case tok == "":
+		t.logger.Warn("token read empty value, re-using cached value")
+	default:
+		t.cachedToken = tok
+	}
+
+	return auth.TokenLookupSelfPath, map[string]interface{}{
+		"token": t.cachedToken,
+	}, nil
+}
+
+func (t *tokenFileMethod) NewCreds() chan struct{} {
+	return nil
+}
+
+func (t *tokenFileMethod) CredSuccess() {
+}
+
+func (t *tokenFileMethod) Shutdown() {
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+)
+
+// APIProxy is an implementation of the proxier interface that is used to
+// forward the request to Vault and get the response.
+type APIProxy struct {
+	client *api.Client
+	logger hclog.Logger
+}
+
+// APIProxyConfig is used to construct an APIProxy.
+type APIProxyConfig struct {
+	Client *api.Client
+	Logger hclog.Logger
+}
+
+var _ Proxier = (*APIProxy)(nil)
+
+// NewAPIProxy returns a new APIProxy.
+func NewAPIProxy(config *APIProxyConfig) Proxier {
+	return &APIProxy{
+		client: config.Client,
+		logger: config.Logger,
+	}
+}
+
+func (ap *APIProxy) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	client, err := ap.client.Clone()
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(req.Token)
+	client.SetHeaders(req.Request.Header)
+
+	fwReq := client.NewRequest(req.Request.Method, req.Request.URL.Path)
+	fwReq.Params = req.Request.URL.Query()
+	if len(req.RequestBody) > 0 {
+		// Obj is set so that the body can be replayed on a redirect
+		fwReq.Obj = json.RawMessage(req.RequestBody)
+		fwReq.BodySize = int64(len(req.RequestBody))
+		fwReq.Body = bytes.NewReader(req.RequestBody)
+	}
+
+	// Make the request to Vault and get the response
+	ap.logger.Info("forwarding request", "path", req.Request.URL.Path, "method", req.Request.Method)
+	resp, err := client.RawRequest(fwReq)
+	if resp == nil && err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read the body so that the response can be cached and replayed
+	respBody, readErr := ioutil.ReadAll(resp.Body)
+	if readErr != nil {
+		return nil, readErr
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	// An error status from Vault is passed through to the caller as-is
+	return &SendResponse{
+		Response:     resp,
+		ResponseBody: respBody,
+	}, nil
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/sink/inmem"
+	"github.com/hashicorp/vault/helper/jsonutil"
+)
+
+// ProxyHandler returns an http.Handler that sends requests received by the
+// agent's listeners through the given proxier. When tokenReader is non-nil,
+// requests that do not carry a token use the auto-auth token.
+func ProxyHandler(ctx context.Context, logger hclog.Logger, proxier Proxier, tokenReader inmem.TokenReader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Info("received request", "path", r.URL.Path, "method", r.Method)
+
+		token := r.Header.Get("X-Vault-Token")
+		if token == "" && tokenReader != nil {
+			logger.Debug("using auto auth token", "path", r.URL.Path, "method", r.Method)
+			token = tokenReader.Token()
+		}
+
+		reqBody, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("failed to read request body: %v", err))
+			return
+		}
+		r.Body.Close()
+
+		resp, err := proxier.Send(ctx, &SendRequest{
+			Token:       token,
+			Request:     r,
+			RequestBody: reqBody,
+		})
+		if err != nil {
+			respondError(w, http.StatusBadGateway, fmt.Errorf("failed to get the response: %v", err))
+			return
+		}
+
+		for k, vals := range resp.Response.Header {
+			for _, v := range vals {
+				w.Header().Add(k, v)
+			}
+		}
+		w.WriteHeader(resp.Response.StatusCode)
+		w.Write(resp.ResponseBody)
+	})
+}
+
+func respondError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	body, _ := jsonutil.EncodeJSON(map[string][]string{
+		"errors": {err.Error()},
+	})
+	w.Write(body)
+}
@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/jsonutil"
+)
+
+// cachedResponse is a response stored by the lease cache along with the
+// indexes it can be evicted by
+type cachedResponse struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	expires    time.Time
+
+	// tokens holds the tokens whose revocation invalidates this entry: the
+	// token that made the request, and for login responses the token that
+	// was issued
+	tokens  []string
+	leaseID string
+}
+
+// LeaseCache is an implementation of Proxier that caches responses carrying
+// a lease or an auth token. Entries are keyed on the requesting token and the
+// request itself, and are evicted when the lease expires or is revoked, or
+// when the token is revoked through the agent.
+type LeaseCache struct {
+	proxier Proxier
+	logger  hclog.Logger
+
+	l       sync.Mutex
+	entries map[string]*cachedResponse
+	nowFunc func() time.Time
+}
+
+// LeaseCacheConfig is used to construct a LeaseCache.
+type LeaseCacheConfig struct {
+	Proxier Proxier
+	Logger  hclog.Logger
+}
+
+var _ Proxier = (*LeaseCache)(nil)
+
+// NewLeaseCache returns a new LeaseCache wrapping the given proxier.
+func NewLeaseCache(conf *LeaseCacheConfig) *LeaseCache {
+	return &LeaseCache{
+		proxier: conf.Proxier,
+		logger:  conf.Logger,
+		entries: make(map[string]*cachedResponse),
+		nowFunc: time.Now,
+	}
+}
+
+// Send returns a cached response if one exists for the request, otherwise it
+// forwards the request and caches the response if it is leased.
+func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	key := computeCacheKey(req)
+
+	if resp := c.lookup(key); resp != nil {
+		c.logger.Debug("returning cached response", "path", req.Request.URL.Path)
+		return resp, nil
+	}
+
+	resp, err := c.proxier.Send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Response.StatusCode >= 300 {
+		return resp, nil
+	}
+
+	c.handleRevocation(req)
+
+	secret, err := api.ParseSecret(bytes.NewReader(resp.ResponseBody))
+	if err != nil || secret == nil {
+		// Not a secret response, so there is nothing to cache
+		return resp, nil
+	}
+
+	entry := &cachedResponse{
+		statusCode: resp.Response.StatusCode,
+		header:     resp.Response.Header,
+		body:       resp.ResponseBody,
+		tokens:     []string{req.Token},
+	}
+
+	now := c.nowFunc()
+	switch {
+	case secret.Auth != nil && secret.Auth.ClientToken != "" && secret.Auth.LeaseDuration > 0:
+		entry.expires = now.Add(time.Duration(secret.Auth.LeaseDuration) * time.Second)
+		entry.tokens = append(entry.tokens, secret.Auth.ClientToken)
+	case secret.LeaseID != "" && secret.LeaseDuration > 0:
+		entry.expires = now.Add(time.Duration(secret.LeaseDuration) * time.Second)
+		entry.leaseID = secret.LeaseID
+	default:
+		return resp, nil
+	}
+
+	c.logger.Debug("caching response", "path", req.Request.URL.Path, "expires", entry.expires)
+
+	c.l.Lock()
+	c.evictExpiredLocked(now)
+	c.entries[key] = entry
+	c.l.Unlock()
+
+	return resp, nil
+}
+
+// lookup returns the cached response for key if it has not expired
+func (c *LeaseCache) lookup(key string) *SendResponse {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if !c.nowFunc().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+
+	header := make(http.Header, len(entry.header))
+	for k, v := range entry.header {
+		header[k] = v
+	}
+
+	return &SendResponse{
+		Response: &api.Response{
+			Response: &http.Response{
+				StatusCode: entry.statusCode,
+				Header:     header,
+				Body:       ioutil.NopCloser(bytes.NewReader(entry.body)),
+			},
+		},
+		ResponseBody: entry.body,
+	}
+}
+
+// handleRevocation evicts cached entries made invalid by a successful
+// revocation request
+func (c *LeaseCache) handleRevocation(req *SendRequest) {
+	path := strings.TrimPrefix(req.Request.URL.Path, "/v1/")
+
+	var body map[string]interface{}
+	if len(req.RequestBody) > 0 {
+		jsonutil.DecodeJSON(req.RequestBody, &body)
+	}
+	bodyString := func(key string) string {
+		v, _ := body[key].(string)
+		return v
+	}
+
+	switch {
+	case path == "auth/token/revoke-self":
+		c.evictToken(req.Token)
+
+	case path == "auth/token/revoke":
+		c.evictToken(bodyString("token"))
+
+	case path == "sys/leases/revoke" || path == "sys/revoke":
+		c.evictLease(bodyString("lease_id"))
+
+	case strings.HasPrefix(path, "sys/leases/revoke/"):
+		c.evictLease(strings.TrimPrefix(path, "sys/leases/revoke/"))
+
+	case strings.HasPrefix(path, "sys/revoke/"):
+		c.evictLease(strings.TrimPrefix(path, "sys/revoke/"))
+	}
+}
+
+func (c *LeaseCache) evictToken(token string) {
+	if token == "" {
+		return
+	}
+
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	for key, entry := range c.entries {
+		for _, t := range entry.tokens {
+			if t == token {
+				delete(c.entries, key)
+				break
+			}
+		}
+	}
+}
+
+func (c *LeaseCache) evictLease(leaseID string) {
+	if leaseID == "" {
+		return
+	}
+
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	for key, entry := range c.entries {
+		if entry.leaseID == leaseID {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *LeaseCache) evictExpiredLocked(now time.Time) {
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// computeCacheKey hashes the requesting token together with the request
+// method, URI and body
+func computeCacheKey(req *SendRequest) string {
+	h := sha256.New()
+	h.Write([]byte(req.Token))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Request.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Request.URL.RequestURI()))
+	h.Write([]byte{0})
+	h.Write(req.RequestBody)
+	return hex.EncodeToString(h.Sum(nil))
+}
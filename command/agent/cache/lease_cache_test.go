@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/logging"
+)
+
+// mockProxier returns the configured responses in order and records the
+// requests it was sent
+type mockProxier struct {
+	responses []string
+	requests  []*SendRequest
+}
+
+func (p *mockProxier) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	p.requests = append(p.requests, req)
+	body := []byte(p.responses[0])
+	p.responses = p.responses[1:]
+	return &SendResponse{
+		Response: &api.Response{
+			Response: &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       ioutil.NopCloser(bytes.NewReader(body)),
+			},
+		},
+		ResponseBody: body,
+	}, nil
+}
+
+func testLeaseCache(t *testing.T, responses ...string) (*LeaseCache, *mockProxier) {
+	proxier := &mockProxier{responses: responses}
+	return NewLeaseCache(&LeaseCacheConfig{
+		Proxier: proxier,
+		Logger:  logging.NewVaultLogger(hclog.Trace),
+	}), proxier
+}
+
+func testSendRequest(token, method, path, body string) *SendRequest {
+	return &SendRequest{
+		Token:       token,
+		Request:     httptest.NewRequest(method, path, strings.NewReader(body)),
+		RequestBody: []byte(body),
+	}
+}
+
+func TestLeaseCache_CachesLeasedResponses(t *testing.T) {
+	lc, proxier := testLeaseCache(t,
+		`{"lease_id": "foo/1", "lease_duration": 60, "data": {"value": "first"}}`,
+		`{"lease_id": "foo/2", "lease_duration": 60, "data": {"value": "second"}}`,
+		`{"lease_id": "foo/3", "lease_duration": 60, "data": {"value": "third"}}`,
+	)
+
+	resp, err := lc.Send(context.Background(), testSendRequest("token1", "GET", "/v1/foo/creds/bar", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.ResponseBody), "first") {
+		t.Fatalf("bad: %s", resp.ResponseBody)
+	}
+
+	// The same request with the same token is served from the cache
+	resp, err = lc.Send(context.Background(), testSendRequest("token1", "GET", "/v1/foo/creds/bar", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.ResponseBody), "first") {
+		t.Fatalf("bad: %s", resp.ResponseBody)
+	}
+	if len(proxier.requests) != 1 {
+		t.Fatalf("expected one forwarded request, got %d", len(proxier.requests))
+	}
+
+	// A different token does not share the cached entry
+	resp, err = lc.Send(context.Background(), testSendRequest("token2", "GET", "/v1/foo/creds/bar", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.ResponseBody), "second") {
+		t.Fatalf("bad: %s", resp.ResponseBody)
+	}
+
+	// Expired entries are forwarded again
+	lc.nowFunc = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	resp, err = lc.Send(context.Background(), testSendRequest("token1", "GET", "/v1/foo/creds/bar", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.ResponseBody), "third") {
+		t.Fatalf("bad: %s", resp.ResponseBody)
+	}
+}
+
+func TestLeaseCache_NonLeasedNotCached(t *testing.T) {
+	lc, proxier := testLeaseCache(t,
+		`{"data": {"value": "first"}}`,
+		`{"data": {"value": "second"}}`,
+	)
+
+	for i := 0; i < 2; i++ {
+		if _, err := lc.Send(context.Background(), testSendRequest("token1", "GET", "/v1/secret/foo", "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(proxier.requests) != 2 {
+		t.Fatalf("expected two forwarded requests, got %d", len(proxier.requests))
+	}
+}
+
+func TestLeaseCache_Revocation(t *testing.T) {
+	lc, proxier := testLeaseCache(t,
+		`{"auth": {"client_token": "child", "lease_duration": 60}}`,
+		`{"lease_id": "foo/1", "lease_duration": 60, "data": {}}`,
+		`{}`,
+		`{"lease_id": "foo/2", "lease_duration": 60, "data": {}}`,
+		`{}`,
+		`{"auth": {"client_token": "child2", "lease_duration": 60}}`,
+	)
+	ctx := context.Background()
+
+	login := testSendRequest("", "PUT", "/v1/auth/approle/login", `{"role_id": "foo"}`)
+	if _, err := lc.Send(ctx, login); err != nil {
+		t.Fatal(err)
+	}
+	creds := testSendRequest("child", "GET", "/v1/foo/creds/bar", "")
+	if _, err := lc.Send(ctx, creds); err != nil {
+		t.Fatal(err)
+	}
+
+	// Revoking the lease evicts the entry holding it
+	if _, err := lc.Send(ctx, testSendRequest("child", "PUT", "/v1/sys/leases/revoke", `{"lease_id": "foo/1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := lc.Send(ctx, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.ResponseBody), "foo/2") {
+		t.Fatalf("bad: %s", resp.ResponseBody)
+	}
+
+	// Revoking the token evicts both its own entries and the login that
+	// produced it
+	if _, err := lc.Send(ctx, testSendRequest("child", "PUT", "/v1/auth/token/revoke-self", "")); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = lc.Send(ctx, login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.ResponseBody), "child2") {
+		t.Fatalf("bad: %s", resp.ResponseBody)
+	}
+	if len(proxier.requests) != 6 {
+		t.Fatalf("expected six forwarded requests, got %d", len(proxier.requests))
+	}
+}
+
+type staticTokenReader string
+
+func (s staticTokenReader) Token() string {
+	return string(s)
+}
+
+func TestProxyHandler_UseAutoAuthToken(t *testing.T) {
+	proxier := &mockProxier{responses: []string{`{"data": {}}`, `{"data": {}}`}}
+	handler := ProxyHandler(context.Background(), logging.NewVaultLogger(hclog.Trace), proxier, staticTokenReader("autoauth"))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/secret/foo", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("bad code: %d", rr.Code)
+	}
+	if proxier.requests[0].Token != "autoauth" {
+		t.Fatalf("expected auto-auth token, got %q", proxier.requests[0].Token)
+	}
+
+	// A token supplied by the client takes precedence
+	req := httptest.NewRequest("GET", "/v1/secret/foo", nil)
+	req.Header.Set("X-Vault-Token", "client")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if proxier.requests[1].Token != "client" {
+		t.Fatalf("expected client token, got %q", proxier.requests[1].Token)
+	}
+}
@@ -0,0 +1,417 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/helper/parseutil"
+)
+
+// Config is the configuration for the vault agent.
+type Config struct {
+	AutoAuth  *AutoAuth          `hcl:"auto_auth"`
+	Cache     *Cache             `hcl:"cache"`
+	Listeners []*server.Listener `hcl:"-"`
+	Vault     *Vault             `hcl:"vault"`
+	PidFile   string             `hcl:"pid_file"`
+}
+
+// Vault contains configuration for connecting to the Vault server.
+type Vault struct {
+	Address          string      `hcl:"address"`
+	CACert           string      `hcl:"ca_cert"`
+	CAPath           string      `hcl:"ca_path"`
+	TLSSkipVerify    bool        `hcl:"-"`
+	TLSSkipVerifyRaw interface{} `hcl:"tls_skip_verify"`
+	ClientCert       string      `hcl:"client_cert"`
+	ClientKey        string      `hcl:"client_key"`
+}
+
+// Cache contains configuration for the local caching proxy.
+type Cache struct {
+	UseAutoAuthToken    bool        `hcl:"-"`
+	UseAutoAuthTokenRaw interface{} `hcl:"use_auto_auth_token"`
+}
+
+// AutoAuth is the configured authentication method and sinks.
+type AutoAuth struct {
+	Method *Method `hcl:"-"`
+	Sinks  []*Sink `hcl:"sinks"`
+}
+
+// Method represents the configuration for the authentication backend.
+type Method struct {
+	Type       string
+	MountPath  string        `hcl:"mount_path"`
+	WrapTTLRaw interface{}   `hcl:"wrap_ttl"`
+	WrapTTL    time.Duration `hcl:"-"`
+	Config     map[string]interface{}
+}
+
+// Sink defines a location to write the authenticated token.
+type Sink struct {
+	Type       string
+	WrapTTLRaw interface{}   `hcl:"wrap_ttl"`
+	WrapTTL    time.Duration `hcl:"-"`
+	DHType     string        `hcl:"dh_type"`
+	DHPath     string        `hcl:"dh_path"`
+	AAD        string        `hcl:"aad"`
+	AADEnvVar  string        `hcl:"aad_env_var"`
+	Config     map[string]interface{}
+}
+
+// LoadConfig loads the configuration at the given path.
+func LoadConfig(path string) (*Config, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConfig(string(d))
+}
+
+// ParseConfig parses the given agent configuration.
+func ParseConfig(d string) (*Config, error) {
+	obj, err := hcl.Parse(d)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start building the result
+	var result Config
+	if err := hcl.DecodeObject(&result, obj); err != nil {
+		return nil, err
+	}
+
+	list, ok := obj.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("error parsing: file doesn't contain a root object")
+	}
+
+	valid := []string{
+		"auto_auth",
+		"cache",
+		"listener",
+		"vault",
+		"pid_file",
+	}
+	if err := checkHCLKeys(list, valid); err != nil {
+		return nil, err
+	}
+
+	if err := parseAutoAuth(&result, list); err != nil {
+		return nil, fmt.Errorf("error parsing 'auto_auth': %s", err)
+	}
+
+	if err := parseVault(&result, list); err != nil {
+		return nil, fmt.Errorf("error parsing 'vault': %s", err)
+	}
+
+	if err := parseCache(&result, list); err != nil {
+		return nil, fmt.Errorf("error parsing 'cache': %s", err)
+	}
+
+	if o := list.Filter("listener"); len(o.Items) > 0 {
+		result.Listeners, err = server.ParseListeners(o)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing 'listener': %s", err)
+		}
+	}
+
+	if result.Cache != nil && len(result.Listeners) == 0 {
+		return nil, fmt.Errorf("at least one listener must be defined when the cache is enabled")
+	}
+	if result.Cache != nil && result.Cache.UseAutoAuthToken && result.AutoAuth == nil {
+		return nil, fmt.Errorf("use_auto_auth_token requires an auto_auth block")
+	}
+
+	return &result, nil
+}
+
+func parseVault(result *Config, list *ast.ObjectList) error {
+	name := "vault"
+
+	vaultList := list.Filter(name)
+	if len(vaultList.Items) == 0 {
+		return nil
+	}
+	if len(vaultList.Items) > 1 {
+		return fmt.Errorf("one and only one %q block is required", name)
+	}
+
+	item := vaultList.Items[0]
+
+	valid := []string{
+		"address",
+		"ca_cert",
+		"ca_path",
+		"tls_skip_verify",
+		"client_cert",
+		"client_key",
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return multierror.Prefix(err, fmt.Sprintf("%s:", name))
+	}
+
+	var v Vault
+	if err := hcl.DecodeObject(&v, item.Val); err != nil {
+		return err
+	}
+
+	if v.TLSSkipVerifyRaw != nil {
+		var err error
+		if v.TLSSkipVerify, err = parseutil.ParseBool(v.TLSSkipVerifyRaw); err != nil {
+			return err
+		}
+	}
+
+	result.Vault = &v
+	return nil
+}
+
+func parseCache(result *Config, list *ast.ObjectList) error {
+	name := "cache"
+
+	cacheList := list.Filter(name)
+	if len(cacheList.Items) == 0 {
+		return nil
+	}
+	if len(cacheList.Items) > 1 {
+		return fmt.Errorf("one and only one %q block is required", name)
+	}
+
+	item := cacheList.Items[0]
+
+	valid := []string{
+		"use_auto_auth_token",
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return multierror.Prefix(err, fmt.Sprintf("%s:", name))
+	}
+
+	var c Cache
+	if err := hcl.DecodeObject(&c, item.Val); err != nil {
+		return err
+	}
+
+	if c.UseAutoAuthTokenRaw != nil {
+		var err error
+		if c.UseAutoAuthToken, err = parseutil.ParseBool(c.UseAutoAuthTokenRaw); err != nil {
+			return err
+		}
+	}
+
+	result.Cache = &c
+	return nil
+}
+
+func parseAutoAuth(result *Config, list *ast.ObjectList) error {
+	name := "auto_auth"
+
+	autoAuthList := list.Filter(name)
+	if len(autoAuthList.Items) == 0 {
+		return nil
+	}
+	if len(autoAuthList.Items) > 1 {
+		return fmt.Errorf("at most one %q block is allowed", name)
+	}
+
+	// Get our item
+	item := autoAuthList.Items[0]
+
+	valid := []string{
+		"method",
+		"sink",
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return multierror.Prefix(err, fmt.Sprintf("%s:", name))
+	}
+
+	var a AutoAuth
+	if err := hcl.DecodeObject(&a, item.Val); err != nil {
+		return err
+	}
+
+	result.AutoAuth = &a
+
+	subs, ok := item.Val.(*ast.ObjectType)
+	if !ok {
+		return fmt.Errorf("could not parse %q as an object", name)
+	}
+	subList := subs.List
+
+	if err := parseMethod(result, subList); err != nil {
+		return multierror.Prefix(err, "method:")
+	}
+
+	if err := parseSinks(result, subList); err != nil {
+		return multierror.Prefix(err, "sink:")
+	}
+
+	return nil
+}
+
+func parseMethod(result *Config, list *ast.ObjectList) error {
+	name := "method"
+
+	methodList := list.Filter(name)
+	if len(methodList.Items) != 1 {
+		return fmt.Errorf("one and only one %q block is required", name)
+	}
+
+	// Get our item
+	item := methodList.Items[0]
+
+	valid := []string{
+		"type",
+		"mount_path",
+		"wrap_ttl",
+		"config",
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return err
+	}
+
+	var m Method
+	if err := hcl.DecodeObject(&m, item.Val); err != nil {
+		return err
+	}
+
+	if m.Type == "" {
+		if len(item.Keys) == 1 {
+			m.Type = strings.ToLower(item.Keys[0].Token.Value().(string))
+		}
+		if m.Type == "" {
+			return fmt.Errorf("method type must be specified")
+		}
+	}
+
+	// Default to Vault's default
+	if m.MountPath == "" {
+		m.MountPath = fmt.Sprintf("auth/%s", m.Type)
+	}
+	// Standardize on no trailing slash
+	m.MountPath = strings.TrimSuffix(m.MountPath, "/")
+
+	if m.WrapTTLRaw != nil {
+		var err error
+		if m.WrapTTL, err = parseutil.ParseDurationSecond(m.WrapTTLRaw); err != nil {
+			return err
+		}
+		m.WrapTTLRaw = nil
+	}
+
+	result.AutoAuth.Method = &m
+	return nil
+}
+
+func parseSinks(result *Config, list *ast.ObjectList) error {
+	name := "sink"
+
+	sinkList := list.Filter(name)
+	if len(sinkList.Items) < 1 {
+		return fmt.Errorf("at least one %q block is required", name)
+	}
+
+	var ts []*Sink
+
+	for _, item := range sinkList.Items {
+		valid := []string{
+			"type",
+			"wrap_ttl",
+			"dh_type",
+			"dh_path",
+			"aad",
+			"aad_env_var",
+			"config",
+		}
+		if err := checkHCLKeys(item.Val, valid); err != nil {
+			return err
+		}
+
+		var s Sink
+		if err := hcl.DecodeObject(&s, item.Val); err != nil {
+			return err
+		}
+
+		if s.Type == "" {
+			if len(item.Keys) == 1 {
+				s.Type = strings.ToLower(item.Keys[0].Token.Value().(string))
+			}
+			if s.Type == "" {
+				return fmt.Errorf("sink type must be specified")
+			}
+		}
+
+		if s.WrapTTLRaw != nil {
+			var err error
+			if s.WrapTTL, err = parseutil.ParseDurationSecond(s.WrapTTLRaw); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("sink.%s", s.Type))
+			}
+			s.WrapTTLRaw = nil
+		}
+
+		switch s.DHType {
+		case "":
+		case "curve25519":
+		default:
+			return multierror.Prefix(fmt.Errorf("invalid 'dh_type' %q", s.DHType), fmt.Sprintf("sink.%s", s.Type))
+		}
+
+		if s.AADEnvVar != "" {
+			s.AAD = os.Getenv(s.AADEnvVar)
+			s.AADEnvVar = ""
+		}
+
+		switch {
+		case s.DHPath == "" && s.DHType == "":
+			if s.AAD != "" {
+				return multierror.Prefix(errors.New("specifying AAD data without 'dh_type' does not make sense"), fmt.Sprintf("sink.%s", s.Type))
+			}
+		case s.DHPath != "" && s.DHType != "":
+		default:
+			return multierror.Prefix(errors.New("'dh_type' and 'dh_path' must be specified together"), fmt.Sprintf("sink.%s", s.Type))
+		}
+
+		ts = append(ts, &s)
+	}
+
+	result.AutoAuth.Sinks = ts
+	return nil
+}
+
+func checkHCLKeys(node ast.Node, valid []string) error {
+	var list *ast.ObjectList
+	switch n := node.(type) {
+	case *ast.ObjectList:
+		list = n
+	case *ast.ObjectType:
+		list = n.List
+	default:
+		return fmt.Errorf("cannot check HCL keys of type %T", n)
+	}
+
+	validMap := make(map[string]struct{}, len(valid))
+	for _, v := range valid {
+		validMap[v] = struct{}{}
+	}
+
+	var result error
+	for _, item := range list.Items {
+		key := item.Keys[0].Token.Value().(string)
+		if _, ok := validMap[key]; !ok {
+			result = multierror.Append(result, fmt.Errorf(
+				"invalid key '%s' on line %d", key, item.Assign.Line))
+		}
+	}
+
+	return result
+}
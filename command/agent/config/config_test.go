@@ -0,0 +1,102 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/command/server"
+)
+
+func TestLoadConfig(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		PidFile: "./pidfile",
+		Vault: &Vault{
+			Address:          "https://127.0.0.1:8200",
+			CACert:           "/path/to/ca.pem",
+			TLSSkipVerify:    true,
+			TLSSkipVerifyRaw: "true",
+		},
+		AutoAuth: &AutoAuth{
+			Method: &Method{
+				Type:      "approle",
+				MountPath: "auth/approle-custom",
+				WrapTTL:   5 * time.Minute,
+				Config: map[string]interface{}{
+					"role_id_file_path":   "/tmp/role-id",
+					"secret_id_file_path": "/tmp/secret-id",
+				},
+			},
+			Sinks: []*Sink{
+				&Sink{
+					Type:   "file",
+					DHType: "curve25519",
+					DHPath: "/tmp/file-foo-dhpath",
+					AAD:    "foobar",
+					Config: map[string]interface{}{
+						"path": "/tmp/file-foo",
+					},
+				},
+				&Sink{
+					Type:    "file",
+					WrapTTL: 5 * time.Minute,
+					Config: map[string]interface{}{
+						"path": "/tmp/file-bar",
+					},
+				},
+			},
+		},
+		Cache: &Cache{
+			UseAutoAuthToken:    true,
+			UseAutoAuthTokenRaw: true,
+		},
+		Listeners: []*server.Listener{
+			&server.Listener{
+				Type: "tcp",
+				Config: map[string]interface{}{
+					"address":     "127.0.0.1:8300",
+					"tls_disable": true,
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("bad:\n%#v\nexpected:\n%#v", config, expected)
+	}
+}
+
+func TestLoadConfig_BadSink(t *testing.T) {
+	_, err := LoadConfig("./test-fixtures/config-bad-sink.hcl")
+	if err == nil || !strings.Contains(err.Error(), "'dh_type' and 'dh_path' must be specified together") {
+		t.Fatalf("expected dh error, got: %v", err)
+	}
+}
+
+func TestLoadConfig_CacheWithoutListener(t *testing.T) {
+	_, err := LoadConfig("./test-fixtures/config-cache-no-listener.hcl")
+	if err == nil || !strings.Contains(err.Error(), "at least one listener") {
+		t.Fatalf("expected listener error, got: %v", err)
+	}
+}
+
+func TestParseConfig_InvalidKey(t *testing.T) {
+	_, err := ParseConfig(`
+auto_auth {
+	method "cert" {}
+	sink "file" {
+		config = { path = "/tmp/foo" }
+	}
+	foo = "bar"
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "invalid key 'foo'") {
+		t.Fatalf("expected invalid key error, got: %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+auto_auth {
+	method "cert" {}
+
+	sink "file" {
+		dh_type = "curve25519"
+		config = {
+			path = "/tmp/file-foo"
+		}
+	}
+}
@@ -0,0 +1,43 @@
+pid_file = "./pidfile"
+
+vault {
+	address = "https://127.0.0.1:8200"
+	ca_cert = "/path/to/ca.pem"
+	tls_skip_verify = "true"
+}
+
+auto_auth {
+	method "approle" {
+		mount_path = "auth/approle-custom"
+		wrap_ttl = 300
+		config = {
+			role_id_file_path = "/tmp/role-id"
+			secret_id_file_path = "/tmp/secret-id"
+		}
+	}
+
+	sink "file" {
+		config = {
+			path = "/tmp/file-foo"
+		}
+		aad = "foobar"
+		dh_type = "curve25519"
+		dh_path = "/tmp/file-foo-dhpath"
+	}
+
+	sink "file" {
+		wrap_ttl = "5m"
+		config = {
+			path = "/tmp/file-bar"
+		}
+	}
+}
+
+cache {
+	use_auto_auth_token = true
+}
+
+listener "tcp" {
+	address = "127.0.0.1:8300"
+	tls_disable = true
+}
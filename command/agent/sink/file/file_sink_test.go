@@ -0,0 +1,71 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/helper/logging"
+)
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-sink-file-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	fs, err := NewFileSink(&sink.SinkConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace),
+		Config: map[string]interface{}{
+			"path": path,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The write check must not leave anything behind
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("expected empty dir after write check, got %d files", len(files))
+	}
+
+	if err := fs.WriteToken("foobar"); err != nil {
+		t.Fatal(err)
+	}
+	val, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "foobar" {
+		t.Fatalf("bad: %q", val)
+	}
+
+	files, err = ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected only the token file, got %d files", len(files))
+	}
+}
+
+func TestFileSink_BadPath(t *testing.T) {
+	_, err := NewFileSink(&sink.SinkConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace),
+		Config: map[string]interface{}{
+			"path": "/nonexistent/dir/token",
+		},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
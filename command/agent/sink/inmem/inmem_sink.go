@@ -0,0 +1,46 @@
+package inmem
+
+import (
+	"errors"
+	"sync"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/sink"
+)
+
+// inmemSink retains the auto-auth token in memory and exposes it through
+// the TokenReader interface, for use by the caching proxy
+type inmemSink struct {
+	logger hclog.Logger
+	token  string
+	l      sync.RWMutex
+}
+
+// TokenReader returns the most recent token written to a sink.
+type TokenReader interface {
+	Token() string
+}
+
+// New creates a new in-memory sink
+func New(conf *sink.SinkConfig) (sink.Sink, error) {
+	if conf.Logger == nil {
+		return nil, errors.New("nil logger provided")
+	}
+
+	return &inmemSink{
+		logger: conf.Logger,
+	}, nil
+}
+
+func (s *inmemSink) WriteToken(token string) error {
+	s.l.Lock()
+	s.token = token
+	s.l.Unlock()
+	return nil
+}
+
+func (s *inmemSink) Token() string {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	return s.token
+}
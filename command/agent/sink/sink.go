@@ -0,0 +1,235 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/dhutil"
+	"github.com/hashicorp/vault/helper/jsonutil"
+)
+
+// Sink is a destination for tokens produced by auto-auth.
+type Sink interface {
+	WriteToken(string) error
+}
+
+// SinkConfig is the configuration of a sink along with the optional
+// wrapping and encryption settings applied before writing to it.
+type SinkConfig struct {
+	Sink
+	Logger  hclog.Logger
+	Config  map[string]interface{}
+	Client  *api.Client
+	WrapTTL time.Duration
+	DHType  string
+	DHPath  string
+	AAD     string
+
+	cachedRemotePubKey []byte
+	cachedPubKey       []byte
+	cachedPriKey       []byte
+}
+
+// SinkServerConfig is used to construct a SinkServer.
+type SinkServerConfig struct {
+	Logger hclog.Logger
+	Client *api.Client
+}
+
+// SinkServer is responsible for pushing tokens to sinks
+type SinkServer struct {
+	DoneCh chan struct{}
+	logger hclog.Logger
+	client *api.Client
+	random *rand.Rand
+}
+
+// NewSinkServer returns a new SinkServer.
+func NewSinkServer(conf *SinkServerConfig) *SinkServer {
+	ss := &SinkServer{
+		DoneCh: make(chan struct{}),
+		logger: conf.Logger,
+		client: conf.Client,
+		random: rand.New(rand.NewSource(int64(time.Now().Nanosecond()))),
+	}
+
+	return ss
+}
+
+// Run executes the server's run loop, which is responsible for reading
+// in new tokens and pushing them out to the various sinks.
+func (ss *SinkServer) Run(ctx context.Context, incoming chan string, sinks []*SinkConfig) {
+	if incoming == nil {
+		panic("incoming channel is nil")
+	}
+
+	ss.logger.Info("starting sink server")
+	defer func() {
+		ss.logger.Info("sink server stopped")
+		close(ss.DoneCh)
+	}()
+
+	latestToken := new(string)
+	sinkCh := make(chan func() error, len(sinks))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case token, ok := <-incoming:
+			if !ok {
+				return
+			}
+			if token != *latestToken {
+				*latestToken = token
+
+				// Any pending writes are for an older token and can be
+				// dropped
+			DrainLoop:
+				for {
+					select {
+					case <-sinkCh:
+					default:
+						break DrainLoop
+					}
+				}
+
+				sinkFuncs := func(currSink *SinkConfig, currToken string) func() error {
+					return func() error {
+						if currToken != *latestToken {
+							return nil
+						}
+						var err error
+
+						if currSink.WrapTTL != 0 {
+							if currToken, err = currSink.wrapToken(ss.client, currSink.WrapTTL, currToken); err != nil {
+								return err
+							}
+						}
+
+						if currSink.DHType != "" {
+							if currToken, err = currSink.encryptToken(currToken); err != nil {
+								return err
+							}
+						}
+
+						return currSink.WriteToken(currToken)
+					}
+				}
+				for _, s := range sinks {
+					sinkCh <- sinkFuncs(s, token)
+				}
+			}
+
+		case sinkFunc := <-sinkCh:
+			if err := sinkFunc(); err != nil {
+				backoff := 2*time.Second + time.Duration(ss.random.Int63()%int64(time.Second*2)-int64(time.Second))
+				ss.logger.Error("error returned by sink function, retrying", "error", err, "backoff", backoff.String())
+				select {
+				case <-time.After(backoff):
+					sinkCh <- sinkFunc
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *SinkConfig) encryptToken(token string) (string, error) {
+	var aadBytes []byte
+	if s.AAD != "" {
+		aadBytes = []byte(s.AAD)
+	}
+
+	_, err := os.Lstat(s.DHPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return "", errwrap.Wrapf("error stat-ing dh parameters file: {{err}}", err)
+		}
+		return "", errors.New("no dh parameters file found, and no cached pub key")
+	}
+
+	fileBytes, err := ioutil.ReadFile(s.DHPath)
+	if err != nil {
+		return "", errwrap.Wrapf("error reading file for dh parameters: {{err}}", err)
+	}
+
+	theirPubKey := new(dhutil.PublicKeyInfo)
+	if err := jsonutil.DecodeJSON(fileBytes, theirPubKey); err != nil {
+		return "", errwrap.Wrapf("error decoding public key: {{err}}", err)
+	}
+	if len(theirPubKey.Curve25519PublicKey) == 0 {
+		return "", errors.New("public key is nil")
+	}
+
+	// Only generate a new key pair when the remote side rotates its key
+	if string(theirPubKey.Curve25519PublicKey) != string(s.cachedRemotePubKey) {
+		pub, pri, err := dhutil.GeneratePublicPrivateKey()
+		if err != nil {
+			return "", errwrap.Wrapf("error generating pub/pri curve25519 keys: {{err}}", err)
+		}
+		s.cachedRemotePubKey = theirPubKey.Curve25519PublicKey
+		s.cachedPubKey = pub
+		s.cachedPriKey = pri
+	}
+
+	aesKey, err := dhutil.GenerateSharedKey(s.cachedPriKey, s.cachedRemotePubKey)
+	if err != nil {
+		return "", errwrap.Wrapf("error deriving shared key: {{err}}", err)
+	}
+	if len(aesKey) == 0 {
+		return "", errors.New("derived AES key is empty")
+	}
+
+	resp := new(dhutil.Envelope)
+	resp.EncryptedPayload, resp.Nonce, err = dhutil.EncryptAES(aesKey, []byte(token), aadBytes)
+	if err != nil {
+		return "", errwrap.Wrapf("error encrypting with shared key: {{err}}", err)
+	}
+	resp.Curve25519PublicKey = s.cachedPubKey
+
+	m, err := jsonutil.EncodeJSON(resp)
+	if err != nil {
+		return "", errwrap.Wrapf("error encoding encrypted payload: {{err}}", err)
+	}
+
+	return string(m), nil
+}
+
+func (s *SinkConfig) wrapToken(client *api.Client, wrapTTL time.Duration, token string) (string, error) {
+	wrapClient, err := client.Clone()
+	if err != nil {
+		return "", errwrap.Wrapf("error deriving client for wrapping, not writing out to sink: {{err}}", err)
+	}
+	wrapClient.SetToken(token)
+	wrapClient.SetWrappingLookupFunc(func(string, string) string {
+		return wrapTTL.String()
+	})
+	secret, err := wrapClient.Logical().Write("sys/wrapping/wrap", map[string]interface{}{
+		"token": token,
+	})
+	if err != nil {
+		return "", errwrap.Wrapf("error wrapping token, not writing out to sink: {{err}}", err)
+	}
+	if secret == nil {
+		return "", errors.New("nil secret returned, not writing out to sink")
+	}
+	if secret.WrapInfo == nil {
+		return "", errors.New("nil wrap info returned, not writing out to sink")
+	}
+
+	m, err := jsonutil.EncodeJSON(secret.WrapInfo)
+	if err != nil {
+		return "", errwrap.Wrapf("error marshaling token, not writing out to sink: {{err}}", err)
+	}
+
+	return string(m), nil
+}
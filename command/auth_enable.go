@@ -25,6 +25,7 @@ type AuthEnableCommand struct {
 	flagAuditNonHMACResponseKeys  []string
 	flagListingVisibility         string
 	flagPassthroughRequestHeaders []string
+	flagTokenType                 string
 	flagPluginName                string
 	flagOptions                   map[string]string
 	flagLocal                     bool
@@ -129,6 +130,13 @@ func (c *AuthEnableCommand) Flags() *FlagSets {
 			"will be sent to the backend",
 	})
 
+	f.StringVar(&StringVar{
+		Name:   flagNameTokenType,
+		Target: &c.flagTokenType,
+		Usage: "Type of the tokens handed out by the auth method, either " +
+			"\"service\" or \"batch\".",
+	})
+
 	f.StringVar(&StringVar{
 		Name:       "plugin-name",
 		Target:     &c.flagPluginName,
@@ -241,6 +249,10 @@ func (c *AuthEnableCommand) Run(args []string) int {
 		if fl.Name == flagNamePassthroughRequestHeaders {
 			authOpts.Config.PassthroughRequestHeaders = c.flagPassthroughRequestHeaders
 		}
+
+		if fl.Name == flagNameTokenType {
+			authOpts.Config.TokenType = c.flagTokenType
+		}
 	})
 
 	if err := client.Sys().EnableAuthWithOptions(authPath, authOpts); err != nil {
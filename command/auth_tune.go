@@ -23,6 +23,7 @@ type AuthTuneCommand struct {
 	flagAuditNonHMACRequestKeys  []string
 	flagAuditNonHMACResponseKeys []string
 	flagListingVisibility        string
+	flagTokenType                string
 }
 
 func (c *AuthTuneCommand) Synopsis() string {
@@ -101,6 +102,13 @@ func (c *AuthTuneCommand) Flags() *FlagSets {
 		Usage:  "Determines the visibility of the mount in the UI-specific listing endpoint.",
 	})
 
+	f.StringVar(&StringVar{
+		Name:   flagNameTokenType,
+		Target: &c.flagTokenType,
+		Usage: "Type of the tokens handed out by the auth method, either " +
+			"\"service\" or \"batch\".",
+	})
+
 	return set
 }
 
@@ -155,6 +163,10 @@ func (c *AuthTuneCommand) Run(args []string) int {
 		if fl.Name == flagNameListingVisibility {
 			mountConfigInput.ListingVisibility = c.flagListingVisibility
 		}
+
+		if fl.Name == flagNameTokenType {
+			mountConfigInput.TokenType = c.flagTokenType
+		}
 	})
 
 	// Append /auth (since that's where auths live) and a trailing slash to
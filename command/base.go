@@ -39,12 +39,15 @@ type BaseCommand struct {
 	flagTLSServerName string
 	flagTLSSkipVerify bool
 	flagWrapTTL       time.Duration
+	flagNamespace     string
 
 	flagFormat string
 	flagField  string
 
 	flagMFA []string
 
+	flagPolicyOverride bool
+
 	tokenHelper token.TokenHelper
 
 	client *api.Client
@@ -91,6 +94,11 @@ func (c *BaseCommand) Client() (*api.Client, error) {
 	// Set the wrapping function
 	client.SetWrappingLookupFunc(c.DefaultWrappingLookupFunc)
 
+	// The flag takes precedence over the namespace from the environment
+	if c.flagNamespace != "" {
+		client.SetNamespace(c.flagNamespace)
+	}
+
 	// Get the token if it came in from the environment
 	token := client.Token()
 
@@ -113,6 +121,8 @@ func (c *BaseCommand) Client() (*api.Client, error) {
 
 	client.SetMFACreds(c.flagMFA)
 
+	client.SetPolicyOverride(c.flagPolicyOverride)
+
 	c.client = client
 
 	return client, nil
@@ -262,6 +272,17 @@ func (c *BaseCommand) flagSet(bit FlagSetBit) *FlagSets {
 					"or \"5m\".",
 			})
 
+			f.StringVar(&StringVar{
+				Name:       "namespace",
+				Target:     &c.flagNamespace,
+				Default:    "",
+				EnvVar:     api.EnvVaultNamespace,
+				Completion: complete.PredictAnything,
+				Usage: "The namespace to use for the command. Paths are relative " +
+					"to this namespace. Setting this is not necessary when using " +
+					"the root namespace.",
+			})
+
 			f.StringSliceVar(&StringSliceVar{
 				Name:       "mfa",
 				Target:     &c.flagMFA,
@@ -270,6 +291,14 @@ func (c *BaseCommand) flagSet(bit FlagSetBit) *FlagSets {
 				Completion: complete.PredictAnything,
 				Usage:      "Supply MFA credentials as part of X-Vault-MFA header.",
 			})
+
+			f.BoolVar(&BoolVar{
+				Name:    "policy-override",
+				Target:  &c.flagPolicyOverride,
+				Default: false,
+				Usage: "Override soft-mandatory endpoint governing policies which " +
+					"would otherwise deny the request.",
+			})
 		}
 
 		if bit&(FlagSetOutputField|FlagSetOutputFormat) != 0 {
@@ -78,6 +78,7 @@ func (b *BaseCommand) PredictVaultAvailableMounts() complete.Predictor {
 		"consul",
 		"database",
 		"generic",
+		"ldap",
 		"pki",
 		"plugin",
 		"rabbitmq",
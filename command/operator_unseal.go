@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/helper/password"
 	"github.com/mitchellh/cli"
 	"github.com/posener/complete"
@@ -17,7 +18,8 @@ var _ cli.CommandAutocomplete = (*OperatorUnsealCommand)(nil)
 type OperatorUnsealCommand struct {
 	*BaseCommand
 
-	flagReset bool
+	flagReset   bool
+	flagMigrate bool
 
 	testOutput io.Writer // for tests
 }
@@ -44,6 +46,12 @@ Usage: vault operator unseal [options] [KEY]
       $ vault operator unseal
       Key (will be hidden): IXyR0OJnSFobekZMMCKCoVEpT7wI6l+USMzE3IcyDyo=
 
+  When a seal migration is configured, each key must be provided with the
+  -migrate flag. The keys are unseal keys when migrating away from Shamir and
+  recovery keys when migrating away from an auto seal:
+
+      $ vault operator unseal -migrate
+
 ` + c.Flags().Help()
 
 	return strings.TrimSpace(helpText)
@@ -64,6 +72,17 @@ func (c *OperatorUnsealCommand) Flags() *FlagSets {
 		Usage:      "Discard any previously entered keys to the unseal process.",
 	})
 
+	f.BoolVar(&BoolVar{
+		Name:       "migrate",
+		Aliases:    []string{},
+		Target:     &c.flagMigrate,
+		Default:    false,
+		EnvVar:     "",
+		Completion: complete.PredictNothing,
+		Usage: "Indicate that this share is provided with the intent that it is " +
+			"part of a seal migration process.",
+	})
+
 	return set
 }
 
@@ -135,7 +154,10 @@ func (c *OperatorUnsealCommand) Run(args []string) int {
 		unsealKey = strings.TrimSpace(value)
 	}
 
-	status, err := client.Sys().Unseal(unsealKey)
+	status, err := client.Sys().UnsealWithOptions(&api.UnsealOpts{
+		Key:     unsealKey,
+		Migrate: c.flagMigrate,
+	})
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error unsealing: %s", err))
 		return 2
@@ -35,6 +35,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/command/server"
+	serverseal "github.com/hashicorp/vault/command/server/seal"
 	"github.com/hashicorp/vault/helper/gated-writer"
 	"github.com/hashicorp/vault/helper/logging"
 	"github.com/hashicorp/vault/helper/mlock"
@@ -420,12 +421,27 @@ func (c *ServerCommand) Run(args []string) int {
 	infoKeys = append(infoKeys, "log level")
 
 	var seal vault.Seal = vault.NewDefaultSeal()
+	seal, sealConfigError := serverseal.ConfigureSeal(config, &infoKeys, &info, c.logger.Named("seal"), seal)
+	if sealConfigError != nil {
+		c.UI.Error(fmt.Sprintf("Error configuring seal: %v", sealConfigError))
+		return 1
+	}
+
+	// A disabled seal is only used to unseal the storage when migrating back
+	// to Shamir
+	var unwrapSeal vault.Seal
+	if config.Seal != nil && config.Seal.Disabled {
+		unwrapSeal = seal
+		seal = vault.NewDefaultSeal()
+	}
 
 	// Ensure that the seal finalizer is called, even if using verify-only
 	defer func() {
-		if seal != nil {
-			err = seal.Finalize(context.Background())
-			if err != nil {
+		for _, s := range []vault.Seal{seal, unwrapSeal} {
+			if s == nil {
+				continue
+			}
+			if err := s.Finalize(context.Background()); err != nil {
 				c.UI.Error(fmt.Sprintf("Error finalizing seals: %v", err))
 			}
 		}
@@ -454,6 +470,7 @@ func (c *ServerCommand) Run(args []string) int {
 		PluginDirectory:    config.PluginDirectory,
 		EnableUI:           config.EnableUI,
 		EnableRaw:          config.EnableRawEndpoint,
+		PerformanceStandby: config.PerformanceStandby,
 	}
 	if c.flagDev {
 		coreConfig.DevToken = c.flagDevRootTokenID
@@ -627,6 +644,11 @@ CLUSTER_SYNTHESIS_COMPLETE:
 		}
 	}
 
+	if err := adjustCoreForSealMigration(core, seal, unwrapSeal); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
 	// Copy the reload funcs pointers back
 	c.reloadFuncs = coreConfig.ReloadFuncs
 	c.reloadFuncsLock = coreConfig.ReloadFuncsLock
@@ -1600,3 +1622,40 @@ func (g *grpclogFaker) Println(args ...interface{}) {
 		g.logger.Debug(fmt.Sprintln(args...))
 	}
 }
+
+// adjustCoreForSealMigration sets up a seal migration when the seal the
+// storage was initialized with differs from the configured one. barrierSeal
+// is the configured seal and unwrapSeal is the seal of a disabled seal
+// stanza, if any.
+func adjustCoreForSealMigration(core *vault.Core, barrierSeal, unwrapSeal vault.Seal) error {
+	existingType, err := core.PhysicalSealConfigType(context.Background())
+	if err != nil {
+		return fmt.Errorf("Error checking for existing seal: %s", err)
+	}
+
+	switch {
+	case existingType == "":
+		// Not initialized yet, so there is nothing to migrate
+		return nil
+
+	case unwrapSeal != nil:
+		switch existingType {
+		case vault.SealTypeShamir:
+			// Already migrated
+			return nil
+		case unwrapSeal.BarrierType():
+			core.SetSealsForMigration(unwrapSeal, barrierSeal)
+			return nil
+		}
+		return fmt.Errorf("Existing seal type %q does not match the disabled seal type %q", existingType, unwrapSeal.BarrierType())
+
+	case existingType == barrierSeal.BarrierType():
+		return nil
+
+	case existingType == vault.SealTypeShamir:
+		core.SetSealsForMigration(vault.NewDefaultSeal(), barrierSeal)
+		return nil
+	}
+
+	return fmt.Errorf("Existing seal type %q does not match the configured seal type %q; to migrate, configure the existing seal with disabled set to true", existingType, barrierSeal.BarrierType())
+}
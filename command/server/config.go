@@ -55,6 +55,9 @@ type Config struct {
 	ClusterAddr          string      `hcl:"cluster_addr"`
 	DisableClustering    bool        `hcl:"-"`
 	DisableClusteringRaw interface{} `hcl:"disable_clustering"`
+
+	PerformanceStandby    bool        `hcl:"-"`
+	PerformanceStandbyRaw interface{} `hcl:"performance_standby"`
 }
 
 // DevConfig is a Config that is used for dev mode of Vault.
@@ -122,8 +125,9 @@ func (b *Storage) GoString() string {
 
 // Seal contains Seal configuration for the server
 type Seal struct {
-	Type   string
-	Config map[string]string
+	Type     string
+	Disabled bool
+	Config   map[string]string
 }
 
 func (h *Seal) GoString() string {
@@ -313,6 +317,11 @@ func (c *Config) Merge(c2 *Config) *Config {
 		result.PidFile = c2.PidFile
 	}
 
+	result.PerformanceStandby = c.PerformanceStandby
+	if c2.PerformanceStandby {
+		result.PerformanceStandby = c2.PerformanceStandby
+	}
+
 	return result
 }
 
@@ -394,6 +403,12 @@ func ParseConfig(d string, logger log.Logger) (*Config, error) {
 		}
 	}
 
+	if result.PerformanceStandbyRaw != nil {
+		if result.PerformanceStandby, err = parseutil.ParseBool(result.PerformanceStandbyRaw); err != nil {
+			return nil, err
+		}
+	}
+
 	list, ok := obj.Node.(*ast.ObjectList)
 	if !ok {
 		return nil, fmt.Errorf("error parsing: file doesn't contain a root object")
@@ -422,6 +437,7 @@ func ParseConfig(d string, logger log.Logger) (*Config, error) {
 		"api_addr",
 		"cluster_addr",
 		"disable_clustering",
+		"performance_standby",
 	}
 	if err := checkHCLKeys(list, valid); err != nil {
 		return nil, err
@@ -729,10 +745,26 @@ func parseSeal(result *Config, list *ast.ObjectList, blockName string) error {
 			"kms_key_id",
 			"max_parallel",
 		}
+	case "transit":
+		valid = []string{
+			"address",
+			"token",
+			"key_name",
+			"mount_path",
+			"namespace",
+			"disable_renewal",
+			"tls_ca_cert",
+			"tls_client_cert",
+			"tls_client_key",
+			"tls_server_name",
+			"tls_skip_verify",
+		}
 	default:
 		return fmt.Errorf("invalid seal type %q", key)
 	}
 
+	valid = append(valid, "disabled")
+
 	if err := checkHCLKeys(item.Val, valid); err != nil {
 		return multierror.Prefix(err, fmt.Sprintf("%s.%s:", blockName, key))
 	}
@@ -742,15 +774,39 @@ func parseSeal(result *Config, list *ast.ObjectList, blockName string) error {
 		return multierror.Prefix(err, fmt.Sprintf("%s.%s:", blockName, key))
 	}
 
+	var disabled bool
+	if v, ok := m["disabled"]; ok {
+		var err error
+		disabled, err = strconv.ParseBool(v)
+		if err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("%s.%s.disabled:", blockName, key))
+		}
+		delete(m, "disabled")
+	}
+
 	result.Seal = &Seal{
-		Type:   strings.ToLower(key),
-		Config: m,
+		Type:     strings.ToLower(key),
+		Disabled: disabled,
+		Config:   m,
 	}
 
 	return nil
 }
 
 func parseListeners(result *Config, list *ast.ObjectList) error {
+	listeners, err := ParseListeners(list)
+	if err != nil {
+		return err
+	}
+
+	result.Listeners = listeners
+	return nil
+}
+
+// ParseListeners parses the given listener blocks. It is exported so that
+// other commands that serve the Vault API, such as the agent, accept the same
+// listener configuration as the server.
+func ParseListeners(list *ast.ObjectList) ([]*Listener, error) {
 	listeners := make([]*Listener, 0, len(list.Items))
 	for _, item := range list.Items {
 		key := "listener"
@@ -778,12 +834,12 @@ func parseListeners(result *Config, list *ast.ObjectList) error {
 			"token",
 		}
 		if err := checkHCLKeys(item.Val, valid); err != nil {
-			return multierror.Prefix(err, fmt.Sprintf("listeners.%s:", key))
+			return nil, multierror.Prefix(err, fmt.Sprintf("listeners.%s:", key))
 		}
 
 		var m map[string]interface{}
 		if err := hcl.DecodeObject(&m, item.Val); err != nil {
-			return multierror.Prefix(err, fmt.Sprintf("listeners.%s:", key))
+			return nil, multierror.Prefix(err, fmt.Sprintf("listeners.%s:", key))
 		}
 
 		lnType := strings.ToLower(key)
@@ -794,8 +850,7 @@ func parseListeners(result *Config, list *ast.ObjectList) error {
 		})
 	}
 
-	result.Listeners = listeners
-	return nil
+	return listeners, nil
 }
 
 func parseTelemetry(result *Config, list *ast.ObjectList) error {
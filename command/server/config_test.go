@@ -382,6 +382,47 @@ listener "tcp" {
 
 }
 
+func TestParseSeal_transit(t *testing.T) {
+	obj, _ := hcl.Parse(strings.TrimSpace(`
+seal "transit" {
+	address = "https://vault:8200"
+	token = "s.Qf1s5zigZ4OX6akYjQXJC1jY"
+	key_name = "autounseal"
+	mount_path = "transit/"
+	disabled = "true"
+}`))
+
+	var config Config
+	list, _ := obj.Node.(*ast.ObjectList)
+	if err := parseSeal(&config, list.Filter("seal"), "seal"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Seal{
+		Type:     "transit",
+		Disabled: true,
+		Config: map[string]string{
+			"address":    "https://vault:8200",
+			"token":      "s.Qf1s5zigZ4OX6akYjQXJC1jY",
+			"key_name":   "autounseal",
+			"mount_path": "transit/",
+		},
+	}
+	if !reflect.DeepEqual(config.Seal, expected) {
+		t.Fatalf("expected \n\n%#v\n\n to be \n\n%#v\n\n", config.Seal, expected)
+	}
+
+	obj, _ = hcl.Parse(strings.TrimSpace(`
+seal "transit" {
+	key_name = "autounseal"
+	bad_key = "foo"
+}`))
+	list, _ = obj.Node.(*ast.ObjectList)
+	if err := parseSeal(&config, list.Filter("seal"), "seal"); err == nil {
+		t.Fatal("expected error for invalid key")
+	}
+}
+
 func TestParseConfig_badTopLevel(t *testing.T) {
 	logger := logging.NewVaultLogger(log.Debug)
 
@@ -0,0 +1,31 @@
+package seal
+
+import (
+	"fmt"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/vault"
+)
+
+var (
+	ConfigureSeal = configureSeal
+)
+
+// configureSeal returns the seal described by the seal stanza of the server
+// configuration, or inseal if there is none. Information about the seal is
+// added to info to be shown on startup.
+func configureSeal(config *server.Config, infoKeys *[]string, info *map[string]string, logger log.Logger, inseal vault.Seal) (vault.Seal, error) {
+	if config.Seal == nil {
+		return inseal, nil
+	}
+
+	switch config.Seal.Type {
+	case vault.SealTypeShamir:
+		return inseal, nil
+	case vault.SealTypeTransit:
+		return configureTransitSeal(config, infoKeys, info, logger, inseal)
+	default:
+		return nil, fmt.Errorf("seal type %q is not supported", config.Seal.Type)
+	}
+}
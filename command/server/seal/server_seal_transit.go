@@ -0,0 +1,27 @@
+package seal
+
+import (
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/vault"
+	"github.com/hashicorp/vault/vault/seal/transit"
+)
+
+func configureTransitSeal(config *server.Config, infoKeys *[]string, info *map[string]string, logger log.Logger, inseal vault.Seal) (vault.Seal, error) {
+	transitSeal := transit.NewSeal(logger)
+	sealInfo, err := transitSeal.SetConfig(config.Seal.Config)
+	if err != nil {
+		return nil, err
+	}
+	autoseal := vault.NewAutoSeal(transitSeal)
+
+	*infoKeys = append(*infoKeys, "seal type")
+	(*info)["seal type"] = autoseal.BarrierType()
+	for _, k := range []string{"transit address", "transit path", "transit key", "transit namespace"} {
+		if v, ok := sealInfo[k]; ok {
+			*infoKeys = append(*infoKeys, k)
+			(*info)[k] = v
+		}
+	}
+	return autoseal, nil
+}
@@ -26,6 +26,7 @@ type TokenCreateCommand struct {
 	flagNoDefaultPolicy bool
 	flagUseLimit        int
 	flagRole            string
+	flagType            string
 	flagMetadata        map[string]string
 	flagPolicies        []string
 
@@ -153,6 +154,15 @@ func (c *TokenCreateCommand) Flags() *FlagSets {
 			"must have permission for \"auth/token/create/<role>\".",
 	})
 
+	f.StringVar(&StringVar{
+		Name:    "type",
+		Target:  &c.flagType,
+		Default: "",
+		Usage: "Type of the token, either \"service\" or \"batch\". Batch tokens " +
+			"are not persisted and cannot be renewed, revoked or create child " +
+			"tokens.",
+	})
+
 	f.StringMapVar(&StringMapVar{
 		Name:       "metadata",
 		Target:     &c.flagMetadata,
@@ -229,6 +239,7 @@ func (c *TokenCreateCommand) Run(args []string) int {
 		Renewable:       &c.flagRenewable,
 		ExplicitMaxTTL:  c.flagExplicitMaxTTL.String(),
 		Period:          c.flagPeriod.String(),
+		Type:            c.flagType,
 	}
 
 	var secret *api.Secret
@@ -6,4 +6,17 @@ const (
 	ExpirationRestoreWorkerCount = 64
 
 	VaultKVCLIClientHeader = "X-Vault-Kv-Client"
+
+	// NamespaceHeaderName is the header carrying the namespace requests are
+	// scoped to
+	NamespaceHeaderName = "X-Vault-Namespace"
+
+	// WALIndexHeaderName is the header carrying the position in the storage
+	// WAL of the active node that the state of a node reflects. Clients pass
+	// it back to performance standbys to read their own writes.
+	WALIndexHeaderName = "X-Vault-Index"
+
+	// AuthInternalDataMFAMethodKey is the key of the internal data of logins
+	// validated with MFA which holds the type of the MFA method
+	AuthInternalDataMFAMethodKey = "mfa_method"
 )
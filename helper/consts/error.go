@@ -11,6 +11,11 @@ var (
 	// No operation is expected to succeed until active.
 	ErrStandby = errors.New("Vault is in standby mode")
 
+	// ErrPerfStandbyPleaseForward is returned when a performance standby
+	// cannot handle a request, which should be forwarded to the active node
+	// instead
+	ErrPerfStandbyPleaseForward = errors.New("please forward to the active node")
+
 	// Used when .. is used in a path
 	ErrPathContainsParentReferences = errors.New("path cannot contain parent references")
 )
@@ -21,6 +21,7 @@ const (
 	ReplicationDRBootstrapping
 	ReplicationPerformanceDisabled
 	ReplicationDRDisabled
+	ReplicationPerformanceStandby
 )
 
 func (r ReplicationState) string() string {
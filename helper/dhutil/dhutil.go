@@ -0,0 +1,102 @@
+package dhutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// PublicKeyInfo is the format in which a public key is shared between parties
+// wishing to derive a shared key.
+type PublicKeyInfo struct {
+	Curve25519PublicKey []byte `json:"curve25519_public_key"`
+}
+
+// Envelope is the format of a payload encrypted with a key derived using
+// Diffie-Hellman. It carries the sender's public key so that the recipient can
+// derive the same key.
+type Envelope struct {
+	Curve25519PublicKey []byte `json:"curve25519_public_key"`
+	Nonce               []byte `json:"nonce"`
+	EncryptedPayload    []byte `json:"encrypted_payload"`
+}
+
+// GeneratePublicPrivateKey generates a new Curve25519 key pair.
+func GeneratePublicPrivateKey() ([]byte, []byte, error) {
+	var scalar, public [32]byte
+
+	if _, err := io.ReadFull(rand.Reader, scalar[:]); err != nil {
+		return nil, nil, err
+	}
+
+	curve25519.ScalarBaseMult(&public, &scalar)
+	return public[:], scalar[:], nil
+}
+
+// GenerateSharedKey derives a shared key from our private key and the other
+// party's public key.
+func GenerateSharedKey(ourPrivate, theirPublic []byte) ([]byte, error) {
+	if len(ourPrivate) != 32 {
+		return nil, fmt.Errorf("invalid private key length: %d", len(ourPrivate))
+	}
+	if len(theirPublic) != 32 {
+		return nil, fmt.Errorf("invalid public key length: %d", len(theirPublic))
+	}
+
+	var scalar, pub, sharedKey [32]byte
+	copy(scalar[:], ourPrivate)
+	copy(pub[:], theirPublic)
+
+	curve25519.ScalarMult(&sharedKey, &scalar, &pub)
+
+	// An all-zero output means the other party's public key was a low order
+	// point and the result is not secret
+	var zero [32]byte
+	if sharedKey == zero {
+		return nil, errors.New("invalid public key")
+	}
+
+	return sharedKey[:], nil
+}
+
+// EncryptAES encrypts the plaintext with AES-GCM using the given key and
+// additional authenticated data, returning the ciphertext and the nonce used.
+func EncryptAES(key, plaintext, aad []byte) ([]byte, []byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, 12)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return aesgcm.Seal(nil, nonce, plaintext, aad), nonce, nil
+}
+
+// DecryptAES decrypts the ciphertext with AES-GCM using the given key, nonce
+// and additional authenticated data.
+func DecryptAES(key, ciphertext, nonce, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return aesgcm.Open(nil, nonce, ciphertext, aad)
+}
@@ -0,0 +1,69 @@
+package dhutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDHUtil_SharedKey(t *testing.T) {
+	pub1, pri1, err := GeneratePublicPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub2, pri2, err := GeneratePublicPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key1, err := GenerateSharedKey(pri1, pub2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := GenerateSharedKey(pri2, pub1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key1, key2) {
+		t.Fatal("derived keys do not match")
+	}
+
+	if _, err := GenerateSharedKey(pri1, make([]byte, 32)); err == nil {
+		t.Fatal("expected error with low order public key")
+	}
+}
+
+func TestDHUtil_EncryptDecrypt(t *testing.T) {
+	pub1, pri1, err := GeneratePublicPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub2, pri2, err := GeneratePublicPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key1, err := GenerateSharedKey(pri1, pub2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, nonce, err := EncryptAES(key1, []byte("token"), []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key2, err := GenerateSharedKey(pri2, pub1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := DecryptAES(key2, ciphertext, nonce, []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "token" {
+		t.Fatalf("bad: %q", plaintext)
+	}
+
+	if _, err := DecryptAES(key2, ciphertext, nonce, []byte("wrong")); err == nil {
+		t.Fatal("expected error with mismatched aad")
+	}
+}
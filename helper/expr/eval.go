@@ -0,0 +1,584 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/cidrutil"
+)
+
+// Result is the outcome of the evaluation of a program
+type Result struct {
+	// Allowed is the value of the main rule
+	Allowed bool
+
+	// Rules holds the values of the rules which were evaluated. Rules are
+	// only evaluated when they are referred to, so rules which weren't needed
+	// to get the result are missing.
+	Rules map[string]interface{}
+}
+
+// Eval evaluates the program against the given values, which are available
+// to the rules as identifiers. Values which are missing from maps, such as
+// request data which wasn't sent, are null; null is equal only to null, is
+// not contained in anything and doesn't match anything, but it is an error to
+// use it in ordering comparisons or arithmetic.
+func (p *Program) Eval(env map[string]interface{}) (*Result, error) {
+	e := &evaluator{
+		program: p,
+		env:     env,
+		values:  make(map[string]interface{}),
+		pending: make(map[string]bool),
+	}
+	raw, err := e.rule(MainRule)
+	if err != nil {
+		return &Result{Rules: e.values}, err
+	}
+	allowed, ok := raw.(bool)
+	if !ok {
+		return &Result{Rules: e.values}, fmt.Errorf("rule %q must evaluate to a boolean, got %s", MainRule, typeName(raw))
+	}
+	return &Result{
+		Allowed: allowed,
+		Rules:   e.values,
+	}, nil
+}
+
+type evaluator struct {
+	program *Program
+	env     map[string]interface{}
+	values  map[string]interface{}
+	pending map[string]bool
+}
+
+func (e *evaluator) rule(name string) (interface{}, error) {
+	if v, ok := e.values[name]; ok {
+		return v, nil
+	}
+	if e.pending[name] {
+		return nil, fmt.Errorf("rule %q refers to itself", name)
+	}
+	for _, r := range e.program.rules {
+		if r.name != name {
+			continue
+		}
+		e.pending[name] = true
+		v, err := r.expr.eval(e)
+		delete(e.pending, name)
+		if err != nil {
+			// Errors of the rules this one refers to are already attributed
+			if _, ok := err.(*ruleError); ok {
+				return nil, err
+			}
+			return nil, &ruleError{rule: name, err: err}
+		}
+		e.values[name] = v
+		return v, nil
+	}
+	return nil, fmt.Errorf("unknown rule %q", name)
+}
+
+// ruleError attributes an evaluation error to the rule it happened in
+type ruleError struct {
+	rule string
+	err  error
+}
+
+func (e *ruleError) Error() string {
+	return fmt.Sprintf("rule %q: %v", e.rule, e.err)
+}
+
+// Nodes
+
+type node interface {
+	eval(e *evaluator) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(e *evaluator) (interface{}, error) {
+	return n.value, nil
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) eval(e *evaluator) (interface{}, error) {
+	list := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+type identNode struct {
+	name string
+	rule bool
+}
+
+func (n *identNode) eval(e *evaluator) (interface{}, error) {
+	if n.rule {
+		return e.rule(n.name)
+	}
+	v, ok := e.env[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown identifier %q", n.name)
+	}
+	return normalize(v), nil
+}
+
+type indexNode struct {
+	operand node
+	index   node
+}
+
+func (n *indexNode) eval(e *evaluator) (interface{}, error) {
+	operand, err := n.operand.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(e)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operand := operand.(type) {
+	case nil:
+		return nil, nil
+
+	case map[string]interface{}:
+		key, ok := index.(string)
+		if !ok {
+			return nil, fmt.Errorf("maps can only be indexed by strings, got %s", typeName(index))
+		}
+		return normalize(operand[key]), nil
+
+	case []interface{}:
+		f, ok := index.(float64)
+		if !ok || f != float64(int(f)) {
+			return nil, fmt.Errorf("lists can only be indexed by integers, got %s", typeName(index))
+		}
+		if int(f) < 0 || int(f) >= len(operand) {
+			return nil, nil
+		}
+		return normalize(operand[int(f)]), nil
+	}
+	return nil, fmt.Errorf("cannot index %s", typeName(operand))
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(e *evaluator) (interface{}, error) {
+	v, err := n.operand.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("operand of \"not\" must be a boolean, got %s", typeName(v))
+	}
+	return !b, nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) eval(e *evaluator) (interface{}, error) {
+	left, err := n.boolOperand(e, n.left)
+	if err != nil {
+		return nil, err
+	}
+	// The right operand is only evaluated if it can change the result
+	if (n.op == "and" && !left) || (n.op == "or" && left) {
+		return left, nil
+	}
+	return n.boolOperand(e, n.right)
+}
+
+func (n *logicalNode) boolOperand(e *evaluator, operand node) (bool, error) {
+	v, err := operand.eval(e)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("operands of %q must be booleans, got %s", n.op, typeName(v))
+	}
+	return b, nil
+}
+
+type arithNode struct {
+	op          string
+	left, right node
+}
+
+func (n *arithNode) eval(e *evaluator) (interface{}, error) {
+	left, err := n.left.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(e)
+	if err != nil {
+		return nil, err
+	}
+
+	switch l := left.(type) {
+	case float64:
+		if r, ok := right.(float64); ok {
+			if n.op == "+" {
+				return l + r, nil
+			}
+			return l - r, nil
+		}
+	case string:
+		if r, ok := right.(string); ok && n.op == "+" {
+			return l + r, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid operands for %q: %s and %s", n.op, typeName(left), typeName(right))
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) eval(e *evaluator) (interface{}, error) {
+	left, err := n.left.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(e)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		return contains(right, left)
+	case "not in":
+		found, err := contains(right, left)
+		return !found, err
+	case "contains":
+		return contains(left, right)
+	case "matches":
+		return matches(left, right)
+	}
+
+	// Ordering comparisons
+	var cmp int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %s and %s", typeName(left), typeName(right))
+		}
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %s and %s", typeName(left), typeName(right))
+		}
+		cmp = strings.Compare(l, r)
+	default:
+		return nil, fmt.Errorf("cannot compare %s and %s", typeName(left), typeName(right))
+	}
+
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", n.op)
+}
+
+type callNode struct {
+	name string
+	fn   function
+	args []node
+}
+
+func (n *callNode) eval(e *evaluator) (interface{}, error) {
+	args := make([]interface{}, 0, len(n.args))
+	for _, arg := range n.args {
+		v, err := arg.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	v, err := n.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", n.name, err)
+	}
+	return v, nil
+}
+
+// Functions
+
+type function struct {
+	args int
+	call func(args []interface{}) (interface{}, error)
+}
+
+var functions = map[string]function{
+	// cidr_match returns whether the IP address belongs to the CIDR block,
+	// or to any of the blocks if given a list
+	"cidr_match": {2, func(args []interface{}) (interface{}, error) {
+		// Addresses which can't be parsed, such as those of requests
+		// without connection information, never match
+		ip, ok := args[1].(string)
+		if !ok || net.ParseIP(ip) == nil {
+			return false, nil
+		}
+		var cidrs []interface{}
+		switch c := args[0].(type) {
+		case string:
+			cidrs = []interface{}{c}
+		case []interface{}:
+			cidrs = c
+		default:
+			return nil, fmt.Errorf("expected a CIDR block or a list of them, got %s", typeName(args[0]))
+		}
+		for _, raw := range cidrs {
+			cidr, ok := normalize(raw).(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a CIDR block, got %s", typeName(raw))
+			}
+			match, err := cidrutil.IPBelongsToCIDR(ip, cidr)
+			if err != nil {
+				return nil, err
+			}
+			if match {
+				return true, nil
+			}
+		}
+		return false, nil
+	}},
+
+	// length returns the length of a string, list or map
+	"length": {1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case nil:
+			return float64(0), nil
+		case string:
+			return float64(len(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return nil, fmt.Errorf("cannot get the length of %s", typeName(args[0]))
+	}},
+
+	"starts_with": {2, func(args []interface{}) (interface{}, error) {
+		s, ok1 := args[0].(string)
+		prefix, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return false, nil
+		}
+		return strings.HasPrefix(s, prefix), nil
+	}},
+
+	"ends_with": {2, func(args []interface{}) (interface{}, error) {
+		s, ok1 := args[0].(string)
+		suffix, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return false, nil
+		}
+		return strings.HasSuffix(s, suffix), nil
+	}},
+}
+
+// Values
+
+// normalize converts values to the types the language works with: nil,
+// bool, float64, string, []interface{} and map[string]interface{}
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, float64, string, []interface{}, map[string]interface{}:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case []string:
+		list := make([]interface{}, 0, len(v))
+		for _, s := range v {
+			list = append(list, s)
+		}
+		return list
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for k, s := range v {
+			m[k] = s
+		}
+		return m
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+	case reflect.Slice:
+		if rv.IsNil() {
+			return nil
+		}
+		list := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			list = append(list, rv.Index(i).Interface())
+		}
+		return list
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+		if rv.Type().Key().Kind() == reflect.String {
+			m := make(map[string]interface{}, rv.Len())
+			for _, key := range rv.MapKeys() {
+				m[key.String()] = rv.MapIndex(key).Interface()
+			}
+			return m
+		}
+	}
+	return v
+}
+
+func equal(a, b interface{}) bool {
+	a, b = normalize(a), normalize(b)
+	switch a := a.(type) {
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			bv, ok := b[k]
+			if !ok || !equal(v, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// contains returns whether the item is an element of the list, a key of the
+// map or a substring of the string
+func contains(container, item interface{}) (bool, error) {
+	switch c := container.(type) {
+	case nil:
+		return false, nil
+	case []interface{}:
+		for _, el := range c {
+			if equal(el, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}:
+		key, ok := item.(string)
+		if !ok {
+			return false, nil
+		}
+		_, found := c[key]
+		return found, nil
+	case string:
+		s, ok := item.(string)
+		if !ok {
+			return false, nil
+		}
+		return strings.Contains(c, s), nil
+	}
+	return false, fmt.Errorf("cannot look for a value in %s", typeName(container))
+}
+
+func matches(value, pattern interface{}) (bool, error) {
+	p, ok := pattern.(string)
+	if !ok {
+		return false, fmt.Errorf("pattern must be a string, got %s", typeName(pattern))
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return false, err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return false, nil
+	}
+	return re.MatchString(s), nil
+}
+
+func typeName(v interface{}) string {
+	switch normalize(v).(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,470 @@
+// Package expr implements a small conditional language used to write
+// endpoint-governing policies. A program is a list of named rules:
+//
+//	# Requests must come from the office network during business hours
+//	office = cidr_match("10.0.0.0/8", request.connection.remote_addr)
+//	business_hours = time.hour >= 9 and time.hour < 17
+//	main = office and business_hours
+//
+// Rules can refer to the rules defined before them, and the program must
+// define a "main" rule evaluating to a boolean, which is its result.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+// MainRule is the name of the rule holding the result of a program
+const MainRule = "main"
+
+var keywords = map[string]bool{
+	"and":      true,
+	"or":       true,
+	"not":      true,
+	"in":       true,
+	"contains": true,
+	"matches":  true,
+	"true":     true,
+	"false":    true,
+	"null":     true,
+}
+
+// Program is a parsed list of rules
+type Program struct {
+	rules []*rule
+}
+
+type rule struct {
+	name string
+	expr node
+}
+
+// Rules returns the names of the rules of the program, in order
+func (p *Program) Rules() []string {
+	names := make([]string, 0, len(p.rules))
+	for _, r := range p.rules {
+		names = append(names, r.name)
+	}
+	return names
+}
+
+// Parse parses the source of a program
+func Parse(src string) (*Program, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{
+		tokens: tokens,
+		rules:  make(map[string]bool),
+	}
+	var program Program
+	for p.peek().kind != tokEOF {
+		name := p.next()
+		if name.kind != tokIdent {
+			return nil, p.errorf(name, "expected a rule name, got %q", name.text)
+		}
+		if p.rules[name.text] {
+			return nil, p.errorf(name, "rule %q is defined more than once", name.text)
+		}
+		if eq := p.next(); eq.kind != tokAssign {
+			return nil, p.errorf(eq, "expected \"=\" after rule %q, got %q", name.text, eq.text)
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.rules[name.text] = true
+		program.rules = append(program.rules, &rule{
+			name: name.text,
+			expr: n,
+		})
+	}
+
+	if !p.rules[MainRule] {
+		return nil, fmt.Errorf("no %q rule defined", MainRule)
+	}
+	return &program, nil
+}
+
+// Tokens
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokKeyword
+	tokNumber
+	tokString
+	tokAssign
+	tokOperator
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '\n':
+			line++
+			i++
+
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '#' || (r == '/' && i+1 < len(runes) && runes[i+1] == '/'):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			text := string(runes[start:i])
+			kind := tokIdent
+			if keywords[text] {
+				kind = tokKeyword
+			}
+			tokens = append(tokens, token{kind, text, line})
+
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, string(runes[start:i]), line})
+
+		case r == '"':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != '"' {
+				if runes[i] == '\\' {
+					i++
+				}
+				if i < len(runes) && runes[i] == '\n' {
+					break
+				}
+				i++
+			}
+			if i >= len(runes) || runes[i] != '"' {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			i++
+			text, err := strconv.Unquote(string(runes[start:i]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid string %s", line, string(runes[start:i]))
+			}
+			tokens = append(tokens, token{tokString, text, line})
+
+		default:
+			var op string
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<=", ">=":
+					op = two
+				}
+			}
+			if op == "" {
+				switch r {
+				case '<', '>', '+', '-':
+					op = string(r)
+				case '=':
+					tokens = append(tokens, token{tokAssign, "=", line})
+					i++
+					continue
+				case '(', ')', '[', ']', ',', '.':
+					tokens = append(tokens, token{tokPunct, string(r), line})
+					i++
+					continue
+				default:
+					return nil, fmt.Errorf("line %d: unexpected character %q", line, r)
+				}
+			}
+			tokens = append(tokens, token{tokOperator, op, line})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokEOF, "end of input", line}), nil
+}
+
+// Parser
+
+type parser struct {
+	tokens []token
+	pos    int
+
+	// rules are the rules defined so far, which can be referred to
+	rules map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(kind tokenKind, text string) bool {
+	if t := p.peek(); t.kind == kind && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	if !p.accept(kind, text) {
+		t := p.peek()
+		return p.errorf(t, "expected %q, got %q", text, t.text)
+	}
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", t.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokKeyword, "or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokKeyword, "and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.accept(tokKeyword, "not") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	var op string
+	switch {
+	case t.kind == tokOperator && t.text != "+" && t.text != "-":
+		op = t.text
+	case t.kind == tokKeyword && (t.text == "in" || t.text == "contains" || t.text == "matches"):
+		op = t.text
+	case t.kind == tokKeyword && t.text == "not":
+		p.next()
+		if in := p.peek(); in.kind != tokKeyword || in.text != "in" {
+			return nil, p.errorf(in, "expected \"in\" after \"not\", got %q", in.text)
+		}
+		op = "not in"
+	default:
+		return left, nil
+	}
+	p.next()
+
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return &compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokOperator || (t.text != "+" && t.text != "-") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept(tokOperator, "-") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &arithNode{op: "-", left: &literalNode{value: float64(0)}, right: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept(tokPunct, "."):
+			t := p.next()
+			if t.kind != tokIdent && t.kind != tokKeyword {
+				return nil, p.errorf(t, "expected a field name after \".\", got %q", t.text)
+			}
+			n = &indexNode{operand: n, index: &literalNode{value: t.text}}
+
+		case p.accept(tokPunct, "["):
+			index, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(tokPunct, "]"); err != nil {
+				return nil, err
+			}
+			n = &indexNode{operand: n, index: index}
+
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %q", t.text)
+		}
+		return &literalNode{value: f}, nil
+
+	case tokString:
+		return &literalNode{value: t.text}, nil
+
+	case tokKeyword:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+
+	case tokIdent:
+		if p.accept(tokPunct, "(") {
+			return p.parseCall(t)
+		}
+		if p.peek().kind == tokAssign {
+			return nil, p.errorf(t, "unexpected %q, rules must be separated by a new rule name", "=")
+		}
+		return &identNode{name: t.text, rule: p.rules[t.text]}, nil
+
+	case tokPunct:
+		switch t.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(tokPunct, ")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+
+		case "[":
+			var list listNode
+			for !p.accept(tokPunct, "]") {
+				if len(list.items) > 0 {
+					if err := p.expect(tokPunct, ","); err != nil {
+						return nil, err
+					}
+					// Allow a trailing comma
+					if p.accept(tokPunct, "]") {
+						break
+					}
+				}
+				item, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				list.items = append(list.items, item)
+			}
+			return &list, nil
+		}
+	}
+	return nil, p.errorf(t, "unexpected %q", t.text)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, p.errorf(name, "unknown function %q", name.text)
+	}
+
+	call := &callNode{name: name.text, fn: fn}
+	for !p.accept(tokPunct, ")") {
+		if len(call.args) > 0 {
+			if err := p.expect(tokPunct, ","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	if len(call.args) != fn.args {
+		return nil, p.errorf(name, "function %q takes %d arguments, got %d", name.text, fn.args, len(call.args))
+	}
+	return call, nil
+}
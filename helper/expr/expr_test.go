@@ -0,0 +1,188 @@
+package expr
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"no main":          `allowed = true`,
+		"duplicate rule":   "main = true\nmain = false",
+		"missing operand":  `main = 1 <`,
+		"unknown function": `main = banana(1)`,
+		"bad arity":        `main = length(1, 2)`,
+		"unterminated":     `main = "foo`,
+		"unbalanced":       `main = (true`,
+		"bad not":          `main = 1 not 2`,
+		"bad character":    `main = 1 ~ 2`,
+		"chained assign":   `main = a = true`,
+		"keyword as name":  `in = true`,
+	}
+	for name, src := range cases {
+		if _, err := Parse(src); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	env := map[string]interface{}{
+		"request": map[string]interface{}{
+			"path":      "secret/foo",
+			"operation": "update",
+			"data": map[string]interface{}{
+				"ttl":   json.Number("30"),
+				"value": "bar",
+				"list":  []interface{}{"a", "b"},
+			},
+			"connection": map[string]interface{}{
+				"remote_addr": "10.1.2.3",
+			},
+		},
+		"identity": map[string]interface{}{
+			"groups": map[string]interface{}{
+				"names": []string{"ops", "dev"},
+			},
+			"entity": map[string]interface{}{
+				"metadata": map[string]string{"team": "ops"},
+			},
+		},
+		"time": map[string]interface{}{
+			"hour": 10,
+		},
+		"token": nil,
+	}
+
+	cases := []struct {
+		src     string
+		allowed bool
+	}{
+		{`main = true`, true},
+		{`main = not true`, false},
+		{`main = request.path == "secret/foo"`, true},
+		{`main = request.path != "secret/foo"`, false},
+		{`main = request.data.ttl <= 30 and request.data.ttl > 29.5`, true},
+		{`main = request.data.ttl + 10 == 40`, true},
+		{`main = -request.data.ttl < 0`, true},
+		{`main = request.operation in ["create", "update"]`, true},
+		{`main = request.operation not in ["create", "update",]`, false},
+		{`main = request.data.list contains "b"`, true},
+		{`main = "value" in request.data`, true},
+		{`main = "oo" in request.path`, true},
+		{`main = request.path matches "^secret/f.+$"`, true},
+		{`main = request.data["value"] == "bar"`, true},
+		{`main = request.data.list[1] == "b"`, true},
+		{`main = request.data.list[5] == null`, true},
+		{`main = request.data.missing == null`, true},
+		{`main = request.data.missing.deeper == null`, true},
+		{`main = request.data.missing matches "."`, false},
+		{`main = token == null`, true},
+		{`main = "ops" in identity.groups.names`, true},
+		{`main = identity.entity.metadata.team == "ops"`, true},
+		{`main = cidr_match("10.0.0.0/8", request.connection.remote_addr)`, true},
+		{`main = cidr_match(["192.168.0.0/16", "172.16.0.0/12"], request.connection.remote_addr)`, false},
+		{`main = cidr_match("10.0.0.0/8", request.data.missing)`, false},
+		{`main = length(request.data.list) == 2 and length(request.path) == 10`, true},
+		{`main = starts_with(request.path, "secret/") and ends_with(request.path, "foo")`, true},
+		{`main = [1, "a"] == [1, "a"]`, true},
+		{`main = "a" < "b" and 2 >= 2`, true},
+		{`
+# Rules can refer to the rules defined before them
+business_hours = time.hour >= 9 and
+	time.hour < 17
+// Comments can use either style
+office = cidr_match("10.0.0.0/8", request.connection.remote_addr)
+main = (business_hours and office) or false
+`, true},
+		// The right operand is not evaluated, so the error doesn't happen
+		{`main = false and request.data.missing > 1`, false},
+		{`main = true or request.data.missing > 1`, true},
+	}
+	for _, c := range cases {
+		p, err := Parse(c.src)
+		if err != nil {
+			t.Fatalf("%s: %v", c.src, err)
+		}
+		result, err := p.Eval(env)
+		if err != nil {
+			t.Fatalf("%s: %v", c.src, err)
+		}
+		if result.Allowed != c.allowed {
+			t.Fatalf("%s: expected %t", c.src, c.allowed)
+		}
+	}
+}
+
+func TestEval_Rules(t *testing.T) {
+	p, err := Parse(`
+a = 1 + 1
+b = a == 2
+unused = 3
+main = b
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules := strings.Join(p.Rules(), ","); rules != "a,b,unused,main" {
+		t.Fatalf("bad rules: %s", rules)
+	}
+
+	result, err := p.Eval(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed {
+		t.Fatal("expected the program to allow")
+	}
+	if result.Rules["a"] != float64(2) || result.Rules["b"] != true {
+		t.Fatalf("bad rule values: %#v", result.Rules)
+	}
+	if _, ok := result.Rules["unused"]; ok {
+		t.Fatal("expected unused rules not to be evaluated")
+	}
+}
+
+func TestEval_Errors(t *testing.T) {
+	env := map[string]interface{}{
+		"request": map[string]interface{}{
+			"path": "secret/foo",
+		},
+	}
+	cases := map[string]string{
+		"not a boolean":    `main = 1`,
+		"unknown ident":    `main = banana == 1`,
+		"null ordering":    `main = request.missing > 1`,
+		"mixed ordering":   `main = request.path > 1`,
+		"non-boolean and":  `main = true and 1`,
+		"bad regexp":       `main = request.path matches "("`,
+		"bad index":        `main = request[1] == null`,
+		"bad cidr":         `main = cidr_match("banana", "10.0.0.1")`,
+		"rule error":       "a = request.path - 1\nmain = a == 1",
+		"bad arithmetic":   `main = true + 1 == 2`,
+		"bad list index":   `main = [1][0.5] == 1`,
+		"index a boolean":  `main = true.foo == null`,
+		"bad contains":     `main = 1 contains 1`,
+		"bad not operand":  `main = not 1`,
+		"bad length value": `main = length(true) == 1`,
+	}
+	for name, src := range cases {
+		p, err := Parse(src)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := p.Eval(env); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+
+	p, err := Parse("a = request.path - 1\nb = a\nmain = b == 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.Eval(env)
+	if err == nil || !strings.HasPrefix(err.Error(), `rule "a": `) {
+		t.Fatalf("expected the error to be attributed to rule a, got %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnbalancedTemplatingCharacter = errors.New("unbalanced templating characters")
+	ErrNoEntityAttachedToToken       = errors.New("string contains entity template directives but no entity was provided")
+	ErrNoGroupsAttachedToToken       = errors.New("string contains groups template directives but no groups were provided")
+	ErrTemplateValueNotFound         = errors.New("no value could be found for one of the template directives")
+	ErrTemplateValueNotAllowedInPath = errors.New("value of one of the template directives contains characters not allowed in ACL paths")
+)
+
+// PopulateStringInput is the input of PopulateString
+type PopulateStringInput struct {
+	// ValidityCheckOnly only checks the syntax of the template directives,
+	// without resolving them
+	ValidityCheckOnly bool
+
+	// ACLPath rejects values containing glob or path separator characters,
+	// so that the values can't widen the ACL path they are placed in
+	ACLPath bool
+
+	String string
+	Entity *Entity
+	Groups []*Group
+}
+
+// PopulateString replaces the {{identity.*}} template directives of the
+// string with the values of the entity and its groups. It returns whether the
+// string contained template directives, and the resulting string. The
+// supported directives are:
+//
+//	identity.entity.id
+//	identity.entity.name
+//	identity.entity.metadata.<key>
+//	identity.entity.aliases.<mount accessor>.name
+//	identity.groups.ids.<group id>.name
+//	identity.groups.names.<group name>.id
+func PopulateString(p *PopulateStringInput) (bool, string, error) {
+	if p == nil {
+		return false, "", errors.New("nil input")
+	}
+
+	splitStr := strings.Split(p.String, "{{")
+	if len(splitStr) == 1 {
+		if strings.Contains(p.String, "}}") {
+			return false, "", ErrUnbalancedTemplatingCharacter
+		}
+		return false, p.String, nil
+	}
+	if strings.Contains(splitStr[0], "}}") {
+		return false, "", ErrUnbalancedTemplatingCharacter
+	}
+
+	var b strings.Builder
+	b.WriteString(splitStr[0])
+	for _, str := range splitStr[1:] {
+		splitPiece := strings.Split(str, "}}")
+		if len(splitPiece) != 2 {
+			return false, "", ErrUnbalancedTemplatingCharacter
+		}
+
+		value, err := p.performTemplating(strings.TrimSpace(splitPiece[0]))
+		if err != nil {
+			return false, "", err
+		}
+		if p.ACLPath && strings.ContainsAny(value, "*+/") {
+			return false, "", ErrTemplateValueNotAllowedInPath
+		}
+		b.WriteString(value)
+		b.WriteString(splitPiece[1])
+	}
+
+	return true, b.String(), nil
+}
+
+func (p *PopulateStringInput) performTemplating(directive string) (string, error) {
+	switch {
+	case strings.HasPrefix(directive, "identity.entity."):
+		return p.performEntityTemplating(strings.TrimPrefix(directive, "identity.entity."))
+	case strings.HasPrefix(directive, "identity.groups."):
+		return p.performGroupsTemplating(strings.TrimPrefix(directive, "identity.groups."))
+	}
+	return "", fmt.Errorf("invalid template directive %q", directive)
+}
+
+func (p *PopulateStringInput) performEntityTemplating(trimmed string) (string, error) {
+	var value string
+	var found bool
+
+	switch {
+	case trimmed == "id":
+		if p.Entity != nil {
+			value, found = p.Entity.ID, true
+		}
+
+	case trimmed == "name":
+		if p.Entity != nil {
+			value, found = p.Entity.Name, p.Entity.Name != ""
+		}
+
+	case strings.HasPrefix(trimmed, "metadata."):
+		key := strings.TrimPrefix(trimmed, "metadata.")
+		if key == "" {
+			return "", errors.New("missing metadata key in template directive")
+		}
+		if p.Entity != nil {
+			value, found = p.Entity.Metadata[key]
+		}
+
+	case strings.HasPrefix(trimmed, "aliases."):
+		split := strings.SplitN(strings.TrimPrefix(trimmed, "aliases."), ".", 2)
+		if len(split) != 2 || split[0] == "" || split[1] != "name" {
+			return "", fmt.Errorf("invalid alias template directive %q", trimmed)
+		}
+		if p.Entity != nil {
+			for _, alias := range p.Entity.Aliases {
+				if alias.MountAccessor == split[0] {
+					value, found = alias.Name, true
+					break
+				}
+			}
+		}
+
+	default:
+		return "", fmt.Errorf("invalid entity template directive %q", trimmed)
+	}
+
+	switch {
+	case p.ValidityCheckOnly:
+		return "", nil
+	case p.Entity == nil:
+		return "", ErrNoEntityAttachedToToken
+	case !found:
+		return "", ErrTemplateValueNotFound
+	}
+	return value, nil
+}
+
+func (p *PopulateStringInput) performGroupsTemplating(trimmed string) (string, error) {
+	var byID bool
+	switch {
+	case strings.HasPrefix(trimmed, "ids."):
+		byID = true
+		trimmed = strings.TrimPrefix(trimmed, "ids.")
+	case strings.HasPrefix(trimmed, "names."):
+		trimmed = strings.TrimPrefix(trimmed, "names.")
+	default:
+		return "", fmt.Errorf("invalid groups template directive %q", trimmed)
+	}
+
+	// Group names can contain dots, so the attribute is split off the end
+	idx := strings.LastIndex(trimmed, ".")
+	if idx <= 0 {
+		return "", fmt.Errorf("invalid groups template directive %q", trimmed)
+	}
+	key, attr := trimmed[:idx], trimmed[idx+1:]
+	if (byID && attr != "name") || (!byID && attr != "id") {
+		return "", fmt.Errorf("invalid groups template directive %q", trimmed)
+	}
+
+	if p.ValidityCheckOnly {
+		return "", nil
+	}
+	if len(p.Groups) == 0 {
+		return "", ErrNoGroupsAttachedToToken
+	}
+
+	for _, group := range p.Groups {
+		switch {
+		case byID && group.ID == key:
+			return group.Name, nil
+		case !byID && group.Name == key:
+			return group.ID, nil
+		}
+	}
+	return "", ErrTemplateValueNotFound
+}
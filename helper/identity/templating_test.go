@@ -0,0 +1,189 @@
+package identity
+
+import (
+	"testing"
+)
+
+func TestPopulate_Basic(t *testing.T) {
+	var tests = []struct {
+		name              string
+		input             string
+		output            string
+		err               error
+		templated         bool
+		validityCheckOnly bool
+		aclPath           bool
+		entityName        string
+		metadata          map[string]string
+		aliasAccessor     string
+		aliasName         string
+		groupName         string
+		invalid           bool
+	}{
+		{
+			name:   "no_templating",
+			input:  "path foobar",
+			output: "path foobar",
+		},
+		{
+			name:  "only_closing",
+			input: "path foobar}}",
+			err:   ErrUnbalancedTemplatingCharacter,
+		},
+		{
+			name:  "closing_in_front",
+			input: "path }} {{foobar}}",
+			err:   ErrUnbalancedTemplatingCharacter,
+		},
+		{
+			name:  "closing_in_back",
+			input: "path {{foobar}} }}",
+			err:   ErrUnbalancedTemplatingCharacter,
+		},
+		{
+			name:    "unknown_directive",
+			input:   "path {{identity.entity.banana}}",
+			invalid: true,
+		},
+		{
+			name:              "validity_check_only",
+			input:             "path/{{identity.entity.name}}/{{identity.groups.names.foo.id}}",
+			templated:         true,
+			validityCheckOnly: true,
+			output:            "path//",
+		},
+		{
+			name:       "entity_name",
+			input:      "secret/users/{{identity.entity.name}}/*",
+			templated:  true,
+			entityName: "alice",
+			output:     "secret/users/alice/*",
+		},
+		{
+			name:      "entity_name_missing",
+			input:     "secret/users/{{identity.entity.name}}/*",
+			templated: true,
+			err:       ErrTemplateValueNotFound,
+		},
+		{
+			name:      "entity_metadata",
+			input:     "secret/teams/{{ identity.entity.metadata.team }}",
+			templated: true,
+			metadata:  map[string]string{"team": "ops"},
+			output:    "secret/teams/ops",
+		},
+		{
+			name:      "entity_metadata_glob_in_acl_path",
+			input:     "secret/teams/{{identity.entity.metadata.team}}",
+			templated: true,
+			aclPath:   true,
+			metadata:  map[string]string{"team": "ops*"},
+			err:       ErrTemplateValueNotAllowedInPath,
+		},
+		{
+			name:      "entity_metadata_separator_in_acl_path",
+			input:     "secret/teams/{{identity.entity.metadata.team}}/*",
+			templated: true,
+			aclPath:   true,
+			metadata:  map[string]string{"team": "ops/../dev"},
+			err:       ErrTemplateValueNotAllowedInPath,
+		},
+		{
+			name:      "entity_metadata_separator",
+			input:     "secret/teams/{{identity.entity.metadata.team}}",
+			templated: true,
+			metadata:  map[string]string{"team": "ops/dev"},
+			output:    "secret/teams/ops/dev",
+		},
+		{
+			name:      "entity_metadata_missing",
+			input:     "secret/teams/{{identity.entity.metadata.team}}",
+			templated: true,
+			err:       ErrTemplateValueNotFound,
+		},
+		{
+			name:          "alias_name",
+			input:         "secret/{{identity.entity.aliases.auth_userpass_1234.name}}",
+			templated:     true,
+			aliasAccessor: "auth_userpass_1234",
+			aliasName:     "bob",
+			output:        "secret/bob",
+		},
+		{
+			name:      "group_name_to_id",
+			input:     "secret/groups/{{identity.groups.names.ops.team.id}}",
+			templated: true,
+			groupName: "ops.team",
+			output:    "secret/groups/groupid",
+		},
+		{
+			name:      "group_id_to_name",
+			input:     "secret/groups/{{identity.groups.ids.groupid.name}}",
+			templated: true,
+			groupName: "ops",
+			output:    "secret/groups/ops",
+		},
+		{
+			name:  "no_groups",
+			input: "secret/groups/{{identity.groups.ids.groupid.name}}",
+			err:   ErrNoGroupsAttachedToToken,
+		},
+	}
+
+	for _, test := range tests {
+		entity := &Entity{
+			ID:       "entityid",
+			Name:     test.entityName,
+			Metadata: test.metadata,
+		}
+		if test.aliasAccessor != "" {
+			entity.Aliases = []*Alias{
+				{
+					MountAccessor: test.aliasAccessor,
+					Name:          test.aliasName,
+				},
+			}
+		}
+		var groups []*Group
+		if test.groupName != "" {
+			groups = append(groups, &Group{
+				ID:   "groupid",
+				Name: test.groupName,
+			})
+		}
+
+		templated, out, err := PopulateString(&PopulateStringInput{
+			ValidityCheckOnly: test.validityCheckOnly,
+			ACLPath:           test.aclPath,
+			String:            test.input,
+			Entity:            entity,
+			Groups:            groups,
+		})
+		switch {
+		case test.invalid:
+			if err == nil {
+				t.Fatalf("%s: expected an error", test.name)
+			}
+			continue
+		case err != test.err:
+			t.Fatalf("%s: expected error %v, got %v", test.name, test.err, err)
+		case err != nil:
+			continue
+		}
+		if templated != test.templated {
+			t.Fatalf("%s: expected templated %t, got %t", test.name, test.templated, templated)
+		}
+		if out != test.output {
+			t.Fatalf("%s: expected %q, got %q", test.name, test.output, out)
+		}
+	}
+}
+
+func TestPopulate_NoEntity(t *testing.T) {
+	_, _, err := PopulateString(&PopulateStringInput{
+		String: "secret/{{identity.entity.id}}",
+	})
+	if err != ErrNoEntityAttachedToToken {
+		t.Fatalf("expected %v, got %v", ErrNoEntityAttachedToToken, err)
+	}
+}
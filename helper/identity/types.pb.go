@@ -68,6 +68,10 @@ type Group struct {
 	// the memberships on the external group --for which a corresponding alias
 	// will be set-- will be managed automatically.
 	Type string `sentinel:"" protobuf:"bytes,12,opt,name=type" json:"type,omitempty"`
+	// NamespaceID is the identifier of the namespace to which this group
+	// belongs to. Do not return this value over the API when reading the
+	// group.
+	NamespaceID string `sentinel:"" protobuf:"bytes,13,opt,name=namespace_id,json=namespaceID" json:"namespace_id,omitempty"`
 }
 
 func (m *Group) Reset()                    { *m = Group{} }
@@ -159,6 +163,13 @@ func (m *Group) GetType() string {
 	return ""
 }
 
+func (m *Group) GetNamespaceID() string {
+	if m != nil {
+		return m.NamespaceID
+	}
+	return ""
+}
+
 // Entity represents an entity that gets persisted and indexed.
 // Entity is fundamentally composed of zero or many aliases.
 type Entity struct {
@@ -200,6 +211,10 @@ type Entity struct {
 	// the entities belonging to a particular bucket during invalidation of the
 	// storage key.
 	BucketKeyHash string `sentinel:"" protobuf:"bytes,9,opt,name=bucket_key_hash,json=bucketKeyHash" json:"bucket_key_hash,omitempty"`
+	// NamespaceID is the identifier of the namespace to which this entity
+	// belongs to. Do not return this value over the API when reading the
+	// entity.
+	NamespaceID string `sentinel:"" protobuf:"bytes,11,opt,name=namespace_id,json=namespaceID" json:"namespace_id,omitempty"`
 }
 
 func (m *Entity) Reset()                    { *m = Entity{} }
@@ -270,6 +285,13 @@ func (m *Entity) GetBucketKeyHash() string {
 	return ""
 }
 
+func (m *Entity) GetNamespaceID() string {
+	if m != nil {
+		return m.NamespaceID
+	}
+	return ""
+}
+
 // Alias represents the alias that gets stored inside of the
 // entity object in storage and also represents in an in-memory index of an
 // alias object.
@@ -392,43 +414,44 @@ func init() {
 func init() { proto.RegisterFile("types.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 603 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x93, 0xdd, 0x6e, 0xd3, 0x30,
-	0x14, 0xc7, 0xd5, 0xa6, 0x9f, 0x27, 0x5d, 0x37, 0x2c, 0x84, 0x4c, 0xa5, 0x41, 0x37, 0x69, 0x28,
-	0x70, 0x91, 0x49, 0xe3, 0x86, 0x8d, 0x0b, 0x34, 0xc1, 0x80, 0x09, 0x21, 0xa1, 0x68, 0x5c, 0x47,
-	0x6e, 0xe2, 0xb5, 0xd6, 0x92, 0x38, 0x8a, 0x1d, 0x44, 0x5e, 0x87, 0x97, 0xe1, 0x69, 0x78, 0x07,
-	0xe4, 0xe3, 0xa6, 0x0d, 0x74, 0x7c, 0x4c, 0xdb, 0x9d, 0xf3, 0x3f, 0xc7, 0xc7, 0x27, 0xe7, 0xff,
-	0x3b, 0xe0, 0xea, 0x2a, 0xe7, 0xca, 0xcf, 0x0b, 0xa9, 0x25, 0x19, 0x88, 0x98, 0x67, 0x5a, 0xe8,
-	0x6a, 0xf2, 0x78, 0x2e, 0xe5, 0x3c, 0xe1, 0x87, 0xa8, 0xcf, 0xca, 0xcb, 0x43, 0x2d, 0x52, 0xae,
-	0x34, 0x4b, 0x73, 0x9b, 0xba, 0xff, 0xad, 0x03, 0xdd, 0x77, 0x85, 0x2c, 0x73, 0x32, 0x86, 0xb6,
-	0x88, 0x69, 0x6b, 0xda, 0xf2, 0x86, 0x41, 0x5b, 0xc4, 0x84, 0x40, 0x27, 0x63, 0x29, 0xa7, 0x6d,
-	0x54, 0xf0, 0x4c, 0x26, 0x30, 0xc8, 0x65, 0x22, 0x22, 0xc1, 0x15, 0x75, 0xa6, 0x8e, 0x37, 0x0c,
-	0x56, 0xdf, 0xc4, 0x83, 0x9d, 0x9c, 0x15, 0x3c, 0xd3, 0xe1, 0xdc, 0xd4, 0x0b, 0x45, 0xac, 0x68,
-	0x07, 0x73, 0xc6, 0x56, 0xc7, 0x67, 0xce, 0x63, 0x45, 0x9e, 0xc1, 0xbd, 0x94, 0xa7, 0x33, 0x5e,
-	0x84, 0xb6, 0x4b, 0x4c, 0xed, 0x62, 0xea, 0xb6, 0x0d, 0x9c, 0xa1, 0x6e, 0x72, 0x8f, 0x61, 0x90,
-	0x72, 0xcd, 0x62, 0xa6, 0x19, 0xed, 0x4d, 0x1d, 0xcf, 0x3d, 0xda, 0xf5, 0xeb, 0xbf, 0xf3, 0xb1,
-	0xa2, 0xff, 0x71, 0x19, 0x3f, 0xcb, 0x74, 0x51, 0x05, 0xab, 0x74, 0xf2, 0x0a, 0xb6, 0xa2, 0x82,
-	0x33, 0x2d, 0x64, 0x16, 0x9a, 0xdf, 0xa6, 0xfd, 0x69, 0xcb, 0x73, 0x8f, 0x26, 0xbe, 0x9d, 0x89,
-	0x5f, 0xcf, 0xc4, 0xbf, 0xa8, 0x67, 0x12, 0x8c, 0xea, 0x0b, 0x46, 0x22, 0x6f, 0x60, 0x27, 0x61,
-	0x4a, 0x87, 0x65, 0x1e, 0x33, 0xcd, 0x6d, 0x8d, 0xc1, 0x3f, 0x6b, 0x8c, 0xcd, 0x9d, 0xcf, 0x78,
-	0x05, 0xab, 0xec, 0xc1, 0x28, 0x95, 0xb1, 0xb8, 0xac, 0x42, 0x91, 0xc5, 0xfc, 0x2b, 0x1d, 0x4e,
-	0x5b, 0x5e, 0x27, 0x70, 0xad, 0x76, 0x6e, 0x24, 0xf2, 0x04, 0xb6, 0x67, 0x65, 0x74, 0xc5, 0x75,
-	0x78, 0xc5, 0xab, 0x70, 0xc1, 0xd4, 0x82, 0x02, 0x4e, 0x7d, 0xcb, 0xca, 0x1f, 0x78, 0xf5, 0x9e,
-	0xa9, 0x05, 0x39, 0x80, 0x2e, 0x4b, 0x04, 0x53, 0xd4, 0xc5, 0x2e, 0xb6, 0xd7, 0x93, 0x38, 0x35,
-	0x72, 0x60, 0xa3, 0xc6, 0x39, 0x43, 0x03, 0x1d, 0x59, 0xe7, 0xcc, 0x79, 0xf2, 0x12, 0xb6, 0x7e,
-	0x99, 0x13, 0xd9, 0x01, 0xe7, 0x8a, 0x57, 0x4b, 0xbf, 0xcd, 0x91, 0xdc, 0x87, 0xee, 0x17, 0x96,
-	0x94, 0xb5, 0xe3, 0xf6, 0xe3, 0xa4, 0xfd, 0xa2, 0xb5, 0xff, 0xdd, 0x81, 0x9e, 0xb5, 0x84, 0x3c,
-	0x85, 0x3e, 0x3e, 0xc2, 0x15, 0x6d, 0xa1, 0x1d, 0x1b, 0x4d, 0xd4, 0xf1, 0x25, 0x50, 0xed, 0x0d,
-	0xa0, 0x9c, 0x06, 0x50, 0x27, 0x0d, 0x7b, 0x3b, 0x58, 0xef, 0xd1, 0xba, 0x9e, 0x7d, 0xf2, 0xff,
-	0xfd, 0xed, 0xde, 0x81, 0xbf, 0xbd, 0x1b, 0xfb, 0x8b, 0x34, 0x17, 0x73, 0x1e, 0x37, 0x69, 0xee,
-	0xd7, 0x34, 0x9b, 0xc0, 0x9a, 0xe6, 0xe6, 0xfe, 0x0c, 0x7e, 0xdb, 0x9f, 0x6b, 0x20, 0x18, 0x5e,
-	0x03, 0xc1, 0xed, 0x9c, 0xfc, 0xe1, 0x40, 0x17, 0x6d, 0xda, 0x58, 0xf7, 0x3d, 0x18, 0x45, 0x2c,
-	0x93, 0x99, 0x88, 0x58, 0x12, 0xae, 0x7c, 0x73, 0x57, 0xda, 0x79, 0x4c, 0x76, 0x01, 0x52, 0x59,
-	0x66, 0x3a, 0x44, 0xba, 0xac, 0x8d, 0x43, 0x54, 0x2e, 0xaa, 0x9c, 0x93, 0x03, 0x18, 0xdb, 0x30,
-	0x8b, 0x22, 0xae, 0x94, 0x2c, 0x68, 0xc7, 0xf6, 0x8f, 0xea, 0xe9, 0x52, 0x5c, 0x57, 0xc9, 0x99,
-	0x5e, 0xa0, 0x67, 0x75, 0x95, 0x4f, 0x4c, 0x2f, 0xfe, 0xbe, 0xf0, 0xd8, 0xfa, 0x1f, 0x81, 0xa8,
-	0x01, 0xeb, 0x37, 0x00, 0xdb, 0x80, 0x64, 0x70, 0x07, 0x90, 0x0c, 0x6f, 0x0c, 0xc9, 0x31, 0x3c,
-	0x5c, 0x42, 0x72, 0x59, 0xc8, 0x34, 0x6c, 0x4e, 0x5a, 0x51, 0x40, 0x12, 0x1e, 0xd8, 0x84, 0xb7,
-	0x85, 0x4c, 0x5f, 0xaf, 0x87, 0xae, 0x6e, 0xe5, 0xf7, 0xac, 0x87, 0xbd, 0x3d, 0xff, 0x19, 0x00,
-	0x00, 0xff, 0xff, 0x8e, 0x4a, 0xc5, 0xdb, 0x1f, 0x06, 0x00, 0x00,
+	// 621 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x94, 0x5d, 0x6f, 0xd3, 0x3c,
+	0x14, 0xc7, 0xd5, 0xa6, 0xe9, 0xcb, 0x49, 0xdb, 0xed, 0xb1, 0x1e, 0x21, 0x53, 0x69, 0xd0, 0x4d,
+	0x1a, 0x2a, 0x5c, 0x64, 0xd2, 0xb8, 0x61, 0xe3, 0x02, 0x4d, 0x6c, 0x40, 0x85, 0x90, 0x50, 0x35,
+	0xae, 0x23, 0x37, 0xf6, 0x5a, 0x6b, 0x4d, 0x1c, 0xc5, 0x2e, 0x22, 0x1f, 0x90, 0x2f, 0x83, 0xc4,
+	0x77, 0x40, 0x3e, 0x6e, 0xda, 0xb0, 0x8d, 0x97, 0x89, 0xde, 0x39, 0xff, 0x73, 0x7c, 0x72, 0x7c,
+	0xfe, 0x3f, 0x1b, 0x02, 0x53, 0x64, 0x42, 0x87, 0x59, 0xae, 0x8c, 0x22, 0x6d, 0xc9, 0x45, 0x6a,
+	0xa4, 0x29, 0x06, 0x8f, 0x67, 0x4a, 0xcd, 0x16, 0xe2, 0x08, 0xf5, 0xe9, 0xf2, 0xea, 0xc8, 0xc8,
+	0x44, 0x68, 0xc3, 0x92, 0xcc, 0xa5, 0x1e, 0x7c, 0x6d, 0x80, 0xff, 0x36, 0x57, 0xcb, 0x8c, 0xf4,
+	0xa1, 0x2e, 0x39, 0xad, 0x0d, 0x6b, 0xa3, 0xce, 0xa4, 0x2e, 0x39, 0x21, 0xd0, 0x48, 0x59, 0x22,
+	0x68, 0x1d, 0x15, 0x5c, 0x93, 0x01, 0xb4, 0x33, 0xb5, 0x90, 0xb1, 0x14, 0x9a, 0x7a, 0x43, 0x6f,
+	0xd4, 0x99, 0xac, 0xbf, 0xc9, 0x08, 0x76, 0x33, 0x96, 0x8b, 0xd4, 0x44, 0x33, 0x5b, 0x2f, 0x92,
+	0x5c, 0xd3, 0x06, 0xe6, 0xf4, 0x9d, 0x8e, 0xbf, 0x19, 0x73, 0x4d, 0x9e, 0xc1, 0x7f, 0x89, 0x48,
+	0xa6, 0x22, 0x8f, 0x5c, 0x97, 0x98, 0xea, 0x63, 0xea, 0x8e, 0x0b, 0x5c, 0xa0, 0x6e, 0x73, 0x4f,
+	0xa0, 0x9d, 0x08, 0xc3, 0x38, 0x33, 0x8c, 0x36, 0x87, 0xde, 0x28, 0x38, 0xde, 0x0b, 0xcb, 0xd3,
+	0x85, 0x58, 0x31, 0xfc, 0xb0, 0x8a, 0x5f, 0xa4, 0x26, 0x2f, 0x26, 0xeb, 0x74, 0xf2, 0x0a, 0x7a,
+	0x71, 0x2e, 0x98, 0x91, 0x2a, 0x8d, 0xec, 0xb1, 0x69, 0x6b, 0x58, 0x1b, 0x05, 0xc7, 0x83, 0xd0,
+	0xcd, 0x24, 0x2c, 0x67, 0x12, 0x5e, 0x96, 0x33, 0x99, 0x74, 0xcb, 0x0d, 0x56, 0x22, 0xe7, 0xb0,
+	0xbb, 0x60, 0xda, 0x44, 0xcb, 0x8c, 0x33, 0x23, 0x5c, 0x8d, 0xf6, 0x1f, 0x6b, 0xf4, 0xed, 0x9e,
+	0x4f, 0xb8, 0x05, 0xab, 0xec, 0x43, 0x37, 0x51, 0x5c, 0x5e, 0x15, 0x91, 0x4c, 0xb9, 0xf8, 0x42,
+	0x3b, 0xc3, 0xda, 0xa8, 0x31, 0x09, 0x9c, 0x36, 0xb6, 0x12, 0x79, 0x02, 0x3b, 0xd3, 0x65, 0x7c,
+	0x2d, 0x4c, 0x74, 0x2d, 0x8a, 0x68, 0xce, 0xf4, 0x9c, 0x02, 0x4e, 0xbd, 0xe7, 0xe4, 0xf7, 0xa2,
+	0x78, 0xc7, 0xf4, 0x9c, 0x1c, 0x82, 0xcf, 0x16, 0x92, 0x69, 0x1a, 0x60, 0x17, 0x3b, 0x9b, 0x49,
+	0x9c, 0x59, 0x79, 0xe2, 0xa2, 0xd6, 0x39, 0x4b, 0x03, 0xed, 0x3a, 0xe7, 0xec, 0xda, 0x76, 0x61,
+	0x1d, 0xd4, 0x19, 0x8b, 0x45, 0x24, 0x39, 0xed, 0x61, 0x2c, 0x58, 0x6b, 0xe3, 0xf3, 0xc1, 0x4b,
+	0xe8, 0xfd, 0x34, 0x4a, 0xb2, 0x0b, 0xde, 0xb5, 0x28, 0x56, 0x48, 0xd8, 0x25, 0xf9, 0x1f, 0xfc,
+	0xcf, 0x6c, 0xb1, 0x2c, 0xa1, 0x70, 0x1f, 0xa7, 0xf5, 0x17, 0xb5, 0x83, 0x6f, 0x1e, 0x34, 0x9d,
+	0x6b, 0xe4, 0x29, 0xb4, 0xb0, 0x0f, 0xa1, 0x69, 0x6d, 0xe8, 0xdd, 0xd5, 0x67, 0x19, 0x5f, 0x31,
+	0x57, 0xbf, 0xc5, 0x9c, 0x57, 0x61, 0xee, 0xb4, 0x42, 0x40, 0x03, 0xeb, 0x3d, 0xda, 0xd4, 0x73,
+	0xbf, 0xfc, 0x7b, 0x04, 0xfc, 0x2d, 0x20, 0xd0, 0xbc, 0x37, 0x02, 0x08, 0x7c, 0x3e, 0x13, 0xbc,
+	0x0a, 0x7c, 0xab, 0x04, 0xde, 0x06, 0x36, 0xc0, 0x57, 0xaf, 0x58, 0xfb, 0xc6, 0x15, 0xbb, 0x83,
+	0x93, 0xce, 0x5d, 0x9c, 0xdc, 0x34, 0x3b, 0xd8, 0xb2, 0xd9, 0xdf, 0x3d, 0xf0, 0xd1, 0xc9, 0x5b,
+	0x8f, 0xc6, 0x3e, 0x74, 0x63, 0x96, 0xaa, 0x54, 0xc6, 0x6c, 0x11, 0xad, 0xad, 0x0d, 0xd6, 0xda,
+	0x98, 0x93, 0x3d, 0x80, 0x44, 0x2d, 0x53, 0x13, 0x21, 0xa3, 0xce, 0xe9, 0x0e, 0x2a, 0x97, 0x16,
+	0xd4, 0x43, 0xe8, 0xbb, 0x30, 0x8b, 0x63, 0xa1, 0xb5, 0xca, 0x69, 0xc3, 0x1d, 0x11, 0xd5, 0xb3,
+	0x95, 0xb8, 0xa9, 0x92, 0x31, 0x33, 0xa7, 0x7e, 0xa5, 0xca, 0x47, 0x66, 0xe6, 0xbf, 0x7f, 0x36,
+	0xb0, 0xf5, 0x5f, 0x32, 0x53, 0x32, 0xd8, 0xaa, 0x30, 0x78, 0x8b, 0xa3, 0xf6, 0x16, 0x38, 0xea,
+	0xdc, 0x9b, 0xa3, 0x13, 0x78, 0xb8, 0xe2, 0xe8, 0x2a, 0x57, 0x49, 0x54, 0x9d, 0xb4, 0xa6, 0x80,
+	0xb0, 0x3c, 0x70, 0x09, 0x6f, 0x72, 0x95, 0xbc, 0xde, 0x0c, 0x5d, 0xff, 0x93, 0xdf, 0xd3, 0x26,
+	0xf6, 0xf6, 0xfc, 0xc7, 0x00, 0x4f, 0x73, 0x6a, 0xa0, 0x65, 0x06, 0x00, 0x00,
 }
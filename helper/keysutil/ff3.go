@@ -0,0 +1,199 @@
+package keysutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// FF3TweakSize is the size in bytes of an FF3-1 tweak
+	FF3TweakSize = 7
+
+	// ff3MinDomain is the minimum size of the domain radix^minlen required
+	// by NIST SP 800-38G Revision 1
+	ff3MinDomain = 1000000
+
+	ff3Rounds = 8
+)
+
+// ff3Cipher implements the FF3-1 format-preserving encryption mode of NIST
+// SP 800-38G Revision 1 on top of AES. Values are slices of numerals, each
+// smaller than the radix.
+type ff3Cipher struct {
+	block  cipher.Block
+	radix  *big.Int
+	minLen int
+	maxLen int
+}
+
+func newFF3Cipher(key []byte, radix int) (*ff3Cipher, error) {
+	if radix < 2 || radix > 1<<16 {
+		return nil, fmt.Errorf("radix must be between 2 and %d", 1<<16)
+	}
+
+	// The key is used byte-reversed
+	revKey := make([]byte, len(key))
+	for i := range key {
+		revKey[len(key)-1-i] = key[i]
+	}
+	block, err := aes.NewCipher(revKey)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ff3Cipher{
+		block: block,
+		radix: big.NewInt(int64(radix)),
+	}
+
+	// minLen is the smallest length with radix^minLen >= 1,000,000, and
+	// maxLen is 2*floor(log_radix(2^96))
+	for domain := 1; domain < ff3MinDomain; domain *= radix {
+		c.minLen++
+	}
+	if c.minLen < 2 {
+		c.minLen = 2
+	}
+	limit := new(big.Int).Lsh(big.NewInt(1), 96)
+	for domain := new(big.Int).Set(c.radix); domain.Cmp(limit) <= 0; domain.Mul(domain, c.radix) {
+		c.maxLen++
+	}
+	c.maxLen *= 2
+
+	return c, nil
+}
+
+// Encrypt encrypts the numerals with the given 7 byte tweak
+func (c *ff3Cipher) Encrypt(tweak []byte, x []uint16) ([]uint16, error) {
+	tl, tr, err := ff3SplitTweak(tweak)
+	if err != nil {
+		return nil, err
+	}
+	return c.crypt(tl, tr, x, true)
+}
+
+// Decrypt decrypts the numerals with the given 7 byte tweak
+func (c *ff3Cipher) Decrypt(tweak []byte, x []uint16) ([]uint16, error) {
+	tl, tr, err := ff3SplitTweak(tweak)
+	if err != nil {
+		return nil, err
+	}
+	return c.crypt(tl, tr, x, false)
+}
+
+// ff3SplitTweak splits the 56 bit FF3-1 tweak into its 32 bit left and right
+// halves
+func ff3SplitTweak(tweak []byte) ([]byte, []byte, error) {
+	if len(tweak) != FF3TweakSize {
+		return nil, nil, fmt.Errorf("tweak must be %d bytes long", FF3TweakSize)
+	}
+	tl := []byte{tweak[0], tweak[1], tweak[2], tweak[3] & 0xf0}
+	tr := []byte{tweak[4], tweak[5], tweak[6], (tweak[3] & 0x0f) << 4}
+	return tl, tr, nil
+}
+
+func (c *ff3Cipher) crypt(tl, tr []byte, x []uint16, encrypt bool) ([]uint16, error) {
+	n := len(x)
+	if n < c.minLen || n > c.maxLen {
+		return nil, fmt.Errorf("value must be between %d and %d characters long for an alphabet of %d characters", c.minLen, c.maxLen, c.radix.Int64())
+	}
+	radix := int(c.radix.Int64())
+	for _, v := range x {
+		if int(v) >= radix {
+			return nil, fmt.Errorf("numeral %d is out of range for radix %d", v, radix)
+		}
+	}
+
+	u := (n + 1) / 2
+	v := n - u
+	a := append([]uint16(nil), x[:u]...)
+	b := append([]uint16(nil), x[u:]...)
+
+	modU := new(big.Int).Exp(c.radix, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(c.radix, big.NewInt(int64(v)), nil)
+
+	for r := 0; r < ff3Rounds; r++ {
+		i := r
+		if !encrypt {
+			i = ff3Rounds - 1 - r
+		}
+
+		m, mod, w := u, modU, tr
+		if i%2 == 1 {
+			m, mod, w = v, modV, tl
+		}
+
+		if encrypt {
+			y, err := c.round(w, i, b)
+			if err != nil {
+				return nil, err
+			}
+			y.Add(y, c.num(a))
+			y.Mod(y, mod)
+			a, b = b, c.str(y, m)
+		} else {
+			y, err := c.round(w, i, a)
+			if err != nil {
+				return nil, err
+			}
+			y.Sub(c.num(b), y)
+			y.Mod(y, mod)
+			a, b = c.str(y, m), a
+		}
+	}
+
+	return append(a, b...), nil
+}
+
+// round computes the output of the round function for round i on the given
+// half of the value
+func (c *ff3Cipher) round(w []byte, i int, half []uint16) (*big.Int, error) {
+	var p [aes.BlockSize]byte
+	copy(p[:4], w)
+	p[3] ^= byte(i)
+
+	numBytes := c.num(half).Bytes()
+	if len(numBytes) > 12 {
+		return nil, fmt.Errorf("value is too long")
+	}
+	copy(p[aes.BlockSize-len(numBytes):], numBytes)
+
+	// S = REVB(CIPH(REVB(P)))
+	reverseBytes(p[:])
+	c.block.Encrypt(p[:], p[:])
+	reverseBytes(p[:])
+
+	return new(big.Int).SetBytes(p[:]), nil
+}
+
+// num returns NUM_radix(REV(x)), that is the numerals are read with the least
+// significant one first
+func (c *ff3Cipher) num(x []uint16) *big.Int {
+	ret := new(big.Int)
+	for i := len(x) - 1; i >= 0; i-- {
+		ret.Mul(ret, c.radix)
+		ret.Add(ret, big.NewInt(int64(x[i])))
+	}
+	return ret
+}
+
+// str returns REV(STR^m_radix(y)), the m numerals of y with the least
+// significant one first
+func (c *ff3Cipher) str(y *big.Int, m int) []uint16 {
+	ret := make([]uint16, m)
+	rem := new(big.Int)
+	y = new(big.Int).Set(y)
+	for i := 0; i < m; i++ {
+		y.QuoRem(y, c.radix, rem)
+		ret[i] = uint16(rem.Int64())
+	}
+	return ret
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
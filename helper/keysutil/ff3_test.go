@@ -0,0 +1,130 @@
+package keysutil
+
+import (
+	"context"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func testFF3Numerals(s string) []uint16 {
+	ret := make([]uint16, len(s))
+	for i, r := range s {
+		ret[i] = uint16(r - '0')
+	}
+	return ret
+}
+
+func TestFF3_Vectors(t *testing.T) {
+	cases := []struct {
+		key, tweak, pt, ct string
+		ff3_1              bool
+	}{
+		// NIST FF3 sample, using the original 64 bit tweak
+		{"EF4359D8D580AA4F7F036D6F04FC6A94", "D8E7920AFA330A73", "890121234567890000", "750918814058654607", false},
+		{"2DE79D232DF5585D68CE47882AE256D6", "CBD09280979564", "3992520240", "8901801106", true},
+	}
+	for _, tc := range cases {
+		key, _ := hex.DecodeString(tc.key)
+		tweak, _ := hex.DecodeString(tc.tweak)
+		c, err := newFF3Cipher(key, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []uint16
+		if tc.ff3_1 {
+			out, err = c.Encrypt(tweak, testFF3Numerals(tc.pt))
+		} else {
+			out, err = c.crypt(tweak[:4], tweak[4:], testFF3Numerals(tc.pt), true)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, testFF3Numerals(tc.ct)) {
+			t.Fatalf("bad: %v expected %s", out, tc.ct)
+		}
+
+		if tc.ff3_1 {
+			out, err = c.Decrypt(tweak, out)
+		} else {
+			out, err = c.crypt(tweak[:4], tweak[4:], out, false)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, testFF3Numerals(tc.pt)) {
+			t.Fatalf("bad: %v expected %s", out, tc.pt)
+		}
+	}
+}
+
+func TestFF3_Limits(t *testing.T) {
+	key := make([]byte, 32)
+	tweak := make([]byte, FF3TweakSize)
+
+	c, err := newFF3Cipher(key, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.minLen != 6 || c.maxLen != 56 {
+		t.Fatalf("bad limits for radix 10: %d, %d", c.minLen, c.maxLen)
+	}
+	if _, err := c.Encrypt(tweak, testFF3Numerals("12345")); err == nil {
+		t.Fatal("expected error for a value below the minimum length")
+	}
+	if _, err := c.Encrypt(tweak, []uint16{1, 2, 3, 4, 5, 10}); err == nil {
+		t.Fatal("expected error for a numeral out of range")
+	}
+	if _, err := c.Encrypt(tweak[:6], testFF3Numerals("123456")); err == nil {
+		t.Fatal("expected error for a short tweak")
+	}
+	if _, err := newFF3Cipher(key, 1); err == nil {
+		t.Fatal("expected error for an invalid radix")
+	}
+}
+
+func TestPolicy_FPE(t *testing.T) {
+	p := &Policy{
+		Name: "test",
+		Type: KeyType_AES256_FF3_1,
+	}
+	if err := p.Rotate(context.Background(), &logical.InmemStorage{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tweak := []byte("1234567")
+	input := testFF3Numerals("4111111111111111")
+	out, err := p.EncryptFPE(0, tweak, 10, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != len(input) || reflect.DeepEqual(out, input) {
+		t.Fatalf("bad: %v", out)
+	}
+
+	// The same input and tweak always encrypt to the same value
+	again, err := p.EncryptFPE(1, tweak, 10, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, again) {
+		t.Fatalf("expected %v, got %v", out, again)
+	}
+
+	dec, err := p.DecryptFPE(0, tweak, 10, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dec, input) {
+		t.Fatalf("expected %v, got %v", input, dec)
+	}
+
+	if _, err := p.EncryptFPE(2, tweak, 10, input); err == nil {
+		t.Fatal("expected error for a nonexistent version")
+	}
+	if _, err := p.Encrypt(0, nil, nil, "Zm9v"); err == nil {
+		t.Fatal("expected error using an FPE key for regular encryption")
+	}
+}
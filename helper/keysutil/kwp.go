@@ -0,0 +1,100 @@
+package keysutil
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// kwpIV is the alternative initial value of RFC 5649
+var kwpIV = []byte{0xa6, 0x59, 0x59, 0xa6}
+
+// WrapKeyKWP wraps the key with the AES key kek using AES Key Wrap with
+// Padding as defined in RFC 5649.
+func WrapKeyKWP(kek, key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errors.New("key to wrap is empty")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	padded := make([]byte, (len(key)+7)/8*8)
+	copy(padded, key)
+
+	var a [8]byte
+	copy(a[:4], kwpIV)
+	binary.BigEndian.PutUint32(a[4:], uint32(len(key)))
+
+	if len(padded) == 8 {
+		out := make([]byte, aes.BlockSize)
+		copy(out, a[:])
+		copy(out[8:], padded)
+		block.Encrypt(out, out)
+		return out, nil
+	}
+
+	n := len(padded) / 8
+	r := padded
+	var b [aes.BlockSize]byte
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			copy(b[:8], a[:])
+			copy(b[8:], r[i*8:(i+1)*8])
+			block.Encrypt(b[:], b[:])
+			copy(a[:], b[:8])
+			binary.BigEndian.PutUint64(a[:], binary.BigEndian.Uint64(a[:])^uint64(n*j+i+1))
+			copy(r[i*8:], b[8:])
+		}
+	}
+
+	return append(a[:], r...), nil
+}
+
+// UnwrapKeyKWP unwraps a key wrapped with WrapKeyKWP
+func UnwrapKeyKWP(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 16 || len(wrapped)%8 != 0 {
+		return nil, errors.New("invalid wrapped key length")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	var a [8]byte
+	var r []byte
+	if len(wrapped) == aes.BlockSize {
+		out := make([]byte, aes.BlockSize)
+		block.Decrypt(out, wrapped)
+		copy(a[:], out[:8])
+		r = out[8:]
+	} else {
+		n := len(wrapped)/8 - 1
+		copy(a[:], wrapped[:8])
+		r = make([]byte, n*8)
+		copy(r, wrapped[8:])
+		var b [aes.BlockSize]byte
+		for j := 5; j >= 0; j-- {
+			for i := n - 1; i >= 0; i-- {
+				binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(a[:])^uint64(n*j+i+1))
+				copy(b[8:], r[i*8:(i+1)*8])
+				block.Decrypt(b[:], b[:])
+				copy(a[:], b[:8])
+				copy(r[i*8:], b[8:])
+			}
+		}
+	}
+
+	// Check the integrity value and the padding
+	mli := int(binary.BigEndian.Uint32(a[4:]))
+	valid := subtle.ConstantTimeCompare(a[:4], kwpIV) == 1
+	valid = valid && mli > len(r)-8 && mli <= len(r)
+	if !valid || !bytes.Equal(r[mli:], make([]byte, len(r)-mli)) {
+		return nil, errors.New("failed to unwrap key: integrity check failed")
+	}
+
+	return r[:mli], nil
+}
@@ -0,0 +1,43 @@
+package keysutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestKWP_Vectors(t *testing.T) {
+	// Test vectors from RFC 5649
+	kek, _ := hex.DecodeString("5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8")
+	cases := []struct {
+		key, wrapped string
+	}{
+		{"c37b7e6492584340bed12207808941155068f738", "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a"},
+		{"466f7250617369", "afbeb0f07dfbf5419200f2ccb50bb24f"},
+	}
+	for _, tc := range cases {
+		key, _ := hex.DecodeString(tc.key)
+		expected, _ := hex.DecodeString(tc.wrapped)
+
+		wrapped, err := WrapKeyKWP(kek, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(wrapped, expected) {
+			t.Fatalf("expected %x, got %x", expected, wrapped)
+		}
+
+		unwrapped, err := UnwrapKeyKWP(kek, wrapped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(unwrapped, key) {
+			t.Fatalf("expected %x, got %x", key, unwrapped)
+		}
+
+		wrapped[len(wrapped)-1] ^= 1
+		if _, err := UnwrapKeyKWP(kek, wrapped); err == nil {
+			t.Fatal("expected error unwrapping a modified key")
+		}
+	}
+}
@@ -47,6 +47,9 @@ type PolicyRequest struct {
 
 	// Whether to allow plaintext backup
 	AllowPlaintextBackup bool
+
+	// Whether to allow rotation of an imported key
+	AllowImportedKeyRotation bool
 }
 
 type LockManager struct {
@@ -292,6 +295,87 @@ func (lm *LockManager) RestorePolicy(ctx context.Context, storage logical.Storag
 	return nil
 }
 
+// ImportPolicy acquires an exclusive lock on the policy name and creates a new
+// policy with the given key material as its first version.
+func (lm *LockManager) ImportPolicy(ctx context.Context, req PolicyRequest, key []byte) error {
+	if err := req.validateKeyType(); err != nil {
+		return err
+	}
+
+	lockType := exclusive
+	lock := lm.policyLock(req.Name, lockType)
+	defer lm.UnlockPolicy(lock, lockType)
+
+	// If the policy is in cache or in storage, error out
+	if lm.CacheActive() {
+		lm.cacheMutex.RLock()
+		p := lm.cache[req.Name]
+		lm.cacheMutex.RUnlock()
+		if p != nil {
+			return fmt.Errorf("policy %q already exists", req.Name)
+		}
+	}
+	p, err := lm.getStoredPolicy(ctx, req.Storage, req.Name)
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		return fmt.Errorf("policy %q already exists", req.Name)
+	}
+
+	p = &Policy{
+		Name:                     req.Name,
+		Type:                     req.KeyType,
+		Derived:                  req.Derived,
+		Exportable:               req.Exportable,
+		AllowPlaintextBackup:     req.AllowPlaintextBackup,
+		AllowImportedKeyRotation: req.AllowImportedKeyRotation,
+		versionPrefixCache:       &sync.Map{},
+	}
+	if req.Derived {
+		p.KDF = Kdf_hkdf_sha256
+	}
+
+	if err := p.Import(ctx, req.Storage, key); err != nil {
+		return err
+	}
+
+	lm.UpdateCache(req.Name, p)
+
+	return nil
+}
+
+// validateKeyType checks that the options of the request are supported by
+// the requested key type
+func (req PolicyRequest) validateKeyType() error {
+	switch req.KeyType {
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+		if req.Convergent && !req.Derived {
+			return fmt.Errorf("convergent encryption requires derivation to be enabled")
+		}
+
+	case KeyType_ECDSA_P256:
+		if req.Derived || req.Convergent {
+			return fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	case KeyType_ED25519:
+		if req.Convergent {
+			return fmt.Errorf("convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	case KeyType_RSA2048, KeyType_RSA4096, KeyType_AES256_FF3_1:
+		if req.Derived || req.Convergent {
+			return fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	default:
+		return fmt.Errorf("unsupported key type %v", req.KeyType)
+	}
+
+	return nil
+}
+
 func (lm *LockManager) BackupPolicy(ctx context.Context, storage logical.Storage, name string) (string, error) {
 	p, lock, err := lm.GetPolicyExclusive(ctx, storage, name)
 	if lock != nil {
@@ -354,34 +438,9 @@ func (lm *LockManager) getPolicyCommon(ctx context.Context, req PolicyRequest, l
 			return nil, nil, false, errNeedExclusiveLock
 		}
 
-		switch req.KeyType {
-		case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
-			if req.Convergent && !req.Derived {
-				lm.UnlockPolicy(lock, lockType)
-				return nil, nil, false, fmt.Errorf("convergent encryption requires derivation to be enabled")
-			}
-
-		case KeyType_ECDSA_P256:
-			if req.Derived || req.Convergent {
-				lm.UnlockPolicy(lock, lockType)
-				return nil, nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
-			}
-
-		case KeyType_ED25519:
-			if req.Convergent {
-				lm.UnlockPolicy(lock, lockType)
-				return nil, nil, false, fmt.Errorf("convergent encryption not supported for keys of type %v", req.KeyType)
-			}
-
-		case KeyType_RSA2048, KeyType_RSA4096:
-			if req.Derived || req.Convergent {
-				lm.UnlockPolicy(lock, lockType)
-				return nil, nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
-			}
-
-		default:
+		if err := req.validateKeyType(); err != nil {
 			lm.UnlockPolicy(lock, lockType)
-			return nil, nil, false, fmt.Errorf("unsupported key type %v", req.KeyType)
+			return nil, nil, false, err
 		}
 
 		p = &Policy{
@@ -13,6 +13,7 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/base64"
 	"encoding/json"
@@ -52,6 +53,7 @@ const (
 	KeyType_RSA2048
 	KeyType_RSA4096
 	KeyType_ChaCha20_Poly1305
+	KeyType_AES256_FF3_1
 )
 
 const (
@@ -124,6 +126,14 @@ func (kt KeyType) DerivationSupported() bool {
 	return false
 }
 
+func (kt KeyType) FPESupported() bool {
+	switch kt {
+	case KeyType_AES256_FF3_1:
+		return true
+	}
+	return false
+}
+
 func (kt KeyType) String() string {
 	switch kt {
 	case KeyType_AES256_GCM96:
@@ -138,6 +148,8 @@ func (kt KeyType) String() string {
 		return "rsa-2048"
 	case KeyType_RSA4096:
 		return "rsa-4096"
+	case KeyType_AES256_FF3_1:
+		return "aes256-ff3-1"
 	}
 
 	return "[unknown]"
@@ -342,6 +354,14 @@ type Policy struct {
 	// policy object.
 	StoragePrefix string `json:"storage_prefix"`
 
+	// Imported indicates that the key material was imported rather than
+	// generated by Vault
+	Imported bool `json:"imported"`
+
+	// AllowImportedKeyRotation allows Vault to generate new versions of an
+	// imported key on rotation
+	AllowImportedKeyRotation bool `json:"allow_imported_key_rotation"`
+
 	// versionPrefixCache stores caches of verison prefix strings and the split
 	// version template.
 	versionPrefixCache *sync.Map
@@ -942,6 +962,77 @@ func (p *Policy) HMACKey(version int) ([]byte, error) {
 	return p.Keys[strconv.Itoa(version)].HMACKey, nil
 }
 
+// EncryptFPE encrypts the numerals, each smaller than radix, with FF3-1 using
+// the given key version and tweak. The result has the same length as the
+// input. As with convergent encryption, the same input and tweak always
+// result in the same output.
+func (p *Policy) EncryptFPE(ver int, tweak []byte, radix int, numerals []uint16) ([]uint16, error) {
+	if !p.Type.FPESupported() {
+		return nil, errutil.UserError{Err: fmt.Sprintf("format-preserving encryption not supported for key type %v", p.Type)}
+	}
+
+	switch {
+	case ver == 0:
+		ver = p.LatestVersion
+	case ver < 0:
+		return nil, errutil.UserError{Err: "requested version for encryption is negative"}
+	case ver > p.LatestVersion:
+		return nil, errutil.UserError{Err: "requested version for encryption is higher than the latest key version"}
+	case ver < p.MinEncryptionVersion:
+		return nil, errutil.UserError{Err: "requested version for encryption is less than the minimum encryption key version"}
+	}
+
+	c, err := p.fpeCipher(ver, radix)
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.Encrypt(tweak, numerals)
+	if err != nil {
+		return nil, errutil.UserError{Err: err.Error()}
+	}
+	return out, nil
+}
+
+// DecryptFPE reverses EncryptFPE for the same key version and tweak
+func (p *Policy) DecryptFPE(ver int, tweak []byte, radix int, numerals []uint16) ([]uint16, error) {
+	if !p.Type.FPESupported() {
+		return nil, errutil.UserError{Err: fmt.Sprintf("format-preserving decryption not supported for key type %v", p.Type)}
+	}
+
+	switch {
+	case ver == 0:
+		ver = p.LatestVersion
+	case ver < 0:
+		return nil, errutil.UserError{Err: "requested version for decryption is negative"}
+	case ver > p.LatestVersion:
+		return nil, errutil.UserError{Err: "requested version for decryption is higher than the latest key version"}
+	case ver < p.MinDecryptionVersion:
+		return nil, errutil.UserError{Err: ErrTooOld}
+	}
+
+	c, err := p.fpeCipher(ver, radix)
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.Decrypt(tweak, numerals)
+	if err != nil {
+		return nil, errutil.UserError{Err: err.Error()}
+	}
+	return out, nil
+}
+
+func (p *Policy) fpeCipher(ver, radix int) (*ff3Cipher, error) {
+	keyEntry, ok := p.Keys[strconv.Itoa(ver)]
+	if !ok || len(keyEntry.Key) == 0 {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("no key found for version %d", ver)}
+	}
+	c, err := newFF3Cipher(keyEntry.Key, radix)
+	if err != nil {
+		return nil, errutil.UserError{Err: err.Error()}
+	}
+	return c, nil
+}
+
 func (p *Policy) Sign(ver int, context, input []byte, hashAlgorithm, sigAlgorithm string) (*SigningResult, error) {
 	if !p.Type.SigningSupported() {
 		return nil, fmt.Errorf("message signing not supported for key type %v", p.Type)
@@ -1169,6 +1260,10 @@ func (p *Policy) VerifySignature(context, input []byte, sig, hashAlgorithm strin
 }
 
 func (p *Policy) Rotate(ctx context.Context, storage logical.Storage) (retErr error) {
+	if p.Imported && !p.AllowImportedKeyRotation {
+		return errutil.UserError{Err: "rotation of this imported key is not allowed; import a new version instead"}
+	}
+
 	priorLatestVersion := p.LatestVersion
 	priorMinDecryptionVersion := p.MinDecryptionVersion
 	var priorKeys keyEntryMap
@@ -1209,7 +1304,7 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage) (retErr er
 	entry.HMACKey = hmacKey
 
 	switch p.Type {
-	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_AES256_FF3_1:
 		// Generate a 256bit key
 		newKey, err := uuid.GenerateRandomBytes(32)
 		if err != nil {
@@ -1225,19 +1320,10 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage) (retErr er
 		entry.EC_D = privKey.D
 		entry.EC_X = privKey.X
 		entry.EC_Y = privKey.Y
-		derBytes, err := x509.MarshalPKIXPublicKey(privKey.Public())
+		entry.FormattedPublicKey, err = formatECDSAPublicKey(privKey)
 		if err != nil {
-			return fmt.Errorf("error marshaling public key: %s", err)
-		}
-		pemBlock := &pem.Block{
-			Type:  "PUBLIC KEY",
-			Bytes: derBytes,
-		}
-		pemBytes := pem.EncodeToMemory(pemBlock)
-		if pemBytes == nil || len(pemBytes) == 0 {
-			return fmt.Errorf("error PEM-encoding public key")
+			return err
 		}
-		entry.FormattedPublicKey = string(pemBytes)
 
 	case KeyType_ED25519:
 		pub, pri, err := ed25519.GenerateKey(rand.Reader)
@@ -1271,6 +1357,153 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage) (retErr er
 	return p.Persist(ctx, storage)
 }
 
+// Import adds the given key material as the latest version of the policy.
+// Symmetric keys are given as 32 raw bytes, asymmetric keys as PKCS #8 DER
+// encoded private keys.
+func (p *Policy) Import(ctx context.Context, storage logical.Storage, key []byte) (retErr error) {
+	entry, err := p.parseImportedKey(key)
+	if err != nil {
+		return err
+	}
+
+	priorLatestVersion := p.LatestVersion
+	priorMinDecryptionVersion := p.MinDecryptionVersion
+	priorImported := p.Imported
+	var priorKeys keyEntryMap
+
+	if p.Keys != nil {
+		priorKeys = keyEntryMap{}
+		for k, v := range p.Keys {
+			priorKeys[k] = v
+		}
+	}
+
+	defer func() {
+		if retErr != nil {
+			p.LatestVersion = priorLatestVersion
+			p.MinDecryptionVersion = priorMinDecryptionVersion
+			p.Imported = priorImported
+			p.Keys = priorKeys
+		}
+	}()
+
+	if p.Keys == nil {
+		p.Keys = keyEntryMap{}
+	}
+
+	now := time.Now()
+	entry.CreationTime = now
+	entry.DeprecatedCreationTime = now.Unix()
+	entry.HMACKey, err = uuid.GenerateRandomBytes(32)
+	if err != nil {
+		return err
+	}
+
+	p.Imported = true
+	p.LatestVersion += 1
+	p.Keys[strconv.Itoa(p.LatestVersion)] = *entry
+
+	if p.MinDecryptionVersion == 0 {
+		p.MinDecryptionVersion = 1
+	}
+
+	return p.Persist(ctx, storage)
+}
+
+// pkcs8 reflects an ASN.1, PKCS #8 PrivateKey. See RFC 5208.
+type pkcs8 struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+var oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+func (p *Policy) parseImportedKey(key []byte) (*KeyEntry, error) {
+	entry := &KeyEntry{}
+
+	switch p.Type {
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_AES256_FF3_1:
+		if len(key) != 32 {
+			return nil, errutil.UserError{Err: fmt.Sprintf("imported key must be 32 bytes long for key type %v", p.Type)}
+		}
+		entry.Key = key
+
+	case KeyType_ED25519:
+		// Ed25519 keys are wrapped in PKCS #8 as described in RFC 8410
+		var privKey pkcs8
+		if rest, err := asn1.Unmarshal(key, &privKey); err != nil || len(rest) != 0 {
+			return nil, errutil.UserError{Err: "failed to parse imported key as a PKCS #8 private key"}
+		}
+		if !privKey.Algo.Algorithm.Equal(oidEd25519) {
+			return nil, errutil.UserError{Err: fmt.Sprintf("imported key is not a key of type %v", p.Type)}
+		}
+		var seed []byte
+		if rest, err := asn1.Unmarshal(privKey.PrivateKey, &seed); err != nil || len(rest) != 0 || len(seed) != 32 {
+			return nil, errutil.UserError{Err: "invalid ed25519 private key"}
+		}
+		pub, pri, err := ed25519.GenerateKey(bytes.NewReader(seed))
+		if err != nil {
+			return nil, err
+		}
+		entry.Key = pri
+		entry.FormattedPublicKey = base64.StdEncoding.EncodeToString(pub)
+
+	case KeyType_ECDSA_P256:
+		parsed, err := x509.ParsePKCS8PrivateKey(key)
+		if err != nil {
+			return nil, errutil.UserError{Err: fmt.Sprintf("failed to parse imported key: %v", err)}
+		}
+		privKey, ok := parsed.(*ecdsa.PrivateKey)
+		if !ok || privKey.Curve != elliptic.P256() {
+			return nil, errutil.UserError{Err: fmt.Sprintf("imported key is not a key of type %v", p.Type)}
+		}
+		entry.EC_D = privKey.D
+		entry.EC_X = privKey.X
+		entry.EC_Y = privKey.Y
+		entry.FormattedPublicKey, err = formatECDSAPublicKey(privKey)
+		if err != nil {
+			return nil, err
+		}
+
+	case KeyType_RSA2048, KeyType_RSA4096:
+		parsed, err := x509.ParsePKCS8PrivateKey(key)
+		if err != nil {
+			return nil, errutil.UserError{Err: fmt.Sprintf("failed to parse imported key: %v", err)}
+		}
+		bitSize := 2048
+		if p.Type == KeyType_RSA4096 {
+			bitSize = 4096
+		}
+		privKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok || privKey.N.BitLen() != bitSize {
+			return nil, errutil.UserError{Err: fmt.Sprintf("imported key is not a key of type %v", p.Type)}
+		}
+		entry.RSAKey = privKey
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %v", p.Type)
+	}
+
+	return entry, nil
+}
+
+func formatECDSAPublicKey(privKey *ecdsa.PrivateKey) (string, error) {
+	derBytes, err := x509.MarshalPKIXPublicKey(privKey.Public())
+	if err != nil {
+		return "", fmt.Errorf("error marshaling public key: %s", err)
+	}
+	pemBlock := &pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	}
+	pemBytes := pem.EncodeToMemory(pemBlock)
+	if pemBytes == nil || len(pemBytes) == 0 {
+		return "", fmt.Errorf("error PEM-encoding public key")
+	}
+	return string(pemBytes), nil
+}
+
 func (p *Policy) MigrateKeyToKeysMap() {
 	now := time.Now()
 	p.Keys = keyEntryMap{
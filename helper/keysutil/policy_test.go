@@ -2,6 +2,10 @@ package keysutil
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
 	"reflect"
 	"strconv"
 	"testing"
@@ -10,6 +14,7 @@ import (
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/mitchellh/copystructure"
+	"golang.org/x/crypto/ed25519"
 )
 
 func TestPolicy_KeyEntryMapUpgrade(t *testing.T) {
@@ -576,3 +581,48 @@ func Test_BadArchive(t *testing.T) {
 		t.Fatalf("unexpected key length %d", len(p.Keys))
 	}
 }
+
+func TestPolicy_ImportEd25519(t *testing.T) {
+	pub, pri, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, err := asn1.Marshal(pri[:32])
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(pkcs8{
+		Algo:       pkix.AlgorithmIdentifier{Algorithm: oidEd25519},
+		PrivateKey: seed,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Policy{
+		Name: "test",
+		Type: KeyType_ED25519,
+	}
+	storage := &logical.InmemStorage{}
+	if err := p.Import(context.Background(), storage, der); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Imported || p.LatestVersion != 1 {
+		t.Fatalf("bad: %#v", p)
+	}
+	if p.Keys["1"].FormattedPublicKey != base64.StdEncoding.EncodeToString(pub) {
+		t.Fatal("imported key does not match")
+	}
+
+	if err := p.Rotate(context.Background(), storage); err == nil {
+		t.Fatal("expected error rotating an imported key")
+	}
+
+	// A raw key is not accepted for an asymmetric key type
+	if err := p.Import(context.Background(), storage, pri); err == nil {
+		t.Fatal("expected error importing a raw key")
+	}
+	if p.LatestVersion != 1 {
+		t.Fatalf("bad version after failed import: %d", p.LatestVersion)
+	}
+}
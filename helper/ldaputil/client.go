@@ -0,0 +1,138 @@
+// Package ldaputil holds the LDAP client code shared by the LDAP auth method
+// and the LDAP secrets engine.
+package ldaputil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/go-ldap/ldap"
+	"github.com/hashicorp/vault/helper/tlsutil"
+)
+
+// ConnectionConfig holds the settings used to connect to LDAP servers
+type ConnectionConfig struct {
+	// URL is a comma-separated list of LDAP URLs, tried in order
+	URL string
+
+	Certificate   string
+	InsecureTLS   bool
+	StartTLS      bool
+	TLSMinVersion string
+	TLSMaxVersion string
+}
+
+// URLs returns the configured URLs
+func (c *ConnectionConfig) URLs() []string {
+	return strings.Split(c.URL, ",")
+}
+
+// TLSConfig returns the TLS configuration used to connect to the given host
+func (c *ConnectionConfig) TLSConfig(host string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: host,
+	}
+
+	if c.TLSMinVersion != "" {
+		tlsMinVersion, ok := tlsutil.TLSLookup[c.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid 'tls_min_version' in config")
+		}
+		tlsConfig.MinVersion = tlsMinVersion
+	}
+
+	if c.TLSMaxVersion != "" {
+		tlsMaxVersion, ok := tlsutil.TLSLookup[c.TLSMaxVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid 'tls_max_version' in config")
+		}
+		tlsConfig.MaxVersion = tlsMaxVersion
+	}
+
+	if c.InsecureTLS {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if c.Certificate != "" {
+		caPool := x509.NewCertPool()
+		ok := caPool.AppendCertsFromPEM([]byte(c.Certificate))
+		if !ok {
+			return nil, fmt.Errorf("could not append CA certificate")
+		}
+		tlsConfig.RootCAs = caPool
+	}
+	return tlsConfig, nil
+}
+
+// DialURL connects to the LDAP server at the given URL. The "ldap://" scheme
+// connects unencrypted on port 389 by default, upgraded with StartTLS if
+// configured, and "ldaps://" connects with TLS on port 636 by default.
+func (c *ConnectionConfig) DialURL(uut string) (*ldap.Conn, error) {
+	u, err := url.Parse(uut)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing url %q: %s", uut, err.Error())
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		host = u.Host
+	}
+
+	var conn *ldap.Conn
+	var tlsConfig *tls.Config
+	switch u.Scheme {
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+		conn, err = ldap.Dial("tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			return nil, err
+		}
+		if conn == nil {
+			return nil, fmt.Errorf("empty connection after dialing")
+		}
+		if c.StartTLS {
+			tlsConfig, err = c.TLSConfig(host)
+			if err == nil {
+				err = conn.StartTLS(tlsConfig)
+			}
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+	case "ldaps":
+		if port == "" {
+			port = "636"
+		}
+		tlsConfig, err = c.TLSConfig(host)
+		if err != nil {
+			return nil, err
+		}
+		conn, err = ldap.DialTLS("tcp", net.JoinHostPort(host, port), tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid LDAP scheme in url %q", net.JoinHostPort(host, port))
+	}
+
+	return conn, nil
+}
+
+// Search runs a search request, paging the results if a page size is set
+func Search(conn *ldap.Conn, searchRequest *ldap.SearchRequest, pageSize int) (*ldap.SearchResult, error) {
+	if pageSize > 0 {
+		return conn.SearchWithPaging(searchRequest, uint32(pageSize))
+	}
+	return conn.Search(searchRequest)
+}
+
+// IsNetworkError returns whether the error is a network error, after which
+// the connection cannot be used anymore
+func IsNetworkError(err error) bool {
+	return ldap.IsErrorWithCode(err, ldap.ErrorNetwork)
+}
@@ -0,0 +1,575 @@
+// Package ldaptest provides an in-process LDAP server for tests. It is only
+// imported by tests, so it is not built into the vault binary.
+package ldaptest
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/go-ldap/ldap"
+	"github.com/mitchellh/go-testing-interface"
+	"gopkg.in/asn1-ber.v1"
+)
+
+const (
+	UserDN       = "ou=users,dc=example,dc=org"
+	GroupDN      = "ou=groups,dc=example,dc=org"
+	BindDN       = "cn=admin,dc=example,dc=org"
+	BindPassword = "adminpw"
+)
+
+// Server is an in-process LDAP server implementing simple binds, adds,
+// deletes, modifies and searches, including paged searches and the
+// LDAP_MATCHING_RULE_IN_CHAIN matching rule of Active Directory. Passwords are
+// set with the userPassword attribute, or the unicodePwd attribute of Active
+// Directory.
+type Server struct {
+	// URL is the URL to connect to the server
+	URL string
+
+	// MaxResults, if set, fails unpaged searches returning more entries, as
+	// Active Directory does
+	MaxResults int
+
+	t        testing.T
+	listener net.Listener
+
+	lock        sync.Mutex
+	entries     []*testEntry
+	passwords   map[string]string
+	conns       []net.Conn
+	connections int
+	searches    map[string]int
+}
+
+type testEntry struct {
+	dn    string
+	attrs map[string][]string
+}
+
+// NewServer starts a server holding the users alice and bob. alice is a
+// member of the devs group, itself a member of the engineering group. bob is
+// a member of the ops group.
+func NewServer(t testing.T) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		URL:      "ldap://" + listener.Addr().String(),
+		t:        t,
+		listener: listener,
+		passwords: map[string]string{
+			BindDN: BindPassword,
+		},
+		searches: make(map[string]int),
+	}
+	s.AddEntry("uid=alice,"+UserDN, map[string][]string{"objectClass": {"person"}, "uid": {"alice"}, "userPassword": {"alicepw"}})
+	s.AddEntry("uid=bob,"+UserDN, map[string][]string{"objectClass": {"person"}, "uid": {"bob"}, "userPassword": {"bobpw"}})
+	s.AddEntry("cn=devs,"+GroupDN, map[string][]string{
+		"objectClass": {"group"},
+		"cn":          {"devs"},
+		"member":      {"uid=alice," + UserDN},
+	})
+	s.AddEntry("cn=engineering,"+GroupDN, map[string][]string{
+		"objectClass": {"group"},
+		"cn":          {"engineering"},
+		"member":      {"cn=devs," + GroupDN},
+	})
+	s.AddEntry("cn=ops,"+GroupDN, map[string][]string{
+		"objectClass": {"group"},
+		"cn":          {"ops"},
+		"member":      {"uid=bob," + UserDN},
+	})
+
+	go s.serve()
+	return s
+}
+
+// AddEntry adds an entry to the server, replacing any entry with the same DN
+func (s *Server) AddEntry(dn string, attrs map[string][]string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.deleteEntry(dn)
+	entry := &testEntry{dn: dn, attrs: make(map[string][]string)}
+	for name, values := range attrs {
+		entry.set(name, values)
+	}
+	s.entries = append(s.entries, entry)
+	s.setPassword(entry)
+}
+
+// Entry returns the attributes of the entry with the given DN, or nil if it
+// does not exist
+func (s *Server) Entry(dn string) map[string][]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entry := s.entry(dn)
+	if entry == nil {
+		return nil
+	}
+	attrs := make(map[string][]string, len(entry.attrs))
+	for name, values := range entry.attrs {
+		attrs[name] = append([]string(nil), values...)
+	}
+	return attrs
+}
+
+// Password returns the password of the entry with the given DN
+func (s *Server) Password(dn string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.passwords[strings.ToLower(dn)]
+}
+
+// Connections returns the number of connections made to the server
+func (s *Server) Connections() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.connections
+}
+
+// Searches returns the number of searches made with the given base DN
+func (s *Server) Searches(baseDN string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.searches[strings.ToLower(baseDN)]
+}
+
+// Close stops the server and closes its connections
+func (s *Server) Close() {
+	s.listener.Close()
+	s.CloseConns()
+}
+
+// CloseConns closes the open connections, as a server timing out idle
+// connections does
+func (s *Server) CloseConns() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.lock.Lock()
+		s.conns = append(s.conns, conn)
+		s.connections++
+		s.lock.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+
+		messageID := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+		var controls []ldap.Control
+		if len(packet.Children) > 2 {
+			for _, child := range packet.Children[2].Children {
+				controls = append(controls, ldap.DecodeControl(child))
+			}
+		}
+
+		var responses []*ber.Packet
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			responses = s.bind(messageID, request)
+		case ldap.ApplicationUnbindRequest:
+			return
+		case ldap.ApplicationSearchRequest:
+			responses = s.search(messageID, request, controls)
+		case ldap.ApplicationModifyRequest:
+			responses = s.modify(messageID, request)
+		case ldap.ApplicationAddRequest:
+			responses = s.add(messageID, request)
+		case ldap.ApplicationDelRequest:
+			responses = s.del(messageID, request)
+		default:
+			s.t.Logf("unsupported LDAP request %d", request.Tag)
+			return
+		}
+
+		for _, response := range responses {
+			if _, err := conn.Write(response.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) bind(messageID int64, request *ber.Packet) []*ber.Packet {
+	name := request.Children[1].Value.(string)
+	password := request.Children[2].Data.String()
+
+	resultCode := int64(ldap.LDAPResultSuccess)
+	if name != "" && password != "" {
+		s.lock.Lock()
+		expected, ok := s.passwords[strings.ToLower(name)]
+		s.lock.Unlock()
+		if !ok || expected != password {
+			resultCode = ldap.LDAPResultInvalidCredentials
+		}
+	}
+
+	return []*ber.Packet{
+		testMessage(messageID, testResult(ldap.ApplicationBindResponse, resultCode)),
+	}
+}
+
+func (s *Server) search(messageID int64, request *ber.Packet, controls []ldap.Control) []*ber.Packet {
+	baseDN := strings.ToLower(request.Children[0].Value.(string))
+	scope := request.Children[1].Value.(int64)
+	filter := request.Children[6]
+
+	// Root DSE
+	if baseDN == "" && scope == ldap.ScopeBaseObject {
+		return []*ber.Packet{
+			testMessage(messageID, testResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)),
+		}
+	}
+
+	s.lock.Lock()
+	s.searches[baseDN]++
+	var matches []*testEntry
+	for _, entry := range s.entries {
+		dn := strings.ToLower(entry.dn)
+		if dn != baseDN && !strings.HasSuffix(dn, ","+baseDN) {
+			continue
+		}
+		if s.matches(entry, filter) {
+			matches = append(matches, entry)
+		}
+	}
+
+	resultCode := int64(ldap.LDAPResultSuccess)
+	var responseControls []ldap.Control
+	if control := ldap.FindControl(controls, ldap.ControlTypePaging); control != nil && control.(*ldap.ControlPaging).PagingSize > 0 {
+		paging := control.(*ldap.ControlPaging)
+		offset, _ := strconv.Atoi(string(paging.Cookie))
+		end := offset + int(paging.PagingSize)
+
+		cookie := ""
+		if end < len(matches) {
+			cookie = strconv.Itoa(end)
+		} else {
+			end = len(matches)
+		}
+		matches = matches[offset:end]
+
+		response := ldap.NewControlPaging(0)
+		response.SetCookie([]byte(cookie))
+		responseControls = append(responseControls, response)
+	} else if s.MaxResults > 0 && len(matches) > s.MaxResults {
+		matches = matches[:s.MaxResults]
+		resultCode = ldap.LDAPResultSizeLimitExceeded
+	}
+
+	var responses []*ber.Packet
+	for _, entry := range matches {
+		responses = append(responses, testMessage(messageID, testSearchEntry(entry)))
+	}
+	s.lock.Unlock()
+
+	done := testMessage(messageID, testResult(ldap.ApplicationSearchResultDone, resultCode))
+	if len(responseControls) > 0 {
+		packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+		for _, control := range responseControls {
+			packet.AppendChild(control.Encode())
+		}
+		done.AppendChild(packet)
+	}
+	return append(responses, done)
+}
+
+func (s *Server) modify(messageID int64, request *ber.Packet) []*ber.Packet {
+	dn := request.Children[0].Value.(string)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entry := s.entry(dn)
+	if entry == nil {
+		return []*ber.Packet{
+			testMessage(messageID, testResult(ldap.ApplicationModifyResponse, ldap.LDAPResultNoSuchObject)),
+		}
+	}
+
+	for _, change := range request.Children[1].Children {
+		operation := change.Children[0].Value.(int64)
+		name, values := testAttribute(change.Children[1])
+		switch operation {
+		case ldap.AddAttribute:
+			entry.set(name, append(entry.values(name), values...))
+		case ldap.DeleteAttribute:
+			var kept []string
+			if len(values) > 0 {
+				for _, v := range entry.values(name) {
+					if !testContains(values, v) {
+						kept = append(kept, v)
+					}
+				}
+			}
+			entry.set(name, kept)
+		case ldap.ReplaceAttribute:
+			entry.set(name, values)
+		}
+	}
+	s.setPassword(entry)
+
+	return []*ber.Packet{
+		testMessage(messageID, testResult(ldap.ApplicationModifyResponse, ldap.LDAPResultSuccess)),
+	}
+}
+
+func (s *Server) add(messageID int64, request *ber.Packet) []*ber.Packet {
+	dn := request.Children[0].Value.(string)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.entry(dn) != nil {
+		return []*ber.Packet{
+			testMessage(messageID, testResult(ldap.ApplicationAddResponse, ldap.LDAPResultEntryAlreadyExists)),
+		}
+	}
+
+	entry := &testEntry{dn: dn, attrs: make(map[string][]string)}
+	for _, attribute := range request.Children[1].Children {
+		name, values := testAttribute(attribute)
+		entry.set(name, values)
+	}
+	s.entries = append(s.entries, entry)
+	s.setPassword(entry)
+
+	return []*ber.Packet{
+		testMessage(messageID, testResult(ldap.ApplicationAddResponse, ldap.LDAPResultSuccess)),
+	}
+}
+
+func (s *Server) del(messageID int64, request *ber.Packet) []*ber.Packet {
+	dn := request.Data.String()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	resultCode := int64(ldap.LDAPResultSuccess)
+	if !s.deleteEntry(dn) {
+		resultCode = ldap.LDAPResultNoSuchObject
+	}
+
+	return []*ber.Packet{
+		testMessage(messageID, testResult(ldap.ApplicationDelResponse, resultCode)),
+	}
+}
+
+// entry returns the entry with the given DN. The lock must be held.
+func (s *Server) entry(dn string) *testEntry {
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.dn, dn) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// deleteEntry deletes the entry with the given DN and its password. The lock
+// must be held.
+func (s *Server) deleteEntry(dn string) bool {
+	for i, entry := range s.entries {
+		if strings.EqualFold(entry.dn, dn) {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			delete(s.passwords, strings.ToLower(dn))
+			return true
+		}
+	}
+	return false
+}
+
+// setPassword moves the password attributes of the entry to the passwords, as
+// they cannot be read back. The lock must be held.
+func (s *Server) setPassword(entry *testEntry) {
+	if values := entry.values("userPassword"); len(values) > 0 {
+		s.passwords[strings.ToLower(entry.dn)] = values[0]
+	}
+	if values := entry.values("unicodePwd"); len(values) > 0 {
+		s.passwords[strings.ToLower(entry.dn)] = testDecodeUnicodePwd(values[0])
+	}
+	entry.set("userPassword", nil)
+	entry.set("unicodePwd", nil)
+}
+
+func (s *Server) matches(entry *testEntry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !s.matches(entry, child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if s.matches(entry, child) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !s.matches(entry, filter.Children[0])
+	case ldap.FilterEqualityMatch:
+		return entry.has(filter.Children[0].Value.(string), filter.Children[1].Value.(string))
+	case ldap.FilterPresent:
+		return strings.EqualFold(filter.Data.String(), "objectClass") || len(entry.values(filter.Data.String())) > 0
+	case ldap.FilterExtensibleMatch:
+		var rule, attr, value string
+		for _, child := range filter.Children {
+			switch child.Tag {
+			case ldap.MatchingRuleAssertionMatchingRule:
+				rule = child.Data.String()
+			case ldap.MatchingRuleAssertionType:
+				attr = child.Data.String()
+			case ldap.MatchingRuleAssertionMatchValue:
+				value = child.Data.String()
+			}
+		}
+		if rule == "1.2.840.113556.1.4.1941" {
+			return s.memberInChain(entry, attr, value, map[string]bool{})
+		}
+		return entry.has(attr, value)
+	default:
+		s.t.Logf("unsupported LDAP filter %d", filter.Tag)
+		return false
+	}
+}
+
+// memberInChain reports whether the value is found by following the
+// attribute from the entry, recursively
+func (s *Server) memberInChain(entry *testEntry, attr, value string, visited map[string]bool) bool {
+	visited[strings.ToLower(entry.dn)] = true
+	for _, v := range entry.values(attr) {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+		if visited[strings.ToLower(v)] {
+			continue
+		}
+		if nested := s.entry(v); nested != nil && s.memberInChain(nested, attr, value, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *testEntry) values(attr string) []string {
+	for name, values := range e.attrs {
+		if strings.EqualFold(name, attr) {
+			return values
+		}
+	}
+	return nil
+}
+
+// set sets the values of the attribute, removing it if there are none
+func (e *testEntry) set(attr string, values []string) {
+	for name := range e.attrs {
+		if strings.EqualFold(name, attr) {
+			delete(e.attrs, name)
+		}
+	}
+	if len(values) > 0 {
+		e.attrs[attr] = values
+	}
+}
+
+func (e *testEntry) has(attr, value string) bool {
+	return testContains(e.values(attr), value)
+}
+
+func testContains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func testAttribute(packet *ber.Packet) (string, []string) {
+	name := packet.Children[0].Data.String()
+	var values []string
+	for _, value := range packet.Children[1].Children {
+		values = append(values, value.Data.String())
+	}
+	return name, values
+}
+
+// testDecodeUnicodePwd decodes the quoted UTF-16LE passwords of Active
+// Directory
+func testDecodeUnicodePwd(value string) string {
+	encoded := make([]uint16, len(value)/2)
+	for i := range encoded {
+		encoded[i] = binary.LittleEndian.Uint16([]byte(value[2*i:]))
+	}
+	return strings.Trim(string(utf16.Decode(encoded)), `"`)
+}
+
+func testMessage(messageID int64, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	packet.AppendChild(op)
+	return packet
+}
+
+func testResult(tag ber.Tag, resultCode int64) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, resultCode, "resultCode"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return packet
+}
+
+func testSearchEntry(entry *testEntry) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "objectName"))
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range entry.attrs {
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		attr.AppendChild(set)
+		attrs.AppendChild(attr)
+	}
+	packet.AppendChild(attrs)
+	return packet
+}
@@ -0,0 +1,196 @@
+package ldaputil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap"
+	log "github.com/hashicorp/go-hclog"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+const (
+	// Idle connections are checked before reuse if they have not been used
+	// for this long
+	poolHealthCheckInterval = 30 * time.Second
+
+	// URLs which failed to connect are tried last for this long
+	FailedURLRetryInterval = time.Minute
+)
+
+// Pool is a bounded pool of connections to the LDAP servers. The number of
+// connections in use is limited to the pool size, and connections are kept
+// open once released. Idle connections are health checked before reuse.
+type Pool struct {
+	cfg    *ConnectionConfig
+	logger log.Logger
+
+	// slots limits the number of connections in use
+	slots chan struct{}
+
+	lock       sync.Mutex
+	idle       []*PooledConn
+	failedURLs map[string]time.Time
+	closed     bool
+}
+
+// PooledConn is a connection of a pool
+type PooledConn struct {
+	*ldap.Conn
+
+	url      string
+	lastUsed time.Time
+}
+
+// NewPool returns a pool of at most size connections in use
+func NewPool(cfg *ConnectionConfig, size int, logger log.Logger) *Pool {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Pool{
+		cfg:        cfg,
+		logger:     logger,
+		slots:      make(chan struct{}, size),
+		failedURLs: make(map[string]time.Time),
+	}
+}
+
+// Get returns a connection from the pool, dialing a new one if none is idle.
+// It blocks while all the connections of the pool are in use. The connection
+// must be returned to the pool with Put.
+func (p *Pool) Get(ctx context.Context) (*PooledConn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out waiting for an LDAP connection: %v", ctx.Err())
+	}
+
+	for {
+		conn := p.popIdle()
+		if conn == nil {
+			break
+		}
+		if time.Since(conn.lastUsed) < poolHealthCheckInterval || Healthy(conn.Conn) {
+			return conn, nil
+		}
+		p.logger.Debug("closing unhealthy LDAP connection", "url", conn.url)
+		conn.Close()
+	}
+
+	conn, err := p.dial()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Put returns a connection to the pool. Broken connections are closed, along
+// with the idle connections, which were likely dropped by the server too.
+func (p *Pool) Put(conn *PooledConn, broken bool) {
+	defer func() { <-p.slots }()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if broken {
+		for _, idle := range p.idle {
+			idle.Close()
+		}
+		p.idle = nil
+	}
+	if broken || p.closed {
+		conn.Close()
+		return
+	}
+
+	conn.lastUsed = time.Now()
+	p.idle = append(p.idle, conn)
+}
+
+// Close closes the idle connections of the pool. Connections in use are
+// closed when they are returned.
+func (p *Pool) Close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.closed = true
+	for _, conn := range p.idle {
+		conn.Close()
+	}
+	p.idle = nil
+}
+
+func (p *Pool) popIdle() *PooledConn {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// Reuse the most recently used connection, so that the others can be
+	// found idle for long and health checked
+	if len(p.idle) == 0 {
+		return nil
+	}
+	conn := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return conn
+}
+
+// dial connects to the first reachable URL. URLs are tried in the configured
+// order, except that URLs which failed recently are tried last.
+func (p *Pool) dial() (*PooledConn, error) {
+	var retErr *multierror.Error
+	for _, u := range p.DialOrder(time.Now()) {
+		conn, err := p.cfg.DialURL(u)
+		if err != nil {
+			retErr = multierror.Append(retErr, fmt.Errorf("error connecting to host %q: %s", u, err.Error()))
+
+			p.lock.Lock()
+			p.failedURLs[u] = time.Now()
+			p.lock.Unlock()
+			continue
+		}
+
+		if retErr != nil {
+			p.logger.Debug("errors connecting to some hosts", "error", retErr.Error())
+		}
+
+		p.lock.Lock()
+		delete(p.failedURLs, u)
+		p.lock.Unlock()
+
+		return &PooledConn{Conn: conn, url: u}, nil
+	}
+
+	return nil, retErr.ErrorOrNil()
+}
+
+// DialOrder returns the URLs in the order they are tried at the given time
+func (p *Pool) DialOrder(now time.Time) []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var preferred, failed []string
+	for _, u := range p.cfg.URLs() {
+		if failedAt, ok := p.failedURLs[u]; ok && now.Sub(failedAt) < FailedURLRetryInterval {
+			failed = append(failed, u)
+		} else {
+			preferred = append(preferred, u)
+		}
+	}
+	return append(preferred, failed...)
+}
+
+// Healthy checks a connection by reading the root DSE. Errors other than
+// network errors mean the server answered.
+func Healthy(conn *ldap.Conn) bool {
+	_, err := conn.Search(&ldap.SearchRequest{
+		BaseDN:     "",
+		Scope:      ldap.ScopeBaseObject,
+		Filter:     "(objectClass=*)",
+		Attributes: []string{"1.1"},
+	})
+	return !IsNetworkError(err)
+}
@@ -15,6 +15,7 @@ package mfa
 import (
 	"context"
 
+	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/mfa/duo"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -80,7 +81,14 @@ func (b *backend) wrapLoginHandler(loginHandler framework.OperationFunc) framewo
 		// perform multi-factor authentication if type supported
 		handler, ok := handlers[mfa_config.Type]
 		if ok {
-			return handler(ctx, req, d, resp)
+			resp, err = handler(ctx, req, d, resp)
+			if err == nil && resp != nil && resp.Auth != nil {
+				if resp.Auth.InternalData == nil {
+					resp.Auth.InternalData = make(map[string]interface{})
+				}
+				resp.Auth.InternalData[consts.AuthInternalDataMFAMethodKey] = mfa_config.Type
+			}
+			return resp, err
 		} else {
 			return resp, err
 		}
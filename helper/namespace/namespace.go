@@ -0,0 +1,86 @@
+package namespace
+
+import (
+	"context"
+	"strings"
+)
+
+type contextValues struct{}
+
+// Namespace represents an isolated tenant. Its path is the prefix of every
+// request path that belongs to it, and is always either empty (for the root
+// namespace) or ends in a slash.
+type Namespace struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+}
+
+const (
+	// RootNamespaceID is the ID of the root namespace
+	RootNamespaceID = "root"
+)
+
+var (
+	contextNamespace contextValues = struct{}{}
+
+	// RootNamespace is the namespace every other namespace is nested in
+	RootNamespace = &Namespace{
+		ID:   RootNamespaceID,
+		Path: "",
+	}
+)
+
+// HasParent returns whether the namespace is nested (at any depth) in the
+// given parent
+func (n *Namespace) HasParent(possibleParent *Namespace) bool {
+	switch {
+	case n.Path == "":
+		return false
+	case possibleParent.Path == "":
+		return true
+	default:
+		return strings.HasPrefix(n.Path, possibleParent.Path)
+	}
+}
+
+// TrimmedPath returns the given path relative to the namespace
+func (n *Namespace) TrimmedPath(path string) string {
+	return strings.TrimPrefix(path, n.Path)
+}
+
+// ContextWithNamespace returns a context carrying the given namespace
+func ContextWithNamespace(ctx context.Context, ns *Namespace) context.Context {
+	return context.WithValue(ctx, contextNamespace, ns)
+}
+
+// RootContext returns a context carrying the root namespace, derived from the
+// given context or from context.Background if it is nil
+func RootContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return ContextWithNamespace(context.Background(), RootNamespace)
+	}
+	return ContextWithNamespace(ctx, RootNamespace)
+}
+
+// FromContext returns the namespace carried by the context. Contexts without
+// a namespace belong to the root namespace.
+func FromContext(ctx context.Context) *Namespace {
+	if ctx == nil {
+		return RootNamespace
+	}
+	ns, ok := ctx.Value(contextNamespace).(*Namespace)
+	if !ok || ns == nil {
+		return RootNamespace
+	}
+	return ns
+}
+
+// Canonicalize trims leading and trailing slashes from the given namespace
+// path and terminates it with a single slash. The empty path stays empty.
+func Canonicalize(nsPath string) string {
+	nsPath = strings.Trim(nsPath, "/")
+	if nsPath == "" {
+		return ""
+	}
+	return nsPath + "/"
+}
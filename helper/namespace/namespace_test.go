@@ -0,0 +1,65 @@
+package namespace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNamespace_Canonicalize(t *testing.T) {
+	cases := map[string]string{
+		"":           "",
+		"/":          "",
+		"foo":        "foo/",
+		"foo/":       "foo/",
+		"/foo/bar/":  "foo/bar/",
+		"//foo/bar/": "foo/bar/",
+	}
+	for in, expected := range cases {
+		if actual := Canonicalize(in); actual != expected {
+			t.Fatalf("%q: expected %q, got %q", in, expected, actual)
+		}
+	}
+}
+
+func TestNamespace_HasParent(t *testing.T) {
+	foo := &Namespace{ID: "foo", Path: "foo/"}
+	bar := &Namespace{ID: "bar", Path: "foo/bar/"}
+	baz := &Namespace{ID: "baz", Path: "baz/"}
+
+	cases := []struct {
+		ns       *Namespace
+		parent   *Namespace
+		expected bool
+	}{
+		{foo, RootNamespace, true},
+		{bar, RootNamespace, true},
+		{bar, foo, true},
+		{foo, bar, false},
+		{baz, foo, false},
+		{RootNamespace, foo, false},
+		{RootNamespace, RootNamespace, false},
+	}
+	for _, tc := range cases {
+		if actual := tc.ns.HasParent(tc.parent); actual != tc.expected {
+			t.Fatalf("%q in %q: expected %t", tc.ns.Path, tc.parent.Path, tc.expected)
+		}
+	}
+}
+
+func TestNamespace_Context(t *testing.T) {
+	if ns := FromContext(context.Background()); ns != RootNamespace {
+		t.Fatalf("expected the root namespace, got %#v", ns)
+	}
+
+	foo := &Namespace{ID: "foo", Path: "foo/"}
+	ctx := ContextWithNamespace(context.Background(), foo)
+	if ns := FromContext(ctx); ns != foo {
+		t.Fatalf("expected %#v, got %#v", foo, ns)
+	}
+	if ns := FromContext(RootContext(ctx)); ns != RootNamespace {
+		t.Fatalf("expected the root namespace, got %#v", ns)
+	}
+	if path := foo.TrimmedPath("foo/secret/bar"); path != "secret/bar" {
+		t.Fatalf("bad: %q", path)
+	}
+}
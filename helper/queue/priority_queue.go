@@ -0,0 +1,183 @@
+// Package queue provides a thread safe priority queue, ordered by the
+// Priority of each Item, where items can also be looked up and removed by
+// key.
+package queue
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+)
+
+// ErrEmpty is returned for queues with no items
+var ErrEmpty = errors.New("queue is empty")
+
+// ErrDuplicateItem is returned when the queue already contains an item with
+// the same key
+var ErrDuplicateItem = errors.New("queue already contains an item with that key")
+
+// Item is an entry in the queue. Items with the lowest Priority are popped
+// first.
+type Item struct {
+	// Key is the unique identifier of the item in the queue
+	Key string
+
+	// Value is the data associated with the item
+	Value interface{}
+
+	// Priority orders the items of the queue
+	Priority int64
+
+	// index is maintained by the heap.Interface methods
+	index int
+}
+
+// PriorityQueue is a min-heap of Items that also tracks them by key. It is
+// safe for concurrent use.
+type PriorityQueue struct {
+	data    queue
+	dataMap map[string]*Item
+	lock    sync.RWMutex
+}
+
+// New returns an empty PriorityQueue
+func New() *PriorityQueue {
+	pq := &PriorityQueue{
+		data:    make(queue, 0),
+		dataMap: make(map[string]*Item),
+	}
+	heap.Init(&pq.data)
+	return pq
+}
+
+// Len returns the number of items in the queue
+func (pq *PriorityQueue) Len() int {
+	pq.lock.RLock()
+	defer pq.lock.RUnlock()
+
+	return pq.data.Len()
+}
+
+// Push adds an item to the queue. An item with the same key must not
+// already be queued.
+func (pq *PriorityQueue) Push(i *Item) error {
+	if i == nil || i.Key == "" {
+		return errors.New("error adding item: item key is required")
+	}
+
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+
+	if _, ok := pq.dataMap[i.Key]; ok {
+		return ErrDuplicateItem
+	}
+
+	// Copy the item so the caller can't modify its index
+	item := &Item{
+		Key:      i.Key,
+		Value:    i.Value,
+		Priority: i.Priority,
+	}
+	heap.Push(&pq.data, item)
+	pq.dataMap[item.Key] = item
+
+	return nil
+}
+
+// Replace adds an item to the queue, removing any item with the same key in
+// the same operation
+func (pq *PriorityQueue) Replace(i *Item) error {
+	if i == nil || i.Key == "" {
+		return errors.New("error adding item: item key is required")
+	}
+
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+
+	if existing, ok := pq.dataMap[i.Key]; ok {
+		heap.Remove(&pq.data, existing.index)
+	}
+
+	item := &Item{
+		Key:      i.Key,
+		Value:    i.Value,
+		Priority: i.Priority,
+	}
+	heap.Push(&pq.data, item)
+	pq.dataMap[item.Key] = item
+
+	return nil
+}
+
+// Peek returns the item with the lowest priority without removing it
+func (pq *PriorityQueue) Peek() (*Item, error) {
+	pq.lock.RLock()
+	defer pq.lock.RUnlock()
+
+	if pq.data.Len() == 0 {
+		return nil, ErrEmpty
+	}
+
+	return pq.data[0], nil
+}
+
+// Pop removes and returns the item with the lowest priority
+func (pq *PriorityQueue) Pop() (*Item, error) {
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+
+	if pq.data.Len() == 0 {
+		return nil, ErrEmpty
+	}
+
+	item := heap.Pop(&pq.data).(*Item)
+	delete(pq.dataMap, item.Key)
+	return item, nil
+}
+
+// PopByKey removes and returns the item with the given key. A nil item is
+// returned if the key is not in the queue.
+func (pq *PriorityQueue) PopByKey(key string) (*Item, error) {
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+
+	item, ok := pq.dataMap[key]
+	if !ok {
+		return nil, nil
+	}
+
+	removed := heap.Remove(&pq.data, item.index).(*Item)
+	delete(pq.dataMap, key)
+	return removed, nil
+}
+
+// queue implements heap.Interface and holds Items
+type queue []*Item
+
+func (q queue) Len() int { return len(q) }
+
+func (q queue) Less(i, j int) bool {
+	return q[i].Priority < q[j].Priority
+}
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *queue) Push(x interface{}) {
+	item := x.(*Item)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *queue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*q = old[0 : n-1]
+	return item
+}
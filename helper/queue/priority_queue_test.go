@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestPriorityQueue_PushPop(t *testing.T) {
+	pq := New()
+
+	if _, err := pq.Pop(); err != ErrEmpty {
+		t.Fatalf("expected ErrEmpty, got %v", err)
+	}
+
+	for _, p := range []int64{5, 1, 4, 2, 3} {
+		if err := pq.Push(&Item{Key: fmt.Sprintf("item-%d", p), Priority: p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pq.Push(&Item{Key: "item-1", Priority: 10}); err != ErrDuplicateItem {
+		t.Fatalf("expected ErrDuplicateItem, got %v", err)
+	}
+	if pq.Len() != 5 {
+		t.Fatalf("expected 5 items, got %d", pq.Len())
+	}
+
+	peeked, err := pq.Peek()
+	if err != nil || peeked.Key != "item-1" {
+		t.Fatalf("bad peek: %#v, %v", peeked, err)
+	}
+
+	for expected := int64(1); expected <= 5; expected++ {
+		item, err := pq.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Priority != expected {
+			t.Fatalf("expected priority %d, got %d", expected, item.Priority)
+		}
+	}
+	if pq.Len() != 0 {
+		t.Fatalf("expected an empty queue, got %d items", pq.Len())
+	}
+}
+
+func TestPriorityQueue_PopByKey(t *testing.T) {
+	pq := New()
+	for i := int64(0); i < 10; i++ {
+		if err := pq.Push(&Item{Key: fmt.Sprintf("item-%d", i), Value: i, Priority: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	item, err := pq.PopByKey("item-4")
+	if err != nil || item == nil || item.Value.(int64) != 4 {
+		t.Fatalf("bad item: %#v, %v", item, err)
+	}
+	item, err = pq.PopByKey("item-4")
+	if err != nil || item != nil {
+		t.Fatalf("expected no item, got %#v, %v", item, err)
+	}
+
+	// The remaining items must still come out in order
+	var last int64 = -1
+	for pq.Len() > 0 {
+		item, err := pq.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Priority == 4 || item.Priority < last {
+			t.Fatalf("bad priority %d after %d", item.Priority, last)
+		}
+		last = item.Priority
+	}
+}
+
+func TestPriorityQueue_Replace(t *testing.T) {
+	pq := New()
+	for i := int64(0); i < 5; i++ {
+		if err := pq.Push(&Item{Key: fmt.Sprintf("item-%d", i), Priority: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := pq.Replace(&Item{Key: "item-0", Priority: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pq.Replace(&Item{Key: "item-5", Priority: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if pq.Len() != 6 {
+		t.Fatalf("expected 6 items, got %d", pq.Len())
+	}
+
+	var keys []string
+	for pq.Len() > 0 {
+		item, err := pq.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, item.Key)
+	}
+	if keys[0] != "item-5" || keys[5] != "item-0" {
+		t.Fatalf("bad order: %v", keys)
+	}
+
+	// Concurrent replaces of the same key leave exactly one item
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := pq.Replace(&Item{Key: "item", Priority: int64(i)}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if pq.Len() != 1 {
+		t.Fatalf("expected 1 item, got %d", pq.Len())
+	}
+}
+
+func TestPriorityQueue_Concurrent(t *testing.T) {
+	pq := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pq.Push(&Item{Key: fmt.Sprintf("item-%d", i), Priority: int64(i % 7)})
+		}(i)
+	}
+	wg.Wait()
+
+	if pq.Len() != 100 {
+		t.Fatalf("expected 100 items, got %d", pq.Len())
+	}
+}
@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+)
+
+const (
+	// rotationTickInterval is how often the queue is checked for credentials
+	// that are due for rotation
+	rotationTickInterval = 5 * time.Second
+
+	// rotationRetryInterval is how long to wait before retrying a failed
+	// rotation
+	rotationRetryInterval = 10 * time.Second
+)
+
+// RotateFunc rotates the credentials with the given key and returns the time
+// of their next rotation. A zero time is returned if the credentials no
+// longer exist, which drops them from the queue.
+type RotateFunc func(ctx context.Context, key string) (time.Time, error)
+
+// RotationQueue schedules the rotation of credentials, such as the passwords
+// of static roles, and rotates each of them when it is due
+type RotationQueue struct {
+	queue  *PriorityQueue
+	rotate RotateFunc
+	logger log.Logger
+
+	lock   sync.Mutex
+	cancel context.CancelFunc
+}
+
+// NewRotationQueue returns an empty RotationQueue rotating credentials with
+// the given function
+func NewRotationQueue(logger log.Logger, rotate RotateFunc) *RotationQueue {
+	return &RotationQueue{
+		queue:  New(),
+		rotate: rotate,
+		logger: logger,
+	}
+}
+
+// Push schedules the rotation of the credentials with the given key,
+// replacing any earlier schedule
+func (q *RotationQueue) Push(key string, next time.Time) error {
+	return q.queue.Replace(&Item{
+		Key:      key,
+		Priority: next.Unix(),
+	})
+}
+
+// Remove drops the credentials with the given key from the queue
+func (q *RotationQueue) Remove(key string) error {
+	_, err := q.queue.PopByKey(key)
+	return err
+}
+
+// Start calls load, which pushes the credentials to rotate, and then rotates
+// them as they are due until Stop is called
+func (q *RotationQueue) Start(load func(context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	q.lock.Lock()
+	q.cancel = cancel
+	q.lock.Unlock()
+
+	go func() {
+		if err := load(ctx); err != nil {
+			q.logger.Error("error loading credentials into the rotation queue", "error", err)
+		}
+		q.run(ctx)
+	}()
+}
+
+// Stop stops rotating credentials, if started
+func (q *RotationQueue) Stop() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.cancel != nil {
+		q.cancel()
+	}
+}
+
+func (q *RotationQueue) run(ctx context.Context) {
+	tick := time.NewTicker(rotationTickInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			q.rotateDue(ctx)
+		}
+	}
+}
+
+// rotateDue rotates all credentials that are due
+func (q *RotationQueue) rotateDue(ctx context.Context) {
+	now := time.Now()
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		item, err := q.queue.Pop()
+		if err != nil {
+			// Queue is empty
+			return
+		}
+
+		if item.Priority > now.Unix() {
+			// Not due yet, and neither is anything after it. If the
+			// credentials were pushed again in the meantime, that schedule
+			// wins.
+			if err := q.queue.Push(item); err != nil && err != ErrDuplicateItem {
+				q.logger.Error("error re-queueing credentials", "key", item.Key, "error", err)
+			}
+			return
+		}
+
+		next, err := q.rotate(ctx, item.Key)
+		switch {
+		case err != nil:
+			q.logger.Error("error rotating credentials", "key", item.Key, "error", err)
+			next = time.Now().Add(rotationRetryInterval)
+		case next.IsZero():
+			// The credentials were deleted
+			continue
+		}
+
+		if err := q.Push(item.Key, next); err != nil {
+			q.logger.Error("error re-queueing credentials", "key", item.Key, "error", err)
+		}
+	}
+}
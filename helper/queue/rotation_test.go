@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+)
+
+func TestRotationQueue_RotateDue(t *testing.T) {
+	now := time.Now()
+	next := now.Add(time.Hour)
+
+	var rotated []string
+	q := NewRotationQueue(log.NewNullLogger(), func(ctx context.Context, key string) (time.Time, error) {
+		rotated = append(rotated, key)
+		switch key {
+		case "failing":
+			return time.Time{}, errors.New("rotation failed")
+		case "deleted":
+			return time.Time{}, nil
+		}
+		return next, nil
+	})
+
+	for key, due := range map[string]time.Time{
+		"due":     now.Add(-time.Minute),
+		"failing": now.Add(-2 * time.Minute),
+		"deleted": now.Add(-3 * time.Minute),
+		"later":   now.Add(time.Minute),
+	} {
+		if err := q.Push(key, due); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q.rotateDue(context.Background())
+
+	if len(rotated) != 3 || rotated[0] != "deleted" || rotated[1] != "failing" || rotated[2] != "due" {
+		t.Fatalf("bad: %v", rotated)
+	}
+	if q.queue.Len() != 3 {
+		t.Fatalf("expected 3 items, got %d", q.queue.Len())
+	}
+
+	// Failed rotations are retried shortly, successful ones at their next
+	// rotation
+	item, err := q.queue.Pop()
+	if err != nil || item.Key != "failing" || item.Priority > now.Add(rotationRetryInterval+time.Second).Unix() {
+		t.Fatalf("bad: %#v %v", item, err)
+	}
+	item, err = q.queue.Pop()
+	if err != nil || item.Key != "later" {
+		t.Fatalf("bad: %#v %v", item, err)
+	}
+	item, err = q.queue.Pop()
+	if err != nil || item.Key != "due" || item.Priority != next.Unix() {
+		t.Fatalf("bad: %#v %v", item, err)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/hashicorp/vault/api"
 	credCert "github.com/hashicorp/vault/builtin/credential/cert"
 	"github.com/hashicorp/vault/builtin/logical/transit"
+	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/vault"
@@ -577,3 +578,80 @@ func TestHTTP_Forwarding_HelpOperation(t *testing.T) {
 	testHelp(cores[0].Client)
 	testHelp(cores[1].Client)
 }
+
+func TestHTTP_PerformanceStandby_ReadAfterWrite(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"kv": vault.PassthroughBackendFactory,
+		},
+		PerformanceStandby: true,
+	}
+
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+	cores := cluster.Cores
+
+	vault.TestWaitActive(t, cores[0].Core)
+
+	// Requesting the leader sets up the connection to the active node
+	standby := cores[1].Core
+	deadline := time.Now().Add(30 * time.Second)
+	for !standby.PerfStandby() {
+		if time.Now().After(deadline) {
+			t.Fatal("standby did not become a performance standby")
+		}
+		standby.Leader()
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	config := api.DefaultConfig()
+	config.Address = fmt.Sprintf("https://127.0.0.1:%d", cores[1].Listeners[0].Address.Port)
+	config.HttpClient.Transport.(*http.Transport).TLSClientConfig = cores[1].TLSConfig
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken(cluster.RootToken)
+
+	for i := 0; i < 20; i++ {
+		value := fmt.Sprintf("bar%d", i)
+
+		// Writes are forwarded, returning the index of the active node
+		req := client.NewRequest("PUT", "/v1/secret/foo")
+		if err := req.SetJSONBody(map[string]interface{}{"value": value}); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.RawRequest(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		index := resp.Header.Get(consts.WALIndexHeaderName)
+		if index == "" {
+			t.Fatal("expected index header on write response")
+		}
+
+		// Reads sending the index always see the write
+		req = client.NewRequest("GET", "/v1/secret/foo")
+		req.Headers = http.Header{}
+		req.Headers.Set(consts.WALIndexHeaderName, index)
+		resp, err = client.RawRequest(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Header.Get(consts.WALIndexHeaderName) == "" {
+			t.Fatal("expected index header on read response")
+		}
+		secret, err := api.ParseSecret(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret.Data["value"] != value {
+			t.Fatalf("bad: expected %q, got %v", value, secret.Data["value"])
+		}
+	}
+}
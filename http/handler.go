@@ -1,12 +1,16 @@
 package http
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -85,8 +89,8 @@ func Handler(core *vault.Core) http.Handler {
 	for _, path := range injectDataIntoTopRoutes {
 		mux.Handle(path, handleRequestForwarding(core, handleLogical(core, true, nil)))
 	}
-	mux.Handle("/v1/sys/", handleRequestForwarding(core, handleLogical(core, false, nil)))
-	mux.Handle("/v1/", handleRequestForwarding(core, handleLogical(core, false, nil)))
+	mux.Handle("/v1/sys/", handlePerfStandbyRequest(core, handleLogical(core, false, nil)))
+	mux.Handle("/v1/", handlePerfStandbyRequest(core, handleLogical(core, false, nil)))
 	if core.UIEnabled() == true {
 		if uiBuiltIn {
 			mux.Handle("/ui/", http.StripPrefix("/ui/", handleUIHeaders(core, handleUI(http.FileServer(&UIAssetWrapper{FileSystem: assetFS()})))))
@@ -275,31 +279,71 @@ func handleRequestForwarding(core *vault.Core, handler http.Handler) http.Handle
 			return
 		}
 
-		// Attempt forwarding the request. If we cannot forward -- perhaps it's
-		// been disabled on the active node -- this will return with an
-		// ErrCannotForward and we simply fall back
-		statusCode, header, retBytes, err := core.ForwardRequest(r)
-		if err != nil {
-			if err == vault.ErrCannotForward {
-				core.Logger().Debug("handleRequestForwarding: cannot forward (possibly disabled on active node), falling back")
-			} else {
-				core.Logger().Error("handleRequestForwarding: error forwarding request", "error", err)
-			}
-
+		if !forwardRequest(core, w, r) {
 			// Fall back to redirection
 			handler.ServeHTTP(w, r)
+		}
+	})
+}
+
+// forwardRequest attempts forwarding the request to the active node, writing
+// its response. If we cannot forward -- perhaps it's been disabled on the
+// active node -- this returns false and the caller should fall back.
+func forwardRequest(core *vault.Core, w http.ResponseWriter, r *http.Request) bool {
+	statusCode, header, retBytes, err := core.ForwardRequest(r)
+	if err != nil {
+		if err == vault.ErrCannotForward {
+			core.Logger().Debug("handleRequestForwarding: cannot forward (possibly disabled on active node), falling back")
+		} else {
+			core.Logger().Error("handleRequestForwarding: error forwarding request", "error", err)
+		}
+		return false
+	}
+
+	if header != nil {
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+	}
+
+	w.WriteHeader(statusCode)
+	w.Write(retBytes)
+	return true
+}
+
+// handlePerfStandbyRequest serves requests locally when this node is a
+// performance standby, and otherwise behaves like handleRequestForwarding.
+// Requests that turn out to need the active node are forwarded by request.
+func handlePerfStandbyRequest(core *vault.Core, handler http.Handler) http.Handler {
+	forwardingHandler := handleRequestForwarding(core, handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !core.PerfStandby() || r.Header.Get(vault.IntNoForwardingHeaderName) != "" {
+			forwardingHandler.ServeHTTP(w, r)
 			return
 		}
 
-		if header != nil {
-			for k, v := range header {
-				w.Header()[k] = v
+		// Clients reading their own writes send the index they were given;
+		// if this node has not caught up yet the active node answers
+		if index := r.Header.Get(consts.WALIndexHeaderName); index != "" && !core.WALIndexApplied(index) {
+			if r.Header.Get(NoRequestForwardingHeaderName) != "" || !forwardRequest(core, w, r) {
+				respondStandby(core, w, r.URL)
 			}
+			return
 		}
 
-		w.WriteHeader(statusCode)
-		w.Write(retBytes)
-		return
+		// Buffer the body so that it can be sent again if the request has
+		// to be forwarded after all
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestSize))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+
+		handler.ServeHTTP(w, r)
 	})
 }
 
@@ -311,6 +355,29 @@ func request(core *vault.Core, w http.ResponseWriter, rawReq *http.Request, r *l
 		respondStandby(core, w, rawReq.URL)
 		return resp, false
 	}
+	if errwrap.Contains(err, consts.ErrPerfStandbyPleaseForward.Error()) {
+		if rawReq.GetBody != nil {
+			body, err := rawReq.GetBody()
+			if err != nil {
+				respondError(w, http.StatusInternalServerError, err)
+				return resp, false
+			}
+			rawReq.Body = body
+		}
+		if rawReq.Header.Get(NoRequestForwardingHeaderName) != "" || !forwardRequest(core, w, rawReq) {
+			respondStandby(core, w, rawReq.URL)
+		}
+		return resp, false
+	}
+
+	// Return the storage WAL index the response reflects, which clients can
+	// send along with later reads to see the effects of this request
+	if index := core.WALIndex(); index != "" {
+		w.Header().Set(consts.WALIndexHeaderName, index)
+	}
+	if quotaErr, ok := errwrap.GetType(err, new(logical.RequestQuotaError)).(*logical.RequestQuotaError); ok && quotaErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(quotaErr.RetryAfter.Seconds()))))
+	}
 	if respondErrorCommon(w, r, resp, err) {
 		return resp, false
 	}
@@ -411,6 +478,22 @@ func requestWrapInfo(r *http.Request, req *logical.Request) (*logical.Request, e
 	return req, nil
 }
 
+// requestPolicyOverride sets the PolicyOverride value of the logical.Request
+// if the header requesting it is set
+func requestPolicyOverride(r *http.Request, req *logical.Request) (*logical.Request, error) {
+	raw := r.Header.Get(PolicyOverrideHeaderName)
+	if raw == "" {
+		return req, nil
+	}
+
+	override, err := parseutil.ParseBool(raw)
+	if err != nil {
+		return req, err
+	}
+	req.PolicyOverride = override
+	return req, nil
+}
+
 func respondError(w http.ResponseWriter, status int, err error) {
 	logical.AdjustErrorStatusCode(&status, err)
 
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"strconv"
@@ -12,6 +13,8 @@ import (
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/vault"
 )
@@ -28,6 +31,9 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 		return nil, http.StatusNotFound, nil
 	}
 
+	// Paths are relative to the namespace of the request, if any
+	path = namespace.Canonicalize(r.Header.Get(consts.NamespaceHeaderName)) + path
+
 	// Determine the operation
 	var op logical.Operation
 	switch r.Method {
@@ -51,6 +57,8 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 		op = logical.UpdateOperation
 	case "LIST":
 		op = logical.ListOperation
+	case "HEAD":
+		op = logical.HeaderOperation
 	case "OPTIONS":
 	default:
 		return nil, http.StatusMethodNotAllowed, nil
@@ -65,13 +73,25 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 	// Parse the request if we can
 	var data map[string]interface{}
 	if op == logical.UpdateOperation {
-		err := parseRequest(r, w, &data)
-		if err == io.EOF {
-			data = nil
-			err = nil
-		}
-		if err != nil {
-			return nil, http.StatusBadRequest, err
+		// OCSP and EST requests carry a DER-encoded body (base64-encoded for
+		// EST) which is passed through unparsed
+		if contentType := r.Header.Get("Content-Type"); contentType == "application/ocsp-request" || strings.HasPrefix(contentType, "application/pkcs10") {
+			body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestSize))
+			if err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+			data = map[string]interface{}{
+				logical.HTTPRawBody: body,
+			}
+		} else {
+			err := parseRequest(r, w, &data)
+			if err == io.EOF {
+				data = nil
+				err = nil
+			}
+			if err != nil {
+				return nil, http.StatusBadRequest, err
+			}
 		}
 	}
 
@@ -119,6 +139,11 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 		return nil, http.StatusBadRequest, errwrap.Wrapf("error parsing X-Vault-Wrap-TTL header: {{err}}", err)
 	}
 
+	req, err = requestPolicyOverride(r, req)
+	if err != nil {
+		return nil, http.StatusBadRequest, errwrap.Wrapf("error parsing X-Vault-Policy-Override header: {{err}}", err)
+	}
+
 	return req, 0, nil
 }
 
@@ -289,6 +314,11 @@ func respondRaw(w http.ResponseWriter, r *http.Request, resp *logical.Response)
 	}
 
 	// Write the response
+	for k, vals := range resp.Headers {
+		for _, v := range vals {
+			w.Header().Add(k, v)
+		}
+	}
 	if contentType != "" {
 		w.Header().Set("Content-Type", contentType)
 	}
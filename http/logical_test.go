@@ -161,6 +161,7 @@ func TestLogical_StandbyRedirect(t *testing.T) {
 			"explicit_max_ttl": json.Number("0"),
 			"expire_time":      nil,
 			"entity_id":        "",
+			"type":             "service",
 		},
 		"warnings":  nilWarnings,
 		"wrap_info": nil,
@@ -304,6 +305,23 @@ func TestLogical_ListSuffix(t *testing.T) {
 	}
 }
 
+func TestLogical_Namespace(t *testing.T) {
+	core, _, _ := vault.TestCoreUnsealed(t)
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8200/v1/secret/foo", nil)
+	req.Header.Set("X-Vault-Namespace", "/ns1/ns2")
+	lreq, status, err := buildLogicalRequest(core, nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 0 {
+		t.Fatalf("got status %d", status)
+	}
+	if lreq.Path != "ns1/ns2/secret/foo" {
+		t.Fatalf("bad path: %q", lreq.Path)
+	}
+}
+
 func TestLogical_RespondWithStatusCode(t *testing.T) {
 	resp := &logical.Response{
 		Data: map[string]interface{}{
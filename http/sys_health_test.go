@@ -29,6 +29,7 @@ func TestSysHealth_get(t *testing.T) {
 		"initialized":                  false,
 		"sealed":                       true,
 		"standby":                      true,
+		"performance_standby":          false,
 	}
 	testResponseStatus(t, resp, 501)
 	testResponseBody(t, resp, &actual)
@@ -61,6 +62,7 @@ func TestSysHealth_get(t *testing.T) {
 		"initialized":                  true,
 		"sealed":                       true,
 		"standby":                      true,
+		"performance_standby":          false,
 	}
 	testResponseStatus(t, resp, 503)
 	testResponseBody(t, resp, &actual)
@@ -97,6 +99,7 @@ func TestSysHealth_get(t *testing.T) {
 		"initialized":                  true,
 		"sealed":                       false,
 		"standby":                      false,
+		"performance_standby":          false,
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
@@ -139,6 +142,7 @@ func TestSysHealth_customcodes(t *testing.T) {
 		"initialized":                  false,
 		"sealed":                       true,
 		"standby":                      true,
+		"performance_standby":          false,
 	}
 	testResponseStatus(t, resp, 581)
 	testResponseBody(t, resp, &actual)
@@ -172,6 +176,7 @@ func TestSysHealth_customcodes(t *testing.T) {
 		"initialized":                  true,
 		"sealed":                       true,
 		"standby":                      true,
+		"performance_standby":          false,
 	}
 	testResponseStatus(t, resp, 523)
 	testResponseBody(t, resp, &actual)
@@ -209,6 +214,7 @@ func TestSysHealth_customcodes(t *testing.T) {
 		"initialized":                  true,
 		"sealed":                       false,
 		"standby":                      false,
+		"performance_standby":          false,
 	}
 	testResponseStatus(t, resp, 202)
 	testResponseBody(t, resp, &actual)
@@ -26,12 +26,14 @@ func TestSysSealStatus(t *testing.T) {
 
 	var actual map[string]interface{}
 	expected := map[string]interface{}{
-		"sealed":   true,
-		"t":        json.Number("3"),
-		"n":        json.Number("3"),
-		"progress": json.Number("0"),
-		"nonce":    "",
-		"type":     "shamir",
+		"sealed":        true,
+		"t":             json.Number("3"),
+		"n":             json.Number("3"),
+		"progress":      json.Number("0"),
+		"nonce":         "",
+		"type":          "shamir",
+		"recovery_seal": false,
+		"migration":     false,
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
@@ -115,12 +117,14 @@ func TestSysUnseal(t *testing.T) {
 
 		var actual map[string]interface{}
 		expected := map[string]interface{}{
-			"sealed":   true,
-			"t":        json.Number("3"),
-			"n":        json.Number("3"),
-			"progress": json.Number(fmt.Sprintf("%d", i+1)),
-			"nonce":    "",
-			"type":     "shamir",
+			"sealed":        true,
+			"t":             json.Number("3"),
+			"n":             json.Number("3"),
+			"progress":      json.Number(fmt.Sprintf("%d", i+1)),
+			"nonce":         "",
+			"type":          "shamir",
+			"recovery_seal": false,
+			"migration":     false,
 		}
 		if i == len(keys)-1 {
 			expected["sealed"] = false
@@ -194,11 +198,13 @@ func TestSysUnseal_Reset(t *testing.T) {
 
 		var actual map[string]interface{}
 		expected := map[string]interface{}{
-			"sealed":   true,
-			"t":        json.Number("3"),
-			"n":        json.Number("5"),
-			"progress": json.Number(strconv.Itoa(i + 1)),
-			"type":     "shamir",
+			"sealed":        true,
+			"t":             json.Number("3"),
+			"n":             json.Number("5"),
+			"progress":      json.Number(strconv.Itoa(i + 1)),
+			"type":          "shamir",
+			"recovery_seal": false,
+			"migration":     false,
 		}
 		testResponseStatus(t, resp, 200)
 		testResponseBody(t, resp, &actual)
@@ -231,11 +237,13 @@ func TestSysUnseal_Reset(t *testing.T) {
 
 	actual = map[string]interface{}{}
 	expected := map[string]interface{}{
-		"sealed":   true,
-		"t":        json.Number("3"),
-		"n":        json.Number("5"),
-		"progress": json.Number("0"),
-		"type":     "shamir",
+		"sealed":        true,
+		"t":             json.Number("3"),
+		"n":             json.Number("5"),
+		"progress":      json.Number("0"),
+		"type":          "shamir",
+		"recovery_seal": false,
+		"migration":     false,
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
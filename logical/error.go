@@ -1,5 +1,10 @@
 package logical
 
+import (
+	"net/http"
+	"time"
+)
+
 type HTTPCodedError interface {
 	Error() string
 	Code() int
@@ -48,3 +53,19 @@ type ReplicationCodedError struct {
 func (r *ReplicationCodedError) Error() string {
 	return r.Msg
 }
+
+// RequestQuotaError is returned when a request is rejected because it would
+// exceed a quota. RetryAfter, if set, is how long the client should wait
+// before retrying the request.
+type RequestQuotaError struct {
+	Msg        string
+	RetryAfter time.Duration
+}
+
+func (e *RequestQuotaError) Error() string {
+	return e.Msg
+}
+
+func (e *RequestQuotaError) Code() int {
+	return http.StatusTooManyRequests
+}
@@ -14,6 +14,7 @@ import (
 	log "github.com/hashicorp/go-hclog"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/logging"
 	"github.com/hashicorp/vault/helper/parseutil"
@@ -207,6 +208,12 @@ func (b *Backend) HandleRequest(ctx context.Context, req *logical.Request) (*log
 		return nil, logical.ErrUnsupportedOperation
 	}
 
+	// Paths with side effects are handled by the active node only
+	if path.ForwardPerformanceStandby && req.Operation != logical.HelpOperation &&
+		b.System() != nil && b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		return nil, consts.ErrPerfStandbyPleaseForward
+	}
+
 	fd := FieldData{
 		Raw:    raw,
 		Schema: path.Fields}
@@ -563,6 +570,8 @@ func (t FieldType) Zero() interface{} {
 		return ""
 	case TypeInt:
 		return 0
+	case TypeFloat:
+		return 0.0
 	case TypeBool:
 		return false
 	case TypeMap:
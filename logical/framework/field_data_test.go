@@ -66,6 +66,28 @@ func TestFieldDataGet(t *testing.T) {
 			42,
 		},
 
+		"float type, float value": {
+			map[string]*FieldSchema{
+				"foo": &FieldSchema{Type: TypeFloat},
+			},
+			map[string]interface{}{
+				"foo": 2.5,
+			},
+			"foo",
+			2.5,
+		},
+
+		"float type, string value": {
+			map[string]*FieldSchema{
+				"foo": &FieldSchema{Type: TypeFloat},
+			},
+			map[string]interface{}{
+				"foo": "0.25",
+			},
+			"foo",
+			0.25,
+		},
+
 		"bool type, bool value": {
 			map[string]*FieldSchema{
 				"foo": &FieldSchema{Type: TypeBool},
@@ -440,6 +462,15 @@ func TestFieldDataGet(t *testing.T) {
 			0,
 		},
 
+		"type float, not supplied": {
+			map[string]*FieldSchema{
+				"foo": {Type: TypeFloat},
+			},
+			map[string]interface{}{},
+			"foo",
+			0.0,
+		},
+
 		"type bool, not supplied": {
 			map[string]*FieldSchema{
 				"foo": {Type: TypeBool},
@@ -38,6 +38,9 @@ const (
 	// TypeCommaIntSlice is a helper for TypeSlice that returns a sanitized
 	// slice of Ints
 	TypeCommaIntSlice
+
+	// TypeFloat represents a 64-bit floating point number
+	TypeFloat
 )
 
 func (t FieldType) String() string {
@@ -48,6 +51,8 @@ func (t FieldType) String() string {
 		return "name string"
 	case TypeInt:
 		return "int"
+	case TypeFloat:
+		return "float"
 	case TypeBool:
 		return "bool"
 	case TypeMap:
@@ -68,6 +68,12 @@ type Path struct {
 	// must have UpdateCapability on the path.
 	ExistenceCheck ExistenceFunc
 
+	// ForwardPerformanceStandby, if set, causes requests to this path
+	// received by a performance standby to be forwarded to the active node.
+	// This should be set on paths whose reads have side effects, such as
+	// creating leased credentials in an external system.
+	ForwardPerformanceStandby bool
+
 	// Help is text describing how to use this path. This will be used
 	// to auto-generate the help operation. The Path will automatically
 	// generate a parameter listing and URL structure based on the
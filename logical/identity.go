@@ -14,3 +14,19 @@ type Alias struct {
 	// Name is the identifier of this identity in its authentication source
 	Name string `json:"name" structs:"name" mapstructure:"name"`
 }
+
+// Entity represents the identity of a client, along with the aliases it is
+// known by in the authentication backends.
+type Entity struct {
+	// ID is the unique identifier of the entity
+	ID string `json:"id" structs:"id" mapstructure:"id"`
+
+	// Name is the name of the entity
+	Name string `json:"name" structs:"name" mapstructure:"name"`
+
+	// Aliases are the identities of the entity in the authentication backends
+	Aliases []*Alias `json:"aliases" structs:"aliases" mapstructure:"aliases"`
+
+	// Metadata is the metadata associated with the entity
+	Metadata map[string]string `json:"metadata" structs:"metadata" mapstructure:"metadata"`
+}
@@ -114,6 +114,10 @@ func (s *gRPCSystemViewClient) LookupPlugin(ctx context.Context, name string) (*
 	return nil, fmt.Errorf("cannot call LookupPlugin from a plugin backend")
 }
 
+func (s *gRPCSystemViewClient) EntityInfo(entityID string) (*logical.Entity, error) {
+	return nil, fmt.Errorf("cannot call EntityInfo from a plugin backend")
+}
+
 func (s *gRPCSystemViewClient) MlockEnabled() bool {
 	reply, err := s.client.MlockEnabled(context.Background(), &pb.Empty{})
 	if err != nil {
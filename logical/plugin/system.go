@@ -109,6 +109,10 @@ func (s *SystemViewClient) LookupPlugin(ctx context.Context, name string) (*plug
 	return nil, fmt.Errorf("cannot call LookupPlugin from a plugin backend")
 }
 
+func (s *SystemViewClient) EntityInfo(entityID string) (*logical.Entity, error) {
+	return nil, fmt.Errorf("cannot call EntityInfo from a plugin backend")
+}
+
 func (s *SystemViewClient) MlockEnabled() bool {
 	var reply MlockEnabledReply
 	err := s.client.Call("Plugin.MlockEnabled", new(interface{}), &reply)
@@ -252,6 +252,10 @@ const (
 	HelpOperation                     = "help"
 	AliasLookaheadOperation           = "alias-lookahead"
 
+	// HeaderOperation is an HTTP HEAD request. Only paths that explicitly
+	// register a callback for it will respond, with headers only.
+	HeaderOperation = "header"
+
 	// The operations below are called globally, the path is less relevant.
 	RevokeOperation   Operation = "revoke"
 	RenewOperation              = "renew"
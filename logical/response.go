@@ -18,6 +18,9 @@ const (
 	// HTTPRawBody is the raw content of the HTTP body that goes with the HTTPContentType.
 	// This can only be specified for non-secrets, and should should be similarly
 	// avoided like the HTTPContentType. The value must be a byte slice.
+	// Requests with a content type of application/ocsp-request or
+	// application/pkcs10 also carry their unparsed body in the request Data
+	// under this key.
 	HTTPRawBody = "http_raw_body"
 
 	// HTTPStatusCode is the response code of the HTTP body that goes with the HTTPContentType.
@@ -54,6 +57,11 @@ type Response struct {
 
 	// Information for wrapping the response in a cubbyhole
 	WrapInfo *wrapping.ResponseWrapInfo `json:"wrap_info" structs:"wrap_info" mapstructure:"wrap_info"`
+
+	// Headers are additional HTTP headers to set on a raw response. Like the
+	// HTTPContentType, this should be avoided unless absolutely necessary,
+	// such as implementing a specification.
+	Headers map[string][]string `json:"headers" structs:"headers" mapstructure:"headers"`
 }
 
 // AddWarning adds a warning into the response's warning list
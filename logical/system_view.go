@@ -57,6 +57,10 @@ type SystemView interface {
 	// MlockEnabled returns the configuration setting for enabling mlock on
 	// plugins.
 	MlockEnabled() bool
+
+	// EntityInfo returns the entity with the given ID, or nil if there is no
+	// such entity.
+	EntityInfo(entityID string) (*Entity, error)
 }
 
 type StaticSystemView struct {
@@ -69,6 +73,7 @@ type StaticSystemView struct {
 	EnableMlock         bool
 	LocalMountVal       bool
 	ReplicationStateVal consts.ReplicationState
+	EntityVal           *Entity
 }
 
 func (d StaticSystemView) DefaultLeaseTTL() time.Duration {
@@ -110,3 +115,10 @@ func (d StaticSystemView) LookupPlugin(_ context.Context, name string) (*pluginu
 func (d StaticSystemView) MlockEnabled() bool {
 	return d.EnableMlock
 }
+
+func (d StaticSystemView) EntityInfo(entityID string) (*Entity, error) {
+	if d.EntityVal == nil || d.EntityVal.ID != entityID {
+		return nil, nil
+	}
+	return d.EntityVal, nil
+}
@@ -0,0 +1,50 @@
+package logical
+
+import (
+	"fmt"
+)
+
+// TokenType is the type of a token, which decides how Vault tracks it
+type TokenType uint8
+
+const (
+	// TokenTypeDefault leaves the choice of the type to the request or, if
+	// not given there either, results in a service token
+	TokenTypeDefault TokenType = iota
+
+	// TokenTypeService is a token that is persisted in storage and tracked
+	// by the expiration manager. It can be renewed, revoked and have child
+	// tokens.
+	TokenTypeService
+
+	// TokenTypeBatch is a token that carries its own information, encrypted
+	// by the barrier. It is never persisted, so it can't be renewed or
+	// revoked and expires at the end of its TTL.
+	TokenTypeBatch
+)
+
+func (t TokenType) String() string {
+	switch t {
+	case TokenTypeService:
+		return "service"
+	case TokenTypeBatch:
+		return "batch"
+	default:
+		return "default"
+	}
+}
+
+// ParseTokenType returns the token type of the given name. An empty name is
+// the default type.
+func ParseTokenType(s string) (TokenType, error) {
+	switch s {
+	case "", "default":
+		return TokenTypeDefault, nil
+	case "service":
+		return TokenTypeService, nil
+	case "batch":
+		return TokenTypeBatch, nil
+	default:
+		return TokenTypeDefault, fmt.Errorf("invalid token type %q", s)
+	}
+}
@@ -89,6 +89,16 @@ func (c *Cache) Purge(ctx context.Context) {
 	c.lru.Purge()
 }
 
+// Invalidate is used to remove a single key from the cache, for instance
+// when it was written by another node sharing the underlying backend
+func (c *Cache) Invalidate(ctx context.Context, key string) {
+	lock := locksutil.LockForKey(c.locks, key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	c.lru.Remove(key)
+}
+
 func (c *Cache) Put(ctx context.Context, entry *Entry) error {
 	if atomic.LoadUint32(c.enabled) == 0 {
 		return c.backend.Put(ctx, entry)
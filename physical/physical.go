@@ -61,6 +61,7 @@ type HABackend interface {
 // cache, don't use it for other things.
 type ToggleablePurgemonster interface {
 	Purge(ctx context.Context)
+	Invalidate(ctx context.Context, key string)
 	SetEnabled(bool)
 }
 
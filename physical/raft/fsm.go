@@ -0,0 +1,379 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/errwrap"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/physical"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	deleteOp uint32 = 1 << iota
+	putOp
+	lockPutOp
+	lockDeleteOp
+)
+
+var (
+	// dataBucketName is the bolt bucket holding the Vault data replicated
+	// through the raft log.
+	dataBucketName = []byte("data")
+
+	// lockBucketName is the bolt bucket holding the values of HA locks. It is
+	// kept separate from the data bucket so lock entries are never visible
+	// to Vault through List.
+	lockBucketName = []byte("locks")
+)
+
+// Verify FSM satisfies the correct interfaces
+var _ raft.FSM = (*FSM)(nil)
+
+// LogOperation represents a single operation carried in a raft log entry.
+type LogOperation struct {
+	OpType uint32 `json:"op_type"`
+	Key    string `json:"key"`
+	Value  []byte `json:"value,omitempty"`
+}
+
+// LogData is the payload of a raft log entry. All operations in a single
+// entry are applied to the FSM atomically.
+type LogData struct {
+	Operations []*LogOperation `json:"operations"`
+}
+
+// FSM is the raft finite state machine. It stores the applied Vault data in a
+// bolt database which can be read directly on any node in the cluster. Writes
+// must go through the raft log.
+type FSM struct {
+	l      sync.RWMutex
+	path   string
+	logger log.Logger
+
+	// snapshotLock is held for reading by every snapshot until it is
+	// released, so that Restore never closes the database under the read
+	// transaction of a snapshot still being persisted
+	snapshotLock sync.RWMutex
+
+	db *bolt.DB
+}
+
+// NewFSM constructs an FSM backed by a bolt database in the given directory.
+func NewFSM(path string, logger log.Logger) (*FSM, error) {
+	dbPath := filepath.Join(path, "vault.db")
+
+	db, err := bolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to open bolt file: {{err}}", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{dataBucketName, lockBucketName} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errwrap.Wrapf("failed to create bolt buckets: {{err}}", err)
+	}
+
+	return &FSM{
+		path:   path,
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// Close closes the underlying bolt database.
+func (f *FSM) Close() error {
+	f.l.Lock()
+	defer f.l.Unlock()
+
+	return f.db.Close()
+}
+
+// Get is used to fetch an entry from the local state.
+func (f *FSM) Get(ctx context.Context, key string) (*physical.Entry, error) {
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	var entry *physical.Entry
+	err := f.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(dataBucketName).Get([]byte(key))
+		if value == nil {
+			return nil
+		}
+
+		// Values returned by bolt are only valid for the life of the
+		// transaction so they must be copied out
+		entry = &physical.Entry{
+			Key:   key,
+			Value: append([]byte(nil), value...),
+		}
+		return nil
+	})
+
+	return entry, err
+}
+
+// List is used to list all the keys under a given prefix, up to the next
+// prefix.
+func (f *FSM) List(ctx context.Context, prefix string) ([]string, error) {
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	var keys []string
+	err := f.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(dataBucketName).Cursor()
+
+		prefixBytes := []byte(prefix)
+		for k, _ := c.Seek(prefixBytes); k != nil && bytes.HasPrefix(k, prefixBytes); k, _ = c.Next() {
+			key := strings.TrimPrefix(string(k), prefix)
+			if i := strings.Index(key, "/"); i == -1 {
+				keys = append(keys, key)
+			} else {
+				// Add the folder and skip past every key under it
+				folder := key[:i+1]
+				keys = append(keys, folder)
+				c.Seek(append([]byte(prefix+folder), 0xff))
+				k, _ = c.Prev()
+			}
+		}
+		return nil
+	})
+
+	return keys, err
+}
+
+func (f *FSM) applyOperations(ops []*LogOperation) error {
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	return f.db.Update(func(tx *bolt.Tx) error {
+		data := tx.Bucket(dataBucketName)
+		locks := tx.Bucket(lockBucketName)
+
+		for _, op := range ops {
+			var err error
+			switch op.OpType {
+			case putOp:
+				err = data.Put([]byte(op.Key), op.Value)
+			case deleteOp:
+				err = data.Delete([]byte(op.Key))
+			case lockPutOp:
+				err = locks.Put([]byte(op.Key), op.Value)
+			case lockDeleteOp:
+				err = locks.Delete([]byte(op.Key))
+			default:
+				err = fmt.Errorf("%d is not a supported operation type", op.OpType)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// lockValue returns the value currently stored for the given HA lock key.
+func (f *FSM) lockValue(key string) (string, bool, error) {
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	var value []byte
+	err := f.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(lockBucketName).Get([]byte(key)); v != nil {
+			value = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	return string(value), value != nil, nil
+}
+
+// Apply is called by raft once a log entry is committed. It decodes the
+// operations in the entry and applies them to the local state.
+func (f *FSM) Apply(l *raft.Log) interface{} {
+	// Configuration changes and no-ops do not carry data
+	if l.Type != raft.LogCommand {
+		return nil
+	}
+
+	var data LogData
+	if err := jsonutil.DecodeJSON(l.Data, &data); err != nil {
+		f.logger.Error("failed to decode log entry", "index", l.Index, "error", err)
+		return err
+	}
+
+	if err := f.applyOperations(data.Operations); err != nil {
+		f.logger.Error("failed to apply log entry", "index", l.Index, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Snapshot returns a point in time view of the data for raft to persist. The
+// bolt read transaction is held open until the snapshot is released so that
+// writes may continue while the snapshot is being written out.
+func (f *FSM) Snapshot() (raft.FSMSnapshot, error) {
+	f.snapshotLock.RLock()
+
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	tx, err := f.db.Begin(false)
+	if err != nil {
+		f.snapshotLock.RUnlock()
+		return nil, err
+	}
+
+	return &fsmSnapshot{
+		tx:      tx,
+		release: f.snapshotLock.RUnlock,
+	}, nil
+}
+
+// Restore replaces the local state with the contents of the given snapshot.
+func (f *FSM) Restore(r io.ReadCloser) error {
+	defer r.Close()
+
+	// Wait for the snapshots being persisted to be released
+	f.snapshotLock.Lock()
+	defer f.snapshotLock.Unlock()
+
+	f.l.Lock()
+	defer f.l.Unlock()
+
+	// Write the restored data into a fresh database and swap it into place
+	// so a failed restore leaves the existing state untouched
+	dbPath := filepath.Join(f.path, "vault.db")
+	restorePath := dbPath + ".restore"
+	os.Remove(restorePath)
+
+	restoreDB, err := bolt.Open(restorePath, 0600, nil)
+	if err != nil {
+		return errwrap.Wrapf("failed to open restore file: {{err}}", err)
+	}
+
+	err = restoreDB.Update(func(tx *bolt.Tx) error {
+		data, err := tx.CreateBucket(dataBucketName)
+		if err != nil {
+			return err
+		}
+		locks, err := tx.CreateBucket(lockBucketName)
+		if err != nil {
+			return err
+		}
+
+		dec := json.NewDecoder(r)
+		for {
+			var entry snapshotEntry
+			err := dec.Decode(&entry)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errwrap.Wrapf("failed to decode snapshot entry: {{err}}", err)
+			}
+
+			bucket := data
+			if entry.Lock {
+				bucket = locks
+			}
+			if err := bucket.Put([]byte(entry.Key), entry.Value); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		restoreDB.Close()
+		os.Remove(restorePath)
+		return err
+	}
+
+	if err := restoreDB.Close(); err != nil {
+		return err
+	}
+	if err := f.db.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(restorePath, dbPath); err != nil {
+		return err
+	}
+
+	f.db, err = bolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		return errwrap.Wrapf("failed to reopen bolt file: {{err}}", err)
+	}
+
+	return nil
+}
+
+// snapshotEntry is a single key in a snapshot. Lock is set for entries from
+// the HA lock bucket.
+type snapshotEntry struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+	Lock  bool   `json:"lock,omitempty"`
+}
+
+// fsmSnapshot streams the contents of a bolt read transaction to a raft
+// snapshot sink as a sequence of JSON encoded entries.
+type fsmSnapshot struct {
+	tx      *bolt.Tx
+	release func()
+	once    sync.Once
+}
+
+// Persist writes the snapshot to the given sink.
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	enc := json.NewEncoder(sink)
+
+	write := func(lock bool) func(k, v []byte) error {
+		return func(k, v []byte) error {
+			return enc.Encode(&snapshotEntry{
+				Key:   string(k),
+				Value: v,
+				Lock:  lock,
+			})
+		}
+	}
+
+	err := s.tx.Bucket(dataBucketName).ForEach(write(false))
+	if err == nil {
+		err = s.tx.Bucket(lockBucketName).ForEach(write(true))
+	}
+	if err != nil {
+		sink.Cancel()
+		return err
+	}
+
+	return sink.Close()
+}
+
+// Release is called when raft is done with the snapshot. The transaction is
+// read-only so there is nothing to commit.
+func (s *fsmSnapshot) Release() {
+	s.once.Do(func() {
+		s.tx.Rollback()
+		s.release()
+	})
+}
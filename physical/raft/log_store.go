@@ -0,0 +1,190 @@
+package raft
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-msgpack/codec"
+	"github.com/hashicorp/raft"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// logsBucketName holds the raft log entries keyed by index.
+	logsBucketName = []byte("logs")
+
+	// stableBucketName holds raft's stable state, such as the current term.
+	stableBucketName = []byte("conf")
+
+	// errKeyNotFound is returned by the stable store for unknown keys, as
+	// raft expects.
+	errKeyNotFound = errors.New("not found")
+)
+
+// Verify logStore satisfies the correct interfaces
+var _ raft.LogStore = (*logStore)(nil)
+var _ raft.StableStore = (*logStore)(nil)
+
+// logStore is a raft LogStore and StableStore persisted in a bolt database.
+type logStore struct {
+	db *bolt.DB
+}
+
+// newLogStore opens or creates the bolt database at the given path.
+func newLogStore(path string) (*logStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to open raft log file: {{err}}", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{logsBucketName, stableBucketName} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &logStore{
+		db: db,
+	}, nil
+}
+
+// Close closes the underlying bolt database.
+func (s *logStore) Close() error {
+	return s.db.Close()
+}
+
+// FirstIndex returns the first index written, or 0 for no entries.
+func (s *logStore) FirstIndex() (uint64, error) {
+	var index uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(logsBucketName).Cursor().First(); k != nil {
+			index = bytesToUint64(k)
+		}
+		return nil
+	})
+	return index, err
+}
+
+// LastIndex returns the last index written, or 0 for no entries.
+func (s *logStore) LastIndex() (uint64, error) {
+	var index uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(logsBucketName).Cursor().Last(); k != nil {
+			index = bytesToUint64(k)
+		}
+		return nil
+	})
+	return index, err
+}
+
+// GetLog gets the log entry at the given index.
+func (s *logStore) GetLog(index uint64, log *raft.Log) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket(logsBucketName).Get(uint64ToBytes(index))
+		if val == nil {
+			return raft.ErrLogNotFound
+		}
+		return decodeMsgPack(val, log)
+	})
+}
+
+// StoreLog stores a single log entry.
+func (s *logStore) StoreLog(log *raft.Log) error {
+	return s.StoreLogs([]*raft.Log{log})
+}
+
+// StoreLogs stores multiple log entries in a single transaction.
+func (s *logStore) StoreLogs(logs []*raft.Log) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(logsBucketName)
+		for _, log := range logs {
+			val, err := encodeMsgPack(log)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(uint64ToBytes(log.Index), val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteRange deletes the log entries between min and max, inclusive.
+func (s *logStore) DeleteRange(min, max uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(logsBucketName).Cursor()
+		for k, _ := c.Seek(uint64ToBytes(min)); k != nil; k, _ = c.Next() {
+			if bytesToUint64(k) > max {
+				break
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Set stores a key in the stable store.
+func (s *logStore) Set(key []byte, val []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(stableBucketName).Put(key, val)
+	})
+}
+
+// Get returns the value for a key in the stable store.
+func (s *logStore) Get(key []byte) ([]byte, error) {
+	var val []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(stableBucketName).Get(key)
+		if v == nil {
+			return errKeyNotFound
+		}
+		val = append([]byte(nil), v...)
+		return nil
+	})
+	return val, err
+}
+
+// SetUint64 stores a uint64 value in the stable store.
+func (s *logStore) SetUint64(key []byte, val uint64) error {
+	return s.Set(key, uint64ToBytes(val))
+}
+
+// GetUint64 returns a uint64 value from the stable store.
+func (s *logStore) GetUint64(key []byte) (uint64, error) {
+	val, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	return bytesToUint64(val), nil
+}
+
+func decodeMsgPack(buf []byte, out interface{}) error {
+	return codec.NewDecoderBytes(buf, &codec.MsgpackHandle{}).Decode(out)
+}
+
+func encodeMsgPack(in interface{}) ([]byte, error) {
+	var buf []byte
+	err := codec.NewEncoderBytes(&buf, &codec.MsgpackHandle{}).Encode(in)
+	return buf, err
+}
+
+func bytesToUint64(b []byte) uint64 {
+	return binary.BigEndian.Uint64(b)
+}
+
+func uint64ToBytes(u uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, u)
+	return buf
+}
@@ -0,0 +1,649 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/errwrap"
+	log "github.com/hashicorp/go-hclog"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/raft"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/physical"
+)
+
+const (
+	// defaultAddress is the address the raft transport binds to if none is
+	// configured.
+	defaultAddress = "127.0.0.1:8202"
+
+	// applyTimeout is the maximum time to wait for a log entry to be
+	// committed before giving up.
+	applyTimeout = 10 * time.Second
+
+	// nodeIDFile is the file in the data directory the generated node ID is
+	// persisted to.
+	nodeIDFile = "node-id"
+
+	// snapshotsRetained is the number of raft snapshots kept on disk.
+	snapshotsRetained = 2
+
+	// lockRetryInterval is how long acquiring an HA lock waits before trying
+	// again when leadership was lost but the change has not been observed
+	// yet.
+	lockRetryInterval = 250 * time.Millisecond
+)
+
+// ErrNotLeader is returned when a write is attempted on a node that is not
+// the raft leader.
+var ErrNotLeader = errors.New("raft storage writes must be performed on the leader node")
+
+// Verify RaftBackend satisfies the correct interfaces
+var _ physical.Backend = (*RaftBackend)(nil)
+var _ physical.Transactional = (*RaftBackend)(nil)
+var _ physical.HABackend = (*RaftBackend)(nil)
+var _ physical.Lock = (*RaftLock)(nil)
+
+// RaftBackend is a physical backend that replicates Vault's data between
+// nodes using an embedded raft log. It provides high availability without
+// depending on an external storage cluster. Reads are served from the local
+// copy of the data; writes are only accepted by the raft leader.
+type RaftBackend struct {
+	logger     log.Logger
+	path       string
+	nodeID     string
+	haEnabled  bool
+	permitPool *physical.PermitPool
+
+	fsm       *FSM
+	raft      *raft.Raft
+	transport *raft.NetworkTransport
+	logs      *logStore
+
+	// leaderLock protects isLeader and leaderStateCh. leaderStateCh is
+	// closed and replaced every time leadership is gained or lost.
+	leaderLock    sync.RWMutex
+	isLeader      bool
+	leaderStateCh chan struct{}
+
+	notifyCh   chan bool
+	shutdownCh chan struct{}
+	closeOnce  sync.Once
+}
+
+// RaftServer describes a single member of the raft cluster.
+type RaftServer struct {
+	NodeID  string `json:"node_id" structs:"node_id" mapstructure:"node_id"`
+	Address string `json:"address" structs:"address" mapstructure:"address"`
+	Leader  bool   `json:"leader" structs:"leader" mapstructure:"leader"`
+	Voter   bool   `json:"voter" structs:"voter" mapstructure:"voter"`
+}
+
+// RaftConfiguration is the current membership of the raft cluster.
+type RaftConfiguration struct {
+	Servers []*RaftServer `json:"servers" structs:"servers" mapstructure:"servers"`
+	Index   uint64        `json:"index" structs:"index" mapstructure:"index"`
+}
+
+// NewRaftBackend constructs a raft backend using the given configuration.
+// The raft node is started immediately so that Vault can read from storage
+// before it is unsealed.
+func NewRaftBackend(conf map[string]string, logger log.Logger) (physical.Backend, error) {
+	path, ok := conf["path"]
+	if !ok || path == "" {
+		return nil, fmt.Errorf("'path' must be set")
+	}
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, errwrap.Wrapf("failed to create data directory: {{err}}", err)
+	}
+
+	nodeID, err := loadNodeID(path, conf["node_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	haEnabled := true
+	if haEnabledStr, ok := conf["ha_enabled"]; ok {
+		haEnabled, err = strconv.ParseBool(haEnabledStr)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed parsing ha_enabled parameter: {{err}}", err)
+		}
+	}
+
+	var bootstrap bool
+	if bootstrapStr, ok := conf["bootstrap"]; ok {
+		bootstrap, err = strconv.ParseBool(bootstrapStr)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed parsing bootstrap parameter: {{err}}", err)
+		}
+	}
+
+	raftConfig := raft.DefaultConfig()
+	raftConfig.LocalID = raft.ServerID(nodeID)
+	raftConfig.Logger = logger.Named("raft")
+	if err := parseUint64(conf, "snapshot_threshold", &raftConfig.SnapshotThreshold); err != nil {
+		return nil, err
+	}
+	if err := parseUint64(conf, "trailing_logs", &raftConfig.TrailingLogs); err != nil {
+		return nil, err
+	}
+
+	address, ok := conf["address"]
+	if !ok || address == "" {
+		address = defaultAddress
+	}
+	var advertise net.Addr
+	if advertiseStr, ok := conf["advertise_address"]; ok && advertiseStr != "" {
+		advertise, err = net.ResolveTCPAddr("tcp", advertiseStr)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse advertise_address: {{err}}", err)
+		}
+	}
+
+	fsm, err := NewFSM(path, logger.Named("fsm"))
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := newLogStore(filepath.Join(path, "raft.db"))
+	if err != nil {
+		fsm.Close()
+		return nil, err
+	}
+	cachedLogStore, err := raft.NewLogCache(512, logs)
+	if err != nil {
+		fsm.Close()
+		logs.Close()
+		return nil, err
+	}
+
+	snapStore, err := raft.NewFileSnapshotStoreWithLogger(path, snapshotsRetained, logger.Named("snapshot"))
+	if err != nil {
+		fsm.Close()
+		logs.Close()
+		return nil, errwrap.Wrapf("failed to create snapshot store: {{err}}", err)
+	}
+
+	transport, err := raft.NewTCPTransportWithLogger(address, advertise, 3, applyTimeout, logger.Named("transport"))
+	if err != nil {
+		fsm.Close()
+		logs.Close()
+		return nil, errwrap.Wrapf("failed to create raft transport: {{err}}", err)
+	}
+
+	b := &RaftBackend{
+		logger:        logger,
+		path:          path,
+		nodeID:        nodeID,
+		haEnabled:     haEnabled,
+		permitPool:    physical.NewPermitPool(physical.DefaultParallelOperations),
+		fsm:           fsm,
+		transport:     transport,
+		logs:          logs,
+		leaderStateCh: make(chan struct{}),
+		notifyCh:      make(chan bool, 1),
+		shutdownCh:    make(chan struct{}),
+	}
+	raftConfig.NotifyCh = b.notifyCh
+
+	if bootstrap {
+		hasState, err := raft.HasExistingState(cachedLogStore, logs, snapStore)
+		if err != nil {
+			b.closeStores()
+			return nil, err
+		}
+		if !hasState {
+			configuration := raft.Configuration{
+				Servers: []raft.Server{
+					{
+						Suffrage: raft.Voter,
+						ID:       raftConfig.LocalID,
+						Address:  transport.LocalAddr(),
+					},
+				},
+			}
+			if err := raft.BootstrapCluster(raftConfig, cachedLogStore, logs, snapStore, transport, configuration); err != nil {
+				b.closeStores()
+				return nil, errwrap.Wrapf("failed to bootstrap raft cluster: {{err}}", err)
+			}
+		}
+	}
+
+	b.raft, err = raft.NewRaft(raftConfig, fsm, cachedLogStore, logs, snapStore, transport)
+	if err != nil {
+		b.closeStores()
+		return nil, errwrap.Wrapf("failed to start raft: {{err}}", err)
+	}
+
+	go b.watchLeadership()
+
+	if logger.IsInfo() {
+		logger.Info("raft storage started", "node_id", nodeID, "address", transport.LocalAddr())
+	}
+
+	return b, nil
+}
+
+// loadNodeID returns the configured node ID, or the one persisted in the data
+// directory, generating and persisting a new one if neither exists.
+func loadNodeID(path, configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+
+	nodeIDPath := filepath.Join(path, nodeIDFile)
+	existing, err := ioutil.ReadFile(nodeIDPath)
+	switch {
+	case err == nil:
+		return strings.TrimSpace(string(existing)), nil
+	case !os.IsNotExist(err):
+		return "", errwrap.Wrapf("failed to read node ID: {{err}}", err)
+	}
+
+	nodeID, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(nodeIDPath, []byte(nodeID), 0600); err != nil {
+		return "", errwrap.Wrapf("failed to persist node ID: {{err}}", err)
+	}
+
+	return nodeID, nil
+}
+
+func parseUint64(conf map[string]string, key string, out *uint64) error {
+	raw, ok := conf[key]
+	if !ok || raw == "" {
+		return nil
+	}
+
+	val, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("failed parsing %s parameter: {{err}}", key), err)
+	}
+	*out = val
+	return nil
+}
+
+// watchLeadership tracks raft leadership changes so that HA locks can wait
+// on them.
+func (b *RaftBackend) watchLeadership() {
+	for {
+		select {
+		case isLeader := <-b.notifyCh:
+			b.leaderLock.Lock()
+			b.isLeader = isLeader
+			close(b.leaderStateCh)
+			b.leaderStateCh = make(chan struct{})
+			b.leaderLock.Unlock()
+
+		case <-b.shutdownCh:
+			return
+		}
+	}
+}
+
+// leaderState returns whether this node is currently the raft leader, and a
+// channel that is closed when that changes.
+func (b *RaftBackend) leaderState() (bool, <-chan struct{}) {
+	b.leaderLock.RLock()
+	defer b.leaderLock.RUnlock()
+
+	return b.isLeader, b.leaderStateCh
+}
+
+// NodeID returns the raft ID of this node.
+func (b *RaftBackend) NodeID() string {
+	return b.nodeID
+}
+
+// Address returns the address other raft nodes use to reach this node.
+func (b *RaftBackend) Address() string {
+	return string(b.transport.LocalAddr())
+}
+
+// Close shuts down the raft node and closes the underlying stores. It is safe
+// to call more than once.
+func (b *RaftBackend) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		close(b.shutdownCh)
+
+		if err = b.raft.Shutdown().Error(); err != nil {
+			return
+		}
+
+		err = b.closeStores()
+	})
+	return err
+}
+
+func (b *RaftBackend) closeStores() error {
+	b.transport.Close()
+
+	var retErr error
+	if err := b.logs.Close(); err != nil {
+		retErr = err
+	}
+	if err := b.fsm.Close(); err != nil {
+		retErr = err
+	}
+	return retErr
+}
+
+// Put is used to insert or update an entry
+func (b *RaftBackend) Put(ctx context.Context, entry *physical.Entry) error {
+	defer metrics.MeasureSince([]string{"raft", "put"}, time.Now())
+
+	return b.applyLog(ctx, &LogOperation{
+		OpType: putOp,
+		Key:    entry.Key,
+		Value:  entry.Value,
+	})
+}
+
+// Get is used to fetch an entry
+func (b *RaftBackend) Get(ctx context.Context, key string) (*physical.Entry, error) {
+	defer metrics.MeasureSince([]string{"raft", "get"}, time.Now())
+
+	b.permitPool.Acquire()
+	defer b.permitPool.Release()
+
+	return b.fsm.Get(ctx, key)
+}
+
+// Delete is used to permanently delete an entry
+func (b *RaftBackend) Delete(ctx context.Context, key string) error {
+	defer metrics.MeasureSince([]string{"raft", "delete"}, time.Now())
+
+	return b.applyLog(ctx, &LogOperation{
+		OpType: deleteOp,
+		Key:    key,
+	})
+}
+
+// List is used to list all the keys under a given prefix, up to the next
+// prefix.
+func (b *RaftBackend) List(ctx context.Context, prefix string) ([]string, error) {
+	defer metrics.MeasureSince([]string{"raft", "list"}, time.Now())
+
+	b.permitPool.Acquire()
+	defer b.permitPool.Release()
+
+	return b.fsm.List(ctx, prefix)
+}
+
+// Transaction applies all the given operations in a single raft log entry so
+// that they are committed atomically.
+func (b *RaftBackend) Transaction(ctx context.Context, txns []*physical.TxnEntry) error {
+	defer metrics.MeasureSince([]string{"raft", "transaction"}, time.Now())
+
+	ops := make([]*LogOperation, 0, len(txns))
+	for _, txn := range txns {
+		op := &LogOperation{
+			Key: txn.Entry.Key,
+		}
+		switch txn.Operation {
+		case physical.PutOperation:
+			op.OpType = putOp
+			op.Value = txn.Entry.Value
+		case physical.DeleteOperation:
+			op.OpType = deleteOp
+		default:
+			return fmt.Errorf("%q is not a supported transaction operation", txn.Operation)
+		}
+		ops = append(ops, op)
+	}
+
+	return b.applyLog(ctx, ops...)
+}
+
+// applyLog commits the given operations through the raft log and waits for
+// them to be applied to the local FSM.
+func (b *RaftBackend) applyLog(ctx context.Context, ops ...*LogOperation) error {
+	b.permitPool.Acquire()
+	defer b.permitPool.Release()
+
+	cmd, err := jsonutil.EncodeJSON(&LogData{
+		Operations: ops,
+	})
+	if err != nil {
+		return err
+	}
+
+	applyFuture := b.raft.Apply(cmd, applyTimeout)
+	if err := applyFuture.Error(); err != nil {
+		if err == raft.ErrNotLeader {
+			return ErrNotLeader
+		}
+		return err
+	}
+
+	if resp, ok := applyFuture.Response().(error); ok && resp != nil {
+		return resp
+	}
+
+	return nil
+}
+
+// AddPeer adds a new voting member to the raft cluster. It must be called on
+// the leader.
+func (b *RaftBackend) AddPeer(ctx context.Context, nodeID, address string) error {
+	if b.raft.State() != raft.Leader {
+		return ErrNotLeader
+	}
+
+	if b.logger.IsInfo() {
+		b.logger.Info("adding raft peer", "node_id", nodeID, "address", address)
+	}
+
+	return b.raft.AddVoter(raft.ServerID(nodeID), raft.ServerAddress(address), 0, applyTimeout).Error()
+}
+
+// RemovePeer removes a member from the raft cluster. It must be called on the
+// leader.
+func (b *RaftBackend) RemovePeer(ctx context.Context, nodeID string) error {
+	if b.raft.State() != raft.Leader {
+		return ErrNotLeader
+	}
+
+	if b.logger.IsInfo() {
+		b.logger.Info("removing raft peer", "node_id", nodeID)
+	}
+
+	return b.raft.RemoveServer(raft.ServerID(nodeID), 0, applyTimeout).Error()
+}
+
+// GetConfiguration returns the current membership of the raft cluster.
+func (b *RaftBackend) GetConfiguration(ctx context.Context) (*RaftConfiguration, error) {
+	future := b.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil, err
+	}
+
+	_, leaderID := b.raft.LeaderWithID()
+
+	config := &RaftConfiguration{
+		Index: future.Index(),
+	}
+	for _, server := range future.Configuration().Servers {
+		config.Servers = append(config.Servers, &RaftServer{
+			NodeID:  string(server.ID),
+			Address: string(server.Address),
+			Leader:  server.ID == leaderID,
+			Voter:   server.Suffrage == raft.Voter,
+		})
+	}
+
+	return config, nil
+}
+
+// HAEnabled indicates whether the HA functionality should be exposed.
+func (b *RaftBackend) HAEnabled() bool {
+	return b.haEnabled
+}
+
+// LockWith is used for mutual exclusion based on the given key. The lock is
+// held by whichever node is the raft leader.
+func (b *RaftBackend) LockWith(key, value string) (physical.Lock, error) {
+	return &RaftLock{
+		b:     b,
+		key:   key,
+		value: value,
+	}, nil
+}
+
+// RaftLock implements the physical.Lock interface on top of raft leadership.
+// Acquiring the lock blocks until the local node is elected raft leader; the
+// lock is lost as soon as leadership is.
+type RaftLock struct {
+	b     *RaftBackend
+	key   string
+	value string
+
+	l        sync.Mutex
+	held     bool
+	unlockCh chan struct{}
+}
+
+// Lock is used to acquire the given lock. The returned channel is closed when
+// the local node loses raft leadership.
+func (l *RaftLock) Lock(stopCh <-chan struct{}) (<-chan struct{}, error) {
+	l.l.Lock()
+	defer l.l.Unlock()
+	if l.held {
+		return nil, fmt.Errorf("lock already held")
+	}
+
+	for {
+		isLeader, stateCh := l.b.leaderState()
+		if !isLeader {
+			select {
+			case <-stateCh:
+				continue
+			case <-stopCh:
+				return nil, nil
+			case <-l.b.shutdownCh:
+				return nil, nil
+			}
+		}
+
+		// Make sure everything committed by the previous leader has been
+		// applied locally before anyone reads from this node as the active
+		// node
+		err := l.b.raft.Barrier(applyTimeout).Error()
+		if err == nil {
+			err = l.b.applyLog(context.Background(), &LogOperation{
+				OpType: lockPutOp,
+				Key:    l.key,
+				Value:  []byte(l.value),
+			})
+		}
+		switch err {
+		case nil:
+		case raft.ErrNotLeader, ErrNotLeader, raft.ErrLeadershipLost:
+			// Leadership is being lost; wait for the change to be observed
+			// rather than spinning on it
+			select {
+			case <-stateCh:
+			case <-time.After(lockRetryInterval):
+			case <-stopCh:
+				return nil, nil
+			case <-l.b.shutdownCh:
+				return nil, nil
+			}
+			continue
+		default:
+			return nil, err
+		}
+
+		l.held = true
+		l.unlockCh = make(chan struct{})
+		leaderCh := make(chan struct{})
+		go l.monitorLeadership(stateCh, leaderCh, l.unlockCh)
+		return leaderCh, nil
+	}
+}
+
+// monitorLeadership closes leaderCh when leadership is lost or the lock is
+// released.
+func (l *RaftLock) monitorLeadership(stateCh <-chan struct{}, leaderCh, unlockCh chan struct{}) {
+	defer close(leaderCh)
+
+	for {
+		select {
+		case <-stateCh:
+			var isLeader bool
+			isLeader, stateCh = l.b.leaderState()
+			if !isLeader {
+				return
+			}
+		case <-unlockCh:
+			return
+		case <-l.b.shutdownCh:
+			return
+		}
+	}
+}
+
+// Unlock is used to release the lock. If other voting members exist,
+// leadership is handed off to one of them so that another node can become
+// active.
+func (l *RaftLock) Unlock() error {
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	if !l.held {
+		return nil
+	}
+
+	close(l.unlockCh)
+	l.unlockCh = nil
+	l.held = false
+
+	if l.b.raft.State() != raft.Leader {
+		return nil
+	}
+
+	err := l.b.applyLog(context.Background(), &LogOperation{
+		OpType: lockDeleteOp,
+		Key:    l.key,
+	})
+	if err != nil && err != ErrNotLeader {
+		return err
+	}
+
+	config, err := l.b.GetConfiguration(context.Background())
+	if err != nil {
+		return err
+	}
+	var voters int
+	for _, server := range config.Servers {
+		if server.Voter {
+			voters++
+		}
+	}
+	if voters > 1 {
+		if err := l.b.raft.LeadershipTransfer().Error(); err != nil && err != raft.ErrNotLeader {
+			l.b.logger.Warn("failed to transfer raft leadership", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// Value returns the value of the lock and if it is held.
+func (l *RaftLock) Value() (bool, string, error) {
+	value, held, err := l.b.fsm.lockValue(l.key)
+	return held, value, err
+}
@@ -0,0 +1,477 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	"github.com/hashicorp/vault/helper/logging"
+	"github.com/hashicorp/vault/physical"
+)
+
+func getRaft(t testing.TB, bootstrap bool, conf map[string]string) (*RaftBackend, func()) {
+	t.Helper()
+
+	raftDir, err := ioutil.TempDir("", "vault-raft-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logging.NewVaultLogger(log.Debug)
+
+	config := map[string]string{
+		"path":      raftDir,
+		"address":   "127.0.0.1:0",
+		"bootstrap": fmt.Sprintf("%t", bootstrap),
+	}
+	for k, v := range conf {
+		config[k] = v
+	}
+
+	backendRaw, err := NewRaftBackend(config, logger)
+	if err != nil {
+		os.RemoveAll(raftDir)
+		t.Fatal(err)
+	}
+	backend := backendRaw.(*RaftBackend)
+
+	return backend, func() {
+		backend.Close()
+		os.RemoveAll(raftDir)
+	}
+}
+
+func waitForLeader(t testing.TB, backends ...*RaftBackend) *RaftBackend {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, b := range backends {
+			if b.raft.State() == raft.Leader {
+				return b
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatal("timed out waiting for a raft leader")
+	return nil
+}
+
+func waitForApplied(t testing.TB, leader *RaftBackend, backends ...*RaftBackend) {
+	t.Helper()
+
+	if err := leader.raft.Barrier(applyTimeout).Error(); err != nil {
+		t.Fatal(err)
+	}
+	want := leader.raft.AppliedIndex()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for _, b := range backends {
+		for b.raft.AppliedIndex() < want {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for node %s to apply index %d", b.NodeID(), want)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+}
+
+func getRaftCluster(t testing.TB, count int, conf map[string]string) ([]*RaftBackend, func()) {
+	t.Helper()
+
+	var backends []*RaftBackend
+	var cleanups []func()
+	cleanup := func() {
+		for _, c := range cleanups {
+			c()
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		b, c := getRaft(t, i == 0, conf)
+		backends = append(backends, b)
+		cleanups = append(cleanups, c)
+	}
+
+	leader := waitForLeader(t, backends[0])
+	for _, b := range backends[1:] {
+		if err := leader.AddPeer(context.Background(), b.NodeID(), b.Address()); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+	}
+
+	return backends, cleanup
+}
+
+func TestRaft_Backend(t *testing.T) {
+	b, cleanup := getRaft(t, true, nil)
+	defer cleanup()
+	waitForLeader(t, b)
+
+	physical.ExerciseBackend(t, b)
+}
+
+func TestRaft_Backend_ListPrefix(t *testing.T) {
+	b, cleanup := getRaft(t, true, nil)
+	defer cleanup()
+	waitForLeader(t, b)
+
+	physical.ExerciseBackend_ListPrefix(t, b)
+}
+
+func TestRaft_TransactionalBackend(t *testing.T) {
+	b, cleanup := getRaft(t, true, nil)
+	defer cleanup()
+	waitForLeader(t, b)
+
+	physical.ExerciseTransactionalBackend(t, b)
+}
+
+func TestRaft_Replication(t *testing.T) {
+	backends, cleanup := getRaftCluster(t, 3, nil)
+	defer cleanup()
+	leader := waitForLeader(t, backends...)
+
+	for i := 0; i < 10; i++ {
+		err := leader.Put(context.Background(), &physical.Entry{
+			Key:   fmt.Sprintf("foo/%d", i),
+			Value: []byte(fmt.Sprintf("bar%d", i)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := leader.Delete(context.Background(), "foo/3"); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForApplied(t, leader, backends...)
+
+	for _, b := range backends {
+		keys, err := b.List(context.Background(), "foo/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 9 {
+			t.Fatalf("node %s: bad keys: %v", b.NodeID(), keys)
+		}
+
+		entry, err := b.Get(context.Background(), "foo/7")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry == nil || string(entry.Value) != "bar7" {
+			t.Fatalf("node %s: bad entry: %#v", b.NodeID(), entry)
+		}
+	}
+
+	// Writes must go to the leader
+	for _, b := range backends {
+		if b == leader {
+			continue
+		}
+		err := b.Put(context.Background(), &physical.Entry{Key: "follower", Value: []byte("write")})
+		if err != ErrNotLeader {
+			t.Fatalf("expected not leader error, got: %v", err)
+		}
+	}
+
+	config, err := leader.GetConfiguration(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Servers) != 3 {
+		t.Fatalf("bad: %#v", config.Servers)
+	}
+	for _, server := range config.Servers {
+		if server.Leader != (server.NodeID == leader.NodeID()) {
+			t.Fatalf("bad leader flag: %#v", server)
+		}
+		if !server.Voter {
+			t.Fatalf("expected voter: %#v", server)
+		}
+	}
+
+	// Removing a peer shrinks the configuration
+	var removed *RaftBackend
+	for _, b := range backends {
+		if b != leader {
+			removed = b
+			break
+		}
+	}
+	if err := leader.RemovePeer(context.Background(), removed.NodeID()); err != nil {
+		t.Fatal(err)
+	}
+	config, err = leader.GetConfiguration(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Servers) != 2 {
+		t.Fatalf("bad: %#v", config.Servers)
+	}
+}
+
+func TestRaft_SnapshotRestore(t *testing.T) {
+	conf := map[string]string{
+		"trailing_logs": "10",
+	}
+
+	leader, cleanup := getRaft(t, true, conf)
+	defer cleanup()
+	waitForLeader(t, leader)
+
+	for i := 0; i < 50; i++ {
+		err := leader.Put(context.Background(), &physical.Entry{
+			Key:   fmt.Sprintf("foo/%d", i),
+			Value: []byte(fmt.Sprintf("bar%d", i)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lock, err := leader.LockWith("core/lock", "leader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lock.Lock(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock()
+
+	// Snapshot and compact the log so a new node can only catch up by
+	// installing the snapshot
+	if err := leader.raft.Snapshot().Error(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 50; i < 55; i++ {
+		err := leader.Put(context.Background(), &physical.Entry{
+			Key:   fmt.Sprintf("foo/%d", i),
+			Value: []byte(fmt.Sprintf("bar%d", i)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	follower, cleanup2 := getRaft(t, false, conf)
+	defer cleanup2()
+	if err := leader.AddPeer(context.Background(), follower.NodeID(), follower.Address()); err != nil {
+		t.Fatal(err)
+	}
+	waitForApplied(t, leader, follower)
+
+	keys, err := follower.List(context.Background(), "foo/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 55 {
+		t.Fatalf("bad: %d keys", len(keys))
+	}
+	entry, err := follower.Get(context.Background(), "foo/12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil || string(entry.Value) != "bar12" {
+		t.Fatalf("bad: %#v", entry)
+	}
+
+	// The HA lock value travels with the snapshot
+	followerLock, err := follower.LockWith("core/lock", "follower")
+	if err != nil {
+		t.Fatal(err)
+	}
+	held, value, err := followerLock.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !held || value != "leader" {
+		t.Fatalf("bad: held %t, value %q", held, value)
+	}
+}
+
+func TestRaft_HABackend(t *testing.T) {
+	backends, cleanup := getRaftCluster(t, 2, nil)
+	defer cleanup()
+	leader := waitForLeader(t, backends...)
+	follower := backends[0]
+	if follower == leader {
+		follower = backends[1]
+	}
+
+	lock, err := leader.LockWith("foo", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaderCh, err := lock.Lock(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaderCh == nil {
+		t.Fatal("missing leaderCh")
+	}
+
+	held, val, err := lock.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !held || val != "bar" {
+		t.Fatalf("bad: held %t, value %q", held, val)
+	}
+
+	// The follower cannot acquire the lock while the leader holds it
+	lock2, err := follower.LockWith("foo", "baz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopCh := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() {
+		close(stopCh)
+	})
+	leaderCh2, err := lock2.Lock(stopCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaderCh2 != nil {
+		t.Fatal("should not have gotten leaderCh")
+	}
+
+	// Releasing the lock hands raft leadership to the follower
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-leaderCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("leaderCh not closed after unlock")
+	}
+
+	leaderCh2, err = lock2.Lock(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaderCh2 == nil {
+		t.Fatal("should get leaderCh")
+	}
+	waitForApplied(t, follower, leader)
+
+	held, val, err = lock.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !held || val != "baz" {
+		t.Fatalf("bad: held %t, value %q", held, val)
+	}
+
+	if err := lock2.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSnapshotSink collects a persisted snapshot in memory
+type testSnapshotSink struct {
+	bytes.Buffer
+}
+
+func (s *testSnapshotSink) ID() string    { return "test" }
+func (s *testSnapshotSink) Cancel() error { return nil }
+func (s *testSnapshotSink) Close() error  { return nil }
+
+func TestRaft_RestoreWaitsForSnapshot(t *testing.T) {
+	b, cleanup := getRaft(t, true, nil)
+	defer cleanup()
+	waitForLeader(t, b)
+
+	if err := b.Put(context.Background(), &physical.Entry{Key: "foo", Value: []byte("bar")}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := b.fsm.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restoring an empty snapshot has to wait for the pending one
+	restored := make(chan error, 1)
+	go func() {
+		restored <- b.fsm.Restore(ioutil.NopCloser(bytes.NewReader(nil)))
+	}()
+	select {
+	case err := <-restored:
+		t.Fatalf("restore did not wait for the pending snapshot: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	sink := new(testSnapshotSink)
+	if err := snapshot.Persist(sink); err != nil {
+		t.Fatal(err)
+	}
+	snapshot.Release()
+	if !strings.Contains(sink.String(), `"key":"foo"`) {
+		t.Fatalf("bad snapshot: %s", sink.String())
+	}
+
+	select {
+	case err := <-restored:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("restore did not finish after the snapshot was released")
+	}
+	entry, err := b.fsm.Get(context.Background(), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry != nil {
+		t.Fatalf("expected the restored state to be empty, got %#v", entry)
+	}
+}
+
+func TestRaft_HABackend_LockShutdown(t *testing.T) {
+	backends, cleanup := getRaftCluster(t, 2, nil)
+	defer cleanup()
+	leader := waitForLeader(t, backends...)
+	follower := backends[0]
+	if follower == leader {
+		follower = backends[1]
+	}
+
+	lock, err := follower.LockWith("foo", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type lockResult struct {
+		leaderCh <-chan struct{}
+		err      error
+	}
+	locked := make(chan lockResult, 1)
+	go func() {
+		leaderCh, err := lock.Lock(nil)
+		locked <- lockResult{leaderCh, err}
+	}()
+
+	// Shutting down the node gives up waiting for leadership
+	time.Sleep(100 * time.Millisecond)
+	if err := follower.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case result := <-locked:
+		if result.err != nil || result.leaderCh != nil {
+			t.Fatalf("bad: %#v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock attempt was not given up on shutdown")
+	}
+}
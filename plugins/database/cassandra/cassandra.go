@@ -2,6 +2,7 @@ package cassandra
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"time"
 
@@ -240,3 +241,57 @@ func (c *Cassandra) RotateRootCredentials(ctx context.Context, statements []stri
 	c.rawConfig["password"] = password
 	return c.rawConfig, nil
 }
+
+// SetCredentials uses the rotation statements to set the password of an
+// existing user. If no password is provided a new one is generated.
+func (c *Cassandra) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticConfig dbplugin.StaticUserConfig) (username, password string, err error) {
+	// Grab the lock
+	c.Lock()
+	defer c.Unlock()
+
+	username = staticConfig.Username
+	if username == "" {
+		return "", "", errors.New("username is required to set credentials")
+	}
+
+	password = staticConfig.Password
+	if password == "" {
+		password, err = c.GeneratePassword()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	session, err := c.getConnection(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	rotateCQL := statements.Rotation
+	if len(rotateCQL) == 0 {
+		rotateCQL = []string{defaultRootCredentialRotationCQL}
+	}
+
+	var result *multierror.Error
+	for _, stmt := range rotateCQL {
+		for _, query := range strutil.ParseArbitraryStringSlice(stmt, ";") {
+			query = strings.TrimSpace(query)
+			if len(query) == 0 {
+				continue
+			}
+
+			err := session.Query(dbutil.QueryHelper(query, map[string]string{
+				"username": username,
+				"password": password,
+			})).Exec()
+
+			result = multierror.Append(result, err)
+		}
+	}
+
+	if err := result.ErrorOrNil(); err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
@@ -301,3 +301,8 @@ func (h *HANA) revokeUserDefault(ctx context.Context, username string) error {
 func (h *HANA) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
 	return nil, errors.New("root credentaion rotation is not currently implemented in this database secrets engine")
 }
+
+// SetCredentials is not currently supported on HANA
+func (h *HANA) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticConfig dbplugin.StaticUserConfig) (username, password string, err error) {
+	return "", "", errors.New("setting credentials is not currently implemented in this database secrets engine")
+}
@@ -222,3 +222,8 @@ func (m *MongoDB) RevokeUser(ctx context.Context, statements dbplugin.Statements
 func (m *MongoDB) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
 	return nil, errors.New("root credentaion rotation is not currently implemented in this database secrets engine")
 }
+
+// SetCredentials is not currently supported on MongoDB
+func (m *MongoDB) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticConfig dbplugin.StaticUserConfig) (username, password string, err error) {
+	return "", "", errors.New("setting credentials is not currently implemented in this database secrets engine")
+}
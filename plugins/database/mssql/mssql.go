@@ -382,6 +382,72 @@ func (m *MSSQL) RotateRootCredentials(ctx context.Context, statements []string)
 	return m.RawConfig, nil
 }
 
+// SetCredentials uses the rotation statements to set the password of an
+// existing user. If no password is provided a new one is generated.
+func (m *MSSQL) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticConfig dbplugin.StaticUserConfig) (username, password string, err error) {
+	m.Lock()
+	defer m.Unlock()
+
+	username = staticConfig.Username
+	if username == "" {
+		return "", "", errors.New("username is required to set credentials")
+	}
+
+	password = staticConfig.Password
+	if password == "" {
+		password, err = m.GeneratePassword()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	rotateStatements := statements.Rotation
+	if len(rotateStatements) == 0 {
+		rotateStatements = []string{setCredentialsSQL}
+	}
+
+	db, err := m.getConnection(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+
+	for _, stmt := range rotateStatements {
+		for _, query := range strutil.ParseArbitraryStringSlice(stmt, ";") {
+			query = strings.TrimSpace(query)
+			if len(query) == 0 {
+				continue
+			}
+			stmt, err := tx.PrepareContext(ctx, dbutil.QueryHelper(query, map[string]string{
+				"name":     username,
+				"username": username,
+				"password": password,
+			}))
+			if err != nil {
+				return "", "", err
+			}
+
+			defer stmt.Close()
+			if _, err := stmt.ExecContext(ctx); err != nil {
+				return "", "", err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
 const dropUserSQL = `
 USE [%s]
 IF EXISTS
@@ -406,3 +472,7 @@ END
 const rotateRootCredentialsSQL = `
 ALTER LOGIN [%s] WITH PASSWORD = '%s' 
 `
+
+const setCredentialsSQL = `
+ALTER LOGIN [{{username}}] WITH PASSWORD = '{{password}}'
+`
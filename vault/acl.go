@@ -10,6 +10,7 @@ import (
 	"github.com/hashicorp/errwrap"
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 )
@@ -25,6 +26,10 @@ type ACL struct {
 
 	// root is enabled if the "root" named policy is present.
 	root bool
+
+	// namespace is the namespace of the policies. The ACL only grants access
+	// to paths within it.
+	namespace *namespace.Namespace
 }
 
 type PolicyCheckOpts struct {
@@ -40,10 +45,11 @@ type AuthResults struct {
 }
 
 type ACLResults struct {
-	Allowed    bool
-	RootPrivs  bool
-	IsRoot     bool
-	MFAMethods []string
+	Allowed      bool
+	RootPrivs    bool
+	IsRoot       bool
+	MFAMethods   []string
+	ControlGroup *ControlGroup
 }
 
 // New is used to construct a policy based ACL from a set of policies.
@@ -104,6 +110,7 @@ func NewACL(policies []*Policy) (*ACL, error) {
 				existingPerms.CapabilitiesBitmap = DenyCapabilityInt
 				existingPerms.AllowedParameters = nil
 				existingPerms.DeniedParameters = nil
+				existingPerms.ControlGroup = nil
 				goto INSERT
 
 			default:
@@ -182,6 +189,21 @@ func NewACL(policies []*Policy) (*ACL, error) {
 				}
 			}
 
+			// Control groups of all policies apply, so their factors are
+			// combined and the shortest TTL wins
+			if pc.Permissions.ControlGroup != nil {
+				if existingPerms.ControlGroup == nil {
+					existingPerms.ControlGroup = &ControlGroup{
+						TTL: pc.Permissions.ControlGroup.TTL,
+					}
+				} else if pc.Permissions.ControlGroup.TTL > 0 &&
+					(existingPerms.ControlGroup.TTL == 0 ||
+						pc.Permissions.ControlGroup.TTL < existingPerms.ControlGroup.TTL) {
+					existingPerms.ControlGroup.TTL = pc.Permissions.ControlGroup.TTL
+				}
+				existingPerms.ControlGroup.Factors = append(existingPerms.ControlGroup.Factors, pc.Permissions.ControlGroup.Factors...)
+			}
+
 		INSERT:
 			tree.Insert(pc.Prefix, existingPerms)
 		}
@@ -189,12 +211,36 @@ func NewACL(policies []*Policy) (*ACL, error) {
 	return a, nil
 }
 
+// newTemplatedACL constructs the ACL of a requester. Templated policies are
+// parsed again to resolve their paths with the entity of the requester and
+// the groups it belongs to; paths which can't be resolved are skipped.
+func newTemplatedACL(policies []*Policy, entity *identity.Entity, groups []*identity.Group) (*ACL, error) {
+	resolved := make([]*Policy, 0, len(policies))
+	for _, policy := range policies {
+		if policy != nil && policy.Type == PolicyTypeACL && policy.Templated {
+			p, err := parseACLPolicyWithTemplating(policy.Raw, true, entity, groups)
+			if err != nil {
+				return nil, errwrap.Wrapf(fmt.Sprintf("error parsing templated policy %q: {{err}}", policy.Name), err)
+			}
+			p.Name = policy.Name
+			policy = p
+		}
+		resolved = append(resolved, policy)
+	}
+	return NewACL(resolved)
+}
+
 func (a *ACL) Capabilities(path string) (pathCapabilities []string) {
 	// Fast-path root
 	if a.root {
 		return []string{RootCapability}
 	}
 
+	path, ok := a.namespacePath(path)
+	if !ok {
+		return []string{DenyCapability}
+	}
+
 	// Find an exact matching rule, look for glob if no match
 	var capabilities uint32
 	raw, ok := a.exactRules.Get(path)
@@ -254,7 +300,11 @@ func (a *ACL) AllowOperation(req *logical.Request) (ret *ACLResults) {
 		return
 	}
 	op := req.Operation
-	path := req.Path
+	path, ok := a.namespacePath(req.Path)
+	if !ok {
+		// Nothing outside of the namespace is reachable
+		return
+	}
 
 	// Help is always allowed
 	if op == logical.HelpOperation {
@@ -290,7 +340,7 @@ CHECK:
 
 	operationAllowed := false
 	switch op {
-	case logical.ReadOperation:
+	case logical.ReadOperation, logical.HeaderOperation:
 		operationAllowed = capabilities&ReadCapabilityInt > 0
 	case logical.ListOperation:
 		operationAllowed = capabilities&ListCapabilityInt > 0
@@ -313,6 +363,7 @@ CHECK:
 	if !operationAllowed {
 		return
 	}
+	ret.ControlGroup = permissions.ControlGroup
 
 	if permissions.MaxWrappingTTL > 0 {
 		if req.WrapInfo == nil || req.WrapInfo.TTL > permissions.MaxWrappingTTL {
@@ -420,6 +471,13 @@ func (c *Core) performPolicyChecks(ctx context.Context, acl *ACL, te *TokenEntry
 		}
 	}
 
+	// Then, evaluate the endpoint governing policies, which can deny
+	// requests the ACLs allow
+	if err := c.performEGPChecks(ctx, req, te, inEntity); err != nil {
+		ret.Error = multierror.Append(ret.Error, err)
+		return
+	}
+
 	ret.Allowed = true
 	return
 }
@@ -449,3 +507,15 @@ func valueInSlice(v interface{}, list []interface{}) bool {
 
 	return false
 }
+
+// namespacePath returns the given path relative to the namespace of the ACL,
+// and false if the path is outside of the namespace
+func (a *ACL) namespacePath(path string) (string, bool) {
+	if a.namespace == nil || a.namespace.ID == namespace.RootNamespaceID {
+		return path, true
+	}
+	if !strings.HasPrefix(path, a.namespace.Path) {
+		return "", false
+	}
+	return a.namespace.TrimmedPath(path), true
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -276,6 +277,88 @@ func TestACL_PolicyMerge(t *testing.T) {
 	}
 }
 
+func TestACL_ControlGroupMerge(t *testing.T) {
+	policy1, err := ParseACLPolicy(`
+path "secret/breakglass" {
+	capabilities = ["read"]
+	control_group = {
+		ttl = "4h"
+		factor "managers" {
+			identity {
+				group_names = ["managers"]
+			}
+		}
+	}
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy2, err := ParseACLPolicy(`
+path "secret/breakglass" {
+	capabilities = ["list"]
+	control_group = {
+		ttl = "1h"
+		factor "security" {
+			identity {
+				group_names = ["security"]
+				approvals = 2
+			}
+		}
+	}
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acl, err := NewACL([]*Policy{policy1, policy2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authResults := acl.AllowOperation(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/breakglass",
+	})
+	if !authResults.Allowed {
+		t.Fatal("expected the operation to be allowed")
+	}
+	cg := authResults.ControlGroup
+	if cg == nil {
+		t.Fatal("expected a control group")
+	}
+	if cg.TTL != time.Hour {
+		t.Fatalf("bad ttl: %v", cg.TTL)
+	}
+	if len(cg.Factors) != 2 || cg.Factors[0].Name != "managers" || cg.Factors[1].Name != "security" {
+		t.Fatalf("bad factors: %#v", cg.Factors)
+	}
+
+	// The policies must not have been modified by the merge
+	if len(policy1.Paths[0].Permissions.ControlGroup.Factors) != 1 {
+		t.Fatalf("policy was modified: %#v", policy1.Paths[0].Permissions.ControlGroup.Factors)
+	}
+
+	// A deny removes the control group
+	denyPolicy, err := ParseACLPolicy(`
+path "secret/breakglass" {
+	capabilities = ["deny"]
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acl, err = NewACL([]*Policy{policy1, denyPolicy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := acl.exactRules.Get("secret/breakglass")
+	if raw.(*ACLPermissions).ControlGroup != nil {
+		t.Fatal("expected the control group to be removed")
+	}
+}
+
 func TestACL_AllowOperation(t *testing.T) {
 	policy, err := ParseACLPolicy(permissionsPolicy)
 	if err != nil {
@@ -776,3 +859,87 @@ path "test/star" {
 	}
 }
 `
+
+func TestACL_Templated(t *testing.T) {
+	policy, err := ParseACLPolicy(`
+path "secret/users/{{identity.entity.name}}/*" {
+	capabilities = ["read"]
+}
+path "secret/teams/{{identity.entity.metadata.team}}" {
+	capabilities = ["read"]
+}
+path "secret/groups/{{identity.groups.names.ops.id}}" {
+	capabilities = ["read"]
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.Name = "templated"
+
+	entity := &identity.Entity{
+		ID:   "entityid",
+		Name: "alice",
+	}
+	groups := []*identity.Group{
+		{
+			ID:   "groupid",
+			Name: "ops",
+		},
+	}
+	acl, err := newTemplatedACL([]*Policy{policy}, entity, groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcases := []struct {
+		path    string
+		allowed bool
+	}{
+		{"secret/users/alice/foo", true},
+		{"secret/users/bob/foo", false},
+		{"secret/users/{{identity.entity.name}}/foo", false},
+		// The entity has no team metadata so the rule is skipped
+		{"secret/teams/", false},
+		{"secret/groups/groupid", true},
+		{"secret/groups/ops", false},
+	}
+	for _, tc := range tcases {
+		authResults := acl.AllowOperation(&logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      tc.path,
+		})
+		if authResults.Allowed != tc.allowed {
+			t.Fatalf("%s: expected allowed %t", tc.path, tc.allowed)
+		}
+	}
+
+	// Values which would widen the path don't resolve it
+	for _, team := range []string{"*", "ops+", "ops/dev"} {
+		entity.Metadata = map[string]string{"team": team}
+		acl, err = newTemplatedACL([]*Policy{policy}, entity, groups)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range []string{"secret/teams/ops", "secret/teams/" + team, "secret/teams/ops/dev"} {
+			if acl.AllowOperation(&logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      path,
+			}).Allowed {
+				t.Fatalf("%s: expected the rule with team %q to be skipped", path, team)
+			}
+		}
+	}
+
+	// Without an entity none of the templated rules apply
+	acl, err = newTemplatedACL([]*Policy{policy}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl.AllowOperation(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/users/alice/foo",
+	}).Allowed {
+		t.Fatal("expected the templated rule to be skipped")
+	}
+}
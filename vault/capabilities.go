@@ -4,6 +4,7 @@ import (
 	"context"
 	"sort"
 
+	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -38,7 +39,7 @@ func (c *Core) Capabilities(ctx context.Context, token, path string) ([]string,
 		policies = append(policies, policy)
 	}
 
-	_, derivedPolicies, err := c.fetchEntityAndDerivedPolicies(te.EntityID)
+	entity, derivedPolicies, err := c.fetchEntityAndDerivedPolicies(te.EntityID)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +56,15 @@ func (c *Core) Capabilities(ctx context.Context, token, path string) ([]string,
 		return []string{DenyCapability}, nil
 	}
 
-	acl, err := NewACL(policies)
+	var groups []*identity.Group
+	if entity != nil {
+		groups, err = c.entityGroups(entity.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	acl, err := newTemplatedACL(policies, entity, groups)
 	if err != nil {
 		return nil, err
 	}
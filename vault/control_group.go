@@ -0,0 +1,515 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/helper/wrapping"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	// controlGroupPolicy is the policy of control group tokens, which can
+	// only be unwrapped
+	controlGroupPolicy = `
+path "sys/wrapping/unwrap" {
+    capabilities = ["update"]
+}
+`
+
+	// controlGroupCubbyholePath is where the pending request is kept in the
+	// cubbyhole of its control group token, so it is destroyed with it
+	controlGroupCubbyholePath = "cubbyhole/control-group"
+)
+
+// controlGroupRequest is a request which was held back by a control group
+type controlGroupRequest struct {
+	Path         string                  `json:"path"`
+	Operation    logical.Operation       `json:"operation"`
+	Data         map[string]interface{}  `json:"data"`
+	Accessor     string                  `json:"accessor"`
+	EntityID     string                  `json:"entity_id"`
+	CreationTime time.Time               `json:"creation_time"`
+	ControlGroup *ControlGroup           `json:"control_group"`
+	Approvals    []*controlGroupApproval `json:"approvals"`
+}
+
+// controlGroupApproval is the approval of a request by an entity
+type controlGroupApproval struct {
+	EntityID string    `json:"entity_id"`
+	Time     time.Time `json:"time"`
+}
+
+type controlGroupApprovedKey struct{}
+
+// contextWithControlGroupApproval marks the requests performed with the
+// context as approved, so their control groups no longer apply
+func contextWithControlGroupApproval(ctx context.Context) context.Context {
+	return context.WithValue(ctx, controlGroupApprovedKey{}, true)
+}
+
+func controlGroupApproved(ctx context.Context) bool {
+	approved, _ := ctx.Value(controlGroupApprovedKey{}).(bool)
+	return approved
+}
+
+// createControlGroupRequest holds back a request governed by a control group.
+// The returned response wraps a control group token, which can be unwrapped
+// once the request is approved to get the response of the request.
+func (c *Core) createControlGroupRequest(ctx context.Context, req *logical.Request, te *TokenEntry, cg *ControlGroup) (*logical.Response, error) {
+	ttl := cg.TTL
+	if ttl == 0 {
+		ttl = c.maxLeaseTTL
+	}
+
+	creationTime := time.Now()
+	cgte := TokenEntry{
+		Path:           req.Path,
+		Policies:       []string{controlGroupPolicyName},
+		CreationTime:   creationTime.Unix(),
+		TTL:            ttl,
+		NumUses:        1,
+		ExplicitMaxTTL: ttl,
+	}
+	if err := c.tokenStore.create(ctx, &cgte); err != nil {
+		c.logger.Error("failed to create control group token", "error", err)
+		return nil, ErrInternalError
+	}
+
+	cgReq := &controlGroupRequest{
+		Path:         req.Path,
+		Operation:    req.Operation,
+		Data:         req.Data,
+		Accessor:     te.Accessor,
+		EntityID:     te.EntityID,
+		CreationTime: creationTime,
+		ControlGroup: cg,
+	}
+	if err := c.storeControlGroupRequest(ctx, cgte.ID, cgReq); err != nil {
+		c.tokenStore.Revoke(ctx, cgte.ID)
+		c.logger.Error("failed to store control group request", "error", err)
+		return nil, ErrInternalError
+	}
+
+	// Store info for lookup
+	cubbyReq := &logical.Request{
+		Operation:   logical.CreateOperation,
+		Path:        "cubbyhole/wrapinfo",
+		ClientToken: cgte.ID,
+		Data: map[string]interface{}{
+			"creation_ttl":  ttl,
+			"creation_time": creationTime,
+			"creation_path": req.Path,
+		},
+	}
+	if _, err := c.router.Route(ctx, cubbyReq); err != nil {
+		c.tokenStore.Revoke(ctx, cgte.ID)
+		c.logger.Error("failed to store control group wrapping information", "error", err)
+		return nil, ErrInternalError
+	}
+
+	cgAuth := &logical.Auth{
+		ClientToken: cgte.ID,
+		Policies:    []string{controlGroupPolicyName},
+		LeaseOptions: logical.LeaseOptions{
+			TTL:       cgte.TTL,
+			Renewable: false,
+		},
+	}
+	if err := c.expiration.RegisterAuth(cgte.Path, cgAuth); err != nil {
+		c.tokenStore.Revoke(ctx, cgte.ID)
+		c.logger.Error("failed to register control group token lease", "request_path", req.Path, "error", err)
+		return nil, ErrInternalError
+	}
+
+	return &logical.Response{
+		WrapInfo: &wrapping.ResponseWrapInfo{
+			Token:           cgte.ID,
+			Accessor:        cgte.Accessor,
+			TTL:             ttl,
+			CreationTime:    creationTime,
+			CreationPath:    req.Path,
+			WrappedEntityID: te.EntityID,
+		},
+	}, nil
+}
+
+func (c *Core) storeControlGroupRequest(ctx context.Context, token string, cgReq *controlGroupRequest) error {
+	raw, err := json.Marshal(cgReq)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.router.Route(ctx, &logical.Request{
+		Operation:   logical.UpdateOperation,
+		Path:        controlGroupCubbyholePath,
+		ClientToken: token,
+		Data: map[string]interface{}{
+			"request": string(raw),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if resp != nil && resp.IsError() {
+		return resp.Error()
+	}
+	return nil
+}
+
+func (c *Core) readControlGroupRequest(ctx context.Context, token string) (*controlGroupRequest, error) {
+	resp, err := c.router.Route(ctx, &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        controlGroupCubbyholePath,
+		ClientToken: token,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil || resp.Data == nil {
+		return nil, nil
+	}
+	if resp.IsError() {
+		return nil, resp.Error()
+	}
+
+	raw, ok := resp.Data["request"].(string)
+	if !ok {
+		return nil, errors.New("could not decode control group request")
+	}
+	var cgReq controlGroupRequest
+	if err := jsonutil.DecodeJSON([]byte(raw), &cgReq); err != nil {
+		return nil, err
+	}
+	return &cgReq, nil
+}
+
+// controlGroupTokenByAccessor returns the ID of the control group token with
+// the given accessor, or an error if the accessor doesn't belong to one
+func (c *Core) controlGroupTokenByAccessor(ctx context.Context, accessor string) (string, error) {
+	aEntry, err := c.tokenStore.lookupByAccessor(ctx, accessor, false)
+	if err != nil {
+		return "", err
+	}
+	te, err := c.tokenStore.Lookup(ctx, aEntry.TokenID)
+	if err != nil {
+		return "", err
+	}
+	if te == nil || len(te.Policies) != 1 || te.Policies[0] != controlGroupPolicyName {
+		return "", &logical.StatusBadRequest{Err: "invalid accessor"}
+	}
+	return te.ID, nil
+}
+
+// entityGroupNames returns the names of the identity groups the entity is a
+// direct or inherited member of
+func (c *Core) entityGroupNames(entityID string) ([]string, error) {
+	groups, err := c.entityGroups(entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	return names, nil
+}
+
+// approvedFactors returns the factors of the control group which the
+// approvals satisfy. Group memberships are checked when the request is
+// evaluated, so approvers which left the groups no longer count.
+func (c *Core) approvedFactors(cgReq *controlGroupRequest) ([]string, error) {
+	groupNames := make(map[string][]string, len(cgReq.Approvals))
+	for _, approval := range cgReq.Approvals {
+		names, err := c.entityGroupNames(approval.EntityID)
+		if err != nil {
+			return nil, err
+		}
+		groupNames[approval.EntityID] = names
+	}
+
+	var approved []string
+	for _, factor := range cgReq.ControlGroup.Factors {
+		count := 0
+		for _, approval := range cgReq.Approvals {
+			for _, name := range factor.Identity.GroupNames {
+				if strutil.StrListContains(groupNames[approval.EntityID], name) {
+					count++
+					break
+				}
+			}
+		}
+		if count >= factor.Identity.ApprovalsRequired {
+			approved = append(approved, factor.Name)
+		}
+	}
+	return approved, nil
+}
+
+func (c *Core) controlGroupRequestApproved(cgReq *controlGroupRequest) (bool, error) {
+	approved, err := c.approvedFactors(cgReq)
+	if err != nil {
+		return false, err
+	}
+	return len(approved) == len(cgReq.ControlGroup.Factors), nil
+}
+
+// controlGroupUnwrap performs the request of a control group token once it
+// is approved, and returns its raw HTTP response
+func (b *SystemBackend) controlGroupUnwrap(ctx context.Context, token string) (string, error) {
+	b.Core.controlGroupLock.Lock()
+	defer b.Core.controlGroupLock.Unlock()
+
+	cgReq, err := b.Core.readControlGroupRequest(ctx, token)
+	if err != nil {
+		return "", fmt.Errorf("error looking up control group request: %v", err)
+	}
+	if cgReq == nil {
+		return "no control group request found", logical.ErrInvalidRequest
+	}
+
+	approved, err := b.Core.controlGroupRequestApproved(cgReq)
+	if err != nil {
+		return "", err
+	}
+	if !approved {
+		return "request needs further approval", logical.ErrPermissionDenied
+	}
+
+	// The request is performed on behalf of the requester, so it fails if
+	// their token is no longer valid
+	aEntry, err := b.Core.tokenStore.lookupByAccessor(ctx, cgReq.Accessor, false)
+	if err != nil || aEntry.TokenID == "" {
+		return "token of the requester is no longer valid", logical.ErrPermissionDenied
+	}
+
+	reqID, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+	req := &logical.Request{
+		ID:          reqID,
+		Operation:   cgReq.Operation,
+		Path:        cgReq.Path,
+		Data:        cgReq.Data,
+		ClientToken: aEntry.TokenID,
+	}
+
+	reqCtx := namespace.ContextWithNamespace(ctx, b.Core.namespaceByPath(req.Path))
+	resp, _, err := b.Core.handleRequest(contextWithControlGroupApproval(reqCtx), req)
+	if err != nil {
+		if resp != nil && resp.IsError() {
+			return resp.Error().Error(), err
+		}
+		return "", err
+	}
+
+	// The token is only revoked once the request succeeded, so it can be
+	// retried otherwise
+	defer b.Core.tokenStore.Revoke(ctx, token)
+
+	if resp == nil {
+		return "", nil
+	}
+	if resp.Secret != nil {
+		resp.Secret.InternalData = nil
+	}
+	if resp.Auth != nil {
+		resp.Auth.InternalData = nil
+	}
+
+	httpResponse := logical.LogicalResponseToHTTPResponse(resp)
+	httpResponse.RequestID = req.ID
+	marshaledResponse, err := json.Marshal(httpResponse)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal control group response: %v", err)
+	}
+	return string(marshaledResponse), nil
+}
+
+// controlGroupPaths returns the paths used to approve control group requests
+func (b *SystemBackend) controlGroupPaths() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "control-group/authorize$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"accessor": {
+					Type:        framework.TypeString,
+					Description: "Accessor of the control group token of the request.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: b.handleControlGroupAuthorize,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["control-group-authorize"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["control-group-authorize"][1]),
+		},
+
+		{
+			Pattern: "control-group/request$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"accessor": {
+					Type:        framework.TypeString,
+					Description: "Accessor of the control group token of the request.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: b.handleControlGroupRequest,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["control-group-request"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["control-group-request"][1]),
+		},
+	}
+}
+
+func (b *SystemBackend) handleControlGroupAuthorize(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	accessor := data.Get("accessor").(string)
+	if accessor == "" {
+		return logical.ErrorResponse("missing accessor"), logical.ErrInvalidRequest
+	}
+	if req.EntityID == "" {
+		return logical.ErrorResponse("approving a request requires an entity"), logical.ErrPermissionDenied
+	}
+
+	b.Core.controlGroupLock.Lock()
+	defer b.Core.controlGroupLock.Unlock()
+
+	token, err := b.Core.controlGroupTokenByAccessor(ctx, accessor)
+	if err != nil {
+		return nil, err
+	}
+	cgReq, err := b.Core.readControlGroupRequest(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if cgReq == nil {
+		return logical.ErrorResponse("no control group request found"), logical.ErrInvalidRequest
+	}
+
+	if req.EntityID == cgReq.EntityID {
+		return logical.ErrorResponse("requesters cannot approve their own request"), logical.ErrPermissionDenied
+	}
+
+	groupNames, err := b.Core.entityGroupNames(req.EntityID)
+	if err != nil {
+		return nil, err
+	}
+	approver := false
+	for _, factor := range cgReq.ControlGroup.Factors {
+		for _, name := range factor.Identity.GroupNames {
+			if strutil.StrListContains(groupNames, name) {
+				approver = true
+			}
+		}
+	}
+	if !approver {
+		return logical.ErrorResponse("entity is not an approver of the request"), logical.ErrPermissionDenied
+	}
+
+	alreadyApproved := false
+	for _, approval := range cgReq.Approvals {
+		if approval.EntityID == req.EntityID {
+			alreadyApproved = true
+		}
+	}
+	if !alreadyApproved {
+		cgReq.Approvals = append(cgReq.Approvals, &controlGroupApproval{
+			EntityID: req.EntityID,
+			Time:     time.Now(),
+		})
+		if err := b.Core.storeControlGroupRequest(ctx, token, cgReq); err != nil {
+			return nil, err
+		}
+	}
+
+	approved, err := b.Core.controlGroupRequestApproved(cgReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"approved": approved,
+		},
+	}, nil
+}
+
+func (b *SystemBackend) handleControlGroupRequest(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	accessor := data.Get("accessor").(string)
+	if accessor == "" {
+		return logical.ErrorResponse("missing accessor"), logical.ErrInvalidRequest
+	}
+
+	b.Core.controlGroupLock.Lock()
+	defer b.Core.controlGroupLock.Unlock()
+
+	token, err := b.Core.controlGroupTokenByAccessor(ctx, accessor)
+	if err != nil {
+		return nil, err
+	}
+	cgReq, err := b.Core.readControlGroupRequest(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if cgReq == nil {
+		return logical.ErrorResponse("no control group request found"), logical.ErrInvalidRequest
+	}
+
+	approvedFactors, err := b.Core.approvedFactors(cgReq)
+	if err != nil {
+		return nil, err
+	}
+
+	authorizations := make([]map[string]interface{}, 0, len(cgReq.Approvals))
+	for _, approval := range cgReq.Approvals {
+		authorizations = append(authorizations, map[string]interface{}{
+			"entity_id":   approval.EntityID,
+			"entity_name": b.Core.entityName(approval.EntityID),
+			"time":        approval.Time,
+		})
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"approved":          len(approvedFactors) == len(cgReq.ControlGroup.Factors),
+			"approved_factors":  approvedFactors,
+			"request_path":      cgReq.Path,
+			"request_operation": string(cgReq.Operation),
+			"request_time":      cgReq.CreationTime,
+			"request_entity": map[string]interface{}{
+				"id":   cgReq.EntityID,
+				"name": b.Core.entityName(cgReq.EntityID),
+			},
+			"authorizations": authorizations,
+		},
+	}, nil
+}
+
+// entityName returns the name of the entity, or an empty string if it
+// doesn't exist
+func (c *Core) entityName(entityID string) string {
+	if entityID == "" {
+		return ""
+	}
+	entity, err := c.identityStore.MemDBEntityByID(entityID, false)
+	if err != nil || entity == nil {
+		return ""
+	}
+	return entity.Name
+}
@@ -0,0 +1,154 @@
+package vault
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestControlGroup(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	doRequest := func(token string, op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return c.HandleRequest(&logical.Request{
+			Operation:   op,
+			Path:        path,
+			ClientToken: token,
+			Data:        data,
+		})
+	}
+	mustRequest := func(token string, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := doRequest(token, op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: resp: %#v err: %v", op, path, resp, err)
+		}
+		return resp
+	}
+
+	mustRequest(root, logical.UpdateOperation, "secret/breakglass", map[string]interface{}{
+		"foo": "bar",
+	})
+	mustRequest(root, logical.UpdateOperation, "sys/policy/breakglass", map[string]interface{}{
+		"policy": `
+path "secret/breakglass" {
+	capabilities = ["read"]
+	control_group = {
+		factor "managers" {
+			identity {
+				group_names = ["managers"]
+			}
+		}
+	}
+}
+path "sys/control-group/authorize" {
+	capabilities = ["update"]
+}
+`,
+	})
+	mustRequest(root, logical.UpdateOperation, "sys/policy/approver", map[string]interface{}{
+		"policy": `
+path "sys/control-group/*" {
+	capabilities = ["update"]
+}
+`,
+	})
+
+	resp := mustRequest(root, logical.UpdateOperation, "identity/entity", map[string]interface{}{
+		"name": "requester",
+	})
+	requesterID := resp.Data["id"].(string)
+	resp = mustRequest(root, logical.UpdateOperation, "identity/entity", map[string]interface{}{
+		"name": "approver",
+	})
+	approverID := resp.Data["id"].(string)
+	resp = mustRequest(root, logical.UpdateOperation, "identity/entity", map[string]interface{}{
+		"name": "outsider",
+	})
+	outsiderID := resp.Data["id"].(string)
+	mustRequest(root, logical.UpdateOperation, "identity/group", map[string]interface{}{
+		"name":              "managers",
+		"member_entity_ids": []string{approverID},
+	})
+
+	tokens := map[string]string{}
+	for name, entityID := range map[string]string{
+		"requester": requesterID,
+		"approver":  approverID,
+		"outsider":  outsiderID,
+	} {
+		te := &TokenEntry{
+			Path:     "auth/token/create",
+			Policies: []string{"default", "breakglass", "approver"},
+			EntityID: entityID,
+		}
+		if err := c.tokenStore.create(context.Background(), te); err != nil {
+			t.Fatal(err)
+		}
+		tokens[name] = te.ID
+	}
+
+	// The read is held back and a control group token is returned
+	resp = mustRequest(tokens["requester"], logical.ReadOperation, "secret/breakglass", nil)
+	if resp.Data != nil || resp.WrapInfo == nil || resp.WrapInfo.Token == "" {
+		t.Fatalf("expected a control group token, got %#v", resp)
+	}
+	cgToken := resp.WrapInfo.Token
+	accessor := resp.WrapInfo.Accessor
+
+	unwrap := func() (*logical.Response, error) {
+		return doRequest(tokens["requester"], logical.UpdateOperation, "sys/wrapping/unwrap", map[string]interface{}{
+			"token": cgToken,
+		})
+	}
+
+	// Unwrapping fails until the request is approved
+	if _, err := unwrap(); err == nil || !strings.Contains(err.Error(), logical.ErrPermissionDenied.Error()) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+
+	// Requesters and entities outside of the factor groups cannot approve
+	for _, name := range []string{"requester", "outsider"} {
+		if _, err := doRequest(tokens[name], logical.UpdateOperation, "sys/control-group/authorize", map[string]interface{}{
+			"accessor": accessor,
+		}); err == nil {
+			t.Fatalf("expected %s to be unable to approve", name)
+		}
+	}
+
+	resp = mustRequest(tokens["approver"], logical.UpdateOperation, "sys/control-group/authorize", map[string]interface{}{
+		"accessor": accessor,
+	})
+	if !resp.Data["approved"].(bool) {
+		t.Fatalf("expected the request to be approved: %#v", resp.Data)
+	}
+
+	resp = mustRequest(tokens["approver"], logical.UpdateOperation, "sys/control-group/request", map[string]interface{}{
+		"accessor": accessor,
+	})
+	if !resp.Data["approved"].(bool) || resp.Data["request_path"] != "secret/breakglass" {
+		t.Fatalf("bad status: %#v", resp.Data)
+	}
+	if entity := resp.Data["request_entity"].(map[string]interface{}); entity["name"] != "requester" {
+		t.Fatalf("bad request entity: %#v", entity)
+	}
+	authorizations := resp.Data["authorizations"].([]map[string]interface{})
+	if len(authorizations) != 1 || authorizations[0]["entity_id"] != approverID {
+		t.Fatalf("bad authorizations: %#v", authorizations)
+	}
+
+	// The approved request is performed on unwrap
+	resp, err := unwrap()
+	if err != nil || resp.IsError() {
+		t.Fatalf("resp: %#v err: %v", resp, err)
+	}
+	if body := string(resp.Data[logical.HTTPRawBody].([]byte)); !strings.Contains(body, `"foo":"bar"`) {
+		t.Fatalf("bad response: %s", body)
+	}
+
+	// The control group token can only be unwrapped once
+	if _, err := unwrap(); err == nil {
+		t.Fatal("expected the control group token to be revoked")
+	}
+}
@@ -11,11 +11,13 @@ import (
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/armon/go-metrics"
+	"github.com/armon/go-radix"
 	log "github.com/hashicorp/go-hclog"
 
 	"google.golang.org/grpc"
@@ -30,6 +32,7 @@ import (
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/logging"
 	"github.com/hashicorp/vault/helper/mlock"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/reload"
 	"github.com/hashicorp/vault/helper/tlsutil"
 	"github.com/hashicorp/vault/logical"
@@ -89,6 +92,10 @@ var (
 	// is attempted to be unsealed.
 	ErrNotInit = errors.New("Vault is not initialized")
 
+	// ErrSealMigrationPending is returned if Vault is unsealed without
+	// requesting migration while a seal migration is configured.
+	ErrSealMigrationPending = errors.New("Vault is configured for seal migration; unseal with migrate set")
+
 	// ErrInternalError is returned when we don't want to leak
 	// any information about an internal error
 	ErrInternalError = errors.New("internal error")
@@ -168,9 +175,18 @@ type Core struct {
 	// physical backend is the un-trusted backend with durable data
 	physical physical.Backend
 
+	// underlyingPhysical is the physical backend as configured, without the
+	// cache and seal unwrapper layers
+	underlyingPhysical physical.Backend
+
 	// Our Seal, for seal configuration information
 	seal Seal
 
+	// sealMigrationTarget is the seal the barrier is migrated to on the next
+	// unseal that requests migration. While it is set, seal is the seal the
+	// storage is currently protected with.
+	sealMigrationTarget Seal
+
 	// barrier is the security barrier wrapping the physical backend
 	barrier SecurityBarrier
 
@@ -231,6 +247,21 @@ type Core struct {
 	// change underneath a calling function
 	authLock sync.RWMutex
 
+	// namespaces are loaded after unseal, keyed by ID. The root namespace
+	// is implicit and not part of the map.
+	namespaces map[string]*namespace.Namespace
+
+	// namespacePaths maps namespace paths to namespaces, to find the
+	// namespace of a request path
+	namespacePaths *radix.Tree
+
+	// namespacesLock protects namespaces and namespacePaths
+	namespacesLock sync.RWMutex
+
+	// namespacesModifyLock serializes the creation and deletion of
+	// namespaces
+	namespacesModifyLock sync.Mutex
+
 	// audit is loaded after unseal since it is a protected
 	// configuration
 	audit *MountTable
@@ -287,6 +318,24 @@ type Core struct {
 	// disabled
 	physicalCache physical.ToggleablePurgemonster
 
+	// walPhysical wraps the cache, recording the writes of the active node in
+	// the storage WAL performance standbys follow
+	walPhysical *walPhysical
+
+	// perfStandbyEnabled opts the node in to serving reads locally while it
+	// is a standby
+	perfStandbyEnabled bool
+	// perfStandby is set while the node is a standby serving reads locally
+	perfStandby bool
+	// perfStandbyWALLock protects the position in the storage WAL of the
+	// active node that the state of the performance standby reflects
+	perfStandbyWALLock  sync.RWMutex
+	perfStandbyWALEpoch string
+	perfStandbyWALIndex uint64
+	// perfStandbyRunLock ensures a single goroutine follows the storage WAL
+	// of the active node, as the connection to it is refreshed
+	perfStandbyRunLock sync.Mutex
+
 	// reloadFuncs is a map containing reload functions
 	reloadFuncs map[string][]reload.ReloadFunc
 
@@ -297,6 +346,12 @@ type Core struct {
 	// wrapping information
 	wrappingJWTKey *ecdsa.PrivateKey
 
+	// controlGroupLock serializes the approvals of control group requests
+	controlGroupLock sync.Mutex
+
+	// quotaManager holds the rate limit and lease count quotas
+	quotaManager *quotaManager
+
 	//
 	// Cluster information
 	//
@@ -428,6 +483,9 @@ type CoreConfig struct {
 
 	ClusterCipherSuites string `json:"cluster_cipher_suites" structs:"cluster_cipher_suites" mapstructure:"cluster_cipher_suites"`
 
+	// Lets standbys serve reads locally
+	PerformanceStandby bool `json:"performance_standby" structs:"performance_standby" mapstructure:"performance_standby"`
+
 	EnableUI bool `json:"ui" structs:"ui" mapstructure:"ui"`
 
 	// Enable the raw endpoint
@@ -478,6 +536,7 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 	c := &Core{
 		devToken:                         conf.DevToken,
 		physical:                         conf.Physical,
+		underlyingPhysical:               conf.Physical,
 		redirectAddr:                     conf.RedirectAddr,
 		clusterAddr:                      conf.ClusterAddr,
 		seal:                             conf.Seal,
@@ -488,6 +547,7 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		defaultLeaseTTL:                  conf.DefaultLeaseTTL,
 		maxLeaseTTL:                      conf.MaxLeaseTTL,
 		cachingDisabled:                  conf.DisableCache,
+		perfStandbyEnabled:               conf.PerformanceStandby,
 		clusterName:                      conf.ClusterName,
 		clusterListenerShutdownCh:        make(chan struct{}),
 		clusterListenerShutdownSuccessCh: make(chan struct{}),
@@ -539,6 +599,17 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 	}
 	c.physicalCache = c.physical.(physical.ToggleablePurgemonster)
 
+	// Wrap the cache to record writes in the storage WAL
+	c.walPhysical = newWALPhysical(c.physical)
+	if txnOK {
+		c.physical = &transactionalWALPhysical{
+			walPhysical:   c.walPhysical,
+			Transactional: c.physical.(physical.Transactional),
+		}
+	} else {
+		c.physical = c.walPhysical
+	}
+
 	if !conf.DisableMlock {
 		// Ensure our memory usage is locked into physical RAM
 		if err := mlock.LockMemory(); err != nil {
@@ -737,6 +808,16 @@ func (c *Core) fetchEntityAndDerivedPolicies(entityID string) (*identity.Entity,
 	return entity, policies, err
 }
 
+// entityGroups returns the identity groups the entity is a direct or inherited
+// member of
+func (c *Core) entityGroups(entityID string) ([]*identity.Group, error) {
+	directGroups, inheritedGroups, err := c.identityStore.groupsByEntityID(entityID)
+	if err != nil {
+		return nil, err
+	}
+	return append(directGroups, inheritedGroups...), nil
+}
+
 func (c *Core) fetchACLTokenEntryAndEntity(clientToken string) (*ACL, *TokenEntry, *identity.Entity, error) {
 	defer metrics.MeasureSince([]string{"core", "fetch_acl_and_token"}, time.Now())
 
@@ -762,6 +843,14 @@ func (c *Core) fetchACLTokenEntryAndEntity(clientToken string) (*ACL, *TokenEntr
 		return nil, nil, nil, logical.ErrPermissionDenied
 	}
 
+	// Policies are resolved in the namespace of the token
+	tokenNS := c.namespaceByID(te.NamespaceID)
+	if tokenNS == nil {
+		// The namespace has been deleted
+		return nil, nil, nil, logical.ErrPermissionDenied
+	}
+	ctx := namespace.ContextWithNamespace(c.activeContext, tokenNS)
+
 	tokenPolicies := te.Policies
 
 	entity, derivedPolicies, err := c.fetchEntityAndDerivedPolicies(te.EntityID)
@@ -772,7 +861,7 @@ func (c *Core) fetchACLTokenEntryAndEntity(clientToken string) (*ACL, *TokenEntr
 	tokenPolicies = append(tokenPolicies, derivedPolicies...)
 
 	// Construct the corresponding ACL object
-	acl, err := c.policyStore.ACL(c.activeContext, tokenPolicies...)
+	acl, err := c.policyStore.ACL(ctx, entity, tokenPolicies...)
 	if err != nil {
 		c.logger.Error("failed to construct ACL", "error", err)
 		return nil, nil, nil, ErrInternalError
@@ -781,7 +870,10 @@ func (c *Core) fetchACLTokenEntryAndEntity(clientToken string) (*ACL, *TokenEntr
 	return acl, te, entity, nil
 }
 
-func (c *Core) checkToken(ctx context.Context, req *logical.Request, unauth bool) (*logical.Auth, *TokenEntry, error) {
+// checkToken validates the token of the request and checks that the request
+// is allowed. If a control group governs the request, it is returned so the
+// request can be held back until it is approved.
+func (c *Core) checkToken(ctx context.Context, req *logical.Request, unauth bool) (*logical.Auth, *TokenEntry, *ControlGroup, error) {
 	defer metrics.MeasureSince([]string{"core", "check_token"}, time.Now())
 
 	var acl *ACL
@@ -798,7 +890,7 @@ func (c *Core) checkToken(ctx context.Context, req *logical.Request, unauth bool
 		// unauth, we just have no information to attach to the request, so
 		// ignore errors...this was best-effort anyways
 		if err != nil && !unauth {
-			return nil, te, err
+			return nil, te, nil, err
 		}
 	}
 
@@ -806,7 +898,7 @@ func (c *Core) checkToken(ctx context.Context, req *logical.Request, unauth bool
 	rootPath := c.router.RootPath(req.Path)
 
 	if rootPath && unauth {
-		return nil, nil, errors.New("cannot access root path in unauthenticated request")
+		return nil, nil, nil, errors.New("cannot access root path in unauthenticated request")
 	}
 
 	// When we receive a write of either type, rather than require clients to
@@ -825,9 +917,9 @@ func (c *Core) checkToken(ctx context.Context, req *logical.Request, unauth bool
 		default:
 			c.logger.Error("failed to run existence check", "error", err)
 			if _, ok := err.(errutil.UserError); ok {
-				return nil, nil, err
+				return nil, nil, nil, err
 			} else {
-				return nil, nil, ErrInternalError
+				return nil, nil, nil, ErrInternalError
 			}
 		}
 
@@ -860,6 +952,13 @@ func (c *Core) checkToken(ctx context.Context, req *logical.Request, unauth bool
 		req.EntityID = te.EntityID
 	}
 
+	// Batch tokens are never revoked, so their cubbyhole would never be
+	// destroyed
+	if te != nil && te.Type == logical.TokenTypeBatch &&
+		strings.HasPrefix(namespace.FromContext(ctx).TrimmedPath(req.Path), "cubbyhole/") {
+		return auth, te, nil, errors.New("cubbyhole operations are not supported for batch tokens")
+	}
+
 	// Check the standard non-root ACLs. Return the token entry if it's not
 	// allowed so we can decrement the use count.
 	authResults := c.performPolicyChecks(ctx, acl, te, req, entity, &PolicyCheckOpts{
@@ -867,14 +966,21 @@ func (c *Core) checkToken(ctx context.Context, req *logical.Request, unauth bool
 		RootPrivsRequired: rootPath,
 	})
 	if authResults.Error.ErrorOrNil() != nil {
-		return auth, te, authResults.Error
+		return auth, te, nil, authResults.Error
 	}
 	if !authResults.Allowed {
 		// Return auth for audit logging even if not allowed
-		return auth, te, logical.ErrPermissionDenied
+		return auth, te, nil, logical.ErrPermissionDenied
+	}
+
+	// Requests which were approved are performed without their control
+	// group
+	if authResults.ACLResults != nil && authResults.ACLResults.ControlGroup != nil &&
+		!controlGroupApproved(ctx) {
+		return auth, te, authResults.ACLResults.ControlGroup, nil
 	}
 
-	return auth, te, nil
+	return auth, te, nil, nil
 }
 
 // Sealed checks if the Vault is current sealed
@@ -1042,6 +1148,9 @@ func (c *Core) Unseal(key []byte) (bool, error) {
 	if !init {
 		return false, ErrNotInit
 	}
+	if c.sealMigrationTarget != nil {
+		return false, ErrSealMigrationPending
+	}
 
 	// Verify the key length
 	min, max := c.barrier.KeyLength()
@@ -1091,6 +1200,9 @@ func (c *Core) UnsealWithRecoveryKeys(ctx context.Context, key []byte) (bool, er
 	if !init {
 		return false, ErrNotInit
 	}
+	if c.sealMigrationTarget != nil {
+		return false, ErrSealMigrationPending
+	}
 
 	var config *SealConfig
 	// If recovery keys are supported then use recovery seal config to unseal
@@ -1564,6 +1676,10 @@ func (c *Core) sealInternal(keepLock bool) error {
 		<-c.standbyDoneCh
 		atomic.StoreUint32(&c.keepHALockOnStepDown, 0)
 		c.logger.Debug("runStandby done")
+
+		if err := c.teardownPerfStandby(); err != nil {
+			c.logger.Error("performance standby teardown failed", "error", err)
+		}
 	}
 
 	c.logger.Debug("sealing barrier")
@@ -1616,6 +1732,13 @@ func (c *Core) postUnseal() (retErr error) {
 		c.physicalCache.SetEnabled(true)
 	}
 
+	// Record writes for performance standbys to follow
+	wal, err := newStorageWAL()
+	if err != nil {
+		return err
+	}
+	c.walPhysical.setStorageWAL(wal)
+
 	switch c.sealUnwrapper.(type) {
 	case *sealUnwrapper:
 		c.sealUnwrapper.(*sealUnwrapper).runUnwraps()
@@ -1638,6 +1761,9 @@ func (c *Core) postUnseal() (retErr error) {
 	if err := c.setupPluginCatalog(); err != nil {
 		return err
 	}
+	if err := c.loadNamespaces(c.activeContext); err != nil {
+		return err
+	}
 	if err := c.loadMounts(c.activeContext); err != nil {
 		return err
 	}
@@ -1656,9 +1782,15 @@ func (c *Core) postUnseal() (retErr error) {
 	if err := c.setupCredentials(c.activeContext); err != nil {
 		return err
 	}
+	if err := c.setupNamespaces(c.activeContext); err != nil {
+		return err
+	}
 	if err := c.startRollback(); err != nil {
 		return err
 	}
+	if err := c.setupQuotas(c.activeContext); err != nil {
+		return err
+	}
 	if err := c.setupExpiration(); err != nil {
 		return err
 	}
@@ -1718,12 +1850,18 @@ func (c *Core) preSeal() error {
 	if err := c.teardownPolicyStore(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("error tearing down policy store: {{err}}", err))
 	}
+	if err := c.teardownQuotas(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down quotas: {{err}}", err))
+	}
 	if err := c.stopRollback(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("error stopping rollback: {{err}}", err))
 	}
 	if err := c.unloadMounts(c.activeContext); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("error unloading mounts: {{err}}", err))
 	}
+	if err := c.teardownNamespaces(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down namespaces: {{err}}", err))
+	}
 	if err := enterprisePreSeal(c); err != nil {
 		result = multierror.Append(result, err)
 	}
@@ -1735,6 +1873,8 @@ func (c *Core) preSeal() error {
 		c.sealUnwrapper.(*transactionalSealUnwrapper).stopUnwraps()
 	}
 
+	c.walPhysical.setStorageWAL(nil)
+
 	// Purge the cache
 	c.physicalCache.SetEnabled(false)
 	c.physicalCache.Purge(c.activeContext)
@@ -1854,6 +1994,11 @@ func (c *Core) runStandby(doneCh, manualStepDownCh, stopCh chan struct{}) {
 			// We now have the lock and can use it
 		}
 
+		// Stop serving reads as a performance standby
+		if err := c.teardownPerfStandby(); err != nil {
+			c.logger.Error("performance standby teardown failed", "error", err)
+		}
+
 		if c.sealed {
 			c.logger.Warn("grabbed HA lock but already sealed, exiting")
 			lock.Unlock()
@@ -3,6 +3,7 @@ package vault
 import (
 	"context"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -671,6 +672,68 @@ func TestCore_HandleRequest_ConnOnLogin(t *testing.T) {
 	}
 }
 
+func TestCore_HandleLogin_BatchToken(t *testing.T) {
+	noop := &NoopBackend{
+		Login: []string{"login"},
+		Response: &logical.Response{
+			Auth: &logical.Auth{
+				Policies:    []string{"foo"},
+				DisplayName: "armon",
+			},
+		},
+	}
+	c, _, root := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
+		return noop, nil
+	}
+
+	// Enable the credential backend, handing out batch tokens
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/auth/foo")
+	req.Data["type"] = "noop"
+	req.Data["config"] = map[string]interface{}{
+		"token_type": "batch",
+	}
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	lresp, err := c.HandleRequest(&logical.Request{
+		Path: "auth/foo/login",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !strings.HasPrefix(lresp.Auth.ClientToken, batchTokenPrefix) || lresp.Auth.Renewable {
+		t.Fatalf("bad: %#v", lresp.Auth)
+	}
+
+	te, err := c.tokenStore.Lookup(context.Background(), lresp.Auth.ClientToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if te == nil || te.Type != logical.TokenTypeBatch || te.Path != "auth/foo/login" || !reflect.DeepEqual(te.Policies, []string{"default", "foo"}) {
+		t.Fatalf("bad: %#v", te)
+	}
+
+	// The token type can be tuned
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/auth/foo/tune")
+	req.Data["token_type"] = "service"
+	req.ClientToken = root
+	if resp, err := c.HandleRequest(req); err != nil || resp.IsError() {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	lresp, err = c.HandleRequest(&logical.Request{
+		Path: "auth/foo/login",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if strings.HasPrefix(lresp.Auth.ClientToken, batchTokenPrefix) {
+		t.Fatalf("bad: %#v", lresp.Auth)
+	}
+}
+
 // Ensure we get a client token
 func TestCore_HandleLogin_Token(t *testing.T) {
 	noop := &NoopBackend{
@@ -731,6 +794,7 @@ func TestCore_HandleLogin_Token(t *testing.T) {
 		DisplayName:  "foo-armon",
 		TTL:          time.Hour * 24,
 		CreationTime: te.CreationTime,
+		Type:         logical.TokenTypeService,
 	}
 
 	if !reflect.DeepEqual(te, expect) {
@@ -1030,6 +1094,7 @@ func TestCore_HandleRequest_CreateToken_Lease(t *testing.T) {
 		Path:         "auth/token/create",
 		DisplayName:  "token",
 		CreationTime: te.CreationTime,
+		Type:         logical.TokenTypeService,
 		TTL:          time.Hour * 24 * 32,
 	}
 	if !reflect.DeepEqual(te, expect) {
@@ -1075,6 +1140,7 @@ func TestCore_HandleRequest_CreateToken_NoDefaultPolicy(t *testing.T) {
 		Path:         "auth/token/create",
 		DisplayName:  "token",
 		CreationTime: te.CreationTime,
+		Type:         logical.TokenTypeService,
 		TTL:          time.Hour * 24 * 32,
 	}
 	if !reflect.DeepEqual(te, expect) {
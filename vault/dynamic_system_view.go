@@ -7,6 +7,7 @@ import (
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/pluginutil"
 	"github.com/hashicorp/vault/helper/wrapping"
 	"github.com/hashicorp/vault/logical"
@@ -41,8 +42,21 @@ func (d dynamicSystemView) SudoPrivilege(ctx context.Context, path string, token
 		return false
 	}
 
+	// Policies are resolved in the namespace of the token
+	tokenNS := d.core.namespaceByID(te.NamespaceID)
+	if tokenNS == nil {
+		d.core.logger.Error("namespace of the token not found", "namespace_id", te.NamespaceID)
+		return false
+	}
+
+	entity, _, err := d.core.fetchEntityAndDerivedPolicies(te.EntityID)
+	if err != nil {
+		d.core.logger.Error("failed to fetch entity of the token", "error", err)
+		return false
+	}
+
 	// Construct the corresponding ACL object
-	acl, err := d.core.policyStore.ACL(ctx, te.Policies...)
+	acl, err := d.core.policyStore.ACL(namespace.ContextWithNamespace(ctx, tokenNS), entity, te.Policies...)
 	if err != nil {
 		d.core.logger.Error("failed to retrieve ACL for token's policies", "token_policies", te.Policies, "error", err)
 		return false
@@ -145,3 +159,37 @@ func (d dynamicSystemView) LookupPlugin(ctx context.Context, name string) (*plug
 func (d dynamicSystemView) MlockEnabled() bool {
 	return d.core.enableMlock
 }
+
+// EntityInfo returns the entity with the given ID from the identity store
+func (d dynamicSystemView) EntityInfo(entityID string) (*logical.Entity, error) {
+	if entityID == "" {
+		return nil, nil
+	}
+	if d.core.identityStore == nil {
+		return nil, fmt.Errorf("identity store is not available")
+	}
+
+	entity, err := d.core.identityStore.MemDBEntityByID(entityID, false)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil {
+		return nil, nil
+	}
+
+	aliases := make([]*logical.Alias, 0, len(entity.Aliases))
+	for _, alias := range entity.Aliases {
+		aliases = append(aliases, &logical.Alias{
+			MountType:     alias.MountType,
+			MountAccessor: alias.MountAccessor,
+			Name:          alias.Name,
+		})
+	}
+
+	return &logical.Entity{
+		ID:       entity.ID,
+		Name:     entity.Name,
+		Aliases:  aliases,
+		Metadata: entity.Metadata,
+	}, nil
+}
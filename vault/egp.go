@@ -0,0 +1,396 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/expr"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	// Failing advisory EGPs are only logged
+	egpEnforcementAdvisory = "advisory"
+
+	// Failing soft-mandatory EGPs deny the request unless the requester asks
+	// to override them
+	egpEnforcementSoftMandatory = "soft-mandatory"
+
+	// Failing hard-mandatory EGPs always deny the request
+	egpEnforcementHardMandatory = "hard-mandatory"
+)
+
+var egpEnforcementLevels = []string{
+	egpEnforcementAdvisory,
+	egpEnforcementSoftMandatory,
+	egpEnforcementHardMandatory,
+}
+
+// parseEGPPolicy validates the settings of an endpoint governing policy and
+// parses its rules
+func parseEGPPolicy(policy *Policy) error {
+	if !strutil.StrListContains(egpEnforcementLevels, policy.EnforcementLevel) {
+		return fmt.Errorf("enforcement level must be one of %s", strings.Join(egpEnforcementLevels, ", "))
+	}
+	if len(policy.EGPPaths) == 0 {
+		return fmt.Errorf("at least one path must be governed by the policy")
+	}
+	for _, path := range policy.EGPPaths {
+		if strings.Contains(strings.TrimSuffix(path, "*"), "*") {
+			return fmt.Errorf("path %q: the glob character is only supported at the end of paths", path)
+		}
+	}
+
+	program, err := expr.Parse(policy.Raw)
+	if err != nil {
+		return errwrap.Wrapf("failed to parse policy: {{err}}", err)
+	}
+	policy.egpProgram = program
+	return nil
+}
+
+// egpFromEntry returns the EGP held by a storage entry
+func egpFromEntry(name string, entry *PolicyEntry) (*Policy, error) {
+	policy := &Policy{
+		Name:             name,
+		Raw:              entry.Raw,
+		Type:             PolicyTypeEGP,
+		EnforcementLevel: entry.EnforcementLevel,
+		EGPPaths:         entry.EGPPaths,
+	}
+	if err := parseEGPPolicy(policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// egpGoverns returns whether the EGP governs the given path, which is
+// relative to the namespace of the EGP
+func (p *Policy) egpGoverns(path string) bool {
+	for _, egpPath := range p.EGPPaths {
+		if strings.HasSuffix(egpPath, "*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(egpPath, "*")) {
+				return true
+			}
+		} else if path == egpPath {
+			return true
+		}
+	}
+	return false
+}
+
+// egpInput holds what EGPs are evaluated against
+type egpInput struct {
+	req    *logical.Request
+	te     *TokenEntry
+	entity *identity.Entity
+	groups []*identity.Group
+	now    time.Time
+}
+
+func (c *Core) newEGPInput(req *logical.Request, te *TokenEntry, entity *identity.Entity) (*egpInput, error) {
+	in := &egpInput{
+		req:    req,
+		te:     te,
+		entity: entity,
+		now:    time.Now().UTC(),
+	}
+	if entity != nil {
+		groups, err := c.entityGroups(entity.ID)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to fetch groups of the entity: {{err}}", err)
+		}
+		in.groups = groups
+	}
+	return in, nil
+}
+
+// environment returns the values available to the rules of the EGPs. The path
+// is the path of the request relative to the namespace of the EGPs.
+func (in *egpInput) environment(path string) map[string]interface{} {
+	req := in.req
+
+	var remoteAddr interface{}
+	if req.Connection != nil && req.Connection.RemoteAddr != "" {
+		remoteAddr = req.Connection.RemoteAddr
+	}
+	var wrapTTL float64
+	if req.WrapInfo != nil {
+		wrapTTL = req.WrapInfo.TTL.Seconds()
+	}
+
+	var entity interface{}
+	if in.entity != nil {
+		aliases := make([]interface{}, 0, len(in.entity.Aliases))
+		for _, alias := range in.entity.Aliases {
+			aliases = append(aliases, map[string]interface{}{
+				"id":             alias.ID,
+				"name":           alias.Name,
+				"mount_accessor": alias.MountAccessor,
+				"mount_type":     alias.MountType,
+				"metadata":       alias.Metadata,
+			})
+		}
+		entity = map[string]interface{}{
+			"id":       in.entity.ID,
+			"name":     in.entity.Name,
+			"metadata": in.entity.Metadata,
+			"aliases":  aliases,
+			"policies": in.entity.Policies,
+		}
+	}
+	groupIDs := make([]interface{}, 0, len(in.groups))
+	groupNames := make([]interface{}, 0, len(in.groups))
+	for _, group := range in.groups {
+		groupIDs = append(groupIDs, group.ID)
+		groupNames = append(groupNames, group.Name)
+	}
+
+	var token interface{}
+	var mfaMethod string
+	if te := in.te; te != nil {
+		token = map[string]interface{}{
+			"accessor":      te.Accessor,
+			"display_name":  te.DisplayName,
+			"policies":      te.Policies,
+			"metadata":      te.Meta,
+			"path":          te.Path,
+			"type":          te.Type.String(),
+			"entity_id":     te.EntityID,
+			"creation_time": te.CreationTime,
+			"age":           int64(in.now.Sub(time.Unix(te.CreationTime, 0)).Seconds()),
+			"ttl":           int64(te.TTL.Seconds()),
+			"num_uses":      te.NumUses,
+		}
+		mfaMethod = te.MFAMethod
+	}
+
+	return map[string]interface{}{
+		"request": map[string]interface{}{
+			"path":            path,
+			"operation":       string(req.Operation),
+			"data":            req.Data,
+			"policy_override": req.PolicyOverride,
+			"unauthenticated": req.Unauthenticated,
+			"connection": map[string]interface{}{
+				"remote_addr": remoteAddr,
+			},
+			"wrapping": map[string]interface{}{
+				"ttl": wrapTTL,
+			},
+		},
+		"time": map[string]interface{}{
+			"now":     in.now.Unix(),
+			"year":    in.now.Year(),
+			"month":   int(in.now.Month()),
+			"day":     in.now.Day(),
+			"weekday": strings.ToLower(in.now.Weekday().String()),
+			"hour":    in.now.Hour(),
+			"minute":  in.now.Minute(),
+		},
+		"identity": map[string]interface{}{
+			"entity": entity,
+			"groups": map[string]interface{}{
+				"ids":   groupIDs,
+				"names": groupNames,
+			},
+		},
+		"token": token,
+		"mfa": map[string]interface{}{
+			"validated": mfaMethod != "",
+			"method":    mfaMethod,
+		},
+	}
+}
+
+// egpOutcome is the result of the evaluation of an EGP for a request
+type egpOutcome struct {
+	result *expr.Result
+	err    error
+
+	// denied is set if the EGP denies the request, and overridden if it
+	// would have but the requester overrode it
+	denied     bool
+	overridden bool
+}
+
+func (p *Policy) evaluateEGP(in *egpInput, path string) *egpOutcome {
+	result, err := p.egpProgram.Eval(in.environment(path))
+	out := &egpOutcome{
+		result: result,
+		err:    err,
+	}
+	if err == nil && result.Allowed {
+		return out
+	}
+
+	switch p.EnforcementLevel {
+	case egpEnforcementAdvisory:
+	case egpEnforcementSoftMandatory:
+		if in.req.PolicyOverride {
+			out.overridden = true
+		} else {
+			out.denied = true
+		}
+	default:
+		out.denied = true
+	}
+	return out
+}
+
+// performEGPChecks evaluates the endpoint governing policies of the namespace
+// of the request, and of the namespaces it is nested in, which govern the
+// request. The returned error holds the reasons the request was denied.
+func (c *Core) performEGPChecks(ctx context.Context, req *logical.Request, te *TokenEntry, entity *identity.Entity) error {
+	ns := namespace.FromContext(ctx)
+
+	var in *egpInput
+	var retErr *multierror.Error
+	for _, egpNS := range append(c.parentNamespaces(ns), ns) {
+		policies, err := c.policyStore.EGPs(namespace.ContextWithNamespace(ctx, egpNS))
+		if err != nil {
+			c.logger.Error("failed to fetch endpoint governing policies", "namespace", egpNS.Path, "error", err)
+			return ErrInternalError
+		}
+
+		path := egpNS.TrimmedPath(req.Path)
+		for _, policy := range policies {
+			if !policy.egpGoverns(path) {
+				continue
+			}
+
+			// Only gather the entity groups once a policy needs them
+			if in == nil {
+				in, err = c.newEGPInput(req, te, entity)
+				if err != nil {
+					c.logger.Error("failed to prepare endpoint governing policy evaluation", "error", err)
+					return ErrInternalError
+				}
+			}
+
+			out := policy.evaluateEGP(in, path)
+			if out.err == nil && out.result.Allowed {
+				continue
+			}
+
+			name := egpNS.Path + policy.Name
+			switch {
+			case out.denied:
+				msg := fmt.Sprintf("%s endpoint governing policy %q denied the request", policy.EnforcementLevel, name)
+				if policy.EnforcementLevel == egpEnforcementSoftMandatory {
+					msg += " and can be overridden"
+				}
+				if out.err != nil {
+					msg += fmt.Sprintf(": %v", out.err)
+				}
+				retErr = multierror.Append(retErr, fmt.Errorf("%s", msg))
+			case out.overridden:
+				c.logger.Info("soft-mandatory endpoint governing policy overridden", "policy", name, "request_path", req.Path, "error", out.err)
+			default:
+				c.logger.Warn("advisory endpoint governing policy failed", "policy", name, "request_path", req.Path, "error", out.err)
+			}
+		}
+	}
+
+	if retErr != nil {
+		return multierror.Append(retErr, logical.ErrPermissionDenied)
+	}
+	return nil
+}
+
+// handleEGPTest evaluates an endpoint governing policy for a simulated
+// request made with the token of the caller, without enforcing it
+func (b *SystemBackend) handleEGPTest(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	var policy *Policy
+	switch name, raw := d.Get("name").(string), d.Get("policy").(string); {
+	case name != "" && raw != "":
+		return logical.ErrorResponse("only one of 'name' and 'policy' can be set"), nil
+	case name != "":
+		p, err := b.Core.policyStore.GetPolicy(ctx, name, PolicyTypeEGP)
+		if err != nil {
+			return handleError(err)
+		}
+		if p == nil {
+			return logical.ErrorResponse(fmt.Sprintf("no endpoint governing policy named %q", name)), nil
+		}
+		policy = p
+	case raw != "":
+		policy = &Policy{
+			Raw:              raw,
+			Type:             PolicyTypeEGP,
+			EnforcementLevel: d.Get("enforcement_level").(string),
+			EGPPaths:         d.Get("paths").([]string),
+		}
+		if err := parseEGPPolicy(policy); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	default:
+		return logical.ErrorResponse("one of 'name' and 'policy' must be set"), nil
+	}
+
+	path := strings.TrimPrefix(d.Get("path").(string), "/")
+	if path == "" {
+		return logical.ErrorResponse("'path' must be set"), nil
+	}
+	operation := logical.Operation(d.Get("operation").(string))
+	switch operation {
+	case logical.CreateOperation, logical.ReadOperation, logical.UpdateOperation, logical.DeleteOperation, logical.ListOperation:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("unsupported operation %q", operation)), nil
+	}
+	remoteAddr := d.Get("remote_addr").(string)
+	if remoteAddr == "" && req.Connection != nil {
+		remoteAddr = req.Connection.RemoteAddr
+	}
+
+	te, err := b.Core.tokenStore.Lookup(ctx, req.ClientToken)
+	if err != nil {
+		return nil, err
+	}
+	if te == nil {
+		return nil, logical.ErrPermissionDenied
+	}
+	entity, _, err := b.Core.fetchEntityAndDerivedPolicies(te.EntityID)
+	if err != nil {
+		return nil, err
+	}
+
+	simulated := &logical.Request{
+		Operation: operation,
+		Path:      namespace.FromContext(ctx).Path + path,
+		Data:      d.Get("data").(map[string]interface{}),
+		Connection: &logical.Connection{
+			RemoteAddr: remoteAddr,
+		},
+		PolicyOverride: d.Get("policy_override").(bool),
+	}
+	in, err := b.Core.newEGPInput(simulated, te, entity)
+	if err != nil {
+		return nil, err
+	}
+	out := policy.evaluateEGP(in, path)
+	governed := policy.egpGoverns(path)
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"governed":          governed,
+			"allowed":           out.err == nil && out.result.Allowed,
+			"denied":            governed && out.denied,
+			"overridden":        governed && out.overridden,
+			"enforcement_level": policy.EnforcementLevel,
+			"rules":             out.result.Rules,
+		},
+	}
+	if out.err != nil {
+		resp.Data["error"] = out.err.Error()
+	}
+	return resp, nil
+}
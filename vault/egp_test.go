@@ -0,0 +1,260 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	credUserpass "github.com/hashicorp/vault/builtin/credential/userpass"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestEGP(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	doRequest := func(req *logical.Request) (*logical.Response, error) {
+		return c.HandleRequest(req)
+	}
+	mustRequest := func(token string, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := doRequest(&logical.Request{
+			Operation:   op,
+			Path:        path,
+			ClientToken: token,
+			Data:        data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: resp: %#v err: %v", op, path, resp, err)
+		}
+		return resp
+	}
+	setEGP := func(name, level, rules string) {
+		mustRequest(root, logical.UpdateOperation, "sys/policies/egp/"+name, map[string]interface{}{
+			"policy":            rules,
+			"enforcement_level": level,
+			"paths":             "secret/governed/*",
+		})
+	}
+
+	mustRequest(root, logical.UpdateOperation, "sys/policy/writer", map[string]interface{}{
+		"policy": `
+path "secret/*" {
+	capabilities = ["create", "read", "update"]
+}
+path "sys/policies/egp-test" {
+	capabilities = ["update"]
+}
+`,
+	})
+	te := &TokenEntry{
+		Path:     "auth/token/create",
+		Policies: []string{"default", "writer"},
+	}
+	if err := c.tokenStore.create(context.Background(), te); err != nil {
+		t.Fatal(err)
+	}
+	token := te.ID
+
+	// write returns the error of the request along with the message of the
+	// error response, which holds the reasons the request was denied
+	write := func(token, path, value string, override bool) error {
+		resp, err := doRequest(&logical.Request{
+			Operation:   logical.UpdateOperation,
+			Path:        path,
+			ClientToken: token,
+			Data: map[string]interface{}{
+				"value": value,
+			},
+			PolicyOverride: override,
+		})
+		if err != nil && resp != nil && resp.IsError() {
+			return fmt.Errorf("%v: %v", resp.Error(), err)
+		}
+		return err
+	}
+	isDenied := func(err error) bool {
+		return err != nil && strings.Contains(err.Error(), logical.ErrPermissionDenied.Error())
+	}
+
+	setEGP("values", egpEnforcementHardMandatory, `main = request.data.value == "ok"`)
+
+	if err := write(token, "secret/governed/foo", "ok", false); err != nil {
+		t.Fatalf("expected the request to be allowed: %v", err)
+	}
+	err := write(token, "secret/governed/foo", "bad", false)
+	if !isDenied(err) || !strings.Contains(err.Error(), `"values"`) {
+		t.Fatalf("expected the request to be denied by the EGP, got %v", err)
+	}
+	if err := write(token, "secret/governed/foo", "bad", true); !isDenied(err) {
+		t.Fatalf("expected hard-mandatory EGPs not to be overridable, got %v", err)
+	}
+
+	// Paths the EGP does not govern and root tokens are not affected
+	if err := write(token, "secret/other", "bad", false); err != nil {
+		t.Fatalf("expected the request to be allowed: %v", err)
+	}
+	if err := write(root, "secret/governed/foo", "bad", false); err != nil {
+		t.Fatalf("expected the root token to bypass EGPs: %v", err)
+	}
+
+	setEGP("values", egpEnforcementSoftMandatory, `main = request.data.value == "ok"`)
+	if err := write(token, "secret/governed/foo", "bad", false); !isDenied(err) {
+		t.Fatalf("expected the request to be denied by the EGP, got %v", err)
+	}
+	if err := write(token, "secret/governed/foo", "bad", true); err != nil {
+		t.Fatalf("expected the EGP to be overridden: %v", err)
+	}
+
+	setEGP("values", egpEnforcementAdvisory, `main = request.data.value == "ok"`)
+	if err := write(token, "secret/governed/foo", "bad", false); err != nil {
+		t.Fatalf("expected advisory EGPs not to deny: %v", err)
+	}
+
+	// A rule failing to evaluate denies the request
+	setEGP("values", egpEnforcementHardMandatory, `main = request.data.value > 1`)
+	if err := write(token, "secret/governed/foo", "bad", false); !isDenied(err) {
+		t.Fatalf("expected the request to be denied by the EGP, got %v", err)
+	}
+
+	// Token attributes are available to the rules
+	setEGP("values", egpEnforcementHardMandatory, `main = "writer" in token.policies and not mfa.validated`)
+	if err := write(token, "secret/governed/foo", "bad", false); err != nil {
+		t.Fatalf("expected the request to be allowed: %v", err)
+	}
+
+	resp := mustRequest(root, logical.ReadOperation, "sys/policies/egp/values", nil)
+	if resp.Data["enforcement_level"] != egpEnforcementHardMandatory {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if paths := resp.Data["paths"].([]string); len(paths) != 1 || paths[0] != "secret/governed/*" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	resp = mustRequest(root, logical.ListOperation, "sys/policies/egp/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "values" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Dry runs report the outcome without enforcing it
+	resp = mustRequest(token, logical.UpdateOperation, "sys/policies/egp-test", map[string]interface{}{
+		"policy": `
+is_ok = request.data.value == "ok"
+main = is_ok
+`,
+		"enforcement_level": egpEnforcementSoftMandatory,
+		"paths":             "secret/governed/*",
+		"path":              "secret/governed/foo",
+		"operation":         "update",
+		"data": map[string]interface{}{
+			"value": "bad",
+		},
+		"policy_override": true,
+	})
+	if resp.Data["governed"] != true || resp.Data["allowed"] != false || resp.Data["denied"] != false || resp.Data["overridden"] != true {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if rules := resp.Data["rules"].(map[string]interface{}); rules["is_ok"] != false {
+		t.Fatalf("bad rules: %#v", rules)
+	}
+
+	mustRequest(root, logical.DeleteOperation, "sys/policies/egp/values", nil)
+	if err := write(token, "secret/governed/foo", "bad", false); err != nil {
+		t.Fatalf("expected the request to be allowed once the EGP is deleted: %v", err)
+	}
+}
+
+func TestEGP_Invalid(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	cases := map[string]map[string]interface{}{
+		"bad rules": {
+			"policy": "main = (",
+			"paths":  "secret/*",
+		},
+		"no main rule": {
+			"policy": "allowed = true",
+			"paths":  "secret/*",
+		},
+		"bad level": {
+			"policy":            "main = true",
+			"paths":             "secret/*",
+			"enforcement_level": "sometimes",
+		},
+		"no paths": {
+			"policy": "main = true",
+		},
+		"bad glob": {
+			"policy": "main = true",
+			"paths":  "secret/*/foo",
+		},
+	}
+	for name, data := range cases {
+		resp, err := c.HandleRequest(&logical.Request{
+			Operation:   logical.UpdateOperation,
+			Path:        "sys/policies/egp/invalid",
+			ClientToken: root,
+			Data:        data,
+		})
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEGP_Login(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	if err := c.loadMounts(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	c.credentialBackends["userpass"] = credUserpass.Factory
+
+	for _, req := range []struct {
+		path string
+		data map[string]interface{}
+	}{
+		{"sys/auth/userpass", map[string]interface{}{
+			"type": "userpass",
+		}},
+		{"auth/userpass/users/test", map[string]interface{}{
+			"password": "foo",
+			"policies": "default",
+		}},
+		{"sys/policies/egp/office", map[string]interface{}{
+			"policy": `main = cidr_match("10.0.0.0/8", request.connection.remote_addr)`,
+			"paths":  "auth/userpass/login/*",
+		}},
+	} {
+		resp, err := c.HandleRequest(&logical.Request{
+			Operation:   logical.UpdateOperation,
+			Path:        req.path,
+			ClientToken: root,
+			Data:        req.data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: resp: %#v err: %v", req.path, resp, err)
+		}
+	}
+
+	login := func(remoteAddr string) (*logical.Response, error) {
+		return c.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "auth/userpass/login/test",
+			Data: map[string]interface{}{
+				"password": "foo",
+			},
+			Connection: &logical.Connection{
+				RemoteAddr: remoteAddr,
+			},
+		})
+	}
+
+	if _, err := login("192.168.1.1"); err == nil || !strings.Contains(err.Error(), logical.ErrPermissionDenied.Error()) {
+		t.Fatalf("expected the login to be denied, got %v", err)
+	}
+	resp, err := login("10.1.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || resp.Auth == nil || resp.Auth.ClientToken == "" {
+		t.Fatalf("expected a token, got %#v", resp)
+	}
+}
@@ -60,6 +60,7 @@ type ExpirationManager struct {
 	idView     *BarrierView
 	tokenView  *BarrierView
 	tokenStore *TokenStore
+	quotas     *quotaManager
 	logger     log.Logger
 
 	pending     map[string]*time.Timer
@@ -87,6 +88,7 @@ func NewExpirationManager(c *Core, view *BarrierView, logger log.Logger) *Expira
 		idView:     view.SubView(leaseViewPrefix),
 		tokenView:  view.SubView(tokenViewPrefix),
 		tokenStore: c.tokenStore,
+		quotas:     c.quotaManager,
 		logger:     logger,
 		pending:    make(map[string]*time.Timer),
 
@@ -124,6 +126,18 @@ func (c *Core) setupExpiration() error {
 	// Link the token store to this
 	c.tokenStore.SetExpirationManager(mgr)
 
+	// Link the quotas to this, so that lease count quotas count its leases
+	if c.quotaManager != nil {
+		c.quotaManager.expiration = mgr
+	}
+
+	// Performance standbys look leases up from storage, leaving restoring
+	// and revoking them to the active node
+	if c.ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		atomic.StoreInt32(&mgr.restoreMode, 0)
+		return nil
+	}
+
 	// Restore the existing state
 	c.logger.Info("restoring leases")
 	errorFunc := func() {
@@ -212,6 +226,11 @@ func (m *ExpirationManager) Tidy() error {
 
 		var isValid, ok bool
 		revokeLease := false
+		// Leases of orphan batch tokens are not tied to any token
+		if le.ClientToken == "" && le.ClientTokenType == logical.TokenTypeBatch {
+			return
+		}
+
 		if le.ClientToken == "" {
 			m.logger.Debug("revoking lease which has an empty token", "lease_id", leaseID)
 			revokeLease = true
@@ -450,8 +469,9 @@ func (m *ExpirationManager) Stop() error {
 	close(m.quitCh)
 
 	m.pendingLock.Lock()
-	for _, timer := range m.pending {
+	for leaseID, timer := range m.pending {
 		timer.Stop()
+		m.quotas.leaseDeleted(leaseID)
 	}
 	m.pending = make(map[string]*time.Timer)
 	m.pendingLock.Unlock()
@@ -510,7 +530,8 @@ func (m *ExpirationManager) revokeCommon(leaseID string, force, skipToken bool)
 	}
 
 	// Delete the secondary index, but only if it's a leased secret (not auth)
-	if le.Secret != nil {
+	// tied to a token
+	if le.Secret != nil && le.ClientToken != "" {
 		if err := m.removeIndexByToken(le.ClientToken, le.LeaseID); err != nil {
 			return err
 		}
@@ -521,6 +542,7 @@ func (m *ExpirationManager) revokeCommon(leaseID string, force, skipToken bool)
 	if timer, ok := m.pending[leaseID]; ok {
 		timer.Stop()
 		delete(m.pending, leaseID)
+		m.quotas.leaseDeleted(leaseID)
 	}
 	m.pendingLock.Unlock()
 
@@ -833,12 +855,47 @@ func (m *ExpirationManager) Register(req *logical.Request, resp *logical.Respons
 
 	leaseID := path.Join(req.Path, leaseUUID)
 
+	// Leases of batch tokens are tied to the parent of the token, since the
+	// batch token itself is never revoked. Leases of orphan batch tokens are
+	// only bound by their TTL.
+	clientToken := req.ClientToken
+	var clientTokenType logical.TokenType
+	if isBatchToken(clientToken) {
+		te, err := m.tokenStore.Lookup(m.quitContext, clientToken)
+		if err != nil {
+			return "", err
+		}
+		if te == nil {
+			return "", fmt.Errorf("expiration: batch token not found")
+		}
+		clientToken = te.Parent
+		clientTokenType = logical.TokenTypeBatch
+	}
+
+	// A lease beyond the lease count quota governing it is rejected. The
+	// secret has been generated already, so the lease is still stored and
+	// then revoked, which is retried like for any expired lease if it fails,
+	// rather than leaving the secret behind.
+	var quotaErr error
+	if quota := m.quotas.leaseCountQuotaFor(leaseID); quota != nil {
+		if quota.reserve() {
+			defer quota.release()
+		} else {
+			metrics.IncrCounter([]string{"quota", "lease_count", "violation"}, 1)
+			metrics.IncrCounter([]string{"quota", "lease_count", quota.Name, "violation"}, 1)
+			quotaErr = &logical.RequestQuotaError{
+				Msg: fmt.Sprintf("lease count quota %q exceeded", quota.Name),
+			}
+		}
+	}
+
+	tracked := false
 	defer func() {
 		// If there is an error we want to rollback as much as possible (note
 		// that errors here are ignored to do as much cleanup as we can). We
 		// want to revoke a generated secret (since an error means we may not
 		// be successfully tracking it), remove indexes, and delete the entry.
-		if retErr != nil {
+		if retErr != nil && !tracked {
 			revResp, err := m.router.Route(m.quitContext, logical.RevokeRequest(req.Path, resp.Secret, resp.Data))
 			if err != nil {
 				retErr = multierror.Append(retErr, errwrap.Wrapf("an additional internal error was encountered revoking the newly-generated secret: {{err}}", err))
@@ -850,30 +907,44 @@ func (m *ExpirationManager) Register(req *logical.Request, resp *logical.Respons
 				retErr = multierror.Append(retErr, errwrap.Wrapf("an additional error was encountered deleting any lease associated with the newly-generated secret: {{err}}", err))
 			}
 
-			if err := m.removeIndexByToken(req.ClientToken, leaseID); err != nil {
-				retErr = multierror.Append(retErr, errwrap.Wrapf("an additional error was encountered removing lease indexes associated with the newly-generated secret: {{err}}", err))
+			if clientToken != "" {
+				if err := m.removeIndexByToken(clientToken, leaseID); err != nil {
+					retErr = multierror.Append(retErr, errwrap.Wrapf("an additional error was encountered removing lease indexes associated with the newly-generated secret: {{err}}", err))
+				}
 			}
 		}
 	}()
 
 	le := leaseEntry{
-		LeaseID:     leaseID,
-		ClientToken: req.ClientToken,
-		Path:        req.Path,
-		Data:        resp.Data,
-		Secret:      resp.Secret,
-		IssueTime:   time.Now(),
-		ExpireTime:  resp.Secret.ExpirationTime(),
+		LeaseID:         leaseID,
+		ClientToken:     clientToken,
+		ClientTokenType: clientTokenType,
+		Path:            req.Path,
+		Data:            resp.Data,
+		Secret:          resp.Secret,
+		IssueTime:       time.Now(),
+		ExpireTime:      resp.Secret.ExpirationTime(),
 	}
-
 	// Encode the entry
 	if err := m.persistEntry(&le); err != nil {
 		return "", err
 	}
 
 	// Maintain secondary index by token
-	if err := m.createIndexByToken(le.ClientToken, le.LeaseID); err != nil {
-		return "", err
+	if le.ClientToken != "" {
+		if err := m.createIndexByToken(le.ClientToken, le.LeaseID); err != nil {
+			return "", err
+		}
+	}
+
+	if quotaErr != nil {
+		tracked = true
+		if err := m.revokeCommon(le.LeaseID, false, false); err != nil {
+			m.logger.Error("failed to revoke lease beyond its quota, retrying", "lease_id", le.LeaseID, "error", err)
+			le.ExpireTime = le.IssueTime
+			m.updatePending(&le, 0)
+		}
+		return "", quotaErr
 	}
 
 	// Setup revocation timer if there is a lease
@@ -970,6 +1041,18 @@ func (m *ExpirationManager) FetchLeaseTimes(leaseID string) (*leaseEntry, error)
 	return ret, nil
 }
 
+// countPending returns the number of leases pending expiration whose IDs
+// start with the given prefix. The pending lock must be held.
+func (m *ExpirationManager) countPending(prefix string) int {
+	count := 0
+	for leaseID := range m.pending {
+		if strings.HasPrefix(leaseID, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
 // updatePending is used to update a pending invocation for a lease
 func (m *ExpirationManager) updatePending(le *leaseEntry, leaseTotal time.Duration) {
 	m.pendingLock.Lock()
@@ -985,6 +1068,7 @@ func (m *ExpirationManager) updatePending(le *leaseEntry, leaseTotal time.Durati
 		if ok {
 			timer.Stop()
 			delete(m.pending, le.LeaseID)
+			m.quotas.leaseDeleted(le.LeaseID)
 		}
 		return
 	}
@@ -995,6 +1079,7 @@ func (m *ExpirationManager) updatePending(le *leaseEntry, leaseTotal time.Durati
 			m.expireID(le.LeaseID)
 		})
 		m.pending[le.LeaseID] = timer
+		m.quotas.leaseCreated(le.LeaseID)
 		return
 	}
 
@@ -1006,7 +1091,10 @@ func (m *ExpirationManager) updatePending(le *leaseEntry, leaseTotal time.Durati
 func (m *ExpirationManager) expireID(leaseID string) {
 	// Clear from the pending expiration
 	m.pendingLock.Lock()
-	delete(m.pending, leaseID)
+	if _, ok := m.pending[leaseID]; ok {
+		delete(m.pending, leaseID)
+		m.quotas.leaseDeleted(leaseID)
+	}
 	m.pendingLock.Unlock()
 
 	for attempt := uint(0); attempt < maxRevokeAttempts; attempt++ {
@@ -1077,7 +1165,14 @@ func (m *ExpirationManager) renewAuthEntry(req *logical.Request, le *leaseEntry,
 	auth := *le.Auth
 	auth.IssueTime = le.IssueTime
 	auth.Increment = increment
-	if strings.HasPrefix(le.Path, "auth/token/") {
+
+	// Tokens created in a namespace have the namespace path in front of the
+	// token store's mount path
+	path := le.Path
+	if me := m.router.MatchingMountEntry(le.Path); me != nil {
+		path = me.Namespace().TrimmedPath(le.Path)
+	}
+	if strings.HasPrefix(path, "auth/token/") {
 		auth.ClientToken = le.ClientToken
 	} else {
 		auth.ClientToken = ""
@@ -1286,6 +1381,7 @@ func (m *ExpirationManager) emitMetrics() {
 type leaseEntry struct {
 	LeaseID         string                 `json:"lease_id"`
 	ClientToken     string                 `json:"client_token"`
+	ClientTokenType logical.TokenType      `json:"token_type"`
 	Path            string                 `json:"path"`
 	Data            map[string]interface{} `json:"data"`
 	Secret          *logical.Secret        `json:"secret"`
@@ -140,7 +140,7 @@ func (i *IdentityStore) pathEntityMergeID() framework.OperationFunc {
 			return nil, err
 		}
 
-		if toEntityForLocking == nil {
+		if toEntityForLocking == nil || !namespaceMatches(ctx, toEntityForLocking.NamespaceID) {
 			return logical.ErrorResponse("entity id to merge to is invalid"), nil
 		}
 
@@ -178,7 +178,7 @@ func (i *IdentityStore) pathEntityMergeID() framework.OperationFunc {
 				return nil, err
 			}
 
-			if lockFromEntity == nil {
+			if lockFromEntity == nil || !namespaceMatches(ctx, lockFromEntity.NamespaceID) {
 				return logical.ErrorResponse("entity id to merge from is invalid"), nil
 			}
 
@@ -309,7 +309,7 @@ func (i *IdentityStore) pathEntityRegister() framework.OperationFunc {
 			return i.pathEntityIDUpdate()(ctx, req, d)
 		}
 
-		return i.handleEntityUpdateCommon(req, d, nil)
+		return i.handleEntityUpdateCommon(ctx, req, d, nil)
 	}
 }
 
@@ -327,16 +327,16 @@ func (i *IdentityStore) pathEntityIDUpdate() framework.OperationFunc {
 		if err != nil {
 			return nil, err
 		}
-		if entity == nil {
+		if entity == nil || !namespaceMatches(ctx, entity.NamespaceID) {
 			return nil, fmt.Errorf("invalid entity id")
 		}
 
-		return i.handleEntityUpdateCommon(req, d, entity)
+		return i.handleEntityUpdateCommon(ctx, req, d, entity)
 	}
 }
 
 // handleEntityUpdateCommon is used to update an entity
-func (i *IdentityStore) handleEntityUpdateCommon(req *logical.Request, d *framework.FieldData, entity *identity.Entity) (*logical.Response, error) {
+func (i *IdentityStore) handleEntityUpdateCommon(ctx context.Context, req *logical.Request, d *framework.FieldData, entity *identity.Entity) (*logical.Response, error) {
 	var err error
 	var newEntity bool
 
@@ -356,7 +356,7 @@ func (i *IdentityStore) handleEntityUpdateCommon(req *logical.Request, d *framew
 	// Get the name
 	entityName := d.Get("name").(string)
 	if entityName != "" {
-		entityByName, err := i.MemDBEntityByName(entityName, false)
+		entityByName, err := i.MemDBEntityByName(ctx, entityName, false)
 		if err != nil {
 			return nil, err
 		}
@@ -376,7 +376,7 @@ func (i *IdentityStore) handleEntityUpdateCommon(req *logical.Request, d *framew
 		entity.Metadata = metadata.(map[string]string)
 	}
 	// ID creation and some validations
-	err = i.sanitizeEntity(entity)
+	err = i.sanitizeEntity(ctx, entity)
 	if err != nil {
 		return nil, err
 	}
@@ -418,7 +418,7 @@ func (i *IdentityStore) pathEntityIDRead() framework.OperationFunc {
 		if err != nil {
 			return nil, err
 		}
-		if entity == nil {
+		if entity == nil || !namespaceMatches(ctx, entity.NamespaceID) {
 			return nil, nil
 		}
 
@@ -492,6 +492,14 @@ func (i *IdentityStore) pathEntityIDDelete() framework.OperationFunc {
 			return logical.ErrorResponse("missing entity id"), nil
 		}
 
+		entity, err := i.MemDBEntityByID(entityID, false)
+		if err != nil {
+			return nil, err
+		}
+		if entity == nil || !namespaceMatches(ctx, entity.NamespaceID) {
+			return nil, nil
+		}
+
 		return nil, i.deleteEntity(entityID)
 	}
 }
@@ -512,7 +520,11 @@ func (i *IdentityStore) pathEntityIDList() framework.OperationFunc {
 			if raw == nil {
 				break
 			}
-			entityIDs = append(entityIDs, raw.(*identity.Entity).ID)
+			entity := raw.(*identity.Entity)
+			if !namespaceMatches(ctx, entity.NamespaceID) {
+				continue
+			}
+			entityIDs = append(entityIDs, entity.ID)
 		}
 
 		return logical.ListResponse(entityIDs), nil
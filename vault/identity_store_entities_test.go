@@ -494,7 +494,7 @@ func TestIdentityStore_MemDBEntityIndexes(t *testing.T) {
 	}
 
 	// Fetch the entity using its name
-	entityFetched, err = is.MemDBEntityByName(entity.Name, false)
+	entityFetched, err = is.MemDBEntityByName(context.Background(), entity.Name, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -542,7 +542,7 @@ func TestIdentityStore_MemDBEntityIndexes(t *testing.T) {
 		t.Fatalf("bad: entity; expected: nil, actual: %#v\n", entityFetched)
 	}
 
-	entityFetched, err = is.MemDBEntityByName(entity.Name, false)
+	entityFetched, err = is.MemDBEntityByName(context.Background(), entity.Name, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -91,7 +91,7 @@ func (i *IdentityStore) pathGroupAliasRegister() framework.OperationFunc {
 		i.groupLock.Lock()
 		defer i.groupLock.Unlock()
 
-		return i.handleGroupAliasUpdateCommon(req, d, nil)
+		return i.handleGroupAliasUpdateCommon(ctx, req, d, nil)
 	}
 }
 
@@ -113,11 +113,11 @@ func (i *IdentityStore) pathGroupAliasIDUpdate() framework.OperationFunc {
 			return logical.ErrorResponse("invalid group alias ID"), nil
 		}
 
-		return i.handleGroupAliasUpdateCommon(req, d, groupAlias)
+		return i.handleGroupAliasUpdateCommon(ctx, req, d, groupAlias)
 	}
 }
 
-func (i *IdentityStore) handleGroupAliasUpdateCommon(req *logical.Request, d *framework.FieldData, groupAlias *identity.Alias) (*logical.Response, error) {
+func (i *IdentityStore) handleGroupAliasUpdateCommon(ctx context.Context, req *logical.Request, d *framework.FieldData, groupAlias *identity.Alias) (*logical.Response, error) {
 	var err error
 	var newGroupAlias bool
 	var group *identity.Group
@@ -133,7 +133,7 @@ func (i *IdentityStore) handleGroupAliasUpdateCommon(req *logical.Request, d *fr
 		if err != nil {
 			return nil, err
 		}
-		if group == nil {
+		if group == nil || !namespaceMatches(ctx, group.NamespaceID) {
 			return logical.ErrorResponse("invalid group ID"), nil
 		}
 		if group.Type != groupTypeExternal {
@@ -208,7 +208,7 @@ func (i *IdentityStore) handleGroupAliasUpdateCommon(req *logical.Request, d *fr
 	group.Alias.MountType = mountValidationResp.MountType
 	group.Alias.MountAccessor = mountValidationResp.MountAccessor
 
-	err = i.sanitizeAndUpsertGroup(group, nil)
+	err = i.sanitizeAndUpsertGroup(ctx, group, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -130,7 +130,7 @@ func (i *IdentityStore) pathGroupRegister() framework.OperationFunc {
 		i.groupLock.Lock()
 		defer i.groupLock.Unlock()
 
-		return i.handleGroupUpdateCommon(req, d, nil)
+		return i.handleGroupUpdateCommon(ctx, req, d, nil)
 	}
 }
 
@@ -148,15 +148,15 @@ func (i *IdentityStore) pathGroupIDUpdate() framework.OperationFunc {
 		if err != nil {
 			return nil, err
 		}
-		if group == nil {
+		if group == nil || !namespaceMatches(ctx, group.NamespaceID) {
 			return logical.ErrorResponse("invalid group ID"), nil
 		}
 
-		return i.handleGroupUpdateCommon(req, d, group)
+		return i.handleGroupUpdateCommon(ctx, req, d, group)
 	}
 }
 
-func (i *IdentityStore) handleGroupUpdateCommon(req *logical.Request, d *framework.FieldData, group *identity.Group) (*logical.Response, error) {
+func (i *IdentityStore) handleGroupUpdateCommon(ctx context.Context, req *logical.Request, d *framework.FieldData, group *identity.Group) (*logical.Response, error) {
 	var err error
 	var newGroup bool
 	if group == nil {
@@ -193,7 +193,7 @@ func (i *IdentityStore) handleGroupUpdateCommon(req *logical.Request, d *framewo
 	groupName := d.Get("name").(string)
 	if groupName != "" {
 		// Check if there is a group already existing for the given name
-		groupByName, err := i.MemDBGroupByName(groupName, false)
+		groupByName, err := i.MemDBGroupByName(ctx, groupName, false)
 		if err != nil {
 			return nil, err
 		}
@@ -237,7 +237,7 @@ func (i *IdentityStore) handleGroupUpdateCommon(req *logical.Request, d *framewo
 		memberGroupIDs = memberGroupIDsRaw.([]string)
 	}
 
-	err = i.sanitizeAndUpsertGroup(group, memberGroupIDs)
+	err = i.sanitizeAndUpsertGroup(ctx, group, memberGroupIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -262,6 +262,9 @@ func (i *IdentityStore) pathGroupIDRead() framework.OperationFunc {
 		if err != nil {
 			return nil, err
 		}
+		if group != nil && !namespaceMatches(ctx, group.NamespaceID) {
+			return nil, nil
+		}
 
 		return i.handleGroupReadCommon(group)
 	}
@@ -316,6 +319,15 @@ func (i *IdentityStore) pathGroupIDDelete() framework.OperationFunc {
 		if groupID == "" {
 			return logical.ErrorResponse("empty group ID"), nil
 		}
+
+		group, err := i.MemDBGroupByID(groupID, false)
+		if err != nil {
+			return nil, err
+		}
+		if group == nil || !namespaceMatches(ctx, group.NamespaceID) {
+			return nil, nil
+		}
+
 		return nil, i.deleteGroupByID(groupID)
 	}
 }
@@ -335,7 +347,11 @@ func (i *IdentityStore) pathGroupIDList() framework.OperationFunc {
 			if raw == nil {
 				break
 			}
-			groupIDs = append(groupIDs, raw.(*identity.Group).ID)
+			group := raw.(*identity.Group)
+			if !namespaceMatches(ctx, group.NamespaceID) {
+				continue
+			}
+			groupIDs = append(groupIDs, group.ID)
 		}
 
 		return logical.ListResponse(groupIDs), nil
@@ -145,7 +145,7 @@ func TestIdentityStore_MemDBGroupIndexes(t *testing.T) {
 	var fetchedGroup *identity.Group
 
 	// Fetch group given the name
-	fetchedGroup, err = i.MemDBGroupByName("testgroupname", false)
+	fetchedGroup, err = i.MemDBGroupByName(context.Background(), "testgroupname", false)
 	if err != nil {
 		t.Fatal(err)
 	}
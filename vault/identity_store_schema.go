@@ -101,8 +101,15 @@ func entitiesTableSchema() *memdb.TableSchema {
 			"name": &memdb.IndexSchema{
 				Name:   "name",
 				Unique: true,
-				Indexer: &memdb.StringFieldIndex{
-					Field: "Name",
+				Indexer: &memdb.CompoundIndex{
+					Indexes: []memdb.Indexer{
+						&memdb.StringFieldIndex{
+							Field: "NamespaceID",
+						},
+						&memdb.StringFieldIndex{
+							Field: "Name",
+						},
+					},
 				},
 			},
 			"metadata": &memdb.IndexSchema{
@@ -147,8 +154,15 @@ func groupsTableSchema() *memdb.TableSchema {
 			"name": {
 				Name:   "name",
 				Unique: true,
-				Indexer: &memdb.StringFieldIndex{
-					Field: "Name",
+				Indexer: &memdb.CompoundIndex{
+					Indexes: []memdb.Indexer{
+						&memdb.StringFieldIndex{
+							Field: "NamespaceID",
+						},
+						&memdb.StringFieldIndex{
+							Field: "Name",
+						},
+					},
 				},
 			},
 			"member_entity_ids": {
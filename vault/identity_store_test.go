@@ -17,7 +17,7 @@ func TestIdentityStore_CreateOrFetchEntity(t *testing.T) {
 		Name:          "githubuser",
 	}
 
-	entity, err := is.CreateOrFetchEntity(alias)
+	entity, err := is.CreateOrFetchEntity(context.Background(), alias)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -33,7 +33,7 @@ func TestIdentityStore_CreateOrFetchEntity(t *testing.T) {
 		t.Fatalf("bad: alias name; expected: %q, actual: %q", alias.Name, entity.Aliases[0].Name)
 	}
 
-	entity, err = is.CreateOrFetchEntity(alias)
+	entity, err = is.CreateOrFetchEntity(context.Background(), alias)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -7,11 +7,13 @@ import (
 	"sync"
 
 	"github.com/golang/protobuf/ptypes"
+	"github.com/hashicorp/errwrap"
 	memdb "github.com/hashicorp/go-memdb"
 	uuid "github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/storagepacker"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
@@ -826,6 +828,12 @@ func (i *IdentityStore) MemDBUpsertEntityInTxn(txn *memdb.Txn, entity *identity.
 		return fmt.Errorf("entity is nil")
 	}
 
+	// Entities created before namespaces existed belong to the root
+	// namespace
+	if entity.NamespaceID == "" {
+		entity.NamespaceID = namespace.RootNamespaceID
+	}
+
 	entityRaw, err := txn.First(entitiesTable, "id", entity.ID)
 	if err != nil {
 		return fmt.Errorf("failed to lookup entity from memdb using entity id: %v", err)
@@ -903,7 +911,7 @@ func (i *IdentityStore) MemDBEntityByID(entityID string, clone bool) (*identity.
 	return i.MemDBEntityByIDInTxn(txn, entityID, clone)
 }
 
-func (i *IdentityStore) MemDBEntityByNameInTxn(txn *memdb.Txn, entityName string, clone bool) (*identity.Entity, error) {
+func (i *IdentityStore) MemDBEntityByNameInTxn(ctx context.Context, txn *memdb.Txn, entityName string, clone bool) (*identity.Entity, error) {
 	if entityName == "" {
 		return nil, fmt.Errorf("missing entity name")
 	}
@@ -912,7 +920,7 @@ func (i *IdentityStore) MemDBEntityByNameInTxn(txn *memdb.Txn, entityName string
 		return nil, fmt.Errorf("txn is nil")
 	}
 
-	entityRaw, err := txn.First(entitiesTable, "name", entityName)
+	entityRaw, err := txn.First(entitiesTable, "name", namespace.FromContext(ctx).ID, entityName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch entity from memdb using entity name: %v", err)
 	}
@@ -933,14 +941,14 @@ func (i *IdentityStore) MemDBEntityByNameInTxn(txn *memdb.Txn, entityName string
 	return entity, nil
 }
 
-func (i *IdentityStore) MemDBEntityByName(entityName string, clone bool) (*identity.Entity, error) {
+func (i *IdentityStore) MemDBEntityByName(ctx context.Context, entityName string, clone bool) (*identity.Entity, error) {
 	if entityName == "" {
 		return nil, fmt.Errorf("missing entity name")
 	}
 
 	txn := i.db.Txn(false)
 
-	return i.MemDBEntityByNameInTxn(txn, entityName, clone)
+	return i.MemDBEntityByNameInTxn(ctx, txn, entityName, clone)
 }
 
 func (i *IdentityStore) MemDBEntitiesByMetadata(filters map[string]string, clone bool) ([]*identity.Entity, error) {
@@ -1181,7 +1189,7 @@ func (i *IdentityStore) sanitizeAlias(alias *identity.Alias) error {
 	return nil
 }
 
-func (i *IdentityStore) sanitizeEntity(entity *identity.Entity) error {
+func (i *IdentityStore) sanitizeEntity(ctx context.Context, entity *identity.Entity) error {
 	var err error
 
 	if entity == nil {
@@ -1197,11 +1205,14 @@ func (i *IdentityStore) sanitizeEntity(entity *identity.Entity) error {
 
 		// Set the hash value of the storage bucket key in entity
 		entity.BucketKeyHash = i.entityPacker.BucketKeyHashByItemID(entity.ID)
+
+		// New entities belong to the namespace they are created in
+		entity.NamespaceID = namespace.FromContext(ctx).ID
 	}
 
 	// Create a name if there isn't one already
 	if entity.Name == "" {
-		entity.Name, err = i.generateName("entity")
+		entity.Name, err = i.generateName(ctx, "entity")
 		if err != nil {
 			return fmt.Errorf("failed to generate entity name")
 		}
@@ -1224,7 +1235,7 @@ func (i *IdentityStore) sanitizeEntity(entity *identity.Entity) error {
 	return nil
 }
 
-func (i *IdentityStore) sanitizeAndUpsertGroup(group *identity.Group, memberGroupIDs []string) error {
+func (i *IdentityStore) sanitizeAndUpsertGroup(ctx context.Context, group *identity.Group, memberGroupIDs []string) error {
 	var err error
 
 	if group == nil {
@@ -1240,11 +1251,14 @@ func (i *IdentityStore) sanitizeAndUpsertGroup(group *identity.Group, memberGrou
 
 		// Set the hash value of the storage bucket key in group
 		group.BucketKeyHash = i.groupPacker.BucketKeyHashByItemID(group.ID)
+
+		// New groups belong to the namespace they are created in
+		group.NamespaceID = namespace.FromContext(ctx).ID
 	}
 
 	// Create a name if there isn't one already
 	if group.Name == "" {
-		group.Name, err = i.generateName("group")
+		group.Name, err = i.generateName(ctx, "group")
 		if err != nil {
 			return fmt.Errorf("failed to generate group name")
 		}
@@ -1267,7 +1281,7 @@ func (i *IdentityStore) sanitizeAndUpsertGroup(group *identity.Group, memberGrou
 	// Remove duplicate entity IDs and check if all IDs are valid
 	group.MemberEntityIDs = strutil.RemoveDuplicates(group.MemberEntityIDs, false)
 	for _, entityID := range group.MemberEntityIDs {
-		err = i.validateEntityID(entityID)
+		err = i.validateEntityID(ctx, entityID)
 		if err != nil {
 			return err
 		}
@@ -1284,7 +1298,7 @@ func (i *IdentityStore) sanitizeAndUpsertGroup(group *identity.Group, memberGrou
 		if err != nil {
 			return err
 		}
-		if memberGroup == nil {
+		if memberGroup == nil || !namespaceMatches(ctx, memberGroup.NamespaceID) {
 			return fmt.Errorf("invalid member group ID %q", memberGroupID)
 		}
 
@@ -1372,12 +1386,12 @@ func (i *IdentityStore) validateMemberGroupID(groupID string, memberGroupID stri
 	return nil
 }
 
-func (i *IdentityStore) validateEntityID(entityID string) error {
+func (i *IdentityStore) validateEntityID(ctx context.Context, entityID string) error {
 	entity, err := i.MemDBEntityByID(entityID, false)
 	if err != nil {
 		return fmt.Errorf("failed to validate entity ID %q: %v", entityID, err)
 	}
-	if entity == nil {
+	if entity == nil || !namespaceMatches(ctx, entity.NamespaceID) {
 		return fmt.Errorf("invalid entity ID %q", entityID)
 	}
 	return nil
@@ -1526,7 +1540,7 @@ func satisfiesMetadataFilters(meta map[string]string, filters map[string]string)
 	return true
 }
 
-func (i *IdentityStore) MemDBGroupByNameInTxn(txn *memdb.Txn, groupName string, clone bool) (*identity.Group, error) {
+func (i *IdentityStore) MemDBGroupByNameInTxn(ctx context.Context, txn *memdb.Txn, groupName string, clone bool) (*identity.Group, error) {
 	if groupName == "" {
 		return nil, fmt.Errorf("missing group name")
 	}
@@ -1535,7 +1549,7 @@ func (i *IdentityStore) MemDBGroupByNameInTxn(txn *memdb.Txn, groupName string,
 		return nil, fmt.Errorf("txn is nil")
 	}
 
-	groupRaw, err := txn.First(groupsTable, "name", groupName)
+	groupRaw, err := txn.First(groupsTable, "name", namespace.FromContext(ctx).ID, groupName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch group from memdb using group name: %v", err)
 	}
@@ -1556,14 +1570,14 @@ func (i *IdentityStore) MemDBGroupByNameInTxn(txn *memdb.Txn, groupName string,
 	return group, nil
 }
 
-func (i *IdentityStore) MemDBGroupByName(groupName string, clone bool) (*identity.Group, error) {
+func (i *IdentityStore) MemDBGroupByName(ctx context.Context, groupName string, clone bool) (*identity.Group, error) {
 	if groupName == "" {
 		return nil, fmt.Errorf("missing group name")
 	}
 
 	txn := i.db.Txn(false)
 
-	return i.MemDBGroupByNameInTxn(txn, groupName, clone)
+	return i.MemDBGroupByNameInTxn(ctx, txn, groupName, clone)
 }
 
 func (i *IdentityStore) UpsertGroup(group *identity.Group, persist bool) error {
@@ -1642,6 +1656,11 @@ func (i *IdentityStore) MemDBUpsertGroupInTxn(txn *memdb.Txn, group *identity.Gr
 		return fmt.Errorf("group is nil")
 	}
 
+	// Groups created before namespaces existed belong to the root namespace
+	if group.NamespaceID == "" {
+		group.NamespaceID = namespace.RootNamespaceID
+	}
+
 	groupRaw, err := txn.First(groupsTable, "id", group.ID)
 	if err != nil {
 		return fmt.Errorf("failed to lookup group from memdb using group id: %v", err)
@@ -1739,7 +1758,7 @@ func (i *IdentityStore) MemDBDeleteGroupByIDInTxn(txn *memdb.Txn, groupID string
 	return nil
 }
 
-func (i *IdentityStore) deleteGroupByName(groupName string) error {
+func (i *IdentityStore) deleteGroupByName(ctx context.Context, groupName string) error {
 	var err error
 	var group *identity.Group
 
@@ -1756,7 +1775,7 @@ func (i *IdentityStore) deleteGroupByName(groupName string) error {
 	defer txn.Abort()
 
 	// Fetch the group using its ID
-	group, err = i.MemDBGroupByNameInTxn(txn, groupName, false)
+	group, err = i.MemDBGroupByNameInTxn(ctx, txn, groupName, false)
 	if err != nil {
 		return err
 	}
@@ -1767,7 +1786,7 @@ func (i *IdentityStore) deleteGroupByName(groupName string) error {
 	}
 
 	// Delete the group using the same transaction
-	err = i.MemDBDeleteGroupByNameInTxn(txn, group.Name)
+	err = i.MemDBDeleteGroupByNameInTxn(ctx, txn, group.Name)
 	if err != nil {
 		return err
 	}
@@ -1784,7 +1803,7 @@ func (i *IdentityStore) deleteGroupByName(groupName string) error {
 	return nil
 }
 
-func (i *IdentityStore) MemDBDeleteGroupByNameInTxn(txn *memdb.Txn, groupName string) error {
+func (i *IdentityStore) MemDBDeleteGroupByNameInTxn(ctx context.Context, txn *memdb.Txn, groupName string) error {
 	if groupName == "" {
 		return nil
 	}
@@ -1793,7 +1812,7 @@ func (i *IdentityStore) MemDBDeleteGroupByNameInTxn(txn *memdb.Txn, groupName st
 		return fmt.Errorf("txn is nil")
 	}
 
-	group, err := i.MemDBGroupByNameInTxn(txn, groupName, false)
+	group, err := i.MemDBGroupByNameInTxn(ctx, txn, groupName, false)
 	if err != nil {
 		return err
 	}
@@ -2144,7 +2163,7 @@ func (i *IdentityStore) MemDBGroupIterator(ws memdb.WatchSet) (memdb.ResultItera
 	return iter, nil
 }
 
-func (i *IdentityStore) generateName(entryType string) (string, error) {
+func (i *IdentityStore) generateName(ctx context.Context, entryType string) (string, error) {
 	var name string
 OUTER:
 	for {
@@ -2156,7 +2175,7 @@ OUTER:
 
 		switch entryType {
 		case "entity":
-			entity, err := i.MemDBEntityByName(name, false)
+			entity, err := i.MemDBEntityByName(ctx, name, false)
 			if err != nil {
 				return "", err
 			}
@@ -2164,7 +2183,7 @@ OUTER:
 				break OUTER
 			}
 		case "group":
-			group, err := i.MemDBGroupByName(name, false)
+			group, err := i.MemDBGroupByName(ctx, name, false)
 			if err != nil {
 				return "", err
 			}
@@ -2400,3 +2419,54 @@ func diffGroups(old, new []*identity.Group) *groupDiff {
 
 	return diff
 }
+
+// namespaceMatches reports whether an identity object with the given
+// namespace ID belongs to the namespace of the request
+func namespaceMatches(ctx context.Context, namespaceID string) bool {
+	if namespaceID == "" {
+		namespaceID = namespace.RootNamespaceID
+	}
+	return namespace.FromContext(ctx).ID == namespaceID
+}
+
+// clearNamespace deletes all the entities and groups of the namespace in the
+// context
+func (i *IdentityStore) clearNamespace(ctx context.Context) error {
+	nsID := namespace.FromContext(ctx).ID
+
+	txn := i.db.Txn(false)
+	entitiesIter, err := txn.Get(entitiesTable, "id")
+	if err != nil {
+		return errwrap.Wrapf("failed to fetch entities: {{err}}", err)
+	}
+	var entityIDs []string
+	for raw := entitiesIter.Next(); raw != nil; raw = entitiesIter.Next() {
+		if entity := raw.(*identity.Entity); entity.NamespaceID == nsID {
+			entityIDs = append(entityIDs, entity.ID)
+		}
+	}
+
+	groupsIter, err := txn.Get(groupsTable, "id")
+	if err != nil {
+		return errwrap.Wrapf("failed to fetch groups: {{err}}", err)
+	}
+	var groupIDs []string
+	for raw := groupsIter.Next(); raw != nil; raw = groupsIter.Next() {
+		if group := raw.(*identity.Group); group.NamespaceID == nsID {
+			groupIDs = append(groupIDs, group.ID)
+		}
+	}
+
+	for _, groupID := range groupIDs {
+		if err := i.deleteGroupByID(groupID); err != nil {
+			return err
+		}
+	}
+	for _, entityID := range entityIDs {
+		if err := i.deleteEntity(entityID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -258,6 +258,11 @@ func (c *Core) UnsealWithStoredKeys(ctx context.Context) error {
 		return nil
 	}
 
+	if c.SealMigrationPending() {
+		c.logger.Info("seal migration pending, not unsealing with stored keys")
+		return nil
+	}
+
 	sealed, err := c.Sealed()
 	if err != nil {
 		c.logger.Error("error checking sealed status in auto-unseal", "error", err)
@@ -0,0 +1,134 @@
+package vault
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/hashicorp/vault/physical/raft"
+)
+
+// raftBackend returns the raft storage backend if Vault is configured to use
+// one, or nil otherwise.
+func (c *Core) raftBackend() *raft.RaftBackend {
+	raftBackend, _ := c.underlyingPhysical.(*raft.RaftBackend)
+	return raftBackend
+}
+
+// raftStoragePaths returns the paths used to manage the membership of the
+// integrated raft storage cluster.
+func (b *SystemBackend) raftStoragePaths() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "storage/raft/join",
+
+			Fields: map[string]*framework.FieldSchema{
+				"node_id": {
+					Type:        framework.TypeString,
+					Description: "The raft node ID of the node joining the cluster.",
+				},
+				"address": {
+					Type:        framework.TypeString,
+					Description: "The raft address of the node joining the cluster, in host:port form.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: b.handleRaftJoin,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["raft-join"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["raft-join"][1]),
+		},
+
+		{
+			Pattern: "storage/raft/remove-peer",
+
+			Fields: map[string]*framework.FieldSchema{
+				"server_id": {
+					Type:        framework.TypeString,
+					Description: "The raft node ID of the node to remove from the cluster.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: b.handleRaftRemovePeer,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["raft-remove-peer"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["raft-remove-peer"][1]),
+		},
+
+		{
+			Pattern: "storage/raft/configuration",
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: b.handleRaftConfigurationRead,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["raft-configuration"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["raft-configuration"][1]),
+		},
+	}
+}
+
+// handleRaftJoin adds the given node to the raft cluster as a voter
+func (b *SystemBackend) handleRaftJoin(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	nodeID := d.Get("node_id").(string)
+	if nodeID == "" {
+		return logical.ErrorResponse("node_id is required"), logical.ErrInvalidRequest
+	}
+	address := d.Get("address").(string)
+	if address == "" {
+		return logical.ErrorResponse("address is required"), logical.ErrInvalidRequest
+	}
+
+	if err := b.Core.raftBackend().AddPeer(ctx, nodeID, address); err != nil {
+		return handleError(err)
+	}
+
+	return nil, nil
+}
+
+// handleRaftRemovePeer removes the given node from the raft cluster
+func (b *SystemBackend) handleRaftRemovePeer(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	serverID := d.Get("server_id").(string)
+	if serverID == "" {
+		return logical.ErrorResponse("server_id is required"), logical.ErrInvalidRequest
+	}
+
+	if err := b.Core.raftBackend().RemovePeer(ctx, serverID); err != nil {
+		return handleError(err)
+	}
+
+	return nil, nil
+}
+
+// handleRaftConfigurationRead returns the current membership of the raft
+// cluster
+func (b *SystemBackend) handleRaftConfigurationRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.Core.raftBackend().GetConfiguration(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make([]map[string]interface{}, 0, len(config.Servers))
+	for _, server := range config.Servers {
+		servers = append(servers, map[string]interface{}{
+			"node_id": server.NodeID,
+			"address": server.Address,
+			"leader":  server.Leader,
+			"voter":   server.Voter,
+		})
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"config": map[string]interface{}{
+				"servers": servers,
+				"index":   config.Index,
+			},
+		},
+	}, nil
+}
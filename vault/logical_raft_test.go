@@ -0,0 +1,252 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/logging"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/physical/raft"
+)
+
+func testRaftBackend(t *testing.T, bootstrap string) (*raft.RaftBackend, func()) {
+	raftDir, err := ioutil.TempDir("", "vault-raft-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := raft.NewRaftBackend(map[string]string{
+		"path":      raftDir,
+		"address":   "127.0.0.1:0",
+		"bootstrap": bootstrap,
+	}, logging.NewVaultLogger(log.Debug))
+	if err != nil {
+		os.RemoveAll(raftDir)
+		t.Fatal(err)
+	}
+
+	raftBackend := b.(*raft.RaftBackend)
+	return raftBackend, func() {
+		raftBackend.Close()
+		os.RemoveAll(raftDir)
+	}
+}
+
+func TestSystemBackend_RaftStorage(t *testing.T) {
+	leader, cleanup := testRaftBackend(t, "true")
+	defer cleanup()
+	follower, cleanup2 := testRaftBackend(t, "false")
+	defer cleanup2()
+
+	// Wait for the bootstrapped node to elect itself
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		err := leader.Put(context.Background(), &physical.Entry{Key: "ping"})
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("leader never elected: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	c, _, root := TestCoreUnsealedBackend(t, leader)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/storage/raft/join")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"node_id": follower.NodeID(),
+		"address": follower.Address(),
+	}
+	resp, err := c.HandleRequest(req)
+	if err != nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "sys/storage/raft/configuration")
+	req.ClientToken = root
+	resp, err = c.HandleRequest(req)
+	if err != nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	servers := resp.Data["config"].(map[string]interface{})["servers"].([]map[string]interface{})
+	if len(servers) != 2 {
+		t.Fatalf("bad: %#v", servers)
+	}
+	for _, server := range servers {
+		if server["leader"].(bool) != (server["node_id"] == leader.NodeID()) {
+			t.Fatalf("bad leader flag: %#v", server)
+		}
+	}
+
+	// The follower receives a copy of the barrier-encrypted data
+	deadline = time.Now().Add(10 * time.Second)
+	for {
+		entry, err := follower.Get(context.Background(), keyringPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("keyring was not replicated to the follower")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/storage/raft/remove-peer")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"server_id": follower.NodeID(),
+	}
+	resp, err = c.HandleRequest(req)
+	if err != nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	config, err := leader.GetConfiguration(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Servers) != 1 || config.Servers[0].NodeID != leader.NodeID() {
+		t.Fatalf("bad: %#v", config.Servers)
+	}
+}
+
+func TestRaft_HA_LeaderLoss(t *testing.T) {
+	var backends []*raft.RaftBackend
+	for i := 0; i < 3; i++ {
+		b, cleanup := testRaftBackend(t, fmt.Sprintf("%t", i == 0))
+		defer cleanup()
+		backends = append(backends, b)
+	}
+
+	// Wait for the bootstrapped node to elect itself, then add the others
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		err := backends[0].Put(context.Background(), &physical.Entry{Key: "ping"})
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("leader never elected: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	for _, b := range backends[1:] {
+		if err := backends[0].AddPeer(context.Background(), b.NodeID(), b.Address()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cluster := NewTestCluster(t, &CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"kv": PassthroughBackendFactory,
+		},
+	}, &TestClusterOptions{
+		KeepStandbysSealed: true,
+		PhysicalFactory: func(coreIdx int) physical.Backend {
+			return backends[coreIdx]
+		},
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	active := cluster.Cores[0].Core
+	TestWaitActive(t, active)
+
+	// The standbys can be unsealed once the initialization has been
+	// replicated to them
+	for _, core := range cluster.Cores[1:] {
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			var err error
+			for _, key := range cluster.BarrierKeys {
+				if _, err = core.Unseal(TestKeyCopy(key)); err != nil {
+					break
+				}
+			}
+			if sealed, _ := core.Sealed(); !sealed {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("standby could not be unsealed: %v", err)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	_, err := active.HandleRequest(&logical.Request{
+		Operation:   logical.UpdateOperation,
+		Path:        "secret/foo",
+		ClientToken: cluster.RootToken,
+		Data: map[string]interface{}{
+			"value": "bar",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Losing the raft leader hands leadership, and with it the HA lock, to
+	// one of the standbys
+	if err := backends[0].Close(); err != nil {
+		t.Fatal(err)
+	}
+	var newActive *Core
+	deadline = time.Now().Add(30 * time.Second)
+	for newActive == nil {
+		for _, core := range cluster.Cores[1:] {
+			if standby, _ := core.Standby(); !standby {
+				newActive = core.Core
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no standby took over")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	resp, err := newActive.HandleRequest(&logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "secret/foo",
+		ClientToken: cluster.RootToken,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || resp.Data["value"] != "bar" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	_, err = newActive.HandleRequest(&logical.Request{
+		Operation:   logical.UpdateOperation,
+		Path:        "secret/foo",
+		ClientToken: cluster.RootToken,
+		Data: map[string]interface{}{
+			"value": "baz",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSystemBackend_RaftStorage_NotConfigured(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.ReadOperation, "sys/storage/raft/configuration")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err == nil || !strings.Contains(err.Error(), logical.ErrUnsupportedPath.Error()) {
+		t.Fatalf("expected unsupported path, got err: %v, resp: %#v", err, resp)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	uuid "github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/helper/compressutil"
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/helper/wrapping"
 	"github.com/hashicorp/vault/logical"
@@ -85,6 +86,7 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 				"leases/revoke-prefix/*",
 				"leases/revoke-force/*",
 				"leases/lookup/*",
+				"quotas/*",
 			},
 
 			Unauthenticated: []string{
@@ -316,6 +318,10 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 						Type:        framework.TypeCommaStringSlice,
 						Description: strings.TrimSpace(sysHelp["passthrough_request_headers"][0]),
 					},
+					"token_type": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["token_type"][0]),
+					},
 				},
 				Callbacks: map[logical.Operation]framework.OperationFunc{
 					logical.ReadOperation:   b.handleAuthTuneRead,
@@ -365,6 +371,10 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 						Type:        framework.TypeCommaStringSlice,
 						Description: strings.TrimSpace(sysHelp["passthrough_request_headers"][0]),
 					},
+					"token_type": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["token_type"][0]),
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -458,6 +468,37 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 				HelpDescription: strings.TrimSpace(sysHelp["remount"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "namespaces/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.handleNamespacesList,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["namespaces"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["namespaces"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "namespaces/(?P<path>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["namespace_path"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handleNamespacesRead,
+					logical.UpdateOperation: b.handleNamespacesSet,
+					logical.DeleteOperation: b.handleNamespacesDelete,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["namespaces"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["namespaces"][1]),
+			},
+
 			&framework.Path{
 				Pattern: "leases/lookup/(?P<prefix>.+?)?",
 
@@ -721,6 +762,103 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 				HelpDescription: strings.TrimSpace(sysHelp["policy"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "policies/egp/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.handlePoliciesList(PolicyTypeEGP),
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["egp-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["egp-list"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "policies/egp/(?P<name>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["policy-name"][0]),
+					},
+					"policy": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["egp-rules"][0]),
+					},
+					"enforcement_level": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     egpEnforcementHardMandatory,
+						Description: strings.TrimSpace(sysHelp["egp-enforcement-level"][0]),
+					},
+					"paths": &framework.FieldSchema{
+						Type:        framework.TypeCommaStringSlice,
+						Description: strings.TrimSpace(sysHelp["egp-paths"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handlePoliciesRead(PolicyTypeEGP),
+					logical.UpdateOperation: b.handlePoliciesSet(PolicyTypeEGP),
+					logical.DeleteOperation: b.handlePoliciesDelete(PolicyTypeEGP),
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["egp"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["egp"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "policies/egp-test$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Name of the stored endpoint governing policy to evaluate.",
+					},
+					"policy": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Rules of an endpoint governing policy to evaluate, instead of a stored one.",
+					},
+					"enforcement_level": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     egpEnforcementHardMandatory,
+						Description: strings.TrimSpace(sysHelp["egp-enforcement-level"][0]),
+					},
+					"paths": &framework.FieldSchema{
+						Type:        framework.TypeCommaStringSlice,
+						Default:     []string{"*"},
+						Description: strings.TrimSpace(sysHelp["egp-paths"][0]),
+					},
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Path of the simulated request.",
+					},
+					"operation": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     string(logical.UpdateOperation),
+						Description: "Operation of the simulated request.",
+					},
+					"data": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: "Data of the simulated request.",
+					},
+					"remote_addr": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Client address of the simulated request. Defaults to the address of the caller.",
+					},
+					"policy_override": &framework.FieldSchema{
+						Type:        framework.TypeBool,
+						Description: "Whether the simulated request overrides soft-mandatory policies.",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleEGPTest,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["egp-test"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["egp-test"][1]),
+			},
+
 			&framework.Path{
 				Pattern:         "seal-status$",
 				HelpSynopsis:    strings.TrimSpace(sysHelp["seal-status"][0]),
@@ -1076,6 +1214,13 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 	}
 
 	b.Backend.Paths = append(b.Backend.Paths, replicationPaths(b)...)
+	b.Backend.Paths = append(b.Backend.Paths, b.controlGroupPaths()...)
+	b.Backend.Paths = append(b.Backend.Paths, b.quotaPaths()...)
+
+	if core.raftBackend() != nil {
+		b.Backend.PathsSpecial.Root = append(b.Backend.PathsSpecial.Root, "storage/raft/*")
+		b.Backend.Paths = append(b.Backend.Paths, b.raftStoragePaths()...)
+	}
 
 	if core.rawEnabled {
 		b.Backend.Paths = append(b.Backend.Paths, &framework.Path{
@@ -1172,6 +1317,16 @@ func (b *SystemBackend) invalidate(ctx context.Context, key string) {
 		if b.Core.policyStore != nil {
 			b.Core.policyStore.invalidate(ctx, strings.TrimPrefix(key, policyACLSubPath), PolicyTypeACL)
 		}
+	case strings.HasPrefix(key, policyEGPSubPath):
+		b.Core.stateLock.RLock()
+		defer b.Core.stateLock.RUnlock()
+		if b.Core.policyStore != nil {
+			b.Core.policyStore.invalidate(ctx, strings.TrimPrefix(key, policyEGPSubPath), PolicyTypeEGP)
+		}
+	case strings.HasPrefix(key, quotasSubPath):
+		b.Core.stateLock.RLock()
+		defer b.Core.stateLock.RUnlock()
+		b.Core.quotaManager.invalidate(ctx, strings.TrimPrefix(key, quotasSubPath))
 	case strings.HasPrefix(key, tokenSubPath):
 		b.Core.stateLock.RLock()
 		defer b.Core.stateLock.RUnlock()
@@ -1516,7 +1671,12 @@ func (b *SystemBackend) handleMountTable(ctx context.Context, req *logical.Reque
 		Data: make(map[string]interface{}),
 	}
 
+	ns := namespace.FromContext(ctx)
 	for _, entry := range b.Core.mounts.Entries {
+		if !mountVisibleInNamespace(entry, ns) {
+			continue
+		}
+
 		// Populate mount info
 		info := map[string]interface{}{
 			"type":        entry.Type,
@@ -1728,17 +1888,18 @@ func handleErrorNoReadOnlyForward(
 func (b *SystemBackend) handleUnmount(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	path := data.Get("path").(string)
 	path = sanitizeMountPath(path)
+	fullPath := namespace.FromContext(ctx).Path + path
 
 	repState := b.Core.ReplicationState()
-	entry := b.Core.router.MatchingMountEntry(path)
+	entry := b.Core.router.MatchingMountEntry(fullPath)
 	if entry != nil && !entry.Local && repState.HasState(consts.ReplicationPerformanceSecondary) {
 		return logical.ErrorResponse("cannot unmount a non-local mount on a replication secondary"), nil
 	}
 
 	// We return success when the mount does not exists to not expose if the
 	// mount existed or not
-	match := b.Core.router.MatchingMount(path)
-	if match == "" || path != match {
+	match := b.Core.router.MatchingMount(fullPath)
+	if match == "" || fullPath != match {
 		return nil, nil
 	}
 
@@ -1751,6 +1912,70 @@ func (b *SystemBackend) handleUnmount(ctx context.Context, req *logical.Request,
 	return nil, nil
 }
 
+// handleNamespacesList lists the namespaces directly nested in the namespace
+// of the request
+func (b *SystemBackend) handleNamespacesList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	parent := namespace.FromContext(ctx)
+
+	var keys []string
+	keyInfo := make(map[string]interface{})
+	for _, ns := range b.Core.listNamespaces(ctx) {
+		key := parent.TrimmedPath(ns.Path)
+		keys = append(keys, key)
+		keyInfo[key] = map[string]interface{}{
+			"id":   ns.ID,
+			"path": ns.Path,
+		}
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+// handleNamespacesRead returns the namespace at the given path
+func (b *SystemBackend) handleNamespacesRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := namespace.Canonicalize(data.Get("path").(string))
+	fullPath := namespace.FromContext(ctx).Path + path
+
+	ns := b.Core.namespaceByPath(fullPath)
+	if path == "" || ns.Path != fullPath {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"id":   ns.ID,
+			"path": ns.Path,
+		},
+	}, nil
+}
+
+// handleNamespacesSet creates a namespace at the given path
+func (b *SystemBackend) handleNamespacesSet(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ns, err := b.Core.createNamespace(ctx, data.Get("path").(string))
+	if err != nil {
+		b.Backend.Logger().Error("namespace creation failed", "path", data.Get("path").(string), "error", err)
+		return handleError(err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"id":   ns.ID,
+			"path": ns.Path,
+		},
+	}, nil
+}
+
+// handleNamespacesDelete deletes the namespace at the given path along with
+// everything in it
+func (b *SystemBackend) handleNamespacesDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := b.Core.deleteNamespace(ctx, data.Get("path").(string)); err != nil {
+		b.Backend.Logger().Error("namespace deletion failed", "path", data.Get("path").(string), "error", err)
+		return handleError(err)
+	}
+
+	return nil, nil
+}
+
 // handleRemount is used to remount a path
 func (b *SystemBackend) handleRemount(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	repState := b.Core.ReplicationState()
@@ -1767,7 +1992,7 @@ func (b *SystemBackend) handleRemount(ctx context.Context, req *logical.Request,
 	fromPath = sanitizeMountPath(fromPath)
 	toPath = sanitizeMountPath(toPath)
 
-	entry := b.Core.router.MatchingMountEntry(fromPath)
+	entry := b.Core.router.MatchingMountEntry(namespace.FromContext(ctx).Path + fromPath)
 	if entry != nil && !entry.Local && repState.HasState(consts.ReplicationPerformanceSecondary) {
 		return logical.ErrorResponse("cannot remount a non-local mount on a replication secondary"), nil
 	}
@@ -1789,7 +2014,7 @@ func (b *SystemBackend) handleAuthTuneRead(ctx context.Context, req *logical.Req
 				"path must be specified as a string"),
 			logical.ErrInvalidRequest
 	}
-	return b.handleTuneReadCommon("auth/" + path)
+	return b.handleTuneReadCommon(ctx, "auth/"+path)
 }
 
 // handleMountTuneRead is used to get config settings on a backend
@@ -1804,12 +2029,12 @@ func (b *SystemBackend) handleMountTuneRead(ctx context.Context, req *logical.Re
 	// This call will read both logical backend's configuration as well as auth methods'.
 	// Retaining this behavior for backward compatibility. If this behavior is not desired,
 	// an error can be returned if path has a prefix of "auth/".
-	return b.handleTuneReadCommon(path)
+	return b.handleTuneReadCommon(ctx, path)
 }
 
 // handleTuneReadCommon returns the config settings of a path
-func (b *SystemBackend) handleTuneReadCommon(path string) (*logical.Response, error) {
-	path = sanitizeMountPath(path)
+func (b *SystemBackend) handleTuneReadCommon(ctx context.Context, path string) (*logical.Response, error) {
+	path = namespace.FromContext(ctx).Path + sanitizeMountPath(path)
 
 	sysView := b.Core.router.MatchingSystemView(path)
 	if sysView == nil {
@@ -1843,6 +2068,10 @@ func (b *SystemBackend) handleTuneReadCommon(path string) (*logical.Response, er
 		resp.Data["listing_visibility"] = mountEntry.Config.ListingVisibility
 	}
 
+	if mountEntry.Config.TokenType != logical.TokenTypeDefault {
+		resp.Data["token_type"] = mountEntry.Config.TokenType.String()
+	}
+
 	if rawVal, ok := mountEntry.synthesizedConfigCache.Load("passthrough_request_headers"); ok {
 		resp.Data["passthrough_request_headers"] = rawVal.([]string)
 	}
@@ -1891,6 +2120,17 @@ func (b *SystemBackend) handleTuneWriteCommon(ctx context.Context, path string,
 		}
 	}
 
+	// The token store is shared by all namespaces and can only be tuned in
+	// the root namespace
+	ns := namespace.FromContext(ctx)
+	if ns.ID != namespace.RootNamespaceID {
+		if strings.HasPrefix(path, credentialRoutePrefix+"token/") {
+			b.Backend.Logger().Error("cannot tune this mount", "path", path)
+			return handleError(fmt.Errorf("sys: cannot tune '%s'", path))
+		}
+		path = ns.Path + path
+	}
+
 	mountEntry := b.Core.router.MatchingMountEntry(path)
 	if mountEntry == nil {
 		b.Backend.Logger().Error("tune failed: no mount entry found", "path", path)
@@ -1902,7 +2142,7 @@ func (b *SystemBackend) handleTuneWriteCommon(ctx context.Context, path string,
 
 	var lock *sync.RWMutex
 	switch {
-	case strings.HasPrefix(path, credentialRoutePrefix):
+	case mountEntry.Table == credentialTableType:
 		lock = &b.Core.authLock
 	default:
 		lock = &b.Core.mountsLock
@@ -2065,6 +2305,30 @@ func (b *SystemBackend) handleTuneWriteCommon(ctx context.Context, path string,
 		}
 	}
 
+	if rawVal, ok := data.GetOk("token_type"); ok {
+		if mountEntry.Table != credentialTableType {
+			return logical.ErrorResponse("token_type can only be set on auth methods"), nil
+		}
+
+		tokenType, err := logical.ParseTokenType(rawVal.(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		oldVal := mountEntry.Config.TokenType
+		mountEntry.Config.TokenType = tokenType
+
+		// Update the mount table
+		if err := b.Core.persistAuth(ctx, b.Core.auth, mountEntry.Local); err != nil {
+			mountEntry.Config.TokenType = oldVal
+			return handleError(err)
+		}
+
+		if b.Core.logger.IsInfo() {
+			b.Core.logger.Info("core: mount tuning of token_type successful", "path", path)
+		}
+	}
+
 	if rawVal, ok := data.GetOk("passthrough_request_headers"); ok {
 		headers := rawVal.([]string)
 
@@ -2137,7 +2401,7 @@ func (b *SystemBackend) handleTuneWriteCommon(ctx context.Context, path string,
 		}
 
 		// Reload the backend to kick off the upgrade process.
-		b.Core.reloadBackendCommon(ctx, mountEntry, strings.HasPrefix(path, credentialRoutePrefix))
+		b.Core.reloadBackendCommon(ctx, mountEntry, mountEntry.Table == credentialTableType)
 	}
 
 	return resp, nil
@@ -2151,6 +2415,10 @@ func (b *SystemBackend) handleLeaseLookup(ctx context.Context, req *logical.Requ
 			logical.ErrInvalidRequest
 	}
 
+	if !leaseInNamespace(ctx, leaseID) {
+		return logical.ErrorResponse("invalid lease"), logical.ErrInvalidRequest
+	}
+
 	leaseTimes, err := b.Core.expiration.FetchLeaseTimes(leaseID)
 	if err != nil {
 		b.Backend.Logger().Error("error retrieving lease", "lease_id", leaseID, "error", err)
@@ -2187,6 +2455,7 @@ func (b *SystemBackend) handleLeaseLookupList(ctx context.Context, req *logical.
 	if prefix != "" && !strings.HasSuffix(prefix, "/") {
 		prefix = prefix + "/"
 	}
+	prefix = namespace.FromContext(ctx).Path + prefix
 
 	keys, err := b.Core.expiration.idView.List(ctx, prefix)
 	if err != nil {
@@ -2207,6 +2476,9 @@ func (b *SystemBackend) handleRenew(ctx context.Context, req *logical.Request, d
 		return logical.ErrorResponse("lease_id must be specified"),
 			logical.ErrInvalidRequest
 	}
+	if !leaseInNamespace(ctx, leaseID) {
+		return logical.ErrorResponse("invalid lease"), logical.ErrInvalidRequest
+	}
 	incrementRaw := data.Get("increment").(int)
 
 	// Convert the increment
@@ -2233,6 +2505,10 @@ func (b *SystemBackend) handleRevoke(ctx context.Context, req *logical.Request,
 			logical.ErrInvalidRequest
 	}
 
+	if !leaseInNamespace(ctx, leaseID) {
+		return logical.ErrorResponse("invalid lease"), logical.ErrInvalidRequest
+	}
+
 	// Invoke the expiration manager directly
 	if err := b.Core.expiration.Revoke(leaseID); err != nil {
 		b.Backend.Logger().Error("lease revocation failed", "lease_id", leaseID, "error", err)
@@ -2243,19 +2519,19 @@ func (b *SystemBackend) handleRevoke(ctx context.Context, req *logical.Request,
 
 // handleRevokePrefix is used to revoke a prefix with many LeaseIDs
 func (b *SystemBackend) handleRevokePrefix(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return b.handleRevokePrefixCommon(req, data, false)
+	return b.handleRevokePrefixCommon(ctx, req, data, false)
 }
 
 // handleRevokeForce is used to revoke a prefix with many LeaseIDs, ignoring errors
 func (b *SystemBackend) handleRevokeForce(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return b.handleRevokePrefixCommon(req, data, true)
+	return b.handleRevokePrefixCommon(ctx, req, data, true)
 }
 
 // handleRevokePrefixCommon is used to revoke a prefix with many LeaseIDs
-func (b *SystemBackend) handleRevokePrefixCommon(
+func (b *SystemBackend) handleRevokePrefixCommon(ctx context.Context,
 	req *logical.Request, data *framework.FieldData, force bool) (*logical.Response, error) {
 	// Get all the options
-	prefix := data.Get("prefix").(string)
+	prefix := namespace.FromContext(ctx).Path + data.Get("prefix").(string)
 
 	// Invoke the expiration manager directly
 	var err error
@@ -2279,7 +2555,12 @@ func (b *SystemBackend) handleAuthTable(ctx context.Context, req *logical.Reques
 	resp := &logical.Response{
 		Data: make(map[string]interface{}),
 	}
+	ns := namespace.FromContext(ctx)
 	for _, entry := range b.Core.auth.Entries {
+		if !mountVisibleInNamespace(entry, ns) {
+			continue
+		}
+
 		info := map[string]interface{}{
 			"type":        entry.Type,
 			"description": entry.Description,
@@ -2307,6 +2588,9 @@ func (b *SystemBackend) handleAuthTable(ctx context.Context, req *logical.Reques
 		if rawVal, ok := entry.synthesizedConfigCache.Load("passthrough_request_headers"); ok {
 			entryConfig["passthrough_request_headers"] = rawVal.([]string)
 		}
+		if entry.Config.TokenType != logical.TokenTypeDefault {
+			entryConfig["token_type"] = entry.Config.TokenType.String()
+		}
 
 		info["config"] = entryConfig
 		resp.Data[entry.Path] = info
@@ -2408,6 +2692,12 @@ func (b *SystemBackend) handleEnableAuth(ctx context.Context, req *logical.Reque
 	}
 	config.ListingVisibility = apiConfig.ListingVisibility
 
+	tokenType, err := logical.ParseTokenType(apiConfig.TokenType)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	config.TokenType = tokenType
+
 	if len(apiConfig.AuditNonHMACRequestKeys) > 0 {
 		config.AuditNonHMACRequestKeys = apiConfig.AuditNonHMACRequestKeys
 	}
@@ -2443,7 +2733,7 @@ func (b *SystemBackend) handleDisableAuth(ctx context.Context, req *logical.Requ
 	path := data.Get("path").(string)
 	path = sanitizeMountPath(path)
 
-	fullPath := credentialRoutePrefix + path
+	fullPath := namespace.FromContext(ctx).Path + credentialRoutePrefix + path
 
 	repState := b.Core.ReplicationState()
 	entry := b.Core.router.MatchingMountEntry(fullPath)
@@ -2472,7 +2762,9 @@ func (b *SystemBackend) handlePolicyList(ctx context.Context, req *logical.Reque
 	policies, err := b.Core.policyStore.ListPolicies(ctx, PolicyTypeACL)
 
 	// Add the special "root" policy
-	policies = append(policies, "root")
+	if namespace.FromContext(ctx).ID == namespace.RootNamespaceID {
+		policies = append(policies, "root")
+	}
 	resp := logical.ListResponse(policies)
 
 	// Backwords compatibility
@@ -2491,9 +2783,13 @@ func (b *SystemBackend) handlePoliciesList(policyType PolicyType) framework.Oper
 		switch policyType {
 		case PolicyTypeACL:
 			// Add the special "root" policy if not egp
-			policies = append(policies, "root")
+			if namespace.FromContext(ctx).ID == namespace.RootNamespaceID {
+				policies = append(policies, "root")
+			}
 			return logical.ListResponse(policies), nil
 
+		case PolicyTypeEGP:
+			return logical.ListResponse(policies), nil
 		}
 
 		return logical.ErrorResponse("unknown policy type"), nil
@@ -2519,6 +2815,10 @@ func (b *SystemBackend) handlePoliciesRead(policyType PolicyType) framework.Oper
 				"policy": policy.Raw,
 			},
 		}
+		if policyType == PolicyTypeEGP {
+			resp.Data["enforcement_level"] = policy.EnforcementLevel
+			resp.Data["paths"] = policy.EGPPaths
+		}
 
 		return resp, nil
 	}
@@ -2574,6 +2874,13 @@ func (b *SystemBackend) handlePoliciesSet(policyType PolicyType) framework.Opera
 			}
 			policy.Paths = p.Paths
 
+		case PolicyTypeEGP:
+			policy.EnforcementLevel = data.Get("enforcement_level").(string)
+			policy.EGPPaths = data.Get("paths").([]string)
+			if err := parseEGPPolicy(policy); err != nil {
+				return logical.ErrorResponse(err.Error()), nil
+			}
+
 		default:
 			return logical.ErrorResponse("unknown policy type"), nil
 		}
@@ -3033,6 +3340,8 @@ func (b *SystemBackend) handleWrappingUnwrap(ctx context.Context, req *logical.R
 	switch te.Policies[0] {
 	case responseWrappingPolicyName:
 		response, err = b.responseWrappingUnwrap(ctx, token, thirdParty)
+	case controlGroupPolicyName:
+		response, err = b.controlGroupUnwrap(ctx, token)
 	}
 	if err != nil {
 		var respErr *logical.Response
@@ -3368,7 +3677,11 @@ func (b *SystemBackend) pathInternalUIMountsRead(ctx context.Context, req *logic
 	resp.Data["secret"] = secretMounts
 	resp.Data["auth"] = authMounts
 
+	ns := namespace.FromContext(ctx)
 	for _, entry := range b.Core.mounts.Entries {
+		if !mountVisibleInNamespace(entry, ns) {
+			continue
+		}
 		if entry.Config.ListingVisibility == ListingVisibilityUnauth {
 			info := map[string]interface{}{
 				"type":        entry.Type,
@@ -3379,6 +3692,9 @@ func (b *SystemBackend) pathInternalUIMountsRead(ctx context.Context, req *logic
 	}
 
 	for _, entry := range b.Core.auth.Entries {
+		if !mountVisibleInNamespace(entry, ns) {
+			continue
+		}
 		if entry.Config.ListingVisibility == ListingVisibilityUnauth {
 			info := map[string]interface{}{
 				"type":        entry.Type,
@@ -3603,6 +3919,35 @@ in the plugin catalog.`,
 		`The options to pass into the backend. Should be a json object with string keys and values.`,
 	},
 
+	"namespaces": {
+		"Create, list, read and delete namespaces.",
+		`
+Namespaces are isolated environments with their own mounts, auth methods,
+policies, tokens and identities. Paths are relative to the namespace of the
+request, and namespaces can be nested.
+
+This path responds to the following HTTP methods.
+
+    LIST /
+        List the namespaces directly nested in the current namespace.
+
+    GET /<path>
+        Read the namespace at the given path.
+
+    POST /<path>
+        Create a namespace at the given path.
+
+    DELETE /<path>
+        Delete the namespace at the given path, revoking all of its
+        leases and tokens and deleting its nested namespaces.
+		`,
+	},
+
+	"namespace_path": {
+		"The path of the namespace, relative to the current namespace.",
+		"",
+	},
+
 	"remount": {
 		"Move the mount point of an already-mounted backend.",
 		`
@@ -3781,6 +4126,97 @@ or delete a policy.
 		"",
 	},
 
+	"egp-list": {
+		`List the configured endpoint governing policies.`,
+		`
+This path responds to the following HTTP methods.
+
+    LIST /
+        List the names of the configured endpoint governing policies.
+
+    GET /<name>
+        Retrieve the rules, enforcement level and paths of the named policy.
+
+    PUT /<name>
+        Add or update an endpoint governing policy.
+
+    DELETE /<name>
+        Delete the endpoint governing policy with the given name.
+		`,
+	},
+
+	"egp": {
+		`Read, Modify, or Delete an endpoint governing policy.`,
+		`
+Endpoint governing policies are evaluated after the ACL policies for every
+request to the paths they govern, including logins. Their rules can check the
+request data, the client address, the time of the request, the entity of the
+token, whether its login was validated with MFA and how old it is. Depending
+on its enforcement level, a policy which fails denies the request
+(hard-mandatory), denies it unless the requester overrides it
+(soft-mandatory), or is only logged (advisory).
+		`,
+	},
+
+	"egp-rules": {
+		`The rules of the endpoint governing policy. They must define a "main" rule evaluating to a boolean.`,
+		"",
+	},
+
+	"egp-enforcement-level": {
+		`The enforcement level of the policy: "advisory", "soft-mandatory" or "hard-mandatory". Defaults to "hard-mandatory".`,
+		"",
+	},
+
+	"egp-paths": {
+		`The paths governed by the policy, relative to its namespace. A trailing "*" matches any path with the given prefix.`,
+		"",
+	},
+
+	"egp-test": {
+		`Evaluate an endpoint governing policy for a simulated request.`,
+		`
+Evaluates a stored or given endpoint governing policy for a simulated request
+made with the token of the caller, and returns the outcome along with the
+values of the rules which were evaluated. Nothing is enforced.
+		`,
+	},
+
+	"quotas-rate-limit-list": {
+		`List the rate limit quotas.`,
+		"",
+	},
+
+	"quotas-rate-limit": {
+		`Read, write or delete a rate limit quota.`,
+		`
+Rate limit quotas limit the rate of the requests made to the paths under
+their path. Requests take tokens from a bucket which holds up to "burst"
+tokens and is refilled at "rate" tokens per second. Requests made while the
+bucket is empty are rejected with a 429 status code and a Retry-After header.
+Only the most specific quota governing a path is applied.
+		`,
+	},
+
+	"quotas-lease-count-list": {
+		`List the lease count quotas.`,
+		"",
+	},
+
+	"quotas-lease-count": {
+		`Read, write or delete a lease count quota.`,
+		`
+Lease count quotas limit the number of leases outstanding under their path.
+Requests which would create leases beyond "max_leases" are rejected with a 429
+status code. Only the most specific quota governing a path is applied.
+		`,
+	},
+
+	"quotas-path": {
+		`Path governed by the quota: a mount, or a path prefix within a mount. The quota governs every request if it is empty.`,
+		"",
+	},
+
 	"audit-hash": {
 		"The hash of the given string via the given audit backend",
 		"",
@@ -3999,10 +4435,57 @@ This path responds to the following HTTP methods.
 		"Generate random bytes",
 		"This function can be used to generate high-entropy random bytes.",
 	},
+	"token_type": {
+		`The type of the tokens handed out by the auth method, either "service" or
+"batch". If "default", service tokens are handed out.`,
+	},
+
 	"listing_visibility": {
 		"Determines the visibility of the mount in the UI-specific listing endpoint.",
 	},
 	"passthrough_request_headers": {
 		"A list of headers to whitelist and pass from the request to the backend.",
 	},
+	"control-group-authorize": {
+		"Approves a request held back by a control group.",
+		`
+The request is identified by the accessor of its control group token. The
+caller must have an entity which is a member of the identity groups of one of
+the factors of the control group, and cannot approve their own request. Once
+every factor has enough approvals, the requester can unwrap the token to get
+the response of the request.
+		`,
+	},
+	"control-group-request": {
+		"Returns the status of a request held back by a control group.",
+		`
+The request is identified by the accessor of its control group token. The
+response contains the path of the request, the entity which made it, the
+approvals it received and whether it is approved.
+		`,
+	},
+	"raft-join": {
+		"Adds a node to the integrated raft storage cluster.",
+		`
+This path must be called on the active node. The joining node must be running
+with raft storage that has not been bootstrapped, and reachable at the given
+raft address. It is added as a voting member and receives a copy of all data.
+		`,
+	},
+	"raft-remove-peer": {
+		"Removes a node from the integrated raft storage cluster.",
+		`
+This path must be called on the active node. The node with the given raft node
+ID stops being a member of the cluster and no longer receives data. The node
+itself is not shut down and should be stopped separately.
+		`,
+	},
+	"raft-configuration": {
+		"Returns the members of the integrated raft storage cluster.",
+		`
+The response lists every member with its raft node ID, its raft address,
+whether it is the current leader and whether it is a voter, along with the
+raft index of the configuration.
+		`,
+	},
 }
@@ -43,6 +43,7 @@ func TestSystemBackend_RootPaths(t *testing.T) {
 		"leases/revoke-prefix/*",
 		"leases/revoke-force/*",
 		"leases/lookup/*",
+		"quotas/*",
 	}
 
 	b := testSystemBackend(t)
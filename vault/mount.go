@@ -12,6 +12,7 @@ import (
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/mitchellh/copystructure"
@@ -134,11 +135,13 @@ func (t *MountTable) shallowClone() *MountTable {
 	return mt
 }
 
-// setTaint is used to set the taint on given entry
-func (t *MountTable) setTaint(path string, value bool) *MountEntry {
+// setTaint is used to set the taint on given entry in the namespace carried
+// by the context
+func (t *MountTable) setTaint(ctx context.Context, path string, value bool) *MountEntry {
+	ns := namespace.FromContext(ctx)
 	n := len(t.Entries)
 	for i := 0; i < n; i++ {
-		if t.Entries[i].Path == path {
+		if t.Entries[i].Path == path && t.Entries[i].Namespace().ID == ns.ID {
 			t.Entries[i].Tainted = value
 			return t.Entries[i]
 		}
@@ -146,12 +149,13 @@ func (t *MountTable) setTaint(path string, value bool) *MountEntry {
 	return nil
 }
 
-// remove is used to remove a given path entry; returns the entry that was
-// removed
-func (t *MountTable) remove(path string) *MountEntry {
+// remove is used to remove a given path entry in the namespace carried by the
+// context; returns the entry that was removed
+func (t *MountTable) remove(ctx context.Context, path string) *MountEntry {
+	ns := namespace.FromContext(ctx)
 	n := len(t.Entries)
 	for i := 0; i < n; i++ {
-		if entry := t.Entries[i]; entry.Path == path {
+		if entry := t.Entries[i]; entry.Path == path && entry.Namespace().ID == ns.ID {
 			t.Entries[i], t.Entries[n-1] = t.Entries[n-1], nil
 			t.Entries = t.Entries[:n-1]
 			return entry
@@ -183,6 +187,11 @@ type MountEntry struct {
 	Local            bool              `json:"local"`              // Local mounts are not replicated or affected by replication
 	SealWrap         bool              `json:"seal_wrap"`          // Whether to wrap CSPs
 	Tainted          bool              `json:"tainted,omitempty"`  // Set as a Write-Ahead flag for unmount/remount
+	NamespaceID      string            `json:"namespace_id"`       // ID of the namespace the mount belongs to
+
+	// namespace is the namespace the mount belongs to, resolved from
+	// NamespaceID when the mount is set up
+	namespace *namespace.Namespace
 
 	// synthesizedConfigCache is used to cache configuration values. These
 	// particular values are cached since we want to get them at a point-in-time
@@ -201,6 +210,7 @@ type MountConfig struct {
 	AuditNonHMACResponseKeys  []string             `json:"audit_non_hmac_response_keys,omitempty" structs:"audit_non_hmac_response_keys" mapstructure:"audit_non_hmac_response_keys"`
 	ListingVisibility         ListingVisiblityType `json:"listing_visibility,omitempty" structs:"listing_visibility" mapstructure:"listing_visibility"`
 	PassthroughRequestHeaders []string             `json:"passthrough_request_headers,omitempty" structs:"passthrough_request_headers" mapstructure:"passthrough_request_headers"`
+	TokenType                 logical.TokenType    `json:"token_type,omitempty" structs:"token_type" mapstructure:"token_type"`
 }
 
 // APIMountConfig is an embedded struct of api.MountConfigInput
@@ -213,6 +223,25 @@ type APIMountConfig struct {
 	AuditNonHMACResponseKeys  []string             `json:"audit_non_hmac_response_keys,omitempty" structs:"audit_non_hmac_response_keys" mapstructure:"audit_non_hmac_response_keys"`
 	ListingVisibility         ListingVisiblityType `json:"listing_visibility,omitempty" structs:"listing_visibility" mapstructure:"listing_visibility"`
 	PassthroughRequestHeaders []string             `json:"passthrough_request_headers,omitempty" structs:"passthrough_request_headers" mapstructure:"passthrough_request_headers"`
+	TokenType                 string               `json:"token_type,omitempty" structs:"token_type" mapstructure:"token_type"`
+}
+
+// Namespace returns the namespace the mount belongs to
+func (e *MountEntry) Namespace() *namespace.Namespace {
+	if e.namespace == nil {
+		return namespace.RootNamespace
+	}
+	return e.namespace
+}
+
+// APIPath returns the full path of the mount as seen by the router, including
+// the path of its namespace and, for credential backends, the "auth/" prefix
+func (e *MountEntry) APIPath() string {
+	path := e.Path
+	if e.Table == credentialTableType {
+		path = credentialRoutePrefix + path
+	}
+	return e.Namespace().Path + path
 }
 
 // Clone returns a deep copy of the mount entry
@@ -274,10 +303,17 @@ func (c *Core) mountInternal(ctx context.Context, entry *MountEntry) error {
 	c.mountsLock.Lock()
 	defer c.mountsLock.Unlock()
 
-	// Verify there are no conflicting mounts
-	if match := c.router.MountConflict(entry.Path); match != "" {
+	ns := namespace.FromContext(ctx)
+	entry.NamespaceID = ns.ID
+	entry.namespace = ns
+
+	// Verify there are no conflicting mounts or namespaces
+	if match := c.router.MountConflict(entry.APIPath()); match != "" {
 		return logical.CodedError(409, fmt.Sprintf("existing mount at %s", match))
 	}
+	if err := c.checkNamespaceConflict(ns, entry.APIPath()); err != nil {
+		return err
+	}
 
 	// Generate a new UUID and view
 	if entry.UUID == "" {
@@ -342,12 +378,12 @@ func (c *Core) mountInternal(ctx context.Context, entry *MountEntry) error {
 	}
 	c.mounts = newTable
 
-	if err := c.router.Mount(backend, entry.Path, entry, view); err != nil {
+	if err := c.router.Mount(backend, entry.APIPath(), entry, view); err != nil {
 		return err
 	}
 
 	if c.logger.IsInfo() {
-		c.logger.Info("successful mount", "path", entry.Path, "type", entry.Type)
+		c.logger.Info("successful mount", "path", entry.APIPath(), "type", entry.Type)
 	}
 	return nil
 }
@@ -370,19 +406,23 @@ func (c *Core) unmount(ctx context.Context, path string) error {
 }
 
 func (c *Core) unmountInternal(ctx context.Context, path string) error {
+	// The path is relative to the namespace; the router deals with full paths
+	ns := namespace.FromContext(ctx)
+	fullPath := ns.Path + path
+
 	// Verify exact match of the route
-	match := c.router.MatchingMount(path)
-	if match == "" || path != match {
+	match := c.router.MatchingMount(fullPath)
+	if match == "" || fullPath != match {
 		return fmt.Errorf("no matching mount")
 	}
 
 	// Get the view for this backend
-	view := c.router.MatchingStorageByAPIPath(path)
+	view := c.router.MatchingStorageByAPIPath(fullPath)
 
 	// Get the backend/mount entry for this path, used to remove ignored
 	// replication prefixes
-	backend := c.router.MatchingBackend(path)
-	entry := c.router.MatchingMountEntry(path)
+	backend := c.router.MatchingBackend(fullPath)
+	entry := c.router.MatchingMountEntry(fullPath)
 
 	// Mark the entry as tainted
 	if err := c.taintMountEntry(ctx, path); err != nil {
@@ -392,18 +432,18 @@ func (c *Core) unmountInternal(ctx context.Context, path string) error {
 
 	// Taint the router path to prevent routing. Note that in-flight requests
 	// are uncertain, right now.
-	if err := c.router.Taint(path); err != nil {
+	if err := c.router.Taint(fullPath); err != nil {
 		return err
 	}
 
 	if backend != nil {
 		// Invoke the rollback manager a final time
-		if err := c.rollback.Rollback(path); err != nil {
+		if err := c.rollback.Rollback(fullPath); err != nil {
 			return err
 		}
 
 		// Revoke all the dynamic keys
-		if err := c.expiration.RevokePrefix(path); err != nil {
+		if err := c.expiration.RevokePrefix(fullPath); err != nil {
 			return err
 		}
 
@@ -412,7 +452,7 @@ func (c *Core) unmountInternal(ctx context.Context, path string) error {
 	}
 
 	// Unmount the backend entirely
-	if err := c.router.Unmount(ctx, path); err != nil {
+	if err := c.router.Unmount(ctx, fullPath); err != nil {
 		return err
 	}
 
@@ -432,7 +472,7 @@ func (c *Core) unmountInternal(ctx context.Context, path string) error {
 	}
 
 	if c.logger.IsInfo() {
-		c.logger.Info("successfully unmounted", "path", path)
+		c.logger.Info("successfully unmounted", "path", fullPath)
 	}
 	return nil
 }
@@ -444,7 +484,7 @@ func (c *Core) removeMountEntry(ctx context.Context, path string) error {
 
 	// Remove the entry from the mount table
 	newTable := c.mounts.shallowClone()
-	entry := newTable.remove(path)
+	entry := newTable.remove(ctx, path)
 	if entry == nil {
 		c.logger.Error("nil entry found removing entry in mounts table", "path", path)
 		return logical.CodedError(500, "failed to remove entry in mounts table")
@@ -473,7 +513,7 @@ func (c *Core) taintMountEntry(ctx context.Context, path string) error {
 
 	// As modifying the taint of an entry affects shallow clones,
 	// we simply use the original
-	entry := c.mounts.setTaint(path, true)
+	entry := c.mounts.setTaint(ctx, path, true)
 	if entry == nil {
 		c.logger.Error("nil entry found tainting entry in mounts table", "path", path)
 		return logical.CodedError(500, "failed to taint entry in mounts table")
@@ -491,7 +531,7 @@ func (c *Core) taintMountEntry(ctx context.Context, path string) error {
 // remountForce takes a copy of the mount entry for the path and fully unmounts
 // and remounts the backend to pick up any changes, such as filtered paths
 func (c *Core) remountForce(ctx context.Context, path string) error {
-	me := c.router.MatchingMountEntry(path)
+	me := c.router.MatchingMountEntry(namespace.FromContext(ctx).Path + path)
 	if me == nil {
 		return fmt.Errorf("cannot find mount for path '%s'", path)
 	}
@@ -524,15 +564,22 @@ func (c *Core) remount(ctx context.Context, src, dst string) error {
 		}
 	}
 
+	// Mounts can only be moved within their namespace
+	ns := namespace.FromContext(ctx)
+	fullSrc, fullDst := ns.Path+src, ns.Path+dst
+
 	// Verify exact match of the route
-	match := c.router.MatchingMount(src)
-	if match == "" || src != match {
+	match := c.router.MatchingMount(fullSrc)
+	if match == "" || fullSrc != match {
 		return fmt.Errorf("no matching mount at '%s'", src)
 	}
 
-	if match := c.router.MatchingMount(dst); match != "" {
+	if match := c.router.MatchingMount(fullDst); match != "" {
 		return fmt.Errorf("existing mount at '%s'", match)
 	}
+	if err := c.checkNamespaceConflict(ns, fullDst); err != nil {
+		return err
+	}
 
 	// Mark the entry as tainted
 	if err := c.taintMountEntry(ctx, src); err != nil {
@@ -540,24 +587,25 @@ func (c *Core) remount(ctx context.Context, src, dst string) error {
 	}
 
 	// Taint the router path to prevent routing
-	if err := c.router.Taint(src); err != nil {
+	if err := c.router.Taint(fullSrc); err != nil {
 		return err
 	}
 
 	// Invoke the rollback manager a final time
-	if err := c.rollback.Rollback(src); err != nil {
+	if err := c.rollback.Rollback(fullSrc); err != nil {
 		return err
 	}
 
 	// Revoke all the dynamic keys
-	if err := c.expiration.RevokePrefix(src); err != nil {
+	if err := c.expiration.RevokePrefix(fullSrc); err != nil {
 		return err
 	}
 
 	c.mountsLock.Lock()
 	var entry *MountEntry
-	for _, entry = range c.mounts.Entries {
-		if entry.Path == src {
+	for _, e := range c.mounts.Entries {
+		if e.Path == src && e.Namespace().ID == ns.ID {
+			entry = e
 			entry.Path = dst
 			entry.Tainted = false
 			break
@@ -581,17 +629,17 @@ func (c *Core) remount(ctx context.Context, src, dst string) error {
 	c.mountsLock.Unlock()
 
 	// Remount the backend
-	if err := c.router.Remount(src, dst); err != nil {
+	if err := c.router.Remount(fullSrc, fullDst); err != nil {
 		return err
 	}
 
 	// Un-taint the path
-	if err := c.router.Untaint(dst); err != nil {
+	if err := c.router.Untaint(fullDst); err != nil {
 		return err
 	}
 
 	if c.logger.IsInfo() {
-		c.logger.Info("successful remount", "old_path", src, "new_path", dst)
+		c.logger.Info("successful remount", "old_path", fullSrc, "new_path", fullDst)
 	}
 	return nil
 }
@@ -683,6 +731,10 @@ func (c *Core) loadMounts(ctx context.Context) error {
 			entry.Table = c.mounts.Type
 			needPersist = true
 		}
+		if entry.NamespaceID == "" {
+			entry.NamespaceID = namespace.RootNamespaceID
+			needPersist = true
+		}
 		if entry.Accessor == "" {
 			accessor, err := c.generateMountAccessor(entry.Type)
 			if err != nil {
@@ -797,6 +849,12 @@ func (c *Core) setupMounts(ctx context.Context) error {
 	var backendType logical.BackendType
 
 	for _, entry := range c.mounts.Entries {
+		// Resolve the namespace of the mount
+		entry.namespace = c.namespaceByID(entry.NamespaceID)
+		if entry.namespace == nil {
+			c.logger.Error("namespace of mount entry not found", "path", entry.Path, "namespace_id", entry.NamespaceID)
+			return errLoadMountsFailed
+		}
 
 		// Initialize the backend, special casing for system
 		barrierPath := backendBarrierPrefix + entry.UUID + "/"
@@ -844,19 +902,19 @@ func (c *Core) setupMounts(ctx context.Context) error {
 
 	ROUTER_MOUNT:
 		// Mount the backend
-		err = c.router.Mount(backend, entry.Path, entry, view)
+		err = c.router.Mount(backend, entry.APIPath(), entry, view)
 		if err != nil {
-			c.logger.Error("failed to mount entry", "path", entry.Path, "error", err)
+			c.logger.Error("failed to mount entry", "path", entry.APIPath(), "error", err)
 			return errLoadMountsFailed
 		}
 
 		if c.logger.IsInfo() {
-			c.logger.Info("successfully mounted backend", "type", entry.Type, "path", entry.Path)
+			c.logger.Info("successfully mounted backend", "type", entry.Type, "path", entry.APIPath())
 		}
 
 		// Ensure the path is tainted if set in the mount table
 		if entry.Tainted {
-			c.router.Taint(entry.Path)
+			c.router.Taint(entry.APIPath())
 		}
 	}
 	return nil
@@ -871,7 +929,7 @@ func (c *Core) unloadMounts(ctx context.Context) error {
 	if c.mounts != nil {
 		mountTable := c.mounts.shallowClone()
 		for _, e := range mountTable.Entries {
-			backend := c.router.MatchingBackend(e.Path)
+			backend := c.router.MatchingBackend(e.APIPath())
 			if backend != nil {
 				backend.Cleanup(ctx)
 			}
@@ -958,6 +1016,7 @@ func (c *Core) defaultMountTable() *MountTable {
 		UUID:             mountUUID,
 		Accessor:         mountAccessor,
 		BackendAwareUUID: bUUID,
+		NamespaceID:      namespace.RootNamespaceID,
 		Options: map[string]string{
 			"versioned": "true",
 		},
@@ -994,6 +1053,7 @@ func (c *Core) requiredMountTable() *MountTable {
 		Accessor:         cubbyholeAccessor,
 		Local:            true,
 		BackendAwareUUID: cubbyholeBackendUUID,
+		NamespaceID:      namespace.RootNamespaceID,
 	}
 
 	sysUUID, err := uuid.GenerateUUID()
@@ -1016,6 +1076,7 @@ func (c *Core) requiredMountTable() *MountTable {
 		UUID:             sysUUID,
 		Accessor:         sysAccessor,
 		BackendAwareUUID: sysBackendUUID,
+		NamespaceID:      namespace.RootNamespaceID,
 	}
 
 	identityUUID, err := uuid.GenerateUUID()
@@ -1038,6 +1099,7 @@ func (c *Core) requiredMountTable() *MountTable {
 		UUID:             identityUUID,
 		Accessor:         identityAccessor,
 		BackendAwareUUID: identityBackendUUID,
+		NamespaceID:      namespace.RootNamespaceID,
 	}
 
 	table.Entries = append(table.Entries, cubbyholeMount)
@@ -0,0 +1,456 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/armon/go-radix"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// coreNamespacesPath is the storage prefix of the namespace entries
+	coreNamespacesPath = "core/namespaces/"
+
+	// namespaceBarrierPrefix is the storage prefix of the data owned by
+	// namespaces other than the root, such as their policies. It is followed
+	// by the namespace ID.
+	namespaceBarrierPrefix = "namespaces/"
+)
+
+var (
+	// namespaceSingletonMounts are the mounts of the root namespace that are
+	// exposed in every namespace. Backends behind them scope their data by
+	// the namespace of the request.
+	namespaceSingletonMounts = []string{
+		"sys/",
+		"cubbyhole/",
+		"identity/",
+		credentialRoutePrefix + "token/",
+	}
+
+	// namespaceSysPaths are the prefixes of the system backend paths that are
+	// available in namespaces other than the root. Everything else, such as
+	// sealing, audit devices and raw storage access, is only available in
+	// the root namespace.
+	namespaceSysPaths = []string{
+		"auth",
+		"capabilities",
+		"control-group/",
+		"internal/ui/mounts",
+		"leases/",
+		"mounts",
+		"namespaces",
+		"policies/",
+		"policy",
+		"remount",
+		"renew",
+		"revoke",
+		"tools/",
+		"wrapping/",
+	}
+
+	// validNamespaceName matches a single namespace path segment
+	validNamespaceName = regexp.MustCompile(`^[\w-]+$`)
+)
+
+// namespaceByID returns the namespace with the given ID, or nil if it doesn't
+// exist. An empty ID refers to the root namespace.
+func (c *Core) namespaceByID(id string) *namespace.Namespace {
+	if id == "" || id == namespace.RootNamespaceID {
+		return namespace.RootNamespace
+	}
+
+	c.namespacesLock.RLock()
+	defer c.namespacesLock.RUnlock()
+	return c.namespaces[id]
+}
+
+// namespaceByPath returns the namespace the given request path belongs to,
+// which is the namespace with the longest path that prefixes it
+func (c *Core) namespaceByPath(path string) *namespace.Namespace {
+	c.namespacesLock.RLock()
+	defer c.namespacesLock.RUnlock()
+
+	if c.namespacePaths == nil {
+		return namespace.RootNamespace
+	}
+	_, raw, ok := c.namespacePaths.LongestPrefix(path)
+	if !ok {
+		return namespace.RootNamespace
+	}
+	return raw.(*namespace.Namespace)
+}
+
+// childNamespaces returns the namespaces nested at any depth in the given
+// namespace, deepest first
+func (c *Core) childNamespaces(ns *namespace.Namespace) []*namespace.Namespace {
+	c.namespacesLock.RLock()
+	defer c.namespacesLock.RUnlock()
+
+	var children []*namespace.Namespace
+	for _, child := range c.namespaces {
+		if child.HasParent(ns) {
+			children = append(children, child)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return len(children[i].Path) > len(children[j].Path)
+	})
+	return children
+}
+
+// parentNamespaces returns the namespaces the given namespace is nested in,
+// starting with the root namespace
+func (c *Core) parentNamespaces(ns *namespace.Namespace) []*namespace.Namespace {
+	if ns.ID == namespace.RootNamespaceID {
+		return nil
+	}
+
+	c.namespacesLock.RLock()
+	defer c.namespacesLock.RUnlock()
+
+	parents := []*namespace.Namespace{namespace.RootNamespace}
+	for _, parent := range c.namespaces {
+		if parent.ID != ns.ID && ns.HasParent(parent) {
+			parents = append(parents, parent)
+		}
+	}
+	sort.Slice(parents, func(i, j int) bool {
+		return len(parents[i].Path) < len(parents[j].Path)
+	})
+	return parents
+}
+
+// checkNamespaceConflict returns an error if the given full path belongs to a
+// namespace other than ns, which would make the mount unreachable
+func (c *Core) checkNamespaceConflict(ns *namespace.Namespace, path string) error {
+	if match := c.namespaceByPath(path); match.ID != ns.ID {
+		return logical.CodedError(409, fmt.Sprintf("path is in use by namespace %s", match.Path))
+	}
+	return nil
+}
+
+// loadNamespaces is invoked as part of postUnseal to load the namespaces
+func (c *Core) loadNamespaces(ctx context.Context) error {
+	view := NewBarrierView(c.barrier, coreNamespacesPath)
+	ids, err := view.List(ctx, "")
+	if err != nil {
+		return errwrap.Wrapf("failed to list namespaces: {{err}}", err)
+	}
+
+	c.namespacesLock.Lock()
+	defer c.namespacesLock.Unlock()
+
+	c.namespaces = make(map[string]*namespace.Namespace, len(ids))
+	c.namespacePaths = radix.New()
+	for _, id := range ids {
+		raw, err := view.Get(ctx, id)
+		if err != nil {
+			return errwrap.Wrapf("failed to read namespace: {{err}}", err)
+		}
+		if raw == nil {
+			continue
+		}
+
+		ns := new(namespace.Namespace)
+		if err := jsonutil.DecodeJSON(raw.Value, ns); err != nil {
+			return errwrap.Wrapf("failed to decode namespace: {{err}}", err)
+		}
+		c.namespaces[ns.ID] = ns
+		c.namespacePaths.Insert(ns.Path, ns)
+	}
+
+	return nil
+}
+
+// setupNamespaces is invoked after the mounts, credential backends and the
+// policy store have been set up, to expose the singleton backends in every
+// namespace
+func (c *Core) setupNamespaces(ctx context.Context) error {
+	c.namespacesLock.RLock()
+	namespaces := make([]*namespace.Namespace, 0, len(c.namespaces))
+	for _, ns := range c.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	c.namespacesLock.RUnlock()
+
+	for _, ns := range namespaces {
+		if err := c.setupNamespace(ctx, ns); err != nil {
+			c.logger.Error("failed to set up namespace", "path", ns.Path, "error", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setupNamespace mounts the singleton backends in the namespace and prepares
+// its policies
+func (c *Core) setupNamespace(ctx context.Context, ns *namespace.Namespace) error {
+	for _, path := range namespaceSingletonMounts {
+		src := c.router.MatchingMountEntry(path)
+		if src == nil {
+			return fmt.Errorf("no mount found at %q", path)
+		}
+
+		entry, err := src.Clone()
+		if err != nil {
+			return err
+		}
+		entry.NamespaceID = ns.ID
+		entry.namespace = ns
+		entry.SyncCache()
+
+		if err := c.router.MountAlias(path, ns.Path+path, entry); err != nil {
+			return err
+		}
+	}
+
+	return c.policyStore.initNamespace(ctx, ns)
+}
+
+// teardownNamespaces is used before we seal the vault to forget the loaded
+// namespaces. The router, which holds the singleton mounts, is reset when the
+// mounts are unloaded.
+func (c *Core) teardownNamespaces() error {
+	c.namespacesLock.Lock()
+	defer c.namespacesLock.Unlock()
+
+	c.namespaces = nil
+	c.namespacePaths = nil
+	return nil
+}
+
+// createNamespace creates a namespace at the given path, relative to the
+// namespace carried by the context. The parent of a nested path must already
+// exist.
+func (c *Core) createNamespace(ctx context.Context, path string) (*namespace.Namespace, error) {
+	if c.ReplicationState().HasState(consts.ReplicationPerformanceSecondary) {
+		return nil, logical.ErrReadOnly
+	}
+
+	c.namespacesModifyLock.Lock()
+	defer c.namespacesModifyLock.Unlock()
+
+	parent := namespace.FromContext(ctx)
+	path = namespace.Canonicalize(path)
+	if path == "" {
+		return nil, logical.CodedError(400, "namespace path must be specified")
+	}
+
+	// Nested paths are created in the namespace of their parent
+	if idx := strings.LastIndex(strings.TrimSuffix(path, "/"), "/"); idx != -1 {
+		parentPath := parent.Path + path[:idx+1]
+		if parent = c.namespaceByPath(parentPath); parent.Path != parentPath {
+			return nil, logical.CodedError(400, fmt.Sprintf("parent namespace %q does not exist", parentPath))
+		}
+		path = path[idx+1:]
+	}
+
+	name := strings.TrimSuffix(path, "/")
+	if !validNamespaceName.MatchString(name) {
+		return nil, logical.CodedError(400, fmt.Sprintf("invalid namespace name %q", name))
+	}
+	if strutil.StrListContains(protectedMounts, path) {
+		return nil, logical.CodedError(400, fmt.Sprintf("%q is a reserved path", name))
+	}
+
+	fullPath := parent.Path + path
+	if existing := c.namespaceByPath(fullPath); existing.Path == fullPath {
+		return existing, nil
+	}
+	if match := c.router.MountConflict(fullPath); match != "" {
+		return nil, logical.CodedError(409, fmt.Sprintf("existing mount at %s", match))
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	ns := &namespace.Namespace{
+		ID:   id,
+		Path: fullPath,
+	}
+
+	raw, err := jsonutil.EncodeJSON(ns)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.barrier.Put(ctx, &Entry{Key: coreNamespacesPath + ns.ID, Value: raw}); err != nil {
+		return nil, errwrap.Wrapf("failed to persist namespace: {{err}}", err)
+	}
+
+	c.namespacesLock.Lock()
+	c.namespaces[ns.ID] = ns
+	c.namespacePaths.Insert(ns.Path, ns)
+	c.namespacesLock.Unlock()
+
+	if err := c.setupNamespace(ctx, ns); err != nil {
+		return nil, err
+	}
+
+	if c.logger.IsInfo() {
+		c.logger.Info("created namespace", "path", ns.Path)
+	}
+	return ns, nil
+}
+
+// deleteNamespace deletes the namespace at the given path, relative to the
+// namespace carried by the context, along with everything in it: nested
+// namespaces, mounts, credential backends, leases, tokens, policies and
+// identities
+func (c *Core) deleteNamespace(ctx context.Context, path string) error {
+	if c.ReplicationState().HasState(consts.ReplicationPerformanceSecondary) {
+		return logical.ErrReadOnly
+	}
+
+	c.namespacesModifyLock.Lock()
+	defer c.namespacesModifyLock.Unlock()
+
+	fullPath := namespace.FromContext(ctx).Path + namespace.Canonicalize(path)
+	ns := c.namespaceByPath(fullPath)
+	if ns.ID == namespace.RootNamespaceID || ns.Path != fullPath {
+		return nil
+	}
+
+	for _, child := range c.childNamespaces(ns) {
+		if err := c.clearNamespace(ctx, child); err != nil {
+			return err
+		}
+	}
+	return c.clearNamespace(ctx, ns)
+}
+
+// clearNamespace removes a single namespace and its contents. Nested
+// namespaces must have been removed already.
+func (c *Core) clearNamespace(ctx context.Context, ns *namespace.Namespace) error {
+	nsCtx := namespace.ContextWithNamespace(ctx, ns)
+
+	c.mountsLock.RLock()
+	var mounts []string
+	for _, entry := range c.mounts.Entries {
+		if entry.Namespace().ID == ns.ID {
+			mounts = append(mounts, entry.Path)
+		}
+	}
+	c.mountsLock.RUnlock()
+	for _, path := range mounts {
+		if err := c.unmountInternal(nsCtx, path); err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to unmount %q: {{err}}", ns.Path+path), err)
+		}
+	}
+
+	c.authLock.RLock()
+	var auths []string
+	for _, entry := range c.auth.Entries {
+		if entry.Namespace().ID == ns.ID {
+			auths = append(auths, entry.Path)
+		}
+	}
+	c.authLock.RUnlock()
+	for _, path := range auths {
+		if err := c.disableCredential(nsCtx, path); err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to disable auth method %q: {{err}}", ns.Path+credentialRoutePrefix+path), err)
+		}
+	}
+
+	// Revoke whatever is left, such as tokens created through the token store
+	if err := c.expiration.RevokePrefix(ns.Path); err != nil {
+		return errwrap.Wrapf("failed to revoke leases: {{err}}", err)
+	}
+
+	if err := c.policyStore.clearNamespace(ctx, ns); err != nil {
+		return err
+	}
+	if err := c.identityStore.clearNamespace(nsCtx); err != nil {
+		return err
+	}
+	if err := logical.ClearView(ctx, c.tokenStore.view.SubView(namespaceRolesPrefix+ns.ID+"/")); err != nil {
+		return errwrap.Wrapf("failed to delete token roles: {{err}}", err)
+	}
+	if err := logical.ClearView(ctx, NewBarrierView(c.barrier, namespaceBarrierPrefix+ns.ID+"/")); err != nil {
+		return errwrap.Wrapf("failed to delete namespace data: {{err}}", err)
+	}
+
+	for _, path := range namespaceSingletonMounts {
+		if err := c.router.Unmount(ctx, ns.Path+path); err != nil {
+			return err
+		}
+	}
+
+	if err := c.barrier.Delete(ctx, coreNamespacesPath+ns.ID); err != nil {
+		return errwrap.Wrapf("failed to delete namespace: {{err}}", err)
+	}
+
+	c.namespacesLock.Lock()
+	delete(c.namespaces, ns.ID)
+	c.namespacePaths.Delete(ns.Path)
+	c.namespacesLock.Unlock()
+
+	if c.logger.IsInfo() {
+		c.logger.Info("deleted namespace", "path", ns.Path)
+	}
+	return nil
+}
+
+// listNamespaces returns the namespaces directly nested in the namespace
+// carried by the context
+func (c *Core) listNamespaces(ctx context.Context) []*namespace.Namespace {
+	parent := namespace.FromContext(ctx)
+
+	c.namespacesLock.RLock()
+	defer c.namespacesLock.RUnlock()
+
+	var children []*namespace.Namespace
+	for _, ns := range c.namespaces {
+		if !ns.HasParent(parent) {
+			continue
+		}
+		if rel := parent.TrimmedPath(ns.Path); strings.Count(rel, "/") == 1 {
+			children = append(children, ns)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Path < children[j].Path
+	})
+	return children
+}
+
+// namespaceSysPathAllowed returns whether the given system backend path, with
+// the "sys/" prefix removed, is available in namespaces other than the root
+func namespaceSysPathAllowed(path string) bool {
+	for _, prefix := range namespaceSysPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mountVisibleInNamespace returns whether the mount entry is listed in the
+// given namespace: its own mounts and the singleton mounts it shares with
+// the root namespace
+func mountVisibleInNamespace(entry *MountEntry, ns *namespace.Namespace) bool {
+	entryNS := entry.Namespace()
+	if entryNS.ID == ns.ID {
+		return true
+	}
+	return entryNS.ID == namespace.RootNamespaceID && strutil.StrListContains(namespaceSingletonMounts, entry.APIPath())
+}
+
+// leaseInNamespace returns whether the lease ID, which is prefixed by the
+// path the lease was created on, belongs to the namespace carried by the
+// context or one of its children
+func leaseInNamespace(ctx context.Context, leaseID string) bool {
+	return strings.HasPrefix(leaseID, namespace.FromContext(ctx).Path)
+}
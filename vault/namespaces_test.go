@@ -0,0 +1,211 @@
+package vault
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+)
+
+func testNamespaceRequest(t *testing.T, c *Core, token string, op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+	req := logical.TestRequest(t, op, path)
+	req.ClientToken = token
+	if data != nil {
+		req.Data = data
+	}
+	return c.HandleRequest(req)
+}
+
+func testNamespaceRequestOK(t *testing.T, c *Core, token string, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+	resp, err := testNamespaceRequest(t, c, token, op, path, data)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("%s %s: err: %v resp: %#v", op, path, err, resp)
+	}
+	return resp
+}
+
+func TestNamespaces_CreateListDelete(t *testing.T) {
+	c, keys, root := TestCoreUnsealed(t)
+
+	resp := testNamespaceRequestOK(t, c, root, logical.UpdateOperation, "sys/namespaces/ns1", nil)
+	if resp.Data["path"] != "ns1/" || resp.Data["id"] == "" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	id := resp.Data["id"]
+
+	// Creating it again is a no-op
+	resp = testNamespaceRequestOK(t, c, root, logical.UpdateOperation, "sys/namespaces/ns1", nil)
+	if resp.Data["id"] != id {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Nested namespaces can be created from the parent or the root
+	testNamespaceRequestOK(t, c, root, logical.UpdateOperation, "ns1/sys/namespaces/ns2", nil)
+	resp = testNamespaceRequestOK(t, c, root, logical.UpdateOperation, "sys/namespaces/ns1/ns3", nil)
+	if resp.Data["path"] != "ns1/ns3/" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// The parent of a nested namespace must exist
+	if _, err := testNamespaceRequest(t, c, root, logical.UpdateOperation, "sys/namespaces/missing/ns4", nil); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// Reserved and mounted paths can't be used
+	for _, path := range []string{"sys", "secret", "bad name"} {
+		if _, err := testNamespaceRequest(t, c, root, logical.UpdateOperation, "sys/namespaces/"+path, nil); err == nil {
+			t.Fatalf("expected an error creating %q", path)
+		}
+	}
+
+	resp = testNamespaceRequestOK(t, c, root, logical.ListOperation, "sys/namespaces", nil)
+	if keys := resp.Data["keys"].([]string); !reflect.DeepEqual(keys, []string{"ns1/"}) {
+		t.Fatalf("bad: %#v", keys)
+	}
+	resp = testNamespaceRequestOK(t, c, root, logical.ListOperation, "ns1/sys/namespaces", nil)
+	if keys := resp.Data["keys"].([]string); !reflect.DeepEqual(keys, []string{"ns2/", "ns3/"}) {
+		t.Fatalf("bad: %#v", keys)
+	}
+
+	resp = testNamespaceRequestOK(t, c, root, logical.ReadOperation, "ns1/sys/namespaces/ns2", nil)
+	if resp.Data["path"] != "ns1/ns2/" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Namespaces survive a seal
+	if err := c.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(c, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ns := c.namespaceByPath("ns1/ns2/secret/foo"); ns.Path != "ns1/ns2/" {
+		t.Fatalf("bad: %#v", ns)
+	}
+
+	// Deleting a namespace deletes its children
+	testNamespaceRequestOK(t, c, root, logical.DeleteOperation, "sys/namespaces/ns1", nil)
+	resp = testNamespaceRequestOK(t, c, root, logical.ListOperation, "sys/namespaces", nil)
+	if len(resp.Data) != 0 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if ns := c.namespaceByPath("ns1/ns2/secret/foo"); ns.ID != namespace.RootNamespaceID {
+		t.Fatalf("bad: %#v", ns)
+	}
+}
+
+func TestNamespaces_Isolation(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	testNamespaceRequestOK(t, c, root, logical.UpdateOperation, "sys/namespaces/ns1", nil)
+
+	// Mounts of the namespace are separate from the ones of the root
+	testNamespaceRequestOK(t, c, root, logical.UpdateOperation, "ns1/sys/mounts/secret", map[string]interface{}{
+		"type": "kv",
+	})
+	testNamespaceRequestOK(t, c, root, logical.UpdateOperation, "ns1/secret/foo", map[string]interface{}{
+		"value": "ns1",
+	})
+	testNamespaceRequestOK(t, c, root, logical.UpdateOperation, "secret/foo", map[string]interface{}{
+		"value": "root",
+	})
+	resp := testNamespaceRequestOK(t, c, root, logical.ReadOperation, "ns1/secret/foo", nil)
+	if resp.Data["value"] != "ns1" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp = testNamespaceRequestOK(t, c, root, logical.ReadOperation, "ns1/sys/mounts", nil)
+	for _, path := range []string{"secret/", "sys/", "cubbyhole/", "identity/"} {
+		if _, ok := resp.Data[path]; !ok {
+			t.Fatalf("expected mount %q, got %#v", path, resp.Data)
+		}
+	}
+	if len(resp.Data) != 4 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Only part of the system backend is available
+	if _, err := testNamespaceRequest(t, c, root, logical.ReadOperation, "ns1/sys/audit", nil); err == nil || !errwrap.Contains(err, logical.ErrUnsupportedPath.Error()) {
+		t.Fatalf("expected an unsupported path error, got %v", err)
+	}
+
+	// Policies of the namespace are separate from the ones of the root
+	testNamespaceRequestOK(t, c, root, logical.UpdateOperation, "ns1/sys/policy/ns-policy", map[string]interface{}{
+		"rules": `path "secret/*" { capabilities = ["read"] }`,
+	})
+	resp = testNamespaceRequestOK(t, c, root, logical.ListOperation, "sys/policy", nil)
+	if keys := resp.Data["keys"].([]string); !reflect.DeepEqual(keys, []string{"default", "root"}) {
+		t.Fatalf("bad: %#v", keys)
+	}
+	resp = testNamespaceRequestOK(t, c, root, logical.ListOperation, "ns1/sys/policy", nil)
+	if keys := resp.Data["keys"].([]string); !reflect.DeepEqual(keys, []string{"default", "ns-policy"}) {
+		t.Fatalf("bad: %#v", keys)
+	}
+
+	// Root tokens can't be created in a namespace
+	if _, err := testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/auth/token/create", nil); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	resp = testNamespaceRequestOK(t, c, root, logical.UpdateOperation, "ns1/auth/token/create", map[string]interface{}{
+		"policies": []string{"ns-policy"},
+	})
+	nsToken := resp.Auth.ClientToken
+
+	// The token uses the policies of its namespace
+	resp = testNamespaceRequestOK(t, c, nsToken, logical.ReadOperation, "ns1/secret/foo", nil)
+	if resp.Data["value"] != "ns1" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	resp = testNamespaceRequestOK(t, c, nsToken, logical.ReadOperation, "ns1/auth/token/lookup-self", nil)
+	if resp.Data["path"] != "ns1/auth/token/create" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// The token can't escape its namespace
+	if _, err := testNamespaceRequest(t, c, nsToken, logical.ReadOperation, "secret/foo", nil); err == nil || !errwrap.Contains(err, logical.ErrPermissionDenied.Error()) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	if _, err := testNamespaceRequest(t, c, nsToken, logical.ReadOperation, "auth/token/lookup-self", nil); err == nil || !errwrap.Contains(err, logical.ErrPermissionDenied.Error()) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+
+	// Tokens of the root namespace aren't visible in the namespace
+	resp = testNamespaceRequestOK(t, c, root, logical.UpdateOperation, "auth/token/create", map[string]interface{}{
+		"policies": []string{"default"},
+	})
+	if _, err := testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/auth/token/lookup", map[string]interface{}{
+		"token": resp.Auth.ClientToken,
+	}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// Deleting the namespace revokes its tokens and removes its data
+	testNamespaceRequestOK(t, c, root, logical.DeleteOperation, "sys/namespaces/ns1", nil)
+	te, err := c.tokenStore.Lookup(context.Background(), nsToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if te != nil {
+		t.Fatalf("expected the token to be revoked, got %#v", te)
+	}
+	if match := c.router.MatchingMount("ns1/secret/foo"); match != "" {
+		t.Fatalf("expected the mount to be removed, got %q", match)
+	}
+	resp = testNamespaceRequestOK(t, c, root, logical.ReadOperation, "secret/foo", nil)
+	if resp.Data["value"] != "root" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Recreating the namespace starts from scratch
+	testNamespaceRequestOK(t, c, root, logical.UpdateOperation, "sys/namespaces/ns1", nil)
+	resp = testNamespaceRequestOK(t, c, root, logical.ListOperation, "ns1/sys/policy", nil)
+	if keys := resp.Data["keys"].([]string); !reflect.DeepEqual(keys, []string{"default"}) {
+		t.Fatalf("bad: %#v", keys)
+	}
+}
@@ -0,0 +1,670 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+)
+
+const (
+	// perfStandbyWALRetention is the number of entries the storage WAL of the
+	// active node holds for performance standbys to catch up from
+	perfStandbyWALRetention = 4096
+
+	// perfStandbyWALBatchSize is the maximum number of entries sent to
+	// performance standbys at once
+	perfStandbyWALBatchSize = 256
+
+	// perfStandbyRetryInterval is how long performance standbys wait before
+	// following the storage WAL of the active node again after losing it
+	perfStandbyRetryInterval = 5 * time.Second
+)
+
+var (
+	// perfStandbyLocalUpdatePaths are the paths taking update operations that
+	// only look tokens up, which performance standbys handle locally
+	perfStandbyLocalUpdatePaths = map[string]bool{
+		"auth/token/lookup":          true,
+		"auth/token/lookup-accessor": true,
+		"auth/token/lookup-self":     true,
+	}
+
+	// perfStandbyReloadPaths are the storage paths holding the configuration
+	// the state of performance standbys is set up from. Standbys set their
+	// state up again when any of them is written.
+	perfStandbyReloadPaths = []string{
+		coreMountConfigPath,
+		coreLocalMountConfigPath,
+		coreAuthConfigPath,
+		coreLocalAuthConfigPath,
+		coreAuditConfigPath,
+		coreLocalAuditConfigPath,
+		coreNamespacesPath,
+		coreWrappingJWTKeyPath,
+		systemBarrierPrefix + "config/cors",
+		systemBarrierPrefix + auditedHeadersSubPath,
+	}
+
+	errNotActive = errors.New("node is not active")
+)
+
+// storageWAL is the write-ahead log of the keys the active node wrote to
+// storage. Performance standbys follow it to invalidate their view of
+// storage.
+type storageWAL struct {
+	l        sync.Mutex
+	epoch    string
+	index    uint64
+	entries  []*PerfStandbyWALEntry
+	notifyCh chan struct{}
+}
+
+func newStorageWAL() (*storageWAL, error) {
+	epoch, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	return &storageWAL{
+		epoch:    epoch,
+		notifyCh: make(chan struct{}),
+	}, nil
+}
+
+// append records the given keys as a new entry, waking up the streams
+// waiting for it
+func (w *storageWAL) append(keys ...string) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	w.index++
+	w.entries = append(w.entries, &PerfStandbyWALEntry{
+		Index: w.index,
+		Keys:  keys,
+	})
+	if len(w.entries) > perfStandbyWALRetention {
+		w.entries = w.entries[len(w.entries)-perfStandbyWALRetention:]
+	}
+
+	close(w.notifyCh)
+	w.notifyCh = make(chan struct{})
+}
+
+// current returns the epoch of the WAL and the index of its last entry
+func (w *storageWAL) current() (string, uint64) {
+	w.l.Lock()
+	defer w.l.Unlock()
+	return w.epoch, w.index
+}
+
+// entriesAfter returns the entries following the given index along with a
+// channel closed once more entries are appended. It returns false if some of
+// the entries following the index are no longer held.
+func (w *storageWAL) entriesAfter(index uint64) ([]*PerfStandbyWALEntry, <-chan struct{}, bool) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	switch {
+	case index > w.index:
+		return nil, w.notifyCh, false
+	case index == w.index:
+		return nil, w.notifyCh, true
+	}
+
+	first := w.entries[0].Index
+	if index+1 < first {
+		return nil, w.notifyCh, false
+	}
+	entries := make([]*PerfStandbyWALEntry, w.index-index)
+	copy(entries, w.entries[index+1-first:])
+	return entries, w.notifyCh, true
+}
+
+// walPhysical wraps the physical backend of the core. While the node is
+// active, writes are recorded in the storage WAL; while it is a performance
+// standby, writes are rejected as only the active node writes to storage.
+type walPhysical struct {
+	physical.Backend
+
+	wal      atomic.Value
+	readOnly *uint32
+}
+
+// transactionalWALPhysical is a walPhysical wrapping a transactional backend
+type transactionalWALPhysical struct {
+	*walPhysical
+	physical.Transactional
+}
+
+var _ physical.Backend = (*walPhysical)(nil)
+var _ physical.Transactional = (*transactionalWALPhysical)(nil)
+
+func newWALPhysical(b physical.Backend) *walPhysical {
+	p := &walPhysical{
+		Backend:  b,
+		readOnly: new(uint32),
+	}
+	p.wal.Store((*storageWAL)(nil))
+	return p
+}
+
+// storageWAL returns the WAL writes are recorded in, if any
+func (p *walPhysical) storageWAL() *storageWAL {
+	return p.wal.Load().(*storageWAL)
+}
+
+// setStorageWAL sets the WAL writes are recorded in; nil stops recording
+func (p *walPhysical) setStorageWAL(wal *storageWAL) {
+	p.wal.Store(wal)
+}
+
+// setReadOnly toggles rejecting writes
+func (p *walPhysical) setReadOnly(readOnly bool) {
+	if readOnly {
+		atomic.StoreUint32(p.readOnly, 1)
+		return
+	}
+	atomic.StoreUint32(p.readOnly, 0)
+}
+
+func (p *walPhysical) record(keys ...string) {
+	if wal := p.storageWAL(); wal != nil {
+		wal.append(keys...)
+	}
+}
+
+func (p *walPhysical) Put(ctx context.Context, entry *physical.Entry) error {
+	if atomic.LoadUint32(p.readOnly) == 1 {
+		return logical.ErrReadOnly
+	}
+	if err := p.Backend.Put(ctx, entry); err != nil {
+		return err
+	}
+	p.record(entry.Key)
+	return nil
+}
+
+func (p *walPhysical) Delete(ctx context.Context, key string) error {
+	if atomic.LoadUint32(p.readOnly) == 1 {
+		return logical.ErrReadOnly
+	}
+	if err := p.Backend.Delete(ctx, key); err != nil {
+		return err
+	}
+	p.record(key)
+	return nil
+}
+
+// Purge purges the cache the WAL wraps, so that PhysicalAccess can still
+// purge it
+func (p *walPhysical) Purge(ctx context.Context) {
+	if purgeable, ok := p.Backend.(physical.ToggleablePurgemonster); ok {
+		purgeable.Purge(ctx)
+	}
+}
+
+func (p *transactionalWALPhysical) Transaction(ctx context.Context, txns []*physical.TxnEntry) error {
+	if atomic.LoadUint32(p.readOnly) == 1 {
+		return logical.ErrReadOnly
+	}
+	if err := p.Transactional.Transaction(ctx, txns); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(txns))
+	for _, txn := range txns {
+		keys = append(keys, txn.Entry.Key)
+	}
+	p.record(keys...)
+	return nil
+}
+
+// PerfStandby returns true if the node is a standby serving reads locally
+func (c *Core) PerfStandby() bool {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	return c.perfStandby
+}
+
+// WALIndex returns the position in the storage WAL of the active node that
+// the state of the node reflects, in the form "<epoch>:<index>". It returns
+// an empty string if the node is neither active nor a performance standby.
+func (c *Core) WALIndex() string {
+	epoch, index := c.perfStandbyWALPosition()
+	if wal := c.walPhysical.storageWAL(); wal != nil {
+		epoch, index = wal.current()
+	}
+	if epoch == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", epoch, index)
+}
+
+// WALIndexApplied returns true if the state of the node reflects the given
+// position in the storage WAL, as returned by WALIndex, so that it can serve
+// reads following the writes up to it
+func (c *Core) WALIndexApplied(walIndex string) bool {
+	if c.walPhysical.storageWAL() != nil {
+		return true
+	}
+
+	parts := strings.SplitN(walIndex, ":", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	index, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return false
+	}
+	epoch, applied := c.perfStandbyWALPosition()
+	return epoch != "" && parts[0] == epoch && index <= applied
+}
+
+func (c *Core) perfStandbyWALPosition() (string, uint64) {
+	c.perfStandbyWALLock.RLock()
+	defer c.perfStandbyWALLock.RUnlock()
+	return c.perfStandbyWALEpoch, c.perfStandbyWALIndex
+}
+
+func (c *Core) setPerfStandbyWALPosition(epoch string, index uint64) {
+	c.perfStandbyWALLock.Lock()
+	defer c.perfStandbyWALLock.Unlock()
+	c.perfStandbyWALEpoch = epoch
+	c.perfStandbyWALIndex = index
+}
+
+// perfStandbyLocalRequest returns true if performance standbys handle the
+// request locally rather than forwarding it to the active node
+func perfStandbyLocalRequest(req *logical.Request, nsPath string) bool {
+	switch req.Operation {
+	case logical.ReadOperation, logical.ListOperation, logical.HelpOperation:
+		return true
+	case logical.UpdateOperation:
+		return perfStandbyLocalUpdatePaths[strings.TrimSuffix(nsPath, "/")]
+	}
+	return false
+}
+
+// perfStandbyForwardError returns true if the error means a request handled
+// by a performance standby has to be forwarded to the active node, for
+// instance because handling it requires writing to storage
+func perfStandbyForwardError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return strings.Contains(err.Error(), logical.ErrReadOnly.Error()) ||
+		strings.Contains(err.Error(), consts.ErrPerfStandbyPleaseForward.Error())
+}
+
+// setupPerfStandby sets up the part of the state of an active node needed
+// to serve reads. Storage is read-only meanwhile, and nothing that writes
+// to it, such as revoking leases, is run. The stateLock must be held.
+func (c *Core) setupPerfStandby() (retErr error) {
+	c.logger.Info("performance standby setup starting")
+
+	c.activeContext, c.activeContextCancelFunc = context.WithCancel(context.Background())
+
+	state := c.ReplicationState()
+	state.AddState(consts.ReplicationPerformanceStandby)
+	atomic.StoreUint32(c.replicationState, uint32(state))
+	c.walPhysical.setReadOnly(true)
+
+	defer func() {
+		if retErr != nil {
+			c.teardownPerfStandby()
+		}
+	}()
+
+	c.physicalCache.Purge(c.activeContext)
+	if !c.cachingDisabled {
+		c.physicalCache.SetEnabled(true)
+	}
+
+	if err := c.ensureWrappingKey(c.activeContext); err != nil {
+		return err
+	}
+	if err := c.setupPluginCatalog(); err != nil {
+		return err
+	}
+	if err := c.loadNamespaces(c.activeContext); err != nil {
+		return err
+	}
+	if err := c.loadMounts(c.activeContext); err != nil {
+		return err
+	}
+	if err := c.setupMounts(c.activeContext); err != nil {
+		return err
+	}
+	if err := c.setupPolicyStore(c.activeContext); err != nil {
+		return err
+	}
+	if err := c.loadCORSConfig(c.activeContext); err != nil {
+		return err
+	}
+	if err := c.loadCredentials(c.activeContext); err != nil {
+		return err
+	}
+	if err := c.setupCredentials(c.activeContext); err != nil {
+		return err
+	}
+	if err := c.setupNamespaces(c.activeContext); err != nil {
+		return err
+	}
+	if err := c.setupQuotas(c.activeContext); err != nil {
+		return err
+	}
+	if err := c.setupExpiration(); err != nil {
+		return err
+	}
+	if err := c.loadAudits(c.activeContext); err != nil {
+		return err
+	}
+	if err := c.setupAudits(c.activeContext); err != nil {
+		return err
+	}
+	if err := c.loadIdentityStoreArtifacts(c.activeContext); err != nil {
+		return err
+	}
+	if err := c.setupAuditedHeadersConfig(c.activeContext); err != nil {
+		return err
+	}
+
+	c.perfStandby = true
+	c.logger.Info("performance standby setup complete")
+	return nil
+}
+
+// teardownPerfStandby tears the state set up by setupPerfStandby down. The
+// stateLock must be held.
+func (c *Core) teardownPerfStandby() error {
+	state := c.ReplicationState()
+	if !state.HasState(consts.ReplicationPerformanceStandby) {
+		return nil
+	}
+	c.logger.Info("performance standby teardown starting")
+
+	c.perfStandby = false
+	c.setPerfStandbyWALPosition("", 0)
+
+	if c.activeContextCancelFunc != nil {
+		c.activeContextCancelFunc()
+	}
+
+	var result error
+	if err := c.teardownAudits(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down audits: {{err}}", err))
+	}
+	if err := c.stopExpiration(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error stopping expiration: {{err}}", err))
+	}
+	if err := c.teardownCredentials(c.activeContext); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down credentials: {{err}}", err))
+	}
+	if err := c.teardownPolicyStore(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down policy store: {{err}}", err))
+	}
+	if err := c.teardownQuotas(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down quotas: {{err}}", err))
+	}
+	if err := c.unloadMounts(c.activeContext); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error unloading mounts: {{err}}", err))
+	}
+	if err := c.teardownNamespaces(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down namespaces: {{err}}", err))
+	}
+
+	c.physicalCache.SetEnabled(false)
+	c.physicalCache.Purge(c.activeContext)
+
+	c.walPhysical.setReadOnly(false)
+	state.ClearState(consts.ReplicationPerformanceStandby)
+	atomic.StoreUint32(c.replicationState, uint32(state))
+
+	c.logger.Info("performance standby teardown complete")
+	return result
+}
+
+// resetPerfStandby sets the state of the performance standby up from
+// scratch, as of the given position in the storage WAL
+func (c *Core) resetPerfStandby(ctx context.Context, epoch string, index uint64) error {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if c.sealed || !c.standby {
+		return errors.New("node is no longer a standby")
+	}
+
+	if err := c.teardownPerfStandby(); err != nil {
+		c.logger.Error("performance standby teardown failed", "error", err)
+	}
+	if err := c.setupPerfStandby(); err != nil {
+		return err
+	}
+	c.setPerfStandbyWALPosition(epoch, index)
+	return nil
+}
+
+// stopPerfStandby stops serving reads locally
+func (c *Core) stopPerfStandby() {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+
+	if err := c.teardownPerfStandby(); err != nil {
+		c.logger.Error("performance standby teardown failed", "error", err)
+	}
+}
+
+// runPerfStandby follows the storage WAL of the active node the standby is
+// connected to through the given client, keeping the state it serves reads
+// from up to date. It runs until ctx is canceled, which happens when the
+// connection to the active node is cleared.
+func (c *Core) runPerfStandby(ctx context.Context, client RequestForwardingClient) {
+	// Wait for the goroutine following the previous active node to tear
+	// its state down
+	c.perfStandbyRunLock.Lock()
+	defer c.perfStandbyRunLock.Unlock()
+	defer c.stopPerfStandby()
+
+	for {
+		err := c.followStorageWAL(ctx, client)
+		if ctx.Err() != nil {
+			return
+		}
+		c.logger.Warn("lost the storage WAL of the active node, following it again", "error", err, "retry_interval", perfStandbyRetryInterval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(perfStandbyRetryInterval):
+		}
+	}
+}
+
+func (c *Core) followStorageWAL(ctx context.Context, client RequestForwardingClient) error {
+	epoch, index := c.perfStandbyWALPosition()
+	stream, err := client.PerformanceStandbyWALStream(ctx, &PerfStandbyWALStreamRequest{
+		Epoch: epoch,
+		Index: index,
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		batch, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if err := c.applyStorageWALBatch(ctx, batch); err != nil {
+			return err
+		}
+	}
+}
+
+// applyStorageWALBatch invalidates the view of storage of the performance
+// standby for the keys written by the active node
+func (c *Core) applyStorageWALBatch(ctx context.Context, batch *PerfStandbyWALBatch) error {
+	epoch, _ := c.perfStandbyWALPosition()
+	if batch.Reset_ || batch.Epoch != epoch {
+		return c.resetPerfStandby(ctx, batch.Epoch, batch.Index)
+	}
+	if len(batch.Entries) == 0 {
+		return nil
+	}
+
+	index := batch.Entries[len(batch.Entries)-1].Index
+	var keys []string
+	for _, entry := range batch.Entries {
+		keys = append(keys, entry.Keys...)
+	}
+
+	for _, key := range keys {
+		for _, path := range perfStandbyReloadPaths {
+			if strings.HasPrefix(key, path) {
+				return c.resetPerfStandby(ctx, epoch, index)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		c.physicalCache.Invalidate(ctx, key)
+
+		if c.invalidateNamespacePolicy(ctx, key) {
+			continue
+		}
+
+		c.stateLock.RLock()
+		var backend logical.Backend
+		prefix, ok := "", false
+		if c.perfStandby {
+			backend, prefix, ok = c.router.MatchingBackendByStoragePath(key)
+		}
+		c.stateLock.RUnlock()
+
+		if ok && backend != nil {
+			backend.InvalidateKey(ctx, strings.TrimPrefix(key, prefix))
+		}
+	}
+
+	c.setPerfStandbyWALPosition(epoch, index)
+	return nil
+}
+
+// invalidateNamespacePolicy invalidates the cached policy stored at the given
+// key if it is a policy of a namespace, returning whether it was. Policies of
+// namespaces are stored under the namespace rather than under a mount, so no
+// backend is told about them.
+func (c *Core) invalidateNamespacePolicy(ctx context.Context, key string) bool {
+	if !strings.HasPrefix(key, namespaceBarrierPrefix) {
+		return false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(key, namespaceBarrierPrefix), "/", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[1], systemBarrierPrefix) {
+		return false
+	}
+
+	var policyType PolicyType
+	name := strings.TrimPrefix(parts[1], systemBarrierPrefix)
+	switch {
+	case strings.HasPrefix(name, policyACLSubPath):
+		policyType, name = PolicyTypeACL, strings.TrimPrefix(name, policyACLSubPath)
+	case strings.HasPrefix(name, policyEGPSubPath):
+		policyType, name = PolicyTypeEGP, strings.TrimPrefix(name, policyEGPSubPath)
+	default:
+		return false
+	}
+
+	ns := c.namespaceByID(parts[0])
+	if ns == nil {
+		// The namespace is being created or deleted, which sets the state of
+		// the standby up again
+		return true
+	}
+
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	if c.perfStandby && c.policyStore != nil {
+		c.policyStore.invalidate(namespace.ContextWithNamespace(ctx, ns), name, policyType)
+	}
+	return true
+}
+
+// streamStorageWAL sends the entries of the storage WAL to a performance
+// standby, starting after the position given in the request, until either
+// node goes away
+func (c *Core) streamStorageWAL(req *PerfStandbyWALStreamRequest, stream RequestForwarding_PerformanceStandbyWALStreamServer) error {
+	c.stateLock.RLock()
+	wal := c.walPhysical.storageWAL()
+	activeCtx := c.activeContext
+	standby := c.standby
+	c.stateLock.RUnlock()
+	if standby || wal == nil || activeCtx == nil {
+		return errNotActive
+	}
+
+	sendReset := func() (uint64, error) {
+		epoch, index := wal.current()
+		return index, stream.Send(&PerfStandbyWALBatch{
+			Epoch:  epoch,
+			Reset_: true,
+			Index:  index,
+		})
+	}
+
+	epoch, _ := wal.current()
+	next := req.Index
+	if req.Epoch != epoch {
+		var err error
+		if next, err = sendReset(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		entries, notifyCh, ok := wal.entriesAfter(next)
+		if !ok {
+			var err error
+			if next, err = sendReset(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for len(entries) > 0 {
+			batch := entries
+			if len(batch) > perfStandbyWALBatchSize {
+				batch = batch[:perfStandbyWALBatchSize]
+			}
+			if err := stream.Send(&PerfStandbyWALBatch{
+				Epoch:   epoch,
+				Entries: batch,
+			}); err != nil {
+				return err
+			}
+			entries = entries[len(batch):]
+			next = batch[len(batch)-1].Index
+		}
+
+		select {
+		case <-notifyCh:
+		case <-stream.Context().Done():
+			return nil
+		case <-activeCtx.Done():
+			return errNotActive
+		}
+	}
+}
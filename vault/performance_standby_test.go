@@ -0,0 +1,229 @@
+package vault
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestStorageWAL(t *testing.T) {
+	wal, err := newStorageWAL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, notifyCh, ok := wal.entriesAfter(0)
+	if !ok || len(entries) != 0 {
+		t.Fatalf("bad: %v %v", entries, ok)
+	}
+
+	wal.append("foo")
+	wal.append("bar", "baz")
+
+	select {
+	case <-notifyCh:
+	default:
+		t.Fatal("expected notification of the new entries")
+	}
+
+	epoch, index := wal.current()
+	if epoch == "" || index != 2 {
+		t.Fatalf("bad: %q %d", epoch, index)
+	}
+
+	entries, _, ok = wal.entriesAfter(1)
+	if !ok || len(entries) != 1 || entries[0].Index != 2 || len(entries[0].Keys) != 2 {
+		t.Fatalf("bad: %v %v", entries, ok)
+	}
+
+	// Positions past the WAL are unknown
+	if _, _, ok := wal.entriesAfter(3); ok {
+		t.Fatal("expected unknown position")
+	}
+
+	// Entries no longer held can't be caught up from
+	for i := 0; i < perfStandbyWALRetention; i++ {
+		wal.append(fmt.Sprintf("key%d", i))
+	}
+	if _, _, ok := wal.entriesAfter(1); ok {
+		t.Fatal("expected entries to be dropped")
+	}
+	entries, _, ok = wal.entriesAfter(2)
+	if !ok || len(entries) != perfStandbyWALRetention {
+		t.Fatalf("bad: %d %v", len(entries), ok)
+	}
+}
+
+func TestPerformanceStandby_ServeReads(t *testing.T) {
+	cluster := NewTestCluster(t, &CoreConfig{
+		PerformanceStandby: true,
+		LogicalBackends: map[string]logical.Factory{
+			"kv": PassthroughBackendFactory,
+		},
+	}, nil)
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	active := cluster.Cores[0].Core
+	standby := cluster.Cores[1].Core
+	root := cluster.RootToken
+	TestWaitActive(t, active)
+
+	// Requesting the leader sets up the connection to the active node
+	deadline := time.Now().Add(30 * time.Second)
+	for !standby.PerfStandby() {
+		if time.Now().After(deadline) {
+			t.Fatal("standby did not become a performance standby")
+		}
+		standby.Leader()
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	waitApplied := func() {
+		t.Helper()
+		index := active.WALIndex()
+		deadline := time.Now().Add(10 * time.Second)
+		for !standby.WALIndexApplied(index) {
+			if time.Now().After(deadline) {
+				t.Fatalf("standby did not apply index %q, at %q", index, standby.WALIndex())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	write := func(core *Core, path, value string) error {
+		_, err := core.HandleRequest(&logical.Request{
+			Operation:   logical.UpdateOperation,
+			Path:        path,
+			ClientToken: root,
+			Data: map[string]interface{}{
+				"value": value,
+			},
+		})
+		return err
+	}
+
+	read := func(path string) interface{} {
+		t.Helper()
+		resp, err := standby.HandleRequest(&logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        path,
+			ClientToken: root,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil {
+			return nil
+		}
+		return resp.Data["value"]
+	}
+
+	if err := write(active, "secret/foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	waitApplied()
+	if value := read("secret/foo"); value != "bar" {
+		t.Fatalf("bad: %v", value)
+	}
+
+	// Cached entries are invalidated by writes of the active node
+	if err := write(active, "secret/foo", "baz"); err != nil {
+		t.Fatal(err)
+	}
+	waitApplied()
+	if value := read("secret/foo"); value != "baz" {
+		t.Fatalf("bad: %v", value)
+	}
+
+	// Writes are left to the active node
+	if err := write(standby, "secret/foo", "qux"); err != consts.ErrPerfStandbyPleaseForward {
+		t.Fatalf("expected forwarding, got: %v", err)
+	}
+
+	// Mounts made by the active node are set up on the standby
+	_, err := active.HandleRequest(&logical.Request{
+		Operation:   logical.UpdateOperation,
+		Path:        "sys/mounts/kv",
+		ClientToken: root,
+		Data: map[string]interface{}{
+			"type": "kv",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := write(active, "kv/foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	waitApplied()
+	if value := read("kv/foo"); value != "bar" {
+		t.Fatalf("bad: %v", value)
+	}
+
+	// Changes to the policies of namespaces are enforced by the standby
+	mustActive := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := active.HandleRequest(&logical.Request{
+			Operation:   logical.UpdateOperation,
+			Path:        path,
+			ClientToken: root,
+			Data:        data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	mustActive("sys/namespaces/ns1", nil)
+	mustActive("ns1/sys/mounts/secret", map[string]interface{}{"type": "kv"})
+	mustActive("ns1/secret/foo", map[string]interface{}{"value": "foo"})
+	mustActive("ns1/secret/bar", map[string]interface{}{"value": "bar"})
+	mustActive("ns1/sys/policy/ns-policy", map[string]interface{}{
+		"rules": `path "secret/foo" { capabilities = ["read"] }`,
+	})
+	nsToken := mustActive("ns1/auth/token/create", map[string]interface{}{
+		"policies": []string{"ns-policy"},
+	}).Auth.ClientToken
+
+	canRead := func(path string) bool {
+		t.Helper()
+		_, err := standby.HandleRequest(&logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        path,
+			ClientToken: nsToken,
+		})
+		if err != nil && !errwrap.Contains(err, logical.ErrPermissionDenied.Error()) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+	waitApplied()
+	if !canRead("ns1/secret/foo") || canRead("ns1/secret/bar") {
+		t.Fatal("expected the first version of the policy to be enforced")
+	}
+
+	mustActive("ns1/sys/policy/ns-policy", map[string]interface{}{
+		"rules": `path "secret/bar" { capabilities = ["read"] }`,
+	})
+	waitApplied()
+	if canRead("ns1/secret/foo") || !canRead("ns1/secret/bar") {
+		t.Fatal("expected the updated policy to be enforced")
+	}
+
+	if _, err := active.HandleRequest(&logical.Request{
+		Operation:   logical.DeleteOperation,
+		Path:        "ns1/sys/policy/ns-policy",
+		ClientToken: root,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	waitApplied()
+	if canRead("ns1/secret/foo") || canRead("ns1/secret/bar") {
+		t.Fatal("expected the deleted policy to no longer be enforced")
+	}
+}
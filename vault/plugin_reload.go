@@ -3,7 +3,6 @@ package vault
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/logical"
@@ -24,11 +23,7 @@ func (c *Core) reloadMatchingPluginMounts(ctx context.Context, mounts []string)
 			// return fmt.Errorf("cannot fetch mount entry on %s", mount)
 		}
 
-		var isAuth bool
-		fullPath := c.router.MatchingMount(mount)
-		if strings.HasPrefix(fullPath, credentialRoutePrefix) {
-			isAuth = true
-		}
+		isAuth := entry.Table == credentialTableType
 
 		if entry.Type == "plugin" {
 			err := c.reloadBackendCommon(ctx, entry, isAuth)
@@ -77,11 +72,7 @@ func (c *Core) reloadMatchingPlugin(ctx context.Context, pluginName string) erro
 // reloadBackendCommon is a generic method to reload a backend provided a
 // MountEntry.
 func (c *Core) reloadBackendCommon(ctx context.Context, entry *MountEntry, isAuth bool) error {
-	path := entry.Path
-
-	if isAuth {
-		path = credentialRoutePrefix + path
-	}
+	path := entry.APIPath()
 
 	// Fast-path out if the backend doesn't exist
 	raw, ok := c.router.root.Get(path)
@@ -10,6 +10,8 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/hcl"
 	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/vault/helper/expr"
+	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/mitchellh/copystructure"
 )
@@ -84,6 +86,17 @@ type Policy struct {
 	Paths []*PathRules `hcl:"-"`
 	Raw   string
 	Type  PolicyType
+
+	// Templated is set if paths of the policy contain identity template
+	// directives. They are left out of Paths, and only apply once the policy
+	// is parsed again with the entity of the requester.
+	Templated bool
+
+	// EnforcementLevel and EGPPaths are only set on endpoint governing
+	// policies, along with their parsed rules
+	EnforcementLevel string
+	EGPPaths         []string
+	egpProgram       *expr.Program
 }
 
 // PathRules represents a policy for a path in the namespace.
@@ -110,6 +123,27 @@ type ACLPermissions struct {
 	AllowedParameters  map[string][]interface{}
 	DeniedParameters   map[string][]interface{}
 	RequiredParameters []string
+	ControlGroup       *ControlGroup
+}
+
+// ControlGroup requires requests to a path to be approved before they are
+// performed. Every factor must be satisfied.
+type ControlGroup struct {
+	TTL     time.Duration         `json:"ttl"`
+	Factors []*ControlGroupFactor `json:"factors"`
+}
+
+// ControlGroupFactor is a named set of approvers of a control group
+type ControlGroupFactor struct {
+	Name     string          `json:"name"`
+	Identity *IdentityFactor `json:"identity"`
+}
+
+// IdentityFactor is satisfied by the approvals of distinct entities which are
+// members of one of the identity groups
+type IdentityFactor struct {
+	GroupNames        []string `json:"group_names"`
+	ApprovalsRequired int      `json:"approvals"`
 }
 
 func (p *ACLPermissions) Clone() (*ACLPermissions, error) {
@@ -120,6 +154,13 @@ func (p *ACLPermissions) Clone() (*ACLPermissions, error) {
 		RequiredParameters: p.RequiredParameters[:],
 	}
 
+	if p.ControlGroup != nil {
+		ret.ControlGroup = &ControlGroup{
+			TTL:     p.ControlGroup.TTL,
+			Factors: append([]*ControlGroupFactor(nil), p.ControlGroup.Factors...),
+		}
+	}
+
 	switch {
 	case p.AllowedParameters == nil:
 	case len(p.AllowedParameters) == 0:
@@ -151,6 +192,13 @@ func (p *ACLPermissions) Clone() (*ACLPermissions, error) {
 // intermediary set of policies, before being compiled into
 // the ACL
 func ParseACLPolicy(rules string) (*Policy, error) {
+	return parseACLPolicyWithTemplating(rules, false, nil, nil)
+}
+
+// parseACLPolicyWithTemplating parses the ACL rules. If performTemplating is
+// set, the template directives of the paths are resolved with the given
+// entity and groups, and paths which can't be resolved are skipped.
+func parseACLPolicyWithTemplating(rules string, performTemplating bool, entity *identity.Entity, groups []*identity.Group) (*Policy, error) {
 	// Parse the rules
 	root, err := hcl.Parse(rules)
 	if err != nil {
@@ -181,7 +229,7 @@ func ParseACLPolicy(rules string) (*Policy, error) {
 	}
 
 	if o := list.Filter("path"); len(o.Items) > 0 {
-		if err := parsePaths(&p, o); err != nil {
+		if err := parsePaths(&p, o, performTemplating, entity, groups); err != nil {
 			return nil, fmt.Errorf("Failed to parse policy: %s", err)
 		}
 	}
@@ -189,13 +237,39 @@ func ParseACLPolicy(rules string) (*Policy, error) {
 	return &p, nil
 }
 
-func parsePaths(result *Policy, list *ast.ObjectList) error {
+func parsePaths(result *Policy, list *ast.ObjectList, performTemplating bool, entity *identity.Entity, groups []*identity.Group) error {
 	paths := make([]*PathRules, 0, len(list.Items))
 	for _, item := range list.Items {
 		key := "path"
 		if len(item.Keys) > 0 {
 			key = item.Keys[0].Token.Value().(string)
 		}
+
+		// Templated paths are only added once they are resolved for a
+		// requester. Paths which can't be resolved don't apply to them.
+		if performTemplating {
+			_, templated, err := identity.PopulateString(&identity.PopulateStringInput{
+				ACLPath: true,
+				String:  key,
+				Entity:  entity,
+				Groups:  groups,
+			})
+			if err != nil {
+				continue
+			}
+			key = templated
+		} else {
+			hasTemplating, _, err := identity.PopulateString(&identity.PopulateStringInput{
+				ValidityCheckOnly: true,
+				String:            key,
+			})
+			if err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("path %q: failed to validate policy templating: {{err}}", key), err)
+			}
+			if hasTemplating {
+				result.Templated = true
+			}
+		}
 		valid := []string{
 			"policy",
 			"capabilities",
@@ -204,6 +278,7 @@ func parsePaths(result *Policy, list *ast.ObjectList) error {
 			"required_parameters",
 			"min_wrapping_ttl",
 			"max_wrapping_ttl",
+			"control_group",
 		}
 		if err := checkHCLKeys(item.Val, valid); err != nil {
 			return multierror.Prefix(err, fmt.Sprintf("path %q:", key))
@@ -297,8 +372,20 @@ func parsePaths(result *Policy, list *ast.ObjectList) error {
 		if len(pc.RequiredParametersHCL) > 0 {
 			pc.Permissions.RequiredParameters = pc.RequiredParametersHCL[:]
 		}
+		if o, ok := item.Val.(*ast.ObjectType); ok {
+			if cgList := o.List.Filter("control_group"); len(cgList.Items) > 0 {
+				cg, err := parseControlGroup(cgList)
+				if err != nil {
+					return multierror.Prefix(err, fmt.Sprintf("path %q:", key))
+				}
+				pc.Permissions.ControlGroup = cg
+			}
+		}
 
 	PathFinished:
+		if !performTemplating && strings.Contains(key, "{{") {
+			continue
+		}
 		paths = append(paths, &pc)
 	}
 
@@ -306,6 +393,88 @@ func parsePaths(result *Policy, list *ast.ObjectList) error {
 	return nil
 }
 
+func parseControlGroup(list *ast.ObjectList) (*ControlGroup, error) {
+	if len(list.Items) > 1 {
+		return nil, errors.New("only one control_group may be specified")
+	}
+	item := list.Items[0]
+	if err := checkHCLKeys(item.Val, []string{"ttl", "factor"}); err != nil {
+		return nil, multierror.Prefix(err, "control_group:")
+	}
+
+	var cgHCL struct {
+		TTL interface{} `hcl:"ttl"`
+	}
+	if err := hcl.DecodeObject(&cgHCL, item.Val); err != nil {
+		return nil, multierror.Prefix(err, "control_group:")
+	}
+
+	cg := new(ControlGroup)
+	if cgHCL.TTL != nil {
+		dur, err := parseutil.ParseDurationSecond(cgHCL.TTL)
+		if err != nil {
+			return nil, errwrap.Wrapf("error parsing control_group ttl: {{err}}", err)
+		}
+		cg.TTL = dur
+	}
+
+	o, ok := item.Val.(*ast.ObjectType)
+	if !ok {
+		return nil, errors.New("control_group: must be an object")
+	}
+	for _, factorItem := range o.List.Filter("factor").Items {
+		if len(factorItem.Keys) == 0 {
+			return nil, errors.New("control_group: factor must have a name")
+		}
+		name := factorItem.Keys[0].Token.Value().(string)
+		if err := checkHCLKeys(factorItem.Val, []string{"identity"}); err != nil {
+			return nil, multierror.Prefix(err, fmt.Sprintf("control_group factor %q:", name))
+		}
+
+		factorObj, ok := factorItem.Val.(*ast.ObjectType)
+		if !ok {
+			return nil, fmt.Errorf("control_group factor %q: must be an object", name)
+		}
+		identityList := factorObj.List.Filter("identity")
+		if len(identityList.Items) != 1 {
+			return nil, fmt.Errorf("control_group factor %q: exactly one identity block must be specified", name)
+		}
+		if err := checkHCLKeys(identityList.Items[0].Val, []string{"group_names", "approvals"}); err != nil {
+			return nil, multierror.Prefix(err, fmt.Sprintf("control_group factor %q:", name))
+		}
+
+		var identityHCL struct {
+			GroupNames []string `hcl:"group_names"`
+			Approvals  int      `hcl:"approvals"`
+		}
+		if err := hcl.DecodeObject(&identityHCL, identityList.Items[0].Val); err != nil {
+			return nil, multierror.Prefix(err, fmt.Sprintf("control_group factor %q:", name))
+		}
+		if len(identityHCL.GroupNames) == 0 {
+			return nil, fmt.Errorf("control_group factor %q: group_names must be specified", name)
+		}
+		switch {
+		case identityHCL.Approvals < 0:
+			return nil, fmt.Errorf("control_group factor %q: approvals cannot be negative", name)
+		case identityHCL.Approvals == 0:
+			identityHCL.Approvals = 1
+		}
+
+		cg.Factors = append(cg.Factors, &ControlGroupFactor{
+			Name: name,
+			Identity: &IdentityFactor{
+				GroupNames:        identityHCL.GroupNames,
+				ApprovalsRequired: identityHCL.Approvals,
+			},
+		})
+	}
+	if len(cg.Factors) == 0 {
+		return nil, errors.New("control_group: at least one factor must be specified")
+	}
+
+	return cg, nil
+}
+
 func checkHCLKeys(node ast.Node, valid []string) error {
 	var list *ast.ObjectList
 	switch n := node.(type) {
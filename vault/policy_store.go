@@ -12,6 +12,8 @@ import (
 	log "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/golang-lru"
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 )
@@ -21,6 +23,9 @@ const (
 	// view. This is nested under the system view.
 	policyACLSubPath = "policy/"
 
+	// policyEGPSubPath is the sub-path used for endpoint governing policies
+	policyEGPSubPath = "policy-egp/"
+
 	// policyCacheSize is the number of policies that are kept cached
 	policyCacheSize = 1024
 
@@ -146,6 +151,10 @@ type PolicyStore struct {
 	modifyLock *sync.RWMutex
 	// Stores whether a token policy is ACL or RGP
 	policyTypeMap sync.Map
+	// egpView is the view EGPs of the root namespace are stored in, and
+	// egpCache holds the parsed EGPs of each namespace, by namespace ID
+	egpView  *BarrierView
+	egpCache sync.Map
 	// logger is the server logger copied over from core
 	logger log.Logger
 }
@@ -155,6 +164,10 @@ type PolicyEntry struct {
 	Version int
 	Raw     string
 	Type    PolicyType
+
+	// Only set for EGPs
+	EnforcementLevel string
+	EGPPaths         []string
 }
 
 // NewPolicyStore creates a new PolicyStore that is backed
@@ -162,6 +175,7 @@ type PolicyEntry struct {
 func NewPolicyStore(ctx context.Context, core *Core, baseView *BarrierView, system logical.SystemView, logger log.Logger) *PolicyStore {
 	ps := &PolicyStore{
 		aclView:    baseView.SubView(policyACLSubPath),
+		egpView:    baseView.SubView(policyEGPSubPath),
 		modifyLock: new(sync.RWMutex),
 		logger:     logger,
 		core:       core,
@@ -195,6 +209,10 @@ func (c *Core) setupPolicyStore(ctx context.Context) error {
 		// Policies will sync from the primary
 		return nil
 	}
+	if c.ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		// The active node ensures the policies exist
+		return nil
+	}
 
 	// Ensure that the default policy exists, and if not, create it
 	if err := c.policyStore.loadACLPolicy(ctx, defaultPolicyName, defaultPolicy); err != nil {
@@ -204,10 +222,102 @@ func (c *Core) setupPolicyStore(ctx context.Context) error {
 	if err := c.policyStore.loadACLPolicy(ctx, responseWrappingPolicyName, responseWrappingPolicy); err != nil {
 		return err
 	}
+	// Ensure that the control group policy exists
+	if err := c.policyStore.loadACLPolicy(ctx, controlGroupPolicyName, controlGroupPolicy); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// initNamespace prepares the policy store for the given namespace. It is called
+// when the namespace is created and on every unseal.
+func (ps *PolicyStore) initNamespace(ctx context.Context, ns *namespace.Namespace) error {
+	ctx = namespace.ContextWithNamespace(ctx, ns)
+
+	keys, err := logical.CollectKeys(ctx, ps.aclViewForNamespace(ns))
+	if err != nil {
+		return errwrap.Wrapf("error collecting acl policy keys: {{err}}", err)
+	}
+	for _, key := range keys {
+		ps.policyTypeMap.Store(ps.cacheKey(ns, ps.sanitizeName(key)), PolicyTypeACL)
+	}
+
+	if ps.core.ReplicationState().HasState(consts.ReplicationPerformanceSecondary) {
+		// Policies will sync from the primary
+		return nil
+	}
+
+	// Every namespace gets its own default, response wrapping and control
+	// group policies
+	if err := ps.loadACLPolicy(ctx, defaultPolicyName, defaultPolicy); err != nil {
+		return err
+	}
+	if err := ps.loadACLPolicy(ctx, responseWrappingPolicyName, responseWrappingPolicy); err != nil {
+		return err
+	}
+	return ps.loadACLPolicy(ctx, controlGroupPolicyName, controlGroupPolicy)
+}
+
+// clearNamespace removes all the policies of the given namespace
+func (ps *PolicyStore) clearNamespace(ctx context.Context, ns *namespace.Namespace) error {
+	ps.modifyLock.Lock()
+	defer ps.modifyLock.Unlock()
+
+	if err := logical.ClearView(ctx, ps.aclViewForNamespace(ns)); err != nil {
+		return errwrap.Wrapf("failed to delete policies: {{err}}", err)
+	}
+	if err := logical.ClearView(ctx, ps.egpViewForNamespace(ns)); err != nil {
+		return errwrap.Wrapf("failed to delete endpoint governing policies: {{err}}", err)
+	}
+	ps.egpCache.Delete(ns.ID)
+
+	prefix := ps.cacheKey(ns, "")
+	ps.policyTypeMap.Range(func(key, _ interface{}) bool {
+		if strings.HasPrefix(key.(string), prefix) {
+			ps.policyTypeMap.Delete(key)
+		}
+		return true
+	})
+	if ps.tokenPoliciesLRU != nil {
+		for _, key := range ps.tokenPoliciesLRU.Keys() {
+			if strings.HasPrefix(key.(string), prefix) {
+				ps.tokenPoliciesLRU.Remove(key)
+			}
+		}
+	}
 
 	return nil
 }
 
+// aclViewForNamespace returns the view the ACL policies of the given namespace
+// are stored in
+func (ps *PolicyStore) aclViewForNamespace(ns *namespace.Namespace) *BarrierView {
+	if ns.ID == namespace.RootNamespaceID {
+		return ps.aclView
+	}
+	return NewBarrierView(ps.core.barrier, namespaceBarrierPrefix+ns.ID+"/"+systemBarrierPrefix+policyACLSubPath)
+}
+
+// egpViewForNamespace returns the view the EGPs of the given namespace are
+// stored in
+func (ps *PolicyStore) egpViewForNamespace(ns *namespace.Namespace) *BarrierView {
+	if ns.ID == namespace.RootNamespaceID {
+		return ps.egpView
+	}
+	return NewBarrierView(ps.core.barrier, namespaceBarrierPrefix+ns.ID+"/"+systemBarrierPrefix+policyEGPSubPath)
+}
+
+// cacheKey returns the key a policy is cached under. Policies of namespaces
+// other than the root are keyed by the namespace ID as well, since names are
+// only unique within a namespace.
+func (ps *PolicyStore) cacheKey(ns *namespace.Namespace, name string) string {
+	if ns.ID == namespace.RootNamespaceID {
+		return name
+	}
+	return ns.ID + "/" + name
+}
+
 // teardownPolicyStore is used to reverse setupPolicyStore
 // when the vault is being sealed.
 func (c *Core) teardownPolicyStore() error {
@@ -224,9 +334,12 @@ func (ps *PolicyStore) invalidate(ctx context.Context, name string, policyType P
 	switch policyType {
 	case PolicyTypeACL:
 		if ps.tokenPoliciesLRU != nil {
-			ps.tokenPoliciesLRU.Remove(saneName)
+			ps.tokenPoliciesLRU.Remove(ps.cacheKey(namespace.FromContext(ctx), saneName))
 		}
 
+	case PolicyTypeEGP:
+		ps.egpCache.Delete(namespace.FromContext(ctx).ID)
+
 	default:
 		// Can't do anything
 		return
@@ -260,27 +373,38 @@ func (ps *PolicyStore) SetPolicy(ctx context.Context, p *Policy) error {
 func (ps *PolicyStore) setPolicyInternal(ctx context.Context, p *Policy) error {
 	ps.modifyLock.Lock()
 	defer ps.modifyLock.Unlock()
+
+	ns := namespace.FromContext(ctx)
+	key := ps.cacheKey(ns, p.Name)
 	// Create the entry
 	entry, err := logical.StorageEntryJSON(p.Name, &PolicyEntry{
-		Version: 2,
-		Raw:     p.Raw,
-		Type:    p.Type,
+		Version:          2,
+		Raw:              p.Raw,
+		Type:             p.Type,
+		EnforcementLevel: p.EnforcementLevel,
+		EGPPaths:         p.EGPPaths,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create entry: %v", err)
 	}
 	switch p.Type {
 	case PolicyTypeACL:
-		if err := ps.aclView.Put(ctx, entry); err != nil {
+		if err := ps.aclViewForNamespace(ns).Put(ctx, entry); err != nil {
 			return errwrap.Wrapf("failed to persist policy: {{err}}", err)
 		}
-		ps.policyTypeMap.Store(p.Name, PolicyTypeACL)
+		ps.policyTypeMap.Store(key, PolicyTypeACL)
 
 		if ps.tokenPoliciesLRU != nil {
 			// Update the LRU cache
-			ps.tokenPoliciesLRU.Add(p.Name, p)
+			ps.tokenPoliciesLRU.Add(key, p)
 		}
 
+	case PolicyTypeEGP:
+		if err := ps.egpViewForNamespace(ns).Put(ctx, entry); err != nil {
+			return errwrap.Wrapf("failed to persist policy: {{err}}", err)
+		}
+		ps.egpCache.Delete(ns.ID)
+
 	default:
 		return fmt.Errorf("unknown policy type, cannot set")
 	}
@@ -295,15 +419,20 @@ func (ps *PolicyStore) GetPolicy(ctx context.Context, name string, policyType Po
 	// Policies are normalized to lower-case
 	name = ps.sanitizeName(name)
 
+	ns := namespace.FromContext(ctx)
+	key := ps.cacheKey(ns, name)
+
 	var cache *lru.TwoQueueCache
 	var view *BarrierView
 	switch policyType {
 	case PolicyTypeACL:
 		cache = ps.tokenPoliciesLRU
-		view = ps.aclView
+		view = ps.aclViewForNamespace(ns)
+	case PolicyTypeEGP:
+		view = ps.egpViewForNamespace(ns)
 	case PolicyTypeToken:
 		cache = ps.tokenPoliciesLRU
-		val, ok := ps.policyTypeMap.Load(name)
+		val, ok := ps.policyTypeMap.Load(key)
 		if !ok {
 			// Doesn't exist
 			return nil, nil
@@ -311,7 +440,7 @@ func (ps *PolicyStore) GetPolicy(ctx context.Context, name string, policyType Po
 		policyType = val.(PolicyType)
 		switch policyType {
 		case PolicyTypeACL:
-			view = ps.aclView
+			view = ps.aclViewForNamespace(ns)
 		default:
 			return nil, fmt.Errorf("invalid type of policy in type map: %s", policyType)
 		}
@@ -319,13 +448,16 @@ func (ps *PolicyStore) GetPolicy(ctx context.Context, name string, policyType Po
 
 	if cache != nil {
 		// Check for cached policy
-		if raw, ok := cache.Get(name); ok {
+		if raw, ok := cache.Get(key); ok {
 			return raw.(*Policy), nil
 		}
 	}
 
-	// Special case the root policy
+	// Special case the root policy, which only exists in the root namespace
 	if policyType == PolicyTypeACL && name == "root" {
+		if ns.ID != namespace.RootNamespaceID {
+			return nil, nil
+		}
 		p := &Policy{Name: "root"}
 		if cache != nil {
 			cache.Add(p.Name, p)
@@ -338,7 +470,7 @@ func (ps *PolicyStore) GetPolicy(ctx context.Context, name string, policyType Po
 
 	// See if anything has added it since we got the lock
 	if cache != nil {
-		if raw, ok := cache.Get(name); ok {
+		if raw, ok := cache.Get(key); ok {
 			return raw.(*Policy), nil
 		}
 	}
@@ -375,7 +507,13 @@ func (ps *PolicyStore) GetPolicy(ctx context.Context, name string, policyType Po
 		// Reset this in case they set the name in the policy itself
 		policy.Name = name
 
-		ps.policyTypeMap.Store(name, PolicyTypeACL)
+		ps.policyTypeMap.Store(key, PolicyTypeACL)
+
+	case PolicyTypeEGP:
+		policy, err = egpFromEntry(name, policyEntry)
+		if err != nil {
+			return nil, err
+		}
 
 	default:
 		return nil, fmt.Errorf("unknown policy type %q", policyEntry.Type.String())
@@ -383,7 +521,7 @@ func (ps *PolicyStore) GetPolicy(ctx context.Context, name string, policyType Po
 
 	if cache != nil {
 		// Update the LRU cache
-		cache.Add(name, policy)
+		cache.Add(key, policy)
 	}
 
 	return policy, nil
@@ -398,7 +536,9 @@ func (ps *PolicyStore) ListPolicies(ctx context.Context, policyType PolicyType)
 	var err error
 	switch policyType {
 	case PolicyTypeACL:
-		keys, err = logical.CollectKeys(ctx, ps.aclView)
+		keys, err = logical.CollectKeys(ctx, ps.aclViewForNamespace(namespace.FromContext(ctx)))
+	case PolicyTypeEGP:
+		keys, err = logical.CollectKeys(ctx, ps.egpViewForNamespace(namespace.FromContext(ctx)))
 	default:
 		return nil, fmt.Errorf("unknown policy type %s", policyType)
 	}
@@ -433,6 +573,9 @@ func (ps *PolicyStore) DeletePolicy(ctx context.Context, name string, policyType
 	// Policies are normalized to lower-case
 	name = ps.sanitizeName(name)
 
+	ns := namespace.FromContext(ctx)
+	key := ps.cacheKey(ns, name)
+
 	switch policyType {
 	case PolicyTypeACL:
 		if strutil.StrListContains(immutablePolicies, name) {
@@ -442,40 +585,106 @@ func (ps *PolicyStore) DeletePolicy(ctx context.Context, name string, policyType
 			return fmt.Errorf("cannot delete default policy")
 		}
 
-		err := ps.aclView.Delete(ctx, name)
+		err := ps.aclViewForNamespace(ns).Delete(ctx, name)
 		if err != nil {
 			return errwrap.Wrapf("failed to delete policy: {{err}}", err)
 		}
 
 		if ps.tokenPoliciesLRU != nil {
 			// Clear the cache
-			ps.tokenPoliciesLRU.Remove(name)
+			ps.tokenPoliciesLRU.Remove(key)
 		}
 
-		ps.policyTypeMap.Delete(name)
+		ps.policyTypeMap.Delete(key)
 
+	case PolicyTypeEGP:
+		if err := ps.egpViewForNamespace(ns).Delete(ctx, name); err != nil {
+			return errwrap.Wrapf("failed to delete policy: {{err}}", err)
+		}
+		ps.egpCache.Delete(ns.ID)
 	}
 	return nil
 }
 
+// EGPs returns the endpoint governing policies of the namespace carried by
+// the context
+func (ps *PolicyStore) EGPs(ctx context.Context) ([]*Policy, error) {
+	ns := namespace.FromContext(ctx)
+	if raw, ok := ps.egpCache.Load(ns.ID); ok {
+		return raw.([]*Policy), nil
+	}
+
+	// Writes drop the cached policies while holding the write lock, so the
+	// policies loaded here can't be stale
+	ps.modifyLock.RLock()
+	defer ps.modifyLock.RUnlock()
+
+	view := ps.egpViewForNamespace(ns)
+	keys, err := logical.CollectKeys(ctx, view)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to list endpoint governing policies: {{err}}", err)
+	}
+
+	policies := make([]*Policy, 0, len(keys))
+	for _, key := range keys {
+		out, err := view.Get(ctx, key)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to read policy: {{err}}", err)
+		}
+		if out == nil {
+			continue
+		}
+		policyEntry := new(PolicyEntry)
+		if err := out.DecodeJSON(policyEntry); err != nil {
+			return nil, errwrap.Wrapf("failed to parse policy: {{err}}", err)
+		}
+		policy, err := egpFromEntry(key, policyEntry)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+
+	ps.egpCache.Store(ns.ID, policies)
+	return policies, nil
+}
+
 // ACL is used to return an ACL which is built using the
-// named policies.
-func (ps *PolicyStore) ACL(ctx context.Context, names ...string) (*ACL, error) {
+// named policies. Templated policies are resolved with the
+// given entity, which may be nil.
+func (ps *PolicyStore) ACL(ctx context.Context, entity *identity.Entity, names ...string) (*ACL, error) {
 	// Fetch the policies
 	var policies []*Policy
+	var templated bool
 	for _, name := range names {
 		p, err := ps.GetPolicy(ctx, name, PolicyTypeToken)
 		if err != nil {
 			return nil, errwrap.Wrapf("failed to get policy: {{err}}", err)
 		}
+		if p != nil && p.Templated {
+			templated = true
+		}
 		policies = append(policies, p)
 	}
 
+	// Groups are only needed to resolve templated policies
+	var groups []*identity.Group
+	if templated && entity != nil {
+		var err error
+		groups, err = ps.core.entityGroups(entity.ID)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to fetch groups of the entity: {{err}}", err)
+		}
+	}
+
 	// Construct the ACL
-	acl, err := NewACL(policies)
+	acl, err := newTemplatedACL(policies, entity, groups)
 	if err != nil {
 		return nil, errwrap.Wrapf("failed to construct ACL: {{err}}", err)
 	}
+
+	// The ACL only grants access within the namespace of its policies
+	acl.namespace = namespace.FromContext(ctx)
 	return acl, nil
 }
 
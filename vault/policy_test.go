@@ -330,3 +330,116 @@ path "/" {
 		t.Errorf("bad error: %s", err)
 	}
 }
+
+func TestPolicy_ParseControlGroup(t *testing.T) {
+	p, err := ParseACLPolicy(strings.TrimSpace(`
+path "secret/breakglass" {
+	capabilities = ["read"]
+	control_group = {
+		ttl = "4h"
+		factor "managers" {
+			identity {
+				group_names = ["managers", "directors"]
+				approvals = 2
+			}
+		}
+		factor "security" {
+			identity {
+				group_names = ["security"]
+			}
+		}
+	}
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &ControlGroup{
+		TTL: 4 * time.Hour,
+		Factors: []*ControlGroupFactor{
+			{
+				Name: "managers",
+				Identity: &IdentityFactor{
+					GroupNames:        []string{"managers", "directors"},
+					ApprovalsRequired: 2,
+				},
+			},
+			{
+				Name: "security",
+				Identity: &IdentityFactor{
+					GroupNames:        []string{"security"},
+					ApprovalsRequired: 1,
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(p.Paths[0].Permissions.ControlGroup, expected) {
+		t.Fatalf("bad control group: %#v", p.Paths[0].Permissions.ControlGroup)
+	}
+}
+
+func TestPolicy_ParseBadControlGroup(t *testing.T) {
+	cases := map[string]string{
+		"no factors": `
+path "secret/foo" {
+	capabilities = ["read"]
+	control_group = {
+		ttl = "1h"
+	}
+}`,
+		"no groups": `
+path "secret/foo" {
+	capabilities = ["read"]
+	control_group = {
+		factor "managers" {
+			identity {
+				approvals = 1
+			}
+		}
+	}
+}`,
+		"bad key": `
+path "secret/foo" {
+	capabilities = ["read"]
+	control_group = {
+		factor "managers" {
+			identity {
+				group_names = ["managers"]
+				banana = 1
+			}
+		}
+	}
+}`,
+	}
+
+	for name, rules := range cases {
+		if _, err := ParseACLPolicy(strings.TrimSpace(rules)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestPolicy_ParseTemplated(t *testing.T) {
+	p, err := ParseACLPolicy(strings.TrimSpace(`
+path "secret/users/{{identity.entity.name}}/*" {
+	capabilities = ["read"]
+}
+path "secret/shared" {
+	capabilities = ["read"]
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Templated {
+		t.Fatal("expected the policy to be templated")
+	}
+	if len(p.Paths) != 1 || p.Paths[0].Prefix != "secret/shared" {
+		t.Fatalf("expected only the untemplated path, got %#v", p.Paths)
+	}
+
+	if _, err := ParseACLPolicy(`path "secret/{{identity.entity.banana}}" { capabilities = ["read"] }`); err == nil {
+		t.Fatal("expected an error for an invalid template directive")
+	}
+}
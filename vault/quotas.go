@@ -0,0 +1,560 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/errwrap"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	// quotasSubPath is the path of the quotas in the system view
+	quotasSubPath = "quotas/"
+
+	// Rate limit quotas limit the rate of the requests made to paths
+	quotaTypeRateLimit = "rate-limit"
+
+	// Lease count quotas limit the number of leases outstanding under paths
+	quotaTypeLeaseCount = "lease-count"
+)
+
+// quotaExemptPaths are never rate limited, so that quotas can always be
+// changed
+var quotaExemptPaths = []string{
+	"sys/quotas/",
+}
+
+// rateLimitQuota allows Rate requests per second on average to the paths
+// under Path, with bursts of up to Burst requests. Requests take tokens from
+// a bucket which holds up to Burst tokens and is refilled at Rate tokens per
+// second.
+type rateLimitQuota struct {
+	Name  string  `json:"name"`
+	Path  string  `json:"path"`
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+
+	lock   sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket of the quota. If the bucket is empty,
+// it returns how long it takes for the next token to be available.
+func (q *rateLimitQuota) allow(now time.Time) (bool, time.Duration) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.last.IsZero() {
+		q.tokens = float64(q.Burst)
+	} else if elapsed := now.Sub(q.last); elapsed > 0 {
+		q.tokens = math.Min(float64(q.Burst), q.tokens+elapsed.Seconds()*q.Rate)
+	}
+	q.last = now
+
+	if q.tokens >= 1 {
+		q.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - q.tokens) / q.Rate * float64(time.Second))
+}
+
+// leaseCountQuota allows at most MaxLeases leases to be outstanding under
+// Path
+type leaseCountQuota struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	MaxLeases int    `json:"max_leases"`
+
+	// lock guards the counts of the quota
+	lock sync.Mutex
+
+	// leases is the number of leases under Path pending expiration
+	leases int
+
+	// reserved is the number of leases under Path being registered, which
+	// are not pending expiration yet
+	reserved int
+}
+
+// reserve reserves room for a lease being registered, unless the quota is
+// exhausted
+func (q *leaseCountQuota) reserve() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.leases+q.reserved >= q.MaxLeases {
+		return false
+	}
+	q.reserved++
+	return true
+}
+
+// release gives back the room reserved for a lease
+func (q *leaseCountQuota) release() {
+	q.lock.Lock()
+	q.reserved--
+	q.lock.Unlock()
+}
+
+// count returns the number of leases under Path pending expiration
+func (q *leaseCountQuota) count() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.leases
+}
+
+// quotaManager holds the quotas applied to the requests handled by this node.
+// Quotas without a path apply to every request, and only the most specific
+// quota of each type governing a path is applied.
+type quotaManager struct {
+	view   *BarrierView
+	logger log.Logger
+
+	lock        sync.RWMutex
+	rateLimits  map[string]*rateLimitQuota
+	leaseCounts map[string]*leaseCountQuota
+
+	// expiration holds the leases counted by the lease count quotas. It is
+	// set once the expiration manager is set up.
+	expiration *ExpirationManager
+}
+
+// setupQuotas loads the quotas from storage. It must be called before the
+// expiration manager is set up.
+func (c *Core) setupQuotas(ctx context.Context) error {
+	qm := &quotaManager{
+		view:        c.systemBarrierView.SubView(quotasSubPath),
+		logger:      c.logger,
+		rateLimits:  make(map[string]*rateLimitQuota),
+		leaseCounts: make(map[string]*leaseCountQuota),
+	}
+
+	for _, quotaType := range []string{quotaTypeRateLimit, quotaTypeLeaseCount} {
+		names, err := qm.view.List(ctx, quotaType+"/")
+		if err != nil {
+			return errwrap.Wrapf("failed to list quotas: {{err}}", err)
+		}
+		for _, name := range names {
+			if err := qm.load(ctx, quotaType, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.quotaManager = qm
+	return nil
+}
+
+func (c *Core) teardownQuotas() error {
+	c.quotaManager = nil
+	return nil
+}
+
+// load reads a quota from storage, replacing the one held in memory
+func (qm *quotaManager) load(ctx context.Context, quotaType, name string) error {
+	entry, err := qm.view.Get(ctx, quotaType+"/"+name)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("failed to read quota %q: {{err}}", name), err)
+	}
+
+	// The pending leases are held still while a lease count quota is counted
+	// and replaced, so that no lease is missed or counted twice
+	if quotaType == quotaTypeLeaseCount && qm.expiration != nil {
+		qm.expiration.pendingLock.RLock()
+		defer qm.expiration.pendingLock.RUnlock()
+	}
+
+	qm.lock.Lock()
+	defer qm.lock.Unlock()
+
+	switch quotaType {
+	case quotaTypeRateLimit:
+		if entry == nil {
+			delete(qm.rateLimits, name)
+			return nil
+		}
+		quota := new(rateLimitQuota)
+		if err := entry.DecodeJSON(quota); err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to decode quota %q: {{err}}", name), err)
+		}
+		qm.rateLimits[name] = quota
+	case quotaTypeLeaseCount:
+		if entry == nil {
+			delete(qm.leaseCounts, name)
+			return nil
+		}
+		quota := new(leaseCountQuota)
+		if err := entry.DecodeJSON(quota); err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to decode quota %q: {{err}}", name), err)
+		}
+		if qm.expiration != nil {
+			quota.leases = qm.expiration.countPending(quota.Path)
+		}
+		qm.leaseCounts[name] = quota
+	}
+	return nil
+}
+
+// invalidate reloads the quota stored under the given key of the quotas view
+func (qm *quotaManager) invalidate(ctx context.Context, key string) {
+	if qm == nil {
+		return
+	}
+	i := strings.Index(key, "/")
+	if i < 0 {
+		return
+	}
+	if err := qm.load(ctx, key[:i], key[i+1:]); err != nil {
+		qm.logger.Error("failed to reload quota", "key", key, "error", err)
+	}
+}
+
+func (qm *quotaManager) set(ctx context.Context, quotaType, name string, quota interface{}) error {
+	entry, err := logical.StorageEntryJSON(quotaType+"/"+name, quota)
+	if err != nil {
+		return err
+	}
+	if err := qm.view.Put(ctx, entry); err != nil {
+		return err
+	}
+	return qm.load(ctx, quotaType, name)
+}
+
+func (qm *quotaManager) delete(ctx context.Context, quotaType, name string) error {
+	if err := qm.view.Delete(ctx, quotaType+"/"+name); err != nil {
+		return err
+	}
+	return qm.load(ctx, quotaType, name)
+}
+
+func (qm *quotaManager) list(quotaType string) []string {
+	qm.lock.RLock()
+	defer qm.lock.RUnlock()
+
+	var names []string
+	switch quotaType {
+	case quotaTypeRateLimit:
+		for name := range qm.rateLimits {
+			names = append(names, name)
+		}
+	case quotaTypeLeaseCount:
+		for name := range qm.leaseCounts {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// moreSpecific returns whether a quota with the given name and path takes
+// precedence over the other one
+func moreSpecific(name, path, otherName, otherPath string) bool {
+	if len(path) != len(otherPath) {
+		return len(path) > len(otherPath)
+	}
+	return name < otherName
+}
+
+// rateLimitQuotaFor returns the rate limit quota governing the path, if any
+func (qm *quotaManager) rateLimitQuotaFor(path string) *rateLimitQuota {
+	if qm == nil {
+		return nil
+	}
+	qm.lock.RLock()
+	defer qm.lock.RUnlock()
+
+	var ret *rateLimitQuota
+	for _, quota := range qm.rateLimits {
+		if strings.HasPrefix(path, quota.Path) && (ret == nil || moreSpecific(quota.Name, quota.Path, ret.Name, ret.Path)) {
+			ret = quota
+		}
+	}
+	return ret
+}
+
+// leaseCountQuotaFor returns the lease count quota governing the path, if
+// any
+func (qm *quotaManager) leaseCountQuotaFor(path string) *leaseCountQuota {
+	if qm == nil {
+		return nil
+	}
+	qm.lock.RLock()
+	defer qm.lock.RUnlock()
+
+	var ret *leaseCountQuota
+	for _, quota := range qm.leaseCounts {
+		if strings.HasPrefix(path, quota.Path) && (ret == nil || moreSpecific(quota.Name, quota.Path, ret.Name, ret.Path)) {
+			ret = quota
+		}
+	}
+	return ret
+}
+
+// leaseCreated counts a lease pending expiration in the lease count quotas
+// whose paths contain it
+func (qm *quotaManager) leaseCreated(leaseID string) {
+	qm.countLease(leaseID, 1)
+}
+
+// leaseDeleted stops counting a lease which is no longer pending expiration
+func (qm *quotaManager) leaseDeleted(leaseID string) {
+	qm.countLease(leaseID, -1)
+}
+
+func (qm *quotaManager) countLease(leaseID string, delta int) {
+	if qm == nil {
+		return
+	}
+	qm.lock.RLock()
+	defer qm.lock.RUnlock()
+
+	for _, quota := range qm.leaseCounts {
+		if strings.HasPrefix(leaseID, quota.Path) {
+			quota.lock.Lock()
+			quota.leases += delta
+			quota.lock.Unlock()
+		}
+	}
+}
+
+// applyRateLimitQuota rejects the request if the rate limit quota governing
+// its path is exhausted
+func (c *Core) applyRateLimitQuota(req *logical.Request) error {
+	for _, prefix := range quotaExemptPaths {
+		if strings.HasPrefix(req.Path, prefix) {
+			return nil
+		}
+	}
+
+	quota := c.quotaManager.rateLimitQuotaFor(req.Path)
+	if quota == nil {
+		return nil
+	}
+	allowed, retryAfter := quota.allow(time.Now())
+	if allowed {
+		return nil
+	}
+
+	metrics.IncrCounter([]string{"quota", "rate_limit", "violation"}, 1)
+	metrics.IncrCounter([]string{"quota", "rate_limit", quota.Name, "violation"}, 1)
+	return &logical.RequestQuotaError{
+		Msg:        fmt.Sprintf("request rate limit quota %q exceeded", quota.Name),
+		RetryAfter: retryAfter,
+	}
+}
+
+// quotaPath validates the path of a quota, which must be empty or within a
+// mount. The paths of mounts are given a trailing slash.
+func (c *Core) quotaPath(path string) (string, error) {
+	path = strings.TrimPrefix(path, "/")
+	if path == "" {
+		return "", nil
+	}
+	if !strings.HasSuffix(path, "/") && c.router.MatchingMount(path+"/") == path+"/" {
+		path += "/"
+	}
+	if c.router.MatchingMount(path) == "" {
+		return "", fmt.Errorf("no mount found for path %q", path)
+	}
+	return path, nil
+}
+
+// quotaPaths returns the paths used to manage quotas
+func (b *SystemBackend) quotaPaths() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "quotas/rate-limit/?$",
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: b.handleQuotasList(quotaTypeRateLimit),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["quotas-rate-limit-list"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["quotas-rate-limit-list"][1]),
+		},
+
+		{
+			Pattern: "quotas/rate-limit/(?P<name>.+)",
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the quota.",
+				},
+				"path": {
+					Type:        framework.TypeString,
+					Description: strings.TrimSpace(sysHelp["quotas-path"][0]),
+				},
+				"rate": {
+					Type:        framework.TypeFloat,
+					Description: "Number of requests allowed per second on average.",
+				},
+				"burst": {
+					Type:        framework.TypeInt,
+					Description: "Number of requests allowed in a burst. Defaults to the rate, rounded up.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   b.handleRateLimitQuotaRead,
+				logical.UpdateOperation: b.handleRateLimitQuotaWrite,
+				logical.DeleteOperation: b.handleQuotasDelete(quotaTypeRateLimit),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["quotas-rate-limit"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["quotas-rate-limit"][1]),
+		},
+
+		{
+			Pattern: "quotas/lease-count/?$",
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: b.handleQuotasList(quotaTypeLeaseCount),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["quotas-lease-count-list"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["quotas-lease-count-list"][1]),
+		},
+
+		{
+			Pattern: "quotas/lease-count/(?P<name>.+)",
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the quota.",
+				},
+				"path": {
+					Type:        framework.TypeString,
+					Description: strings.TrimSpace(sysHelp["quotas-path"][0]),
+				},
+				"max_leases": {
+					Type:        framework.TypeInt,
+					Description: "Maximum number of leases outstanding under the path.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   b.handleLeaseCountQuotaRead,
+				logical.UpdateOperation: b.handleLeaseCountQuotaWrite,
+				logical.DeleteOperation: b.handleQuotasDelete(quotaTypeLeaseCount),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["quotas-lease-count"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["quotas-lease-count"][1]),
+		},
+	}
+}
+
+func (b *SystemBackend) handleQuotasList(quotaType string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		return logical.ListResponse(b.Core.quotaManager.list(quotaType)), nil
+	}
+}
+
+func (b *SystemBackend) handleQuotasDelete(quotaType string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		if err := b.Core.quotaManager.delete(ctx, quotaType, data.Get("name").(string)); err != nil {
+			return handleError(err)
+		}
+		return nil, nil
+	}
+}
+
+func (b *SystemBackend) handleRateLimitQuotaRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	qm := b.Core.quotaManager
+	qm.lock.RLock()
+	quota, ok := qm.rateLimits[data.Get("name").(string)]
+	qm.lock.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name":  quota.Name,
+			"path":  quota.Path,
+			"rate":  quota.Rate,
+			"burst": quota.Burst,
+		},
+	}, nil
+}
+
+func (b *SystemBackend) handleRateLimitQuotaWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path, err := b.Core.quotaPath(data.Get("path").(string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	rate := data.Get("rate").(float64)
+	if rate <= 0 {
+		return logical.ErrorResponse("'rate' must be positive"), logical.ErrInvalidRequest
+	}
+	burst := data.Get("burst").(int)
+	switch {
+	case burst < 0:
+		return logical.ErrorResponse("'burst' cannot be negative"), logical.ErrInvalidRequest
+	case burst == 0:
+		burst = int(math.Ceil(rate))
+	}
+
+	quota := &rateLimitQuota{
+		Name:  data.Get("name").(string),
+		Path:  path,
+		Rate:  rate,
+		Burst: burst,
+	}
+	if err := b.Core.quotaManager.set(ctx, quotaTypeRateLimit, quota.Name, quota); err != nil {
+		return handleError(err)
+	}
+	return nil, nil
+}
+
+func (b *SystemBackend) handleLeaseCountQuotaRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	qm := b.Core.quotaManager
+	qm.lock.RLock()
+	quota, ok := qm.leaseCounts[data.Get("name").(string)]
+	qm.lock.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name":        quota.Name,
+			"path":        quota.Path,
+			"max_leases":  quota.MaxLeases,
+			"lease_count": quota.count(),
+		},
+	}, nil
+}
+
+func (b *SystemBackend) handleLeaseCountQuotaWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path, err := b.Core.quotaPath(data.Get("path").(string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	maxLeases := data.Get("max_leases").(int)
+	if maxLeases <= 0 {
+		return logical.ErrorResponse("'max_leases' must be positive"), logical.ErrInvalidRequest
+	}
+
+	quota := &leaseCountQuota{
+		Name:      data.Get("name").(string),
+		Path:      path,
+		MaxLeases: maxLeases,
+	}
+	if err := b.Core.quotaManager.set(ctx, quotaTypeLeaseCount, quota.Name, quota); err != nil {
+		return handleError(err)
+	}
+	return nil, nil
+}
@@ -0,0 +1,260 @@
+package vault
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestRateLimitQuota_Allow(t *testing.T) {
+	q := &rateLimitQuota{
+		Rate:  2,
+		Burst: 2,
+	}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := q.allow(now); !allowed {
+			t.Fatalf("expected request %d of the burst to be allowed", i)
+		}
+	}
+	allowed, retryAfter := q.allow(now)
+	if allowed || retryAfter != 500*time.Millisecond {
+		t.Fatalf("bad: allowed: %t retry after: %s", allowed, retryAfter)
+	}
+
+	// The bucket is refilled at the rate of the quota
+	allowed, retryAfter = q.allow(now.Add(250 * time.Millisecond))
+	if allowed || retryAfter != 250*time.Millisecond {
+		t.Fatalf("bad: allowed: %t retry after: %s", allowed, retryAfter)
+	}
+	if allowed, _ := q.allow(now.Add(500 * time.Millisecond)); !allowed {
+		t.Fatal("expected the request to be allowed")
+	}
+
+	// The bucket never holds more than the burst
+	later := now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if allowed, _ := q.allow(later); !allowed {
+			t.Fatalf("expected request %d of the burst to be allowed", i)
+		}
+	}
+	if allowed, _ := q.allow(later); allowed {
+		t.Fatal("expected the request to be rejected")
+	}
+}
+
+func TestQuotas_RateLimit(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	doRequest := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		op := logical.Operation(logical.ReadOperation)
+		if data != nil {
+			op = logical.UpdateOperation
+		}
+		return c.HandleRequest(&logical.Request{
+			Operation:   op,
+			Path:        path,
+			ClientToken: root,
+			Data:        data,
+		})
+	}
+	mustRequest := func(path string, data map[string]interface{}) *logical.Response {
+		resp, err := doRequest(path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: resp: %#v err: %v", path, resp, err)
+		}
+		return resp
+	}
+	isRateLimited := func(err error) bool {
+		quotaErr, ok := err.(*logical.RequestQuotaError)
+		return ok && quotaErr.RetryAfter > 0
+	}
+
+	mustRequest("secret/foo", map[string]interface{}{"value": "bar"})
+
+	// Quota paths must be within a mount
+	resp, err := doRequest("sys/quotas/rate-limit/bad", map[string]interface{}{
+		"path": "unknown/",
+		"rate": 1,
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got resp: %#v err: %v", resp, err)
+	}
+
+	mustRequest("sys/quotas/rate-limit/secret", map[string]interface{}{
+		"path":  "secret",
+		"rate":  0.01,
+		"burst": 2,
+	})
+	resp = mustRequest("sys/quotas/rate-limit/secret", nil)
+	if resp.Data["path"] != "secret/" || resp.Data["rate"] != 0.01 || resp.Data["burst"] != 2 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	for i := 0; i < 2; i++ {
+		mustRequest("secret/foo", nil)
+	}
+	if _, err := doRequest("secret/foo", nil); !isRateLimited(err) {
+		t.Fatalf("expected the request to be rate limited, got %v", err)
+	}
+
+	// Other paths are not governed by the quota, and quotas can still be
+	// managed when a quota governs every path
+	mustRequest("cubbyhole/foo", map[string]interface{}{"value": "bar"})
+	mustRequest("sys/quotas/rate-limit/global", map[string]interface{}{
+		"rate":  0.01,
+		"burst": 1,
+	})
+	mustRequest("cubbyhole/foo", nil)
+	if _, err := doRequest("cubbyhole/foo", nil); !isRateLimited(err) {
+		t.Fatalf("expected the request to be rate limited, got %v", err)
+	}
+	resp, err = c.HandleRequest(&logical.Request{
+		Operation:   logical.ListOperation,
+		Path:        "sys/quotas/rate-limit/",
+		ClientToken: root,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := resp.Data["keys"].([]string); len(keys) != 2 || keys[0] != "global" || keys[1] != "secret" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	mustRequest("sys/quotas/rate-limit/global", nil)
+
+	// Only the most specific quota applies, so requests under secret/ are not
+	// limited by the exhausted global quota
+	mustRequest("sys/quotas/rate-limit/secret", map[string]interface{}{
+		"path": "secret/",
+		"rate": 1000,
+	})
+	for i := 0; i < 5; i++ {
+		mustRequest("secret/foo", nil)
+	}
+
+	if _, err := c.HandleRequest(&logical.Request{
+		Operation:   logical.DeleteOperation,
+		Path:        "sys/quotas/rate-limit/global",
+		ClientToken: root,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	mustRequest("cubbyhole/foo", nil)
+}
+
+func TestQuotas_LeaseCount(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	doRequest := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return c.HandleRequest(&logical.Request{
+			Operation:   op,
+			Path:        path,
+			ClientToken: root,
+			Data:        data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := doRequest(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: resp: %#v err: %v", path, resp, err)
+		}
+		return resp
+	}
+
+	mustRequest(logical.UpdateOperation, "secret/creds/foo", map[string]interface{}{
+		"value": "bar",
+		"lease": "1h",
+	})
+	mustRequest(logical.UpdateOperation, "secret/other", map[string]interface{}{
+		"value": "bar",
+		"lease": "1h",
+	})
+	mustRequest(logical.UpdateOperation, "sys/quotas/lease-count/creds", map[string]interface{}{
+		"path":       "secret/creds/",
+		"max_leases": 2,
+	})
+
+	var leaseIDs []string
+	for i := 0; i < 2; i++ {
+		resp := mustRequest(logical.ReadOperation, "secret/creds/foo", nil)
+		leaseIDs = append(leaseIDs, resp.Secret.LeaseID)
+	}
+	resp, err := doRequest(logical.ReadOperation, "secret/creds/foo", nil)
+	if !errwrap.ContainsType(err, new(logical.RequestQuotaError)) {
+		t.Fatalf("expected the lease count quota to be exceeded, got %v", err)
+	}
+	if resp == nil || !strings.Contains(resp.Error().Error(), `"creds"`) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Leases outside of the path of the quota are not limited
+	mustRequest(logical.ReadOperation, "secret/other", nil)
+
+	// Requests under the path which create no lease, such as writes, lists
+	// and deletes, still succeed while the quota is full
+	mustRequest(logical.UpdateOperation, "secret/creds/bar", map[string]interface{}{
+		"value": "bar",
+	})
+	mustRequest(logical.ListOperation, "secret/creds/", nil)
+	mustRequest(logical.DeleteOperation, "secret/creds/bar", nil)
+
+	resp = mustRequest(logical.ReadOperation, "sys/quotas/lease-count/creds", nil)
+	if resp.Data["path"] != "secret/creds/" || resp.Data["max_leases"] != 2 || resp.Data["lease_count"] != 2 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Revoking a lease makes room for another one
+	mustRequest(logical.UpdateOperation, "sys/leases/revoke/"+leaseIDs[0], map[string]interface{}{})
+	mustRequest(logical.ReadOperation, "secret/creds/foo", nil)
+
+	mustRequest(logical.DeleteOperation, "sys/quotas/lease-count/creds", nil)
+	mustRequest(logical.ReadOperation, "secret/creds/foo", nil)
+	resp = mustRequest(logical.ListOperation, "sys/quotas/lease-count/", nil)
+	if resp != nil && resp.Data["keys"] != nil {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Quotas created while leases are outstanding count them, and the secrets
+	// of rejected requests are revoked
+	noop := &NoopBackend{
+		Response: &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{
+					TTL: time.Hour,
+				},
+			},
+		},
+	}
+	c.logicalBackends["noop"] = func(context.Context, *logical.BackendConfig) (logical.Backend, error) {
+		return noop, nil
+	}
+	mustRequest(logical.UpdateOperation, "sys/mounts/noop", map[string]interface{}{
+		"type": "noop",
+	})
+	mustRequest(logical.ReadOperation, "noop/creds", nil)
+	mustRequest(logical.UpdateOperation, "sys/quotas/lease-count/noop", map[string]interface{}{
+		"path":       "noop/",
+		"max_leases": 1,
+	})
+	resp = mustRequest(logical.ReadOperation, "sys/quotas/lease-count/noop", nil)
+	if resp.Data["lease_count"] != 1 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if _, err := doRequest(logical.ReadOperation, "noop/creds", nil); !errwrap.ContainsType(err, new(logical.RequestQuotaError)) {
+		t.Fatalf("expected the lease count quota to be exceeded, got %v", err)
+	}
+	noop.Lock()
+	if len(noop.Requests) != 3 || noop.Requests[2].Operation != logical.RevokeOperation {
+		t.Fatalf("expected the secret of the rejected request to be revoked, got %d requests", len(noop.Requests))
+	}
+	noop.Unlock()
+	resp = mustRequest(logical.ReadOperation, "sys/quotas/lease-count/noop", nil)
+	if resp.Data["lease_count"] != 1 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
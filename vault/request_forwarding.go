@@ -284,6 +284,11 @@ func (c *Core) refreshRequestForwardingConnection(ctx context.Context, clusterAd
 	}
 	c.rpcForwardingClient.startHeartbeat()
 
+	// Serve reads locally, following the storage WAL of the active node
+	if c.perfStandbyEnabled {
+		go c.runPerfStandby(dctx, c.rpcForwardingClient)
+	}
+
 	return nil
 }
 
@@ -429,6 +434,10 @@ func (s *forwardedRequestRPCServer) Echo(ctx context.Context, in *EchoRequest) (
 	}, nil
 }
 
+func (s *forwardedRequestRPCServer) PerformanceStandbyWALStream(in *PerfStandbyWALStreamRequest, stream RequestForwarding_PerformanceStandbyWALStreamServer) error {
+	return s.core.streamStorageWAL(in, stream)
+}
+
 type forwardingClient struct {
 	RequestForwardingClient
 
@@ -10,6 +10,9 @@ It is generated from these files:
 It has these top-level messages:
 	EchoRequest
 	EchoReply
+	PerfStandbyWALStreamRequest
+	PerfStandbyWALEntry
+	PerfStandbyWALBatch
 */
 package vault
 
@@ -102,9 +105,106 @@ func (m *EchoReply) GetReplicationState() uint32 {
 	return 0
 }
 
+type PerfStandbyWALStreamRequest struct {
+	// Epoch identifies the WAL the standby applied entries from, and index
+	// is the index of the last entry it applied
+	Epoch string `protobuf:"bytes,1,opt,name=epoch" json:"epoch,omitempty"`
+	Index uint64 `protobuf:"varint,2,opt,name=index" json:"index,omitempty"`
+}
+
+func (m *PerfStandbyWALStreamRequest) Reset()                    { *m = PerfStandbyWALStreamRequest{} }
+func (m *PerfStandbyWALStreamRequest) String() string            { return proto.CompactTextString(m) }
+func (*PerfStandbyWALStreamRequest) ProtoMessage()               {}
+func (*PerfStandbyWALStreamRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *PerfStandbyWALStreamRequest) GetEpoch() string {
+	if m != nil {
+		return m.Epoch
+	}
+	return ""
+}
+
+func (m *PerfStandbyWALStreamRequest) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+type PerfStandbyWALEntry struct {
+	Index uint64 `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	// Keys are the storage keys written or deleted
+	Keys []string `protobuf:"bytes,2,rep,name=keys" json:"keys,omitempty"`
+}
+
+func (m *PerfStandbyWALEntry) Reset()                    { *m = PerfStandbyWALEntry{} }
+func (m *PerfStandbyWALEntry) String() string            { return proto.CompactTextString(m) }
+func (*PerfStandbyWALEntry) ProtoMessage()               {}
+func (*PerfStandbyWALEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *PerfStandbyWALEntry) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *PerfStandbyWALEntry) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+type PerfStandbyWALBatch struct {
+	Epoch string `protobuf:"bytes,1,opt,name=epoch" json:"epoch,omitempty"`
+	// Reset is set when the standby cannot catch up from the entries the
+	// active node holds, in which case it must drop all of its cached state
+	// and consider itself at index
+	Reset_  bool                   `protobuf:"varint,2,opt,name=reset" json:"reset,omitempty"`
+	Index   uint64                 `protobuf:"varint,3,opt,name=index" json:"index,omitempty"`
+	Entries []*PerfStandbyWALEntry `protobuf:"bytes,4,rep,name=entries" json:"entries,omitempty"`
+}
+
+func (m *PerfStandbyWALBatch) Reset()                    { *m = PerfStandbyWALBatch{} }
+func (m *PerfStandbyWALBatch) String() string            { return proto.CompactTextString(m) }
+func (*PerfStandbyWALBatch) ProtoMessage()               {}
+func (*PerfStandbyWALBatch) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *PerfStandbyWALBatch) GetEpoch() string {
+	if m != nil {
+		return m.Epoch
+	}
+	return ""
+}
+
+func (m *PerfStandbyWALBatch) GetReset_() bool {
+	if m != nil {
+		return m.Reset_
+	}
+	return false
+}
+
+func (m *PerfStandbyWALBatch) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *PerfStandbyWALBatch) GetEntries() []*PerfStandbyWALEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*EchoRequest)(nil), "vault.EchoRequest")
 	proto.RegisterType((*EchoReply)(nil), "vault.EchoReply")
+	proto.RegisterType((*PerfStandbyWALStreamRequest)(nil), "vault.PerfStandbyWALStreamRequest")
+	proto.RegisterType((*PerfStandbyWALEntry)(nil), "vault.PerfStandbyWALEntry")
+	proto.RegisterType((*PerfStandbyWALBatch)(nil), "vault.PerfStandbyWALBatch")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -120,6 +220,7 @@ const _ = grpc.SupportPackageIsVersion4
 type RequestForwardingClient interface {
 	ForwardRequest(ctx context.Context, in *forwarding.Request, opts ...grpc.CallOption) (*forwarding.Response, error)
 	Echo(ctx context.Context, in *EchoRequest, opts ...grpc.CallOption) (*EchoReply, error)
+	PerformanceStandbyWALStream(ctx context.Context, in *PerfStandbyWALStreamRequest, opts ...grpc.CallOption) (RequestForwarding_PerformanceStandbyWALStreamClient, error)
 }
 
 type requestForwardingClient struct {
@@ -148,11 +249,44 @@ func (c *requestForwardingClient) Echo(ctx context.Context, in *EchoRequest, opt
 	return out, nil
 }
 
+func (c *requestForwardingClient) PerformanceStandbyWALStream(ctx context.Context, in *PerfStandbyWALStreamRequest, opts ...grpc.CallOption) (RequestForwarding_PerformanceStandbyWALStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_RequestForwarding_serviceDesc.Streams[0], c.cc, "/vault.RequestForwarding/PerformanceStandbyWALStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &requestForwardingPerformanceStandbyWALStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RequestForwarding_PerformanceStandbyWALStreamClient interface {
+	Recv() (*PerfStandbyWALBatch, error)
+	grpc.ClientStream
+}
+
+type requestForwardingPerformanceStandbyWALStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *requestForwardingPerformanceStandbyWALStreamClient) Recv() (*PerfStandbyWALBatch, error) {
+	m := new(PerfStandbyWALBatch)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Server API for RequestForwarding service
 
 type RequestForwardingServer interface {
 	ForwardRequest(context.Context, *forwarding.Request) (*forwarding.Response, error)
 	Echo(context.Context, *EchoRequest) (*EchoReply, error)
+	PerformanceStandbyWALStream(*PerfStandbyWALStreamRequest, RequestForwarding_PerformanceStandbyWALStreamServer) error
 }
 
 func RegisterRequestForwardingServer(s *grpc.Server, srv RequestForwardingServer) {
@@ -195,6 +329,27 @@ func _RequestForwarding_Echo_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RequestForwarding_PerformanceStandbyWALStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PerfStandbyWALStreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RequestForwardingServer).PerformanceStandbyWALStream(m, &requestForwardingPerformanceStandbyWALStreamServer{stream})
+}
+
+type RequestForwarding_PerformanceStandbyWALStreamServer interface {
+	Send(*PerfStandbyWALBatch) error
+	grpc.ServerStream
+}
+
+type requestForwardingPerformanceStandbyWALStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *requestForwardingPerformanceStandbyWALStreamServer) Send(m *PerfStandbyWALBatch) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _RequestForwarding_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "vault.RequestForwarding",
 	HandlerType: (*RequestForwardingServer)(nil),
@@ -208,30 +363,45 @@ var _RequestForwarding_serviceDesc = grpc.ServiceDesc{
 			Handler:    _RequestForwarding_Echo_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PerformanceStandbyWALStream",
+			Handler:       _RequestForwarding_PerformanceStandbyWALStream_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "request_forwarding_service.proto",
 }
 
 func init() { proto.RegisterFile("request_forwarding_service.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 287 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x90, 0xbf, 0x4e, 0xc3, 0x30,
-	0x10, 0xc6, 0x9b, 0x96, 0x3f, 0xaa, 0xdb, 0xa2, 0xd6, 0x30, 0x44, 0x99, 0x42, 0x58, 0x22, 0x21,
-	0x39, 0x12, 0x2c, 0x2c, 0x0c, 0x0c, 0xf0, 0x00, 0xe1, 0x01, 0x22, 0xd7, 0x39, 0x12, 0x4b, 0x6e,
-	0x6c, 0x7c, 0x4e, 0xab, 0xac, 0x3c, 0x39, 0x6a, 0x92, 0xd2, 0x54, 0x95, 0x18, 0xef, 0x77, 0xa7,
-	0xef, 0xd3, 0xfd, 0x48, 0x68, 0xe1, 0xbb, 0x06, 0x74, 0xd9, 0x97, 0xb6, 0x3b, 0x6e, 0x73, 0x59,
-	0x15, 0x19, 0x82, 0xdd, 0x4a, 0x01, 0xcc, 0x58, 0xed, 0x34, 0xbd, 0xdc, 0xf2, 0x5a, 0xb9, 0xe0,
-	0xa5, 0x90, 0xae, 0xac, 0xd7, 0x4c, 0xe8, 0x4d, 0x52, 0x72, 0x2c, 0xa5, 0xd0, 0xd6, 0x24, 0xed,
-	0x2e, 0x29, 0x41, 0x19, 0xb0, 0xc9, 0x31, 0x22, 0x71, 0x8d, 0x01, 0xec, 0x02, 0x22, 0x4d, 0x66,
-	0xef, 0xa2, 0xd4, 0x69, 0x57, 0x44, 0x7d, 0x72, 0xbd, 0x01, 0x44, 0x5e, 0x80, 0xef, 0x85, 0x5e,
-	0x3c, 0x4d, 0x0f, 0x23, 0xbd, 0x27, 0x73, 0xa1, 0x6a, 0x74, 0x60, 0x33, 0x9e, 0xe7, 0xd6, 0x1f,
-	0xb7, 0xeb, 0x59, 0xcf, 0xde, 0xf2, 0xdc, 0xd2, 0x07, 0xb2, 0x18, 0x9e, 0xa0, 0x3f, 0x09, 0x27,
-	0xf1, 0x34, 0x9d, 0x0f, 0x6e, 0x30, 0xda, 0x91, 0x69, 0x57, 0x68, 0x54, 0xf3, 0x4f, 0xdd, 0x59,
-	0xd6, 0xf8, 0x3c, 0x8b, 0x3e, 0x92, 0x95, 0x05, 0xa3, 0xa4, 0xe0, 0x4e, 0xea, 0x2a, 0x43, 0xc7,
-	0x1d, 0xf8, 0x93, 0xd0, 0x8b, 0x17, 0xe9, 0x72, 0xb0, 0xf8, 0xdc, 0xf3, 0xa7, 0x1f, 0x8f, 0xac,
-	0xfa, 0x37, 0x3f, 0xfe, 0x5c, 0xd0, 0x57, 0x72, 0xd3, 0x4f, 0x07, 0x05, 0xb7, 0xec, 0xa8, 0x8a,
-	0xf5, 0x30, 0xb8, 0x3b, 0x85, 0x68, 0x74, 0x85, 0x10, 0x8d, 0x28, 0x23, 0x17, 0xfb, 0x6f, 0x28,
-	0x65, 0xad, 0x6c, 0x36, 0x70, 0x19, 0x2c, 0x4f, 0x98, 0x51, 0x4d, 0x34, 0x5a, 0x5f, 0xb5, 0xd6,
-	0x9f, 0x7f, 0x03, 0x00, 0x00, 0xff, 0xff, 0x94, 0x1d, 0xe9, 0x21, 0xda, 0x01, 0x00, 0x00,
+	// 430 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x52, 0xc1, 0x6e, 0xd3, 0x40,
+	0x10, 0x8d, 0xeb, 0x94, 0x92, 0x4d, 0x8b, 0xda, 0x6d, 0x0f, 0x96, 0xb9, 0x98, 0xe5, 0x12, 0x09,
+	0xc9, 0x46, 0x85, 0x03, 0x17, 0x84, 0x8a, 0x54, 0x24, 0x24, 0x0e, 0xc8, 0x39, 0x70, 0x8c, 0x36,
+	0xf6, 0x34, 0x5e, 0xe1, 0xec, 0x2e, 0xb3, 0x9b, 0x16, 0x7f, 0x04, 0x9f, 0xc9, 0x7f, 0xa0, 0xec,
+	0xda, 0xc4, 0x69, 0xac, 0xde, 0x76, 0xde, 0x8e, 0xde, 0x9b, 0x37, 0xf3, 0x48, 0x82, 0xf0, 0x6b,
+	0x03, 0xc6, 0x2e, 0xee, 0x14, 0x3e, 0x70, 0x2c, 0x85, 0x5c, 0x2d, 0x0c, 0xe0, 0xbd, 0x28, 0x20,
+	0xd5, 0xa8, 0xac, 0xa2, 0xc7, 0xf7, 0x7c, 0x53, 0xdb, 0xf8, 0xc3, 0x4a, 0xd8, 0x6a, 0xb3, 0x4c,
+	0x0b, 0xb5, 0xce, 0x2a, 0x6e, 0x2a, 0x51, 0x28, 0xd4, 0x99, 0xfb, 0xcb, 0x2a, 0xa8, 0x35, 0x60,
+	0xb6, 0xa3, 0xc8, 0x6c, 0xa3, 0xc1, 0x78, 0x02, 0xa6, 0xc8, 0xf4, 0xb6, 0xa8, 0x54, 0xee, 0x85,
+	0x68, 0x44, 0x4e, 0xd6, 0x60, 0x0c, 0x5f, 0x41, 0x14, 0x24, 0xc1, 0x6c, 0x92, 0x77, 0x25, 0x7d,
+	0x45, 0x4e, 0x8b, 0x7a, 0x63, 0x2c, 0xe0, 0x82, 0x97, 0x25, 0x46, 0x47, 0xee, 0x7b, 0xda, 0x62,
+	0x37, 0x65, 0x89, 0xf4, 0x35, 0x39, 0xeb, 0xb7, 0x98, 0x28, 0x4c, 0xc2, 0xd9, 0x24, 0x3f, 0xed,
+	0xf5, 0x18, 0xf6, 0x40, 0x26, 0x5e, 0x50, 0xd7, 0xcd, 0x13, 0x72, 0x07, 0x5c, 0x47, 0x87, 0x5c,
+	0xf4, 0x0d, 0xb9, 0x40, 0xd0, 0xb5, 0x28, 0xb8, 0x15, 0x4a, 0x2e, 0x8c, 0xe5, 0x16, 0xa2, 0x30,
+	0x09, 0x66, 0x67, 0xf9, 0x79, 0xef, 0x63, 0xbe, 0xc5, 0xd9, 0x57, 0xf2, 0xf2, 0x3b, 0xe0, 0xdd,
+	0xdc, 0x72, 0x59, 0x2e, 0x9b, 0x1f, 0x37, 0xdf, 0xe6, 0x16, 0x81, 0xaf, 0x3b, 0xe7, 0x57, 0xe4,
+	0x18, 0xb4, 0x2a, 0xaa, 0x76, 0x10, 0x5f, 0x6c, 0x51, 0x21, 0x4b, 0xf8, 0xed, 0xec, 0x8e, 0x73,
+	0x5f, 0xb0, 0x4f, 0xe4, 0x72, 0x9f, 0xea, 0x56, 0x5a, 0x6c, 0x76, 0xcd, 0x41, 0xaf, 0x99, 0x52,
+	0x32, 0xfe, 0x09, 0x4d, 0x67, 0xc0, 0xbd, 0xd9, 0x9f, 0xe0, 0x31, 0xc3, 0x67, 0x6e, 0xbd, 0xdc,
+	0xf0, 0x10, 0x08, 0x06, 0xac, 0x1b, 0xe2, 0x79, 0xee, 0x8b, 0x9d, 0x5a, 0xd8, 0x57, 0x7b, 0x4f,
+	0x4e, 0x40, 0x5a, 0x14, 0x60, 0xa2, 0x71, 0x12, 0xce, 0xa6, 0xd7, 0x71, 0xea, 0x62, 0x90, 0x0e,
+	0x0c, 0x9c, 0x77, 0xad, 0xd7, 0x7f, 0x03, 0x72, 0xd1, 0x2e, 0xe2, 0xcb, 0xff, 0x9c, 0xd0, 0x8f,
+	0xe4, 0x45, 0x5b, 0x75, 0x4b, 0xba, 0x4c, 0x77, 0x31, 0x4a, 0x5b, 0x30, 0xbe, 0xda, 0x07, 0x8d,
+	0x56, 0xd2, 0x00, 0x1b, 0xd1, 0x94, 0x8c, 0xb7, 0x97, 0xa6, 0xb4, 0x9d, 0xa0, 0x97, 0xb3, 0xf8,
+	0x7c, 0x0f, 0xd3, 0x75, 0xc3, 0x46, 0x94, 0xfb, 0x03, 0x29, 0x5c, 0x73, 0x59, 0xc0, 0xe3, 0x3b,
+	0x51, 0x36, 0x68, 0x64, 0xef, 0x88, 0xf1, 0xb0, 0x59, 0xb7, 0x5b, 0x36, 0x7a, 0x1b, 0x2c, 0x9f,
+	0xb9, 0xd0, 0xbf, 0xfb, 0x37, 0x00, 0xc8, 0x3f, 0x10, 0xbf, 0x59, 0x03, 0x00, 0x00,
 }
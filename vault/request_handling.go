@@ -7,11 +7,13 @@ import (
 	"time"
 
 	"github.com/armon/go-metrics"
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/helper/wrapping"
@@ -30,13 +32,41 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 	if c.sealed {
 		return nil, consts.ErrSealed
 	}
-	if c.standby {
+	if c.standby && !c.perfStandby {
 		return nil, consts.ErrStandby
 	}
 
 	ctx, cancel := context.WithCancel(c.activeContext)
 	defer cancel()
 
+	// Requests are scoped to the namespace their path is in. Namespaces other
+	// than the root only have access to part of the system backend.
+	ns := c.namespaceByPath(req.Path)
+	nsPath := ns.TrimmedPath(req.Path)
+	if ns.ID != namespace.RootNamespaceID && strings.HasPrefix(nsPath, "sys/") &&
+		!namespaceSysPathAllowed(strings.TrimPrefix(nsPath, "sys/")) {
+		return logical.ErrorResponse(fmt.Sprintf("path %q is only available in the root namespace", nsPath)), logical.ErrUnsupportedPath
+	}
+	ctx = namespace.ContextWithNamespace(ctx, ns)
+
+	// Performance standbys only handle requests reading from storage
+	if c.perfStandby && !perfStandbyLocalRequest(req, nsPath) {
+		return nil, consts.ErrPerfStandbyPleaseForward
+	}
+
+	// Requests rejected by rate limit quotas are audited but not handled
+	if err := c.applyRateLimitQuota(req); err != nil {
+		logInput := &audit.LogInput{
+			Request:  req,
+			OuterErr: err,
+		}
+		if auditErr := c.auditBroker.LogRequest(ctx, logInput, c.auditedHeaders); auditErr != nil {
+			c.logger.Error("failed to audit request", "path", req.Path, "error", auditErr)
+			return nil, ErrInternalError
+		}
+		return nil, err
+	}
+
 	// Allowing writing to a path ending in / makes it extremely difficult to
 	// understand user intent for the filesystem-like backends (kv,
 	// cubbyhole) -- did they want a key named foo/ or did they want to write
@@ -56,6 +86,13 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 		resp, auth, err = c.handleRequest(ctx, req)
 	}
 
+	// Requests that turn out to need writing to storage, including wrapping
+	// responses, are forwarded to the active node by performance standbys
+	if c.perfStandby && (perfStandbyForwardError(err) ||
+		(err == nil && resp != nil && resp.WrapInfo != nil && resp.WrapInfo.TTL != 0 && resp.WrapInfo.Token == "")) {
+		return nil, consts.ErrPerfStandbyPleaseForward
+	}
+
 	// Ensure we don't leak internal data
 	if resp != nil {
 		if resp.Secret != nil {
@@ -99,7 +136,7 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 	// When unwrapping we want to log the actual response that will be written
 	// out. We still want to return the raw value to avoid automatic updating
 	// to any of it.
-	if req.Path == "sys/wrapping/unwrap" &&
+	if nsPath == "sys/wrapping/unwrap" &&
 		resp != nil &&
 		resp.Data != nil &&
 		resp.Data[logical.HTTPRawBody] != nil {
@@ -152,6 +189,8 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp *logical.Response, retAuth *logical.Auth, retErr error) {
 	defer metrics.MeasureSince([]string{"core", "handle_request"}, time.Now())
 
+	nsPath := namespace.FromContext(ctx).TrimmedPath(req.Path)
+
 	var nonHMACReqDataKeys []string
 	entry := c.router.MatchingMountEntry(req.Path)
 	if entry != nil {
@@ -162,9 +201,14 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 	}
 
 	// Validate the token
-	auth, te, ctErr := c.checkToken(ctx, req, false)
+	auth, te, controlGroup, ctErr := c.checkToken(ctx, req, false)
 	// We run this logic first because we want to decrement the use count even in the case of an error
 	if te != nil {
+		// Uses of limited use tokens are counted by the active node
+		if te.NumUses > 0 && c.perfStandby {
+			return nil, nil, consts.ErrPerfStandbyPleaseForward
+		}
+
 		// Attempt to use the token (decrement NumUses)
 		var err error
 		te, err = c.tokenStore.UseToken(ctx, te)
@@ -200,12 +244,20 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 		}
 	}
 	if ctErr != nil {
+		if c.perfStandby && perfStandbyForwardError(ctErr) {
+			return nil, nil, consts.ErrPerfStandbyPleaseForward
+		}
+
 		// If it is an internal error we return that, otherwise we
 		// return invalid request so that the status codes can be correct
 		errType := logical.ErrInvalidRequest
-		switch ctErr {
-		case ErrInternalError, logical.ErrPermissionDenied:
+		switch {
+		case ctErr == ErrInternalError, ctErr == logical.ErrPermissionDenied:
 			errType = ctErr
+		case errwrap.Contains(ctErr, ErrInternalError.Error()):
+			errType = ErrInternalError
+		case errwrap.Contains(ctErr, logical.ErrPermissionDenied.Error()):
+			errType = logical.ErrPermissionDenied
 		}
 
 		logInput := &audit.LogInput{
@@ -221,7 +273,7 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 		if errType != nil {
 			retErr = multierror.Append(retErr, errType)
 		}
-		if ctErr == ErrInternalError {
+		if errType == ErrInternalError {
 			return nil, auth, retErr
 		}
 		return logical.ErrorResponse(ctErr.Error()), auth, retErr
@@ -242,6 +294,16 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 		return nil, auth, retErr
 	}
 
+	// Requests governed by a control group are held back until they are
+	// approved; the caller gets a token to unwrap their response with
+	if controlGroup != nil {
+		resp, err := c.createControlGroupRequest(ctx, req, te, controlGroup)
+		if err != nil {
+			retErr = multierror.Append(retErr, err)
+		}
+		return resp, auth, retErr
+	}
+
 	// Route the request
 	resp, routeErr := c.router.Route(ctx, req)
 	if resp != nil {
@@ -289,8 +351,8 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 
 	// If there is a secret, we must register it with the expiration manager.
 	// We exclude renewal of a lease, since it does not need to be re-registered
-	if resp != nil && resp.Secret != nil && !strings.HasPrefix(req.Path, "sys/renew") &&
-		!strings.HasPrefix(req.Path, "sys/leases/renew") {
+	if resp != nil && resp.Secret != nil && !strings.HasPrefix(nsPath, "sys/renew") &&
+		!strings.HasPrefix(nsPath, "sys/leases/renew") {
 		// KV mounts should return the TTL but not register
 		// for a lease as this provides a massive slowdown
 		registerLease := true
@@ -346,10 +408,27 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 			for _, warning := range warnings {
 				resp.AddWarning(warning)
 			}
+
+			// Leases of batch tokens can't outlive the token
+			if te != nil && te.Type == logical.TokenTypeBatch {
+				if remaining := time.Until(time.Unix(te.CreationTime, 0).Add(te.TTL)); ttl > remaining {
+					ttl = remaining
+				}
+			}
 			resp.Secret.TTL = ttl
 
+			// Leases are only created by the active node
+			if c.perfStandby {
+				retErr = multierror.Append(retErr, consts.ErrPerfStandbyPleaseForward)
+				return nil, auth, retErr
+			}
+
 			leaseID, err := c.expiration.Register(req, resp)
 			if err != nil {
+				if quotaErr := errwrap.GetType(err, new(logical.RequestQuotaError)); quotaErr != nil {
+					retErr = multierror.Append(retErr, quotaErr)
+					return logical.ErrorResponse(quotaErr.Error()), auth, retErr
+				}
 				c.logger.Error("failed to register lease", "request_path", req.Path, "error", err)
 				retErr = multierror.Append(retErr, ErrInternalError)
 				return nil, auth, retErr
@@ -360,7 +439,7 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 
 	// If the request was to renew a token, and if there are group aliases set
 	// in the auth object, then the group memberships should be refreshed
-	if strings.HasPrefix(req.Path, "auth/token/renew") &&
+	if strings.HasPrefix(nsPath, "auth/token/renew") &&
 		resp != nil &&
 		resp.Auth != nil &&
 		resp.Auth.EntityID != "" &&
@@ -376,8 +455,8 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 	// Only the token store is allowed to return an auth block, for any
 	// other request this is an internal error. We exclude renewal of a token,
 	// since it does not need to be re-registered
-	if resp != nil && resp.Auth != nil && !strings.HasPrefix(req.Path, "auth/token/renew") {
-		if !strings.HasPrefix(req.Path, "auth/token/") {
+	if resp != nil && resp.Auth != nil && !strings.HasPrefix(nsPath, "auth/token/renew") {
+		if !strings.HasPrefix(nsPath, "auth/token/") {
 			c.logger.Error("unexpected Auth response for non-token backend", "request_path", req.Path)
 			retErr = multierror.Append(retErr, ErrInternalError)
 			return nil, auth, retErr
@@ -392,16 +471,19 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 			return nil, auth, retErr
 		}
 
-		if err := c.expiration.RegisterAuth(te.Path, resp.Auth); err != nil {
-			c.tokenStore.Revoke(ctx, te.ID)
-			c.logger.Error("failed to register token lease", "request_path", req.Path, "error", err)
-			retErr = multierror.Append(retErr, ErrInternalError)
-			return nil, auth, retErr
+		// Batch tokens are not tracked by the expiration manager
+		if te.Type != logical.TokenTypeBatch {
+			if err := c.expiration.RegisterAuth(te.Path, resp.Auth); err != nil {
+				c.tokenStore.Revoke(ctx, te.ID)
+				c.logger.Error("failed to register token lease", "request_path", req.Path, "error", err)
+				retErr = multierror.Append(retErr, ErrInternalError)
+				return nil, auth, retErr
+			}
 		}
 	}
 
 	if resp != nil &&
-		req.Path == "cubbyhole/response" &&
+		nsPath == "cubbyhole/response" &&
 		len(te.Policies) == 1 &&
 		te.Policies[0] == responseWrappingPolicyName {
 		resp.AddWarning("Reading from 'cubbyhole/response' is deprecated. Please use sys/wrapping/unwrap to unwrap responses, as it provides additional security checks and other benefits.")
@@ -422,6 +504,8 @@ func (c *Core) handleLoginRequest(ctx context.Context, req *logical.Request) (re
 
 	req.Unauthenticated = true
 
+	ns := namespace.FromContext(ctx)
+
 	var auth *logical.Auth
 	// Create an audit trail of the request, auth is not available on login requests
 	// Create an audit trail of the request. Attach auth if it was returned,
@@ -437,11 +521,22 @@ func (c *Core) handleLoginRequest(ctx context.Context, req *logical.Request) (re
 
 	// The token store uses authentication even when creating a new token,
 	// so it's handled in handleRequest. It should not be reached here.
-	if strings.HasPrefix(req.Path, "auth/token/") {
+	if strings.HasPrefix(ns.TrimmedPath(req.Path), "auth/token/") {
 		c.logger.Error("unexpected login request for token backend", "request_path", req.Path)
 		return nil, nil, ErrInternalError
 	}
 
+	// Endpoint governing policies apply to logins as well
+	authResults := c.performPolicyChecks(ctx, nil, nil, req, nil, &PolicyCheckOpts{
+		Unauth: true,
+	})
+	if err := authResults.Error.ErrorOrNil(); err != nil {
+		if errwrap.Contains(err, ErrInternalError.Error()) {
+			return nil, nil, ErrInternalError
+		}
+		return logical.ErrorResponse(err.Error()), nil, logical.ErrPermissionDenied
+	}
+
 	// Route the request
 	resp, routeErr := c.router.Route(ctx, req)
 	if resp != nil {
@@ -510,7 +605,7 @@ func (c *Core) handleLoginRequest(ctx context.Context, req *logical.Request) (re
 
 			// Fetch the entity for the alias, or create an entity if one
 			// doesn't exist.
-			entity, err = c.identityStore.CreateOrFetchEntity(auth.Alias)
+			entity, err = c.identityStore.CreateOrFetchEntity(ctx, auth.Alias)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -533,7 +628,7 @@ func (c *Core) handleLoginRequest(ctx context.Context, req *logical.Request) (re
 		}
 
 		// Determine the source of the login
-		source := c.router.MatchingMount(req.Path)
+		source := ns.TrimmedPath(c.router.MatchingMount(req.Path))
 		source = strings.TrimPrefix(source, credentialRoutePrefix)
 		source = strings.Replace(source, "/", "-", -1)
 
@@ -564,6 +659,24 @@ func (c *Core) handleLoginRequest(ctx context.Context, req *logical.Request) (re
 			TTL:          tokenTTL,
 			NumUses:      auth.NumUses,
 			EntityID:     auth.EntityID,
+			Type:         logical.TokenTypeService,
+		}
+
+		// Keep track of logins validated with MFA, which EGPs can require
+		if method, ok := auth.InternalData[consts.AuthInternalDataMFAMethodKey].(string); ok {
+			te.MFAMethod = method
+		}
+
+		// Auth methods can be tuned to hand out batch tokens
+		if me := c.router.MatchingMountEntry(req.Path); me != nil && me.Config.TokenType == logical.TokenTypeBatch {
+			if auth.NumUses > 0 {
+				return logical.ErrorResponse("batch tokens cannot have a limited number of uses"), nil, logical.ErrInvalidRequest
+			}
+			if auth.Period > 0 {
+				return logical.ErrorResponse("batch tokens cannot be periodic"), nil, logical.ErrInvalidRequest
+			}
+			te.Type = logical.TokenTypeBatch
+			auth.Renewable = false
 		}
 
 		te.Policies = policyutil.SanitizePolicies(te.Policies, true)
@@ -586,11 +699,13 @@ func (c *Core) handleLoginRequest(ctx context.Context, req *logical.Request) (re
 		auth.Policies = te.Policies
 		auth.TTL = te.TTL
 
-		// Register with the expiration manager
-		if err := c.expiration.RegisterAuth(te.Path, auth); err != nil {
-			c.tokenStore.Revoke(ctx, te.ID)
-			c.logger.Error("failed to register token lease", "request_path", req.Path, "error", err)
-			return nil, auth, ErrInternalError
+		// Register with the expiration manager. Batch tokens are not tracked.
+		if te.Type != logical.TokenTypeBatch {
+			if err := c.expiration.RegisterAuth(te.Path, auth); err != nil {
+				c.tokenStore.Revoke(ctx, te.ID)
+				c.logger.Error("failed to register token lease", "request_path", req.Path, "error", err)
+				return nil, auth, ErrInternalError
+			}
 		}
 
 		// Attach the display name, might be used by audit backends
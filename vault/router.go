@@ -11,6 +11,7 @@ import (
 	"github.com/armon/go-metrics"
 	"github.com/armon/go-radix"
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/salt"
 	"github.com/hashicorp/vault/logical"
 )
@@ -55,6 +56,10 @@ type routeEntry struct {
 	rootPaths     atomic.Value
 	loginPaths    atomic.Value
 	l             sync.RWMutex
+
+	// alias is set when the entry exposes the backend of another mount, see
+	// MountAlias
+	alias bool
 }
 
 type validateMountResponse struct {
@@ -153,8 +158,15 @@ func (r *Router) Unmount(ctx context.Context, prefix string) error {
 		return nil
 	}
 
-	// Call backend's Cleanup routine
+	// Aliases share the backend and caches of the mount they expose, so only
+	// the route itself is removed
 	re := raw.(*routeEntry)
+	if re.alias {
+		r.root.Delete(prefix)
+		return nil
+	}
+
+	// Call backend's Cleanup routine
 	if re.backend != nil {
 		re.backend.Cleanup(ctx)
 	}
@@ -168,6 +180,38 @@ func (r *Router) Unmount(ctx context.Context, prefix string) error {
 	return nil
 }
 
+// MountAlias routes the given prefix to the backend and storage of the
+// existing mount at src, using mountEntry to describe the new route. This is
+// how singleton backends such as the system backend and the token store are
+// exposed inside namespaces. The UUID, accessor and storage lookups keep
+// resolving to the original mount.
+func (r *Router) MountAlias(src, prefix string, mountEntry *MountEntry) error {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	raw, ok := r.root.Get(src)
+	if !ok {
+		return fmt.Errorf("no mount at '%s'", src)
+	}
+	if existing, _, ok := r.root.LongestPrefix(prefix); ok && existing != "" {
+		return fmt.Errorf("cannot mount under existing mount '%s'", existing)
+	}
+	orig := raw.(*routeEntry)
+
+	re := &routeEntry{
+		backend:       orig.backend,
+		mountEntry:    mountEntry,
+		storagePrefix: orig.storagePrefix,
+		storageView:   orig.storageView,
+		alias:         true,
+	}
+	re.rootPaths.Store(orig.rootPaths.Load())
+	re.loginPaths.Store(orig.loginPaths.Load())
+
+	r.root.Insert(prefix, re)
+	return nil
+}
+
 // Remount is used to change the mount location of a logical backend
 func (r *Router) Remount(src, dst string) error {
 	r.l.Lock()
@@ -330,6 +374,19 @@ func (r *Router) MatchingBackend(path string) logical.Backend {
 	return raw.(*routeEntry).backend
 }
 
+// MatchingBackendByStoragePath returns the backend of the mount the given
+// storage path belongs to, along with the storage prefix of the mount
+func (r *Router) MatchingBackendByStoragePath(path string) (logical.Backend, string, bool) {
+	r.l.RLock()
+	_, raw, ok := r.storagePrefix.LongestPrefix(path)
+	r.l.RUnlock()
+	if !ok {
+		return nil, "", false
+	}
+	re := raw.(*routeEntry)
+	return re.backend, re.storagePrefix, true
+}
+
 // MatchingSystemView returns the SystemView used for a path
 func (r *Router) MatchingSystemView(path string) logical.SystemView {
 	r.l.RLock()
@@ -442,11 +499,16 @@ func (r *Router) routeCommon(ctx context.Context, req *logical.Request, existenc
 
 	originalEntityID := req.EntityID
 
+	// Backends are handed the namespace of the mount they serve
+	ns := re.mountEntry.Namespace()
+	ctx = namespace.ContextWithNamespace(ctx, ns)
+	nsPath := ns.TrimmedPath(originalPath)
+
 	// Allow EntityID to passthrough to the system backend. This is required to
 	// allow clients to generate MFA credentials in respective entity objects
 	// in identity store via the system backend.
 	switch {
-	case strings.HasPrefix(originalPath, "sys/"):
+	case strings.HasPrefix(nsPath, "sys/"):
 	default:
 		req.EntityID = ""
 	}
@@ -455,9 +517,9 @@ func (r *Router) routeCommon(ctx context.Context, req *logical.Request, existenc
 	// or system backend.
 	clientToken := req.ClientToken
 	switch {
-	case strings.HasPrefix(originalPath, "auth/token/"):
-	case strings.HasPrefix(originalPath, "sys/"):
-	case strings.HasPrefix(originalPath, "cubbyhole/"):
+	case strings.HasPrefix(nsPath, "auth/token/"):
+	case strings.HasPrefix(nsPath, "sys/"):
+	case strings.HasPrefix(nsPath, "cubbyhole/"):
 		// In order for the token store to revoke later, we need to have the same
 		// salted ID, so we double-salt what's going to the cubbyhole backend
 		salt, err := r.tokenStoreSaltFunc(ctx)
@@ -10,6 +10,7 @@ import (
 
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/vault/seal"
 
 	"github.com/keybase/go-crypto/openpgp"
 	"github.com/keybase/go-crypto/openpgp/packet"
@@ -43,10 +44,11 @@ const (
 )
 
 const (
-	SealTypeShamir = "shamir"
-	SealTypePKCS11 = "pkcs11"
-	SealTypeAWSKMS = "awskms"
-	SealTypeTest   = "test-auto"
+	SealTypeShamir  = seal.Shamir
+	SealTypePKCS11  = "pkcs11"
+	SealTypeAWSKMS  = "awskms"
+	SealTypeTransit = seal.Transit
+	SealTypeTest    = seal.Test
 
 	RecoveryTypeUnsupported = "unsupported"
 	RecoveryTypeShamir      = "shamir"
@@ -0,0 +1,42 @@
+package seal
+
+import (
+	"context"
+)
+
+const (
+	Shamir  = "shamir"
+	Transit = "transit"
+	Test    = "test-auto"
+)
+
+// Access is the interface implemented by the key-wrapping services that an
+// auto seal uses to protect the master key and recovery key.
+type Access interface {
+	// SealType returns the type of the seal, which is persisted with the
+	// barrier configuration
+	SealType() string
+
+	// KeyID returns the identifier of the key currently used for encryption
+	KeyID() string
+
+	Init(context.Context) error
+	Finalize(context.Context) error
+
+	Encrypt(context.Context, []byte) (*EncryptedBlobInfo, error)
+	Decrypt(context.Context, *EncryptedBlobInfo) ([]byte, error)
+}
+
+// EncryptedBlobInfo contains the output of an Access's Encrypt call along
+// with the information needed to decrypt it again.
+type EncryptedBlobInfo struct {
+	// Ciphertext is the encrypted value
+	Ciphertext []byte `json:"ciphertext"`
+
+	// IV is the initialization vector, for services that require the caller
+	// to keep track of it
+	IV []byte `json:"iv,omitempty"`
+
+	// KeyID is the identifier of the key that was used to encrypt the value
+	KeyID string `json:"key_id,omitempty"`
+}
@@ -0,0 +1,253 @@
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/vault/seal"
+)
+
+// Seal is an Access that uses a key of a transit secrets engine, usually on
+// another Vault cluster, to encrypt and decrypt values.
+type Seal struct {
+	logger log.Logger
+
+	client    *api.Client
+	renewer   *api.Renewer
+	mountPath string
+	keyName   string
+
+	currentKeyID *atomic.Value
+}
+
+var _ seal.Access = (*Seal)(nil)
+
+// NewSeal creates a new transit Seal. SetConfig must be called before it
+// can be used.
+func NewSeal(logger log.Logger) *Seal {
+	s := &Seal{
+		logger:       logger,
+		currentKeyID: new(atomic.Value),
+	}
+	s.currentKeyID.Store("")
+	return s
+}
+
+// SetConfig sets the fields on the Seal object based on values from the
+// config parameter. Environment variables take precedence over the values in
+// the config. It returns information about the seal to be shown on startup.
+func (s *Seal) SetConfig(config map[string]string) (map[string]string, error) {
+	if config == nil {
+		config = map[string]string{}
+	}
+
+	switch {
+	case os.Getenv("VAULT_TRANSIT_SEAL_MOUNT_PATH") != "":
+		s.mountPath = os.Getenv("VAULT_TRANSIT_SEAL_MOUNT_PATH")
+	case config["mount_path"] != "":
+		s.mountPath = config["mount_path"]
+	default:
+		return nil, errors.New("mount_path is required")
+	}
+	s.mountPath = strings.Trim(s.mountPath, "/")
+
+	switch {
+	case os.Getenv("VAULT_TRANSIT_SEAL_KEY_NAME") != "":
+		s.keyName = os.Getenv("VAULT_TRANSIT_SEAL_KEY_NAME")
+	case config["key_name"] != "":
+		s.keyName = config["key_name"]
+	default:
+		return nil, errors.New("key_name is required")
+	}
+
+	var disableRenewal bool
+	if v := config["disable_renewal"]; v != "" {
+		var err error
+		disableRenewal, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for disable_renewal: %v", err)
+		}
+	}
+
+	client, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+
+	if !disableRenewal && client.Token() != "" {
+		// Renew the token right away to get a secret to hand to the renewer.
+		// Tokens that can't be renewed are used as they are.
+		secret, err := client.Auth().Token().RenewSelf(0)
+		if err == nil && secret != nil && secret.Auth != nil && secret.Auth.Renewable {
+			renewer, err := client.NewRenewer(&api.RenewerInput{
+				Secret: secret,
+			})
+			if err != nil {
+				return nil, err
+			}
+			s.renewer = renewer
+			go s.renew()
+		}
+	}
+
+	info := map[string]string{
+		"transit address": client.Address(),
+		"transit path":    s.mountPath,
+		"transit key":     s.keyName,
+	}
+	if ns := config["namespace"]; ns != "" {
+		info["transit namespace"] = ns
+	}
+	return info, nil
+}
+
+func newClient(config map[string]string) (*api.Client, error) {
+	apiConfig := api.DefaultConfig()
+	if apiConfig.Error != nil {
+		return nil, apiConfig.Error
+	}
+	if os.Getenv(api.EnvVaultAddress) == "" && config["address"] != "" {
+		apiConfig.Address = config["address"]
+	}
+
+	tlsSkipVerify := false
+	if v := config["tls_skip_verify"]; v != "" {
+		var err error
+		tlsSkipVerify, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for tls_skip_verify: %v", err)
+		}
+	}
+	if config["tls_ca_cert"] != "" || config["tls_client_cert"] != "" || config["tls_server_name"] != "" || tlsSkipVerify {
+		tlsConfig := &api.TLSConfig{
+			CACert:        config["tls_ca_cert"],
+			ClientCert:    config["tls_client_cert"],
+			ClientKey:     config["tls_client_key"],
+			TLSServerName: config["tls_server_name"],
+			Insecure:      tlsSkipVerify,
+		}
+		if err := apiConfig.ConfigureTLS(tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := api.NewClient(apiConfig)
+	if err != nil {
+		return nil, err
+	}
+	if client.Token() == "" && config["token"] != "" {
+		client.SetToken(config["token"])
+	}
+	if os.Getenv("VAULT_NAMESPACE") == "" && config["namespace"] != "" {
+		client.SetNamespace(config["namespace"])
+	}
+	return client, nil
+}
+
+func (s *Seal) renew() {
+	go s.renewer.Renew()
+	for {
+		select {
+		case err := <-s.renewer.DoneCh():
+			if err != nil {
+				s.logger.Error("error renewing transit seal token", "error", err)
+			}
+			return
+		case <-s.renewer.RenewCh():
+			s.logger.Trace("renewed transit seal token")
+		}
+	}
+}
+
+func (s *Seal) SealType() string {
+	return seal.Transit
+}
+
+// KeyID returns the version of the transit key that was last used to encrypt
+// a value.
+func (s *Seal) KeyID() string {
+	return s.currentKeyID.Load().(string)
+}
+
+func (s *Seal) Init(_ context.Context) error {
+	return nil
+}
+
+func (s *Seal) Finalize(_ context.Context) error {
+	if s.renewer != nil {
+		s.renewer.Stop()
+	}
+	return nil
+}
+
+func (s *Seal) Encrypt(_ context.Context, plaintext []byte) (*seal.EncryptedBlobInfo, error) {
+	if plaintext == nil {
+		return nil, errors.New("given plaintext for encryption is nil")
+	}
+
+	secret, err := s.client.Logical().Write(path.Join(s.mountPath, "encrypt", s.keyName), map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting with transit: %v", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("empty response from transit encrypt")
+	}
+	ciphertext, ok := secret.Data["ciphertext"].(string)
+	if !ok || ciphertext == "" {
+		return nil, errors.New("no ciphertext in response from transit encrypt")
+	}
+
+	keyID, err := keyVersion(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	s.currentKeyID.Store(keyID)
+
+	return &seal.EncryptedBlobInfo{
+		Ciphertext: []byte(ciphertext),
+		KeyID:      keyID,
+	}, nil
+}
+
+func (s *Seal) Decrypt(_ context.Context, in *seal.EncryptedBlobInfo) ([]byte, error) {
+	if in == nil {
+		return nil, errors.New("given input for decryption is nil")
+	}
+
+	secret, err := s.client.Logical().Write(path.Join(s.mountPath, "decrypt", s.keyName), map[string]interface{}{
+		"ciphertext": string(in.Ciphertext),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting with transit: %v", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("empty response from transit decrypt")
+	}
+	plaintext, ok := secret.Data["plaintext"].(string)
+	if !ok {
+		return nil, errors.New("no plaintext in response from transit decrypt")
+	}
+	return base64.StdEncoding.DecodeString(plaintext)
+}
+
+// keyVersion returns the key version out of a transit ciphertext, which has
+// the form "vault:v<version>:<data>"
+func keyVersion(ciphertext string) (string, error) {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" || !strings.HasPrefix(parts[1], "v") {
+		return "", errors.New("invalid ciphertext returned by transit")
+	}
+	return strings.TrimPrefix(parts[1], "v"), nil
+}
@@ -0,0 +1,137 @@
+package transit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	transitbackend "github.com/hashicorp/vault/builtin/logical/transit"
+	"github.com/hashicorp/vault/helper/logging"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/vault"
+	"github.com/hashicorp/vault/vault/seal"
+)
+
+// testTransitCluster starts a cluster with a transit key to seal with and
+// returns a Seal configured to use it.
+func testTransitCluster(t *testing.T) (*vault.TestCluster, *Seal) {
+	cluster := vault.NewTestCluster(t, &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"transit": transitbackend.Factory,
+		},
+	}, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+
+	client := cluster.Cores[0].Client
+	if err := client.Sys().Mount("transit", &api.MountInput{
+		Type: "transit",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("transit/keys/unseal", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSeal(logging.NewVaultLogger(log.Trace))
+	if _, err := s.SetConfig(map[string]string{
+		"address":     client.Address(),
+		"token":       client.Token(),
+		"mount_path":  "transit/",
+		"key_name":    "unseal",
+		"tls_ca_cert": cluster.CACertPEMFile,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return cluster, s
+}
+
+func TestTransitSeal_Lifecycle(t *testing.T) {
+	cluster, s := testTransitCluster(t)
+	defer cluster.Cleanup()
+	defer s.Finalize(context.Background())
+
+	input := []byte("foo")
+	blob, err := s.Encrypt(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.KeyID() != "1" || blob.KeyID != "1" {
+		t.Fatalf("bad key ID: %q, %q", s.KeyID(), blob.KeyID)
+	}
+
+	// Rotating the key keeps older values decryptable
+	if _, err := cluster.Cores[0].Client.Logical().Write("transit/keys/unseal/rotate", nil); err != nil {
+		t.Fatal(err)
+	}
+	newBlob, err := s.Encrypt(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.KeyID() != "2" {
+		t.Fatalf("bad key ID: %q", s.KeyID())
+	}
+
+	for _, b := range []*seal.EncryptedBlobInfo{blob, newBlob} {
+		pt, err := s.Decrypt(context.Background(), b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pt, input) {
+			t.Fatalf("expected %s, got %s", input, pt)
+		}
+	}
+
+	if _, err := NewSeal(nil).SetConfig(map[string]string{"mount_path": "transit"}); err == nil {
+		t.Fatal("expected error without a key name")
+	}
+}
+
+func TestTransitSeal_AutoUnseal(t *testing.T) {
+	cluster, s := testTransitCluster(t)
+	defer cluster.Cleanup()
+	defer s.Finalize(context.Background())
+
+	// The second cluster is initialized and unsealed through the transit key
+	// of the first one
+	sealedCluster := vault.NewTestCluster(t, nil, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+		SealFunc: func() vault.Seal {
+			return vault.NewAutoSeal(s)
+		},
+	})
+	sealedCluster.Start()
+	defer sealedCluster.Cleanup()
+
+	core := sealedCluster.Cores[0].Core
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+	if len(sealedCluster.RecoveryKeys) == 0 {
+		t.Fatal("expected recovery keys")
+	}
+
+	status, err := sealedCluster.Cores[0].Client.Sys().SealStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Type != "shamir" || !status.RecoverySeal {
+		t.Fatalf("bad: %#v", status)
+	}
+
+	// Seal the active node and bring it back with the stored keys
+	if err := core.Seal(sealedCluster.RootToken); err != nil {
+		t.Fatal(err)
+	}
+	if err := core.UnsealWithStoredKeys(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+}
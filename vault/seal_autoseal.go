@@ -0,0 +1,363 @@
+package vault
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/vault/seal"
+)
+
+// autoSeal is a Seal that wraps the master key with an external key-wrapping
+// service so that Vault can unseal itself on startup. Operators hold recovery
+// keys instead of unseal keys; these authorize the operations that would
+// otherwise require unseal keys, such as generating a root token.
+type autoSeal struct {
+	seal.Access
+
+	barrierConfig  atomic.Value
+	recoveryConfig atomic.Value
+	core           *Core
+}
+
+// Ensure we are implementing the Seal interface
+var _ Seal = (*autoSeal)(nil)
+
+// NewAutoSeal returns a Seal that uses the given Access to encrypt the master
+// key and recovery key.
+func NewAutoSeal(lowLevel seal.Access) Seal {
+	ret := &autoSeal{
+		Access: lowLevel,
+	}
+	ret.barrierConfig.Store((*SealConfig)(nil))
+	ret.recoveryConfig.Store((*SealConfig)(nil))
+	return ret
+}
+
+func (d *autoSeal) checkCore() error {
+	if d.core == nil {
+		return fmt.Errorf("seal does not have a core set")
+	}
+	return nil
+}
+
+func (d *autoSeal) SetCore(core *Core) {
+	d.core = core
+}
+
+func (d *autoSeal) Init(ctx context.Context) error {
+	return d.Access.Init(ctx)
+}
+
+func (d *autoSeal) Finalize(ctx context.Context) error {
+	return d.Access.Finalize(ctx)
+}
+
+func (d *autoSeal) BarrierType() string {
+	return d.SealType()
+}
+
+func (d *autoSeal) StoredKeysSupported() bool {
+	return true
+}
+
+func (d *autoSeal) RecoveryKeySupported() bool {
+	return true
+}
+
+// SetStoredKeys uses the autoSeal.Access.Encrypt method to wrap the keys. The
+// stored entry will contain the ciphertext along with the key ID used to
+// encrypt it.
+func (d *autoSeal) SetStoredKeys(ctx context.Context, keys [][]byte) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+	if keys == nil {
+		return fmt.Errorf("keys were nil")
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys provided")
+	}
+
+	buf, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("failed to encode keys for storage: %v", err)
+	}
+
+	return d.putEncrypted(ctx, storedBarrierKeysPath, buf)
+}
+
+// GetStoredKeys retrieves the key shares by unwrapping the encrypted key
+// using the autoseal.
+func (d *autoSeal) GetStoredKeys(ctx context.Context) ([][]byte, error) {
+	if err := d.checkCore(); err != nil {
+		return nil, err
+	}
+
+	pt, err := d.getDecrypted(ctx, storedBarrierKeysPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stored keys: %v", err)
+	}
+	if pt == nil {
+		return nil, nil
+	}
+
+	var keys [][]byte
+	if err := json.Unmarshal(pt, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode stored keys: %v", err)
+	}
+	return keys, nil
+}
+
+func (d *autoSeal) BarrierConfig(ctx context.Context) (*SealConfig, error) {
+	if d.barrierConfig.Load().(*SealConfig) != nil {
+		return d.barrierConfig.Load().(*SealConfig).Clone(), nil
+	}
+
+	conf, err := d.readConfig(ctx, barrierSealConfigPath, d.BarrierType())
+	if err != nil || conf == nil {
+		return nil, err
+	}
+
+	d.barrierConfig.Store(conf)
+	return conf.Clone(), nil
+}
+
+func (d *autoSeal) SetBarrierConfig(ctx context.Context, conf *SealConfig) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+
+	// Provide a way to wipe out the cached value (also prevents actually
+	// saving a nil config)
+	if conf == nil {
+		d.barrierConfig.Store((*SealConfig)(nil))
+		return nil
+	}
+
+	conf.Type = d.BarrierType()
+	if err := d.writeConfig(ctx, barrierSealConfigPath, conf); err != nil {
+		return err
+	}
+
+	d.barrierConfig.Store(conf.Clone())
+	return nil
+}
+
+func (d *autoSeal) RecoveryType() string {
+	return RecoveryTypeShamir
+}
+
+// RecoveryConfig returns the recovery config on recoverySealConfigPlaintextPath.
+func (d *autoSeal) RecoveryConfig(ctx context.Context) (*SealConfig, error) {
+	if d.recoveryConfig.Load().(*SealConfig) != nil {
+		return d.recoveryConfig.Load().(*SealConfig).Clone(), nil
+	}
+
+	conf, err := d.readConfig(ctx, recoverySealConfigPlaintextPath, d.RecoveryType())
+	if err != nil || conf == nil {
+		return nil, err
+	}
+
+	d.recoveryConfig.Store(conf)
+	return conf.Clone(), nil
+}
+
+// SetRecoveryConfig writes the recovery configuration to the physical
+// storage and sets it as the seal's recovery configuration.
+func (d *autoSeal) SetRecoveryConfig(ctx context.Context, conf *SealConfig) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+
+	// Perform migration if applicable
+	if err := d.migrateRecoveryConfig(ctx); err != nil {
+		return err
+	}
+
+	// Provide a way to wipe out the cached value (also prevents actually
+	// saving a nil config)
+	if conf == nil {
+		d.recoveryConfig.Store((*SealConfig)(nil))
+		return nil
+	}
+
+	conf.Type = d.RecoveryType()
+	if err := d.writeConfig(ctx, recoverySealConfigPlaintextPath, conf); err != nil {
+		return err
+	}
+
+	d.recoveryConfig.Store(conf.Clone())
+	return nil
+}
+
+func (d *autoSeal) VerifyRecoveryKey(ctx context.Context, key []byte) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+	if key == nil {
+		return fmt.Errorf("recovery key to verify is nil")
+	}
+
+	pt, err := d.getDecrypted(ctx, recoveryKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to fetch recovery key: %v", err)
+	}
+	if pt == nil {
+		return fmt.Errorf("no recovery key found")
+	}
+
+	if subtle.ConstantTimeCompare(key, pt) != 1 {
+		return errors.New("recovery key verification failed")
+	}
+	return nil
+}
+
+func (d *autoSeal) SetRecoveryKey(ctx context.Context, key []byte) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+	if key == nil {
+		return fmt.Errorf("recovery key to store is nil")
+	}
+
+	return d.putEncrypted(ctx, recoveryKeyPath, key)
+}
+
+// migrateRecoveryConfig moves a recovery configuration stored inside the
+// barrier at the deprecated recoverySealConfigPath to the plaintext path, so
+// that it can be read while Vault is sealed. It is a no-op while sealed.
+func (d *autoSeal) migrateRecoveryConfig(ctx context.Context) error {
+	if sealed, _ := d.core.barrier.Sealed(); sealed {
+		return nil
+	}
+
+	be, err := d.core.barrier.Get(ctx, recoverySealConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to read old recovery seal configuration: %v", err)
+	}
+	if be == nil {
+		return nil
+	}
+
+	pe, err := d.core.physical.Get(ctx, recoverySealConfigPlaintextPath)
+	if err != nil {
+		return fmt.Errorf("failed to read recovery seal configuration: %v", err)
+	}
+	if pe == nil {
+		if err := d.core.physical.Put(ctx, &physical.Entry{
+			Key:   recoverySealConfigPlaintextPath,
+			Value: be.Value,
+		}); err != nil {
+			return fmt.Errorf("failed to write recovery seal configuration: %v", err)
+		}
+	}
+
+	if err := d.core.barrier.Delete(ctx, recoverySealConfigPath); err != nil {
+		return fmt.Errorf("failed to delete old recovery seal configuration: %v", err)
+	}
+	return nil
+}
+
+func (d *autoSeal) readConfig(ctx context.Context, path, sealType string) (*SealConfig, error) {
+	if err := d.checkCore(); err != nil {
+		return nil, err
+	}
+
+	pe, err := d.core.physical.Get(ctx, path)
+	if err != nil {
+		d.core.logger.Error("failed to read seal configuration", "path", path, "error", err)
+		return nil, fmt.Errorf("failed to check seal configuration: %v", err)
+	}
+
+	// If the seal configuration is missing, we are not initialized
+	if pe == nil {
+		d.core.logger.Info("seal configuration missing, not initialized", "path", path)
+		return nil, nil
+	}
+
+	var conf SealConfig
+	if err := jsonutil.DecodeJSON(pe.Value, &conf); err != nil {
+		d.core.logger.Error("failed to decode seal configuration", "path", path, "error", err)
+		return nil, fmt.Errorf("failed to decode seal configuration: %v", err)
+	}
+
+	switch conf.Type {
+	case "":
+		conf.Type = sealType
+	case sealType:
+	default:
+		d.core.logger.Error("seal type does not match loaded type", "seal_type", conf.Type, "loaded_seal_type", sealType)
+		return nil, fmt.Errorf("seal type of %s does not match loaded type of %s", conf.Type, sealType)
+	}
+
+	// Check for a valid seal configuration
+	if err := conf.Validate(); err != nil {
+		d.core.logger.Error("invalid seal configuration", "path", path, "error", err)
+		return nil, fmt.Errorf("seal validation failed: %v", err)
+	}
+
+	return &conf, nil
+}
+
+func (d *autoSeal) writeConfig(ctx context.Context, path string, conf *SealConfig) error {
+	buf, err := json.Marshal(conf)
+	if err != nil {
+		return fmt.Errorf("failed to encode seal configuration: %v", err)
+	}
+
+	if err := d.core.physical.Put(ctx, &physical.Entry{
+		Key:   path,
+		Value: buf,
+	}); err != nil {
+		d.core.logger.Error("failed to write seal configuration", "path", path, "error", err)
+		return fmt.Errorf("failed to write seal configuration: %v", err)
+	}
+	return nil
+}
+
+func (d *autoSeal) putEncrypted(ctx context.Context, path string, pt []byte) error {
+	blobInfo, err := d.Encrypt(ctx, pt)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt value: %v", err)
+	}
+
+	value, err := json.Marshal(blobInfo)
+	if err != nil {
+		return fmt.Errorf("failed to encode encrypted value: %v", err)
+	}
+
+	if err := d.core.physical.Put(ctx, &physical.Entry{
+		Key:   path,
+		Value: value,
+	}); err != nil {
+		return fmt.Errorf("failed to write encrypted value: %v", err)
+	}
+	return nil
+}
+
+func (d *autoSeal) getDecrypted(ctx context.Context, path string) ([]byte, error) {
+	pe, err := d.core.physical.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if pe == nil {
+		return nil, nil
+	}
+
+	var blobInfo seal.EncryptedBlobInfo
+	if err := jsonutil.DecodeJSON(pe.Value, &blobInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted value: %v", err)
+	}
+
+	pt, err := d.Decrypt(ctx, &blobInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %v", err)
+	}
+	return pt, nil
+}
@@ -0,0 +1,358 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/logging"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/physical/inmem"
+	"github.com/hashicorp/vault/vault/seal"
+)
+
+func testCoreWithBackendSeal(t *testing.T, backend physical.Backend, s Seal) *Core {
+	t.Helper()
+	logger := logging.NewVaultLogger(log.Trace)
+	conf := testCoreConfig(t, backend, logger)
+	conf.Seal = s
+	core, err := NewCore(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return core
+}
+
+func testCheckRootToken(t *testing.T, core *Core, root string) {
+	t.Helper()
+	req := logical.TestRequest(t, logical.ReadOperation, "sys/mounts")
+	req.ClientToken = root
+	resp, err := core.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+}
+
+func TestAutoSeal_StoredKeys(t *testing.T) {
+	access := seal.NewTestSeal()
+	core := TestCoreWithSeal(t, NewAutoSeal(access), false)
+
+	_, recoveryKeys, root := TestCoreInitClusterWrapperSetup(t, core, nil, nil)
+	if err := core.UnsealWithStoredKeys(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+	testCheckRootToken(t, core, root)
+
+	// The stored keys must be encrypted
+	pe, err := core.physical.Get(context.Background(), storedBarrierKeysPath)
+	if err != nil || pe == nil {
+		t.Fatalf("err: %v, entry: %v", err, pe)
+	}
+	keys, err := core.seal.GetStoredKeys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 stored keys, got %d", len(keys))
+	}
+
+	// Recovery keys can unseal as well
+	if err := core.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range recoveryKeys {
+		if _, err := TestCoreUnsealWithRecoveryKeys(core, TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+
+	// A different key in the service can't unseal the storage
+	if err := core.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+	core.seal = NewAutoSeal(seal.NewTestSeal())
+	core.seal.SetCore(core)
+	if err := core.UnsealWithStoredKeys(context.Background()); err == nil {
+		t.Fatal("expected error unsealing with the wrong key")
+	}
+}
+
+func TestAutoSeal_Migration(t *testing.T) {
+	logger := logging.NewVaultLogger(log.Trace)
+	backend, err := inmem.NewInmem(nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	access := seal.NewTestSeal()
+
+	// Initialize with Shamir
+	core := testCoreWithBackendSeal(t, backend, NewDefaultSeal())
+	keys, root := TestCoreInit(t, core)
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(core, TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := core.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+
+	// Migrate to the auto seal; the unseal keys become the recovery keys
+	core = testCoreWithBackendSeal(t, backend, NewDefaultSeal())
+	core.SetSealsForMigration(NewDefaultSeal(), NewAutoSeal(access))
+	if !core.SealMigrationPending() {
+		t.Fatal("expected migration to be pending")
+	}
+	if _, err := TestCoreUnseal(core, TestKeyCopy(keys[0])); err != ErrSealMigrationPending {
+		t.Fatalf("expected migration pending error, got %v", err)
+	}
+	if err := core.UnsealWithStoredKeys(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sealed, _ := core.Sealed(); !sealed {
+		t.Fatal("should be sealed")
+	}
+	for _, key := range keys {
+		if _, err := core.UnsealMigrate(TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+	if core.SealMigrationPending() {
+		t.Fatal("expected migration to be done")
+	}
+	sealType, err := core.PhysicalSealConfigType(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealType != SealTypeTest {
+		t.Fatalf("bad: %q", sealType)
+	}
+	testCheckRootToken(t, core, root)
+	recoveryConfig, err := core.seal.RecoveryConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recoveryConfig.SecretShares != 3 || recoveryConfig.SecretThreshold != 3 {
+		t.Fatalf("bad: %#v", recoveryConfig)
+	}
+	if err := core.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart unseals with the stored key
+	core = testCoreWithBackendSeal(t, backend, NewAutoSeal(access))
+	if err := core.UnsealWithStoredKeys(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+	testCheckRootToken(t, core, root)
+	if err := core.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+
+	// Migrate back to Shamir with the recovery keys
+	core = testCoreWithBackendSeal(t, backend, NewDefaultSeal())
+	core.SetSealsForMigration(NewAutoSeal(access), NewDefaultSeal())
+	for _, key := range keys {
+		if _, err := core.UnsealMigrate(TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+	sealType, err = core.PhysicalSealConfigType(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealType != SealTypeShamir {
+		t.Fatalf("bad: %q", sealType)
+	}
+	pe, err := core.physical.Get(context.Background(), storedBarrierKeysPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pe != nil {
+		t.Fatal("expected stored keys to be removed")
+	}
+	if err := core.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+
+	// The recovery keys are the unseal keys now
+	core = testCoreWithBackendSeal(t, backend, NewDefaultSeal())
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(core, TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+	testCheckRootToken(t, core, root)
+}
+
+// putFailingBackend fails writes of the given key, to make a seal migration
+// fail at that step
+type putFailingBackend struct {
+	physical.Backend
+	key string
+}
+
+func (b *putFailingBackend) Put(ctx context.Context, entry *physical.Entry) error {
+	if entry.Key == b.key {
+		return fmt.Errorf("write of %q failed", b.key)
+	}
+	return b.Backend.Put(ctx, entry)
+}
+
+func TestAutoSeal_MigrationRekeyFailure(t *testing.T) {
+	logger := logging.NewVaultLogger(log.Trace)
+	backend, err := inmem.NewInmem(nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	core := testCoreWithBackendSeal(t, backend, NewDefaultSeal())
+	keys, root := TestCoreInit(t, core)
+
+	// A migration failing to rekey the barrier leaves nothing of the new seal
+	// behind
+	core = testCoreWithBackendSeal(t, &putFailingBackend{Backend: backend, key: keyringPath}, NewDefaultSeal())
+	core.SetSealsForMigration(NewDefaultSeal(), NewAutoSeal(seal.NewTestSeal()))
+	var migrateErr error
+	for _, key := range keys {
+		if _, migrateErr = core.UnsealMigrate(TestKeyCopy(key)); migrateErr != nil {
+			break
+		}
+	}
+	if migrateErr == nil {
+		t.Fatal("expected migration to fail")
+	}
+	for _, path := range []string{storedBarrierKeysPath, recoveryKeyPath, recoverySealConfigPlaintextPath} {
+		pe, err := backend.Get(context.Background(), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pe != nil {
+			t.Fatalf("expected %q to be removed", path)
+		}
+	}
+
+	// The unseal keys still unseal the barrier
+	core = testCoreWithBackendSeal(t, backend, NewDefaultSeal())
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(core, TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+	testCheckRootToken(t, core, root)
+}
+
+func TestAutoSeal_MigrationBarrierConfigFailure(t *testing.T) {
+	logger := logging.NewVaultLogger(log.Trace)
+	backend, err := inmem.NewInmem(nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	access := seal.NewTestSeal()
+
+	core := testCoreWithBackendSeal(t, backend, NewDefaultSeal())
+	keys, root := TestCoreInit(t, core)
+
+	// A migration to the auto seal failing after the barrier was rekeyed
+	// rekeys it back and leaves nothing of the new seal behind
+	core = testCoreWithBackendSeal(t, &putFailingBackend{Backend: backend, key: barrierSealConfigPath}, NewDefaultSeal())
+	core.SetSealsForMigration(NewDefaultSeal(), NewAutoSeal(access))
+	var migrateErr error
+	for _, key := range keys {
+		if _, migrateErr = core.UnsealMigrate(TestKeyCopy(key)); migrateErr != nil {
+			break
+		}
+	}
+	if migrateErr == nil {
+		t.Fatal("expected migration to fail")
+	}
+	for _, path := range []string{storedBarrierKeysPath, recoveryKeyPath, recoverySealConfigPlaintextPath} {
+		pe, err := backend.Get(context.Background(), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pe != nil {
+			t.Fatalf("expected %q to be removed", path)
+		}
+	}
+
+	// The unseal keys still unseal the barrier
+	core = testCoreWithBackendSeal(t, backend, NewDefaultSeal())
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(core, TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+	testCheckRootToken(t, core, root)
+	if err := core.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+
+	// Migrate to the auto seal for real
+	core = testCoreWithBackendSeal(t, backend, NewDefaultSeal())
+	core.SetSealsForMigration(NewDefaultSeal(), NewAutoSeal(access))
+	for _, key := range keys {
+		if _, err := core.UnsealMigrate(TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := core.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+
+	// A migration back to Shamir failing after the barrier was rekeyed
+	// rekeys it back, so the auto seal still unseals it
+	core = testCoreWithBackendSeal(t, &putFailingBackend{Backend: backend, key: barrierSealConfigPath}, NewDefaultSeal())
+	core.SetSealsForMigration(NewAutoSeal(access), NewDefaultSeal())
+	migrateErr = nil
+	for _, key := range keys {
+		if _, migrateErr = core.UnsealMigrate(TestKeyCopy(key)); migrateErr != nil {
+			break
+		}
+	}
+	if migrateErr == nil {
+		t.Fatal("expected migration to fail")
+	}
+	sealType, err := core.PhysicalSealConfigType(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealType != SealTypeTest {
+		t.Fatalf("bad: %q", sealType)
+	}
+
+	core = testCoreWithBackendSeal(t, backend, NewAutoSeal(access))
+	if err := core.UnsealWithStoredKeys(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+	testCheckRootToken(t, core, root)
+}
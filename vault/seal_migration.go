@@ -0,0 +1,279 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/shamir"
+)
+
+// SetSealsForMigration configures a seal migration. from is the seal the
+// storage is currently protected with and to is the seal the storage is
+// migrated to by the next call to UnsealMigrate. It must be called while
+// Vault is sealed.
+func (c *Core) SetSealsForMigration(from, to Seal) {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+
+	c.seal = from
+	c.seal.SetCore(c)
+	c.sealMigrationTarget = to
+	c.sealMigrationTarget.SetCore(c)
+
+	c.logger.Warn("seal migration configured, unseal with migrate set to perform it", "from_seal_type", from.BarrierType(), "to_seal_type", to.BarrierType())
+}
+
+// SealMigrationPending returns whether a seal migration is configured and
+// has not been performed yet.
+func (c *Core) SealMigrationPending() bool {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	return c.sealMigrationTarget != nil
+}
+
+// PhysicalSealConfigType returns the seal type the storage was initialized
+// with, without checking it against the configured seal. An empty string is
+// returned if Vault is not initialized.
+func (c *Core) PhysicalSealConfigType(ctx context.Context) (string, error) {
+	pe, err := c.physical.Get(ctx, barrierSealConfigPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read seal configuration: %v", err)
+	}
+	if pe == nil {
+		return "", nil
+	}
+
+	var conf SealConfig
+	if err := jsonutil.DecodeJSON(pe.Value, &conf); err != nil {
+		return "", fmt.Errorf("failed to decode seal configuration: %v", err)
+	}
+	if conf.Type == "" {
+		return SealTypeShamir, nil
+	}
+	return conf.Type, nil
+}
+
+// UnsealMigrate is used to provide one of the key shares of the current seal
+// to unseal the Vault and migrate it to the configured target seal. The key
+// shares are unseal keys when migrating away from Shamir and recovery keys
+// when migrating away from an auto seal.
+func (c *Core) UnsealMigrate(key []byte) (bool, error) {
+	defer metrics.MeasureSince([]string{"core", "unseal_migrate"}, time.Now())
+
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+
+	ctx := context.Background()
+
+	init, err := c.Initialized(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !init {
+		return false, ErrNotInit
+	}
+	if c.sealMigrationTarget == nil {
+		return false, errors.New("no seal migration is configured")
+	}
+
+	// Verify the key length
+	min, max := c.barrier.KeyLength()
+	max += shamir.ShareOverhead
+	if len(key) < min {
+		return false, &ErrInvalidKey{fmt.Sprintf("key is shorter than minimum %d bytes", min)}
+	}
+	if len(key) > max {
+		return false, &ErrInvalidKey{fmt.Sprintf("key is longer than maximum %d bytes", max)}
+	}
+
+	// Check if already unsealed
+	if !c.sealed {
+		return true, nil
+	}
+
+	var config *SealConfig
+	if c.seal.RecoveryKeySupported() {
+		config, err = c.seal.RecoveryConfig(ctx)
+	} else {
+		config, err = c.seal.BarrierConfig(ctx)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// The shares are combined as given, so for a seal with recovery keys this
+	// is the recovery key rather than the master key
+	combinedKey, err := c.unsealPart(ctx, config, key, false)
+	if err != nil {
+		return false, err
+	}
+	if combinedKey == nil {
+		return false, nil
+	}
+	defer memzero(combinedKey)
+
+	masterKey := combinedKey
+	if c.seal.RecoveryKeySupported() {
+		if err := c.seal.VerifyRecoveryKey(ctx, combinedKey); err != nil {
+			return false, err
+		}
+
+		keys, err := c.seal.GetStoredKeys(ctx)
+		if err != nil {
+			return false, fmt.Errorf("unable to retrieve stored keys: %v", err)
+		}
+		if len(keys) != 1 {
+			return false, fmt.Errorf("seal migration requires a single stored key, found %d", len(keys))
+		}
+		masterKey = keys[0]
+	}
+
+	newMasterKey, err := c.migrateSeal(ctx, masterKey, combinedKey)
+	if err != nil {
+		c.logger.Error("seal migration failed", "error", err)
+		return false, err
+	}
+
+	return c.unsealInternal(ctx, newMasterKey)
+}
+
+// migrateSeal moves the protection of the master key from the current seal
+// to the migration target and returns the new master key. combinedKey is the
+// key combined from the provided shares: the master key for Shamir and the
+// recovery key for an auto seal. The barrier is sealed again on return.
+// N.B.: This must be called with the state write lock held.
+func (c *Core) migrateSeal(ctx context.Context, masterKey, combinedKey []byte) ([]byte, error) {
+	from, to := c.seal, c.sealMigrationTarget
+
+	if err := c.barrier.Unseal(ctx, masterKey); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := c.barrier.Seal(); err != nil {
+			c.logger.Error("failed to seal barrier after seal migration", "error", err)
+		}
+	}()
+
+	if err := to.Init(ctx); err != nil {
+		return nil, fmt.Errorf("error initializing seal: %v", err)
+	}
+
+	// Until the new seal's barrier configuration is written the old seal is
+	// still the one unsealing the barrier, so if anything fails before then
+	// the barrier is rekeyed back to the old master key and whatever the new
+	// seal wrote is removed.
+	var cleanup func()
+	rekeyed, migrated := false, false
+	defer func() {
+		if migrated {
+			return
+		}
+		if rekeyed {
+			if err := c.barrier.Rekey(ctx, masterKey); err != nil {
+				// The barrier still needs what the new seal stored
+				c.logger.Error("failed to roll back barrier rekey after failed seal migration", "error", err)
+				return
+			}
+		}
+		if cleanup != nil {
+			cleanup()
+		}
+	}()
+
+	var newMasterKey []byte
+	switch {
+	case from.BarrierType() == SealTypeShamir && to.StoredKeysSupported() && to.RecoveryKeySupported():
+		// The existing unseal keys become the recovery keys, and a new master
+		// key is generated and stored through the new seal.
+		oldConfig, err := from.BarrierConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// The new master key is stored before the barrier is rekeyed with it
+		// so that it is never lost
+		cleanup = func() {
+			for _, path := range []string{storedBarrierKeysPath, recoveryKeyPath, recoverySealConfigPlaintextPath} {
+				if err := c.physical.Delete(ctx, path); err != nil {
+					c.logger.Error("failed to clean up after failed seal migration", "path", path, "error", err)
+				}
+			}
+		}
+
+		newMasterKey, err = c.barrier.GenerateKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate master key: %v", err)
+		}
+		if err := to.SetStoredKeys(ctx, [][]byte{newMasterKey}); err != nil {
+			return nil, fmt.Errorf("failed to store master key: %v", err)
+		}
+		if err := to.SetRecoveryKey(ctx, combinedKey); err != nil {
+			return nil, fmt.Errorf("failed to store recovery key: %v", err)
+		}
+
+		recoveryConfig := oldConfig.Clone()
+		recoveryConfig.StoredShares = 0
+		recoveryConfig.PGPKeys = nil
+		if err := to.SetRecoveryConfig(ctx, recoveryConfig); err != nil {
+			return nil, fmt.Errorf("failed to save recovery configuration: %v", err)
+		}
+
+		if err := c.barrier.Rekey(ctx, newMasterKey); err != nil {
+			return nil, fmt.Errorf("failed to rekey barrier: %v", err)
+		}
+		rekeyed = true
+
+		if err := to.SetBarrierConfig(ctx, &SealConfig{
+			SecretShares:    1,
+			SecretThreshold: 1,
+			StoredShares:    1,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to save barrier configuration: %v", err)
+		}
+		migrated = true
+
+	case from.RecoveryKeySupported() && to.BarrierType() == SealTypeShamir:
+		// The recovery key becomes the master key, so the recovery key shares
+		// are the unseal keys from now on.
+		recoveryConfig, err := from.RecoveryConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		newMasterKey = make([]byte, len(combinedKey))
+		copy(newMasterKey, combinedKey)
+		if err := c.barrier.Rekey(ctx, newMasterKey); err != nil {
+			return nil, fmt.Errorf("failed to rekey barrier: %v", err)
+		}
+		rekeyed = true
+
+		barrierConfig := recoveryConfig.Clone()
+		barrierConfig.StoredShares = 0
+		barrierConfig.PGPKeys = nil
+		if err := to.SetBarrierConfig(ctx, barrierConfig); err != nil {
+			return nil, fmt.Errorf("failed to save barrier configuration: %v", err)
+		}
+		migrated = true
+
+		// The stored keys are no longer used once the barrier configuration
+		// is Shamir's, so failing to remove them doesn't fail the migration
+		for _, path := range []string{storedBarrierKeysPath, recoveryKeyPath, recoverySealConfigPlaintextPath} {
+			if err := c.physical.Delete(ctx, path); err != nil {
+				c.logger.Error("failed to clean up after seal migration", "path", path, "error", err)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("migrating from seal type %q to %q is not supported", from.BarrierType(), to.BarrierType())
+	}
+
+	c.seal = to
+	c.sealMigrationTarget = nil
+
+	c.logger.Info("seal migration complete", "from_seal_type", from.BarrierType(), "to_seal_type", to.BarrierType())
+	return newMasterKey, nil
+}
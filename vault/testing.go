@@ -923,6 +923,11 @@ type TestClusterOptions struct {
 	TempDir            string
 	CACert             []byte
 	CAKey              *ecdsa.PrivateKey
+
+	// PhysicalFactory, if set, returns the storage of each core, which is
+	// also used for HA if it supports it. Otherwise the cores share the
+	// storage of the base config, or an in-memory one.
+	PhysicalFactory func(coreIdx int) physical.Backend
 }
 
 var DefaultNumCores = 3
@@ -1243,6 +1248,8 @@ func NewTestCluster(t testing.T, base *CoreConfig, opts *TestClusterOptions) *Te
 
 		coreConfig.DisableCache = base.DisableCache
 
+		coreConfig.PerformanceStandby = base.PerformanceStandby
+
 		coreConfig.DevToken = base.DevToken
 	}
 
@@ -1267,6 +1274,13 @@ func NewTestCluster(t testing.T, base *CoreConfig, opts *TestClusterOptions) *Te
 			coreConfig.ClusterAddr = fmt.Sprintf("https://127.0.0.1:%d", listeners[i][0].Address.Port+105)
 		}
 
+		if opts != nil && opts.PhysicalFactory != nil {
+			coreConfig.Physical = opts.PhysicalFactory(i)
+			if haPhys, ok := coreConfig.Physical.(physical.HABackend); ok && haPhys.HAEnabled() {
+				coreConfig.HAPhysical = haPhys
+			}
+		}
+
 		// if opts.SealFunc is provided, use that to generate a seal for the config instead
 		if opts != nil && opts.SealFunc != nil {
 			coreConfig.Seal = opts.SealFunc()
@@ -2,6 +2,7 @@ package vault
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"sync"
@@ -19,6 +20,7 @@ import (
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/helper/salt"
@@ -48,6 +50,18 @@ const (
 	// rolesPrefix is the prefix used to store role information
 	rolesPrefix = "roles/"
 
+	// namespaceRolesPrefix is the prefix used to store the role information
+	// of namespaces other than the root, under the namespace ID
+	namespaceRolesPrefix = "namespace-roles/"
+
+	// batchTokenPrefix is the prefix of batch token IDs, which tells them
+	// apart from the UUIDs of service tokens
+	batchTokenPrefix = "b."
+
+	// batchTokenEncryptionPath is the path batch tokens are encrypted for by
+	// the barrier, so that no other encrypted value can pass as one
+	batchTokenEncryptionPath = "core/batch-token"
+
 	// tokenRevocationDeferred indicates that the token should not be used
 	// again but is currently fulfilling its final use
 	tokenRevocationDeferred = -1
@@ -91,7 +105,11 @@ type TokenStore struct {
 
 	cubbyholeBackend *CubbyholeBackend
 
-	policyLookupFunc func(string) (*Policy, error)
+	policyLookupFunc func(context.Context, string) (*Policy, error)
+
+	namespaceLookupFunc func(string) *namespace.Namespace
+
+	batchTokenEncryptor BarrierEncryptor
 
 	tokenLocks []*locksutil.LockEntry
 
@@ -121,10 +139,12 @@ func NewTokenStore(ctx context.Context, logger log.Logger, c *Core, config *logi
 	}
 
 	if c.policyStore != nil {
-		t.policyLookupFunc = func(name string) (*Policy, error) {
+		t.policyLookupFunc = func(ctx context.Context, name string) (*Policy, error) {
 			return c.policyStore.GetPolicy(ctx, name, PolicyTypeToken)
 		}
 	}
+	t.namespaceLookupFunc = c.namespaceByID
+	t.batchTokenEncryptor = c.barrier
 
 	// Setup the framework endpoints
 	t.Backend = &framework.Backend{
@@ -216,6 +236,12 @@ func NewTokenStore(ctx context.Context, logger log.Logger, c *Core, config *logi
 						Default:     true,
 						Description: tokenRenewableHelp,
 					},
+
+					"token_type": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     "default",
+						Description: tokenTypeHelp,
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -570,6 +596,18 @@ type TokenEntry struct {
 	ExplicitMaxTTLDeprecated time.Duration `json:"ExplicitMaxTTL" mapstructure:"ExplicitMaxTTL" structs:"ExplicitMaxTTL" sentinel:""`
 
 	EntityID string `json:"entity_id" mapstructure:"entity_id" structs:"entity_id"`
+
+	// ID of the namespace the token belongs to. The token can't be used
+	// outside of it.
+	NamespaceID string `json:"namespace_id" mapstructure:"namespace_id" structs:"namespace_id"`
+
+	// The type of the token. Tokens created before token types existed are
+	// service tokens.
+	Type logical.TokenType `json:"type" mapstructure:"type" structs:"type"`
+
+	// If set, the type of the MFA method the login the token was created by
+	// was validated with
+	MFAMethod string `json:"mfa_method" mapstructure:"mfa_method" structs:"mfa_method"`
 }
 
 func (te *TokenEntry) SentinelGet(key string) (interface{}, error) {
@@ -652,6 +690,10 @@ type tsRoleEntry struct {
 	// If set, the token entry will have an explicit maximum TTL set, rather
 	// than deferring to role/mount values
 	ExplicitMaxTTL time.Duration `json:"explicit_max_ttl" mapstructure:"explicit_max_ttl" structs:"explicit_max_ttl"`
+
+	// If set, the type of the tokens created using this role. Otherwise the
+	// type can be chosen when creating the token.
+	TokenType logical.TokenType `json:"token_type" mapstructure:"token_type" structs:"token_type"`
 }
 
 type accessorEntry struct {
@@ -757,6 +799,22 @@ func (ts *TokenStore) createAccessor(ctx context.Context, entry *TokenEntry) err
 // a newly generated ID if not provided.
 func (ts *TokenStore) create(ctx context.Context, entry *TokenEntry) error {
 	defer metrics.MeasureSince([]string{"token", "create"}, time.Now())
+
+	// Tokens belong to the namespace they are created in. Tokens of the root
+	// namespace leave it empty, like the ones created before namespaces
+	// existed.
+	if ns := namespace.FromContext(ctx); entry.NamespaceID == "" && ns.ID != namespace.RootNamespaceID {
+		entry.NamespaceID = ns.ID
+	}
+
+	switch entry.Type {
+	case logical.TokenTypeDefault:
+		entry.Type = logical.TokenTypeService
+	case logical.TokenTypeBatch:
+		entry.Policies = policyutil.SanitizePolicies(entry.Policies, policyutil.DoNotAddDefaultPolicy)
+		return ts.createBatchToken(ctx, entry)
+	}
+
 	// Generate an ID if necessary
 	if entry.ID == "" {
 		entryUUID, err := uuid.GenerateUUID()
@@ -785,6 +843,130 @@ func (ts *TokenStore) create(ctx context.Context, entry *TokenEntry) error {
 	return ts.storeCommon(ctx, entry, true)
 }
 
+// batchToken is the information carried by a batch token
+type batchToken struct {
+	Parent       string            `json:"parent,omitempty"`
+	Policies     []string          `json:"policies,omitempty"`
+	Path         string            `json:"path,omitempty"`
+	Meta         map[string]string `json:"meta,omitempty"`
+	DisplayName  string            `json:"display_name,omitempty"`
+	CreationTime int64             `json:"creation_time"`
+	TTL          time.Duration     `json:"ttl"`
+	Role         string            `json:"role,omitempty"`
+	EntityID     string            `json:"entity_id,omitempty"`
+	NamespaceID  string            `json:"namespace_id,omitempty"`
+	MFAMethod    string            `json:"mfa_method,omitempty"`
+}
+
+// isBatchToken returns whether the given token ID is the one of a batch token
+func isBatchToken(id string) bool {
+	return strings.HasPrefix(id, batchTokenPrefix)
+}
+
+// createBatchToken sets the ID of the entry to the encrypted form of its
+// contents. Nothing is written to storage.
+func (ts *TokenStore) createBatchToken(ctx context.Context, entry *TokenEntry) error {
+	switch {
+	case entry.ID != "":
+		return fmt.Errorf("batch tokens cannot have a custom ID")
+	case entry.TTL == 0:
+		return fmt.Errorf("batch tokens must have a TTL")
+	case entry.NumUses != 0:
+		return fmt.Errorf("batch tokens cannot have a limited number of uses")
+	case entry.Period != 0:
+		return fmt.Errorf("batch tokens cannot be periodic")
+	case strutil.StrListContains(entry.Policies, "root"):
+		return fmt.Errorf("batch tokens cannot be root tokens")
+	case ts.batchTokenEncryptor == nil:
+		return fmt.Errorf("batch tokens are not supported")
+	}
+
+	plaintext, err := jsonutil.EncodeJSON(&batchToken{
+		Parent:       entry.Parent,
+		Policies:     entry.Policies,
+		Path:         entry.Path,
+		Meta:         entry.Meta,
+		DisplayName:  entry.DisplayName,
+		CreationTime: entry.CreationTime,
+		TTL:          entry.TTL,
+		Role:         entry.Role,
+		EntityID:     entry.EntityID,
+		NamespaceID:  entry.NamespaceID,
+		MFAMethod:    entry.MFAMethod,
+	})
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := ts.batchTokenEncryptor.Encrypt(ctx, batchTokenEncryptionPath, plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt batch token: %v", err)
+	}
+
+	entry.ID = batchTokenPrefix + base64.RawURLEncoding.EncodeToString(ciphertext)
+	entry.Accessor = ""
+	return nil
+}
+
+// lookupBatchToken decrypts a batch token. Nil is returned if the token is
+// invalid, has expired, or its parent or namespace no longer exist.
+func (ts *TokenStore) lookupBatchToken(ctx context.Context, id string) (*TokenEntry, error) {
+	if ts.batchTokenEncryptor == nil {
+		return nil, nil
+	}
+
+	ciphertext, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(id, batchTokenPrefix))
+	if err != nil {
+		return nil, nil
+	}
+	plaintext, err := ts.batchTokenEncryptor.Decrypt(ctx, batchTokenEncryptionPath, ciphertext)
+	if err != nil {
+		// Anyone can make up a token, so a token that can't be decrypted is
+		// simply not a valid one
+		return nil, nil
+	}
+
+	var bt batchToken
+	if err := jsonutil.DecodeJSON(plaintext, &bt); err != nil {
+		return nil, fmt.Errorf("failed to decode batch token: %v", err)
+	}
+
+	if time.Now().After(time.Unix(bt.CreationTime, 0).Add(bt.TTL)) {
+		return nil, nil
+	}
+
+	if bt.NamespaceID != "" && (ts.namespaceLookupFunc == nil || ts.namespaceLookupFunc(bt.NamespaceID) == nil) {
+		return nil, nil
+	}
+
+	// Revoking the parent of a batch token also invalidates the token
+	if bt.Parent != "" {
+		parent, err := ts.Lookup(ctx, bt.Parent)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			return nil, nil
+		}
+	}
+
+	return &TokenEntry{
+		ID:           id,
+		Parent:       bt.Parent,
+		Policies:     bt.Policies,
+		Path:         bt.Path,
+		Meta:         bt.Meta,
+		DisplayName:  bt.DisplayName,
+		CreationTime: bt.CreationTime,
+		TTL:          bt.TTL,
+		Role:         bt.Role,
+		EntityID:     bt.EntityID,
+		NamespaceID:  bt.NamespaceID,
+		MFAMethod:    bt.MFAMethod,
+		Type:         logical.TokenTypeBatch,
+	}, nil
+}
+
 // Store is used to store an updated token entry without writing the
 // secondary index.
 func (ts *TokenStore) store(ctx context.Context, entry *TokenEntry) error {
@@ -928,6 +1110,10 @@ func (ts *TokenStore) Lookup(ctx context.Context, id string) (*TokenEntry, error
 		return nil, fmt.Errorf("cannot lookup blank token")
 	}
 
+	if isBatchToken(id) {
+		return ts.lookupBatchToken(ctx, id)
+	}
+
 	lock := locksutil.LockForKey(ts.tokenLocks, id)
 	lock.RLock()
 	defer lock.RUnlock()
@@ -947,6 +1133,10 @@ func (ts *TokenStore) lookupTainted(ctx context.Context, id string) (*TokenEntry
 		return nil, fmt.Errorf("cannot lookup blank token")
 	}
 
+	if isBatchToken(id) {
+		return ts.lookupBatchToken(ctx, id)
+	}
+
 	lock := locksutil.LockForKey(ts.tokenLocks, id)
 	lock.RLock()
 	defer lock.RUnlock()
@@ -985,6 +1175,11 @@ func (ts *TokenStore) lookupSalted(ctx context.Context, saltedID string, tainted
 		return nil, nil
 	}
 
+	// Tokens created before token types existed are service tokens
+	if entry.Type == logical.TokenTypeDefault {
+		entry.Type = logical.TokenTypeService
+	}
+
 	// If we are still restoring the expiration manager, we want to ensure the
 	// token is not expired
 	if ts.expiration == nil {
@@ -1050,6 +1245,9 @@ func (ts *TokenStore) Revoke(ctx context.Context, id string) error {
 	if id == "" {
 		return fmt.Errorf("cannot revoke blank token")
 	}
+	if isBatchToken(id) {
+		return fmt.Errorf("batch tokens cannot be revoked")
+	}
 
 	saltedID, err := ts.SaltID(ctx, id)
 	if err != nil {
@@ -1219,6 +1417,9 @@ func (ts *TokenStore) RevokeTree(ctx context.Context, id string) error {
 	if id == "" {
 		return fmt.Errorf("cannot tree-revoke blank token")
 	}
+	if isBatchToken(id) {
+		return fmt.Errorf("batch tokens cannot be revoked")
+	}
 
 	// Get the salted ID
 	saltedID, err := ts.SaltID(ctx, id)
@@ -1600,6 +1801,10 @@ func (ts *TokenStore) handleUpdateRevokeAccessor(ctx context.Context, req *logic
 		return nil, err
 	}
 
+	if resp, err := ts.checkTokenNamespace(ctx, aEntry.TokenID); resp != nil || err != nil {
+		return resp, err
+	}
+
 	// Revoke the token and its children
 	if err := ts.RevokeTree(ctx, aEntry.TokenID); err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
@@ -1641,6 +1846,12 @@ func (ts *TokenStore) handleCreateCommon(ctx context.Context, req *logical.Reque
 			logical.ErrInvalidRequest
 	}
 
+	// Batch tokens can't be revoked, so neither could their children
+	if parent.Type == logical.TokenTypeBatch {
+		return logical.ErrorResponse("batch tokens cannot generate child tokens"),
+			logical.ErrInvalidRequest
+	}
+
 	// Check if the client token has sudo/root privileges for the requested path
 	isSudo := ts.System().SudoPrivilege(ctx, req.MountPoint+req.Path, req.ClientToken)
 
@@ -1658,6 +1869,7 @@ func (ts *TokenStore) handleCreateCommon(ctx context.Context, req *logical.Reque
 		DisplayName     string `mapstructure:"display_name"`
 		NumUses         int    `mapstructure:"num_uses"`
 		Period          string
+		Type            string
 	}
 	if err := mapstructure.WeakDecode(req.Data, &data); err != nil {
 		return logical.ErrorResponse(fmt.Sprintf(
@@ -1670,14 +1882,21 @@ func (ts *TokenStore) handleCreateCommon(ctx context.Context, req *logical.Reque
 			logical.ErrInvalidRequest
 	}
 
+	tokenType, err := logical.ParseTokenType(data.Type)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	ns := namespace.FromContext(ctx)
+
 	// Setup the token entry
 	te := TokenEntry{
 		Parent: req.ClientToken,
 
 		// The mount point is always the same since we have only one token
-		// store; using req.MountPoint causes trouble in tests since they don't
-		// have an official mount
-		Path: fmt.Sprintf("auth/token/%s", req.Path),
+		// store, apart from the namespace; using req.MountPoint causes
+		// trouble in tests since they don't have an official mount
+		Path: fmt.Sprintf("%sauth/token/%s", ns.Path, req.Path),
 
 		Meta:         data.Metadata,
 		DisplayName:  "token",
@@ -1707,6 +1926,22 @@ func (ts *TokenStore) handleCreateCommon(ctx context.Context, req *logical.Reque
 		if role.PathSuffix != "" {
 			te.Path = fmt.Sprintf("%s/%s", te.Path, role.PathSuffix)
 		}
+
+		if role.TokenType != logical.TokenTypeDefault {
+			if tokenType != logical.TokenTypeDefault && tokenType != role.TokenType {
+				return logical.ErrorResponse(fmt.Sprintf("token type %q is not allowed by this role", tokenType)), logical.ErrInvalidRequest
+			}
+			tokenType = role.TokenType
+		}
+	}
+
+	te.Type = logical.TokenTypeService
+	if tokenType == logical.TokenTypeBatch {
+		if te.NumUses > 0 {
+			return logical.ErrorResponse("batch tokens cannot have a limited number of uses"), logical.ErrInvalidRequest
+		}
+		te.Type = logical.TokenTypeBatch
+		renewable = false
 	}
 
 	// Attach the given display name if any
@@ -1723,6 +1958,9 @@ func (ts *TokenStore) handleCreateCommon(ctx context.Context, req *logical.Reque
 			return logical.ErrorResponse("root or sudo privileges required to specify token id"),
 				logical.ErrInvalidRequest
 		}
+		if te.Type == logical.TokenTypeBatch {
+			return logical.ErrorResponse("batch tokens cannot have a custom ID"), logical.ErrInvalidRequest
+		}
 		te.ID = data.ID
 	}
 
@@ -1847,6 +2085,16 @@ func (ts *TokenStore) handleCreateCommon(ctx context.Context, req *logical.Reque
 		return logical.ErrorResponse("root tokens may not be created without parent token being root"), logical.ErrInvalidRequest
 	}
 
+	// The root policy only exists in the root namespace
+	if strutil.StrListContains(te.Policies, "root") && ns.ID != namespace.RootNamespaceID {
+		return logical.ErrorResponse("root tokens may not be created in a namespace"), logical.ErrInvalidRequest
+	}
+
+	// Batch tokens can't be revoked, so they can't be allowed to live forever
+	if strutil.StrListContains(te.Policies, "root") && te.Type == logical.TokenTypeBatch {
+		return logical.ErrorResponse("batch tokens cannot be root tokens"), logical.ErrInvalidRequest
+	}
+
 	//
 	// NOTE: Do not modify policies below this line. We need the checks above
 	// to be the last checks as they must look at the final policy set.
@@ -1963,6 +2211,10 @@ func (ts *TokenStore) handleCreateCommon(ctx context.Context, req *logical.Reque
 		}
 	}
 
+	if periodToUse > 0 && te.Type == logical.TokenTypeBatch {
+		return logical.ErrorResponse("batch tokens cannot be periodic"), logical.ErrInvalidRequest
+	}
+
 	sysView := ts.System()
 
 	// Only calculate a TTL if you are A) periodic, B) have a TTL, C) do not have a TTL and are not a root token
@@ -2014,7 +2266,7 @@ func (ts *TokenStore) handleCreateCommon(ctx context.Context, req *logical.Reque
 
 	if ts.policyLookupFunc != nil {
 		for _, p := range te.Policies {
-			policy, err := ts.policyLookupFunc(p)
+			policy, err := ts.policyLookupFunc(ctx, p)
 			if err != nil {
 				return logical.ErrorResponse(fmt.Sprintf("could not look up policy %s", p)), nil
 			}
@@ -2027,6 +2279,38 @@ func (ts *TokenStore) handleCreateCommon(ctx context.Context, req *logical.Reque
 	return resp, nil
 }
 
+// tokenInNamespace returns whether the token belongs to the namespace of the
+// request or to one of its children. Tokens can't be looked up or managed from
+// any other namespace.
+func (ts *TokenStore) tokenInNamespace(ctx context.Context, te *TokenEntry) bool {
+	ns := namespace.FromContext(ctx)
+	switch {
+	case ns.ID == namespace.RootNamespaceID, te.NamespaceID == ns.ID:
+		return true
+	case ts.namespaceLookupFunc == nil:
+		return false
+	}
+
+	teNS := ts.namespaceLookupFunc(te.NamespaceID)
+	return teNS != nil && teNS.HasParent(ns)
+}
+
+// checkTokenNamespace returns an error response if the token with the given ID
+// exists outside of the namespace of the request
+func (ts *TokenStore) checkTokenNamespace(ctx context.Context, id string) (*logical.Response, error) {
+	if id == "" {
+		return nil, nil
+	}
+	te, err := ts.lookupTainted(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if te != nil && !ts.tokenInNamespace(ctx, te) {
+		return logical.ErrorResponse("token not found"), logical.ErrInvalidRequest
+	}
+	return nil, nil
+}
+
 // handleRevokeSelf handles the auth/token/revoke-self path for revocation of tokens
 // in a way that revokes all child tokens. Normally, using sys/revoke/leaseID will revoke
 // the token and all children anyways, but that is only available when there is a lease.
@@ -2052,6 +2336,10 @@ func (ts *TokenStore) handleRevokeTree(ctx context.Context, req *logical.Request
 		urltoken = true
 	}
 
+	if resp, err := ts.checkTokenNamespace(ctx, id); resp != nil || err != nil {
+		return resp, err
+	}
+
 	// Revoke the token and its children
 	if err := ts.RevokeTree(ctx, id); err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
@@ -2097,6 +2385,10 @@ func (ts *TokenStore) handleRevokeOrphan(ctx context.Context, req *logical.Reque
 			logical.ErrInvalidRequest
 	}
 
+	if resp, err := ts.checkTokenNamespace(ctx, id); resp != nil || err != nil {
+		return resp, err
+	}
+
 	// Revoke and orphan
 	if err := ts.Revoke(ctx, id); err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
@@ -2134,21 +2426,13 @@ func (ts *TokenStore) handleLookup(ctx context.Context, req *logical.Request, da
 		return logical.ErrorResponse("missing token ID"), logical.ErrInvalidRequest
 	}
 
-	lock := locksutil.LockForKey(ts.tokenLocks, id)
-	lock.RLock()
-	defer lock.RUnlock()
-
 	// Lookup the token
-	saltedID, err := ts.SaltID(ctx, id)
-	if err != nil {
-		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
-	}
-	out, err := ts.lookupSalted(ctx, saltedID, true)
+	out, err := ts.lookupTainted(ctx, id)
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 
-	if out == nil {
+	if out == nil || (id != req.ClientToken && !ts.tokenInNamespace(ctx, out)) {
 		return logical.ErrorResponse("bad token"), logical.ErrPermissionDenied
 	}
 
@@ -2170,6 +2454,7 @@ func (ts *TokenStore) handleLookup(ctx context.Context, req *logical.Request, da
 			"ttl":              int64(0),
 			"explicit_max_ttl": int64(out.ExplicitMaxTTL.Seconds()),
 			"entity_id":        out.EntityID,
+			"type":             out.Type.String(),
 		},
 	}
 
@@ -2184,6 +2469,20 @@ func (ts *TokenStore) handleLookup(ctx context.Context, req *logical.Request, da
 		resp.Data["period"] = int64(out.Period.Seconds())
 	}
 
+	// Batch tokens have no lease, their expiration is part of the token
+	if out.Type == logical.TokenTypeBatch {
+		expireTime := time.Unix(out.CreationTime, 0).Add(out.TTL)
+		resp.Data["expire_time"] = expireTime
+		resp.Data["ttl"] = int64(time.Until(expireTime).Seconds())
+		resp.Data["renewable"] = false
+		resp.Data["issue_time"] = time.Unix(out.CreationTime, 0)
+
+		if urltoken {
+			resp.AddWarning(`Using a token in the path is unsafe as the token can be logged in many places. Please use POST or PUT with the token passed in via the "token" parameter.`)
+		}
+		return resp, nil
+	}
+
 	// Fetch the last renewal time
 	leaseTimes, err := ts.expiration.FetchLeaseTimesByToken(out.Path, out.ID)
 	if err != nil {
@@ -2239,10 +2538,14 @@ func (ts *TokenStore) handleRenew(ctx context.Context, req *logical.Request, dat
 	}
 
 	// Verify the token exists
-	if te == nil {
+	if te == nil || (id != req.ClientToken && !ts.tokenInNamespace(ctx, te)) {
 		return logical.ErrorResponse("token not found"), logical.ErrInvalidRequest
 	}
 
+	if te.Type == logical.TokenTypeBatch {
+		return logical.ErrorResponse("batch tokens cannot be renewed"), logical.ErrInvalidRequest
+	}
+
 	// Renew the token and its children
 	resp, err := ts.expiration.RenewToken(req, te.Path, te.ID, increment)
 
@@ -2293,8 +2596,18 @@ func (ts *TokenStore) authRenew(ctx context.Context, req *logical.Request, d *fr
 	return &logical.Response{Auth: req.Auth}, nil
 }
 
+// rolesPrefixForNamespace returns the storage prefix of the roles of the
+// namespace of the request
+func rolesPrefixForNamespace(ctx context.Context) string {
+	ns := namespace.FromContext(ctx)
+	if ns.ID == namespace.RootNamespaceID {
+		return rolesPrefix
+	}
+	return namespaceRolesPrefix + ns.ID + "/"
+}
+
 func (ts *TokenStore) tokenStoreRole(ctx context.Context, name string) (*tsRoleEntry, error) {
-	entry, err := ts.view.Get(ctx, fmt.Sprintf("%s%s", rolesPrefix, name))
+	entry, err := ts.view.Get(ctx, fmt.Sprintf("%s%s", rolesPrefixForNamespace(ctx), name))
 	if err != nil {
 		return nil, err
 	}
@@ -2311,21 +2624,22 @@ func (ts *TokenStore) tokenStoreRole(ctx context.Context, name string) (*tsRoleE
 }
 
 func (ts *TokenStore) tokenStoreRoleList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	entries, err := ts.view.List(ctx, rolesPrefix)
+	prefix := rolesPrefixForNamespace(ctx)
+	entries, err := ts.view.List(ctx, prefix)
 	if err != nil {
 		return nil, err
 	}
 
 	ret := make([]string, len(entries))
 	for i, entry := range entries {
-		ret[i] = strings.TrimPrefix(entry, rolesPrefix)
+		ret[i] = strings.TrimPrefix(entry, prefix)
 	}
 
 	return logical.ListResponse(ret), nil
 }
 
 func (ts *TokenStore) tokenStoreRoleDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	err := ts.view.Delete(ctx, fmt.Sprintf("%s%s", rolesPrefix, data.Get("role_name").(string)))
+	err := ts.view.Delete(ctx, fmt.Sprintf("%s%s", rolesPrefixForNamespace(ctx), data.Get("role_name").(string)))
 	if err != nil {
 		return nil, err
 	}
@@ -2352,6 +2666,7 @@ func (ts *TokenStore) tokenStoreRoleRead(ctx context.Context, req *logical.Reque
 			"orphan":              role.Orphan,
 			"path_suffix":         role.PathSuffix,
 			"renewable":           role.Renewable,
+			"token_type":          role.TokenType.String(),
 		},
 	}
 
@@ -2462,6 +2777,21 @@ func (ts *TokenStore) tokenStoreRoleCreateUpdate(ctx context.Context, req *logic
 		entry.AllowedPolicies = policyutil.SanitizePolicies(data.Get("allowed_policies").([]string), policyutil.DoNotAddDefaultPolicy)
 	}
 
+	tokenTypeRaw, ok := data.GetOk("token_type")
+	if !ok && req.Operation == logical.CreateOperation {
+		tokenTypeRaw = data.Get("token_type")
+	}
+	if tokenTypeRaw != nil {
+		tokenType, err := logical.ParseTokenType(tokenTypeRaw.(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		entry.TokenType = tokenType
+	}
+	if entry.TokenType == logical.TokenTypeBatch && entry.Period != 0 {
+		return logical.ErrorResponse("batch tokens cannot be periodic"), nil
+	}
+
 	disallowedPoliciesRaw, ok := data.GetOk("disallowed_policies")
 	if ok {
 		entry.DisallowedPolicies = strutil.RemoveDuplicates(disallowedPoliciesRaw.([]string), true)
@@ -2470,7 +2800,7 @@ func (ts *TokenStore) tokenStoreRoleCreateUpdate(ctx context.Context, req *logic
 	}
 
 	// Store it
-	jsonEntry, err := logical.StorageEntryJSON(fmt.Sprintf("%s%s", rolesPrefix, name), entry)
+	jsonEntry, err := logical.StorageEntryJSON(fmt.Sprintf("%s%s", rolesPrefixForNamespace(ctx), name), entry)
 	if err != nil {
 		return nil, err
 	}
@@ -2537,6 +2867,11 @@ no effect on the token being renewed.`
 	tokenRenewableHelp = `Tokens created via this role will be
 renewable or not according to this value.
 Defaults to "true".`
+	tokenTypeHelp = `The type of the tokens created via this role,
+either "service" or "batch". Batch tokens are
+not persisted and can't be renewed or revoked.
+If "default", the type is chosen when creating
+the token and defaults to "service".`
 	tokenListAccessorsHelp = `List token accessors, which can then be
 be used to iterate and discover their properties
 or revoke them. Because this can be used to
@@ -942,6 +942,7 @@ func TestTokenStore_HandleRequest_CreateToken_DisplayName(t *testing.T) {
 		Path:        "auth/token/create",
 		DisplayName: "token-foo-bar-baz",
 		TTL:         0,
+		Type:        logical.TokenTypeService,
 	}
 	out, err := ts.Lookup(context.Background(), resp.Auth.ClientToken)
 	if err != nil {
@@ -974,6 +975,7 @@ func TestTokenStore_HandleRequest_CreateToken_NumUses(t *testing.T) {
 		DisplayName: "token",
 		NumUses:     1,
 		TTL:         0,
+		Type:        logical.TokenTypeService,
 	}
 	out, err := ts.Lookup(context.Background(), resp.Auth.ClientToken)
 	if err != nil {
@@ -1037,6 +1039,7 @@ func TestTokenStore_HandleRequest_CreateToken_NoPolicy(t *testing.T) {
 		Path:        "auth/token/create",
 		DisplayName: "token",
 		TTL:         0,
+		Type:        logical.TokenTypeService,
 	}
 	out, err := ts.Lookup(context.Background(), resp.Auth.ClientToken)
 	if err != nil {
@@ -1505,6 +1508,7 @@ func TestTokenStore_HandleRequest_Lookup(t *testing.T) {
 		"explicit_max_ttl": int64(0),
 		"expire_time":      nil,
 		"entity_id":        "",
+		"type":             "service",
 	}
 
 	if resp.Data["creation_time"].(int64) == 0 {
@@ -1545,6 +1549,7 @@ func TestTokenStore_HandleRequest_Lookup(t *testing.T) {
 		"explicit_max_ttl": int64(0),
 		"renewable":        true,
 		"entity_id":        "",
+		"type":             "service",
 	}
 
 	if resp.Data["creation_time"].(int64) == 0 {
@@ -1596,6 +1601,7 @@ func TestTokenStore_HandleRequest_Lookup(t *testing.T) {
 		"explicit_max_ttl": int64(0),
 		"renewable":        true,
 		"entity_id":        "",
+		"type":             "service",
 	}
 
 	if resp.Data["creation_time"].(int64) == 0 {
@@ -1678,6 +1684,7 @@ func TestTokenStore_HandleRequest_LookupSelf(t *testing.T) {
 		"ttl":              int64(3600),
 		"explicit_max_ttl": int64(0),
 		"entity_id":        "",
+		"type":             "service",
 	}
 
 	if resp.Data["creation_time"].(int64) == 0 {
@@ -1848,6 +1855,7 @@ func TestTokenStore_RoleCRUD(t *testing.T) {
 		"path_suffix":         "happenin",
 		"explicit_max_ttl":    int64(0),
 		"renewable":           true,
+		"token_type":          "default",
 	}
 
 	if !reflect.DeepEqual(expected, resp.Data) {
@@ -1892,6 +1900,7 @@ func TestTokenStore_RoleCRUD(t *testing.T) {
 		"path_suffix":         "happenin",
 		"explicit_max_ttl":    int64(0),
 		"renewable":           false,
+		"token_type":          "default",
 	}
 
 	if !reflect.DeepEqual(expected, resp.Data) {
@@ -1929,6 +1938,7 @@ func TestTokenStore_RoleCRUD(t *testing.T) {
 		"path_suffix":         "happenin",
 		"period":              int64(0),
 		"renewable":           false,
+		"token_type":          "default",
 	}
 
 	if !reflect.DeepEqual(expected, resp.Data) {
@@ -3846,3 +3856,173 @@ func TestTokenStore_TidyLeaseRevocation(t *testing.T) {
 		t.Fatal("found leases")
 	}
 }
+
+func TestTokenStore_BatchTokens(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	ts := c.tokenStore
+
+	request := func(token, path string, data map[string]interface{}) (*logical.Response, error) {
+		req := logical.TestRequest(t, logical.UpdateOperation, path)
+		req.ClientToken = token
+		req.Data = data
+		return c.HandleRequest(req)
+	}
+
+	resp, err := request(root, "sys/policy/creator", map[string]interface{}{
+		"rules": `path "auth/token/create" { capabilities = ["update"] }`,
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	// A parent for the batch token, so that its revocation can be tested
+	resp, err = request(root, "auth/token/create", map[string]interface{}{
+		"policies": []string{"creator"},
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	parent := resp.Auth.ClientToken
+
+	resp, err = request(parent, "auth/token/create", map[string]interface{}{
+		"type": "batch",
+		"ttl":  "1h",
+	})
+	if err != nil || resp.IsError() {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	batch := resp.Auth.ClientToken
+	if !strings.HasPrefix(batch, batchTokenPrefix) || resp.Auth.Accessor != "" || resp.Auth.Renewable {
+		t.Fatalf("bad: %#v", resp.Auth)
+	}
+
+	// Nothing is stored for the token
+	saltedID, err := ts.SaltID(context.Background(), batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := ts.view.Get(context.Background(), lookupPrefix+saltedID); err != nil || out != nil {
+		t.Fatalf("expected no storage entry, got %#v %v", out, err)
+	}
+
+	req := logical.TestRequest(t, logical.ReadOperation, "auth/token/lookup-self")
+	req.ClientToken = batch
+	resp, err = c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if resp.Data["type"] != "batch" || resp.Data["orphan"] != false || resp.Data["renewable"] != false {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if ttl := resp.Data["ttl"].(int64); ttl <= 0 || ttl > 3600 {
+		t.Fatalf("bad ttl: %d", ttl)
+	}
+
+	// Batch tokens can't be renewed, revoked, create children or use a
+	// cubbyhole
+	for _, path := range []string{"auth/token/renew-self", "auth/token/revoke-self", "auth/token/create", "cubbyhole/foo"} {
+		resp, err := request(batch, path, map[string]interface{}{
+			"value": "bar",
+		})
+		if err == nil && !resp.IsError() {
+			t.Fatalf("expected an error on %q, got %#v", path, resp)
+		}
+	}
+
+	// Tampered tokens are invalid
+	if te, err := ts.Lookup(context.Background(), batch[:len(batch)-2]+"aa"); err != nil || te != nil {
+		t.Fatalf("expected no token, got %#v %v", te, err)
+	}
+
+	// Leases created with the token are tied to its parent
+	meUUID, err := uuid.GenerateUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.router.Mount(&NoopBackend{}, "prod/aws/", &MountEntry{Path: "prod/aws/", Type: "noop", UUID: meUUID, Accessor: "noop-accessor"}, NewBarrierView(c.barrier, "logical/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaseID, err := c.expiration.Register(&logical.Request{
+		ClientToken: batch,
+		Path:        "prod/aws/foo",
+	}, &logical.Response{
+		Secret: &logical.Secret{
+			LeaseOptions: logical.LeaseOptions{
+				TTL: time.Hour,
+			},
+		},
+		Data: map[string]interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leases, err := c.expiration.lookupByToken(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(leases, []string{leaseID}) {
+		t.Fatalf("bad: %#v", leases)
+	}
+
+	// Revoking the parent invalidates the token
+	if err := ts.RevokeTree(context.Background(), parent); err != nil {
+		t.Fatal(err)
+	}
+	if te, err := ts.Lookup(context.Background(), batch); err != nil || te != nil {
+		t.Fatalf("expected no token, got %#v %v", te, err)
+	}
+
+	// Batch tokens expire on their own
+	resp, err = request(root, "auth/token/create-orphan", map[string]interface{}{
+		"type":     "batch",
+		"ttl":      "1s",
+		"policies": []string{"default"},
+	})
+	if err != nil || resp.IsError() {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	batch = resp.Auth.ClientToken
+	if te, err := ts.Lookup(context.Background(), batch); err != nil || te == nil || te.Parent != "" {
+		t.Fatalf("expected a token, got %#v %v", te, err)
+	}
+	time.Sleep(2 * time.Second)
+	if te, err := ts.Lookup(context.Background(), batch); err != nil || te != nil {
+		t.Fatalf("expected no token, got %#v %v", te, err)
+	}
+
+	// Invalid options
+	for _, data := range []map[string]interface{}{
+		{"type": "batch"},
+		{"type": "batch", "policies": []string{"default"}, "num_uses": 1},
+		{"type": "batch", "policies": []string{"default"}, "period": "1h"},
+		{"type": "other"},
+	} {
+		resp, err := request(root, "auth/token/create", data)
+		if err == nil && !resp.IsError() {
+			t.Fatalf("expected an error creating %#v, got %#v", data, resp)
+		}
+	}
+
+	// Roles can force the token type
+	resp, err = request(root, "auth/token/roles/batch", map[string]interface{}{
+		"token_type":       "batch",
+		"allowed_policies": "default",
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	resp, err = request(root, "auth/token/create/batch", nil)
+	if err != nil || resp.IsError() {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if !strings.HasPrefix(resp.Auth.ClientToken, batchTokenPrefix) {
+		t.Fatalf("bad: %#v", resp.Auth)
+	}
+	resp, err = request(root, "auth/token/create/batch", map[string]interface{}{
+		"type": "service",
+	})
+	if err == nil && !resp.IsError() {
+		t.Fatalf("expected an error, got %#v", resp)
+	}
+}
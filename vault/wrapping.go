@@ -16,6 +16,7 @@ import (
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -100,6 +101,8 @@ func (c *Core) wrapInCubbyhole(ctx context.Context, req *logical.Request, resp *
 	// before auditing so that resp.WrapInfo.Token can contain the HMAC'd
 	// wrapping token ID in the audit logs, so that it can be determined from
 	// the audit logs whether the token was ever actually used.
+	isRewrap := namespace.FromContext(ctx).TrimmedPath(req.Path) == "sys/wrapping/rewrap"
+
 	creationTime := time.Now()
 	te := TokenEntry{
 		Path:           req.Path,
@@ -119,7 +122,7 @@ func (c *Core) wrapInCubbyhole(ctx context.Context, req *logical.Request, resp *
 	resp.WrapInfo.Accessor = te.Accessor
 	resp.WrapInfo.CreationTime = creationTime
 	// If this is not a rewrap, store the request path as creation_path
-	if req.Path != "sys/wrapping/rewrap" {
+	if !isRewrap {
 		resp.WrapInfo.CreationPath = req.Path
 	}
 
@@ -172,7 +175,7 @@ func (c *Core) wrapInCubbyhole(ctx context.Context, req *logical.Request, resp *
 	}
 
 	// During a rewrap, store the original response, don't wrap it again.
-	if req.Path == "sys/wrapping/rewrap" {
+	if isRewrap {
 		cubbyReq.Data = map[string]interface{}{
 			"response": resp.Data["response"],
 		}
@@ -221,7 +224,7 @@ func (c *Core) wrapInCubbyhole(ctx context.Context, req *logical.Request, resp *
 		"creation_time": creationTime,
 	}
 	// Store creation_path if not a rewrap
-	if req.Path != "sys/wrapping/rewrap" {
+	if !isRewrap {
 		cubbyReq.Data["creation_path"] = req.Path
 	} else {
 		cubbyReq.Data["creation_path"] = resp.WrapInfo.CreationPath